@@ -0,0 +1,182 @@
+// Command admin runs administrative tasks against the Hooked API.
+//
+// Usage:
+//
+//	admin [-url URL] [-key KEY] duplicates
+//	admin [-url URL] [-key KEY] merge [-dry-run] KEEP_ID REMOVE_ID
+//	admin [-url URL] [-key KEY] reopen [-end TIME] SLUG REASON
+//	admin [-url URL] [-key KEY] keys
+//	admin [-url URL] [-key KEY] create-key NAME SCOPE...
+//	admin [-url URL] [-key KEY] rotate-key ID
+//	admin [-url URL] [-key KEY] revoke-key ID
+//
+// The API key defaults to the HOOKED_API_KEY environment variable.
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"github.com/rejlersembriq/hooked/pkg/apikey"
+	"github.com/rejlersembriq/hooked/pkg/server"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+)
+
+// key is the API key sent with every request.
+var key string
+
+func usage() {
+	fmt.Fprintf(flag.CommandLine.Output(), `Usage:
+  %[1]s [-url URL] [-key KEY] duplicates
+        List participants likely to be registered more than once.
+  %[1]s [-url URL] [-key KEY] merge [-dry-run] KEEP_ID REMOVE_ID
+        Merge participant REMOVE_ID into KEEP_ID and delete REMOVE_ID.
+  %[1]s [-url URL] [-key KEY] reopen [-end TIME] SLUG REASON
+        Reopen the closed event SLUG, recording REASON. TIME is the new end, RFC 3339.
+  %[1]s [-url URL] [-key KEY] keys
+        List API keys.
+  %[1]s [-url URL] [-key KEY] create-key NAME SCOPE...
+        Create an API key with the scopes read, write-score and/or admin. The key is only shown once.
+  %[1]s [-url URL] [-key KEY] rotate-key ID
+        Replace API key ID with a new key, keeping its scopes. The old key stops working.
+  %[1]s [-url URL] [-key KEY] revoke-key ID
+        Revoke API key ID.
+
+`, os.Args[0])
+	flag.PrintDefaults()
+}
+
+func main() {
+	apiURL := flag.String("url", "http://localhost:8081", "Hooked API URL.")
+	flag.StringVar(&key, "key", os.Getenv("HOOKED_API_KEY"), "API key with the admin scope.")
+	flag.Usage = usage
+	flag.Parse()
+
+	if flag.NArg() < 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	client := &http.Client{
+		Timeout: 1 * time.Minute,
+	}
+
+	switch flag.Arg(0) {
+	case "duplicates":
+		do(client, http.MethodGet, *apiURL+"/admin/duplicates", nil)
+	case "merge":
+		merge(client, *apiURL, flag.Args()[1:])
+	case "reopen":
+		reopen(client, *apiURL, flag.Args()[1:])
+	case "keys":
+		do(client, http.MethodGet, *apiURL+"/admin/keys", nil)
+	case "create-key":
+		createKey(client, *apiURL, flag.Args()[1:])
+	case "rotate-key":
+		updateKey(client, *apiURL, "rotate", flag.Args()[1:])
+	case "revoke-key":
+		updateKey(client, *apiURL, "revoke", flag.Args()[1:])
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+}
+
+func merge(client *http.Client, apiURL string, args []string) {
+	fs := flag.NewFlagSet("merge", flag.ExitOnError)
+	dryRun := fs.Bool("dry-run", false, "Show the merged participant without saving it.")
+	fs.Parse(args)
+
+	if fs.NArg() != 2 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	payload, _ := json.Marshal(&server.MergeRequest{Keep: fs.Arg(0), Remove: fs.Arg(1), DryRun: *dryRun})
+
+	do(client, http.MethodPost, apiURL+"/admin/merge", bytes.NewReader(payload))
+}
+
+func reopen(client *http.Client, apiURL string, args []string) {
+	fs := flag.NewFlagSet("reopen", flag.ExitOnError)
+	end := fs.String("end", "", "New end time of the event, RFC 3339. Required if the end has passed.")
+	fs.Parse(args)
+
+	if fs.NArg() != 2 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	rr := server.ReopenRequest{Reason: fs.Arg(1)}
+	if *end != "" {
+		t, err := time.Parse(time.RFC3339, *end)
+		if err != nil {
+			log.Fatalf("Invalid end %q. Must be a RFC 3339 timestamp.", *end)
+		}
+		rr.End = &t
+	}
+
+	payload, _ := json.Marshal(&rr)
+
+	do(client, http.MethodPost, apiURL+"/admin/event/"+url.PathEscape(fs.Arg(0))+"/reopen", bytes.NewReader(payload))
+}
+
+func createKey(client *http.Client, apiURL string, args []string) {
+	if len(args) < 2 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	payload, _ := json.Marshal(&apikey.Request{Name: args[0], Scopes: args[1:]})
+
+	do(client, http.MethodPost, apiURL+"/admin/key", bytes.NewReader(payload))
+}
+
+// updateKey rotates or revokes a key.
+func updateKey(client *http.Client, apiURL, action string, args []string) {
+	if len(args) != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	do(client, http.MethodPost, apiURL+"/admin/key/"+url.PathEscape(args[0])+"/"+action, nil)
+}
+
+// do sends the request and prints the response body as indented json.
+func do(client *http.Client, method, url string, body io.Reader) {
+	req, err := http.NewRequest(method, url, body)
+	if err != nil {
+		log.Fatalf("Error creating request. Error: %v", err)
+	}
+
+	if key != "" {
+		req.Header.Set("X-API-Key", key)
+	}
+
+	res, err := client.Do(req)
+	if err != nil {
+		log.Fatalf("Error during %s %s. Error: %v", method, url, err)
+	}
+	defer res.Body.Close()
+
+	b, _ := ioutil.ReadAll(res.Body)
+
+	var out bytes.Buffer
+	if err := json.Indent(&out, b, "", "  "); err != nil {
+		out.Reset()
+		out.Write(b)
+	}
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		log.Fatalf("Error during %s %s. Status: %d\n%s", method, url, res.StatusCode, out.String())
+	}
+
+	fmt.Println(out.String())
+}
@@ -1,9 +1,12 @@
 package main
 
 import (
+	"errors"
 	"github.com/aws/aws-lambda-go/lambda"
 	"github.com/aws/aws-sdk-go-v2/aws/external"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/rejlersembriq/hooked/pkg/attempt"
+	"github.com/rejlersembriq/hooked/pkg/auth"
 	"github.com/rejlersembriq/hooked/pkg/lambdahandler"
 	"github.com/rejlersembriq/hooked/pkg/repository/dynamo"
 	"github.com/rejlersembriq/hooked/pkg/router"
@@ -12,16 +15,37 @@ import (
 	"go.uber.org/zap/zapcore"
 	"log"
 	"os"
+	"time"
 )
 
 // Environment variable names
 const (
-	tableName = "TABLE_NAME"
-	awsRegion = "REGION"
+	tableName            = "TABLE_NAME"
+	awsRegion            = "REGION"
+	idempotencyTableName = "IDEMPOTENCY_TABLE_NAME"
+	idempotencyWindow    = "IDEMPOTENCY_WINDOW"
+	attemptTableName     = "ATTEMPT_TABLE_NAME"
+	scoreRule            = "SCORE_RULE"
+	eventTableName       = "EVENT_TABLE_NAME"
+	drawTableName        = "DRAW_TABLE_NAME"
+	adminKey             = "ADMIN_KEY"
+	apiKeyTableName      = "API_KEY_TABLE_NAME"
+	jwtSecret            = "JWT_SECRET"
+	jwksFile             = "JWKS_FILE"
+	jwtIssuer            = "JWT_ISSUER"
+	jwtAudience          = "JWT_AUDIENCE"
+	jwtRolesClaim        = "JWT_ROLES_CLAIM"
+	jwtRoleMap           = "JWT_ROLE_MAP"
+	authorizerRolesClaim = "AUTHORIZER_ROLES_CLAIM"
+	authorizerRoleMap    = "AUTHORIZER_ROLE_MAP"
 )
 
+// How long idempotency keys are kept unless overridden by IDEMPOTENCY_WINDOW.
+const defaultIdempotencyWindow = 24 * time.Hour
+
 var rtr *router.Router
 var dyna *dynamo.Dynamo
+var opts []server.Option
 
 func init() {
 	config := zap.NewProductionEncoderConfig()
@@ -48,11 +72,81 @@ func init() {
 	}
 	conf.Region = region
 
-	dyna = dynamo.New(dynamodb.New(conf), table)
+	client := dynamodb.New(conf)
+	dyna = dynamo.New(client, table)
+
+	// Idempotency keys need to outlive the lambda instance, so they are only honoured with a table.
+	if idempotencyTable, exists := os.LookupEnv(idempotencyTableName); exists {
+		window := defaultIdempotencyWindow
+		if v, exists := os.LookupEnv(idempotencyWindow); exists {
+			if window, err = time.ParseDuration(v); err != nil || window <= 0 {
+				log.Fatalf("invalid %s %q", idempotencyWindow, v)
+			}
+		}
+
+		opts = append(opts, server.WithIdempotency(dynamo.NewIdempotencyStore(client, idempotencyTable), window))
+	}
+
+	if attemptTable, exists := os.LookupEnv(attemptTableName); exists {
+		rule, err := attempt.ParseRule(os.Getenv(scoreRule))
+		if err != nil {
+			log.Fatalf("invalid %s %q", scoreRule, os.Getenv(scoreRule))
+		}
+
+		opts = append(opts, server.WithAttempts(dynamo.NewAttemptRepository(client, attemptTable), rule))
+	}
+
+	if eventTable, exists := os.LookupEnv(eventTableName); exists {
+		opts = append(opts, server.WithEvents(dynamo.NewEventRepository(client, eventTable)))
+	}
+
+	if drawTable, exists := os.LookupEnv(drawTableName); exists {
+		opts = append(opts, server.WithDraws(dynamo.NewDrawRepository(client, drawTable)))
+	}
+
+	// Keys need to outlive the lambda instance, so they're only required with a table.
+	if key, exists := os.LookupEnv(adminKey); exists {
+		keyTable, exists := os.LookupEnv(apiKeyTableName)
+		if !exists {
+			log.Fatalf("%s requires %s", adminKey, apiKeyTableName)
+		}
+
+		opts = append(opts, server.WithAPIKeys(dynamo.NewAPIKeyRepository(client, keyTable), key))
+	}
+
+	// Empty values are unset, as the stack passes every optional parameter. The key set is read from the deployment
+	// package.
+	jwtOpt, err := server.JWTOption(server.JWTConfig{
+		Secret:     os.Getenv(jwtSecret),
+		KeySetFile: os.Getenv(jwksFile),
+		Issuer:     os.Getenv(jwtIssuer),
+		Audience:   os.Getenv(jwtAudience),
+		RolesClaim: os.Getenv(jwtRolesClaim),
+		RoleMap:    os.Getenv(jwtRoleMap),
+	})
+	if errors.Is(err, server.ErrJWTAudienceRequired) {
+		log.Fatalf("%s must be specified with %s", jwtAudience, jwksFile)
+	}
+	if err != nil {
+		log.Fatalf("invalid JWT settings: %v", err)
+	}
+	if jwtOpt != nil {
+		opts = append(opts, jwtOpt)
+	}
+
+	// Claims of an API Gateway authorizer are only trusted when the claim holding the roles is named.
+	if claim := os.Getenv(authorizerRolesClaim); claim != "" {
+		mapping, err := auth.ParseMapping(os.Getenv(authorizerRoleMap))
+		if err != nil {
+			log.Fatalf("invalid %s: %v", authorizerRoleMap, err)
+		}
+
+		opts = append(opts, server.WithAuthorizerClaims(auth.RoleMapper{Claim: claim, Mapping: mapping}))
+	}
 }
 
 func main() {
 	lambda.Start(lambdahandler.Handler{
-		Handler: server.New(rtr, dyna),
+		Handler: server.New(rtr, dyna, opts...),
 	}.Handle)
 }
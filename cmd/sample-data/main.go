@@ -10,6 +10,7 @@ import (
 	"log"
 	"math/rand"
 	"net/http"
+	"os"
 	"strconv"
 	"time"
 )
@@ -89,12 +90,27 @@ func getPhoneNo(someInt int, len int) string {
 	return phoneNo
 }
 
+// apiKeyTransport sends the API key in the HOOKED_API_KEY environment variable with every request.
+type apiKeyTransport struct {
+	key string
+}
+
+func (t apiKeyTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.Header.Set("X-API-Key", t.key)
+	return http.DefaultTransport.RoundTrip(req)
+}
+
 func main() {
 
 	client := &http.Client{
 		Timeout: 1 * time.Minute,
 	}
 
+	if key, exists := os.LookupEnv("HOOKED_API_KEY"); exists {
+		client.Transport = apiKeyTransport{key: key}
+	}
+
 	populateWithTestData(client)
 	//deleteParticipant(client, "0d42191f-0284-4681-bbbd-e4316f5b8857")
 	//deleteAll(client)
@@ -148,24 +164,34 @@ func deleteParticipant(client *http.Client, id string) {
 }
 
 func deleteAll(client *http.Client) {
-	req, err := http.NewRequest(http.MethodGet, apiURL+"/participants", nil)
+	var cursor string
 
-	res, err := client.Do(req)
-	if err != nil {
-		log.Fatalf("Error during participants GET. Error: %v", err)
-	}
+	for {
+		req, err := http.NewRequest(http.MethodGet, apiURL+"/participants?cursor="+cursor, nil)
 
-	if res.StatusCode < 200 || res.StatusCode > 299 {
-		log.Fatalf("Error during participants GET. Status: %d", res.StatusCode)
-	}
+		res, err := client.Do(req)
+		if err != nil {
+			log.Fatalf("Error during participants GET. Error: %v", err)
+		}
 
-	defer res.Body.Close()
-	var participants []*participant.Participant
-	if err := json.NewDecoder(res.Body).Decode(&participants); err != nil {
-		log.Fatalf("Error getting participants response: %v", err)
-	}
+		if res.StatusCode < 200 || res.StatusCode > 299 {
+			log.Fatalf("Error during participants GET. Status: %d", res.StatusCode)
+		}
 
-	for _, p := range participants {
-		deleteParticipant(client, *p.ID)
+		var page participant.Page
+		if err := json.NewDecoder(res.Body).Decode(&page); err != nil {
+			log.Fatalf("Error getting participants response: %v", err)
+		}
+		res.Body.Close()
+
+		for _, p := range page.Participants {
+			deleteParticipant(client, *p.ID)
+		}
+
+		// Deleting while paging is fine as the cursor points to the last participant seen.
+		if page.Next == "" {
+			return
+		}
+		cursor = page.Next
 	}
 }
@@ -1,10 +1,25 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/external"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/rejlersembriq/hooked/pkg/apikey"
+	"github.com/rejlersembriq/hooked/pkg/attempt"
+	"github.com/rejlersembriq/hooked/pkg/draw"
+	"github.com/rejlersembriq/hooked/pkg/event"
+	"github.com/rejlersembriq/hooked/pkg/idempotency"
+	"github.com/rejlersembriq/hooked/pkg/leaderboard"
+	"github.com/rejlersembriq/hooked/pkg/participant"
+	"github.com/rejlersembriq/hooked/pkg/repository/dynamo"
+	"github.com/rejlersembriq/hooked/pkg/repository/file"
 	"github.com/rejlersembriq/hooked/pkg/repository/memory"
 	"github.com/rejlersembriq/hooked/pkg/router"
 	"github.com/rejlersembriq/hooked/pkg/server"
+	"github.com/rejlersembriq/hooked/pkg/webhook"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
 	"log"
@@ -15,6 +30,62 @@ import (
 
 var version = "No version provided"
 
+// Environment variable names
+const (
+	envPort           = "port"
+	envStorage        = "storage"
+	envTableName      = "tableName"
+	envRegion         = "region"
+	envDynamoEndpoint = "dynamoEndpoint"
+	envDataFile       = "dataFile"
+
+	envIdempotencyTableName = "idempotencyTableName"
+	envIdempotencyWindow    = "idempotencyWindow"
+
+	envAttemptTableName = "attemptTableName"
+	envScoreRule        = "scoreRule"
+
+	envEventTableName = "eventTableName"
+
+	envDrawTableName = "drawTableName"
+
+	envAdminKey        = "adminKey"
+	envAPIKeyTableName = "apiKeyTableName"
+
+	envJWTSecret     = "jwtSecret"
+	envJWKSFile      = "jwksFile"
+	envJWTIssuer     = "jwtIssuer"
+	envJWTAudience   = "jwtAudience"
+	envJWTRolesClaim = "jwtRolesClaim"
+	envJWTRoleMap    = "jwtRoleMap"
+
+	envWebhookTableName         = "webhookTableName"
+	envWebhookDeliveryTableName = "webhookDeliveryTableName"
+)
+
+// How long idempotency keys are kept unless overridden by the 'idempotencyWindow' environment variable.
+const defaultIdempotencyWindow = 24 * time.Hour
+
+// minAdminKeyLength keeps the admin key from being guessed.
+const minAdminKeyLength = 32
+
+// How often events past their end time are saved as closed.
+const eventCloseInterval = time.Minute
+
+// Live leaderboard settings: the interval between heartbeats, and the number of updates kept per topic for clients
+// resuming after reconnecting.
+const (
+	streamHeartbeat = 15 * time.Second
+	streamHistory   = 1000
+)
+
+// Storage options
+const (
+	storageMemory = "memory"
+	storageDynamo = "dynamo"
+	storageFile   = "file"
+)
+
 func main() {
 	config := zap.NewProductionEncoderConfig()
 	config.EncodeTime = zapcore.ISO8601TimeEncoder
@@ -31,17 +102,98 @@ func main() {
 
 	zap.L().Info("Starting hooked.", zap.String("version", version))
 
-	port, exists := os.LookupEnv("port")
+	port, exists := os.LookupEnv(envPort)
 	if !exists {
 		zap.L().Fatal("Port not specified. Specify via 'port' environment variable")
 	}
 
+	store, err := newStorage()
+	if err != nil {
+		zap.L().Fatal("Error setting up storage.", zap.String("error", err.Error()))
+	}
+
+	window, err := idempotencyWindow()
+	if err != nil {
+		zap.L().Fatal("Error setting up idempotency.", zap.String("error", err.Error()))
+	}
+
+	opts := []server.Option{server.WithIdempotency(store.idempotency, window)}
+
+	if adminKey, exists := os.LookupEnv(envAdminKey); exists {
+		if len(adminKey) < minAdminKeyLength {
+			zap.L().Fatal("Admin key too short.", zap.Int("minLength", minAdminKeyLength))
+		}
+
+		keys := store.keys
+		if keys == nil {
+			zap.L().Info("API key table not specified. API keys are kept in memory.")
+			keys = memory.NewAPIKeyRepository()
+		}
+
+		zap.L().Info("Requiring API keys.")
+		opts = append(opts, server.WithAPIKeys(keys, adminKey))
+	} else {
+		zap.L().Info("Admin key not specified. API keys aren't required.")
+	}
+
+	jwtOpt, err := jwtOption()
+	if err != nil {
+		zap.L().Fatal("Error setting up JWT.", zap.String("error", err.Error()))
+	}
+
+	if jwtOpt != nil {
+		opts = append(opts, jwtOpt)
+	}
+
+	if store.attempts != nil {
+		rule, err := attempt.ParseRule(os.Getenv(envScoreRule))
+		if err != nil {
+			zap.L().Fatal("Invalid score rule. Valid values: best, latest, sum, topN, eg. top3.", zap.String("scoreRule", os.Getenv(envScoreRule)))
+		}
+
+		zap.L().Info("Recording score attempts.", zap.String("scoreRule", rule.String()))
+		opts = append(opts, server.WithAttempts(store.attempts, rule))
+	}
+
+	if store.events != nil {
+		opts = append(opts, server.WithEvents(store.events))
+		go closeEvents(store.events, eventCloseInterval)
+	}
+
+	if store.draws != nil {
+		opts = append(opts, server.WithDraws(store.draws))
+	}
+
+	// Changes are only seen when made through this server, eg. not by other instances or cmd/admin.
+	participants := participant.Observe(store.participants)
+
+	ps, err := participants.GetAll(context.Background())
+	if err != nil {
+		zap.L().Fatal("Error loading leaderboard.", zap.String("error", err.Error()))
+	}
+
+	hub := leaderboard.NewHub(ps, leaderboard.TieShared, streamHistory)
+	participants.OnChange(hub.Apply)
+	opts = append(opts, server.WithLiveLeaderboard(hub, streamHeartbeat))
+
+	if store.hooks != nil {
+		dispatcher := webhook.NewDispatcher(store.hooks, store.deliveries)
+		defer dispatcher.Close()
+
+		if err := dispatcher.Resume(context.Background()); err != nil {
+			zap.L().Error("Error resuming webhook deliveries.", zap.String("error", err.Error()))
+		}
+
+		participants.OnChange(dispatcher.Notify)
+		opts = append(opts, server.WithWebhooks(store.hooks, store.deliveries, dispatcher))
+	}
+
+	// No write timeout, as it would end the leaderboard stream.
 	srv := &http.Server{
-		Addr:         fmt.Sprintf(":%s", port),
-		Handler:      server.New(router.New(), memory.New()),
-		ReadTimeout:  15 * time.Second,
-		WriteTimeout: 30 * time.Second,
-		IdleTimeout:  60 * time.Second,
+		Addr:        fmt.Sprintf(":%s", port),
+		Handler:     server.New(router.New(), participants, opts...),
+		ReadTimeout: 15 * time.Second,
+		IdleTimeout: 60 * time.Second,
 	}
 
 	zap.L().Info("Starting server.", zap.String("address", srv.Addr))
@@ -49,3 +201,196 @@ func main() {
 		zap.L().Info("Error serving http.", zap.String("error", err.Error()))
 	}
 }
+
+// storage holds the repositories selected by the environment. Attempts is nil if attempts aren't recorded, events is
+// nil if events aren't enabled, draws is nil if prize draws aren't enabled, and hooks and deliveries are nil if
+// webhooks aren't enabled. Keys is nil if API keys should be kept in memory.
+type storage struct {
+	participants participant.Repository
+	idempotency  idempotency.Store
+	keys         apikey.Repository
+	attempts     attempt.Repository
+	events       event.Repository
+	draws        draw.Repository
+	hooks        webhook.Repository
+	deliveries   webhook.DeliveryRepository
+}
+
+// newStorage returns the repositories selected by the 'storage' environment variable. Defaults to memory.
+// Dynamo requires 'tableName' and takes an optional 'region' and 'dynamoEndpoint', eg. for DynamoDB Local.
+// Idempotency keys are kept in the 'idempotencyTableName' table if set, otherwise in memory. Attempts are recorded in
+// the 'attemptTableName' table if set, events are enabled with the 'eventTableName' table if set, prize draws with
+// the 'drawTableName' table if set, and webhooks with the 'webhookTableName' and 'webhookDeliveryTableName' tables if
+// both are set. API keys are kept in the 'apiKeyTableName' table if set.
+// File requires 'dataFile' and keeps idempotency keys in memory. Attempts aren't recorded, and neither events, draws
+// nor webhooks are enabled with file storage.
+func newStorage() (*storage, error) {
+	kind := os.Getenv(envStorage)
+
+	switch kind {
+	case "", storageMemory:
+		zap.L().Info("Using memory storage. Data is lost on restart.")
+		return &storage{
+			participants: memory.New(),
+			idempotency:  memory.NewIdempotencyStore(),
+			keys:         memory.NewAPIKeyRepository(),
+			attempts:     memory.NewAttemptRepository(),
+			events:       memory.NewEventRepository(),
+			draws:        memory.NewDrawRepository(),
+			hooks:        memory.NewWebhookRepository(),
+			deliveries:   memory.NewDeliveryRepository(),
+		}, nil
+	case storageDynamo:
+		table, exists := os.LookupEnv(envTableName)
+		if !exists {
+			return nil, fmt.Errorf("table not specified. Specify via '%s' environment variable", envTableName)
+		}
+
+		conf, err := external.LoadDefaultAWSConfig()
+		if err != nil {
+			return nil, fmt.Errorf("unable to load SDK config: %v", err)
+		}
+
+		if region, exists := os.LookupEnv(envRegion); exists {
+			conf.Region = region
+		}
+
+		if endpoint, exists := os.LookupEnv(envDynamoEndpoint); exists {
+			conf.EndpointResolver = aws.ResolveWithEndpointURL(endpoint)
+		}
+
+		client := dynamodb.New(conf)
+
+		s := &storage{
+			participants: dynamo.New(client, table),
+			idempotency:  memory.NewIdempotencyStore(),
+		}
+
+		if idempotencyTable, exists := os.LookupEnv(envIdempotencyTableName); exists {
+			s.idempotency = dynamo.NewIdempotencyStore(client, idempotencyTable)
+		} else {
+			zap.L().Info("Idempotency table not specified. Idempotency keys are kept in memory.")
+		}
+
+		if keyTable, exists := os.LookupEnv(envAPIKeyTableName); exists {
+			s.keys = dynamo.NewAPIKeyRepository(client, keyTable)
+		}
+
+		if attemptTable, exists := os.LookupEnv(envAttemptTableName); exists {
+			s.attempts = dynamo.NewAttemptRepository(client, attemptTable)
+		} else {
+			zap.L().Info("Attempt table not specified. Score attempts aren't recorded.")
+		}
+
+		if eventTable, exists := os.LookupEnv(envEventTableName); exists {
+			s.events = dynamo.NewEventRepository(client, eventTable)
+		} else {
+			zap.L().Info("Event table not specified. Events aren't enabled.")
+		}
+
+		if drawTable, exists := os.LookupEnv(envDrawTableName); exists {
+			s.draws = dynamo.NewDrawRepository(client, drawTable)
+		} else {
+			zap.L().Info("Draw table not specified. Prize draws aren't enabled.")
+		}
+
+		webhookTable, hooksExist := os.LookupEnv(envWebhookTableName)
+		deliveryTable, deliveriesExist := os.LookupEnv(envWebhookDeliveryTableName)
+		if hooksExist && deliveriesExist {
+			s.hooks = dynamo.NewWebhookRepository(client, webhookTable)
+			s.deliveries = dynamo.NewDeliveryRepository(client, deliveryTable)
+		} else {
+			zap.L().Info("Webhook tables not specified. Webhooks aren't enabled.")
+		}
+
+		zap.L().Info("Using dynamo storage.", zap.String("table", table), zap.String("region", conf.Region))
+		return s, nil
+	case storageFile:
+		path, exists := os.LookupEnv(envDataFile)
+		if !exists {
+			return nil, fmt.Errorf("data file not specified. Specify via '%s' environment variable", envDataFile)
+		}
+
+		f, err := file.New(path)
+		if err != nil {
+			return nil, err
+		}
+
+		zap.L().Info("Using file storage. Score attempts aren't recorded, and neither events, prize draws nor webhooks are enabled.", zap.String("path", path))
+		return &storage{participants: f, idempotency: memory.NewIdempotencyStore()}, nil
+	default:
+		return nil, fmt.Errorf("invalid storage '%s'. Valid values: %s, %s, %s", kind, storageMemory, storageDynamo, storageFile)
+	}
+}
+
+// closeEvents saves events past their end time as closed every interval. The server treats them as closed already,
+// this makes the stored status match.
+func closeEvents(repo event.Repository, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for now := range ticker.C {
+		ctx, cancel := context.WithTimeout(context.Background(), interval)
+		closed, err := event.CloseExpired(ctx, repo, now)
+		cancel()
+
+		if err != nil {
+			zap.L().Error("Error closing events.", zap.String("error", err.Error()))
+		}
+
+		for _, e := range closed {
+			zap.L().Info("Closed event.", zap.String("slug", e.Slug))
+		}
+	}
+}
+
+// jwtOption accepts JWTs signed with the 'jwtSecret' (HS256) or a key of the JSON Web Key Set in 'jwksFile' (RS256)
+// environment variables, issued by 'jwtIssuer' for 'jwtAudience' if specified. 'jwtAudience' is required with a key
+// set, see server.ErrJWTAudienceRequired. Roles are read from the 'jwtRolesClaim' claim, mapped by 'jwtRoleMap', eg.
+// "staff=scorer,it=admin". Returns nil if neither secret nor key set is specified.
+func jwtOption() (server.Option, error) {
+	c := server.JWTConfig{
+		Secret:     os.Getenv(envJWTSecret),
+		KeySetFile: os.Getenv(envJWKSFile),
+		Issuer:     os.Getenv(envJWTIssuer),
+		Audience:   os.Getenv(envJWTAudience),
+		RolesClaim: os.Getenv(envJWTRolesClaim),
+		RoleMap:    os.Getenv(envJWTRoleMap),
+	}
+	if !c.Enabled() {
+		zap.L().Info("JWT secret or key set not specified. JWTs aren't accepted.")
+		return nil, nil
+	}
+
+	opt, err := server.JWTOption(c)
+	if errors.Is(err, server.ErrJWTAudienceRequired) {
+		return nil, fmt.Errorf("'%s' must be specified with '%s': %w", envJWTAudience, envJWKSFile, err)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	claim := c.RolesClaim
+	if claim == "" {
+		claim = server.DefaultJWTRolesClaim
+	}
+
+	zap.L().Info("Accepting JWTs.", zap.Bool("HS256", c.Secret != ""), zap.Bool("RS256", c.KeySetFile != ""), zap.String("rolesClaim", claim))
+	return opt, nil
+}
+
+// idempotencyWindow returns how long idempotency keys are kept, from the 'idempotencyWindow' environment variable
+// as a duration, eg. "1h". Defaults to 24 hours.
+func idempotencyWindow() (time.Duration, error) {
+	v, exists := os.LookupEnv(envIdempotencyWindow)
+	if !exists {
+		return defaultIdempotencyWindow, nil
+	}
+
+	window, err := time.ParseDuration(v)
+	if err != nil || window <= 0 {
+		return 0, fmt.Errorf("invalid '%s' %q. Must be a positive duration, eg. 24h", envIdempotencyWindow, v)
+	}
+
+	return window, nil
+}
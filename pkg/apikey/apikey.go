@@ -0,0 +1,205 @@
+// Package apikey authenticates API clients. A key is a token handed to the client once, when the key is created or
+// rotated. Only the SHA-256 hash of its secret is stored, so the stored keys can't be used to call the API. Every key
+// has scopes limiting what it may do, see Key.Allows.
+package apikey
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/rejlersembriq/hooked/pkg/participant"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Scopes, from least to most permissive. Every scope includes the scopes before it.
+const (
+	// ScopeRead reads participants, organisations and events.
+	ScopeRead = "read"
+	// ScopeWriteScore registers participants and records their scores.
+	ScopeWriteScore = "write-score"
+	// ScopeAdmin deletes participants, manages events, draws, webhooks and keys.
+	ScopeAdmin = "admin"
+)
+
+// Scopes lists the scopes of keys, from least to most permissive.
+var Scopes = []string{ScopeRead, ScopeWriteScore, ScopeAdmin}
+
+// ErrNotExist should be returned when the requested key is not found in the repository.
+var ErrNotExist = errors.New("api key doesn't exist")
+
+// ErrRevoked should be returned when rotating or revoking a revoked key.
+var ErrRevoked = errors.New("api key is revoked")
+
+// ErrInvalidToken is returned when a token isn't in the format issued by New.
+var ErrInvalidToken = errors.New("invalid api key")
+
+// tokenPrefix starts every token, making leaked keys easy to find.
+const tokenPrefix = "hk_"
+
+// secretBytes is the length of generated secrets, before hex encoding.
+const secretBytes = 32
+
+// MaxNameLength limits the name of a key.
+const MaxNameLength = 100
+
+// Request describes a key to create.
+type Request struct {
+	Name   string   `json:"name"`
+	Scopes []string `json:"scopes"`
+}
+
+// Key is a stored key. Hash is the hex encoded SHA-256 hash of the secret of its token. The times are named for
+// DynamoDb, as Rotate and Revoke set them by name.
+type Key struct {
+	ID      string     `json:"id"`
+	Name    string     `json:"name"`
+	Scopes  []string   `json:"scopes"`
+	Hash    string     `json:"hash,omitempty"`
+	Created time.Time  `json:"created" dynamodbav:"created,unixtime"`
+	Rotated *time.Time `json:"rotated,omitempty" dynamodbav:"rotated,omitempty,unixtime"`
+	Revoked *time.Time `json:"revoked,omitempty" dynamodbav:"revoked,omitempty,unixtime"`
+}
+
+// Clone returns a copy of k where no field shares memory with k.
+func (k Key) Clone() Key {
+	if k.Scopes != nil {
+		k.Scopes = append([]string(nil), k.Scopes...)
+	}
+	if k.Rotated != nil {
+		t := *k.Rotated
+		k.Rotated = &t
+	}
+	if k.Revoked != nil {
+		t := *k.Revoked
+		k.Revoked = &t
+	}
+	return k
+}
+
+// Allows reports if the key grants scope, by one of its scopes or a more permissive one. Revoked keys grant nothing.
+func (k Key) Allows(scope string) bool {
+	if k.Revoked != nil {
+		return false
+	}
+
+	for _, s := range k.Scopes {
+		if rank(s) >= rank(scope) && rank(scope) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// Verify reports if secret is the secret of the key, comparing in constant time.
+func (k Key) Verify(secret string) bool {
+	return subtle.ConstantTimeCompare([]byte(Hash(secret)), []byte(k.Hash)) == 1
+}
+
+// rank orders scopes by permissiveness, starting at 1. Unknown scopes are 0.
+func rank(scope string) int {
+	for i, s := range Scopes {
+		if s == scope {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+// Repository persists keys. Keys can't be deleted, so revoked keys are kept for auditing. Only the hash of a key's
+// secret is stored.
+type Repository interface {
+	Create(ctx context.Context, k Key) (*Key, error)
+	Get(ctx context.Context, id string) (*Key, error)
+	// List returns all keys, newest first.
+	List(ctx context.Context) ([]*Key, error)
+	// Rotate replaces the hash of a key, setting Rotated to at. Returns ErrNotExist if the key is not found, and
+	// ErrRevoked if it's revoked. The check and the write are atomic, so a revoked key is never rotated.
+	Rotate(ctx context.Context, id, hash string, at time.Time) (*Key, error)
+	// Revoke sets Revoked of a key to at. Returns ErrNotExist if the key is not found, and ErrRevoked if it's revoked
+	// already.
+	Revoke(ctx context.Context, id string, at time.Time) (*Key, error)
+}
+
+// SortKeys orders keys newest first. Keys created at the same time are ordered by id.
+func SortKeys(ks []*Key) {
+	sort.Slice(ks, func(i, j int) bool {
+		if !ks[i].Created.Equal(ks[j].Created) {
+			return ks[i].Created.After(ks[j].Created)
+		}
+		return ks[i].ID < ks[j].ID
+	})
+}
+
+// Validate validates a key request. Returns a *participant.ValidationError listing every invalid field.
+func Validate(r Request) error {
+	verr := &participant.ValidationError{}
+	add := func(field, msg string) {
+		verr.Fields = append(verr.Fields, participant.FieldError{Field: field, Message: msg})
+	}
+
+	if strings.TrimSpace(r.Name) == "" {
+		add("name", "required")
+	} else if len(r.Name) > MaxNameLength {
+		add("name", fmt.Sprintf("must be at most %d characters", MaxNameLength))
+	}
+
+	if len(r.Scopes) == 0 {
+		add("scopes", "required")
+	}
+
+	seen := make(map[string]bool, len(r.Scopes))
+	for _, s := range r.Scopes {
+		switch {
+		case rank(s) == 0:
+			add("scopes", fmt.Sprintf("unknown scope %q. Valid values: %s", s, strings.Join(Scopes, ", ")))
+		case seen[s]:
+			add("scopes", fmt.Sprintf("duplicate scope %q", s))
+		}
+		seen[s] = true
+	}
+
+	if len(verr.Fields) > 0 {
+		return verr
+	}
+
+	return nil
+}
+
+// New returns a token for the key with the given id, and the hash to store. The token is "hk_", the id, "." and a hex
+// encoded secret from a cryptographically secure source.
+func New(id string) (token, hash string, err error) {
+	b := make([]byte, secretBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", err
+	}
+
+	secret := hex.EncodeToString(b)
+	return tokenPrefix + id + "." + secret, Hash(secret), nil
+}
+
+// Parse splits a token into the id of its key and its secret. Returns ErrInvalidToken if it's malformed.
+func Parse(token string) (id, secret string, err error) {
+	if !strings.HasPrefix(token, tokenPrefix) {
+		return "", "", ErrInvalidToken
+	}
+
+	parts := strings.SplitN(strings.TrimPrefix(token, tokenPrefix), ".", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", "", ErrInvalidToken
+	}
+
+	return parts[0], parts[1], nil
+}
+
+// Hash returns the hex encoded SHA-256 hash of a secret. Secrets are random, so a plain hash is enough to keep the
+// stored keys from being used.
+func Hash(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,106 @@
+package apikey
+
+import (
+	"errors"
+	"github.com/rejlersembriq/hooked/pkg/participant"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestValidate(t *testing.T) {
+	valid := Request{Name: "Scoreboard", Scopes: []string{ScopeWriteScore}}
+	assert.NoError(t, Validate(valid))
+
+	tests := []struct {
+		name   string
+		modify func(r *Request)
+		fields []string
+	}{
+		{"missing name", func(r *Request) { r.Name = " " }, []string{"name"}},
+		{"long name", func(r *Request) { r.Name = strings.Repeat("n", MaxNameLength+1) }, []string{"name"}},
+		{"missing scopes", func(r *Request) { r.Scopes = nil }, []string{"scopes"}},
+		{"unknown scope", func(r *Request) { r.Scopes = []string{"write"} }, []string{"scopes"}},
+		{"duplicate scope", func(r *Request) { r.Scopes = []string{ScopeRead, ScopeRead} }, []string{"scopes"}},
+		{"several", func(r *Request) { r.Name, r.Scopes = "", nil }, []string{"name", "scopes"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := valid
+			test.modify(&r)
+
+			var verr *participant.ValidationError
+			if err := Validate(r); assert.True(t, errors.As(err, &verr), "expected *participant.ValidationError, got %v", err) {
+				fields := make([]string, len(verr.Fields))
+				for i, f := range verr.Fields {
+					fields[i] = f.Field
+				}
+				assert.Equal(t, test.fields, fields)
+			}
+		})
+	}
+}
+
+func TestKey_Allows(t *testing.T) {
+	tests := []struct {
+		scopes  []string
+		allowed []string
+	}{
+		{[]string{ScopeRead}, []string{ScopeRead}},
+		{[]string{ScopeWriteScore}, []string{ScopeRead, ScopeWriteScore}},
+		{[]string{ScopeAdmin}, []string{ScopeRead, ScopeWriteScore, ScopeAdmin}},
+		{[]string{ScopeRead, ScopeAdmin}, []string{ScopeRead, ScopeWriteScore, ScopeAdmin}},
+		{[]string{"unknown"}, nil},
+	}
+
+	for _, test := range tests {
+		k := Key{Scopes: test.scopes}
+
+		var allowed []string
+		for _, s := range append(Scopes, "unknown") {
+			if k.Allows(s) {
+				allowed = append(allowed, s)
+			}
+		}
+
+		assert.Equal(t, test.allowed, allowed, "scopes %v", test.scopes)
+	}
+
+	now := time.Now()
+	assert.False(t, Key{Scopes: []string{ScopeAdmin}, Revoked: &now}.Allows(ScopeRead), "revoked keys grant nothing")
+}
+
+func TestNew(t *testing.T) {
+	token, hash, err := New("key")
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(token, "hk_key."))
+
+	id, secret, err := Parse(token)
+	assert.NoError(t, err)
+	assert.Equal(t, "key", id)
+	assert.Len(t, secret, 2*secretBytes)
+	assert.Equal(t, Hash(secret), hash)
+	assert.NotContains(t, hash, secret)
+
+	k := Key{Hash: hash}
+	assert.True(t, k.Verify(secret))
+	assert.False(t, k.Verify(secret+"0"))
+
+	other, _, err := New("key")
+	assert.NoError(t, err)
+	assert.NotEqual(t, token, other)
+}
+
+func TestParse_Invalid(t *testing.T) {
+	for _, token := range []string{"", "key.secret", "hk_", "hk_key", "hk_.secret", "hk_key."} {
+		_, _, err := Parse(token)
+		assert.Equal(t, ErrInvalidToken, err, "token %q", token)
+	}
+}
+
+func TestHash(t *testing.T) {
+	// SHA-256 of "abc" from FIPS 180-2.
+	assert.Equal(t, "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad", Hash("abc"))
+}
@@ -0,0 +1,224 @@
+// Package apikeytest checks apikey.Repository implementations. Keys are never deleted, so the suite checks that
+// rotating replaces only the hash and that revoked keys are kept, and can't be rotated or revoked again.
+package apikeytest
+
+import (
+	"context"
+	"errors"
+	"github.com/google/uuid"
+	"github.com/rejlersembriq/hooked/pkg/apikey"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+// Factory returns a repository without keys, and a function removing it when the test is done.
+type Factory func(t *testing.T) (apikey.Repository, func())
+
+// Run tests a new repository from newRepo in every case.
+func Run(t *testing.T, newRepo Factory) {
+	tests := []struct {
+		name string
+		test func(t *testing.T, repo apikey.Repository)
+	}{
+		{"Create", testCreate},
+		{"GetNotExist", testGetNotExist},
+		{"List", testList},
+		{"Rotate", testRotate},
+		{"Revoke", testRevoke},
+		{"ReturnedValuesNotShared", testReturnedValuesNotShared},
+		{"CancelledContext", testCancelledContext},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			repo, cleanup := newRepo(t)
+			defer cleanup()
+
+			test.test(t, repo)
+		})
+	}
+}
+
+func timestamp(offset time.Duration) time.Time {
+	return time.Date(2020, 5, 1, 17, 0, 0, 0, time.UTC).Add(offset)
+}
+
+func newKey() apikey.Key {
+	return apikey.Key{
+		ID:      uuid.New().String(),
+		Name:    "Scoreboard",
+		Scopes:  []string{apikey.ScopeRead, apikey.ScopeWriteScore},
+		Hash:    apikey.Hash("secret"),
+		Created: timestamp(0),
+	}
+}
+
+func mustCreate(t *testing.T, repo apikey.Repository, k apikey.Key) *apikey.Key {
+	t.Helper()
+
+	created, err := repo.Create(context.Background(), k)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	return created
+}
+
+func assertSameTime(t *testing.T, expected, actual *time.Time, field string) {
+	t.Helper()
+
+	if expected == nil || actual == nil {
+		assert.Equal(t, expected == nil, actual == nil, "%s: expected %v, got %v", field, expected, actual)
+		return
+	}
+
+	assert.True(t, expected.Equal(*actual), "%s: expected %v, got %v", field, *expected, *actual)
+}
+
+func assertSameKey(t *testing.T, expected apikey.Key, actual *apikey.Key) {
+	t.Helper()
+
+	assert.Equal(t, expected.ID, actual.ID)
+	assert.Equal(t, expected.Name, actual.Name)
+	assert.Equal(t, expected.Scopes, actual.Scopes)
+	assert.Equal(t, expected.Hash, actual.Hash)
+	assert.True(t, expected.Created.Equal(actual.Created), "created: expected %v, got %v", expected.Created, actual.Created)
+	assertSameTime(t, expected.Rotated, actual.Rotated, "rotated")
+	assertSameTime(t, expected.Revoked, actual.Revoked, "revoked")
+}
+
+func testCreate(t *testing.T, repo apikey.Repository) {
+	k := newKey()
+
+	created := mustCreate(t, repo, k)
+	assertSameKey(t, k, created)
+
+	got, err := repo.Get(context.Background(), k.ID)
+	assert.NoError(t, err)
+	assertSameKey(t, k, got)
+}
+
+func testGetNotExist(t *testing.T, repo apikey.Repository) {
+	_, err := repo.Get(context.Background(), "missing")
+	assert.True(t, errors.Is(err, apikey.ErrNotExist), "expected ErrNotExist, got %v", err)
+}
+
+func testList(t *testing.T, repo apikey.Repository) {
+	ks, err := repo.List(context.Background())
+	assert.NoError(t, err)
+	assert.Empty(t, ks)
+
+	first := newKey()
+	second := newKey()
+	second.Created = timestamp(time.Hour)
+
+	mustCreate(t, repo, first)
+	mustCreate(t, repo, second)
+
+	ks, err = repo.List(context.Background())
+	assert.NoError(t, err)
+
+	if assert.Len(t, ks, 2) {
+		assert.Equal(t, []string{second.ID, first.ID}, []string{ks[0].ID, ks[1].ID})
+	}
+}
+
+func testRotate(t *testing.T, repo apikey.Repository) {
+	k := newKey()
+	mustCreate(t, repo, k)
+
+	at := timestamp(time.Minute)
+	rotated, err := repo.Rotate(context.Background(), k.ID, apikey.Hash("rotated"), at)
+	assert.NoError(t, err)
+
+	k.Hash = apikey.Hash("rotated")
+	k.Rotated = &at
+	assertSameKey(t, k, rotated)
+
+	got, err := repo.Get(context.Background(), k.ID)
+	assert.NoError(t, err)
+	assertSameKey(t, k, got)
+
+	_, err = repo.Rotate(context.Background(), "missing", apikey.Hash("rotated"), at)
+	assert.True(t, errors.Is(err, apikey.ErrNotExist), "expected ErrNotExist, got %v", err)
+
+	_, err = repo.Revoke(context.Background(), k.ID, at)
+	assert.NoError(t, err)
+
+	_, err = repo.Rotate(context.Background(), k.ID, apikey.Hash("again"), timestamp(time.Hour))
+	assert.True(t, errors.Is(err, apikey.ErrRevoked), "expected ErrRevoked, got %v", err)
+
+	got, err = repo.Get(context.Background(), k.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, apikey.Hash("rotated"), got.Hash, "a revoked key should not be rotated")
+}
+
+func testRevoke(t *testing.T, repo apikey.Repository) {
+	k := newKey()
+	mustCreate(t, repo, k)
+
+	at := timestamp(time.Minute)
+	revoked, err := repo.Revoke(context.Background(), k.ID, at)
+	assert.NoError(t, err)
+
+	k.Revoked = &at
+	assertSameKey(t, k, revoked)
+
+	got, err := repo.Get(context.Background(), k.ID)
+	assert.NoError(t, err)
+	assertSameKey(t, k, got)
+
+	_, err = repo.Revoke(context.Background(), k.ID, timestamp(time.Hour))
+	assert.True(t, errors.Is(err, apikey.ErrRevoked), "expected ErrRevoked, got %v", err)
+
+	got, err = repo.Get(context.Background(), k.ID)
+	assert.NoError(t, err)
+	assertSameTime(t, &at, got.Revoked, "revoked")
+
+	_, err = repo.Revoke(context.Background(), "missing", at)
+	assert.True(t, errors.Is(err, apikey.ErrNotExist), "expected ErrNotExist, got %v", err)
+}
+
+func testReturnedValuesNotShared(t *testing.T, repo apikey.Repository) {
+	k := newKey()
+	created := mustCreate(t, repo, k)
+
+	created.Scopes[0] = apikey.ScopeAdmin
+	k.Scopes[1] = apikey.ScopeAdmin
+
+	got, err := repo.Get(context.Background(), k.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, newKey().Scopes, got.Scopes)
+
+	got.Scopes[0] = apikey.ScopeAdmin
+
+	ks, err := repo.List(context.Background())
+	assert.NoError(t, err)
+	if assert.Len(t, ks, 1) {
+		assert.Equal(t, newKey().Scopes, ks[0].Scopes)
+	}
+}
+
+func testCancelledContext(t *testing.T, repo apikey.Repository) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	k := newKey()
+
+	_, err := repo.Create(ctx, k)
+	assert.Equal(t, context.Canceled, err)
+
+	_, err = repo.Get(ctx, k.ID)
+	assert.Equal(t, context.Canceled, err)
+
+	_, err = repo.List(ctx)
+	assert.Equal(t, context.Canceled, err)
+
+	_, err = repo.Rotate(ctx, k.ID, apikey.Hash("rotated"), timestamp(0))
+	assert.Equal(t, context.Canceled, err)
+
+	_, err = repo.Revoke(ctx, k.ID, timestamp(0))
+	assert.Equal(t, context.Canceled, err)
+}
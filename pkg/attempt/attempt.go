@@ -0,0 +1,144 @@
+// Package attempt keeps every score a participant achieves. A participant's score is derived from its attempts by a
+// Rule, so earlier attempts aren't lost when a new one is recorded.
+package attempt
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Attempt is a single score achieved by a participant.
+type Attempt struct {
+	ID          string    `json:"id"`
+	Participant string    `json:"participant"`
+	Score       int       `json:"score"`
+	Created     time.Time `json:"created"`
+}
+
+// Repository persists attempts. Attempts aren't changed once saved, only deleted with their participant.
+type Repository interface {
+	// Save stores a new attempt with a new id. Created is set to the current time unless already set.
+	Save(ctx context.Context, a Attempt) (*Attempt, error)
+	// List returns the attempts of a participant, oldest first.
+	List(ctx context.Context, participantID string) ([]*Attempt, error)
+	// DeleteAll removes the attempts of a participant.
+	DeleteAll(ctx context.Context, participantID string) error
+}
+
+// Sort orders attempts oldest first. Attempts created at the same time are ordered by id, so the order is stable.
+func Sort(as []*Attempt) {
+	sort.Slice(as, func(i, j int) bool {
+		if !as[i].Created.Equal(as[j].Created) {
+			return as[i].Created.Before(as[j].Created)
+		}
+		return as[i].ID < as[j].ID
+	})
+}
+
+// Kinds of rules.
+const (
+	best   = "best"
+	latest = "latest"
+	sum    = "sum"
+	top    = "top"
+)
+
+// Rule derives a participant's score from its attempts.
+type Rule struct {
+	kind string
+	n    int
+}
+
+var (
+	// Best scores participants by their best attempt.
+	Best = Rule{kind: best}
+	// Latest scores participants by their most recent attempt.
+	Latest = Rule{kind: latest}
+	// Sum scores participants by the sum of all attempts.
+	Sum = Rule{kind: sum}
+)
+
+// TopAverage scores participants by the average of their n best attempts, rounded to the nearest integer. Fewer
+// attempts are averaged if the participant has less than n.
+func TopAverage(n int) Rule {
+	return Rule{kind: top, n: n}
+}
+
+// ErrInvalidRule is returned when parsing an unknown rule.
+var ErrInvalidRule = errors.New("invalid score rule")
+
+// ParseRule returns the Rule matching s: "best", "latest", "sum" or "topN" for the average of the N best attempts,
+// eg. "top3". An empty string gives Best.
+func ParseRule(s string) (Rule, error) {
+	switch s {
+	case "", best:
+		return Best, nil
+	case latest:
+		return Latest, nil
+	case sum:
+		return Sum, nil
+	}
+
+	if strings.HasPrefix(s, top) {
+		if n, err := strconv.Atoi(s[len(top):]); err == nil && n > 0 {
+			return TopAverage(n), nil
+		}
+	}
+
+	return Rule{}, ErrInvalidRule
+}
+
+func (r Rule) String() string {
+	if r.kind == top {
+		return fmt.Sprintf("%s%d", top, r.n)
+	}
+	return r.kind
+}
+
+// Score returns the score derived from the attempts, ordered oldest first. Returns false if there are no attempts.
+func (r Rule) Score(as []*Attempt) (int, bool) {
+	if len(as) == 0 {
+		return 0, false
+	}
+
+	switch r.kind {
+	case latest:
+		return as[len(as)-1].Score, true
+	case sum:
+		total := 0
+		for _, a := range as {
+			total += a.Score
+		}
+		return total, true
+	case top:
+		scores := make([]int, len(as))
+		for i, a := range as {
+			scores[i] = a.Score
+		}
+		sort.Sort(sort.Reverse(sort.IntSlice(scores)))
+
+		if len(scores) > r.n {
+			scores = scores[:r.n]
+		}
+
+		total := 0
+		for _, s := range scores {
+			total += s
+		}
+		// Scores aren't negative, so adding half the divisor rounds to nearest.
+		return (total + len(scores)/2) / len(scores), true
+	default:
+		max := as[0].Score
+		for _, a := range as[1:] {
+			if a.Score > max {
+				max = a.Score
+			}
+		}
+		return max, true
+	}
+}
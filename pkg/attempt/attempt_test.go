@@ -0,0 +1,88 @@
+package attempt
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func attempts(scores ...int) []*Attempt {
+	now := time.Now()
+
+	as := make([]*Attempt, len(scores))
+	for i, s := range scores {
+		as[i] = &Attempt{ID: string(rune('a' + i)), Score: s, Created: now.Add(time.Duration(i) * time.Second)}
+	}
+
+	return as
+}
+
+func TestParseRule(t *testing.T) {
+	tests := []struct {
+		in   string
+		rule Rule
+		err  error
+	}{
+		{"", Best, nil},
+		{"best", Best, nil},
+		{"latest", Latest, nil},
+		{"sum", Sum, nil},
+		{"top3", TopAverage(3), nil},
+		{"top0", Rule{}, ErrInvalidRule},
+		{"top", Rule{}, ErrInvalidRule},
+		{"top-1", Rule{}, ErrInvalidRule},
+		{"worst", Rule{}, ErrInvalidRule},
+	}
+
+	for _, test := range tests {
+		rule, err := ParseRule(test.in)
+		assert.Equal(t, test.err, err, test.in)
+		assert.Equal(t, test.rule, rule, test.in)
+
+		if err == nil && test.in != "" {
+			assert.Equal(t, test.in, rule.String())
+		}
+	}
+}
+
+func TestRule_Score(t *testing.T) {
+	tests := []struct {
+		rule   Rule
+		scores []int
+		score  int
+	}{
+		{Best, []int{10, 30, 20}, 30},
+		{Latest, []int{10, 30, 20}, 20},
+		{Sum, []int{10, 30, 20}, 60},
+		{TopAverage(2), []int{10, 30, 20}, 25},
+		{TopAverage(2), []int{10, 30, 21}, 26},
+		{TopAverage(5), []int{10, 30, 20}, 20},
+		{TopAverage(1), []int{10, 30, 20}, 30},
+	}
+
+	for _, test := range tests {
+		score, ok := test.rule.Score(attempts(test.scores...))
+		assert.True(t, ok)
+		assert.Equal(t, test.score, score, "%s %v", test.rule, test.scores)
+	}
+}
+
+func TestRule_Score_NoAttempts(t *testing.T) {
+	for _, rule := range []Rule{Best, Latest, Sum, TopAverage(3)} {
+		_, ok := rule.Score(nil)
+		assert.False(t, ok, rule.String())
+	}
+}
+
+func TestSort(t *testing.T) {
+	now := time.Now()
+	as := []*Attempt{
+		{ID: "c", Created: now.Add(time.Second)},
+		{ID: "b", Created: now},
+		{ID: "a", Created: now},
+	}
+
+	Sort(as)
+
+	assert.Equal(t, []string{"a", "b", "c"}, []string{as[0].ID, as[1].ID, as[2].ID})
+}
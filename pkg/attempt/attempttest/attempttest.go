@@ -0,0 +1,131 @@
+// Package attempttest checks attempt.Repository implementations. Scores are derived from the listed attempts, so the
+// suite is strict about what List returns: only the participant's attempts, ordered by the Created they were saved
+// with, even when attempts moved from a merged participant are saved out of order.
+package attempttest
+
+import (
+	"context"
+	"github.com/google/uuid"
+	"github.com/rejlersembriq/hooked/pkg/attempt"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+// Factory returns a repository without attempts, and a function removing it when the test is done.
+type Factory func(t *testing.T) (attempt.Repository, func())
+
+// Run tests a new repository from newRepo in every case.
+func Run(t *testing.T, newRepo Factory) {
+	tests := []struct {
+		name string
+		test func(t *testing.T, repo attempt.Repository)
+	}{
+		{"Save", testSave},
+		{"List", testList},
+		{"ListEmpty", testListEmpty},
+		{"DeleteAll", testDeleteAll},
+		{"CancelledContext", testCancelledContext},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			repo, cleanup := newRepo(t)
+			defer cleanup()
+
+			test.test(t, repo)
+		})
+	}
+}
+
+func mustSave(t *testing.T, repo attempt.Repository, a attempt.Attempt) *attempt.Attempt {
+	t.Helper()
+
+	saved, err := repo.Save(context.Background(), a)
+	if err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	return saved
+}
+
+func testSave(t *testing.T, repo attempt.Repository) {
+	participantID := uuid.New().String()
+	before := time.Now()
+
+	saved := mustSave(t, repo, attempt.Attempt{ID: "ignored", Participant: participantID, Score: 42})
+
+	assert.NotEmpty(t, saved.ID)
+	assert.NotEqual(t, "ignored", saved.ID)
+	assert.Equal(t, participantID, saved.Participant)
+	assert.Equal(t, 42, saved.Score)
+	assert.False(t, saved.Created.Before(before.Truncate(time.Second)), "created should be set to the current time")
+
+	other := mustSave(t, repo, attempt.Attempt{Participant: participantID, Score: 42})
+	assert.NotEqual(t, saved.ID, other.ID)
+}
+
+func testList(t *testing.T, repo attempt.Repository) {
+	participantID := uuid.New().String()
+	now := time.Now().UTC().Truncate(time.Millisecond)
+
+	// Saved out of order with Created set, to check it is kept and List orders by it.
+	second := mustSave(t, repo, attempt.Attempt{Participant: participantID, Score: 2, Created: now.Add(time.Second)})
+	first := mustSave(t, repo, attempt.Attempt{Participant: participantID, Score: 1, Created: now})
+	third := mustSave(t, repo, attempt.Attempt{Participant: participantID, Score: 3, Created: now.Add(2 * time.Second)})
+	mustSave(t, repo, attempt.Attempt{Participant: uuid.New().String(), Score: 4, Created: now})
+
+	as, err := repo.List(context.Background(), participantID)
+	assert.NoError(t, err)
+
+	if assert.Len(t, as, 3) {
+		assert.Equal(t, []string{first.ID, second.ID, third.ID}, []string{as[0].ID, as[1].ID, as[2].ID})
+		assert.Equal(t, 1, as[0].Score)
+		assert.Equal(t, participantID, as[0].Participant)
+		assert.True(t, now.Equal(as[0].Created), "expected %v, got %v", now, as[0].Created)
+	}
+}
+
+func testListEmpty(t *testing.T, repo attempt.Repository) {
+	as, err := repo.List(context.Background(), uuid.New().String())
+	assert.NoError(t, err)
+	assert.Empty(t, as)
+}
+
+func testDeleteAll(t *testing.T, repo attempt.Repository) {
+	participantID := uuid.New().String()
+	otherID := uuid.New().String()
+
+	for i := 0; i < 3; i++ {
+		mustSave(t, repo, attempt.Attempt{Participant: participantID, Score: i})
+	}
+	mustSave(t, repo, attempt.Attempt{Participant: otherID, Score: 1})
+
+	assert.NoError(t, repo.DeleteAll(context.Background(), participantID))
+
+	as, err := repo.List(context.Background(), participantID)
+	assert.NoError(t, err)
+	assert.Empty(t, as)
+
+	as, err = repo.List(context.Background(), otherID)
+	assert.NoError(t, err)
+	assert.Len(t, as, 1, "attempts of other participants should be kept")
+
+	assert.NoError(t, repo.DeleteAll(context.Background(), participantID), "deleting without attempts should succeed")
+}
+
+func testCancelledContext(t *testing.T, repo attempt.Repository) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	participantID := uuid.New().String()
+
+	_, err := repo.Save(ctx, attempt.Attempt{Participant: participantID, Score: 1})
+	assert.Equal(t, context.Canceled, err)
+
+	_, err = repo.List(ctx, participantID)
+	assert.Equal(t, context.Canceled, err)
+
+	assert.Equal(t, context.Canceled, repo.DeleteAll(ctx, participantID))
+}
@@ -0,0 +1,161 @@
+// Package auth holds the identity of the caller of a request, and the roles it has. The identity is established by
+// the server from an API key or a JWT, and added to the request context for handlers, see FromContext.
+package auth
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// Roles, from least to most privileged. Every role includes the roles before it.
+const (
+	// RoleViewer reads participants, organisations and events.
+	RoleViewer = "viewer"
+	// RoleScorer registers participants and records their scores.
+	RoleScorer = "scorer"
+	// RoleAdmin deletes participants, manages events, draws, webhooks and API keys.
+	RoleAdmin = "admin"
+)
+
+// Roles lists the roles, from least to most privileged.
+var Roles = []string{RoleViewer, RoleScorer, RoleAdmin}
+
+// Methods of authentication.
+const (
+	MethodAPIKey     = "api-key"
+	MethodJWT        = "jwt"
+	MethodAuthorizer = "authorizer"
+)
+
+// Principal is the authenticated caller of a request. Subject is the id of the API key, or the "sub" claim of the
+// JWT or authorizer. Claims holds every claim of the JWT or authorizer, and is nil for API keys.
+type Principal struct {
+	Subject string
+	Method  string
+	Roles   []string
+	Claims  map[string]interface{}
+}
+
+// Has reports if the principal has role, directly or by a more privileged role.
+func (p *Principal) Has(role string) bool {
+	for _, r := range p.Roles {
+		if rank(r) >= rank(role) && rank(role) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// HasAny reports if the principal has one of roles, see Has.
+func (p *Principal) HasAny(roles ...string) bool {
+	for _, r := range roles {
+		if p.Has(r) {
+			return true
+		}
+	}
+	return false
+}
+
+// rank orders roles by privilege, starting at 1. Unknown roles are 0.
+func rank(role string) int {
+	for i, r := range Roles {
+		if r == role {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+type principalKey struct{}
+
+// NewContext returns a copy of ctx holding p.
+func NewContext(ctx context.Context, p *Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+// FromContext gets the principal of a request from context. Not found for public routes, or when authentication
+// isn't enabled.
+func FromContext(ctx context.Context) (*Principal, bool) {
+	p, ok := ctx.Value(principalKey{}).(*Principal)
+	return p, ok
+}
+
+type claimsKey struct{}
+
+// NewClaimsContext returns a copy of ctx holding claims verified before the request reached the server, eg. by an
+// API Gateway authorizer. Only the transport may add them, never from anything the client sent.
+func NewClaimsContext(ctx context.Context, claims map[string]interface{}) context.Context {
+	return context.WithValue(ctx, claimsKey{}, claims)
+}
+
+// ClaimsFromContext gets the claims verified before the request reached the server from context, see
+// NewClaimsContext.
+func ClaimsFromContext(ctx context.Context) (map[string]interface{}, bool) {
+	claims, ok := ctx.Value(claimsKey{}).(map[string]interface{})
+	return claims, ok
+}
+
+// RoleMapper maps the claims of a JWT or authorizer to roles. Claim names the claim holding the roles of the caller, an
+// array of strings or a comma separated string, eg. "roles" or "cognito:groups". API Gateway authorizers only pass
+// strings. Mapping maps its values, eg. groups of the identity provider, to roles. Without a mapping the values are
+// taken as role names. Unknown values are left out.
+type RoleMapper struct {
+	Claim   string
+	Mapping map[string]string
+}
+
+// Roles returns the roles given by claims.
+func (m RoleMapper) Roles(claims map[string]interface{}) []string {
+	var values []string
+	switch v := claims[m.Claim].(type) {
+	case string:
+		values = strings.Split(v, ",")
+	case []interface{}:
+		for _, e := range v {
+			if s, ok := e.(string); ok {
+				values = append(values, s)
+			}
+		}
+	}
+
+	var roles []string
+	for _, v := range values {
+		v = strings.TrimSpace(v)
+		role := v
+		if m.Mapping != nil {
+			role = m.Mapping[v]
+		}
+
+		if rank(role) > 0 {
+			roles = append(roles, role)
+		}
+	}
+
+	return roles
+}
+
+// ParseMapping parses a mapping from claim values to roles, like "staff=scorer,it-admins=admin". An empty string is
+// no mapping.
+func ParseMapping(s string) (map[string]string, error) {
+	if strings.TrimSpace(s) == "" {
+		return nil, nil
+	}
+
+	mapping := make(map[string]string)
+	for _, pair := range strings.Split(s, ",") {
+		kv := strings.SplitN(pair, "=", 2)
+		if len(kv) != 2 || strings.TrimSpace(kv[0]) == "" {
+			return nil, fmt.Errorf("invalid mapping %q. Must be VALUE=ROLE", pair)
+		}
+
+		role := strings.TrimSpace(kv[1])
+		if rank(role) == 0 {
+			return nil, fmt.Errorf("unknown role %q. Valid values: %s", role, strings.Join(Roles, ", "))
+		}
+
+		mapping[strings.TrimSpace(kv[0])] = role
+	}
+
+	return mapping, nil
+}
@@ -0,0 +1,93 @@
+package auth
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestPrincipal_Has(t *testing.T) {
+	tests := []struct {
+		roles []string
+		has   []string
+	}{
+		{[]string{RoleViewer}, []string{RoleViewer}},
+		{[]string{RoleScorer}, []string{RoleViewer, RoleScorer}},
+		{[]string{RoleAdmin}, []string{RoleViewer, RoleScorer, RoleAdmin}},
+		{[]string{"unknown"}, nil},
+		{nil, nil},
+	}
+
+	for _, test := range tests {
+		p := &Principal{Roles: test.roles}
+
+		var has []string
+		for _, r := range append(Roles, "unknown") {
+			if p.Has(r) {
+				has = append(has, r)
+			}
+		}
+
+		assert.Equal(t, test.has, has, "roles %v", test.roles)
+	}
+
+	p := &Principal{Roles: []string{RoleScorer}}
+	assert.True(t, p.HasAny(RoleAdmin, RoleScorer))
+	assert.False(t, p.HasAny(RoleAdmin))
+	assert.False(t, p.HasAny())
+}
+
+func TestContext(t *testing.T) {
+	_, ok := FromContext(context.Background())
+	assert.False(t, ok)
+
+	p := &Principal{Subject: "annie", Method: MethodJWT, Roles: []string{RoleViewer}}
+
+	got, ok := FromContext(NewContext(context.Background(), p))
+	assert.True(t, ok)
+	assert.Equal(t, p, got)
+}
+
+func TestClaimsContext(t *testing.T) {
+	_, ok := ClaimsFromContext(context.Background())
+	assert.False(t, ok)
+
+	claims := map[string]interface{}{"sub": "annie"}
+
+	got, ok := ClaimsFromContext(NewClaimsContext(context.Background(), claims))
+	assert.True(t, ok)
+	assert.Equal(t, claims, got)
+}
+
+func TestRoleMapper_Roles(t *testing.T) {
+	claims := map[string]interface{}{
+		"roles":  []interface{}{"scorer", "unknown", 1},
+		"groups": []interface{}{"staff", "it-admins", "visitors"},
+		"role":   "admin",
+		"list":   "viewer, scorer",
+	}
+
+	assert.Equal(t, []string{RoleScorer}, RoleMapper{Claim: "roles"}.Roles(claims))
+	assert.Equal(t, []string{RoleAdmin}, RoleMapper{Claim: "role"}.Roles(claims))
+	assert.Equal(t, []string{RoleViewer, RoleScorer}, RoleMapper{Claim: "list"}.Roles(claims))
+	assert.Nil(t, RoleMapper{Claim: "missing"}.Roles(claims))
+
+	mapping := map[string]string{"staff": RoleScorer, "it-admins": RoleAdmin}
+	assert.Equal(t, []string{RoleScorer, RoleAdmin}, RoleMapper{Claim: "groups", Mapping: mapping}.Roles(claims))
+	assert.Nil(t, RoleMapper{Claim: "roles", Mapping: mapping}.Roles(claims), "only mapped values should count")
+}
+
+func TestParseMapping(t *testing.T) {
+	mapping, err := ParseMapping(" staff = scorer,it-admins=admin")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"staff": RoleScorer, "it-admins": RoleAdmin}, mapping)
+
+	mapping, err = ParseMapping("")
+	assert.NoError(t, err)
+	assert.Nil(t, mapping)
+
+	for _, s := range []string{"staff", "=admin", "staff=owner"} {
+		_, err := ParseMapping(s)
+		assert.Error(t, err, "mapping %q", s)
+	}
+}
@@ -0,0 +1,325 @@
+// Package draw raffles prizes among participants. Winners are picked from the recorded entries by a deterministic
+// algorithm driven by a recorded seed, so anyone holding a draw can repeat it and check the result, see Pick and
+// Verify. Draws are stored once and never changed, so a result can't be silently re-rolled.
+package draw
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/rejlersembriq/hooked/pkg/participant"
+	"math"
+	"regexp"
+	"sort"
+	"time"
+)
+
+// ErrNotExist should be returned when the requested draw is not found in the repository.
+var ErrNotExist = errors.New("draw doesn't exist")
+
+// ErrExists should be returned when creating a draw with an id already in use.
+var ErrExists = errors.New("draw already exists")
+
+// Validation limits.
+const (
+	MaxIDLength = 64
+	MaxWinners  = 1000
+)
+
+// seedBytes is the length of generated seeds, before hex encoding.
+const seedBytes = 32
+
+// Lower case letters and digits, in groups separated by single dashes. Used in urls and keys.
+var idPattern = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+
+// Criteria selects the participants eligible for a draw. Nil fields are ignored.
+type Criteria struct {
+	Event    *string `json:"event,omitempty"`
+	Org      *string `json:"org,omitempty"`
+	MinScore *int    `json:"minScore,omitempty"`
+	// Consent limits the draw to participants who have consented.
+	Consent bool `json:"consent"`
+}
+
+// Query returns the participant query matching the criteria. Consent isn't part of the query, see Eligible.
+func (c Criteria) Query() participant.Query {
+	return participant.Query{Event: c.Event, Org: c.Org, MinScore: c.MinScore}
+}
+
+// Request describes a draw to run. The seed is always generated when the draw is run, so the caller can't choose one
+// known to pick particular winners.
+type Request struct {
+	ID       string   `json:"id"`
+	Winners  int      `json:"winners"`
+	Weighted bool     `json:"weighted"`
+	Criteria Criteria `json:"criteria"`
+}
+
+// Entry is a participant taking part in a draw. Weight is the score of the participant at the time of the draw.
+type Entry struct {
+	Participant string `json:"participant"`
+	Weight      int    `json:"weight"`
+}
+
+// Winner is a participant picked in a draw, in the order picked.
+type Winner struct {
+	Participant string `json:"participant"`
+	Name        string `json:"name,omitempty"`
+}
+
+// Draw is the recorded result of a draw. Entries are ordered by participant id. Winners holds fewer than Count
+// participants if there weren't enough entries to pick from.
+type Draw struct {
+	ID        string    `json:"id"`
+	Criteria  Criteria  `json:"criteria"`
+	Count     int       `json:"count"`
+	Weighted  bool      `json:"weighted"`
+	Seed      string    `json:"seed"`
+	Entries   []Entry   `json:"entries"`
+	Winners   []Winner  `json:"winners"`
+	Created   time.Time `json:"created" dynamodbav:",unixtime"`
+	RequestID string    `json:"requestId,omitempty"`
+}
+
+// Clone returns a copy of d where no field shares memory with d.
+func (d Draw) Clone() Draw {
+	d.Criteria.Event = copyString(d.Criteria.Event)
+	d.Criteria.Org = copyString(d.Criteria.Org)
+	if d.Criteria.MinScore != nil {
+		min := *d.Criteria.MinScore
+		d.Criteria.MinScore = &min
+	}
+	if d.Entries != nil {
+		d.Entries = append([]Entry(nil), d.Entries...)
+	}
+	if d.Winners != nil {
+		d.Winners = append([]Winner(nil), d.Winners...)
+	}
+	return d
+}
+
+func copyString(s *string) *string {
+	if s == nil {
+		return nil
+	}
+	c := *s
+	return &c
+}
+
+// Repository persists draws. Draws can't be updated or deleted, so every draw can be verified later.
+type Repository interface {
+	// Create stores a new draw. Returns ErrExists if the id is in use.
+	Create(ctx context.Context, d Draw) (*Draw, error)
+	Get(ctx context.Context, id string) (*Draw, error)
+	// List returns all draws, newest first.
+	List(ctx context.Context) ([]*Draw, error)
+}
+
+// Sort orders draws newest first. Draws created at the same time are ordered by id.
+func Sort(ds []*Draw) {
+	sort.Slice(ds, func(i, j int) bool {
+		if !ds[i].Created.Equal(ds[j].Created) {
+			return ds[i].Created.After(ds[j].Created)
+		}
+		return ds[i].ID < ds[j].ID
+	})
+}
+
+// Validate validates a draw request. Returns a *participant.ValidationError listing every invalid field.
+func Validate(r Request) error {
+	verr := &participant.ValidationError{}
+	add := func(field, msg string) {
+		verr.Fields = append(verr.Fields, participant.FieldError{Field: field, Message: msg})
+	}
+
+	switch {
+	case r.ID == "":
+		add("id", "required")
+	case len(r.ID) > MaxIDLength || !idPattern.MatchString(r.ID):
+		add("id", fmt.Sprintf("must be at most %d lower case letters, digits and single dashes", MaxIDLength))
+	}
+
+	if r.Winners < 1 || r.Winners > MaxWinners {
+		add("winners", fmt.Sprintf("must be between 1 and %d", MaxWinners))
+	}
+
+	if r.Criteria.MinScore != nil && *r.Criteria.MinScore < 0 {
+		add("criteria.minScore", "must not be negative")
+	}
+
+	if len(verr.Fields) > 0 {
+		return verr
+	}
+
+	return nil
+}
+
+// NewSeed returns a hex encoded seed from a cryptographically secure source.
+func NewSeed() (string, error) {
+	b := make([]byte, seedBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// Eligible returns the entries of the participants matching the criteria, ordered by participant id.
+func Eligible(ps []*participant.Participant, c Criteria) []Entry {
+	q := c.Query()
+
+	entries := make([]Entry, 0)
+	for _, p := range ps {
+		if p.ID == nil || !q.Match(p) {
+			continue
+		}
+
+		if c.Consent && (p.Consent == nil || !*p.Consent) {
+			continue
+		}
+
+		weight := 0
+		if p.Score != nil && *p.Score > 0 {
+			weight = *p.Score
+		}
+
+		entries = append(entries, Entry{Participant: *p.ID, Weight: weight})
+	}
+
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Participant < entries[j].Participant })
+
+	return entries
+}
+
+// Pick picks up to n entries without replacement, returning the participant ids in the order picked. Entries have
+// equal chances, or chances proportional to their weight if weighted. Entries with weight 0 are never picked in a
+// weighted draw.
+//
+// The result only depends on the arguments. Pick k, counted from 0, draws a number below the total weight W of the
+// remaining entries: the first 8 bytes of SHA-256(seed || k || j), read as a big endian uint64, modulo W. k and j are
+// 4 byte big endian integers, and j counts from 0, incremented while the number is at or above the largest multiple
+// of W that fits in a uint64, so every number is equally likely. The entry picked is the one where the running total
+// of weights, in the order given, first exceeds the number.
+func Pick(seed string, entries []Entry, n int, weighted bool) ([]string, error) {
+	key, err := hex.DecodeString(seed)
+	if err != nil {
+		return nil, fmt.Errorf("invalid seed: %w", err)
+	}
+
+	remaining := append([]Entry(nil), entries...)
+	weight := func(e Entry) uint64 {
+		if !weighted {
+			return 1
+		}
+		if e.Weight < 0 {
+			return 0
+		}
+		return uint64(e.Weight)
+	}
+
+	picked := make([]string, 0, n)
+	for k := 0; len(picked) < n; k++ {
+		var total uint64
+		for _, e := range remaining {
+			total += weight(e)
+		}
+
+		if total == 0 {
+			break
+		}
+
+		r := uniform(key, uint32(k), total)
+
+		for i, e := range remaining {
+			w := weight(e)
+			if r < w {
+				picked = append(picked, e.Participant)
+				remaining = append(remaining[:i], remaining[i+1:]...)
+				break
+			}
+			r -= w
+		}
+	}
+
+	return picked, nil
+}
+
+// uniform returns a number below max for pick k, see Pick.
+func uniform(key []byte, k uint32, max uint64) uint64 {
+	limit := math.MaxUint64 - math.MaxUint64%max
+
+	buf := make([]byte, len(key)+8)
+	copy(buf, key)
+	binary.BigEndian.PutUint32(buf[len(key):], k)
+
+	for j := uint32(0); ; j++ {
+		binary.BigEndian.PutUint32(buf[len(key)+4:], j)
+		sum := sha256.Sum256(buf)
+
+		if x := binary.BigEndian.Uint64(sum[:8]); x < limit {
+			return x % max
+		}
+	}
+}
+
+// Run runs a draw among the participants matching the request criteria with a new seed, see NewSeed. The request must
+// be valid, see Validate.
+func Run(r Request, ps []*participant.Participant, now time.Time) (Draw, error) {
+	seed, err := NewSeed()
+	if err != nil {
+		return Draw{}, err
+	}
+
+	entries := Eligible(ps, r.Criteria)
+
+	ids, err := Pick(seed, entries, r.Winners, r.Weighted)
+	if err != nil {
+		return Draw{}, err
+	}
+
+	names := make(map[string]string, len(ps))
+	for _, p := range ps {
+		if p.ID != nil && p.Name != nil {
+			names[*p.ID] = *p.Name
+		}
+	}
+
+	winners := make([]Winner, len(ids))
+	for i, id := range ids {
+		winners[i] = Winner{Participant: id, Name: names[id]}
+	}
+
+	return Draw{
+		ID:       r.ID,
+		Criteria: r.Criteria,
+		Count:    r.Winners,
+		Weighted: r.Weighted,
+		Seed:     seed,
+		Entries:  entries,
+		Winners:  winners,
+		Created:  now,
+	}, nil
+}
+
+// Verify repeats a recorded draw from its seed and entries, reporting if it picks the recorded winners in order.
+func Verify(d Draw) (bool, error) {
+	ids, err := Pick(d.Seed, d.Entries, d.Count, d.Weighted)
+	if err != nil {
+		return false, err
+	}
+
+	if len(ids) != len(d.Winners) {
+		return false, nil
+	}
+
+	for i, id := range ids {
+		if d.Winners[i].Participant != id {
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
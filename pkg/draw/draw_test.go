@@ -0,0 +1,201 @@
+package draw
+
+import (
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/rejlersembriq/hooked/pkg/participant"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testSeed = "000102030405060708090a0b0c0d0e0f"
+
+func entries(n int) []Entry {
+	es := make([]Entry, n)
+	for i := range es {
+		es[i] = Entry{Participant: string(rune('a' + i)), Weight: i}
+	}
+	return es
+}
+
+func TestValidate(t *testing.T) {
+	valid := Request{ID: "tshirts-2020", Winners: 3}
+
+	tests := []struct {
+		name     string
+		modify   func(r *Request)
+		expected []string
+	}{
+		{name: "valid", modify: func(r *Request) {}},
+		{name: "missing id", modify: func(r *Request) { r.ID = "" }, expected: []string{"id"}},
+		{name: "invalid id", modify: func(r *Request) { r.ID = "T-shirts" }, expected: []string{"id"}},
+		{name: "long id", modify: func(r *Request) { r.ID = strings.Repeat("a", MaxIDLength+1) }, expected: []string{"id"}},
+		{name: "no winners", modify: func(r *Request) { r.Winners = 0 }, expected: []string{"winners"}},
+		{name: "too many winners", modify: func(r *Request) { r.Winners = MaxWinners + 1 }, expected: []string{"winners"}},
+		{name: "negative min score", modify: func(r *Request) { r.Criteria.MinScore = aws.Int(-1) }, expected: []string{"criteria.minScore"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := valid
+			test.modify(&r)
+
+			err := Validate(r)
+			if test.expected == nil {
+				assert.NoError(t, err)
+				return
+			}
+
+			verr, ok := err.(*participant.ValidationError)
+			if assert.True(t, ok, "expected *ValidationError, got %v", err) {
+				var fields []string
+				for _, f := range verr.Fields {
+					fields = append(fields, f.Field)
+				}
+				assert.Equal(t, test.expected, fields)
+			}
+		})
+	}
+}
+
+func TestEligible(t *testing.T) {
+	ps := []*participant.Participant{
+		{ID: aws.String("c"), Org: aws.String("OrgA"), Score: aws.Int(5), Consent: aws.Bool(true)},
+		{ID: aws.String("a"), Org: aws.String("OrgA"), Score: aws.Int(10)},
+		{ID: aws.String("b"), Org: aws.String("OrgB"), Score: aws.Int(20), Consent: aws.Bool(true)},
+		{ID: aws.String("d"), Org: aws.String("OrgA"), Consent: aws.Bool(false)},
+		{ID: aws.String("e"), Org: aws.String("OrgA"), Score: aws.Int(1), Event: aws.String("conf")},
+	}
+
+	assert.Equal(t, []Entry{{"a", 10}, {"b", 20}, {"c", 5}, {"d", 0}, {"e", 1}}, Eligible(ps, Criteria{}))
+	assert.Equal(t, []Entry{{"a", 10}, {"c", 5}, {"d", 0}, {"e", 1}}, Eligible(ps, Criteria{Org: aws.String("OrgA")}))
+	assert.Equal(t, []Entry{{"a", 10}, {"b", 20}}, Eligible(ps, Criteria{MinScore: aws.Int(10)}))
+	assert.Equal(t, []Entry{{"b", 20}, {"c", 5}}, Eligible(ps, Criteria{Consent: true}))
+	assert.Equal(t, []Entry{{"e", 1}}, Eligible(ps, Criteria{Event: aws.String("conf")}))
+	assert.Empty(t, Eligible(ps, Criteria{Org: aws.String("OrgB"), MinScore: aws.Int(30)}))
+}
+
+// TestPick_KnownAnswer pins the algorithm, so draws recorded earlier can still be verified.
+func TestPick_KnownAnswer(t *testing.T) {
+	ids, err := Pick(testSeed, entries(10), 3, false)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a", "c", "f"}, ids)
+
+	ids, err = Pick(testSeed, entries(10), 3, true)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"g", "h", "j"}, ids)
+}
+
+func TestPick(t *testing.T) {
+	first, err := Pick(testSeed, entries(20), 5, false)
+	assert.NoError(t, err)
+	assert.Len(t, first, 5)
+
+	again, _ := Pick(testSeed, entries(20), 5, false)
+	assert.Equal(t, first, again, "same seed picks the same winners")
+
+	other, _ := Pick(strings.Repeat("ff", 16), entries(20), 5, false)
+	assert.NotEqual(t, first, other, "another seed picks other winners")
+
+	seen := make(map[string]bool)
+	for _, id := range first {
+		assert.False(t, seen[id], "%s picked twice", id)
+		seen[id] = true
+	}
+}
+
+func TestPick_FewEntries(t *testing.T) {
+	ids, err := Pick(testSeed, entries(3), 5, false)
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []string{"a", "b", "c"}, ids)
+
+	ids, err = Pick(testSeed, nil, 5, false)
+	assert.NoError(t, err)
+	assert.Empty(t, ids)
+}
+
+func TestPick_Weighted(t *testing.T) {
+	// Entry a has weight 0, and is never picked in a weighted draw.
+	ids, err := Pick(testSeed, entries(3), 5, true)
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []string{"b", "c"}, ids)
+
+	// Heavier entries win more often.
+	es := []Entry{{"light", 1}, {"heavy", 99}}
+	heavy := 0
+	for i := 0; i < 100; i++ {
+		seed, err := NewSeed()
+		if err != nil {
+			t.Fatalf("NewSeed: %v", err)
+		}
+
+		ids, _ := Pick(seed, es, 1, true)
+		if ids[0] == "heavy" {
+			heavy++
+		}
+	}
+	assert.True(t, heavy > 80, "heavy picked %d of 100 times", heavy)
+}
+
+func TestPick_InvalidSeed(t *testing.T) {
+	_, err := Pick("not hex", entries(3), 1, false)
+	assert.Error(t, err)
+}
+
+func TestRun(t *testing.T) {
+	ps := []*participant.Participant{
+		{ID: aws.String("a"), Name: aws.String("Annie"), Score: aws.Int(10), Consent: aws.Bool(true)},
+		{ID: aws.String("b"), Name: aws.String("Bob"), Score: aws.Int(20), Consent: aws.Bool(true)},
+		{ID: aws.String("c"), Name: aws.String("Carl"), Score: aws.Int(30)},
+	}
+	now := time.Date(2020, 5, 1, 17, 0, 0, 0, time.UTC)
+
+	d, err := Run(Request{ID: "prize", Winners: 2, Criteria: Criteria{Consent: true}}, ps, now)
+	assert.NoError(t, err)
+
+	assert.Equal(t, "prize", d.ID)
+	assert.Equal(t, 2, d.Count)
+	assert.Len(t, d.Seed, 2*seedBytes)
+	assert.Equal(t, []Entry{{"a", 10}, {"b", 20}}, d.Entries)
+	assert.ElementsMatch(t, []Winner{{"a", "Annie"}, {"b", "Bob"}}, d.Winners)
+	assert.Equal(t, now, d.Created)
+
+	verified, err := Verify(d)
+	assert.NoError(t, err)
+	assert.True(t, verified, "the recorded seed repeats the draw")
+
+	again, err := Run(Request{ID: "prize", Winners: 2, Criteria: Criteria{Consent: true}}, ps, now)
+	assert.NoError(t, err)
+	assert.NotEqual(t, d.Seed, again.Seed, "every draw gets a new seed")
+}
+
+func TestVerify(t *testing.T) {
+	ids, _ := Pick(testSeed, entries(10), 2, true)
+	d := Draw{Count: 2, Weighted: true, Seed: testSeed, Entries: entries(10)}
+	for _, id := range ids {
+		d.Winners = append(d.Winners, Winner{Participant: id})
+	}
+
+	ok, err := Verify(d)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+
+	tampered := d.Clone()
+	tampered.Winners[0], tampered.Winners[1] = tampered.Winners[1], tampered.Winners[0]
+	ok, _ = Verify(tampered)
+	assert.False(t, ok, "winners swapped")
+
+	tampered = d.Clone()
+	tampered.Weighted = false
+	ok, _ = Verify(tampered)
+	assert.False(t, ok, "weighting changed")
+
+	tampered = d.Clone()
+	tampered.Winners = tampered.Winners[:1]
+	ok, _ = Verify(tampered)
+	assert.False(t, ok, "winner removed")
+
+	_, err = Verify(Draw{Seed: "not hex"})
+	assert.Error(t, err)
+}
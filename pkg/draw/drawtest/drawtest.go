@@ -0,0 +1,201 @@
+// Package drawtest checks draw.Repository implementations. A draw is the record proving how winners were picked, so
+// the suite checks that everything needed to re-run it, its entries, weights, criteria and seed, is stored as created
+// and never changed, also not through values returned by the repository.
+package drawtest
+
+import (
+	"context"
+	"errors"
+	"github.com/google/uuid"
+	"github.com/rejlersembriq/hooked/pkg/draw"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+// Factory returns a repository without draws, and a function removing it when the test is done.
+type Factory func(t *testing.T) (draw.Repository, func())
+
+// Run tests a new repository from newRepo in every case.
+func Run(t *testing.T, newRepo Factory) {
+	tests := []struct {
+		name string
+		test func(t *testing.T, repo draw.Repository)
+	}{
+		{"Create", testCreate},
+		{"CreateExists", testCreateExists},
+		{"NoEntries", testNoEntries},
+		{"GetNotExist", testGetNotExist},
+		{"List", testList},
+		{"ReturnedValuesNotShared", testReturnedValuesNotShared},
+		{"CancelledContext", testCancelledContext},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			repo, cleanup := newRepo(t)
+			defer cleanup()
+
+			test.test(t, repo)
+		})
+	}
+}
+
+func timestamp(offset time.Duration) time.Time {
+	return time.Date(2020, 5, 1, 17, 0, 0, 0, time.UTC).Add(offset)
+}
+
+func newDraw() draw.Draw {
+	event, org, minScore := "conf", "OrgA", 10
+
+	return draw.Draw{
+		ID:        "prize-" + uuid.New().String(),
+		Criteria:  draw.Criteria{Event: &event, Org: &org, MinScore: &minScore, Consent: true},
+		Count:     2,
+		Weighted:  true,
+		Seed:      "000102030405060708090a0b0c0d0e0f",
+		Entries:   []draw.Entry{{Participant: "a", Weight: 10}, {Participant: "b", Weight: 20}, {Participant: "c", Weight: 30}},
+		Winners:   []draw.Winner{{Participant: "c", Name: "Carl"}, {Participant: "a"}},
+		Created:   timestamp(0),
+		RequestID: "req-1",
+	}
+}
+
+func mustCreate(t *testing.T, repo draw.Repository, d draw.Draw) *draw.Draw {
+	t.Helper()
+
+	created, err := repo.Create(context.Background(), d)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	return created
+}
+
+func assertSameDraw(t *testing.T, expected draw.Draw, actual *draw.Draw) {
+	t.Helper()
+
+	assert.Equal(t, expected.ID, actual.ID)
+	assert.Equal(t, expected.Criteria, actual.Criteria)
+	assert.Equal(t, expected.Count, actual.Count)
+	assert.Equal(t, expected.Weighted, actual.Weighted)
+	assert.Equal(t, expected.Seed, actual.Seed)
+	assert.Equal(t, expected.Entries, actual.Entries)
+	assert.Equal(t, expected.Winners, actual.Winners)
+	assert.True(t, expected.Created.Equal(actual.Created), "created: expected %v, got %v", expected.Created, actual.Created)
+	assert.Equal(t, expected.RequestID, actual.RequestID)
+}
+
+func testCreate(t *testing.T, repo draw.Repository) {
+	d := newDraw()
+
+	created := mustCreate(t, repo, d)
+	assertSameDraw(t, d, created)
+
+	got, err := repo.Get(context.Background(), d.ID)
+	assert.NoError(t, err)
+	assertSameDraw(t, d, got)
+}
+
+func testCreateExists(t *testing.T, repo draw.Repository) {
+	d := mustCreate(t, repo, newDraw())
+
+	reroll := newDraw()
+	reroll.ID = d.ID
+	reroll.Seed = "ffffffffffffffffffffffffffffffff"
+	reroll.Winners = []draw.Winner{{Participant: "b"}}
+
+	_, err := repo.Create(context.Background(), reroll)
+	assert.True(t, errors.Is(err, draw.ErrExists), "expected ErrExists, got %v", err)
+
+	got, err := repo.Get(context.Background(), d.ID)
+	assert.NoError(t, err)
+	assertSameDraw(t, *d, got)
+}
+
+func testNoEntries(t *testing.T, repo draw.Repository) {
+	d := draw.Draw{
+		ID:      "empty-" + uuid.New().String(),
+		Count:   1,
+		Seed:    "000102030405060708090a0b0c0d0e0f",
+		Created: timestamp(0),
+	}
+
+	mustCreate(t, repo, d)
+
+	got, err := repo.Get(context.Background(), d.ID)
+	assert.NoError(t, err)
+	assert.Empty(t, got.Entries)
+	assert.Empty(t, got.Winners)
+	assert.Nil(t, got.Criteria.Event)
+	assert.Nil(t, got.Criteria.MinScore)
+}
+
+func testGetNotExist(t *testing.T, repo draw.Repository) {
+	_, err := repo.Get(context.Background(), "missing")
+	assert.True(t, errors.Is(err, draw.ErrNotExist), "expected ErrNotExist, got %v", err)
+}
+
+func testList(t *testing.T, repo draw.Repository) {
+	ds, err := repo.List(context.Background())
+	assert.NoError(t, err)
+	assert.Empty(t, ds)
+
+	first := newDraw()
+	second := newDraw()
+	second.Created = timestamp(time.Hour)
+
+	mustCreate(t, repo, first)
+	mustCreate(t, repo, second)
+
+	ds, err = repo.List(context.Background())
+	assert.NoError(t, err)
+
+	if assert.Len(t, ds, 2) {
+		assert.Equal(t, []string{second.ID, first.ID}, []string{ds[0].ID, ds[1].ID})
+	}
+}
+
+func testReturnedValuesNotShared(t *testing.T, repo draw.Repository) {
+	d := newDraw()
+	created := mustCreate(t, repo, d)
+
+	created.Winners[0].Participant = "changed"
+	*created.Criteria.Org = "changed"
+	d.Entries[0].Weight = 1000
+
+	got, err := repo.Get(context.Background(), d.ID)
+	assert.NoError(t, err)
+	assertSameDraw(t, newDrawWithID(d.ID), got)
+
+	got.Entries[0].Participant = "changed"
+
+	ds, err := repo.List(context.Background())
+	assert.NoError(t, err)
+	if assert.Len(t, ds, 1) {
+		assertSameDraw(t, newDrawWithID(d.ID), ds[0])
+	}
+}
+
+func newDrawWithID(id string) draw.Draw {
+	d := newDraw()
+	d.ID = id
+	return d
+}
+
+func testCancelledContext(t *testing.T, repo draw.Repository) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	d := newDraw()
+
+	_, err := repo.Create(ctx, d)
+	assert.Equal(t, context.Canceled, err)
+
+	_, err = repo.Get(ctx, d.ID)
+	assert.Equal(t, context.Canceled, err)
+
+	_, err = repo.List(ctx)
+	assert.Equal(t, context.Canceled, err)
+}
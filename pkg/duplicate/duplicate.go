@@ -0,0 +1,227 @@
+// Package duplicate finds participants likely to be registered more than once, and merges them into one.
+package duplicate
+
+import (
+	"github.com/rejlersembriq/hooked/pkg/participant"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Reason tells why two participants are likely the same person.
+type Reason string
+
+const (
+	// ReasonEmail is given for participants with the same normalized email. Only possible for participants saved
+	// before emails were unique.
+	ReasonEmail Reason = "email"
+	// ReasonPhone is given for participants with the same phone number, ignoring anything but digits.
+	ReasonPhone Reason = "phone"
+	// ReasonName is given for participants in the same organisation with similar names.
+	ReasonName Reason = "name"
+)
+
+// Names are similar when at least this share of their characters match, measured by edit distance.
+const nameSimilarity = 0.8
+
+// Candidate is a pair of participants likely to be the same person. The participant created first is the first.
+type Candidate struct {
+	Participants [2]*participant.Participant `json:"participants"`
+	Reasons      []Reason                    `json:"reasons"`
+}
+
+// Find returns the pairs of participants likely to be duplicates, ordered by the ids of the pairs. Only participants
+// in the same event are compared, as a person may take part in several events. Participants without an id are
+// ignored.
+func Find(ps []*participant.Participant) []Candidate {
+	candidates := make(map[[2]string]*Candidate)
+
+	add := func(a, b *participant.Participant, reason Reason) {
+		if earlier(b, a) {
+			a, b = b, a
+		}
+
+		key := [2]string{*a.ID, *b.ID}
+		c, exists := candidates[key]
+		if !exists {
+			c = &Candidate{Participants: [2]*participant.Participant{a, b}}
+			candidates[key] = c
+		}
+		c.Reasons = append(c.Reasons, reason)
+	}
+
+	var valid []*participant.Participant
+	for _, p := range ps {
+		if p.ID != nil {
+			valid = append(valid, p)
+		}
+	}
+
+	for _, group := range groupBy(valid, inEvent(emailKey)) {
+		pairs(group, func(a, b *participant.Participant) { add(a, b, ReasonEmail) })
+	}
+
+	for _, group := range groupBy(valid, inEvent(phoneKey)) {
+		pairs(group, func(a, b *participant.Participant) { add(a, b, ReasonPhone) })
+	}
+
+	for _, group := range groupBy(valid, inEvent(orgKey)) {
+		pairs(group, func(a, b *participant.Participant) {
+			if a.Name != nil && b.Name != nil && similarNames(*a.Name, *b.Name) {
+				add(a, b, ReasonName)
+			}
+		})
+	}
+
+	res := make([]Candidate, 0, len(candidates))
+	for _, c := range candidates {
+		res = append(res, *c)
+	}
+
+	sort.Slice(res, func(i, j int) bool {
+		a, b := res[i].Participants, res[j].Participants
+		if *a[0].ID != *b[0].ID {
+			return *a[0].ID < *b[0].ID
+		}
+		return *a[1].ID < *b[1].ID
+	})
+
+	return res
+}
+
+// earlier reports if a was created before b. Participants without a creation time sort last, and ties are broken by
+// id so the order is stable.
+func earlier(a, b *participant.Participant) bool {
+	switch {
+	case a.Created != nil && b.Created != nil && !a.Created.Equal(*b.Created):
+		return a.Created.Before(*b.Created)
+	case a.Created != nil && b.Created == nil:
+		return true
+	case a.Created == nil && b.Created != nil:
+		return false
+	default:
+		return *a.ID < *b.ID
+	}
+}
+
+// groupBy groups participants by key. Participants with an empty key are left out, and only groups with more than
+// one participant are returned.
+func groupBy(ps []*participant.Participant, key func(p *participant.Participant) string) [][]*participant.Participant {
+	groups := make(map[string][]*participant.Participant)
+	for _, p := range ps {
+		if k := key(p); k != "" {
+			groups[k] = append(groups[k], p)
+		}
+	}
+
+	var res [][]*participant.Participant
+	for _, g := range groups {
+		if len(g) > 1 {
+			res = append(res, g)
+		}
+	}
+
+	return res
+}
+
+func pairs(ps []*participant.Participant, f func(a, b *participant.Participant)) {
+	for i := range ps {
+		for j := i + 1; j < len(ps); j++ {
+			f(ps[i], ps[j])
+		}
+	}
+}
+
+// inEvent scopes key to the event of the participant. Events are slugs, which can't contain a space.
+func inEvent(key func(p *participant.Participant) string) func(p *participant.Participant) string {
+	return func(p *participant.Participant) string {
+		k := key(p)
+		if k == "" || p.Event == nil {
+			return k
+		}
+		return *p.Event + " " + k
+	}
+}
+
+func emailKey(p *participant.Participant) string {
+	if p.Email == nil {
+		return ""
+	}
+	return participant.NormalizeEmail(*p.Email)
+}
+
+func phoneKey(p *participant.Participant) string {
+	if p.Phone == nil {
+		return ""
+	}
+	return NormalizePhone(*p.Phone)
+}
+
+func orgKey(p *participant.Participant) string {
+	if p.Org == nil {
+		return ""
+	}
+	return normalizeName(*p.Org)
+}
+
+// NormalizePhone returns the digits of a phone number, so numbers formatted differently compare equal.
+func NormalizePhone(phone string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= '0' && r <= '9' {
+			return r
+		}
+		return -1
+	}, phone)
+}
+
+// normalizeName lower cases name and collapses whitespace.
+func normalizeName(name string) string {
+	return strings.Join(strings.FieldsFunc(strings.ToLower(name), unicode.IsSpace), " ")
+}
+
+// similarNames reports if the names differ by a small share of their characters, eg. a typo.
+func similarNames(a, b string) bool {
+	ra, rb := []rune(normalizeName(a)), []rune(normalizeName(b))
+
+	longest := len(ra)
+	if len(rb) > longest {
+		longest = len(rb)
+	}
+	if longest == 0 {
+		return false
+	}
+
+	return 1-float64(distance(ra, rb))/float64(longest) >= nameSimilarity
+}
+
+// distance returns the Levenshtein distance between a and b.
+func distance(a, b []rune) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+
+	return prev[len(b)]
+}
+
+func min(v int, vs ...int) int {
+	for _, x := range vs {
+		if x < v {
+			v = x
+		}
+	}
+	return v
+}
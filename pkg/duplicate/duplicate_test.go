@@ -0,0 +1,145 @@
+package duplicate
+
+import (
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/rejlersembriq/hooked/pkg/participant"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestFind(t *testing.T) {
+	base := time.Date(2019, 10, 1, 12, 0, 0, 0, time.UTC)
+	created := func(minutes int) *time.Time {
+		t := base.Add(time.Duration(minutes) * time.Minute)
+		return &t
+	}
+
+	ps := []*participant.Participant{
+		{ID: aws.String("a"), Name: aws.String("Test Testson"), Email: aws.String("test@testson.com"), Org: aws.String("OrgA"), Created: created(2)},
+		{ID: aws.String("b"), Name: aws.String("Other Person"), Email: aws.String(" TEST@testson.com"), Org: aws.String("OrgB"), Created: created(1)},
+		{ID: aws.String("c"), Name: aws.String("test  testsen"), Email: aws.String("c@testson.com"), Org: aws.String("orga"), Phone: aws.String("123 45 678")},
+		{ID: aws.String("d"), Name: aws.String("Someone Else"), Email: aws.String("d@testson.com"), Org: aws.String("OrgA"), Phone: aws.String("12345678")},
+		{ID: aws.String("e"), Name: aws.String("Test Testson"), Email: aws.String("e@testson.com"), Org: aws.String("OrgC")},
+		{Name: aws.String("Test Testson"), Email: aws.String("test@testson.com"), Org: aws.String("OrgA")},
+	}
+
+	candidates := Find(ps)
+
+	type pair struct {
+		a, b    string
+		reasons []Reason
+	}
+
+	var actual []pair
+	for _, c := range candidates {
+		actual = append(actual, pair{*c.Participants[0].ID, *c.Participants[1].ID, c.Reasons})
+	}
+
+	assert.Equal(t, []pair{
+		{"a", "c", []Reason{ReasonName}},
+		{"b", "a", []Reason{ReasonEmail}},
+		{"c", "d", []Reason{ReasonPhone}},
+	}, actual)
+}
+
+func TestFind_Event(t *testing.T) {
+	ps := []*participant.Participant{
+		{ID: aws.String("a"), Name: aws.String("Test Testson"), Email: aws.String("test@testson.com"), Org: aws.String("OrgA")},
+		{ID: aws.String("b"), Name: aws.String("Test Testson"), Email: aws.String("test@testson.com"), Org: aws.String("OrgA"), Event: aws.String("conf-a")},
+		{ID: aws.String("c"), Name: aws.String("Test Testson"), Email: aws.String("test@testson.com"), Org: aws.String("OrgA"), Event: aws.String("conf-b")},
+		{ID: aws.String("d"), Name: aws.String("Test Testsen"), Email: aws.String("d@testson.com"), Org: aws.String("OrgA"), Event: aws.String("conf-b")},
+	}
+
+	candidates := Find(ps)
+
+	if assert.Len(t, candidates, 1) {
+		assert.Equal(t, "c", *candidates[0].Participants[0].ID)
+		assert.Equal(t, "d", *candidates[0].Participants[1].ID)
+		assert.Equal(t, []Reason{ReasonName}, candidates[0].Reasons)
+	}
+}
+
+func TestFind_Empty(t *testing.T) {
+	assert.Len(t, Find(nil), 0)
+}
+
+func TestSimilarNames(t *testing.T) {
+	tests := []struct {
+		a, b     string
+		expected bool
+	}{
+		{"Test Testson", "Test Testson", true},
+		{"Test Testson", "test testson", true},
+		{"Test Testson", "Test  Testsen", true},
+		{"Test Testson", "Tset Testson", true},
+		{"Test Testson", "Other Person", false},
+		{"Ann", "Bob", false},
+		{"", "", false},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.expected, similarNames(test.a, test.b), "%q and %q", test.a, test.b)
+	}
+}
+
+func TestNormalizePhone(t *testing.T) {
+	assert.Equal(t, "4712345678", NormalizePhone("+47 123-45 678"))
+	assert.Equal(t, "", NormalizePhone("n/a"))
+}
+
+func TestMerge(t *testing.T) {
+	keep := participant.Participant{
+		ID:      aws.String("keep"),
+		Name:    aws.String("Test Testson"),
+		Email:   aws.String("keep@testson.com"),
+		Phone:   aws.String(""),
+		Score:   aws.Int(10),
+		Comment: aws.String("First."),
+		Version: aws.Int(3),
+	}
+
+	remove := participant.Participant{
+		ID:      aws.String("remove"),
+		Name:    aws.String("Test Testsen"),
+		Email:   aws.String("remove@testson.com"),
+		Phone:   aws.String("12345678"),
+		Org:     aws.String("OrgA"),
+		Score:   aws.Int(20),
+		Comment: aws.String("Second."),
+		Consent: aws.Bool(true),
+		Version: aws.Int(1),
+	}
+
+	merged := Merge(keep, remove)
+
+	assert.Equal(t, "keep", *merged.ID)
+	assert.Equal(t, "Test Testson", *merged.Name)
+	assert.Equal(t, "keep@testson.com", *merged.Email)
+	assert.Equal(t, "12345678", *merged.Phone)
+	assert.Equal(t, "OrgA", *merged.Org)
+	assert.Equal(t, 20, *merged.Score)
+	assert.Equal(t, "First.\nSecond.", *merged.Comment)
+	assert.True(t, *merged.Consent)
+	assert.Equal(t, 3, *merged.Version)
+
+	// The inputs are left unchanged.
+	assert.Equal(t, 10, *keep.Score)
+	assert.Equal(t, "", *keep.Phone)
+}
+
+func TestMerge_KeepsBest(t *testing.T) {
+	keep := participant.Participant{ID: aws.String("keep"), Score: aws.Int(20), Comment: aws.String("Same."), Consent: aws.Bool(false)}
+	remove := participant.Participant{ID: aws.String("remove"), Score: aws.Int(10), Comment: aws.String("Same."), Consent: aws.Bool(true)}
+
+	merged := Merge(keep, remove)
+
+	assert.Equal(t, 20, *merged.Score)
+	assert.Equal(t, "Same.", *merged.Comment)
+	assert.False(t, *merged.Consent)
+
+	merged = Merge(participant.Participant{ID: aws.String("keep")}, remove)
+
+	assert.Equal(t, 10, *merged.Score)
+	assert.Equal(t, "Same.", *merged.Comment)
+}
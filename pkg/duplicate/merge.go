@@ -0,0 +1,51 @@
+package duplicate
+
+import (
+	"github.com/rejlersembriq/hooked/pkg/participant"
+)
+
+// commentSeparator separates the comments of merged participants.
+const commentSeparator = "\n"
+
+// Merge returns keep with remove merged into it. The best score is kept, comments are concatenated, and fields missing
+// or empty in keep are taken from remove. Consent is taken from remove only if keep hasn't given an answer. Id, email,
+// timestamps and version are those of keep, as the email identifies the participant.
+func Merge(keep, remove participant.Participant) participant.Participant {
+	merged := keep.Clone()
+
+	merged.Name = fill(merged.Name, remove.Name)
+	merged.Phone = fill(merged.Phone, remove.Phone)
+	merged.Org = fill(merged.Org, remove.Org)
+
+	if remove.Score != nil && (merged.Score == nil || *remove.Score > *merged.Score) {
+		score := *remove.Score
+		merged.Score = &score
+	}
+
+	if remove.Comment != nil && *remove.Comment != "" {
+		switch {
+		case merged.Comment == nil || *merged.Comment == "":
+			comment := *remove.Comment
+			merged.Comment = &comment
+		case *merged.Comment != *remove.Comment:
+			comment := *merged.Comment + commentSeparator + *remove.Comment
+			merged.Comment = &comment
+		}
+	}
+
+	if merged.Consent == nil && remove.Consent != nil {
+		consent := *remove.Consent
+		merged.Consent = &consent
+	}
+
+	return merged
+}
+
+// fill returns v, or a copy of other if v is missing or empty.
+func fill(v, other *string) *string {
+	if (v == nil || *v == "") && other != nil && *other != "" {
+		c := *other
+		return &c
+	}
+	return v
+}
@@ -0,0 +1,167 @@
+// Package event defines the events, eg. conferences, the game is run at. Participants belong to at most one event,
+// identified by its slug.
+package event
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/rejlersembriq/hooked/pkg/participant"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// ErrNotExist should be returned when the requested event is not found in the repository.
+var ErrNotExist = errors.New("event doesn't exist")
+
+// ErrExists should be returned when creating an event with a slug already in use.
+var ErrExists = errors.New("event already exists")
+
+// ErrChanged should be returned when closing an event that changed since it was read, see Repository.Close.
+var ErrChanged = errors.New("event changed")
+
+// Status is the state of an event.
+type Status string
+
+const (
+	// StatusDraft is an event being prepared. Default for new events.
+	StatusDraft Status = "draft"
+	// StatusOpen is an event taking place.
+	StatusOpen Status = "open"
+	// StatusClosed is an event that has ended. Scores can't be changed.
+	StatusClosed Status = "closed"
+	// StatusArchived is an event kept for the record. Neither the event nor its scores can be changed.
+	StatusArchived Status = "archived"
+)
+
+// Validation limits.
+const (
+	MaxSlugLength = 64
+	MaxNameLength = 100
+)
+
+// Lower case letters and digits, in groups separated by single dashes. Used in urls and keys.
+var slugPattern = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+
+// Event is a single occasion the game is run at.
+type Event struct {
+	Slug    string     `json:"slug"`
+	Name    string     `json:"name"`
+	Start   *time.Time `json:"start,omitempty" dynamodbav:",unixtime"`
+	End     *time.Time `json:"end,omitempty" dynamodbav:",unixtime"`
+	Status  Status     `json:"status"`
+	Created *time.Time `json:"created,omitempty" dynamodbav:",unixtime"`
+	Updated *time.Time `json:"updated,omitempty" dynamodbav:",unixtime"`
+	// Overrides records every admin override of the lifecycle, oldest first. See Reopen.
+	Overrides []Override `json:"overrides,omitempty"`
+}
+
+// Clone returns a copy of e where every pointer field points to a new value.
+func (e Event) Clone() Event {
+	e.Start = copyTime(e.Start)
+	e.End = copyTime(e.End)
+	e.Created = copyTime(e.Created)
+	e.Updated = copyTime(e.Updated)
+	if e.Overrides != nil {
+		e.Overrides = append([]Override(nil), e.Overrides...)
+	}
+	return e
+}
+
+func copyTime(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	c := *t
+	return &c
+}
+
+// Repository persists events by slug. Slugs can't be changed, as participants refer to their event by it.
+type Repository interface {
+	// Create stores a new event. Returns ErrExists if the slug is in use.
+	Create(ctx context.Context, e Event) (*Event, error)
+	// Update replaces an event, keeping its creation time. Returns ErrNotExist if there is no event with the slug.
+	Update(ctx context.Context, e Event) (*Event, error)
+	Get(ctx context.Context, slug string) (*Event, error)
+	// Close sets the status of an open event ending at end to closed, leaving everything else. Returns ErrNotExist if
+	// there is no event with the slug, and ErrChanged if it isn't open or its end differs, eg. as it was reopened.
+	Close(ctx context.Context, slug string, end time.Time) (*Event, error)
+	// List returns all events, see Sort for the order.
+	List(ctx context.Context) ([]*Event, error)
+	Delete(ctx context.Context, slug string) error
+}
+
+// Sort orders events by start time, then slug. Events without a start time are last.
+func Sort(es []*Event) {
+	sort.Slice(es, func(i, j int) bool {
+		a, b := es[i], es[j]
+		switch {
+		case a.Start != nil && b.Start != nil && !a.Start.Equal(*b.Start):
+			return a.Start.Before(*b.Start)
+		case a.Start != nil && b.Start == nil:
+			return true
+		case a.Start == nil && b.Start != nil:
+			return false
+		default:
+			return a.Slug < b.Slug
+		}
+	})
+}
+
+// ValidSlug reports if s can be used as an event slug.
+func ValidSlug(s string) bool {
+	return len(s) <= MaxSlugLength && slugPattern.MatchString(s)
+}
+
+// Validate validates an event about to be saved. Returns a *participant.ValidationError listing every invalid field.
+func Validate(e Event) error {
+	verr := &participant.ValidationError{}
+	add := func(field, msg string) {
+		verr.Fields = append(verr.Fields, participant.FieldError{Field: field, Message: msg})
+	}
+
+	switch {
+	case e.Slug == "":
+		add("slug", "required")
+	case !ValidSlug(e.Slug):
+		add("slug", fmt.Sprintf("must be at most %d lower case letters, digits and single dashes", MaxSlugLength))
+	}
+
+	switch {
+	case strings.TrimSpace(e.Name) == "":
+		add("name", "must not be empty")
+	case utf8.RuneCountInString(e.Name) > MaxNameLength:
+		add("name", fmt.Sprintf("must be at most %d characters", MaxNameLength))
+	}
+
+	if e.Start != nil && e.End != nil && !e.End.After(*e.Start) {
+		add("end", "must be after start")
+	}
+
+	switch e.Status {
+	case StatusDraft, StatusOpen, StatusClosed, StatusArchived:
+	default:
+		add("status", "must be one of: draft, open, closed, archived")
+	}
+
+	if e.Created != nil {
+		add("created", "read only")
+	}
+
+	if e.Updated != nil {
+		add("updated", "read only")
+	}
+
+	if e.Overrides != nil {
+		add("overrides", "read only")
+	}
+
+	if len(verr.Fields) > 0 {
+		return verr
+	}
+
+	return nil
+}
@@ -0,0 +1,68 @@
+package event
+
+import (
+	"errors"
+	"github.com/rejlersembriq/hooked/pkg/participant"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestValidate(t *testing.T) {
+	start := time.Date(2020, 5, 1, 9, 0, 0, 0, time.UTC)
+	end := start.Add(8 * time.Hour)
+
+	assert.NoError(t, Validate(Event{Slug: "conf-2020", Name: "Conf", Start: &start, End: &end, Status: StatusOpen}))
+
+	tests := []struct {
+		event  Event
+		fields []string
+	}{
+		{Event{Status: StatusDraft}, []string{"slug", "name"}},
+		{Event{Slug: "Conf", Name: "Conf", Status: StatusDraft}, []string{"slug"}},
+		{Event{Slug: "conf--2020", Name: "Conf", Status: StatusDraft}, []string{"slug"}},
+		{Event{Slug: "-conf", Name: "Conf", Status: StatusDraft}, []string{"slug"}},
+		{Event{Slug: strings.Repeat("a", MaxSlugLength+1), Name: "Conf", Status: StatusDraft}, []string{"slug"}},
+		{Event{Slug: "conf", Name: " ", Status: StatusDraft}, []string{"name"}},
+		{Event{Slug: "conf", Name: strings.Repeat("a", MaxNameLength+1), Status: StatusDraft}, []string{"name"}},
+		{Event{Slug: "conf", Name: "Conf", Start: &end, End: &start, Status: StatusDraft}, []string{"end"}},
+		{Event{Slug: "conf", Name: "Conf", Status: "finished"}, []string{"status"}},
+		{Event{Slug: "conf", Name: "Conf", Status: StatusDraft, Created: &start, Updated: &start}, []string{"created", "updated"}},
+		{Event{Slug: "conf", Name: "Conf", Status: StatusDraft, Overrides: []Override{{Action: OverrideReopen}}}, []string{"overrides"}},
+	}
+
+	for _, test := range tests {
+		err := Validate(test.event)
+
+		var verr *participant.ValidationError
+		if assert.True(t, errors.As(err, &verr), "%+v", test.event) {
+			var fields []string
+			for _, f := range verr.Fields {
+				fields = append(fields, f.Field)
+			}
+			assert.Equal(t, test.fields, fields)
+		}
+	}
+}
+
+func TestSort(t *testing.T) {
+	early := time.Date(2020, 5, 1, 9, 0, 0, 0, time.UTC)
+	late := early.Add(time.Hour)
+
+	es := []*Event{
+		{Slug: "d"},
+		{Slug: "c", Start: &late},
+		{Slug: "b", Start: &early},
+		{Slug: "a"},
+		{Slug: "e", Start: &early},
+	}
+
+	Sort(es)
+
+	var slugs []string
+	for _, e := range es {
+		slugs = append(slugs, e.Slug)
+	}
+	assert.Equal(t, []string{"b", "e", "c", "a", "d"}, slugs)
+}
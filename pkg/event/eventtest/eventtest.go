@@ -0,0 +1,299 @@
+// Package eventtest checks event.Repository implementations: slugs are unique, updates keep the creation time and the
+// audit trail of overrides, Close only closes an event still open with the end it was read with, and List orders
+// events as Sort does.
+package eventtest
+
+import (
+	"context"
+	"errors"
+	"github.com/google/uuid"
+	"github.com/rejlersembriq/hooked/pkg/event"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+// Factory returns a repository without events, and a function removing it when the test is done.
+type Factory func(t *testing.T) (event.Repository, func())
+
+// Run tests a new repository from newRepo in every case.
+func Run(t *testing.T, newRepo Factory) {
+	tests := []struct {
+		name string
+		test func(t *testing.T, repo event.Repository)
+	}{
+		{"Create", testCreate},
+		{"CreateExists", testCreateExists},
+		{"Update", testUpdate},
+		{"UpdateNotExist", testUpdateNotExist},
+		{"Close", testClose},
+		{"CloseChanged", testCloseChanged},
+		{"Overrides", testOverrides},
+		{"GetNotExist", testGetNotExist},
+		{"List", testList},
+		{"Delete", testDelete},
+		{"ReturnedValuesNotShared", testReturnedValuesNotShared},
+		{"CancelledContext", testCancelledContext},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			repo, cleanup := newRepo(t)
+			defer cleanup()
+
+			test.test(t, repo)
+		})
+	}
+}
+
+func timestamp(offset time.Duration) *time.Time {
+	t := time.Date(2020, 5, 1, 9, 0, 0, 0, time.UTC).Add(offset)
+	return &t
+}
+
+func newEvent() event.Event {
+	return event.Event{
+		Slug:   "conf-" + uuid.New().String(),
+		Name:   "Conference",
+		Start:  timestamp(0),
+		End:    timestamp(8 * time.Hour),
+		Status: event.StatusOpen,
+	}
+}
+
+func mustCreate(t *testing.T, repo event.Repository, e event.Event) *event.Event {
+	t.Helper()
+
+	created, err := repo.Create(context.Background(), e)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	return created
+}
+
+func assertSameTime(t *testing.T, expected, actual *time.Time, field string) {
+	t.Helper()
+
+	if expected == nil || actual == nil {
+		assert.Equal(t, expected, actual, field)
+		return
+	}
+	assert.True(t, expected.Equal(*actual), "%s: expected %v, got %v", field, expected, actual)
+}
+
+func testCreate(t *testing.T, repo event.Repository) {
+	e := newEvent()
+	before := time.Now().Truncate(time.Second)
+
+	created := mustCreate(t, repo, e)
+
+	assert.Equal(t, e.Slug, created.Slug)
+	assert.Equal(t, e.Name, created.Name)
+	assert.Equal(t, e.Status, created.Status)
+	assertSameTime(t, e.Start, created.Start, "start")
+	assertSameTime(t, e.End, created.End, "end")
+	if assert.NotNil(t, created.Created) && assert.NotNil(t, created.Updated) {
+		assert.False(t, created.Created.Before(before))
+	}
+
+	got, err := repo.Get(context.Background(), e.Slug)
+	assert.NoError(t, err)
+	assert.Equal(t, e.Name, got.Name)
+	assertSameTime(t, e.Start, got.Start, "start")
+	assertSameTime(t, created.Created, got.Created, "created")
+}
+
+func testCreateExists(t *testing.T, repo event.Repository) {
+	e := mustCreate(t, repo, newEvent())
+
+	dup := newEvent()
+	dup.Slug = e.Slug
+	dup.Name = "Other"
+
+	_, err := repo.Create(context.Background(), dup)
+	assert.True(t, errors.Is(err, event.ErrExists), "expected ErrExists, got %v", err)
+
+	got, err := repo.Get(context.Background(), e.Slug)
+	assert.NoError(t, err)
+	assert.Equal(t, "Conference", got.Name, "existing event should be unchanged")
+}
+
+func testUpdate(t *testing.T, repo event.Repository) {
+	created := mustCreate(t, repo, newEvent())
+
+	update := event.Event{Slug: created.Slug, Name: "Renamed", Status: event.StatusClosed}
+	updated, err := repo.Update(context.Background(), update)
+	assert.NoError(t, err)
+
+	assert.Equal(t, "Renamed", updated.Name)
+	assert.Equal(t, event.StatusClosed, updated.Status)
+	assert.Nil(t, updated.Start, "update replaces the event")
+	assert.Nil(t, updated.End, "update replaces the event")
+	assertSameTime(t, created.Created, updated.Created, "created")
+	assert.NotNil(t, updated.Updated)
+
+	got, err := repo.Get(context.Background(), created.Slug)
+	assert.NoError(t, err)
+	assert.Equal(t, "Renamed", got.Name)
+	assert.Nil(t, got.Start)
+}
+
+func testOverrides(t *testing.T, repo event.Repository) {
+	e := newEvent()
+	e.Status = event.StatusClosed
+	created := mustCreate(t, repo, e)
+
+	end := time.Now().Add(time.Hour).Truncate(time.Second)
+	reopened, err := event.Reopen(*created, time.Now(), "Score registered on the wrong participant", &end, "req-1", "key-1")
+	assert.NoError(t, err)
+
+	_, err = repo.Update(context.Background(), reopened)
+	assert.NoError(t, err)
+
+	got, err := repo.Get(context.Background(), created.Slug)
+	assert.NoError(t, err)
+	assert.Equal(t, event.StatusOpen, got.Status)
+
+	if assert.Len(t, got.Overrides, 1) {
+		o := got.Overrides[0]
+		assert.Equal(t, event.OverrideReopen, o.Action)
+		assert.Equal(t, event.StatusClosed, o.From)
+		assert.Equal(t, "Score registered on the wrong participant", o.Reason)
+		assert.Equal(t, "req-1", o.RequestID)
+		assert.Equal(t, "key-1", o.By)
+		assert.True(t, o.At.Sub(reopened.Overrides[0].At) < time.Second, "expected %v, got %v", reopened.Overrides[0].At, o.At)
+	}
+}
+
+func testUpdateNotExist(t *testing.T, repo event.Repository) {
+	_, err := repo.Update(context.Background(), newEvent())
+	assert.True(t, errors.Is(err, event.ErrNotExist), "expected ErrNotExist, got %v", err)
+}
+
+func testClose(t *testing.T, repo event.Repository) {
+	created := mustCreate(t, repo, newEvent())
+
+	closed, err := repo.Close(context.Background(), created.Slug, *created.End)
+	assert.NoError(t, err)
+	assert.Equal(t, event.StatusClosed, closed.Status)
+
+	got, err := repo.Get(context.Background(), created.Slug)
+	assert.NoError(t, err)
+	assert.Equal(t, event.StatusClosed, got.Status)
+	assert.Equal(t, created.Name, got.Name, "close should only change the status")
+	assertSameTime(t, created.Start, got.Start, "start")
+	assertSameTime(t, created.End, got.End, "end")
+	assertSameTime(t, created.Created, got.Created, "created")
+
+	_, err = repo.Close(context.Background(), "missing", *created.End)
+	assert.True(t, errors.Is(err, event.ErrNotExist), "expected ErrNotExist, got %v", err)
+}
+
+func testCloseChanged(t *testing.T, repo event.Repository) {
+	created := mustCreate(t, repo, newEvent())
+
+	_, err := repo.Close(context.Background(), created.Slug, created.End.Add(-time.Hour))
+	assert.True(t, errors.Is(err, event.ErrChanged), "other end: expected ErrChanged, got %v", err)
+
+	draft := newEvent()
+	draft.Status = event.StatusDraft
+	draft = *mustCreate(t, repo, draft)
+
+	_, err = repo.Close(context.Background(), draft.Slug, *draft.End)
+	assert.True(t, errors.Is(err, event.ErrChanged), "not open: expected ErrChanged, got %v", err)
+
+	got, err := repo.Get(context.Background(), created.Slug)
+	assert.NoError(t, err)
+	assert.Equal(t, event.StatusOpen, got.Status)
+}
+
+func testGetNotExist(t *testing.T, repo event.Repository) {
+	_, err := repo.Get(context.Background(), "missing")
+	assert.True(t, errors.Is(err, event.ErrNotExist), "expected ErrNotExist, got %v", err)
+}
+
+func testList(t *testing.T, repo event.Repository) {
+	es, err := repo.List(context.Background())
+	assert.NoError(t, err)
+	assert.Empty(t, es)
+
+	late := newEvent()
+	late.Start = timestamp(time.Hour)
+	late.End = nil
+	early := newEvent()
+	unscheduled := newEvent()
+	unscheduled.Start = nil
+	unscheduled.End = nil
+
+	mustCreate(t, repo, late)
+	mustCreate(t, repo, unscheduled)
+	mustCreate(t, repo, early)
+
+	es, err = repo.List(context.Background())
+	assert.NoError(t, err)
+
+	if assert.Len(t, es, 3) {
+		assert.Equal(t, []string{early.Slug, late.Slug, unscheduled.Slug}, []string{es[0].Slug, es[1].Slug, es[2].Slug})
+	}
+}
+
+func testDelete(t *testing.T, repo event.Repository) {
+	e := mustCreate(t, repo, newEvent())
+
+	assert.NoError(t, repo.Delete(context.Background(), e.Slug))
+
+	_, err := repo.Get(context.Background(), e.Slug)
+	assert.True(t, errors.Is(err, event.ErrNotExist), "expected ErrNotExist, got %v", err)
+
+	err = repo.Delete(context.Background(), e.Slug)
+	assert.True(t, errors.Is(err, event.ErrNotExist), "expected ErrNotExist, got %v", err)
+
+	mustCreate(t, repo, event.Event{Slug: e.Slug, Name: "Again", Status: event.StatusDraft})
+}
+
+func testReturnedValuesNotShared(t *testing.T, repo event.Repository) {
+	e := newEvent()
+	created := mustCreate(t, repo, e)
+
+	*created.Start = created.Start.Add(time.Hour)
+	*e.End = e.End.Add(time.Hour)
+
+	got, err := repo.Get(context.Background(), e.Slug)
+	assert.NoError(t, err)
+	assertSameTime(t, timestamp(0), got.Start, "start")
+	assertSameTime(t, timestamp(8*time.Hour), got.End, "end")
+
+	*got.Start = got.Start.Add(time.Hour)
+
+	es, err := repo.List(context.Background())
+	assert.NoError(t, err)
+	if assert.Len(t, es, 1) {
+		assertSameTime(t, timestamp(0), es[0].Start, "start")
+	}
+}
+
+func testCancelledContext(t *testing.T, repo event.Repository) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	e := newEvent()
+
+	_, err := repo.Create(ctx, e)
+	assert.Equal(t, context.Canceled, err)
+
+	_, err = repo.Update(ctx, e)
+	assert.Equal(t, context.Canceled, err)
+
+	_, err = repo.Close(ctx, e.Slug, *e.End)
+	assert.Equal(t, context.Canceled, err)
+
+	_, err = repo.Get(ctx, e.Slug)
+	assert.Equal(t, context.Canceled, err)
+
+	_, err = repo.List(ctx)
+	assert.Equal(t, context.Canceled, err)
+
+	assert.Equal(t, context.Canceled, repo.Delete(ctx, e.Slug))
+}
@@ -0,0 +1,144 @@
+package event
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/rejlersembriq/hooked/pkg/participant"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// ErrInvalidTransition is returned when changing the status of an event in a way the lifecycle doesn't allow.
+var ErrInvalidTransition = errors.New("invalid event status transition")
+
+// OverrideReopen is the action of an Override reopening a closed event.
+const OverrideReopen = "reopen"
+
+// MaxReasonLength limits the reason given for an override.
+const MaxReasonLength = 500
+
+// Override is an audit record of an admin overriding the lifecycle of an event. By is the caller, the id of its API
+// key or the subject of its JWT, and empty if authentication isn't enabled.
+type Override struct {
+	Action    string    `json:"action"`
+	From      Status    `json:"from"`
+	Reason    string    `json:"reason"`
+	At        time.Time `json:"at" dynamodbav:",unixtime"`
+	RequestID string    `json:"requestId,omitempty"`
+	By        string    `json:"by,omitempty"`
+}
+
+// StatusAt returns the status of e at t. Open events are closed automatically at their end time, so an open event
+// past its end is closed even before the closing is saved, see CloseExpired.
+func (e Event) StatusAt(t time.Time) Status {
+	if e.Status == StatusOpen && e.End != nil && !t.Before(*e.End) {
+		return StatusClosed
+	}
+	return e.Status
+}
+
+// ScoresLocked reports if the scores of participants in e can't be changed at t.
+func (e Event) ScoresLocked(t time.Time) bool {
+	switch e.StatusAt(t) {
+	case StatusClosed, StatusArchived:
+		return true
+	default:
+		return false
+	}
+}
+
+// Transition returns ErrInvalidTransition unless the lifecycle allows changing the status from one to the other.
+// Events move forward from draft to open, closed and archived. A closed event can only be opened again by Reopen.
+func Transition(from, to Status) error {
+	switch {
+	case from == to,
+		from == StatusDraft && to == StatusOpen,
+		from == StatusOpen && to == StatusClosed,
+		from == StatusClosed && to == StatusArchived:
+		return nil
+	default:
+		return ErrInvalidTransition
+	}
+}
+
+// Reopen returns e opened again at now, with the override by the caller recorded. A reason is required. If the end of
+// e has passed, a new end in the future must be given, otherwise the event would close again right away. Returns
+// ErrInvalidTransition if e isn't closed at now, or a *participant.ValidationError for an invalid reason or end.
+func Reopen(e Event, now time.Time, reason string, end *time.Time, requestID, by string) (Event, error) {
+	from := e.StatusAt(now)
+	if from != StatusClosed {
+		return e, ErrInvalidTransition
+	}
+
+	verr := &participant.ValidationError{}
+	add := func(field, msg string) {
+		verr.Fields = append(verr.Fields, participant.FieldError{Field: field, Message: msg})
+	}
+
+	switch {
+	case strings.TrimSpace(reason) == "":
+		add("reason", "required")
+	case utf8.RuneCountInString(reason) > MaxReasonLength:
+		add("reason", fmt.Sprintf("must be at most %d characters", MaxReasonLength))
+	}
+
+	switch {
+	case end != nil && !end.After(now):
+		add("end", "must be in the future")
+	case end != nil && e.Start != nil && !end.After(*e.Start):
+		add("end", "must be after start")
+	case end == nil && e.End != nil && !e.End.After(now):
+		add("end", "required, the event has ended")
+	}
+
+	if len(verr.Fields) > 0 {
+		return e, verr
+	}
+
+	e = e.Clone()
+	e.Status = StatusOpen
+	if end != nil {
+		c := *end
+		e.End = &c
+	}
+	e.Overrides = append(e.Overrides, Override{
+		Action:    OverrideReopen,
+		From:      from,
+		Reason:    reason,
+		At:        now,
+		RequestID: requestID,
+		By:        by,
+	})
+
+	return e, nil
+}
+
+// CloseExpired saves every open event past its end time as closed. Events changed since they were listed, eg. reopened
+// with a new end, are left as they are. Returns the events closed.
+func CloseExpired(ctx context.Context, repo Repository, now time.Time) ([]*Event, error) {
+	es, err := repo.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var closed []*Event
+	for _, e := range es {
+		if e.Status != StatusOpen || e.StatusAt(now) != StatusClosed {
+			continue
+		}
+
+		saved, err := repo.Close(ctx, e.Slug, *e.End)
+		if errors.Is(err, ErrNotExist) || errors.Is(err, ErrChanged) {
+			continue
+		}
+		if err != nil {
+			return closed, err
+		}
+
+		closed = append(closed, saved)
+	}
+
+	return closed, nil
+}
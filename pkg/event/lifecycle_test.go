@@ -0,0 +1,186 @@
+package event
+
+import (
+	"context"
+	"errors"
+	"github.com/rejlersembriq/hooked/pkg/participant"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+// repoStub holds events by slug. Only List and Close are used by CloseExpired. listed is called after listing.
+type repoStub struct {
+	Repository
+	events map[string]Event
+	listed func()
+}
+
+func (r *repoStub) List(ctx context.Context) ([]*Event, error) {
+	var es []*Event
+	for _, e := range r.events {
+		e := e.Clone()
+		es = append(es, &e)
+	}
+	Sort(es)
+	if r.listed != nil {
+		r.listed()
+	}
+	return es, nil
+}
+
+func (r *repoStub) Close(ctx context.Context, slug string, end time.Time) (*Event, error) {
+	e, exists := r.events[slug]
+	if !exists {
+		return nil, ErrNotExist
+	}
+	if e.Status != StatusOpen || e.End == nil || !e.End.Equal(end) {
+		return nil, ErrChanged
+	}
+	e.Status = StatusClosed
+	r.events[slug] = e
+	return &e, nil
+}
+
+func TestEvent_StatusAt(t *testing.T) {
+	end := time.Date(2020, 5, 1, 17, 0, 0, 0, time.UTC)
+	before, after := end.Add(-time.Minute), end.Add(time.Minute)
+
+	tests := []struct {
+		event  Event
+		at     time.Time
+		status Status
+		locked bool
+	}{
+		{Event{Status: StatusOpen, End: &end}, before, StatusOpen, false},
+		{Event{Status: StatusOpen, End: &end}, end, StatusClosed, true},
+		{Event{Status: StatusOpen, End: &end}, after, StatusClosed, true},
+		{Event{Status: StatusOpen}, after, StatusOpen, false},
+		{Event{Status: StatusDraft, End: &end}, after, StatusDraft, false},
+		{Event{Status: StatusClosed}, before, StatusClosed, true},
+		{Event{Status: StatusArchived}, before, StatusArchived, true},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.status, test.event.StatusAt(test.at), "%+v at %v", test.event, test.at)
+		assert.Equal(t, test.locked, test.event.ScoresLocked(test.at), "%+v at %v", test.event, test.at)
+	}
+}
+
+func TestTransition(t *testing.T) {
+	allowed := map[[2]Status]bool{
+		{StatusDraft, StatusOpen}:      true,
+		{StatusOpen, StatusClosed}:     true,
+		{StatusClosed, StatusArchived}: true,
+	}
+
+	statuses := []Status{StatusDraft, StatusOpen, StatusClosed, StatusArchived}
+	for _, from := range statuses {
+		for _, to := range statuses {
+			err := Transition(from, to)
+			if from == to || allowed[[2]Status{from, to}] {
+				assert.NoError(t, err, "%s -> %s", from, to)
+			} else {
+				assert.Equal(t, ErrInvalidTransition, err, "%s -> %s", from, to)
+			}
+		}
+	}
+}
+
+func TestReopen(t *testing.T) {
+	now := time.Date(2020, 5, 2, 9, 0, 0, 0, time.UTC)
+	start := now.Add(-48 * time.Hour)
+	ended := now.Add(-time.Hour)
+	later := now.Add(time.Hour)
+
+	closed := Event{Slug: "conf", Name: "Conf", Start: &start, End: &ended, Status: StatusOpen}
+
+	reopened, err := Reopen(closed, now, "Wrong score registered", &later, "req-1", "key-1")
+	assert.NoError(t, err)
+	assert.Equal(t, StatusOpen, reopened.Status)
+	assert.Equal(t, StatusOpen, reopened.StatusAt(now))
+	assert.Equal(t, later, *reopened.End)
+	assert.Equal(t, []Override{{Action: OverrideReopen, From: StatusClosed, Reason: "Wrong score registered", At: now, RequestID: "req-1", By: "key-1"}}, reopened.Overrides)
+	assert.Equal(t, ended, *closed.End, "the original event should not change")
+
+	_, err = Reopen(reopened, now, "Again", nil, "", "")
+	assert.Equal(t, ErrInvalidTransition, err, "open events can't be reopened")
+
+	_, err = Reopen(Event{Status: StatusArchived}, now, "Archived", nil, "", "")
+	assert.Equal(t, ErrInvalidTransition, err, "archived events can't be reopened")
+
+	manual := Event{Slug: "conf", Name: "Conf", Status: StatusClosed}
+	reopened, err = Reopen(manual, now, "Closed by mistake", nil, "", "")
+	assert.NoError(t, err)
+	assert.Nil(t, reopened.End)
+
+	tests := []struct {
+		reason string
+		end    *time.Time
+		fields []string
+	}{
+		{" ", &later, []string{"reason"}},
+		{"Reason", nil, []string{"end"}},
+		{"Reason", &ended, []string{"end"}},
+	}
+
+	for _, test := range tests {
+		_, err := Reopen(closed, now, test.reason, test.end, "", "")
+
+		var verr *participant.ValidationError
+		if assert.True(t, errors.As(err, &verr), "%q %v", test.reason, test.end) {
+			var fields []string
+			for _, f := range verr.Fields {
+				fields = append(fields, f.Field)
+			}
+			assert.Equal(t, test.fields, fields)
+		}
+	}
+}
+
+func TestCloseExpired(t *testing.T) {
+	now := time.Date(2020, 5, 2, 9, 0, 0, 0, time.UTC)
+	ended := now.Add(-time.Hour)
+	later := now.Add(time.Hour)
+
+	repo := &repoStub{events: map[string]Event{
+		"ended":   {Slug: "ended", Status: StatusOpen, End: &ended},
+		"running": {Slug: "running", Status: StatusOpen, End: &later},
+		"draft":   {Slug: "draft", Status: StatusDraft, End: &ended},
+		"closed":  {Slug: "closed", Status: StatusClosed, End: &ended},
+	}}
+
+	closed, err := CloseExpired(context.Background(), repo, now)
+	assert.NoError(t, err)
+	if assert.Len(t, closed, 1) {
+		assert.Equal(t, "ended", closed[0].Slug)
+	}
+
+	assert.Equal(t, StatusClosed, repo.events["ended"].Status)
+	assert.Equal(t, StatusOpen, repo.events["running"].Status)
+	assert.Equal(t, StatusDraft, repo.events["draft"].Status)
+}
+
+func TestCloseExpired_Reopened(t *testing.T) {
+	now := time.Date(2020, 5, 2, 9, 0, 0, 0, time.UTC)
+	ended := now.Add(-time.Hour)
+	later := now.Add(time.Hour)
+
+	repo := &repoStub{events: map[string]Event{
+		"ended": {Slug: "ended", Status: StatusOpen, End: &ended},
+	}}
+
+	// The event is reopened with a new end after it was listed, before it's closed.
+	repo.listed = func() {
+		reopened, err := Reopen(repo.events["ended"], now, "Late scores", &later, "", "")
+		assert.NoError(t, err)
+		repo.events["ended"] = reopened
+	}
+
+	closed, err := CloseExpired(context.Background(), repo, now)
+	assert.NoError(t, err)
+	assert.Empty(t, closed)
+
+	assert.Equal(t, StatusOpen, repo.events["ended"].Status)
+	assert.Equal(t, later, *repo.events["ended"].End)
+}
@@ -0,0 +1,50 @@
+// Package idempotency defines storage for idempotency keys, letting clients safely retry requests that create
+// resources. The first request with a key reserves it, and its response is stored so retries get the same response
+// instead of creating the resource again.
+package idempotency
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+)
+
+// Response is a stored response, replayed for retries.
+type Response struct {
+	Status int               `json:"status"`
+	Header map[string]string `json:"header,omitempty"`
+	Body   []byte            `json:"body,omitempty"`
+}
+
+// Record is the state of an idempotency key. Response is nil while the first request is in progress. Expires is the
+// end of the lease of an in progress record, so a key abandoned by a failed request can be reserved again, and the end
+// of the replay window of a completed record.
+type Record struct {
+	Key         string
+	Fingerprint string
+	Expires     time.Time
+	Response    *Response
+}
+
+// Expired reports if the record is no longer valid at t.
+func (r Record) Expired(t time.Time) bool {
+	return !t.Before(r.Expires)
+}
+
+// Store persists idempotency records.
+type Store interface {
+	// Reserve stores rec unless an unexpired record with the same key exists. Returns the existing record, or nil if
+	// the key was reserved by this call.
+	Reserve(ctx context.Context, rec Record) (*Record, error)
+	// Complete stores the response for a reserved key, keeping the record until expires.
+	Complete(ctx context.Context, key string, res Response, expires time.Time) error
+	// Release removes a reservation, so the request can be retried. Used when the request failed.
+	Release(ctx context.Context, key string) error
+}
+
+// Fingerprint identifies a request payload, to detect a key reused for a different request.
+func Fingerprint(payload []byte) string {
+	sum := sha256.Sum256(payload)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,210 @@
+// Package idempotencytest checks idempotency.Store implementations against what the server relies on: of concurrent
+// requests with the same key exactly one reserves it, a reservation lapses when its lease expires, and a completed
+// response is replayed, unshared, until it expires.
+package idempotencytest
+
+import (
+	"context"
+	"github.com/rejlersembriq/hooked/pkg/idempotency"
+	"github.com/stretchr/testify/assert"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Factory returns an empty store, and a function removing it when the test is done.
+type Factory func(t *testing.T) (idempotency.Store, func())
+
+// Run tests a new store from newStore in every case. Stores must be safe for concurrent use, as reservations are made
+// concurrently.
+func Run(t *testing.T, newStore Factory) {
+	tests := []struct {
+		name string
+		test func(t *testing.T, store idempotency.Store)
+	}{
+		{"Reserve", testReserve},
+		{"Complete", testComplete},
+		{"CompleteNotReserved", testCompleteNotReserved},
+		{"Release", testRelease},
+		{"Expired", testExpired},
+		{"LeaseExpired", testLeaseExpired},
+		{"CompleteExtendsLease", testCompleteExtendsLease},
+		{"ReturnedValuesNotShared", testReturnedValuesNotShared},
+		{"CancelledContext", testCancelledContext},
+		{"ConcurrentReserve", testConcurrentReserve},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			store, cleanup := newStore(t)
+			defer cleanup()
+
+			test.test(t, store)
+		})
+	}
+}
+
+func record(key string) idempotency.Record {
+	return idempotency.Record{
+		Key:         key,
+		Fingerprint: idempotency.Fingerprint([]byte(key)),
+		Expires:     time.Now().Add(time.Hour),
+	}
+}
+
+func response() idempotency.Response {
+	return idempotency.Response{
+		Status: 200,
+		Header: map[string]string{"Content-Type": "application/json"},
+		Body:   []byte(`{"id":"someId"}`),
+	}
+}
+
+func mustReserve(t *testing.T, store idempotency.Store, rec idempotency.Record) {
+	t.Helper()
+
+	existing, err := store.Reserve(context.Background(), rec)
+	if err != nil {
+		t.Fatalf("Reserve: %v", err)
+	}
+
+	if existing != nil {
+		t.Fatalf("Reserve: key %s already reserved", rec.Key)
+	}
+}
+
+func testReserve(t *testing.T, store idempotency.Store) {
+	rec := record("key")
+	mustReserve(t, store, rec)
+
+	existing, err := store.Reserve(context.Background(), record("key"))
+	assert.NoError(t, err)
+
+	if assert.NotNil(t, existing) {
+		assert.Equal(t, rec.Key, existing.Key)
+		assert.Equal(t, rec.Fingerprint, existing.Fingerprint)
+		assert.Nil(t, existing.Response, "in progress record should have no response")
+	}
+
+	mustReserve(t, store, record("other"))
+}
+
+func testComplete(t *testing.T, store idempotency.Store) {
+	mustReserve(t, store, record("key"))
+
+	assert.NoError(t, store.Complete(context.Background(), "key", response(), time.Now().Add(time.Hour)))
+
+	existing, err := store.Reserve(context.Background(), record("key"))
+	assert.NoError(t, err)
+
+	if assert.NotNil(t, existing) && assert.NotNil(t, existing.Response) {
+		assert.Equal(t, response(), *existing.Response)
+	}
+}
+
+func testCompleteNotReserved(t *testing.T, store idempotency.Store) {
+	assert.NoError(t, store.Complete(context.Background(), "key", response(), time.Now().Add(time.Hour)))
+
+	mustReserve(t, store, record("key"))
+}
+
+func testRelease(t *testing.T, store idempotency.Store) {
+	mustReserve(t, store, record("key"))
+
+	assert.NoError(t, store.Release(context.Background(), "key"))
+	assert.NoError(t, store.Release(context.Background(), "key"), "releasing twice should not fail")
+
+	mustReserve(t, store, record("key"))
+}
+
+func testExpired(t *testing.T, store idempotency.Store) {
+	mustReserve(t, store, record("key"))
+	assert.NoError(t, store.Complete(context.Background(), "key", response(), time.Now().Add(-time.Second)))
+
+	mustReserve(t, store, record("key"))
+}
+
+func testLeaseExpired(t *testing.T, store idempotency.Store) {
+	rec := record("key")
+	rec.Expires = time.Now().Add(-time.Second)
+	mustReserve(t, store, rec)
+
+	mustReserve(t, store, record("key"))
+}
+
+func testCompleteExtendsLease(t *testing.T, store idempotency.Store) {
+	rec := record("key")
+	rec.Expires = time.Now().Add(-time.Second)
+	mustReserve(t, store, rec)
+	assert.NoError(t, store.Complete(context.Background(), "key", response(), time.Now().Add(time.Hour)))
+
+	existing, err := store.Reserve(context.Background(), record("key"))
+	assert.NoError(t, err)
+	assert.NotNil(t, existing, "completed record should be kept until it expires")
+}
+
+func testReturnedValuesNotShared(t *testing.T, store idempotency.Store) {
+	mustReserve(t, store, record("key"))
+
+	res := response()
+	assert.NoError(t, store.Complete(context.Background(), "key", res, time.Now().Add(time.Hour)))
+	res.Header["Content-Type"] = "changed"
+	res.Body[0] = 'x'
+
+	existing, err := store.Reserve(context.Background(), record("key"))
+	assert.NoError(t, err)
+	existing.Response.Header["Content-Type"] = "changed"
+	existing.Response.Body[0] = 'x'
+
+	existing, err = store.Reserve(context.Background(), record("key"))
+	assert.NoError(t, err)
+	assert.Equal(t, response(), *existing.Response)
+}
+
+func testCancelledContext(t *testing.T, store idempotency.Store) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := store.Reserve(ctx, record("key"))
+	assert.Equal(t, context.Canceled, err, "Reserve")
+
+	assert.Equal(t, context.Canceled, store.Complete(ctx, "key", response(), time.Now().Add(time.Hour)), "Complete")
+	assert.Equal(t, context.Canceled, store.Release(ctx, "key"), "Release")
+}
+
+func testConcurrentReserve(t *testing.T, store idempotency.Store) {
+	const n = 20
+
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		reserved int
+	)
+
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			rec := record("key")
+			rec.Fingerprint = strconv.Itoa(i)
+
+			existing, err := store.Reserve(context.Background(), rec)
+			if !assert.NoError(t, err) {
+				return
+			}
+
+			if existing == nil {
+				mu.Lock()
+				reserved++
+				mu.Unlock()
+			}
+		}(i)
+	}
+
+	wg.Wait()
+
+	assert.Equal(t, 1, reserved, "exactly one request should reserve the key")
+}
@@ -0,0 +1,338 @@
+// Package jwt verifies JSON Web Tokens (RFC 7519) signed with HS256 or RS256 (RFC 7518). RS256 keys are read from a
+// JSON Web Key Set (RFC 7517), eg. exported from the identity provider. Tokens are only verified, never issued.
+package jwt
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"math/big"
+	"strings"
+	"time"
+)
+
+// Signing algorithms.
+const (
+	HS256 = "HS256"
+	RS256 = "RS256"
+)
+
+// Errors returned by Verify.
+var (
+	ErrMalformed   = errors.New("malformed token")
+	ErrAlgorithm   = errors.New("unsupported signing algorithm")
+	ErrSignature   = errors.New("invalid signature")
+	ErrNoExpiry    = errors.New("token has no expiry")
+	ErrExpired     = errors.New("token expired")
+	ErrNotYetValid = errors.New("token not yet valid")
+	ErrIssuer      = errors.New("unexpected issuer")
+	ErrAudience    = errors.New("unexpected audience")
+)
+
+// DefaultLeeway allows for clock skew between the issuer and the verifier.
+const DefaultLeeway = time.Minute
+
+// minRSABits is the smallest RSA key accepted, as recommended by RFC 7518.
+const minRSABits = 2048
+
+// Claims are the claims of a verified token, decoded from JSON. Numbers are json.Number.
+type Claims map[string]interface{}
+
+// String returns a string claim, or "" if it's missing or not a string.
+func (c Claims) String(name string) string {
+	s, _ := c[name].(string)
+	return s
+}
+
+// Strings returns a claim holding a string or an array of strings, like "aud". Other values are left out.
+func (c Claims) Strings(name string) []string {
+	switch v := c[name].(type) {
+	case string:
+		return []string{v}
+	case []interface{}:
+		var ss []string
+		for _, e := range v {
+			if s, ok := e.(string); ok {
+				ss = append(ss, s)
+			}
+		}
+		return ss
+	}
+	return nil
+}
+
+// Time returns a NumericDate claim, like "exp", and reports if it's present and numeric.
+func (c Claims) Time(name string) (time.Time, bool) {
+	n, ok := c[name].(json.Number)
+	if !ok {
+		return time.Time{}, false
+	}
+
+	f, err := n.Float64()
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	sec := int64(f)
+	return time.Unix(sec, int64((f-float64(sec))*float64(time.Second))), true
+}
+
+// Key is a public key verifying RS256 signatures. ID matches the "kid" header of the tokens it signed.
+type Key struct {
+	ID     string
+	Public *rsa.PublicKey
+}
+
+// Verifier verifies tokens. Safe for concurrent use.
+type Verifier struct {
+	secret   []byte
+	keys     []Key
+	issuer   string
+	audience string
+	leeway   time.Duration
+	now      func() time.Time
+}
+
+// Option configures a Verifier.
+type Option func(*Verifier)
+
+// WithSecret accepts HS256 tokens signed with secret.
+func WithSecret(secret []byte) Option {
+	return func(v *Verifier) {
+		v.secret = secret
+	}
+}
+
+// WithKeys accepts RS256 tokens signed by one of keys, see ParseJWKS.
+func WithKeys(keys []Key) Option {
+	return func(v *Verifier) {
+		v.keys = keys
+	}
+}
+
+// WithIssuer requires the "iss" claim to be iss.
+func WithIssuer(iss string) Option {
+	return func(v *Verifier) {
+		v.issuer = iss
+	}
+}
+
+// WithAudience requires the "aud" claim to hold aud.
+func WithAudience(aud string) Option {
+	return func(v *Verifier) {
+		v.audience = aud
+	}
+}
+
+// WithLeeway replaces DefaultLeeway.
+func WithLeeway(leeway time.Duration) Option {
+	return func(v *Verifier) {
+		v.leeway = leeway
+	}
+}
+
+// NewVerifier returns a verifier accepting the algorithms it has keys for: HS256 WithSecret, and RS256 WithKeys.
+func NewVerifier(opts ...Option) *Verifier {
+	v := &Verifier{
+		leeway: DefaultLeeway,
+		now:    time.Now,
+	}
+
+	for _, opt := range opts {
+		opt(v)
+	}
+
+	return v
+}
+
+type header struct {
+	Alg  string   `json:"alg"`
+	Kid  string   `json:"kid"`
+	Crit []string `json:"crit"`
+}
+
+// Verify verifies the signature of a token in compact serialization, and its "exp", "nbf", "iss" and "aud" claims.
+// Tokens without "exp" are refused, so a leaked token can't be used forever. The algorithm of the token must be one
+// the verifier has a key for, so an HS256 token can't be signed with a public RS256 key.
+func (v *Verifier) Verify(token string) (Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrMalformed
+	}
+
+	var h header
+	if err := decodeSegment(parts[0], &h); err != nil {
+		return nil, ErrMalformed
+	}
+
+	// No extensions are understood, so tokens requiring them are refused, see RFC 7515 section 4.1.11.
+	if h.Crit != nil {
+		return nil, ErrMalformed
+	}
+
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, ErrMalformed
+	}
+
+	if err := v.verifySignature(h, []byte(parts[0]+"."+parts[1]), sig); err != nil {
+		return nil, err
+	}
+
+	var claims Claims
+	if err := decodeSegment(parts[1], &claims); err != nil || claims == nil {
+		return nil, ErrMalformed
+	}
+
+	if err := v.verifyClaims(claims); err != nil {
+		return nil, err
+	}
+
+	return claims, nil
+}
+
+func (v *Verifier) verifySignature(h header, input, sig []byte) error {
+	switch h.Alg {
+	case HS256:
+		if len(v.secret) == 0 {
+			return ErrAlgorithm
+		}
+
+		mac := hmac.New(sha256.New, v.secret)
+		mac.Write(input)
+		if !hmac.Equal(mac.Sum(nil), sig) {
+			return ErrSignature
+		}
+		return nil
+	case RS256:
+		if len(v.keys) == 0 {
+			return ErrAlgorithm
+		}
+
+		sum := sha256.Sum256(input)
+		for _, k := range v.keys {
+			if h.Kid != "" && k.ID != h.Kid {
+				continue
+			}
+			if rsa.VerifyPKCS1v15(k.Public, crypto.SHA256, sum[:], sig) == nil {
+				return nil
+			}
+		}
+		return ErrSignature
+	default:
+		return ErrAlgorithm
+	}
+}
+
+func (v *Verifier) verifyClaims(c Claims) error {
+	now := v.now()
+
+	exp, ok := c.Time("exp")
+	if !ok {
+		return ErrNoExpiry
+	}
+	if !now.Before(exp.Add(v.leeway)) {
+		return ErrExpired
+	}
+
+	if nbf, ok := c.Time("nbf"); ok && now.Add(v.leeway).Before(nbf) {
+		return ErrNotYetValid
+	}
+
+	if v.issuer != "" && c.String("iss") != v.issuer {
+		return ErrIssuer
+	}
+
+	if v.audience != "" && !contains(c.Strings("aud"), v.audience) {
+		return ErrAudience
+	}
+
+	return nil
+}
+
+func contains(ss []string, s string) bool {
+	for _, e := range ss {
+		if e == s {
+			return true
+		}
+	}
+	return false
+}
+
+// decodeSegment decodes a base64url encoded JSON segment into v. Numbers are decoded as json.Number.
+func decodeSegment(segment string, v interface{}) error {
+	b, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	return dec.Decode(v)
+}
+
+type jwk struct {
+	Kty string `json:"kty"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+}
+
+// ParseJWKS returns the RS256 signing keys of a JSON Web Key Set. Keys for other algorithms or uses are left out.
+// Returns an error if no keys are left, or a key is invalid or shorter than 2048 bits.
+func ParseJWKS(data []byte) ([]Key, error) {
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, err
+	}
+
+	var keys []Key
+	for _, k := range set.Keys {
+		if k.Kty != "RSA" || (k.Use != "" && k.Use != "sig") || (k.Alg != "" && k.Alg != RS256) {
+			continue
+		}
+
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return nil, errors.New("invalid modulus of key " + k.Kid)
+		}
+
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil || len(e) == 0 || len(e) > 4 {
+			return nil, errors.New("invalid exponent of key " + k.Kid)
+		}
+
+		pub := &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+		if pub.N.BitLen() < minRSABits {
+			return nil, errors.New("key " + k.Kid + " is shorter than 2048 bits")
+		}
+
+		keys = append(keys, Key{ID: k.Kid, Public: pub})
+	}
+
+	if len(keys) == 0 {
+		return nil, errors.New("no RS256 signing keys")
+	}
+
+	return keys, nil
+}
+
+// LoadJWKS reads the RS256 signing keys of the JSON Web Key Set in a file, see ParseJWKS.
+func LoadJWKS(path string) ([]Key, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return ParseJWKS(data)
+}
@@ -0,0 +1,235 @@
+package jwt
+
+import (
+	"crypto"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+)
+
+func encodeSegment(v interface{}) string {
+	b, _ := json.Marshal(v)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func signHS256(secret string, h map[string]interface{}, claims map[string]interface{}) string {
+	input := encodeSegment(h) + "." + encodeSegment(claims)
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(input))
+
+	return input + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func signRS256(t *testing.T, key *rsa.PrivateKey, kid string, claims map[string]interface{}) string {
+	input := encodeSegment(map[string]interface{}{"alg": RS256, "kid": kid}) + "." + encodeSegment(claims)
+
+	sum := sha256.Sum256([]byte(input))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, sum[:])
+	if err != nil {
+		t.Fatalf("SignPKCS1v15: %v", err)
+	}
+
+	return input + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+func jwks(keys map[string]*rsa.PublicKey) []byte {
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+
+	for kid, k := range keys {
+		set.Keys = append(set.Keys, jwk{
+			Kty: "RSA",
+			Use: "sig",
+			Alg: RS256,
+			Kid: kid,
+			N:   base64.RawURLEncoding.EncodeToString(k.N.Bytes()),
+			E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(k.E)).Bytes()),
+		})
+	}
+
+	b, _ := json.Marshal(&set)
+	return b
+}
+
+// tamper replaces the claims of a token, keeping its signature.
+func tamper(token string, claims map[string]interface{}) string {
+	parts := strings.Split(token, ".")
+	return parts[0] + "." + encodeSegment(claims) + "." + parts[2]
+}
+
+func fixedNow(t time.Time) Option {
+	return func(v *Verifier) {
+		v.now = func() time.Time { return t }
+	}
+}
+
+func TestVerifier_Verify_RFC7515(t *testing.T) {
+	// Example A.1 of RFC 7515.
+	token := "eyJ0eXAiOiJKV1QiLA0KICJhbGciOiJIUzI1NiJ9" +
+		".eyJpc3MiOiJqb2UiLA0KICJleHAiOjEzMDA4MTkzODAsDQogImh0dHA6Ly9leGFtcGxlLmNvbS9pc19yb290Ijp0cnVlfQ" +
+		".dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+	secret, _ := base64.RawURLEncoding.DecodeString("AyM1SysPpbyDfgZld3umj1qzKObwVMkoqQ-EstJQLr_T-1qS0gZH75aKtMN3Yj0iPS4hcgUuTwjAzZr1Z9CAow")
+
+	v := NewVerifier(WithSecret(secret), WithIssuer("joe"), fixedNow(time.Unix(1300819380, 0).Add(-time.Hour)))
+
+	claims, err := v.Verify(token)
+	assert.NoError(t, err)
+	assert.Equal(t, "joe", claims.String("iss"))
+	assert.Equal(t, true, claims["http://example.com/is_root"])
+
+	v = NewVerifier(WithSecret(secret), fixedNow(time.Unix(1300819380, 0).Add(DefaultLeeway)))
+	_, err = v.Verify(token)
+	assert.Equal(t, ErrExpired, err)
+}
+
+func TestVerifier_Verify_HS256(t *testing.T) {
+	now := time.Date(2020, 5, 1, 17, 0, 0, 0, time.UTC)
+	hs := map[string]interface{}{"alg": HS256, "typ": "JWT"}
+	claims := func(extra map[string]interface{}) map[string]interface{} {
+		c := map[string]interface{}{"sub": "annie", "exp": now.Add(time.Hour).Unix()}
+		for k, v := range extra {
+			c[k] = v
+		}
+		return c
+	}
+
+	v := NewVerifier(WithSecret([]byte("secret")), WithIssuer("https://idp.example.com"), WithAudience("hooked"), fixedNow(now))
+	valid := map[string]interface{}{"iss": "https://idp.example.com", "aud": []string{"other", "hooked"}}
+
+	c, err := v.Verify(signHS256("secret", hs, claims(valid)))
+	assert.NoError(t, err)
+	assert.Equal(t, "annie", c.String("sub"))
+	assert.Equal(t, []string{"other", "hooked"}, c.Strings("aud"))
+
+	exp, ok := c.Time("exp")
+	assert.True(t, ok)
+	assert.True(t, exp.Equal(now.Add(time.Hour)))
+
+	tests := []struct {
+		name  string
+		token string
+		err   error
+	}{
+		{"wrong secret", signHS256("other", hs, claims(valid)), ErrSignature},
+		{"tampered", tamper(signHS256("secret", hs, claims(valid)), claims(map[string]interface{}{"iss": "https://idp.example.com", "aud": "hooked", "sub": "bob"})), ErrSignature},
+		{"no expiry", signHS256("secret", hs, map[string]interface{}{"iss": "https://idp.example.com", "aud": "hooked"}), ErrNoExpiry},
+		{"expired", signHS256("secret", hs, claims(map[string]interface{}{"iss": "https://idp.example.com", "aud": "hooked", "exp": now.Add(-2 * time.Minute).Unix()})), ErrExpired},
+		{"not yet valid", signHS256("secret", hs, claims(map[string]interface{}{"iss": "https://idp.example.com", "aud": "hooked", "nbf": now.Add(2 * time.Minute).Unix()})), ErrNotYetValid},
+		{"wrong issuer", signHS256("secret", hs, claims(map[string]interface{}{"iss": "https://evil.example.com", "aud": "hooked"})), ErrIssuer},
+		{"wrong audience", signHS256("secret", hs, claims(map[string]interface{}{"iss": "https://idp.example.com", "aud": "other"})), ErrAudience},
+		{"none", signHS256("secret", map[string]interface{}{"alg": "none"}, claims(valid)), ErrAlgorithm},
+		{"RS256 without keys", signHS256("secret", map[string]interface{}{"alg": RS256}, claims(valid)), ErrAlgorithm},
+		{"critical extension", signHS256("secret", map[string]interface{}{"alg": HS256, "crit": []string{"exp"}}, claims(valid)), ErrMalformed},
+		{"two segments", "a.b", ErrMalformed},
+		{"bad header", "!." + encodeSegment(claims(valid)) + ".c2ln", ErrMalformed},
+		{"bad signature encoding", encodeSegment(hs) + "." + encodeSegment(claims(valid)) + ".!", ErrMalformed},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := v.Verify(test.token)
+			assert.Equal(t, test.err, err)
+		})
+	}
+
+	t.Run("leeway", func(t *testing.T) {
+		_, err := v.Verify(signHS256("secret", hs, claims(map[string]interface{}{"iss": "https://idp.example.com", "aud": "hooked", "exp": now.Add(-30 * time.Second).Unix()})))
+		assert.NoError(t, err)
+	})
+}
+
+func TestVerifier_Verify_RS256(t *testing.T) {
+	first, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+	second, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+
+	keys, err := ParseJWKS(jwks(map[string]*rsa.PublicKey{"first": &first.PublicKey, "second": &second.PublicKey}))
+	assert.NoError(t, err)
+	assert.Len(t, keys, 2)
+
+	now := time.Date(2020, 5, 1, 17, 0, 0, 0, time.UTC)
+	claims := map[string]interface{}{"sub": "annie", "exp": now.Add(time.Hour).Unix()}
+
+	v := NewVerifier(WithKeys(keys), fixedNow(now))
+
+	c, err := v.Verify(signRS256(t, first, "first", claims))
+	assert.NoError(t, err)
+	assert.Equal(t, "annie", c.String("sub"))
+
+	_, err = v.Verify(signRS256(t, second, "second", claims))
+	assert.NoError(t, err)
+
+	_, err = v.Verify(signRS256(t, second, "", claims))
+	assert.NoError(t, err, "tokens without kid should be tried with every key")
+
+	_, err = v.Verify(signRS256(t, second, "first", claims))
+	assert.Equal(t, ErrSignature, err, "the key named by kid should be used")
+
+	_, err = v.Verify(signRS256(t, first, "unknown", claims))
+	assert.Equal(t, ErrSignature, err)
+
+	// An HS256 token signed with the public key must not pass as the verifier has no secret.
+	public := first.PublicKey.N.Bytes()
+	_, err = v.Verify(signHS256(string(public), map[string]interface{}{"alg": HS256, "kid": "first"}, claims))
+	assert.Equal(t, ErrAlgorithm, err)
+}
+
+func TestParseJWKS(t *testing.T) {
+	small, err := rsa.GenerateKey(rand.Reader, 1024)
+	assert.NoError(t, err)
+
+	_, err = ParseJWKS(jwks(map[string]*rsa.PublicKey{"small": &small.PublicKey}))
+	assert.Error(t, err, "keys shorter than 2048 bits should be refused")
+
+	tests := []struct {
+		name string
+		set  string
+	}{
+		{"not json", `keys`},
+		{"no keys", `{"keys":[]}`},
+		{"only other key types", `{"keys":[{"kty":"EC","crv":"P-256","x":"eA","y":"eQ"}]}`},
+		{"only encryption keys", `{"keys":[{"kty":"RSA","use":"enc","n":"AQAB","e":"AQAB"}]}`},
+		{"bad exponent", `{"keys":[{"kty":"RSA","n":"AQAB","e":"!"}]}`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := ParseJWKS([]byte(test.set))
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestClaims(t *testing.T) {
+	c := Claims{
+		"name":   "Annie",
+		"roles":  []interface{}{"admin", 1, "scorer"},
+		"number": json.Number("1588352400.5"),
+		"text":   "1588352400",
+	}
+
+	assert.Equal(t, "Annie", c.String("name"))
+	assert.Equal(t, "", c.String("roles"))
+	assert.Equal(t, []string{"Annie"}, c.Strings("name"))
+	assert.Equal(t, []string{"admin", "scorer"}, c.Strings("roles"))
+	assert.Nil(t, c.Strings("missing"))
+
+	tm, ok := c.Time("number")
+	assert.True(t, ok)
+	assert.Equal(t, fmt.Sprint(time.Unix(1588352400, int64(time.Second/2))), fmt.Sprint(tm))
+
+	_, ok = c.Time("text")
+	assert.False(t, ok)
+}
@@ -0,0 +1,42 @@
+package lambdahandler
+
+import (
+	"context"
+	"github.com/aws/aws-lambda-go/events"
+)
+
+type requestContextKey struct{}
+
+// NewContext returns a copy of ctx holding the API Gateway request context of a request.
+func NewContext(ctx context.Context, rc events.APIGatewayProxyRequestContext) context.Context {
+	return context.WithValue(ctx, requestContextKey{}, rc)
+}
+
+// RequestContext gets the API Gateway request context of a request from context. Not found when the request wasn't
+// received through Handler, eg. under cmd/server.
+func RequestContext(ctx context.Context) (events.APIGatewayProxyRequestContext, bool) {
+	rc, ok := ctx.Value(requestContextKey{}).(events.APIGatewayProxyRequestContext)
+	return rc, ok
+}
+
+// Identity gets the identity of the caller of a request from context, eg. its source IP and Cognito identity.
+func Identity(ctx context.Context) (events.APIGatewayRequestIdentity, bool) {
+	rc, ok := RequestContext(ctx)
+	return rc.Identity, ok
+}
+
+// AuthorizerClaims gets the claims of the API Gateway authorizer of a request from context. For a Cognito user pool
+// authorizer these are the claims of its token, for a custom authorizer its context and principalId. Not found if the
+// route has no authorizer.
+func AuthorizerClaims(ctx context.Context) (map[string]interface{}, bool) {
+	rc, ok := RequestContext(ctx)
+	if !ok || len(rc.Authorizer) == 0 {
+		return nil, false
+	}
+
+	if claims, ok := rc.Authorizer["claims"].(map[string]interface{}); ok {
+		return claims, true
+	}
+
+	return rc.Authorizer, true
+}
@@ -4,9 +4,11 @@ import (
 	"bytes"
 	"context"
 	"github.com/aws/aws-lambda-go/events"
+	"github.com/rejlersembriq/hooked/pkg/auth"
 	"io"
 	"io/ioutil"
 	"net/http"
+	"net/url"
 )
 
 // Handler is the entry point for a lambda and wraps the APIGateway events so http.Handler funcs can be called.
@@ -23,13 +25,45 @@ func (h Handler) Handle(ctx context.Context, req events.APIGatewayProxyRequest)
 	}
 
 	// If any request scoped variables that doesnt fit in the http.Request are needed, add them to the context.
-	newCtx, cancel := context.WithCancel(ctx)
+	newCtx, cancel := context.WithCancel(NewContext(ctx, req.RequestContext))
 	defer cancel()
 
+	// The authorizer has verified its claims before invoking the lambda, so they're trusted like a verified token.
+	if claims, ok := AuthorizerClaims(newCtx); ok {
+		newCtx = auth.NewClaimsContext(newCtx, claims)
+	}
+
 	httpReq, err := http.NewRequestWithContext(newCtx, req.HTTPMethod, req.Path, bytes.NewBufferString(req.Body))
 	if err != nil {
 		return events.APIGatewayProxyResponse{}, err
 	}
+	httpReq.RemoteAddr = req.RequestContext.Identity.SourceIP
+
+	// API Gateway fills MultiValueHeaders with every header, Headers only holds the last value of each.
+	for k, vs := range req.MultiValueHeaders {
+		for _, v := range vs {
+			httpReq.Header.Add(k, v)
+		}
+	}
+	if len(req.MultiValueHeaders) == 0 {
+		for k, v := range req.Headers {
+			httpReq.Header.Set(k, v)
+		}
+	}
+
+	// API Gateway passes the query decoded, and like the headers fills MultiValueQueryStringParameters with every value.
+	query := make(url.Values)
+	for k, vs := range req.MultiValueQueryStringParameters {
+		for _, v := range vs {
+			query.Add(k, v)
+		}
+	}
+	if len(req.MultiValueQueryStringParameters) == 0 {
+		for k, v := range req.QueryStringParameters {
+			query.Set(k, v)
+		}
+	}
+	httpReq.URL.RawQuery = query.Encode()
 
 	h.Handler.ServeHTTP(&httpRes, httpReq)
 
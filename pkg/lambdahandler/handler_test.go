@@ -0,0 +1,164 @@
+package lambdahandler
+
+import (
+	"context"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/rejlersembriq/hooked/pkg/auth"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"testing"
+)
+
+func TestHandler_Handle(t *testing.T) {
+	var got *http.Request
+	h := Handler{Handler: http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		got = req
+		res.Header().Set("ETag", `"1"`)
+		res.WriteHeader(http.StatusPreconditionFailed)
+		res.Write([]byte("Modified"))
+	})}
+
+	res, err := h.Handle(context.Background(), events.APIGatewayProxyRequest{
+		HTTPMethod:        http.MethodPut,
+		Path:              "/participant/1",
+		MultiValueHeaders: map[string][]string{"If-Match": {`"2"`}, "Accept": {"text/plain", "application/json"}},
+		Body:              "{}",
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusPreconditionFailed, res.StatusCode)
+	assert.Equal(t, "Modified", res.Body)
+	assert.Equal(t, []string{`"1"`}, res.MultiValueHeaders["Etag"])
+
+	assert.Equal(t, http.MethodPut, got.Method)
+	assert.Equal(t, `"2"`, got.Header.Get("If-Match"))
+	assert.Equal(t, []string{"text/plain", "application/json"}, got.Header["Accept"])
+}
+
+func TestHandler_Handle_SingleValueHeaders(t *testing.T) {
+	var got *http.Request
+	h := Handler{Handler: http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		got = req
+	})}
+
+	_, err := h.Handle(context.Background(), events.APIGatewayProxyRequest{
+		HTTPMethod: http.MethodGet,
+		Path:       "/participant/1",
+		Headers:    map[string]string{"If-None-Match": `"1"`},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, `"1"`, got.Header.Get("If-None-Match"))
+}
+
+func TestHandler_Handle_Query(t *testing.T) {
+	var got *http.Request
+	h := Handler{Handler: http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		got = req
+	})}
+
+	_, err := h.Handle(context.Background(), events.APIGatewayProxyRequest{
+		HTTPMethod:                      http.MethodGet,
+		Path:                            "/participants",
+		MultiValueQueryStringParameters: map[string][]string{"limit": {"10"}, "org": {"Org A&B"}, "sort": {"-score", "name"}},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "/participants", got.URL.Path)
+	assert.Equal(t, "10", got.URL.Query().Get("limit"))
+	assert.Equal(t, "Org A&B", got.URL.Query().Get("org"))
+	assert.Equal(t, []string{"-score", "name"}, got.URL.Query()["sort"])
+
+	_, err = h.Handle(context.Background(), events.APIGatewayProxyRequest{
+		HTTPMethod:            http.MethodGet,
+		Path:                  "/leaderboard",
+		QueryStringParameters: map[string]string{"ties": "earliest"},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "earliest", got.URL.Query().Get("ties"))
+}
+
+func TestHandler_Handle_RequestContext(t *testing.T) {
+	var got *http.Request
+	h := Handler{Handler: http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		got = req
+	})}
+
+	_, err := h.Handle(context.Background(), events.APIGatewayProxyRequest{
+		HTTPMethod: http.MethodGet,
+		Path:       "/participants",
+		RequestContext: events.APIGatewayProxyRequestContext{
+			RequestID: "request",
+			Identity:  events.APIGatewayRequestIdentity{SourceIP: "192.0.2.1"},
+		},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "192.0.2.1", got.RemoteAddr)
+
+	rc, ok := RequestContext(got.Context())
+	assert.True(t, ok)
+	assert.Equal(t, "request", rc.RequestID)
+
+	identity, ok := Identity(got.Context())
+	assert.True(t, ok)
+	assert.Equal(t, "192.0.2.1", identity.SourceIP)
+
+	_, ok = AuthorizerClaims(got.Context())
+	assert.False(t, ok, "routes without an authorizer should have no claims")
+
+	_, ok = auth.ClaimsFromContext(got.Context())
+	assert.False(t, ok)
+}
+
+func TestHandler_Handle_Authorizer(t *testing.T) {
+	tests := []struct {
+		name       string
+		authorizer map[string]interface{}
+		claims     map[string]interface{}
+	}{
+		{
+			name: "cognito",
+			authorizer: map[string]interface{}{
+				"claims": map[string]interface{}{"sub": "annie", "cognito:groups": "staff"},
+			},
+			claims: map[string]interface{}{"sub": "annie", "cognito:groups": "staff"},
+		},
+		{
+			name:       "custom",
+			authorizer: map[string]interface{}{"principalId": "annie", "roles": "scorer"},
+			claims:     map[string]interface{}{"principalId": "annie", "roles": "scorer"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var got *http.Request
+			h := Handler{Handler: http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+				got = req
+			})}
+
+			_, err := h.Handle(context.Background(), events.APIGatewayProxyRequest{
+				HTTPMethod:     http.MethodGet,
+				Path:           "/participants",
+				RequestContext: events.APIGatewayProxyRequestContext{Authorizer: test.authorizer},
+			})
+			assert.NoError(t, err)
+
+			claims, ok := AuthorizerClaims(got.Context())
+			assert.True(t, ok)
+			assert.Equal(t, test.claims, claims)
+
+			claims, ok = auth.ClaimsFromContext(got.Context())
+			assert.True(t, ok)
+			assert.Equal(t, test.claims, claims)
+		})
+	}
+}
+
+func TestRequestContext_NotFound(t *testing.T) {
+	_, ok := RequestContext(context.Background())
+	assert.False(t, ok)
+
+	_, ok = Identity(context.Background())
+	assert.False(t, ok)
+
+	_, ok = AuthorizerClaims(context.Background())
+	assert.False(t, ok)
+}
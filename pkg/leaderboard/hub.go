@@ -0,0 +1,148 @@
+package leaderboard
+
+import (
+	"github.com/rejlersembriq/hooked/pkg/participant"
+	"sync"
+	"time"
+)
+
+// Topic selects the participants of a leaderboard. Nil fields are ignored, so the zero Topic is the leaderboard of all
+// participants.
+type Topic struct {
+	Event *string `json:"event,omitempty"`
+	Org   *string `json:"org,omitempty"`
+}
+
+// Match reports if p is on the leaderboard of the topic.
+func (t Topic) Match(p *participant.Participant) bool {
+	return participant.Query{Event: t.Event, Org: t.Org}.Match(p)
+}
+
+// topicKey identifies a topic in the hub. Topics with equal fields have equal keys.
+type topicKey struct {
+	event, org       string
+	hasEvent, hasOrg bool
+}
+
+func (t Topic) key() topicKey {
+	var k topicKey
+	if t.Event != nil {
+		k.event, k.hasEvent = *t.Event, true
+	}
+	if t.Org != nil {
+		k.org, k.hasOrg = *t.Org, true
+	}
+	return k
+}
+
+// Hub keeps a Stream for every topic with subscribers, fed by participant changes. A topic's stream is created on the
+// first subscription and removed with the last. Update ids are shared by all topics, so an id from a removed stream is
+// never mistaken for one from its replacement, and start from the time the hub was created, so neither is an id from
+// before a restart. Safe for concurrent use.
+type Hub struct {
+	mu           sync.Mutex
+	tie          TieBreak
+	historySize  int
+	seq          uint64
+	participants map[string]*participant.Participant
+	topics       map[topicKey]*hubTopic
+}
+
+type hubTopic struct {
+	topic       Topic
+	stream      *Stream
+	subscribers int
+}
+
+// NewHub returns a hub ranking ps by tie. Every topic keeps its last historySize updates, see Stream.
+func NewHub(ps []*participant.Participant, tie TieBreak, historySize int) *Hub {
+	return newHub(ps, tie, historySize, firstID(time.Now()))
+}
+
+// firstID returns the id preceding the first update of a hub created at t, in microseconds. Microseconds stay below
+// 2^53, so ids are exact as JavaScript numbers.
+func firstID(t time.Time) uint64 {
+	return uint64(t.UnixNano() / int64(time.Microsecond))
+}
+
+func newHub(ps []*participant.Participant, tie TieBreak, historySize int, start uint64) *Hub {
+	h := &Hub{
+		seq:          start,
+		tie:          tie,
+		historySize:  historySize,
+		participants: make(map[string]*participant.Participant, len(ps)),
+		topics:       make(map[topicKey]*hubTopic),
+	}
+
+	for _, p := range ps {
+		if p != nil && p.ID != nil {
+			cp := p.Clone()
+			h.participants[*p.ID] = &cp
+		}
+	}
+
+	return h
+}
+
+// Apply updates every topic with a change, see participant.ObservedRepository.OnChange. Participants not matching a
+// topic are removed from its leaderboard, eg. when they change organisation.
+func (h *Hub) Apply(c participant.Change) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if c.Op == participant.ChangeDeleted || c.Participant == nil {
+		delete(h.participants, c.ID)
+	} else {
+		cp := c.Participant.Clone()
+		h.participants[c.ID] = &cp
+	}
+
+	h.seq++
+	for _, t := range h.topics {
+		t.stream.mu.Lock()
+		if c.Participant != nil && t.topic.Match(c.Participant) {
+			t.stream.apply(c, h.seq)
+		} else {
+			t.stream.apply(participant.Change{Op: participant.ChangeDeleted, ID: c.ID}, h.seq)
+		}
+		t.stream.mu.Unlock()
+	}
+}
+
+// Subscribe subscribes to the leaderboard of a topic, see Stream.Subscribe.
+func (h *Hub) Subscribe(topic Topic, lastID string) (backlog []Update, updates <-chan Update, cancel func()) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	key := topic.key()
+
+	t, exists := h.topics[key]
+	if !exists {
+		ps := make([]*participant.Participant, 0)
+		for _, p := range h.participants {
+			if topic.Match(p) {
+				ps = append(ps, p)
+			}
+		}
+
+		t = &hubTopic{topic: topic, stream: newStream(ps, h.tie, h.historySize, h.seq)}
+		h.topics[key] = t
+	}
+	t.subscribers++
+
+	backlog, updates, cancelStream := t.stream.Subscribe(lastID)
+
+	var once sync.Once
+	return backlog, updates, func() {
+		once.Do(func() {
+			cancelStream()
+
+			h.mu.Lock()
+			defer h.mu.Unlock()
+
+			if t.subscribers--; t.subscribers == 0 {
+				delete(h.topics, key)
+			}
+		})
+	}
+}
@@ -0,0 +1,114 @@
+package leaderboard
+
+import (
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/rejlersembriq/hooked/pkg/participant"
+	"github.com/stretchr/testify/assert"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func savedOrg(id, org string, score int) participant.Change {
+	c := saved(id, score)
+	c.Participant.Org = aws.String(org)
+	return c
+}
+
+func TestHub_Subscribe_Topic(t *testing.T) {
+	ps := []*participant.Participant{
+		savedOrg("a", "acme", 10).Participant,
+		savedOrg("b", "initech", 20).Participant,
+		savedOrg("c", "acme", 30).Participant,
+	}
+	h := NewHub(ps, TieShared, 10)
+
+	backlog, _, cancel := h.Subscribe(Topic{Org: aws.String("acme")}, "")
+	defer cancel()
+	if assert.Len(t, backlog, 1) {
+		assert.Equal(t, []string{"c", "a"}, ids(backlog[0].Entries))
+	}
+
+	backlog, _, cancelAll := h.Subscribe(Topic{}, "")
+	defer cancelAll()
+	if assert.Len(t, backlog, 1) {
+		assert.Equal(t, []string{"c", "b", "a"}, ids(backlog[0].Entries))
+	}
+}
+
+func TestHub_Apply(t *testing.T) {
+	h := NewHub(nil, TieShared, 10)
+	start := h.seq
+
+	_, acme, cancel := h.Subscribe(Topic{Org: aws.String("acme")}, "")
+	defer cancel()
+
+	// Changes to other topics aren't sent, but still number the updates.
+	h.Apply(savedOrg("b", "initech", 20))
+	h.Apply(savedOrg("a", "acme", 10))
+
+	u := <-acme
+	assert.Equal(t, start+2, u.ID)
+	assert.Equal(t, []string{"a"}, ids(u.Entries))
+
+	// Moving to another organisation removes the participant from the topic.
+	h.Apply(savedOrg("a", "initech", 10))
+
+	u = <-acme
+	assert.Equal(t, start+3, u.ID)
+	assert.Empty(t, u.Entries)
+	assert.Equal(t, []string{"a"}, u.Removed)
+
+	select {
+	case u := <-acme:
+		t.Errorf("Unexpected update %+v", u)
+	default:
+	}
+}
+
+func TestNewHub(t *testing.T) {
+	// Ids from an earlier hub, eg. before a restart, are older than the first id of a new one as long as the earlier
+	// hub applied fewer updates than microseconds passed.
+	created := time.Date(2020, 5, 1, 17, 0, 0, 0, time.UTC)
+	old := newHub(nil, TieShared, 10, firstID(created))
+	for i := 0; i < 100; i++ {
+		old.Apply(saved("a", i))
+	}
+
+	restarted := newHub(nil, TieShared, 10, firstID(created.Add(time.Millisecond)))
+	assert.True(t, restarted.seq > old.seq)
+
+	assert.True(t, NewHub(nil, TieShared, 10).seq >= firstID(created))
+}
+
+func TestHub_Subscribe_Removed(t *testing.T) {
+	h := NewHub(nil, TieShared, 10)
+	start := h.seq
+	topic := Topic{Org: aws.String("acme")}
+
+	_, _, cancel := h.Subscribe(topic, "")
+	h.Apply(savedOrg("a", "acme", 10))
+	cancel()
+	cancel()
+	assert.Empty(t, h.topics)
+
+	// The topic is created again after the changes, so an id from before can't be resumed from.
+	h.Apply(savedOrg("b", "acme", 20))
+	backlog, _, cancel := h.Subscribe(topic, strconv.FormatUint(start+1, 10))
+	defer cancel()
+
+	if assert.Len(t, backlog, 1) {
+		assert.Equal(t, UpdateSnapshot, backlog[0].Type)
+		assert.Equal(t, start+2, backlog[0].ID)
+		assert.Equal(t, []string{"b", "a"}, ids(backlog[0].Entries))
+	}
+
+	h.Apply(savedOrg("a", "acme", 30))
+	backlog, _, cancelResumed := h.Subscribe(topic, strconv.FormatUint(start+2, 10))
+	defer cancelResumed()
+
+	if assert.Len(t, backlog, 1) {
+		assert.Equal(t, UpdateRank, backlog[0].Type)
+		assert.Equal(t, start+3, backlog[0].ID)
+	}
+}
@@ -0,0 +1,125 @@
+package leaderboard
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/rejlersembriq/hooked/pkg/participant"
+	"sort"
+)
+
+// TieBreak decides how participants with equal score are ranked.
+type TieBreak string
+
+const (
+	// TieShared gives participants with equal score the same rank. The following rank is skipped accordingly (1, 1, 3).
+	TieShared TieBreak = "shared"
+	// TieEarliest ranks the participant created first highest when scores are equal. Every rank is unique.
+	TieEarliest TieBreak = "earliest"
+)
+
+// ErrInvalidTieBreak is returned when parsing an unknown tie break mode.
+var ErrInvalidTieBreak = errors.New("invalid tie break")
+
+// ParseTieBreak returns the TieBreak matching s. An empty string gives TieShared.
+func ParseTieBreak(s string) (TieBreak, error) {
+	switch TieBreak(s) {
+	case "", TieShared:
+		return TieShared, nil
+	case TieEarliest:
+		return TieEarliest, nil
+	default:
+		return "", ErrInvalidTieBreak
+	}
+}
+
+// Entry is a participant with its position on the leaderboard. Leaderboards are public, so an entry is marshalled
+// as a PublicEntry, leaving out the contact details, comment and consent of the participant.
+type Entry struct {
+	Rank int `json:"rank"`
+	*participant.Participant
+}
+
+// PublicEntry is the part of an entry shown on leaderboards.
+type PublicEntry struct {
+	Rank  int     `json:"rank"`
+	ID    *string `json:"id,omitempty"`
+	Name  *string `json:"name,omitempty"`
+	Org   *string `json:"org,omitempty"`
+	Score *int    `json:"score"`
+	Event *string `json:"event,omitempty"`
+}
+
+// Public returns the part of e shown on leaderboards.
+func (e Entry) Public() PublicEntry {
+	pe := PublicEntry{Rank: e.Rank}
+	if e.Participant != nil {
+		pe.ID = e.ID
+		pe.Name = e.Name
+		pe.Org = e.Org
+		pe.Score = e.Score
+		pe.Event = e.Event
+	}
+	return pe
+}
+
+// MarshalJSON marshals the public part of e, see Public.
+func (e Entry) MarshalJSON() ([]byte, error) {
+	return json.Marshal(e.Public())
+}
+
+// Rank orders the participants by score, highest first, and assigns ranks according to tie.
+// Participants without a score are left out. If limit is larger than 0 at most limit entries are returned.
+func Rank(ps []*participant.Participant, tie TieBreak, limit int) []Entry {
+	scored := make([]*participant.Participant, 0, len(ps))
+	for _, p := range ps {
+		if p != nil && p.Score != nil {
+			scored = append(scored, p)
+		}
+	}
+
+	sort.SliceStable(scored, func(i, j int) bool {
+		return less(scored[i], scored[j])
+	})
+
+	if limit > 0 && limit < len(scored) {
+		scored = scored[:limit]
+	}
+
+	entries := make([]Entry, len(scored))
+	for i, p := range scored {
+		rank := i + 1
+		if tie == TieShared && i > 0 && *p.Score == *scored[i-1].Score {
+			rank = entries[i-1].Rank
+		}
+
+		entries[i] = Entry{
+			Rank:        rank,
+			Participant: p,
+		}
+	}
+
+	return entries
+}
+
+// less reports whether a should be placed before b. Higher score first, then earliest created, then id to keep
+// the order deterministic.
+func less(a, b *participant.Participant) bool {
+	if *a.Score != *b.Score {
+		return *a.Score > *b.Score
+	}
+
+	switch {
+	case a.Created != nil && b.Created != nil && !a.Created.Equal(*b.Created):
+		return a.Created.Before(*b.Created)
+	case a.Created != nil && b.Created == nil:
+		return true
+	case a.Created == nil && b.Created != nil:
+		return false
+	}
+
+	if a.ID != nil && b.ID != nil {
+		return *a.ID < *b.ID
+	}
+
+	return false
+}
@@ -0,0 +1,107 @@
+package leaderboard
+
+import (
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/rejlersembriq/hooked/pkg/participant"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func testParticipants() []*participant.Participant {
+	base := time.Date(2019, 10, 1, 12, 0, 0, 0, time.UTC)
+	created := func(minutes int) *time.Time {
+		t := base.Add(time.Duration(minutes) * time.Minute)
+		return &t
+	}
+
+	return []*participant.Participant{
+		{ID: aws.String("a"), Score: aws.Int(10), Created: created(3)},
+		{ID: aws.String("b"), Score: aws.Int(30), Created: created(2)},
+		{ID: aws.String("c"), Score: aws.Int(10), Created: created(1)},
+		{ID: aws.String("d")},
+		{ID: aws.String("e"), Score: aws.Int(5), Created: created(0)},
+		{ID: aws.String("f"), Score: aws.Int(30), Created: created(4)},
+	}
+}
+
+func ids(entries []Entry) []string {
+	var res []string
+	for _, e := range entries {
+		res = append(res, *e.ID)
+	}
+	return res
+}
+
+func ranks(entries []Entry) []int {
+	var res []int
+	for _, e := range entries {
+		res = append(res, e.Rank)
+	}
+	return res
+}
+
+var rankTests = []struct {
+	name          string
+	tie           TieBreak
+	limit         int
+	expectedIDs   []string
+	expectedRanks []int
+}{
+	{
+		name:          "shared",
+		tie:           TieShared,
+		expectedIDs:   []string{"b", "f", "c", "a", "e"},
+		expectedRanks: []int{1, 1, 3, 3, 5},
+	},
+	{
+		name:          "earliest",
+		tie:           TieEarliest,
+		expectedIDs:   []string{"b", "f", "c", "a", "e"},
+		expectedRanks: []int{1, 2, 3, 4, 5},
+	},
+	{
+		name:          "shared with limit",
+		tie:           TieShared,
+		limit:         3,
+		expectedIDs:   []string{"b", "f", "c"},
+		expectedRanks: []int{1, 1, 3},
+	},
+	{
+		name:          "limit larger than list",
+		tie:           TieEarliest,
+		limit:         100,
+		expectedIDs:   []string{"b", "f", "c", "a", "e"},
+		expectedRanks: []int{1, 2, 3, 4, 5},
+	},
+}
+
+func TestRank(t *testing.T) {
+	for _, test := range rankTests {
+		t.Run(test.name, func(t *testing.T) {
+			entries := Rank(testParticipants(), test.tie, test.limit)
+
+			assert.Equal(t, test.expectedIDs, ids(entries))
+			assert.Equal(t, test.expectedRanks, ranks(entries))
+		})
+	}
+}
+
+func TestRank_Empty(t *testing.T) {
+	entries := Rank(nil, TieShared, 0)
+	assert.NotNil(t, entries)
+	assert.Len(t, entries, 0)
+}
+
+func TestParseTieBreak(t *testing.T) {
+	tie, err := ParseTieBreak("")
+	assert.NoError(t, err)
+	assert.Equal(t, TieShared, tie)
+
+	tie, err = ParseTieBreak("earliest")
+	assert.NoError(t, err)
+	assert.Equal(t, TieEarliest, tie)
+
+	_, err = ParseTieBreak("invalid")
+	assert.Equal(t, ErrInvalidTieBreak, err)
+}
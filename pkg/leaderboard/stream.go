@@ -0,0 +1,189 @@
+package leaderboard
+
+import (
+	"github.com/rejlersembriq/hooked/pkg/participant"
+	"sort"
+	"strconv"
+	"sync"
+)
+
+// Kinds of updates sent by a Stream.
+const (
+	// UpdateSnapshot holds the whole leaderboard.
+	UpdateSnapshot = "snapshot"
+	// UpdateRank holds the entries added or changed, and the ids of participants no longer on the leaderboard.
+	UpdateRank = "rank"
+)
+
+// subscriberBuffer is the number of updates a subscriber can fall behind before it's dropped.
+const subscriberBuffer = 32
+
+// Update is a change to the leaderboard. Rank updates are numbered in increasing order, and a snapshot has the id of
+// the last rank update it includes.
+type Update struct {
+	ID      uint64   `json:"-"`
+	Type    string   `json:"-"`
+	Entries []Entry  `json:"entries"`
+	Removed []string `json:"removed,omitempty"`
+}
+
+// Stream keeps a leaderboard up to date from participant changes, and sends the rank changes to subscribers. The last
+// updates are kept, so a subscriber reconnecting shortly after losing its connection gets the ones it missed. Safe for
+// concurrent use.
+type Stream struct {
+	mu           sync.Mutex
+	tie          TieBreak
+	participants map[string]*participant.Participant
+	entries      map[string]Entry
+	seq          uint64
+	// floor is the oldest id updates can be resumed from: the id the stream started at, or of the last update dropped
+	// from history.
+	floor       uint64
+	history     []Update
+	historySize int
+	subscribers map[chan Update]struct{}
+}
+
+// NewStream returns a stream ranking ps by tie, keeping the last historySize updates.
+func NewStream(ps []*participant.Participant, tie TieBreak, historySize int) *Stream {
+	return newStream(ps, tie, historySize, 0)
+}
+
+// newStream returns a stream numbering updates after seq.
+func newStream(ps []*participant.Participant, tie TieBreak, historySize int, seq uint64) *Stream {
+	s := &Stream{
+		seq:          seq,
+		floor:        seq,
+		tie:          tie,
+		participants: make(map[string]*participant.Participant, len(ps)),
+		entries:      make(map[string]Entry),
+		historySize:  historySize,
+		subscribers:  make(map[chan Update]struct{}),
+	}
+
+	for _, p := range ps {
+		if p != nil && p.ID != nil {
+			cp := p.Clone()
+			s.participants[*p.ID] = &cp
+		}
+	}
+
+	for _, e := range s.rank() {
+		s.entries[*e.ID] = e
+	}
+
+	return s
+}
+
+// Apply updates the leaderboard with a change, see participant.ObservedRepository.OnChange. Changes older than the
+// version of the participant held are ignored. Subscribers are sent a rank update if any entry changed. Subscribers
+// too far behind to receive it are dropped, closing their channel.
+func (s *Stream) Apply(c participant.Change) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.apply(c, s.seq+1)
+}
+
+// apply updates the leaderboard, numbering the update id if sent. Ids must increase.
+func (s *Stream) apply(c participant.Change, id uint64) {
+	// Concurrent saves may be notified out of order, so a change older than the participant held is dropped.
+	if held, exists := s.participants[c.ID]; exists && c.Op != participant.ChangeDeleted && c.Participant != nil &&
+		c.Participant.CurrentVersion() < held.CurrentVersion() {
+		return
+	}
+
+	if c.Op == participant.ChangeDeleted || c.Participant == nil {
+		delete(s.participants, c.ID)
+	} else {
+		cp := c.Participant.Clone()
+		s.participants[c.ID] = &cp
+	}
+
+	ranked := s.rank()
+	entries := make(map[string]Entry, len(ranked))
+	update := Update{Type: UpdateRank, Entries: make([]Entry, 0)}
+
+	for _, e := range ranked {
+		entries[*e.ID] = e
+
+		// Other fields of the changed participant, eg. the name, are shown on the leaderboard too.
+		if old, exists := s.entries[*e.ID]; !exists || old.Rank != e.Rank || *old.Score != *e.Score || *e.ID == c.ID {
+			update.Entries = append(update.Entries, e)
+		}
+	}
+
+	for id := range s.entries {
+		if _, exists := entries[id]; !exists {
+			update.Removed = append(update.Removed, id)
+		}
+	}
+
+	s.entries = entries
+
+	if len(update.Entries) == 0 && len(update.Removed) == 0 {
+		return
+	}
+
+	s.seq = id
+	update.ID = id
+
+	s.history = append(s.history, update)
+	if drop := len(s.history) - s.historySize; drop > 0 {
+		s.floor = s.history[drop-1].ID
+		s.history = s.history[drop:]
+	}
+
+	for ch := range s.subscribers {
+		select {
+		case ch <- update:
+		default:
+			delete(s.subscribers, ch)
+			close(ch)
+		}
+	}
+}
+
+// Subscribe returns the updates since lastID, followed by new updates on the channel until cancel is called. If
+// lastID is empty, invalid or too old, the updates start with a snapshot instead. The channel is closed if the
+// subscriber falls behind, and it should subscribe again with the id of the last update received.
+func (s *Stream) Subscribe(lastID string) (backlog []Update, updates <-chan Update, cancel func()) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	backlog = s.since(lastID)
+
+	ch := make(chan Update, subscriberBuffer)
+	s.subscribers[ch] = struct{}{}
+
+	return backlog, ch, func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		if _, exists := s.subscribers[ch]; exists {
+			delete(s.subscribers, ch)
+			close(ch)
+		}
+	}
+}
+
+// since returns the updates after lastID, or a snapshot if they aren't all kept.
+func (s *Stream) since(lastID string) []Update {
+	if id, err := strconv.ParseUint(lastID, 10, 64); err == nil && id >= s.floor && id <= s.seq {
+		i := sort.Search(len(s.history), func(i int) bool { return s.history[i].ID > id })
+		return append([]Update(nil), s.history[i:]...)
+	}
+
+	return []Update{{ID: s.seq, Type: UpdateSnapshot, Entries: s.rank()}}
+}
+
+// rank returns the current leaderboard. The entries hold copies of the participants.
+func (s *Stream) rank() []Entry {
+	ps := make([]*participant.Participant, 0, len(s.participants))
+	for _, p := range s.participants {
+		cp := p.Clone()
+		ps = append(ps, &cp)
+	}
+
+	return Rank(ps, s.tie, 0)
+}
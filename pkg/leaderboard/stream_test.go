@@ -0,0 +1,157 @@
+package leaderboard
+
+import (
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/rejlersembriq/hooked/pkg/participant"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func saved(id string, score int) participant.Change {
+	return participant.Change{
+		Op:          participant.ChangeSaved,
+		ID:          id,
+		Participant: &participant.Participant{ID: aws.String(id), Score: aws.Int(score)},
+	}
+}
+
+func TestStream_Subscribe_Snapshot(t *testing.T) {
+	s := NewStream(testParticipants(), TieShared, 10)
+
+	backlog, _, cancel := s.Subscribe("")
+	defer cancel()
+
+	if assert.Len(t, backlog, 1) {
+		assert.Equal(t, UpdateSnapshot, backlog[0].Type)
+		assert.Equal(t, uint64(0), backlog[0].ID)
+		assert.Equal(t, []string{"b", "f", "c", "a", "e"}, ids(backlog[0].Entries))
+		assert.Equal(t, []int{1, 1, 3, 3, 5}, ranks(backlog[0].Entries))
+	}
+}
+
+func TestStream_Apply(t *testing.T) {
+	s := NewStream(testParticipants(), TieShared, 10)
+
+	_, updates, cancel := s.Subscribe("")
+	defer cancel()
+
+	// e passes c and a, moving them down a rank.
+	s.Apply(saved("e", 20))
+
+	u := <-updates
+	assert.Equal(t, uint64(1), u.ID)
+	assert.Equal(t, UpdateRank, u.Type)
+	assert.Equal(t, []string{"e", "c", "a"}, ids(u.Entries))
+	assert.Equal(t, []int{3, 4, 4}, ranks(u.Entries))
+	assert.Empty(t, u.Removed)
+
+	s.Apply(participant.Change{Op: participant.ChangeDeleted, ID: "b"})
+
+	u = <-updates
+	assert.Equal(t, uint64(2), u.ID)
+	assert.Equal(t, []string{"e", "c", "a"}, ids(u.Entries))
+	assert.Equal(t, []int{2, 3, 3}, ranks(u.Entries))
+	assert.Equal(t, []string{"b"}, u.Removed)
+
+	// d has no score, and isn't on the leaderboard before or after.
+	s.Apply(participant.Change{Op: participant.ChangeSaved, ID: "d", Participant: &participant.Participant{ID: aws.String("d"), Name: aws.String("Dina")}})
+
+	select {
+	case u := <-updates:
+		t.Errorf("unexpected update %+v", u)
+	default:
+	}
+
+	// The changed participant is sent even if its rank is unchanged, as other fields may have changed.
+	s.Apply(participant.Change{Op: participant.ChangeSaved, ID: "f", Participant: &participant.Participant{ID: aws.String("f"), Name: aws.String("Fred"), Score: aws.Int(30)}})
+
+	u = <-updates
+	if assert.Equal(t, []string{"f"}, ids(u.Entries)) {
+		assert.Equal(t, "Fred", *u.Entries[0].Name)
+	}
+}
+
+func TestStream_Apply_Stale(t *testing.T) {
+	s := NewStream(testParticipants(), TieShared, 10)
+
+	_, updates, cancel := s.Subscribe("")
+	defer cancel()
+
+	newer := saved("e", 20)
+	newer.Participant.Version = aws.Int(3)
+	s.Apply(newer)
+	<-updates
+
+	// An earlier save notified after the later one.
+	older := saved("e", 1)
+	older.Participant.Version = aws.Int(2)
+	s.Apply(older)
+
+	select {
+	case u := <-updates:
+		t.Fatalf("unexpected update for a stale change: %+v", u)
+	default:
+	}
+
+	backlog, _, cancel2 := s.Subscribe("")
+	defer cancel2()
+
+	for _, e := range backlog[0].Entries {
+		if *e.ID == "e" {
+			assert.Equal(t, 20, *e.Score, "stale change should not overwrite the newer score")
+		}
+	}
+}
+
+func TestStream_Subscribe_LastID(t *testing.T) {
+	s := NewStream(testParticipants(), TieShared, 2)
+
+	s.Apply(saved("e", 20))
+	s.Apply(saved("e", 25))
+	s.Apply(saved("e", 35))
+
+	tests := []struct {
+		lastID string
+		ids    []uint64
+		types  []string
+	}{
+		{lastID: "3", ids: nil},
+		{lastID: "2", ids: []uint64{3}, types: []string{UpdateRank}},
+		{lastID: "1", ids: []uint64{2, 3}, types: []string{UpdateRank, UpdateRank}},
+		{lastID: "0", ids: []uint64{3}, types: []string{UpdateSnapshot}},
+		{lastID: "4", ids: []uint64{3}, types: []string{UpdateSnapshot}},
+		{lastID: "x", ids: []uint64{3}, types: []string{UpdateSnapshot}},
+	}
+
+	for _, test := range tests {
+		backlog, _, cancel := s.Subscribe(test.lastID)
+		cancel()
+
+		var ids []uint64
+		var types []string
+		for _, u := range backlog {
+			ids = append(ids, u.ID)
+			types = append(types, u.Type)
+		}
+
+		assert.Equal(t, test.ids, ids, "last id %s", test.lastID)
+		assert.Equal(t, test.types, types, "last id %s", test.lastID)
+	}
+}
+
+func TestStream_SlowSubscriber(t *testing.T) {
+	s := NewStream(testParticipants(), TieShared, 10)
+
+	_, updates, cancel := s.Subscribe("")
+	defer cancel()
+
+	for i := 0; i <= subscriberBuffer; i++ {
+		s.Apply(saved("e", i))
+	}
+
+	received := 0
+	for range updates {
+		received++
+	}
+	assert.Equal(t, subscriberBuffer, received, "channel should be closed when full")
+}
@@ -0,0 +1,189 @@
+package leaderboard
+
+import (
+	"errors"
+	"github.com/rejlersembriq/hooked/pkg/participant"
+	"math"
+	"sort"
+)
+
+// Metric selects which team aggregate teams are ranked by.
+type Metric string
+
+const (
+	// MetricTotal ranks teams by the sum of their participants' scores.
+	MetricTotal Metric = "total"
+	// MetricAverage ranks teams by the average score of their participants.
+	MetricAverage Metric = "average"
+	// MetricBest ranks teams by their best participant's score.
+	MetricBest Metric = "best"
+)
+
+// ErrInvalidMetric is returned when parsing an unknown metric.
+var ErrInvalidMetric = errors.New("invalid metric")
+
+// ParseMetric returns the Metric matching s. An empty string gives MetricTotal.
+func ParseMetric(s string) (Metric, error) {
+	switch Metric(s) {
+	case "", MetricTotal:
+		return MetricTotal, nil
+	case MetricAverage:
+		return MetricAverage, nil
+	case MetricBest:
+		return MetricBest, nil
+	default:
+		return "", ErrInvalidMetric
+	}
+}
+
+// Team holds aggregated scores for all participants in an organisation.
+// Total, Average and Best only take participants with a score into account.
+type Team struct {
+	Org          string  `json:"org"`
+	Participants int     `json:"participants"`
+	Total        int     `json:"total"`
+	Average      float64 `json:"average"`
+	Best         *int    `json:"best"`
+}
+
+// TeamEntry is a team with its position on the team leaderboard.
+type TeamEntry struct {
+	Rank int `json:"rank"`
+	Team
+}
+
+// Orgs returns the distinct organisations of the participants sorted alphabetically.
+func Orgs(ps []*participant.Participant) []string {
+	seen := make(map[string]bool)
+	orgs := make([]string, 0)
+
+	for _, p := range ps {
+		if p == nil || p.Org == nil || *p.Org == "" || seen[*p.Org] {
+			continue
+		}
+		seen[*p.Org] = true
+		orgs = append(orgs, *p.Org)
+	}
+
+	sort.Strings(orgs)
+
+	return orgs
+}
+
+// FilterOrg returns the participants belonging to org.
+func FilterOrg(ps []*participant.Participant, org string) []*participant.Participant {
+	res := make([]*participant.Participant, 0)
+	for _, p := range ps {
+		if p != nil && p.Org != nil && *p.Org == org {
+			res = append(res, p)
+		}
+	}
+	return res
+}
+
+// Aggregate computes the team aggregates for org.
+func Aggregate(ps []*participant.Participant, org string) Team {
+	b := NewTeamBuilder(org)
+	b.Add(ps)
+	return b.Team()
+}
+
+// TeamBuilder computes the aggregates of a team from participants added a page at a time, so a large organisation
+// doesn't have to be read at once.
+type TeamBuilder struct {
+	team   Team
+	scored int
+}
+
+// NewTeamBuilder returns a builder for the team of org, without participants.
+func NewTeamBuilder(org string) *TeamBuilder {
+	return &TeamBuilder{team: Team{Org: org}}
+}
+
+// Add counts the participants belonging to the organisation of the team. Others are ignored.
+func (b *TeamBuilder) Add(ps []*participant.Participant) {
+	for _, p := range FilterOrg(ps, b.team.Org) {
+		b.team.Participants++
+
+		if p.Score == nil {
+			continue
+		}
+
+		b.scored++
+		b.team.Total += *p.Score
+
+		if b.team.Best == nil || *p.Score > *b.team.Best {
+			best := *p.Score
+			b.team.Best = &best
+		}
+	}
+}
+
+// Team returns the aggregates of the participants added so far.
+func (b *TeamBuilder) Team() Team {
+	team := b.team
+	if b.scored > 0 {
+		team.Average = float64(team.Total) / float64(b.scored)
+	}
+	return team
+}
+
+// Teams computes the aggregates for every organisation, sorted alphabetically.
+func Teams(ps []*participant.Participant) []Team {
+	orgs := Orgs(ps)
+
+	teams := make([]Team, len(orgs))
+	for i, org := range orgs {
+		teams[i] = Aggregate(ps, org)
+	}
+
+	return teams
+}
+
+// RankTeams orders the teams by the metric, highest first. Teams with equal value share rank.
+// If limit is larger than 0 at most limit entries are returned.
+func RankTeams(teams []Team, by Metric, limit int) []TeamEntry {
+	sorted := make([]Team, len(teams))
+	copy(sorted, teams)
+
+	sort.SliceStable(sorted, func(i, j int) bool {
+		vi, vj := value(sorted[i], by), value(sorted[j], by)
+		if vi != vj {
+			return vi > vj
+		}
+		return sorted[i].Org < sorted[j].Org
+	})
+
+	if limit > 0 && limit < len(sorted) {
+		sorted = sorted[:limit]
+	}
+
+	entries := make([]TeamEntry, len(sorted))
+	for i, team := range sorted {
+		rank := i + 1
+		if i > 0 && value(team, by) == value(sorted[i-1], by) {
+			rank = entries[i-1].Rank
+		}
+
+		entries[i] = TeamEntry{
+			Rank: rank,
+			Team: team,
+		}
+	}
+
+	return entries
+}
+
+func value(t Team, by Metric) float64 {
+	switch by {
+	case MetricAverage:
+		return t.Average
+	case MetricBest:
+		if t.Best == nil {
+			return math.Inf(-1)
+		}
+		return float64(*t.Best)
+	default:
+		return float64(t.Total)
+	}
+}
@@ -0,0 +1,102 @@
+package leaderboard
+
+import (
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/rejlersembriq/hooked/pkg/participant"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func teamParticipants() []*participant.Participant {
+	return []*participant.Participant{
+		{ID: aws.String("a1"), Org: aws.String("OrgA"), Score: aws.Int(10)},
+		{ID: aws.String("a2"), Org: aws.String("OrgA"), Score: aws.Int(20)},
+		{ID: aws.String("a3"), Org: aws.String("OrgA")},
+		{ID: aws.String("b1"), Org: aws.String("OrgB"), Score: aws.Int(25)},
+		{ID: aws.String("c1"), Org: aws.String("OrgC"), Score: aws.Int(5)},
+		{ID: aws.String("c2"), Org: aws.String("OrgC"), Score: aws.Int(25)},
+		{ID: aws.String("none"), Score: aws.Int(100)},
+	}
+}
+
+func TestOrgs(t *testing.T) {
+	assert.Equal(t, []string{"OrgA", "OrgB", "OrgC"}, Orgs(teamParticipants()))
+	assert.Equal(t, []string{}, Orgs(nil))
+}
+
+func TestFilterOrg(t *testing.T) {
+	ps := FilterOrg(teamParticipants(), "OrgC")
+	assert.Len(t, ps, 2)
+	assert.Equal(t, "c1", *ps[0].ID)
+	assert.Equal(t, "c2", *ps[1].ID)
+
+	assert.Len(t, FilterOrg(teamParticipants(), "NoOrg"), 0)
+}
+
+func TestAggregate(t *testing.T) {
+	team := Aggregate(teamParticipants(), "OrgA")
+
+	assert.Equal(t, "OrgA", team.Org)
+	assert.Equal(t, 3, team.Participants)
+	assert.Equal(t, 30, team.Total)
+	assert.Equal(t, 15.0, team.Average)
+	assert.Equal(t, 20, *team.Best)
+
+	empty := Aggregate(teamParticipants(), "NoOrg")
+	assert.Equal(t, 0, empty.Participants)
+	assert.Nil(t, empty.Best)
+}
+
+func TestTeamBuilder(t *testing.T) {
+	ps := teamParticipants()
+
+	b := NewTeamBuilder("OrgA")
+	b.Add(ps[:2])
+	b.Add(ps[2:])
+
+	assert.Equal(t, Aggregate(ps, "OrgA"), b.Team())
+}
+
+func TestRankTeams(t *testing.T) {
+	teams := Teams(teamParticipants())
+
+	orgs := func(entries []TeamEntry) []string {
+		var res []string
+		for _, e := range entries {
+			res = append(res, e.Org)
+		}
+		return res
+	}
+	ranks := func(entries []TeamEntry) []int {
+		var res []int
+		for _, e := range entries {
+			res = append(res, e.Rank)
+		}
+		return res
+	}
+
+	byTotal := RankTeams(teams, MetricTotal, 0)
+	assert.Equal(t, []string{"OrgA", "OrgC", "OrgB"}, orgs(byTotal))
+	assert.Equal(t, []int{1, 1, 3}, ranks(byTotal))
+
+	byAverage := RankTeams(teams, MetricAverage, 0)
+	assert.Equal(t, []string{"OrgB", "OrgA", "OrgC"}, orgs(byAverage))
+	assert.Equal(t, []int{1, 2, 2}, ranks(byAverage))
+
+	byBest := RankTeams(teams, MetricBest, 1)
+	assert.Equal(t, []string{"OrgB"}, orgs(byBest))
+	assert.Equal(t, []int{1}, ranks(byBest))
+}
+
+func TestParseMetric(t *testing.T) {
+	m, err := ParseMetric("")
+	assert.NoError(t, err)
+	assert.Equal(t, MetricTotal, m)
+
+	m, err = ParseMetric("best")
+	assert.NoError(t, err)
+	assert.Equal(t, MetricBest, m)
+
+	_, err = ParseMetric("invalid")
+	assert.Equal(t, ErrInvalidMetric, err)
+}
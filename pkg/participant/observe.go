@@ -0,0 +1,109 @@
+package participant
+
+import (
+	"context"
+	"sync"
+)
+
+// ChangeOp is the kind of change made to a participant.
+type ChangeOp string
+
+const (
+	// ChangeSaved is a participant created or updated.
+	ChangeSaved ChangeOp = "saved"
+	// ChangeDeleted is a participant deleted.
+	ChangeDeleted ChangeOp = "deleted"
+)
+
+// Change describes a participant changed through an ObservedRepository. Participant is the saved participant, and nil
+// when deleted. Created is set when a saved participant is new.
+type Change struct {
+	Op          ChangeOp
+	ID          string
+	Participant *Participant
+	Created     bool
+}
+
+// ObservedRepository wraps a Repository, notifying listeners after every successful Save and Delete. It works with
+// any backend, but only sees changes made through it.
+type ObservedRepository struct {
+	Repository
+
+	mu        sync.RWMutex
+	listeners map[int]func(Change)
+	next      int
+}
+
+// Observe returns repo wrapped in an ObservedRepository without listeners.
+func Observe(repo Repository) *ObservedRepository {
+	return &ObservedRepository{
+		Repository: repo,
+		listeners:  make(map[int]func(Change)),
+	}
+}
+
+// OnChange adds a listener, returning a function removing it. Listeners are called synchronously after the change
+// is stored, each with its own copy of the participant, and should return quickly.
+func (r *ObservedRepository) OnChange(listener func(Change)) func() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	id := r.next
+	r.next++
+	r.listeners[id] = listener
+
+	return func() {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+
+		delete(r.listeners, id)
+	}
+}
+
+// Save saves the participant in the underlying repository and notifies the listeners. A participant without an id is
+// created.
+func (r *ObservedRepository) Save(ctx context.Context, p Participant) (*Participant, Error) {
+	created := p.ID == nil
+
+	saved, err := r.Repository.Save(ctx, p)
+	if err != nil {
+		return nil, err
+	}
+
+	id := ""
+	if saved.ID != nil {
+		id = *saved.ID
+	}
+	r.notify(func() Change {
+		cp := saved.Clone()
+		return Change{Op: ChangeSaved, ID: id, Participant: &cp, Created: created}
+	})
+
+	return saved, nil
+}
+
+// Delete deletes the participant from the underlying repository and notifies the listeners.
+func (r *ObservedRepository) Delete(ctx context.Context, id string) Error {
+	if err := r.Repository.Delete(ctx, id); err != nil {
+		return err
+	}
+
+	r.notify(func() Change {
+		return Change{Op: ChangeDeleted, ID: id}
+	})
+
+	return nil
+}
+
+func (r *ObservedRepository) notify(change func() Change) {
+	r.mu.RLock()
+	listeners := make([]func(Change), 0, len(r.listeners))
+	for _, listener := range r.listeners {
+		listeners = append(listeners, listener)
+	}
+	r.mu.RUnlock()
+
+	for _, listener := range listeners {
+		listener(change())
+	}
+}
@@ -0,0 +1,88 @@
+package participant
+
+import (
+	"context"
+	"errors"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+// repoStub stores participants by id. Only Save and Delete are used by ObservedRepository.
+type repoStub struct {
+	Repository
+	participants map[string]Participant
+	fail         bool
+}
+
+func (r *repoStub) Save(ctx context.Context, p Participant) (*Participant, Error) {
+	if r.fail {
+		return nil, errors.New("failed")
+	}
+	if p.ID == nil {
+		p.ID = aws.String("new")
+	}
+	r.participants[*p.ID] = p
+	return &p, nil
+}
+
+func (r *repoStub) Delete(ctx context.Context, id string) Error {
+	if _, exists := r.participants[id]; !exists {
+		return ErrNotExist
+	}
+	delete(r.participants, id)
+	return nil
+}
+
+func TestObservedRepository(t *testing.T) {
+	stub := &repoStub{participants: make(map[string]Participant)}
+	repo := Observe(stub)
+
+	var changes []Change
+	remove := repo.OnChange(func(c Change) { changes = append(changes, c) })
+
+	saved, err := repo.Save(context.Background(), Participant{ID: aws.String("a"), Score: aws.Int(10)})
+	assert.NoError(t, err)
+	assert.NoError(t, repo.Delete(context.Background(), "a"))
+
+	if assert.Len(t, changes, 2) {
+		assert.Equal(t, ChangeSaved, changes[0].Op)
+		assert.Equal(t, "a", changes[0].ID)
+		assert.Equal(t, 10, *changes[0].Participant.Score)
+		assert.False(t, changes[0].Participant.Score == saved.Score, "listeners get a copy")
+		assert.False(t, changes[0].Created)
+
+		assert.Equal(t, Change{Op: ChangeDeleted, ID: "a"}, changes[1])
+	}
+
+	// Failed changes aren't notified.
+	assert.Error(t, repo.Delete(context.Background(), "a"))
+	stub.fail = true
+	_, err = repo.Save(context.Background(), Participant{ID: aws.String("b")})
+	assert.Error(t, err)
+	assert.Len(t, changes, 2)
+
+	stub.fail = false
+	remove()
+	_, err = repo.Save(context.Background(), Participant{ID: aws.String("b")})
+	assert.NoError(t, err)
+	assert.Len(t, changes, 2, "removed listener isn't called")
+}
+
+func TestObservedRepository_Created(t *testing.T) {
+	repo := Observe(&repoStub{participants: make(map[string]Participant)})
+
+	var changes []Change
+	repo.OnChange(func(c Change) { changes = append(changes, c) })
+
+	_, err := repo.Save(context.Background(), Participant{Name: aws.String("Annie")})
+	assert.NoError(t, err)
+	_, err = repo.Save(context.Background(), Participant{ID: aws.String("new"), Score: aws.Int(10)})
+	assert.NoError(t, err)
+
+	if assert.Len(t, changes, 2) {
+		assert.Equal(t, "new", changes[0].ID)
+		assert.True(t, changes[0].Created)
+		assert.False(t, changes[1].Created)
+	}
+}
@@ -1,7 +1,9 @@
 package participant
 
 import (
+	"context"
 	"errors"
+	"strings"
 	"time"
 )
 
@@ -12,12 +14,58 @@ type Error error
 // ErrNotExist should be returned when the requested participant is not found in the repository.
 var ErrNotExist Error = errors.New("participant doesn't exist")
 
+// ErrVersionConflict should be returned when a participant is saved with a version that doesn't match the stored one.
+var ErrVersionConflict Error = errors.New("participant version conflict")
+
+// ErrEmailTaken should be returned when a participant is saved with an email registered to another participant.
+// Repositories return it as an *EmailTakenError, identifying the existing participant.
+var ErrEmailTaken Error = errors.New("participant email already registered")
+
+// EmailTakenError is returned when saving a participant with an email registered to the participant with ID.
+// It matches ErrEmailTaken with errors.Is.
+type EmailTakenError struct {
+	ID string
+}
+
+func (e *EmailTakenError) Error() string {
+	return ErrEmailTaken.Error()
+}
+
+// Is reports if target is ErrEmailTaken.
+func (e *EmailTakenError) Is(target error) bool {
+	return target == ErrEmailTaken
+}
+
+// NormalizeEmail returns the form emails are compared in for uniqueness. Emails differing only in case or
+// surrounding whitespace belong to the same person.
+func NormalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// SameEmail reports if a and b are the same email when normalized.
+func SameEmail(a, b string) bool {
+	return NormalizeEmail(a) == NormalizeEmail(b)
+}
+
+// SameEvent reports if a and b are the same event slug. Nil is the group of participants without an event.
+func SameEvent(a, b *string) bool {
+	return deref(a) == deref(b)
+}
+
 // Repository defines interface for persisting and retrieving participant info.
+// The context is passed on to the underlying storage so a cancelled request or an expired deadline stops the operation.
+// Save increments the version of the participant. If the participant passed to Save has a version, the update is only
+// done if it matches the stored version, otherwise ErrVersionConflict is returned. Participants stored before versions
+// were introduced have no version and are matched by version 0.
+// Emails are unique within an event by their normalized form, see NormalizeEmail. Saving a participant with an email
+// registered to another participant in the same event returns an *EmailTakenError. Participants without an event are
+// one group. The event of a participant is set when it is created and never changed.
 type Repository interface {
-	Save(participant Participant) (*Participant, Error)
-	Get(id string) (*Participant, Error)
-	GetAll() ([]*Participant, Error)
-	Delete(id string) Error
+	Save(ctx context.Context, participant Participant) (*Participant, Error)
+	Get(ctx context.Context, id string) (*Participant, Error)
+	GetAll(ctx context.Context) ([]*Participant, Error)
+	Query(ctx context.Context, query Query) (*Page, Error)
+	Delete(ctx context.Context, id string) Error
 }
 
 // Participant represents a participants object.
@@ -27,8 +75,101 @@ type Participant struct {
 	Email   *string    `json:"email,omitempty"`
 	Phone   *string    `json:"phone,omitempty"`
 	Org     *string    `json:"org,omitempty"`
+	Event   *string    `json:"event,omitempty"`
 	Score   *int       `json:"score"`
 	Comment *string    `json:"comment,omitempty"`
+	Consent *bool      `json:"consent,omitempty"`
 	Created *time.Time `json:"created" dynamodbav:",unixtime"`
 	Updated *time.Time `json:"updated" dynamodbav:",unixtime"`
+	Version *int       `json:"version,omitempty"`
+}
+
+// Clone returns a copy of p where every pointer field points to a new value.
+func (p Participant) Clone() Participant {
+	return Participant{
+		ID:      copyString(p.ID),
+		Name:    copyString(p.Name),
+		Email:   copyString(p.Email),
+		Phone:   copyString(p.Phone),
+		Org:     copyString(p.Org),
+		Event:   copyString(p.Event),
+		Score:   copyInt(p.Score),
+		Comment: copyString(p.Comment),
+		Consent: copyBool(p.Consent),
+		Created: copyTime(p.Created),
+		Updated: copyTime(p.Updated),
+		Version: copyInt(p.Version),
+	}
+}
+
+// Apply sets the fields present in update on p, supporting partial updates. ID, event, timestamps and version are
+// left unchanged.
+func (p *Participant) Apply(update Participant) {
+	if update.Name != nil {
+		p.Name = update.Name
+	}
+
+	if update.Email != nil {
+		p.Email = update.Email
+	}
+
+	if update.Phone != nil {
+		p.Phone = update.Phone
+	}
+
+	if update.Org != nil {
+		p.Org = update.Org
+	}
+
+	if update.Score != nil {
+		p.Score = update.Score
+	}
+
+	if update.Comment != nil {
+		p.Comment = update.Comment
+	}
+
+	if update.Consent != nil {
+		p.Consent = update.Consent
+	}
+}
+
+// CurrentVersion returns the version of p. Participants stored before versions were introduced are version 0.
+func (p Participant) CurrentVersion() int {
+	if p.Version == nil {
+		return 0
+	}
+	return *p.Version
+}
+
+func copyString(s *string) *string {
+	if s == nil {
+		return nil
+	}
+	c := *s
+	return &c
+}
+
+func copyInt(i *int) *int {
+	if i == nil {
+		return nil
+	}
+	c := *i
+	return &c
+}
+
+func copyBool(b *bool) *bool {
+	if b == nil {
+		return nil
+	}
+	c := *b
+	return &c
+}
+
+func copyTime(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	c := *t
+	return &c
 }
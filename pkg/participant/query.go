@@ -0,0 +1,248 @@
+package participant
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ErrInvalidCursor should be returned when a query cursor can't be decoded or doesn't belong to the query.
+var ErrInvalidCursor Error = errors.New("invalid cursor")
+
+// ErrQueryTooLarge should be returned when a query needs every match to be read, eg. to sort them, and more
+// participants match than the repository reads at once.
+var ErrQueryTooLarge Error = errors.New("query matches too many participants")
+
+// SortField defines which attribute query results are ordered by.
+type SortField string
+
+// Valid sort fields. SortNone leaves the order up to the repository.
+const (
+	SortNone    SortField = ""
+	SortScore   SortField = "score"
+	SortName    SortField = "name"
+	SortCreated SortField = "created"
+	SortUpdated SortField = "updated"
+)
+
+// ParseSortField returns the SortField matching s.
+func ParseSortField(s string) (SortField, bool) {
+	switch f := SortField(s); f {
+	case SortNone, SortScore, SortName, SortCreated, SortUpdated:
+		return f, true
+	default:
+		return "", false
+	}
+}
+
+// Query describes a filtered, sorted and paginated lookup of participants. Nil filter fields are ignored.
+// All ranges are inclusive.
+type Query struct {
+	Org         *string
+	Event       *string
+	MinScore    *int
+	MaxScore    *int
+	CreatedFrom *time.Time
+	CreatedTo   *time.Time
+	UpdatedFrom *time.Time
+	UpdatedTo   *time.Time
+
+	Sort       SortField
+	Descending bool
+
+	// Limit is the max number of participants in a page. 0 means no limit.
+	Limit int
+	// Cursor is the opaque continuation token from Page.Next of the previous page.
+	Cursor string
+}
+
+// Page holds one page of query results. Next is empty when there are no more results.
+type Page struct {
+	Participants []*Participant `json:"participants"`
+	Next         string         `json:"next,omitempty"`
+}
+
+// Match reports whether p satisfies the filters in the query.
+func (q Query) Match(p *Participant) bool {
+	if q.Org != nil && (p.Org == nil || *p.Org != *q.Org) {
+		return false
+	}
+
+	if q.Event != nil && (p.Event == nil || *p.Event != *q.Event) {
+		return false
+	}
+
+	if q.MinScore != nil && (p.Score == nil || *p.Score < *q.MinScore) {
+		return false
+	}
+
+	if q.MaxScore != nil && (p.Score == nil || *p.Score > *q.MaxScore) {
+		return false
+	}
+
+	if !inRange(p.Created, q.CreatedFrom, q.CreatedTo) {
+		return false
+	}
+
+	return inRange(p.Updated, q.UpdatedFrom, q.UpdatedTo)
+}
+
+func inRange(t, from, to *time.Time) bool {
+	if from == nil && to == nil {
+		return true
+	}
+
+	if t == nil {
+		return false
+	}
+
+	if from != nil && t.Before(*from) {
+		return false
+	}
+
+	return to == nil || !t.After(*to)
+}
+
+// Less reports whether a is ordered before b according to the query sort. Id is used as tie breaker so the order
+// is total. Participants missing the sort attribute are placed first in ascending order.
+func (q Query) Less(a, b *Participant) bool {
+	c := compare(q.Sort, a, b)
+	if q.Descending {
+		c = -c
+	}
+
+	if c != 0 {
+		return c < 0
+	}
+
+	return strings.Compare(deref(a.ID), deref(b.ID)) < 0
+}
+
+func compare(field SortField, a, b *Participant) int {
+	switch field {
+	case SortScore:
+		switch {
+		case a.Score == nil && b.Score == nil:
+			return 0
+		case a.Score == nil:
+			return -1
+		case b.Score == nil:
+			return 1
+		case *a.Score < *b.Score:
+			return -1
+		case *a.Score > *b.Score:
+			return 1
+		}
+		return 0
+	case SortName:
+		return strings.Compare(deref(a.Name), deref(b.Name))
+	case SortCreated:
+		return compareTime(a.Created, b.Created)
+	case SortUpdated:
+		return compareTime(a.Updated, b.Updated)
+	default:
+		return 0
+	}
+}
+
+func compareTime(a, b *time.Time) int {
+	switch {
+	case a == nil && b == nil:
+		return 0
+	case a == nil:
+		return -1
+	case b == nil:
+		return 1
+	case a.Before(*b):
+		return -1
+	case a.After(*b):
+		return 1
+	}
+	return 0
+}
+
+func deref(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+// Paginate sorts ps according to the query and returns the page following the query cursor.
+// ps is expected to already be filtered. The cursor returned in Page.Next identifies the last participant in the
+// page, so entries added or removed between requests don't shift the following pages.
+func (q Query) Paginate(ps []*Participant) (*Page, Error) {
+	sort.Slice(ps, func(i, j int) bool {
+		return q.Less(ps[i], ps[j])
+	})
+
+	start := 0
+	if q.Cursor != "" {
+		last, err := decodeCursor(q.Cursor)
+		if err != nil {
+			return nil, err
+		}
+
+		start = sort.Search(len(ps), func(i int) bool {
+			return q.Less(last, ps[i])
+		})
+	}
+
+	end := len(ps)
+	if q.Limit > 0 && start+q.Limit < end {
+		end = start + q.Limit
+	}
+
+	page := &Page{
+		Participants: append(make([]*Participant, 0, end-start), ps[start:end]...),
+	}
+
+	if end < len(ps) {
+		next, err := q.encodeCursor(ps[end-1])
+		if err != nil {
+			return nil, err
+		}
+		page.Next = next
+	}
+
+	return page, nil
+}
+
+// encodeCursor encodes the id and the sort attribute of p, which is all that's needed to find its position again.
+func (q Query) encodeCursor(p *Participant) (string, Error) {
+	c := Participant{ID: p.ID}
+	switch q.Sort {
+	case SortScore:
+		c.Score = p.Score
+	case SortName:
+		c.Name = p.Name
+	case SortCreated:
+		c.Created = p.Created
+	case SortUpdated:
+		c.Updated = p.Updated
+	}
+
+	b, err := json.Marshal(&c)
+	if err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func decodeCursor(cursor string) (*Participant, Error) {
+	b, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	var p Participant
+	if err := json.Unmarshal(b, &p); err != nil || p.ID == nil {
+		return nil, ErrInvalidCursor
+	}
+
+	return &p, nil
+}
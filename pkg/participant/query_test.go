@@ -0,0 +1,129 @@
+package participant
+
+import (
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func queryParticipants() []*Participant {
+	base := time.Date(2019, 10, 1, 12, 0, 0, 0, time.UTC)
+	at := func(minutes int) *time.Time {
+		t := base.Add(time.Duration(minutes) * time.Minute)
+		return &t
+	}
+
+	return []*Participant{
+		{ID: aws.String("a"), Name: aws.String("Anna"), Org: aws.String("OrgA"), Score: aws.Int(10), Created: at(0), Updated: at(5)},
+		{ID: aws.String("b"), Name: aws.String("Bob"), Org: aws.String("OrgB"), Event: aws.String("conf"), Score: aws.Int(30), Created: at(1), Updated: at(1)},
+		{ID: aws.String("c"), Name: aws.String("Carl"), Org: aws.String("OrgA"), Event: aws.String("conf"), Score: aws.Int(20), Created: at(2), Updated: at(2)},
+		{ID: aws.String("d"), Name: aws.String("Dina"), Org: aws.String("OrgA"), Created: at(3), Updated: at(3)},
+		{ID: aws.String("e"), Name: aws.String("Eve"), Org: aws.String("OrgB"), Score: aws.Int(20), Created: at(4), Updated: at(4)},
+	}
+}
+
+func pageIDs(p *Page) []string {
+	ids := make([]string, 0, len(p.Participants))
+	for _, pp := range p.Participants {
+		ids = append(ids, *pp.ID)
+	}
+	return ids
+}
+
+func TestQuery_Match(t *testing.T) {
+	base := time.Date(2019, 10, 1, 12, 0, 0, 0, time.UTC)
+	from := base.Add(1 * time.Minute)
+	to := base.Add(3 * time.Minute)
+
+	tests := []struct {
+		name     string
+		query    Query
+		expected []string
+	}{
+		{name: "no filter", query: Query{}, expected: []string{"a", "b", "c", "d", "e"}},
+		{name: "org", query: Query{Org: aws.String("OrgA")}, expected: []string{"a", "c", "d"}},
+		{name: "event", query: Query{Event: aws.String("conf")}, expected: []string{"b", "c"}},
+		{name: "min score", query: Query{MinScore: aws.Int(20)}, expected: []string{"b", "c", "e"}},
+		{name: "max score", query: Query{MaxScore: aws.Int(20)}, expected: []string{"a", "c", "e"}},
+		{name: "created range", query: Query{CreatedFrom: &from, CreatedTo: &to}, expected: []string{"b", "c", "d"}},
+		{name: "updated from", query: Query{UpdatedFrom: &to}, expected: []string{"a", "d", "e"}},
+		{name: "combined", query: Query{Org: aws.String("OrgA"), MinScore: aws.Int(15)}, expected: []string{"c"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var res []string
+			for _, p := range queryParticipants() {
+				if test.query.Match(p) {
+					res = append(res, *p.ID)
+				}
+			}
+			assert.Equal(t, test.expected, res)
+		})
+	}
+}
+
+func TestQuery_Paginate_Sort(t *testing.T) {
+	tests := []struct {
+		name     string
+		query    Query
+		expected []string
+	}{
+		{name: "none sorts by id", query: Query{}, expected: []string{"a", "b", "c", "d", "e"}},
+		{name: "score", query: Query{Sort: SortScore}, expected: []string{"d", "a", "c", "e", "b"}},
+		{name: "score descending", query: Query{Sort: SortScore, Descending: true}, expected: []string{"b", "c", "e", "a", "d"}},
+		{name: "name descending", query: Query{Sort: SortName, Descending: true}, expected: []string{"e", "d", "c", "b", "a"}},
+		{name: "updated", query: Query{Sort: SortUpdated}, expected: []string{"b", "c", "d", "e", "a"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			page, err := test.query.Paginate(queryParticipants())
+			assert.NoError(t, err)
+			assert.Equal(t, test.expected, pageIDs(page))
+			assert.Empty(t, page.Next)
+		})
+	}
+}
+
+func TestQuery_Paginate_Cursor(t *testing.T) {
+	q := Query{Sort: SortScore, Descending: true, Limit: 2}
+
+	var pages [][]string
+	for {
+		page, err := q.Paginate(queryParticipants())
+		assert.NoError(t, err)
+		pages = append(pages, pageIDs(page))
+
+		if page.Next == "" {
+			break
+		}
+		q.Cursor = page.Next
+	}
+
+	assert.Equal(t, [][]string{{"b", "c"}, {"e", "a"}, {"d"}}, pages)
+}
+
+func TestQuery_Paginate_CursorStableOnInsert(t *testing.T) {
+	q := Query{Sort: SortScore, Descending: true, Limit: 2}
+
+	first, err := q.Paginate(queryParticipants())
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"b", "c"}, pageIDs(first))
+
+	// A new participant ranked before the cursor should not shift the next page.
+	ps := append(queryParticipants(), &Participant{ID: aws.String("f"), Score: aws.Int(100)})
+	q.Cursor = first.Next
+
+	second, err := q.Paginate(ps)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"e", "a"}, pageIDs(second))
+}
+
+func TestQuery_Paginate_InvalidCursor(t *testing.T) {
+	for _, cursor := range []string{"%%%", "bm90IGpzb24", "e30"} {
+		_, err := Query{Cursor: cursor}.Paginate(queryParticipants())
+		assert.Equal(t, ErrInvalidCursor, err, cursor)
+	}
+}
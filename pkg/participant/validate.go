@@ -0,0 +1,231 @@
+package participant
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/mail"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// Validation limits.
+const (
+	MinScore = 0
+	MaxScore = 1000000
+
+	MaxNameLength    = 100
+	MaxEmailLength   = 254
+	MaxPhoneLength   = 20
+	MaxOrgLength     = 100
+	MaxCommentLength = 1000
+)
+
+// Allows an optional leading +, digits, spaces, dashes and parentheses.
+var phonePattern = regexp.MustCompile(`^\+?[0-9 ()-]+$`)
+
+const minPhoneDigits = 5
+
+// FieldError describes why a single field is invalid.
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// ValidationError is returned when a participant payload is invalid. It holds one entry per invalid field.
+type ValidationError struct {
+	Fields []FieldError `json:"errors"`
+}
+
+func (v *ValidationError) Error() string {
+	msgs := make([]string, len(v.Fields))
+	for i, f := range v.Fields {
+		msgs[i] = fmt.Sprintf("%s: %s", f.Field, f.Message)
+	}
+	return "validation failed: " + strings.Join(msgs, ", ")
+}
+
+func (v *ValidationError) add(field, msg string) {
+	v.Fields = append(v.Fields, FieldError{Field: field, Message: msg})
+}
+
+// errOrNil avoids returning a non nil error interface holding a nil pointer.
+func (v *ValidationError) errOrNil() error {
+	if len(v.Fields) == 0 {
+		return nil
+	}
+	return v
+}
+
+// Fields accepted in a participant payload. Read only fields are accepted so a participant received from the api
+// can be sent back, but Validate rejects them if they are set.
+var knownFields = map[string]bool{
+	"id":      true,
+	"name":    true,
+	"email":   true,
+	"phone":   true,
+	"org":     true,
+	"event":   true,
+	"score":   true,
+	"comment": true,
+	"consent": true,
+	"created": true,
+	"updated": true,
+	"version": true,
+}
+
+// Decode reads a participant payload from r. Returns a *ValidationError listing the fields if the payload contains
+// fields not part of Participant. Other errors are returned from the underlying reader or decoder.
+func Decode(r io.Reader) (Participant, error) {
+	var p Participant
+
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return p, err
+	}
+
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return p, err
+	}
+
+	var unknown []string
+	for k := range raw {
+		if !knownFields[k] {
+			unknown = append(unknown, k)
+		}
+	}
+
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+
+		verr := &ValidationError{}
+		for _, k := range unknown {
+			verr.add(k, "unknown field")
+		}
+		return p, verr
+	}
+
+	if err := json.NewDecoder(bytes.NewReader(b)).Decode(&p); err != nil {
+		return p, err
+	}
+
+	return p, nil
+}
+
+// ValidateCreate validates a participant about to be created. Name and email are required.
+func ValidateCreate(p Participant) error {
+	verr := &ValidationError{}
+
+	if p.Name == nil {
+		verr.add("name", "required")
+	}
+
+	if p.Email == nil {
+		verr.add("email", "required")
+	}
+
+	validate(p, verr)
+
+	return verr.errOrNil()
+}
+
+// ValidateUpdate validates a partial update of a participant. Only fields present are checked. The event can only be
+// set on create.
+func ValidateUpdate(p Participant) error {
+	verr := &ValidationError{}
+
+	validate(p, verr)
+
+	if p.Event != nil {
+		verr.add("event", "read only")
+	}
+
+	return verr.errOrNil()
+}
+
+func validate(p Participant, verr *ValidationError) {
+	if p.Name != nil {
+		validateString(verr, "name", *p.Name, MaxNameLength, true)
+	}
+
+	if p.Email != nil && validateString(verr, "email", *p.Email, MaxEmailLength, true) {
+		if !validEmail(*p.Email) {
+			verr.add("email", "invalid format")
+		}
+	}
+
+	if p.Phone != nil && validateString(verr, "phone", *p.Phone, MaxPhoneLength, false) && *p.Phone != "" {
+		if !validPhone(*p.Phone) {
+			verr.add("phone", "invalid format")
+		}
+	}
+
+	if p.Org != nil {
+		validateString(verr, "org", *p.Org, MaxOrgLength, false)
+	}
+
+	if p.Comment != nil {
+		validateString(verr, "comment", *p.Comment, MaxCommentLength, false)
+	}
+
+	if p.Score != nil && (*p.Score < MinScore || *p.Score > MaxScore) {
+		verr.add("score", fmt.Sprintf("must be between %d and %d", MinScore, MaxScore))
+	}
+
+	if p.Created != nil {
+		verr.add("created", "read only")
+	}
+
+	if p.Updated != nil {
+		verr.add("updated", "read only")
+	}
+
+	if p.Version != nil {
+		verr.add("version", "read only")
+	}
+}
+
+// validateString checks length and emptiness of a string field. Returns true if the field passed.
+func validateString(verr *ValidationError, field, v string, max int, required bool) bool {
+	if required && strings.TrimSpace(v) == "" {
+		verr.add(field, "must not be empty")
+		return false
+	}
+
+	if utf8.RuneCountInString(v) > max {
+		verr.add(field, fmt.Sprintf("must be at most %d characters", max))
+		return false
+	}
+
+	return true
+}
+
+func validEmail(email string) bool {
+	addr, err := mail.ParseAddress(email)
+	if err != nil {
+		return false
+	}
+
+	// ParseAddress accepts display names like "Test <test@test.com>". Only the bare address is allowed.
+	return addr.Address == email && strings.Contains(email[strings.LastIndex(email, "@"):], ".")
+}
+
+func validPhone(phone string) bool {
+	if !phonePattern.MatchString(phone) {
+		return false
+	}
+
+	digits := 0
+	for _, r := range phone {
+		if r >= '0' && r <= '9' {
+			digits++
+		}
+	}
+
+	return digits >= minPhoneDigits
+}
@@ -0,0 +1,101 @@
+package participant
+
+import (
+	"bytes"
+	"errors"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+	"time"
+)
+
+func validParticipant() Participant {
+	return Participant{
+		Name:    aws.String("Test Testson"),
+		Email:   aws.String("test@testson.com"),
+		Phone:   aws.String("+47 123 45 678"),
+		Org:     aws.String("TestOrg"),
+		Score:   aws.Int(2),
+		Comment: aws.String("Test comment."),
+	}
+}
+
+func fields(err error) []string {
+	var verr *ValidationError
+	if !errors.As(err, &verr) {
+		return nil
+	}
+
+	var res []string
+	for _, f := range verr.Fields {
+		res = append(res, f.Field)
+	}
+	return res
+}
+
+func TestValidateCreate(t *testing.T) {
+	now := time.Now()
+
+	tests := []struct {
+		name     string
+		modify   func(p *Participant)
+		expected []string
+	}{
+		{name: "valid", modify: func(p *Participant) {}},
+		{name: "missing name and email", modify: func(p *Participant) { p.Name = nil; p.Email = nil }, expected: []string{"name", "email"}},
+		{name: "empty name", modify: func(p *Participant) { p.Name = aws.String("  ") }, expected: []string{"name"}},
+		{name: "long name", modify: func(p *Participant) { p.Name = aws.String(strings.Repeat("a", MaxNameLength+1)) }, expected: []string{"name"}},
+		{name: "email without at", modify: func(p *Participant) { p.Email = aws.String("test.testson.com") }, expected: []string{"email"}},
+		{name: "email with display name", modify: func(p *Participant) { p.Email = aws.String("Test <test@testson.com>") }, expected: []string{"email"}},
+		{name: "email without domain dot", modify: func(p *Participant) { p.Email = aws.String("test@localhost") }, expected: []string{"email"}},
+		{name: "phone with letters", modify: func(p *Participant) { p.Phone = aws.String("12345abc") }, expected: []string{"phone"}},
+		{name: "phone too short", modify: func(p *Participant) { p.Phone = aws.String("123") }, expected: []string{"phone"}},
+		{name: "empty phone", modify: func(p *Participant) { p.Phone = aws.String("") }},
+		{name: "negative score", modify: func(p *Participant) { p.Score = aws.Int(-1) }, expected: []string{"score"}},
+		{name: "too high score", modify: func(p *Participant) { p.Score = aws.Int(MaxScore + 1) }, expected: []string{"score"}},
+		{name: "long comment", modify: func(p *Participant) { p.Comment = aws.String(strings.Repeat("a", MaxCommentLength+1)) }, expected: []string{"comment"}},
+		{name: "timestamps", modify: func(p *Participant) { p.Created = &now; p.Updated = &now }, expected: []string{"created", "updated"}},
+		{name: "version", modify: func(p *Participant) { p.Version = aws.Int(1) }, expected: []string{"version"}},
+		{name: "event", modify: func(p *Participant) { p.Event = aws.String("conf") }},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			p := validParticipant()
+			test.modify(&p)
+
+			err := ValidateCreate(p)
+			if test.expected == nil {
+				assert.NoError(t, err)
+				return
+			}
+			assert.Equal(t, test.expected, fields(err))
+		})
+	}
+}
+
+func TestValidateUpdate(t *testing.T) {
+	assert.NoError(t, ValidateUpdate(Participant{Score: aws.Int(10)}))
+	assert.NoError(t, ValidateUpdate(Participant{}))
+	assert.Equal(t, []string{"name", "score"}, fields(ValidateUpdate(Participant{Name: aws.String(""), Score: aws.Int(-5)})))
+	assert.Equal(t, []string{"event"}, fields(ValidateUpdate(Participant{Event: aws.String("conf")})))
+}
+
+func TestDecode(t *testing.T) {
+	p, err := Decode(bytes.NewBufferString(`{"name":"Test","score":3,"created":null}`))
+	assert.NoError(t, err)
+	assert.Equal(t, "Test", *p.Name)
+	assert.Equal(t, 3, *p.Score)
+
+	_, err = Decode(bytes.NewBufferString(`{"name":"Test","nickname":"T","age":3}`))
+	assert.Equal(t, []string{"age", "nickname"}, fields(err))
+
+	_, err = Decode(bytes.NewBufferString(`{"name":`))
+	assert.Error(t, err)
+	assert.Nil(t, fields(err))
+
+	_, err = Decode(bytes.NewBufferString(`{"score":"high"}`))
+	assert.Error(t, err)
+	assert.Nil(t, fields(err))
+}
@@ -0,0 +1,154 @@
+package dynamo
+
+import (
+	"context"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/dynamodbattribute"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/dynamodbiface"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/expression"
+	"github.com/rejlersembriq/hooked/pkg/apikey"
+	"time"
+)
+
+// APIKeyRepository implements apikey.Repository in a DynamoDb table with the string hash key "id".
+type APIKeyRepository struct {
+	dynamoDb dynamodbiface.ClientAPI
+	table    string
+}
+
+// NewAPIKeyRepository returns a repository using the provided table.
+func NewAPIKeyRepository(dynamoIface dynamodbiface.ClientAPI, tableName string) *APIKeyRepository {
+	return &APIKeyRepository{
+		dynamoDb: dynamoIface,
+		table:    tableName,
+	}
+}
+
+// Create stores a new key.
+func (r *APIKeyRepository) Create(ctx context.Context, k apikey.Key) (*apikey.Key, error) {
+	item, err := dynamodbattribute.MarshalMap(&k)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = r.dynamoDb.PutItemRequest(&dynamodb.PutItemInput{
+		Item:      item,
+		TableName: &r.table,
+	}).Send(ctx)
+	if err != nil {
+		return nil, requestError(ctx, err)
+	}
+
+	return r.Get(ctx, k.ID)
+}
+
+// Get retrieves a key. The read is consistent, so a revoked key is never accepted.
+func (r *APIKeyRepository) Get(ctx context.Context, id string) (*apikey.Key, error) {
+	res, err := r.dynamoDb.GetItemRequest(&dynamodb.GetItemInput{
+		ConsistentRead: aws.Bool(true),
+		Key: map[string]dynamodb.AttributeValue{
+			"id": {S: &id},
+		},
+		TableName: &r.table,
+	}).Send(ctx)
+	if err != nil {
+		return nil, requestError(ctx, err)
+	}
+
+	if res.Item == nil {
+		return nil, apikey.ErrNotExist
+	}
+
+	var k apikey.Key
+	if err := dynamodbattribute.UnmarshalMap(res.Item, &k); err != nil {
+		return nil, err
+	}
+
+	return &k, nil
+}
+
+// List retrieves all keys.
+func (r *APIKeyRepository) List(ctx context.Context) ([]*apikey.Key, error) {
+	ks := make([]*apikey.Key, 0)
+
+	paginator := dynamodb.NewScanPaginator(r.dynamoDb.ScanRequest(&dynamodb.ScanInput{
+		ConsistentRead: aws.Bool(true),
+		TableName:      &r.table,
+	}))
+
+	for paginator.Next(ctx) {
+		var page []*apikey.Key
+		if err := dynamodbattribute.UnmarshalListOfMaps(paginator.CurrentPage().Items, &page); err != nil {
+			return nil, err
+		}
+
+		ks = append(ks, page...)
+	}
+
+	if err := paginator.Err(); err != nil {
+		return nil, requestError(ctx, err)
+	}
+
+	apikey.SortKeys(ks)
+
+	return ks, nil
+}
+
+// Rotate replaces the hash of a key.
+func (r *APIKeyRepository) Rotate(ctx context.Context, id, hash string, at time.Time) (*apikey.Key, error) {
+	return r.update(ctx, id, expression.
+		Set(expression.Name("hash"), expression.Value(hash)).
+		Set(expression.Name("rotated"), expression.Value(at.Unix())))
+}
+
+// Revoke revokes a key.
+func (r *APIKeyRepository) Revoke(ctx context.Context, id string, at time.Time) (*apikey.Key, error) {
+	return r.update(ctx, id, expression.Set(expression.Name("revoked"), expression.Value(at.Unix())))
+}
+
+// update updates a key, conditioned on the key existing and not being revoked. Times are set as unix seconds, the
+// way they're marshalled.
+func (r *APIKeyRepository) update(ctx context.Context, id string, update expression.UpdateBuilder) (*apikey.Key, error) {
+	exp, err := expression.NewBuilder().
+		WithUpdate(update).
+		WithCondition(expression.And(
+			expression.AttributeExists(expression.Name("id")),
+			expression.AttributeNotExists(expression.Name("revoked")),
+		)).
+		Build()
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := r.dynamoDb.UpdateItemRequest(&dynamodb.UpdateItemInput{
+		ConditionExpression:       exp.Condition(),
+		ExpressionAttributeNames:  exp.Names(),
+		ExpressionAttributeValues: exp.Values(),
+		Key: map[string]dynamodb.AttributeValue{
+			"id": {S: &id},
+		},
+		ReturnValues:     dynamodb.ReturnValueAllNew,
+		TableName:        &r.table,
+		UpdateExpression: exp.Update(),
+	}).Send(ctx)
+
+	if isConditionFailed(err) {
+		// Tell a missing key from a revoked one.
+		if _, err := r.Get(ctx, id); err != nil {
+			return nil, err
+		}
+		return nil, apikey.ErrRevoked
+	}
+
+	if err != nil {
+		return nil, requestError(ctx, err)
+	}
+
+	var k apikey.Key
+	if err := dynamodbattribute.UnmarshalMap(res.Attributes, &k); err != nil {
+		return nil, err
+	}
+
+	return &k, nil
+}
@@ -0,0 +1,138 @@
+package dynamo
+
+import (
+	"context"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/dynamodbattribute"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/dynamodbiface"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/expression"
+	"github.com/google/uuid"
+	"github.com/rejlersembriq/hooked/pkg/attempt"
+	"time"
+)
+
+// AttemptRepository implements attempt.Repository in a DynamoDb table with the string hash key "participant" and
+// the string range key "id", so the attempts of a participant are read by a single query.
+type AttemptRepository struct {
+	dynamoDb dynamodbiface.ClientAPI
+	table    string
+}
+
+// NewAttemptRepository returns a repository using the provided table.
+func NewAttemptRepository(dynamoIface dynamodbiface.ClientAPI, tableName string) *AttemptRepository {
+	return &AttemptRepository{
+		dynamoDb: dynamoIface,
+		table:    tableName,
+	}
+}
+
+type attemptItem struct {
+	Participant string    `dynamodbav:"participant"`
+	ID          string    `dynamodbav:"id"`
+	Score       int       `dynamodbav:"score"`
+	Created     time.Time `dynamodbav:"created"`
+}
+
+// Save stores a new attempt.
+func (r *AttemptRepository) Save(ctx context.Context, a attempt.Attempt) (*attempt.Attempt, error) {
+	a.ID = uuid.New().String()
+	if a.Created.IsZero() {
+		a.Created = time.Now()
+	}
+
+	item, err := dynamodbattribute.MarshalMap(&attemptItem{
+		Participant: a.Participant,
+		ID:          a.ID,
+		Score:       a.Score,
+		Created:     a.Created,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	exp, err := expression.NewBuilder().WithCondition(expression.AttributeNotExists(expression.Name("id"))).Build()
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = r.dynamoDb.PutItemRequest(&dynamodb.PutItemInput{
+		ConditionExpression:       exp.Condition(),
+		ExpressionAttributeNames:  exp.Names(),
+		ExpressionAttributeValues: exp.Values(),
+		Item:                      item,
+		TableName:                 &r.table,
+	}).Send(ctx)
+	if err != nil {
+		return nil, requestError(ctx, err)
+	}
+
+	return &a, nil
+}
+
+// List returns the attempts of a participant, oldest first.
+func (r *AttemptRepository) List(ctx context.Context, participantID string) ([]*attempt.Attempt, error) {
+	exp, err := expression.NewBuilder().
+		WithKeyCondition(expression.Key("participant").Equal(expression.Value(participantID))).
+		Build()
+	if err != nil {
+		return nil, err
+	}
+
+	paginator := dynamodb.NewQueryPaginator(r.dynamoDb.QueryRequest(&dynamodb.QueryInput{
+		ConsistentRead:            aws.Bool(true),
+		ExpressionAttributeNames:  exp.Names(),
+		ExpressionAttributeValues: exp.Values(),
+		KeyConditionExpression:    exp.KeyCondition(),
+		TableName:                 &r.table,
+	}))
+
+	as := make([]*attempt.Attempt, 0)
+	for paginator.Next(ctx) {
+		var items []attemptItem
+		if err := dynamodbattribute.UnmarshalListOfMaps(paginator.CurrentPage().Items, &items); err != nil {
+			return nil, err
+		}
+
+		for _, item := range items {
+			as = append(as, &attempt.Attempt{
+				ID:          item.ID,
+				Participant: item.Participant,
+				Score:       item.Score,
+				Created:     item.Created,
+			})
+		}
+	}
+
+	if err := paginator.Err(); err != nil {
+		return nil, requestError(ctx, err)
+	}
+
+	// The range key is a random id, so the query order isn't the creation order.
+	attempt.Sort(as)
+
+	return as, nil
+}
+
+// DeleteAll removes the attempts of a participant, one at a time. Attempts saved concurrently might be left.
+func (r *AttemptRepository) DeleteAll(ctx context.Context, participantID string) error {
+	as, err := r.List(ctx, participantID)
+	if err != nil {
+		return err
+	}
+
+	for _, a := range as {
+		_, err := r.dynamoDb.DeleteItemRequest(&dynamodb.DeleteItemInput{
+			Key: map[string]dynamodb.AttributeValue{
+				"participant": {S: aws.String(a.Participant)},
+				"id":          {S: aws.String(a.ID)},
+			},
+			TableName: &r.table,
+		}).Send(ctx)
+		if err != nil {
+			return requestError(ctx, err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,137 @@
+package dynamo
+
+import (
+	"context"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/dynamodbiface"
+	"github.com/google/uuid"
+	"github.com/rejlersembriq/hooked/pkg/apikey"
+	"github.com/rejlersembriq/hooked/pkg/apikey/apikeytest"
+	"github.com/rejlersembriq/hooked/pkg/attempt"
+	"github.com/rejlersembriq/hooked/pkg/attempt/attempttest"
+	"github.com/rejlersembriq/hooked/pkg/draw"
+	"github.com/rejlersembriq/hooked/pkg/draw/drawtest"
+	"github.com/rejlersembriq/hooked/pkg/event"
+	"github.com/rejlersembriq/hooked/pkg/event/eventtest"
+	"github.com/rejlersembriq/hooked/pkg/idempotency"
+	"github.com/rejlersembriq/hooked/pkg/idempotency/idempotencytest"
+	"github.com/rejlersembriq/hooked/pkg/repository/dynamo/dynamotest"
+	"github.com/rejlersembriq/hooked/pkg/webhook"
+	"github.com/rejlersembriq/hooked/pkg/webhook/webhooktest"
+	"testing"
+)
+
+// createTestTable creates a table with a unique name starting with prefix, keyed by the string hashKey and, if given,
+// the string rangeKey. The returned func deletes the table.
+func createTestTable(t *testing.T, client dynamodbiface.ClientAPI, prefix, hashKey string, rangeKey ...string) (string, func()) {
+	table := prefix + uuid.New().String()
+
+	input := &dynamodb.CreateTableInput{
+		AttributeDefinitions: []dynamodb.AttributeDefinition{
+			{AttributeName: aws.String(hashKey), AttributeType: dynamodb.ScalarAttributeTypeS},
+		},
+		KeySchema: []dynamodb.KeySchemaElement{
+			{AttributeName: aws.String(hashKey), KeyType: dynamodb.KeyTypeHash},
+		},
+		BillingMode: dynamodb.BillingModePayPerRequest,
+		TableName:   &table,
+	}
+	for _, k := range rangeKey {
+		input.AttributeDefinitions = append(input.AttributeDefinitions, dynamodb.AttributeDefinition{AttributeName: aws.String(k), AttributeType: dynamodb.ScalarAttributeTypeS})
+		input.KeySchema = append(input.KeySchema, dynamodb.KeySchemaElement{AttributeName: aws.String(k), KeyType: dynamodb.KeyTypeRange})
+	}
+
+	if _, err := client.CreateTableRequest(input).Send(context.Background()); err != nil {
+		t.Fatalf("Error creating table: %v", err)
+	}
+
+	return table, func() {
+		client.DeleteTableRequest(&dynamodb.DeleteTableInput{TableName: &table}).Send(context.Background())
+	}
+}
+
+// newTestIdempotencyStore creates an idempotency table. The returned func deletes the table.
+func newTestIdempotencyStore(t *testing.T, client dynamodbiface.ClientAPI) (idempotency.Store, func()) {
+	table, cleanup := createTestTable(t, client, "hooked-test-idempotency-", "key")
+	return NewIdempotencyStore(client, table), cleanup
+}
+
+// newTestAttemptRepo creates an attempt table keyed by participant and id. The returned func deletes the table.
+func newTestAttemptRepo(t *testing.T, client dynamodbiface.ClientAPI) (attempt.Repository, func()) {
+	table, cleanup := createTestTable(t, client, "hooked-test-attempts-", "participant", "id")
+	return NewAttemptRepository(client, table), cleanup
+}
+
+// newTestEventRepo creates an event table keyed by slug. The returned func deletes the table.
+func newTestEventRepo(t *testing.T, client dynamodbiface.ClientAPI) (event.Repository, func()) {
+	table, cleanup := createTestTable(t, client, "hooked-test-events-", "slug")
+	return NewEventRepository(client, table), cleanup
+}
+
+// newTestDrawRepo creates a draw table. The returned func deletes the table.
+func newTestDrawRepo(t *testing.T, client dynamodbiface.ClientAPI) (draw.Repository, func()) {
+	table, cleanup := createTestTable(t, client, "hooked-test-draws-", "id")
+	return NewDrawRepository(client, table), cleanup
+}
+
+// newTestWebhookRepo creates a hook table. The returned func deletes the table.
+func newTestWebhookRepo(t *testing.T, client dynamodbiface.ClientAPI) (webhook.Repository, func()) {
+	table, cleanup := createTestTable(t, client, "hooked-test-webhooks-", "id")
+	return NewWebhookRepository(client, table), cleanup
+}
+
+// newTestDeliveryRepo creates a delivery table keyed by hook and id. The returned func deletes the table.
+func newTestDeliveryRepo(t *testing.T, client dynamodbiface.ClientAPI) (webhook.DeliveryRepository, func()) {
+	table, cleanup := createTestTable(t, client, "hooked-test-deliveries-", "hook", "id")
+	return NewDeliveryRepository(client, table), cleanup
+}
+
+// newTestAPIKeyRepo creates a key table. The returned func deletes the table.
+func newTestAPIKeyRepo(t *testing.T, client dynamodbiface.ClientAPI) (apikey.Repository, func()) {
+	table, cleanup := createTestTable(t, client, "hooked-test-apikeys-", "id")
+	return NewAPIKeyRepository(client, table), cleanup
+}
+
+// Tests
+func TestIdempotencyStore_Conformance(t *testing.T) {
+	idempotencytest.Run(t, func(t *testing.T) (idempotency.Store, func()) {
+		return newTestIdempotencyStore(t, dynamotest.New())
+	})
+}
+
+func TestAttemptRepository_Conformance(t *testing.T) {
+	attempttest.Run(t, func(t *testing.T) (attempt.Repository, func()) {
+		return newTestAttemptRepo(t, dynamotest.New())
+	})
+}
+
+func TestEventRepository_Conformance(t *testing.T) {
+	eventtest.Run(t, func(t *testing.T) (event.Repository, func()) {
+		return newTestEventRepo(t, dynamotest.New())
+	})
+}
+
+func TestDrawRepository_Conformance(t *testing.T) {
+	drawtest.Run(t, func(t *testing.T) (draw.Repository, func()) {
+		return newTestDrawRepo(t, dynamotest.New())
+	})
+}
+
+func TestWebhookRepository_Conformance(t *testing.T) {
+	webhooktest.Run(t, func(t *testing.T) (webhook.Repository, func()) {
+		return newTestWebhookRepo(t, dynamotest.New())
+	})
+}
+
+func TestDeliveryRepository_Conformance(t *testing.T) {
+	webhooktest.RunDeliveries(t, func(t *testing.T) (webhook.DeliveryRepository, func()) {
+		return newTestDeliveryRepo(t, dynamotest.New())
+	})
+}
+
+func TestAPIKeyRepository_Conformance(t *testing.T) {
+	apikeytest.Run(t, func(t *testing.T) (apikey.Repository, func()) {
+		return newTestAPIKeyRepo(t, dynamotest.New())
+	})
+}
@@ -0,0 +1,99 @@
+package dynamo
+
+import (
+	"context"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/dynamodbattribute"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/dynamodbiface"
+	"github.com/rejlersembriq/hooked/pkg/draw"
+)
+
+// DrawRepository implements draw.Repository in a DynamoDb table with the string hash key "id". Draws are stored as
+// single items, limiting a draw to the entries fitting in 400 KB, about ten thousand participants.
+type DrawRepository struct {
+	dynamoDb dynamodbiface.ClientAPI
+	table    string
+}
+
+// NewDrawRepository returns a repository using the provided table.
+func NewDrawRepository(dynamoIface dynamodbiface.ClientAPI, tableName string) *DrawRepository {
+	return &DrawRepository{
+		dynamoDb: dynamoIface,
+		table:    tableName,
+	}
+}
+
+// Create stores a new draw. The write is conditional, so an existing draw is never replaced.
+func (r *DrawRepository) Create(ctx context.Context, d draw.Draw) (*draw.Draw, error) {
+	item, err := dynamodbattribute.MarshalMap(&d)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = r.dynamoDb.PutItemRequest(&dynamodb.PutItemInput{
+		ConditionExpression: aws.String("attribute_not_exists(id)"),
+		Item:                item,
+		TableName:           &r.table,
+	}).Send(ctx)
+	if isConditionFailed(err) {
+		return nil, draw.ErrExists
+	}
+
+	if err != nil {
+		return nil, requestError(ctx, err)
+	}
+
+	return r.Get(ctx, d.ID)
+}
+
+// Get retrieves a draw. The read is consistent, so a draw is found right after it's created.
+func (r *DrawRepository) Get(ctx context.Context, id string) (*draw.Draw, error) {
+	res, err := r.dynamoDb.GetItemRequest(&dynamodb.GetItemInput{
+		ConsistentRead: aws.Bool(true),
+		Key: map[string]dynamodb.AttributeValue{
+			"id": {S: &id},
+		},
+		TableName: &r.table,
+	}).Send(ctx)
+	if err != nil {
+		return nil, requestError(ctx, err)
+	}
+
+	if res.Item == nil {
+		return nil, draw.ErrNotExist
+	}
+
+	var d draw.Draw
+	if err := dynamodbattribute.UnmarshalMap(res.Item, &d); err != nil {
+		return nil, err
+	}
+
+	return &d, nil
+}
+
+// List retrieves all draws.
+func (r *DrawRepository) List(ctx context.Context) ([]*draw.Draw, error) {
+	ds := make([]*draw.Draw, 0)
+
+	paginator := dynamodb.NewScanPaginator(r.dynamoDb.ScanRequest(&dynamodb.ScanInput{
+		TableName: &r.table,
+	}))
+
+	for paginator.Next(ctx) {
+		var page []*draw.Draw
+		if err := dynamodbattribute.UnmarshalListOfMaps(paginator.CurrentPage().Items, &page); err != nil {
+			return nil, err
+		}
+
+		ds = append(ds, page...)
+	}
+
+	if err := paginator.Err(); err != nil {
+		return nil, requestError(ctx, err)
+	}
+
+	draw.Sort(ds)
+
+	return ds, nil
+}
@@ -2,6 +2,9 @@ package dynamo
 
 import (
 	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/aws/awserr"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
@@ -10,6 +13,7 @@ import (
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb/expression"
 	"github.com/google/uuid"
 	"github.com/rejlersembriq/hooked/pkg/participant"
+	"strings"
 	"time"
 )
 
@@ -17,47 +21,121 @@ import (
 type Dynamo struct {
 	dynamoDb         dynamodbiface.ClientAPI
 	participantTable string
+	maxQueryItems    int
 }
 
+// defaultMaxQueryItems is the max number of participants read by a query needing every match, see Query.
+const defaultMaxQueryItems = 10000
+
 // New returns a new dynamo repository.
 func New(dynamoIface dynamodbiface.ClientAPI, tableName string) *Dynamo {
 	return &Dynamo{
 		dynamoDb:         dynamoIface,
 		participantTable: tableName,
+		maxQueryItems:    defaultMaxQueryItems,
 	}
 }
 
+// Emails are registered as uniqueness items in the participant table, with the id emailKeyPrefix followed by the
+// normalized email and the id of the participant owning it. They are written in the same transaction as the
+// participant, so two participants can't register the same email concurrently. Emails of participants in an event are
+// registered with the id suffixed by "#" and the event slug, see emailKey.
+const emailKeyPrefix = "email#"
+
+// eventIndex is the global secondary index on the event of participants, with the string hash key "event". Email
+// registrations have no event, so they are not part of the index.
+const eventIndex = "event-index"
+
+// emailItem registers an email to a participant.
+type emailItem struct {
+	ID          string `dynamodbav:"id"`
+	Participant string `dynamodbav:"participant"`
+}
+
+// Saves and deletes reading the email of the participant are retried this many times if the participant changed
+// before the transaction.
+const maxAttempts = 3
+
+// errConcurrentChange is returned when the participant kept changing between reading and writing it.
+var errConcurrentChange = errors.New("participant changed concurrently")
+
+func isEmailKey(id string) bool {
+	return strings.HasPrefix(id, emailKeyPrefix)
+}
+
+// emailKey returns the id registering the normalized email in event. The suffix can't be confused with a part of the
+// email, as domains can't contain "#".
+func emailKey(email string, event *string) string {
+	if event == nil || *event == "" {
+		return emailKeyPrefix + email
+	}
+	return emailKeyPrefix + email + "#" + *event
+}
+
 // Save persists a participant to DynamoDb.
-func (d *Dynamo) Save(p participant.Participant) (*participant.Participant, participant.Error) {
+func (d *Dynamo) Save(ctx context.Context, p participant.Participant) (*participant.Participant, participant.Error) {
+	if p.ID != nil && isEmailKey(*p.ID) {
+		return nil, participant.ErrNotExist
+	}
+
+	// Without an email there's nothing to register, and the participant is saved in a single update.
+	if p.Email == nil {
+		return d.update(ctx, p)
+	}
+
+	for i := 0; i < maxAttempts; i++ {
+		saved, err := d.saveEmail(ctx, p)
+		if err != errConcurrentChange {
+			return saved, err
+		}
+	}
+
+	return nil, errConcurrentChange
+}
+
+// saveExpression returns the update and condition saving p. If p has an id the participant should exist, otherwise
+// p is given a new id and the participant should not exist. The event is only set on new participants.
+func saveExpression(p *participant.Participant) (expression.UpdateBuilder, expression.ConditionBuilder) {
 	// If id is specified the object should exist in the table. Otherwise we expect it to not be present.
 	condition := expression.ConditionBuilder{}
 	if p.ID != nil {
 		condition = expression.AttributeExists(expression.Name("id"))
-	} else {
-		id := uuid.New().String()
-		p.ID = &id
-		condition = expression.AttributeNotExists(expression.Name("id"))
+		if p.Version != nil {
+			condition = condition.And(versionCondition(*p.Version))
+		}
 	}
 
 	update := expression.
 		Set(expression.Name("created"), expression.IfNotExists(expression.Name("created"), expression.Value(time.Now().Unix()))).
-		Set(expression.Name("updated"), expression.Value(time.Now().Unix()))
+		Set(expression.Name("updated"), expression.Value(time.Now().Unix())).
+		Set(expression.Name("version"), expression.Plus(expression.IfNotExists(expression.Name("version"), expression.Value(0)), expression.Value(1)))
+
+	if p.ID == nil {
+		id := uuid.New().String()
+		p.ID = &id
+		condition = expression.AttributeNotExists(expression.Name("id"))
+
+		// Index keys can't be empty strings.
+		if p.Event != nil && *p.Event != "" {
+			update = update.Set(expression.Name("event"), expression.Value(*p.Event))
+		}
+	}
 
 	// Split up to support partial updates and empty attributes.
 	if p.Name != nil {
-		update = update.Set(expression.Name("name"), expression.Value(p.Name))
+		update = update.Set(expression.Name("name"), expression.Value(stringValue(*p.Name)))
 	}
 
 	if p.Email != nil {
-		update = update.Set(expression.Name("email"), expression.Value(p.Email))
+		update = update.Set(expression.Name("email"), expression.Value(stringValue(*p.Email)))
 	}
 
 	if p.Phone != nil {
-		update = update.Set(expression.Name("phone"), expression.Value(p.Phone))
+		update = update.Set(expression.Name("phone"), expression.Value(stringValue(*p.Phone)))
 	}
 
 	if p.Org != nil {
-		update = update.Set(expression.Name("org"), expression.Value(p.Org))
+		update = update.Set(expression.Name("org"), expression.Value(stringValue(*p.Org)))
 	}
 
 	if p.Score != nil {
@@ -65,9 +143,20 @@ func (d *Dynamo) Save(p participant.Participant) (*participant.Participant, part
 	}
 
 	if p.Comment != nil {
-		update = update.Set(expression.Name("comment"), expression.Value(p.Comment))
+		update = update.Set(expression.Name("comment"), expression.Value(stringValue(*p.Comment)))
+	}
+
+	if p.Consent != nil {
+		update = update.Set(expression.Name("consent"), expression.Value(*p.Consent))
 	}
 
+	return update, condition
+}
+
+// update saves a participant without changing its email.
+func (d *Dynamo) update(ctx context.Context, p participant.Participant) (*participant.Participant, error) {
+	update, condition := saveExpression(&p)
+
 	exp, err := expression.NewBuilder().
 		WithUpdate(update).
 		WithCondition(condition).
@@ -87,12 +176,12 @@ func (d *Dynamo) Save(p participant.Participant) (*participant.Participant, part
 			ReturnValues:     dynamodb.ReturnValueAllNew,
 			TableName:        &d.participantTable,
 			UpdateExpression: exp.Update(),
-		}).Send(context.Background())
+		}).Send(ctx)
 	if err != nil {
 		if aerr, ok := err.(awserr.Error); ok && aerr.Code() == dynamodb.ErrCodeConditionalCheckFailedException {
-			return nil, participant.ErrNotExist
+			return nil, d.conditionFailed(ctx, p)
 		}
-		return nil, err
+		return nil, requestError(ctx, err)
 	}
 
 	var savedParticipant participant.Participant
@@ -103,18 +192,236 @@ func (d *Dynamo) Save(p participant.Participant) (*participant.Participant, part
 	return &savedParticipant, err
 }
 
+// saveEmail saves a participant with an email. If the normalized email changes, the new email is registered and the
+// old one released in the same transaction as the participant is saved. The transaction is conditioned on the stored
+// email being unchanged since it was read, otherwise errConcurrentChange is returned and the save can be retried.
+func (d *Dynamo) saveEmail(ctx context.Context, p participant.Participant) (*participant.Participant, error) {
+	var existing *participant.Participant
+	if p.ID != nil {
+		var err error
+		if existing, err = d.get(ctx, *p.ID, true); err != nil {
+			return nil, err
+		}
+
+		if p.Version != nil && *p.Version != existing.CurrentVersion() {
+			return nil, participant.ErrVersionConflict
+		}
+	}
+
+	update, condition := saveExpression(&p)
+
+	// Emails are registered in the event of the participant, which doesn't change on update.
+	event := p.Event
+	oldEmail := ""
+	if existing != nil {
+		event = existing.Event
+		condition = condition.And(emailCondition(existing.Email))
+		if existing.Email != nil {
+			oldEmail = participant.NormalizeEmail(*existing.Email)
+		}
+	}
+	newEmail := participant.NormalizeEmail(*p.Email)
+
+	exp, err := expression.NewBuilder().
+		WithUpdate(update).
+		WithCondition(condition).
+		Build()
+	if err != nil {
+		return nil, err
+	}
+
+	items := []dynamodb.TransactWriteItem{{
+		Update: &dynamodb.Update{
+			ConditionExpression:       exp.Condition(),
+			ExpressionAttributeNames:  exp.Names(),
+			ExpressionAttributeValues: exp.Values(),
+			Key: map[string]dynamodb.AttributeValue{
+				"id": {S: p.ID},
+			},
+			TableName:        &d.participantTable,
+			UpdateExpression: exp.Update(),
+		},
+	}}
+
+	if newEmail != oldEmail {
+		if newEmail != "" {
+			put, err := d.registerEmail(emailKey(newEmail, event), *p.ID)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, put)
+		}
+
+		if oldEmail != "" {
+			release, err := d.releaseEmail(ctx, emailKey(oldEmail, event), *p.ID)
+			if err != nil {
+				return nil, err
+			}
+			if release != nil {
+				items = append(items, *release)
+			}
+		}
+	}
+
+	_, err = d.dynamoDb.TransactWriteItemsRequest(&dynamodb.TransactWriteItemsInput{
+		TransactItems: items,
+	}).Send(ctx)
+	if err != nil {
+		if aerr, ok := err.(awserr.Error); !ok || aerr.Code() != dynamodb.ErrCodeTransactionCanceledException {
+			return nil, requestError(ctx, err)
+		}
+
+		// DynamoDb doesn't tell which condition failed, so the email is checked first.
+		if newEmail != oldEmail && newEmail != "" {
+			owner, err := d.emailOwner(ctx, emailKey(newEmail, event))
+			if err != nil {
+				return nil, err
+			}
+
+			if owner != "" && owner != *p.ID {
+				return nil, &participant.EmailTakenError{ID: owner}
+			}
+		}
+
+		if existing == nil {
+			return nil, requestError(ctx, err)
+		}
+
+		// The participant was changed or deleted since it was read. Retrying reads it again.
+		return nil, errConcurrentChange
+	}
+
+	return d.get(ctx, *p.ID, true)
+}
+
+// emailCondition matches the stored email of a participant.
+func emailCondition(email *string) expression.ConditionBuilder {
+	if email == nil {
+		return expression.AttributeNotExists(expression.Name("email"))
+	}
+	return expression.Name("email").Equal(expression.Value(stringValue(*email)))
+}
+
+// registerEmail returns the transaction item registering the email key to the participant with id. Fails the
+// transaction if the email is registered.
+func (d *Dynamo) registerEmail(key, id string) (dynamodb.TransactWriteItem, error) {
+	item, err := dynamodbattribute.MarshalMap(&emailItem{ID: key, Participant: id})
+	if err != nil {
+		return dynamodb.TransactWriteItem{}, err
+	}
+
+	return dynamodb.TransactWriteItem{
+		Put: &dynamodb.Put{
+			ConditionExpression: aws.String("attribute_not_exists(id)"),
+			Item:                item,
+			TableName:           &d.participantTable,
+		},
+	}, nil
+}
+
+// releaseEmail returns the transaction item removing the registration of the email key to the participant with id.
+// Returns nil if the email isn't registered to the participant, as for participants registered before emails were
+// unique.
+func (d *Dynamo) releaseEmail(ctx context.Context, key, id string) (*dynamodb.TransactWriteItem, error) {
+	owner, err := d.emailOwner(ctx, key)
+	if err != nil || owner != id {
+		return nil, err
+	}
+
+	exp, err := expression.NewBuilder().
+		WithCondition(expression.Name("participant").Equal(expression.Value(id))).
+		Build()
+	if err != nil {
+		return nil, err
+	}
+
+	return &dynamodb.TransactWriteItem{
+		Delete: &dynamodb.Delete{
+			ConditionExpression:       exp.Condition(),
+			ExpressionAttributeNames:  exp.Names(),
+			ExpressionAttributeValues: exp.Values(),
+			Key: map[string]dynamodb.AttributeValue{
+				"id": {S: &key},
+			},
+			TableName: &d.participantTable,
+		},
+	}, nil
+}
+
+// emailOwner returns the id of the participant the email key is registered to, or "" if not registered.
+func (d *Dynamo) emailOwner(ctx context.Context, key string) (string, error) {
+	res, err := d.dynamoDb.GetItemRequest(&dynamodb.GetItemInput{
+		ConsistentRead: aws.Bool(true),
+		Key: map[string]dynamodb.AttributeValue{
+			"id": {S: &key},
+		},
+		TableName: &d.participantTable,
+	}).Send(ctx)
+	if err != nil {
+		return "", requestError(ctx, err)
+	}
+
+	var item emailItem
+	if err := dynamodbattribute.UnmarshalMap(res.Item, &item); err != nil {
+		return "", err
+	}
+
+	return item.Participant, nil
+}
+
+// versionCondition matches the stored version. Participants saved before versions were introduced match version 0.
+func versionCondition(version int) expression.ConditionBuilder {
+	if version == 0 {
+		return expression.AttributeNotExists(expression.Name("version"))
+	}
+	return expression.Name("version").Equal(expression.Value(version))
+}
+
+// conditionFailed finds out why the condition of a save failed. The participant is either missing or, when saved
+// with a version, has been changed since.
+func (d *Dynamo) conditionFailed(ctx context.Context, p participant.Participant) error {
+	if p.Version == nil {
+		return participant.ErrNotExist
+	}
+
+	if _, err := d.Get(ctx, *p.ID); err != nil {
+		return err
+	}
+
+	return participant.ErrVersionConflict
+}
+
+// stringValue marshals to a string attribute also when empty. The default encoder stores empty strings as null, which
+// would read back as a missing field.
+type stringValue string
+
+func (s stringValue) MarshalDynamoDBAttributeValue(av *dynamodb.AttributeValue) error {
+	str := string(s)
+	av.S = &str
+	return nil
+}
+
 // Get retrieves a participant from DynamoDb.
-func (d *Dynamo) Get(id string) (*participant.Participant, participant.Error) {
+func (d *Dynamo) Get(ctx context.Context, id string) (*participant.Participant, participant.Error) {
+	return d.get(ctx, id, false)
+}
+
+func (d *Dynamo) get(ctx context.Context, id string, consistent bool) (*participant.Participant, error) {
+	if isEmailKey(id) {
+		return nil, participant.ErrNotExist
+	}
+
 	res, err := d.dynamoDb.GetItemRequest(
 		&dynamodb.GetItemInput{
+			ConsistentRead: aws.Bool(consistent),
 			Key: map[string]dynamodb.AttributeValue{
 				"id": {S: &id},
 			},
 			TableName: &d.participantTable,
-		}).Send(context.Background())
+		}).Send(ctx)
 
 	if err != nil {
-		return nil, err
+		return nil, requestError(ctx, err)
 	}
 
 	var p participant.Participant
@@ -130,47 +437,353 @@ func (d *Dynamo) Get(id string) (*participant.Participant, participant.Error) {
 }
 
 // GetAll retrieves all participants from DynamoDb.
-func (d *Dynamo) GetAll() ([]*participant.Participant, participant.Error) {
-	var result []*participant.Participant
+func (d *Dynamo) GetAll(ctx context.Context) ([]*participant.Participant, participant.Error) {
+	exp, err := expression.NewBuilder().WithFilter(participantItems()).Build()
+	if err != nil {
+		return nil, err
+	}
 
-	scanReq := d.dynamoDb.ScanRequest(&dynamodb.ScanInput{
-		TableName: &d.participantTable,
-	})
+	return d.scanAll(ctx, exp, 0)
+}
+
+// Query retrieves a page of participants matching the query. Filters are evaluated by DynamoDb. Queries for an event
+// read the event index, other queries scan the table.
+// Unsorted queries are paged by continuing from the LastEvaluatedKey, so only the requested page is read.
+// Sorted queries need every match to be read before the page can be picked, as neither the scan nor the index is
+// ordered by the sort attributes. So do queries without a limit. They fail with participant.ErrQueryTooLarge rather
+// than read more than 10000 participants.
+func (d *Dynamo) Query(ctx context.Context, q participant.Query) (*participant.Page, participant.Error) {
+	builder := expression.NewBuilder()
+	filter, hasFilter := filterCondition(q)
+
+	if q.Event != nil {
+		builder = builder.WithKeyCondition(expression.Key("event").Equal(expression.Value(*q.Event)))
+		if hasFilter {
+			builder = builder.WithFilter(filter)
+		}
+	} else {
+		items := participantItems()
+		if hasFilter {
+			items = items.And(filter)
+		}
+		builder = builder.WithFilter(items)
+	}
+
+	exp, err := builder.Build()
+	if err != nil {
+		return nil, err
+	}
+
+	if q.Sort != participant.SortNone || q.Limit == 0 {
+		var ps []*participant.Participant
+		if q.Event != nil {
+			ps, err = d.queryAll(ctx, exp, d.maxQueryItems)
+		} else {
+			ps, err = d.scanAll(ctx, exp, d.maxQueryItems)
+		}
+		if err != nil {
+			return nil, err
+		}
+		return q.Paginate(ps)
+	}
+
+	var startKey map[string]dynamodb.AttributeValue
+	if q.Cursor != "" {
+		key, err := decodeKey(q.Cursor, q.Event)
+		if err != nil {
+			return nil, err
+		}
+		startKey = key
+	}
+
+	page := &participant.Page{
+		Participants: make([]*participant.Participant, 0, q.Limit),
+	}
+
+	// Limit is applied by DynamoDb before the filter, so several requests might be needed to fill the page.
+	for {
+		limit := aws.Int64(int64(q.Limit - len(page.Participants)))
+
+		var items []map[string]dynamodb.AttributeValue
+		if q.Event != nil {
+			input := newQueryInput(d.participantTable, exp)
+			input.Limit = limit
+			input.ExclusiveStartKey = startKey
+
+			res, err := d.dynamoDb.QueryRequest(input).Send(ctx)
+			if err != nil {
+				return nil, requestError(ctx, err)
+			}
+			items, startKey = res.Items, res.LastEvaluatedKey
+		} else {
+			input := newScanInput(d.participantTable, exp)
+			input.Limit = limit
+			input.ExclusiveStartKey = startKey
+
+			res, err := d.dynamoDb.ScanRequest(input).Send(ctx)
+			if err != nil {
+				return nil, requestError(ctx, err)
+			}
+			items, startKey = res.Items, res.LastEvaluatedKey
+		}
+
+		var recs []*participant.Participant
+		if err := dynamodbattribute.UnmarshalListOfMaps(items, &recs); err != nil {
+			return nil, err
+		}
+		page.Participants = append(page.Participants, recs...)
+
+		if len(startKey) == 0 {
+			return page, nil
+		}
+
+		if len(page.Participants) >= q.Limit {
+			next, err := encodeKey(startKey)
+			if err != nil {
+				return nil, err
+			}
+			page.Next = next
+			return page, nil
+		}
+	}
+}
 
-	paginator := dynamodb.NewScanPaginator(scanReq)
+// scanAll reads every participant matching exp. Fails with participant.ErrQueryTooLarge if more than max match, unless
+// max is 0.
+func (d *Dynamo) scanAll(ctx context.Context, exp expression.Expression, max int) ([]*participant.Participant, error) {
+	result := make([]*participant.Participant, 0)
 
-	// Paginator next returns false when finished or an error has occured
-	for paginator.Next(context.Background()) {
+	paginator := dynamodb.NewScanPaginator(d.dynamoDb.ScanRequest(newScanInput(d.participantTable, exp)))
+
+	for paginator.Next(ctx) {
 		var recs []*participant.Participant
-		page := paginator.CurrentPage()
-		if err := dynamodbattribute.UnmarshalListOfMaps(page.Items, &recs); err != nil {
+		if err := dynamodbattribute.UnmarshalListOfMaps(paginator.CurrentPage().Items, &recs); err != nil {
 			return nil, err
 		}
 
 		result = append(result, recs...)
+		if max > 0 && len(result) > max {
+			return nil, participant.ErrQueryTooLarge
+		}
 	}
 
 	if err := paginator.Err(); err != nil {
-		return nil, err
+		return nil, requestError(ctx, err)
+	}
+
+	return result, nil
+}
+
+// queryAll reads every participant in the event index matching exp, see scanAll.
+func (d *Dynamo) queryAll(ctx context.Context, exp expression.Expression, max int) ([]*participant.Participant, error) {
+	result := make([]*participant.Participant, 0)
+
+	paginator := dynamodb.NewQueryPaginator(d.dynamoDb.QueryRequest(newQueryInput(d.participantTable, exp)))
+
+	for paginator.Next(ctx) {
+		var recs []*participant.Participant
+		if err := dynamodbattribute.UnmarshalListOfMaps(paginator.CurrentPage().Items, &recs); err != nil {
+			return nil, err
+		}
+
+		result = append(result, recs...)
+		if max > 0 && len(result) > max {
+			return nil, participant.ErrQueryTooLarge
+		}
+	}
+
+	if err := paginator.Err(); err != nil {
+		return nil, requestError(ctx, err)
 	}
 
 	return result, nil
 }
 
-// Delete removes and entry matching the provided id.
-func (d *Dynamo) Delete(id string) participant.Error {
-	_, err := d.dynamoDb.DeleteItemRequest(
-		&dynamodb.DeleteItemInput{
-			ConditionExpression: aws.String("attribute_exists(id)"),
+// newQueryInput queries the event index. Reads of a global secondary index are eventually consistent.
+func newQueryInput(table string, exp expression.Expression) *dynamodb.QueryInput {
+	input := &dynamodb.QueryInput{
+		ExpressionAttributeNames:  exp.Names(),
+		ExpressionAttributeValues: exp.Values(),
+		IndexName:                 aws.String(eventIndex),
+		KeyConditionExpression:    exp.KeyCondition(),
+		TableName:                 &table,
+	}
+
+	// The builder returns an empty filter when there is none, which DynamoDb rejects.
+	if filter := exp.Filter(); filter != nil && *filter != "" {
+		input.FilterExpression = filter
+	}
+
+	return input
+}
+
+func newScanInput(table string, exp expression.Expression) *dynamodb.ScanInput {
+	return &dynamodb.ScanInput{
+		ExpressionAttributeNames:  exp.Names(),
+		ExpressionAttributeValues: exp.Values(),
+		FilterExpression:          exp.Filter(),
+		TableName:                 &table,
+	}
+}
+
+// participantItems filters out the email registrations sharing the table with the participants.
+func participantItems() expression.ConditionBuilder {
+	return expression.Not(expression.Name("id").BeginsWith(emailKeyPrefix))
+}
+
+// filterCondition builds the DynamoDb filter matching participant.Query.Match. Returns false if the query has no
+// filters.
+func filterCondition(q participant.Query) (expression.ConditionBuilder, bool) {
+	var conditions []expression.ConditionBuilder
+
+	if q.Org != nil {
+		conditions = append(conditions, expression.Name("org").Equal(expression.Value(*q.Org)))
+	}
+
+	if q.MinScore != nil {
+		conditions = append(conditions, expression.Name("score").GreaterThanEqual(expression.Value(*q.MinScore)))
+	}
+
+	if q.MaxScore != nil {
+		conditions = append(conditions, expression.Name("score").LessThanEqual(expression.Value(*q.MaxScore)))
+	}
+
+	if q.CreatedFrom != nil {
+		conditions = append(conditions, expression.Name("created").GreaterThanEqual(expression.Value(q.CreatedFrom.Unix())))
+	}
+
+	if q.CreatedTo != nil {
+		conditions = append(conditions, expression.Name("created").LessThanEqual(expression.Value(q.CreatedTo.Unix())))
+	}
+
+	if q.UpdatedFrom != nil {
+		conditions = append(conditions, expression.Name("updated").GreaterThanEqual(expression.Value(q.UpdatedFrom.Unix())))
+	}
+
+	if q.UpdatedTo != nil {
+		conditions = append(conditions, expression.Name("updated").LessThanEqual(expression.Value(q.UpdatedTo.Unix())))
+	}
+
+	switch len(conditions) {
+	case 0:
+		return expression.ConditionBuilder{}, false
+	case 1:
+		return conditions[0], true
+	default:
+		return expression.And(conditions[0], conditions[1], conditions[2:]...), true
+	}
+}
+
+// tableKey is the primary key of the participant table, and the event of queries reading the event index. Used as
+// continuation token for unsorted queries.
+type tableKey struct {
+	ID    string `json:"id" dynamodbav:"id"`
+	Event string `json:"event,omitempty" dynamodbav:"event,omitempty"`
+}
+
+func encodeKey(key map[string]dynamodb.AttributeValue) (string, error) {
+	var k tableKey
+	if err := dynamodbattribute.UnmarshalMap(key, &k); err != nil {
+		return "", err
+	}
+
+	b, err := json.Marshal(&k)
+	if err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// decodeKey decodes the cursor of a query for event, nil if the query scans the table.
+func decodeKey(cursor string, event *string) (map[string]dynamodb.AttributeValue, error) {
+	b, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, participant.ErrInvalidCursor
+	}
+
+	var k tableKey
+	if err := json.Unmarshal(b, &k); err != nil || k.ID == "" {
+		return nil, participant.ErrInvalidCursor
+	}
+
+	if (event == nil && k.Event != "") || (event != nil && k.Event != *event) {
+		return nil, participant.ErrInvalidCursor
+	}
+
+	return dynamodbattribute.MarshalMap(&k)
+}
+
+// Delete removes and entry matching the provided id, and releases its email in the same transaction.
+func (d *Dynamo) Delete(ctx context.Context, id string) participant.Error {
+	for i := 0; i < maxAttempts; i++ {
+		err := d.delete(ctx, id)
+		if err != errConcurrentChange {
+			return err
+		}
+	}
+
+	return errConcurrentChange
+}
+
+func (d *Dynamo) delete(ctx context.Context, id string) error {
+	existing, err := d.get(ctx, id, true)
+	if err != nil {
+		return err
+	}
+
+	exp, err := expression.NewBuilder().
+		WithCondition(expression.AttributeExists(expression.Name("id")).And(emailCondition(existing.Email))).
+		Build()
+	if err != nil {
+		return err
+	}
+
+	items := []dynamodb.TransactWriteItem{{
+		Delete: &dynamodb.Delete{
+			ConditionExpression:       exp.Condition(),
+			ExpressionAttributeNames:  exp.Names(),
+			ExpressionAttributeValues: exp.Values(),
 			Key: map[string]dynamodb.AttributeValue{
 				"id": {S: &id},
 			},
 			TableName: &d.participantTable,
-		}).Send(context.Background())
+		},
+	}}
 
-	if aerr, ok := err.(awserr.Error); ok && aerr.Code() == dynamodb.ErrCodeConditionalCheckFailedException {
-		return participant.ErrNotExist
+	if existing.Email != nil {
+		if email := participant.NormalizeEmail(*existing.Email); email != "" {
+			release, err := d.releaseEmail(ctx, emailKey(email, existing.Event), id)
+			if err != nil {
+				return err
+			}
+			if release != nil {
+				items = append(items, *release)
+			}
+		}
 	}
 
+	_, err = d.dynamoDb.TransactWriteItemsRequest(&dynamodb.TransactWriteItemsInput{
+		TransactItems: items,
+	}).Send(ctx)
+
+	if aerr, ok := err.(awserr.Error); ok && aerr.Code() == dynamodb.ErrCodeTransactionCanceledException {
+		// The participant was changed or deleted since it was read. Retrying reads it again.
+		return errConcurrentChange
+	}
+
+	if err != nil {
+		return requestError(ctx, err)
+	}
+
+	return nil
+}
+
+// requestError returns the context error if the request failed because ctx is done. The sdk reports it as a
+// RequestCanceled error not wrapping the context error.
+func requestError(ctx context.Context, err error) error {
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return ctxErr
+	}
 	return err
 }
@@ -0,0 +1,105 @@
+// +build integration
+
+package dynamo
+
+import (
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/defaults"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/rejlersembriq/hooked/pkg/apikey"
+	"github.com/rejlersembriq/hooked/pkg/apikey/apikeytest"
+	"github.com/rejlersembriq/hooked/pkg/attempt"
+	"github.com/rejlersembriq/hooked/pkg/attempt/attempttest"
+	"github.com/rejlersembriq/hooked/pkg/draw"
+	"github.com/rejlersembriq/hooked/pkg/draw/drawtest"
+	"github.com/rejlersembriq/hooked/pkg/event"
+	"github.com/rejlersembriq/hooked/pkg/event/eventtest"
+	"github.com/rejlersembriq/hooked/pkg/idempotency"
+	"github.com/rejlersembriq/hooked/pkg/idempotency/idempotencytest"
+	"github.com/rejlersembriq/hooked/pkg/participant"
+	"github.com/rejlersembriq/hooked/pkg/repository/repotest"
+	"github.com/rejlersembriq/hooked/pkg/webhook"
+	"github.com/rejlersembriq/hooked/pkg/webhook/webhooktest"
+	"os"
+	"testing"
+)
+
+// integrationClient connects to DynamoDB Local, eg. docker run -p 8000:8000 amazon/dynamodb-local.
+// The endpoint can be overridden with the DYNAMO_ENDPOINT environment variable.
+func integrationClient() *dynamodb.Client {
+	endpoint := os.Getenv("DYNAMO_ENDPOINT")
+	if endpoint == "" {
+		endpoint = "http://localhost:8000"
+	}
+
+	return dynamodb.New(aws.Config{
+		Region:           "local",
+		EndpointResolver: aws.ResolveWithEndpointURL(endpoint),
+		Credentials:      aws.NewStaticCredentialsProvider("local", "local", ""),
+		Handlers:         defaults.Handlers(),
+	})
+}
+
+func TestDynamo_Integration_Conformance(t *testing.T) {
+	client := integrationClient()
+
+	repotest.Run(t, func(t *testing.T) (participant.Repository, func()) {
+		return newTestRepo(t, client)
+	})
+}
+
+func TestIdempotencyStore_Integration_Conformance(t *testing.T) {
+	client := integrationClient()
+
+	idempotencytest.Run(t, func(t *testing.T) (idempotency.Store, func()) {
+		return newTestIdempotencyStore(t, client)
+	})
+}
+
+func TestAttemptRepository_Integration_Conformance(t *testing.T) {
+	client := integrationClient()
+
+	attempttest.Run(t, func(t *testing.T) (attempt.Repository, func()) {
+		return newTestAttemptRepo(t, client)
+	})
+}
+
+func TestEventRepository_Integration_Conformance(t *testing.T) {
+	client := integrationClient()
+
+	eventtest.Run(t, func(t *testing.T) (event.Repository, func()) {
+		return newTestEventRepo(t, client)
+	})
+}
+
+func TestDrawRepository_Integration_Conformance(t *testing.T) {
+	client := integrationClient()
+
+	drawtest.Run(t, func(t *testing.T) (draw.Repository, func()) {
+		return newTestDrawRepo(t, client)
+	})
+}
+
+func TestWebhookRepository_Integration_Conformance(t *testing.T) {
+	client := integrationClient()
+
+	webhooktest.Run(t, func(t *testing.T) (webhook.Repository, func()) {
+		return newTestWebhookRepo(t, client)
+	})
+}
+
+func TestDeliveryRepository_Integration_Conformance(t *testing.T) {
+	client := integrationClient()
+
+	webhooktest.RunDeliveries(t, func(t *testing.T) (webhook.DeliveryRepository, func()) {
+		return newTestDeliveryRepo(t, client)
+	})
+}
+
+func TestAPIKeyRepository_Integration_Conformance(t *testing.T) {
+	client := integrationClient()
+
+	apikeytest.Run(t, func(t *testing.T) (apikey.Repository, func()) {
+		return newTestAPIKeyRepo(t, client)
+	})
+}
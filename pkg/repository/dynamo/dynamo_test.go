@@ -1,12 +1,17 @@
 package dynamo
 
 import (
+	"context"
 	"errors"
 	"github.com/aws/aws-sdk-go-v2/aws"
-	"github.com/aws/aws-sdk-go-v2/aws/awserr"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb/dynamodbiface"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/expression"
+	"github.com/google/uuid"
 	"github.com/rejlersembriq/hooked/pkg/participant"
+	"github.com/rejlersembriq/hooked/pkg/repository/dynamo/dynamotest"
+	"github.com/rejlersembriq/hooked/pkg/repository/repotest"
+	"github.com/stretchr/testify/assert"
 	"net/http"
 	"testing"
 )
@@ -17,7 +22,7 @@ type dynamodbMock struct {
 
 	getItemRequestHandler    func(*dynamodb.GetItemInput) dynamodb.GetItemRequest
 	updateItemRequestHandler func(*dynamodb.UpdateItemInput) dynamodb.UpdateItemRequest
-	deleteItemRequestHandler func(*dynamodb.DeleteItemInput) dynamodb.DeleteItemRequest
+	scanRequestHandler       func(*dynamodb.ScanInput) dynamodb.ScanRequest
 }
 
 func (d dynamodbMock) GetItemRequest(input *dynamodb.GetItemInput) dynamodb.GetItemRequest {
@@ -28,11 +33,50 @@ func (d dynamodbMock) UpdateItemRequest(input *dynamodb.UpdateItemInput) dynamod
 	return d.updateItemRequestHandler(input)
 }
 
-func (d dynamodbMock) DeleteItemRequest(input *dynamodb.DeleteItemInput) dynamodb.DeleteItemRequest {
-	return d.deleteItemRequestHandler(input)
+func (d dynamodbMock) ScanRequest(input *dynamodb.ScanInput) dynamodb.ScanRequest {
+	return d.scanRequestHandler(input)
+}
+
+// newTestRepo creates a participant table with a unique name. The returned func deletes the table.
+func newTestRepo(t *testing.T, client dynamodbiface.ClientAPI) (participant.Repository, func()) {
+	table := "hooked-test-" + uuid.New().String()
+
+	_, err := client.CreateTableRequest(&dynamodb.CreateTableInput{
+		AttributeDefinitions: []dynamodb.AttributeDefinition{
+			{AttributeName: aws.String("id"), AttributeType: dynamodb.ScalarAttributeTypeS},
+			{AttributeName: aws.String("event"), AttributeType: dynamodb.ScalarAttributeTypeS},
+		},
+		KeySchema: []dynamodb.KeySchemaElement{
+			{AttributeName: aws.String("id"), KeyType: dynamodb.KeyTypeHash},
+		},
+		GlobalSecondaryIndexes: []dynamodb.GlobalSecondaryIndex{{
+			IndexName: aws.String(eventIndex),
+			KeySchema: []dynamodb.KeySchemaElement{
+				{AttributeName: aws.String("event"), KeyType: dynamodb.KeyTypeHash},
+			},
+			Projection: &dynamodb.Projection{ProjectionType: dynamodb.ProjectionTypeAll},
+		}},
+		BillingMode: dynamodb.BillingModePayPerRequest,
+		TableName:   &table,
+	}).Send(context.Background())
+	if err != nil {
+		t.Fatalf("Error creating table: %v", err)
+	}
+
+	return New(client, table), func() {
+		client.DeleteTableRequest(&dynamodb.DeleteTableInput{TableName: &table}).Send(context.Background())
+	}
 }
 
 // Tests
+func TestDynamo_Conformance(t *testing.T) {
+	client := dynamotest.New()
+
+	repotest.Run(t, func(t *testing.T) (participant.Repository, func()) {
+		return newTestRepo(t, client)
+	})
+}
+
 func TestDynamo_Get_NotExist(t *testing.T) {
 	mock := dynamodbMock{
 		getItemRequestHandler: func(input *dynamodb.GetItemInput) dynamodb.GetItemRequest {
@@ -48,19 +92,20 @@ func TestDynamo_Get_NotExist(t *testing.T) {
 
 	repo := New(mock, "test-table")
 
-	if _, err := repo.Get(""); !errors.Is(err, participant.ErrNotExist) {
+	if _, err := repo.Get(context.Background(), ""); !errors.Is(err, participant.ErrNotExist) {
 		t.Errorf("Got unexpected error %v", err)
 	}
 }
 
 func TestDynamo_Delete_NotExist(t *testing.T) {
 	mock := dynamodbMock{
-		deleteItemRequestHandler: func(input *dynamodb.DeleteItemInput) dynamodb.DeleteItemRequest {
+		getItemRequestHandler: func(input *dynamodb.GetItemInput) dynamodb.GetItemRequest {
+			assert.True(t, *input.ConsistentRead)
 
-			return dynamodb.DeleteItemRequest{
+			return dynamodb.GetItemRequest{
 				Request: &aws.Request{
+					Data:        &dynamodb.GetItemOutput{},
 					HTTPRequest: &http.Request{},
-					Error:       awserr.New(dynamodb.ErrCodeConditionalCheckFailedException, "", nil),
 				},
 			}
 		},
@@ -68,7 +113,130 @@ func TestDynamo_Delete_NotExist(t *testing.T) {
 
 	repo := New(mock, "test-table")
 
-	if err := repo.Delete(""); !errors.Is(err, participant.ErrNotExist) {
+	if err := repo.Delete(context.Background(), "someId"); !errors.Is(err, participant.ErrNotExist) {
 		t.Errorf("Got unexpected error %v", err)
 	}
 }
+
+func TestDynamo_Query_Paginated(t *testing.T) {
+	// Two scan pages, where the filter removed one of the items in the first page.
+	pages := []*dynamodb.ScanOutput{
+		{
+			Items: []map[string]dynamodb.AttributeValue{
+				{"id": {S: aws.String("a")}},
+			},
+			LastEvaluatedKey: map[string]dynamodb.AttributeValue{"id": {S: aws.String("b")}},
+		},
+		{
+			Items: []map[string]dynamodb.AttributeValue{
+				{"id": {S: aws.String("c")}},
+			},
+			LastEvaluatedKey: map[string]dynamodb.AttributeValue{"id": {S: aws.String("c")}},
+		},
+	}
+
+	var calls int
+	mock := dynamodbMock{
+		scanRequestHandler: func(input *dynamodb.ScanInput) dynamodb.ScanRequest {
+			assert.NotNil(t, input.FilterExpression)
+			if calls == 0 {
+				assert.Equal(t, int64(2), *input.Limit)
+				assert.Nil(t, input.ExclusiveStartKey)
+			} else {
+				assert.Equal(t, int64(1), *input.Limit)
+				assert.Equal(t, "b", *input.ExclusiveStartKey["id"].S)
+			}
+
+			out := pages[calls]
+			calls++
+
+			return dynamodb.ScanRequest{
+				Request: &aws.Request{
+					Data:        out,
+					HTTPRequest: &http.Request{},
+				},
+			}
+		},
+	}
+
+	repo := New(mock, "test-table")
+
+	page, err := repo.Query(context.Background(), participant.Query{Org: aws.String("OrgA"), Limit: 2})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, calls)
+	assert.Len(t, page.Participants, 2)
+	assert.NotEmpty(t, page.Next)
+
+	key, err := decodeKey(page.Next, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "c", *key["id"].S)
+}
+
+func TestDynamo_Query_TooLarge(t *testing.T) {
+	repo, cleanup := newTestRepo(t, dynamotest.New())
+	defer cleanup()
+
+	d := repo.(*Dynamo)
+	d.maxQueryItems = 2
+
+	ctx := context.Background()
+	for _, name := range []string{"Anna", "Bob", "Carl"} {
+		_, err := d.Save(ctx, participant.Participant{Name: aws.String(name), Email: aws.String(name + "@testson.com"), Score: aws.Int(1)})
+		assert.NoError(t, err)
+	}
+
+	_, err := d.Query(ctx, participant.Query{Sort: participant.SortScore, Limit: 1})
+	assert.True(t, errors.Is(err, participant.ErrQueryTooLarge), "sorted queries should fail rather than read every match")
+
+	_, err = d.Query(ctx, participant.Query{})
+	assert.True(t, errors.Is(err, participant.ErrQueryTooLarge), "queries without limit should fail rather than read every match")
+
+	page, err := d.Query(ctx, participant.Query{Limit: 2})
+	assert.NoError(t, err)
+	assert.Len(t, page.Participants, 2)
+
+	page, err = d.Query(ctx, participant.Query{Sort: participant.SortScore, Limit: 1, MinScore: aws.Int(2)})
+	assert.NoError(t, err)
+	assert.Empty(t, page.Participants)
+
+	ps, err := d.GetAll(ctx)
+	assert.NoError(t, err)
+	assert.Len(t, ps, 3)
+}
+
+func TestDynamo_Query_InvalidCursor(t *testing.T) {
+	repo := New(dynamodbMock{}, "test-table")
+
+	_, err := repo.Query(context.Background(), participant.Query{Limit: 1, Cursor: "invalid!"})
+	assert.True(t, errors.Is(err, participant.ErrInvalidCursor))
+}
+
+func TestDecodeKey_Event(t *testing.T) {
+	cursor, err := encodeKey(map[string]dynamodb.AttributeValue{
+		"id":    {S: aws.String("a")},
+		"event": {S: aws.String("conf-a")},
+	})
+	assert.NoError(t, err)
+
+	key, err := decodeKey(cursor, aws.String("conf-a"))
+	assert.NoError(t, err)
+	assert.Equal(t, "conf-a", *key["event"].S)
+
+	_, err = decodeKey(cursor, aws.String("conf-b"))
+	assert.Equal(t, participant.ErrInvalidCursor, err, "cursor of another event")
+
+	_, err = decodeKey(cursor, nil)
+	assert.Equal(t, participant.ErrInvalidCursor, err, "cursor of an event used in a scan")
+}
+
+func TestFilterCondition(t *testing.T) {
+	_, ok := filterCondition(participant.Query{})
+	assert.False(t, ok)
+
+	cond, ok := filterCondition(participant.Query{Org: aws.String("OrgA"), MinScore: aws.Int(1), MaxScore: aws.Int(2)})
+	assert.True(t, ok)
+
+	exp, err := expression.NewBuilder().WithFilter(cond).Build()
+	assert.NoError(t, err)
+	assert.Len(t, exp.Values(), 3)
+}
@@ -0,0 +1,361 @@
+package dynamotest
+
+import (
+	"bytes"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"math/big"
+	"sort"
+	"strings"
+)
+
+// Attribute value types as named by DynamoDB.
+const (
+	typeS    = "S"
+	typeN    = "N"
+	typeB    = "B"
+	typeBOOL = "BOOL"
+	typeNULL = "NULL"
+	typeSS   = "SS"
+	typeNS   = "NS"
+	typeBS   = "BS"
+	typeL    = "L"
+	typeM    = "M"
+)
+
+// typeOf returns the DynamoDB type of v, or an empty string if no field is set.
+func typeOf(v dynamodb.AttributeValue) string {
+	switch {
+	case v.S != nil:
+		return typeS
+	case v.N != nil:
+		return typeN
+	case v.B != nil:
+		return typeB
+	case v.BOOL != nil:
+		return typeBOOL
+	case v.NULL != nil:
+		return typeNULL
+	case v.SS != nil:
+		return typeSS
+	case v.NS != nil:
+		return typeNS
+	case v.BS != nil:
+		return typeBS
+	case v.L != nil:
+		return typeL
+	case v.M != nil:
+		return typeM
+	default:
+		return ""
+	}
+}
+
+func copyItem(item map[string]dynamodb.AttributeValue) map[string]dynamodb.AttributeValue {
+	if item == nil {
+		return nil
+	}
+
+	cp := make(map[string]dynamodb.AttributeValue, len(item))
+	for k, v := range item {
+		cp[k] = copyValue(v)
+	}
+	return cp
+}
+
+// copyValue returns a deep copy of v, so values stored in a table are never shared with callers.
+func copyValue(v dynamodb.AttributeValue) dynamodb.AttributeValue {
+	var cp dynamodb.AttributeValue
+
+	if v.S != nil {
+		s := *v.S
+		cp.S = &s
+	}
+	if v.N != nil {
+		n := *v.N
+		cp.N = &n
+	}
+	if v.B != nil {
+		cp.B = append([]byte{}, v.B...)
+	}
+	if v.BOOL != nil {
+		b := *v.BOOL
+		cp.BOOL = &b
+	}
+	if v.NULL != nil {
+		b := *v.NULL
+		cp.NULL = &b
+	}
+	if v.SS != nil {
+		cp.SS = append([]string{}, v.SS...)
+	}
+	if v.NS != nil {
+		cp.NS = append([]string{}, v.NS...)
+	}
+	if v.BS != nil {
+		cp.BS = make([][]byte, len(v.BS))
+		for i, b := range v.BS {
+			cp.BS[i] = append([]byte{}, b...)
+		}
+	}
+	if v.L != nil {
+		cp.L = make([]dynamodb.AttributeValue, len(v.L))
+		for i, e := range v.L {
+			cp.L[i] = copyValue(e)
+		}
+	}
+	if v.M != nil {
+		cp.M = copyItem(v.M)
+	}
+
+	return cp
+}
+
+func parseNumber(n string) (*big.Rat, bool) {
+	return new(big.Rat).SetString(strings.TrimSpace(n))
+}
+
+func formatNumber(r *big.Rat) string {
+	if r.IsInt() {
+		return r.Num().String()
+	}
+
+	s := strings.TrimRight(r.FloatString(38), "0")
+	return strings.TrimSuffix(s, ".")
+}
+
+// compareNumbers compares two numbers. Unparsable numbers are rejected when entering the table, so they compare equal
+// here.
+func compareNumbers(a, b string) int {
+	ra, okA := parseNumber(a)
+	rb, okB := parseNumber(b)
+	if !okA || !okB {
+		return 0
+	}
+	return ra.Cmp(rb)
+}
+
+// compare orders two scalar values of the same type. Returns false if the values can't be ordered.
+func compare(a, b dynamodb.AttributeValue) (int, bool) {
+	switch {
+	case a.S != nil && b.S != nil:
+		return strings.Compare(*a.S, *b.S), true
+	case a.N != nil && b.N != nil:
+		return compareNumbers(*a.N, *b.N), true
+	case a.B != nil && b.B != nil:
+		return bytes.Compare(a.B, b.B), true
+	default:
+		return 0, false
+	}
+}
+
+// equal compares values the way DynamoDB does. Numbers are compared by value and sets regardless of order.
+func equal(a, b dynamodb.AttributeValue) bool {
+	ta := typeOf(a)
+	if ta != typeOf(b) {
+		return false
+	}
+
+	switch ta {
+	case typeS, typeN, typeB:
+		c, _ := compare(a, b)
+		return c == 0
+	case typeBOOL:
+		return *a.BOOL == *b.BOOL
+	case typeNULL:
+		return true
+	case typeSS:
+		return equalSets(a.SS, b.SS, func(x, y string) bool { return x == y })
+	case typeNS:
+		return equalSets(a.NS, b.NS, func(x, y string) bool { return compareNumbers(x, y) == 0 })
+	case typeBS:
+		return len(a.BS) == len(b.BS) && containsAllBytes(a.BS, b.BS)
+	case typeL:
+		if len(a.L) != len(b.L) {
+			return false
+		}
+		for i := range a.L {
+			if !equal(a.L[i], b.L[i]) {
+				return false
+			}
+		}
+		return true
+	case typeM:
+		if len(a.M) != len(b.M) {
+			return false
+		}
+		for k, v := range a.M {
+			w, ok := b.M[k]
+			if !ok || !equal(v, w) {
+				return false
+			}
+		}
+		return true
+	default:
+		return false
+	}
+}
+
+func equalSets(a, b []string, eq func(x, y string) bool) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for _, x := range a {
+		if !containsString(b, x, eq) {
+			return false
+		}
+	}
+	return true
+}
+
+func containsString(set []string, v string, eq func(x, y string) bool) bool {
+	for _, s := range set {
+		if eq(s, v) {
+			return true
+		}
+	}
+	return false
+}
+
+func containsAllBytes(set, values [][]byte) bool {
+	for _, v := range values {
+		if !containsBytes(set, v) {
+			return false
+		}
+	}
+	return true
+}
+
+func containsBytes(set [][]byte, v []byte) bool {
+	for _, b := range set {
+		if bytes.Equal(b, v) {
+			return true
+		}
+	}
+	return false
+}
+
+func numberEq(x, y string) bool {
+	return compareNumbers(x, y) == 0
+}
+
+func stringEq(x, y string) bool {
+	return x == y
+}
+
+// union adds the elements of b missing in a. Both values must be sets of the same type.
+func union(a, b dynamodb.AttributeValue) dynamodb.AttributeValue {
+	switch typeOf(a) {
+	case typeSS:
+		for _, s := range b.SS {
+			if !containsString(a.SS, s, stringEq) {
+				a.SS = append(a.SS, s)
+			}
+		}
+	case typeNS:
+		for _, n := range b.NS {
+			if !containsString(a.NS, n, numberEq) {
+				a.NS = append(a.NS, n)
+			}
+		}
+	case typeBS:
+		for _, v := range b.BS {
+			if !containsBytes(a.BS, v) {
+				a.BS = append(a.BS, v)
+			}
+		}
+	}
+	return a
+}
+
+// difference removes the elements of b from a. Both values must be sets of the same type. Returns false if the
+// resulting set is empty, as DynamoDB doesn't store empty sets.
+func difference(a, b dynamodb.AttributeValue) (dynamodb.AttributeValue, bool) {
+	var res dynamodb.AttributeValue
+
+	switch typeOf(a) {
+	case typeSS:
+		for _, s := range a.SS {
+			if !containsString(b.SS, s, stringEq) {
+				res.SS = append(res.SS, s)
+			}
+		}
+		return res, len(res.SS) > 0
+	case typeNS:
+		for _, n := range a.NS {
+			if !containsString(b.NS, n, numberEq) {
+				res.NS = append(res.NS, n)
+			}
+		}
+		return res, len(res.NS) > 0
+	case typeBS:
+		for _, v := range a.BS {
+			if !containsBytes(b.BS, v) {
+				res.BS = append(res.BS, v)
+			}
+		}
+		return res, len(res.BS) > 0
+	default:
+		return a, true
+	}
+}
+
+func isSet(v dynamodb.AttributeValue) bool {
+	t := typeOf(v)
+	return t == typeSS || t == typeNS || t == typeBS
+}
+
+// validValue checks that v is a value DynamoDB would accept. Returns a description of the problem if not.
+func validValue(v dynamodb.AttributeValue) string {
+	set := 0
+	for _, ok := range []bool{v.S != nil, v.N != nil, v.B != nil, v.BOOL != nil, v.NULL != nil, v.SS != nil,
+		v.NS != nil, v.BS != nil, v.L != nil, v.M != nil} {
+		if ok {
+			set++
+		}
+	}
+	if set != 1 {
+		return "Supplied AttributeValue has more than one datatypes set, must contain exactly one of the supported datatypes"
+	}
+
+	if isSet(v) && len(v.SS)+len(v.NS)+len(v.BS) == 0 {
+		return "An empty set is not allowed"
+	}
+
+	switch typeOf(v) {
+	case typeN:
+		if _, ok := parseNumber(*v.N); !ok {
+			return "A value provided cannot be converted into a number"
+		}
+	case typeNS:
+		for _, n := range v.NS {
+			if _, ok := parseNumber(n); !ok {
+				return "A value provided cannot be converted into a number"
+			}
+		}
+	case typeL:
+		for _, e := range v.L {
+			if msg := validValue(e); msg != "" {
+				return msg
+			}
+		}
+	case typeM:
+		for _, e := range v.M {
+			if msg := validValue(e); msg != "" {
+				return msg
+			}
+		}
+	}
+
+	return ""
+}
+
+// sortedNames returns the keys of m in order, for deterministic error messages.
+func sortedNames(m map[string]bool) []string {
+	names := make([]string, 0, len(m))
+	for k := range m {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,1044 @@
+// Package dynamotest provides an in-memory stand-in for DynamoDB, so code using dynamodbiface.ClientAPI can be tested
+// without AWS or network.
+//
+// Client is a regular dynamodb.Client where sending a request is replaced by executing it against in-memory tables.
+// Requests are validated by the SDK as usual, and responses and errors are returned the same way as from DynamoDB.
+// Supported operations are CreateTable, DescribeTable, DeleteTable, GetItem, PutItem, UpdateItem, DeleteItem,
+// TransactWriteItems, Scan and Query, including condition, update, filter, key condition and projection expressions.
+// Tables can have global secondary indexes projecting all attributes, which can be queried.
+// Other operations fail with an UnknownOperationException.
+//
+// Known differences from DynamoDB: reserved words are accepted as attribute names, local secondary indexes, scanning
+// an index, parallel scans and the legacy parameters replaced by expressions are not supported, and there are no item
+// size or throughput limits. Indexes are updated with the table, so reading them is always consistent. Scan returns
+// items ordered by key.
+package dynamotest
+
+import (
+	"context"
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/awserr"
+	"github.com/aws/aws-sdk-go-v2/aws/defaults"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/dynamodbiface"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Error codes returned by DynamoDB without a constant in the dynamodb package.
+const (
+	ErrCodeValidationException       = "ValidationException"
+	ErrCodeUnknownOperationException = "UnknownOperationException"
+)
+
+var _ dynamodbiface.ClientAPI = (*Client)(nil)
+
+// Client implements dynamodbiface.ClientAPI against in-memory tables. Safe for concurrent use.
+type Client struct {
+	*dynamodb.Client
+
+	mu     sync.Mutex
+	tables map[string]*table
+}
+
+// New returns a client without tables.
+func New() *Client {
+	c := &Client{
+		Client: dynamodb.New(aws.Config{
+			Region:           "local",
+			EndpointResolver: aws.ResolveWithEndpointURL("http://dynamotest.local"),
+			Credentials:      aws.NewStaticCredentialsProvider("dynamotest", "dynamotest", ""),
+		}),
+		tables: make(map[string]*table),
+	}
+
+	// Keep parameter validation, but execute requests instead of building, signing and sending them.
+	c.Handlers = aws.Handlers{}
+	c.Handlers.Validate.PushBackNamed(defaults.ValidateParametersHandler)
+	c.Handlers.Send.PushBack(c.send)
+
+	return c
+}
+
+// CreateTable creates a table with a hash key and an optional range key, ready for use. Shorthand for a
+// CreateTableRequest with string keys. Panics if the table exists, as it's meant for test setup.
+func (c *Client) CreateTable(name, hashKey, rangeKey string) {
+	attrs := []dynamodb.AttributeDefinition{{AttributeName: aws.String(hashKey), AttributeType: dynamodb.ScalarAttributeTypeS}}
+	schema := []dynamodb.KeySchemaElement{{AttributeName: aws.String(hashKey), KeyType: dynamodb.KeyTypeHash}}
+
+	if rangeKey != "" {
+		attrs = append(attrs, dynamodb.AttributeDefinition{AttributeName: aws.String(rangeKey), AttributeType: dynamodb.ScalarAttributeTypeS})
+		schema = append(schema, dynamodb.KeySchemaElement{AttributeName: aws.String(rangeKey), KeyType: dynamodb.KeyTypeRange})
+	}
+
+	_, err := c.CreateTableRequest(&dynamodb.CreateTableInput{
+		AttributeDefinitions: attrs,
+		KeySchema:            schema,
+		BillingMode:          dynamodb.BillingModePayPerRequest,
+		TableName:            &name,
+	}).Send(context.Background())
+	if err != nil {
+		panic(err)
+	}
+}
+
+func validationError(format string, args ...interface{}) error {
+	return awserr.New(ErrCodeValidationException, fmt.Sprintf(format, args...), nil)
+}
+
+func conditionFailed() error {
+	return awserr.New(dynamodb.ErrCodeConditionalCheckFailedException, "The conditional request failed", nil)
+}
+
+// send executes the request. Runs as the only send handler, so the result is returned as if it came from DynamoDB.
+func (c *Client) send(r *aws.Request) {
+	if err := r.Context().Err(); err != nil {
+		r.Error = awserr.New(aws.ErrCodeRequestCanceled, "request context canceled", err)
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	switch in := r.Params.(type) {
+	case *dynamodb.CreateTableInput:
+		r.Error = c.createTable(in, r.Data.(*dynamodb.CreateTableOutput))
+	case *dynamodb.DescribeTableInput:
+		r.Error = c.describeTable(in, r.Data.(*dynamodb.DescribeTableOutput))
+	case *dynamodb.DeleteTableInput:
+		r.Error = c.deleteTable(in, r.Data.(*dynamodb.DeleteTableOutput))
+	case *dynamodb.GetItemInput:
+		r.Error = c.getItem(in, r.Data.(*dynamodb.GetItemOutput))
+	case *dynamodb.PutItemInput:
+		r.Error = c.putItem(in, r.Data.(*dynamodb.PutItemOutput))
+	case *dynamodb.UpdateItemInput:
+		r.Error = c.updateItem(in, r.Data.(*dynamodb.UpdateItemOutput))
+	case *dynamodb.DeleteItemInput:
+		r.Error = c.deleteItem(in, r.Data.(*dynamodb.DeleteItemOutput))
+	case *dynamodb.TransactWriteItemsInput:
+		r.Error = c.transactWriteItems(in, r.Data.(*dynamodb.TransactWriteItemsOutput))
+	case *dynamodb.ScanInput:
+		r.Error = c.scan(in, r.Data.(*dynamodb.ScanOutput))
+	case *dynamodb.QueryInput:
+		r.Error = c.query(in, r.Data.(*dynamodb.QueryOutput))
+	default:
+		r.Error = awserr.New(ErrCodeUnknownOperationException,
+			fmt.Sprintf("operation %s is not supported by dynamotest", r.Operation.Name), nil)
+	}
+}
+
+// table holds the items of a table by their key.
+type table struct {
+	name     string
+	created  time.Time
+	attrs    []dynamodb.AttributeDefinition
+	schema   []dynamodb.KeySchemaElement
+	hashKey  string
+	rangeKey string
+	keyTypes map[string]string
+	indexes  map[string]*index
+	items    map[string]map[string]dynamodb.AttributeValue
+}
+
+func (c *Client) table(name *string) (*table, error) {
+	t, ok := c.tables[aws.StringValue(name)]
+	if !ok {
+		return nil, awserr.New(dynamodb.ErrCodeResourceNotFoundException, "Requested resource not found", nil)
+	}
+	return t, nil
+}
+
+func (t *table) description() *dynamodb.TableDescription {
+	desc := &dynamodb.TableDescription{
+		AttributeDefinitions: t.attrs,
+		CreationDateTime:     &t.created,
+		ItemCount:            aws.Int64(int64(len(t.items))),
+		KeySchema:            t.schema,
+		TableName:            aws.String(t.name),
+		TableStatus:          dynamodb.TableStatusActive,
+	}
+
+	for _, name := range t.indexNames() {
+		desc.GlobalSecondaryIndexes = append(desc.GlobalSecondaryIndexes, t.indexes[name].description())
+	}
+
+	return desc
+}
+
+func (t *table) indexNames() []string {
+	names := make([]string, 0, len(t.indexes))
+	for name := range t.indexes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (c *Client) createTable(in *dynamodb.CreateTableInput, out *dynamodb.CreateTableOutput) error {
+	name := aws.StringValue(in.TableName)
+	if _, exists := c.tables[name]; exists {
+		return awserr.New(dynamodb.ErrCodeResourceInUseException, "Table already exists: "+name, nil)
+	}
+
+	if len(in.LocalSecondaryIndexes) > 0 {
+		return validationError("Local secondary indexes are not supported by dynamotest")
+	}
+
+	defined := make(map[string]string)
+	for _, a := range in.AttributeDefinitions {
+		defined[aws.StringValue(a.AttributeName)] = string(a.AttributeType)
+	}
+
+	t := &table{
+		name:     name,
+		created:  time.Now(),
+		attrs:    in.AttributeDefinitions,
+		schema:   in.KeySchema,
+		keyTypes: make(map[string]string),
+		indexes:  make(map[string]*index),
+		items:    make(map[string]map[string]dynamodb.AttributeValue),
+	}
+
+	for _, k := range in.KeySchema {
+		attr := aws.StringValue(k.AttributeName)
+		typ, ok := defined[attr]
+		if !ok {
+			return validationError("Some index key attributes are not defined in AttributeDefinitions: %s", attr)
+		}
+		t.keyTypes[attr] = typ
+
+		if k.KeyType == dynamodb.KeyTypeHash && t.hashKey == "" {
+			t.hashKey = attr
+		} else if k.KeyType == dynamodb.KeyTypeRange && t.rangeKey == "" {
+			t.rangeKey = attr
+		} else {
+			return validationError("Invalid KeySchema: a table has one hash key and at most one range key")
+		}
+	}
+
+	if t.hashKey == "" {
+		return validationError("Invalid KeySchema: hash key is required")
+	}
+
+	used := make(map[string]bool)
+	for attr := range t.keyTypes {
+		used[attr] = true
+	}
+
+	for _, gsi := range in.GlobalSecondaryIndexes {
+		idx, err := newIndex(t, gsi, defined)
+		if err != nil {
+			return err
+		}
+		t.indexes[idx.name] = idx
+
+		for attr := range idx.keyTypes {
+			used[attr] = true
+		}
+	}
+
+	if len(defined) != len(used) {
+		return validationError("The number of attributes in key schema must match the number of attributes defined in attribute definitions")
+	}
+
+	c.tables[name] = t
+	out.TableDescription = t.description()
+
+	return nil
+}
+
+func (c *Client) describeTable(in *dynamodb.DescribeTableInput, out *dynamodb.DescribeTableOutput) error {
+	t, err := c.table(in.TableName)
+	if err != nil {
+		return err
+	}
+
+	out.Table = t.description()
+	return nil
+}
+
+func (c *Client) deleteTable(in *dynamodb.DeleteTableInput, out *dynamodb.DeleteTableOutput) error {
+	t, err := c.table(in.TableName)
+	if err != nil {
+		return err
+	}
+
+	delete(c.tables, t.name)
+	out.TableDescription = t.description()
+	out.TableDescription.TableStatus = dynamodb.TableStatusDeleting
+
+	return nil
+}
+
+// keyOf validates that key holds exactly the key attributes of the table and returns the identity used to store the
+// item.
+func (t *table) keyOf(key map[string]dynamodb.AttributeValue, exact bool) (string, error) {
+	if exact && len(key) != len(t.keyTypes) {
+		return "", validationError("The provided key element does not match the schema")
+	}
+
+	id := ""
+	for _, attr := range []string{t.hashKey, t.rangeKey} {
+		if attr == "" {
+			continue
+		}
+
+		v, ok := key[attr]
+		if !ok || typeOf(v) != t.keyTypes[attr] {
+			if exact {
+				return "", validationError("The provided key element does not match the schema")
+			}
+			return "", validationError("One or more parameter values were invalid: Missing the key %s in the item", attr)
+		}
+
+		switch {
+		case v.S != nil && *v.S == "":
+			return "", validationError("One or more parameter values are not valid. The AttributeValue for a key attribute cannot contain an empty string value. Key: %s", attr)
+		case v.B != nil && len(v.B) == 0:
+			return "", validationError("One or more parameter values are not valid. The AttributeValue for a key attribute cannot contain an empty binary value. Key: %s", attr)
+		case v.N != nil:
+			n, ok := parseNumber(*v.N)
+			if !ok {
+				return "", validationError("A value provided cannot be converted into a number")
+			}
+			id += fmt.Sprintf("%d:%s|", len(n.String()), n.String())
+		case v.S != nil:
+			id += fmt.Sprintf("%d:%s|", len(*v.S), *v.S)
+		default:
+			id += fmt.Sprintf("%d:%x|", len(v.B), v.B)
+		}
+	}
+
+	return id, nil
+}
+
+// checkKey validates that key holds exactly the key attributes of the table.
+func (t *table) checkKey(key map[string]dynamodb.AttributeValue) error {
+	_, err := t.keyOf(key, true)
+	return err
+}
+
+// checkIndexKeys fails if item can't be added to the indexes of the table.
+func (t *table) checkIndexKeys(item map[string]dynamodb.AttributeValue) error {
+	for _, name := range t.indexNames() {
+		if err := t.indexes[name].checkItem(item); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// key returns the key attributes of item.
+func (t *table) key(item map[string]dynamodb.AttributeValue) map[string]dynamodb.AttributeValue {
+	key := map[string]dynamodb.AttributeValue{t.hashKey: copyValue(item[t.hashKey])}
+	if t.rangeKey != "" {
+		key[t.rangeKey] = copyValue(item[t.rangeKey])
+	}
+	return key
+}
+
+// less orders items by hash key and then range key.
+func (t *table) less(a, b map[string]dynamodb.AttributeValue) bool {
+	if c, _ := compare(a[t.hashKey], b[t.hashKey]); c != 0 {
+		return c < 0
+	}
+	if t.rangeKey == "" {
+		return false
+	}
+	c, _ := compare(a[t.rangeKey], b[t.rangeKey])
+	return c < 0
+}
+
+func (t *table) sorted() []map[string]dynamodb.AttributeValue {
+	items := make([]map[string]dynamodb.AttributeValue, 0, len(t.items))
+	for _, item := range t.items {
+		items = append(items, item)
+	}
+	sort.Slice(items, func(i, j int) bool {
+		return t.less(items[i], items[j])
+	})
+	return items
+}
+
+func validateItem(item map[string]dynamodb.AttributeValue) error {
+	for k, v := range item {
+		if msg := validValue(v); msg != "" {
+			return validationError("One or more parameter values were invalid: %s for attribute %s", msg, k)
+		}
+	}
+	return nil
+}
+
+// parseConditionParam parses an optional condition expression. Returns nil if expr is nil.
+func parseConditionParam(expr *string, ctx *exprContext) (condition, error) {
+	if expr == nil {
+		return nil, nil
+	}
+	return parseCondition(*expr, ctx)
+}
+
+func parseProjectionParam(expr *string, ctx *exprContext) ([]path, error) {
+	if expr == nil {
+		return nil, nil
+	}
+	return parseProjection(*expr, ctx)
+}
+
+// checkCondition fails with ConditionalCheckFailedException if item doesn't match cond. A missing item is matched as
+// an item without attributes.
+func checkCondition(cond condition, item map[string]dynamodb.AttributeValue) error {
+	if cond != nil && !cond.match(item) {
+		return conditionFailed()
+	}
+	return nil
+}
+
+func (c *Client) getItem(in *dynamodb.GetItemInput, out *dynamodb.GetItemOutput) error {
+	if len(in.AttributesToGet) > 0 {
+		return validationError("AttributesToGet is not supported by dynamotest, use ProjectionExpression")
+	}
+
+	t, err := c.table(in.TableName)
+	if err != nil {
+		return err
+	}
+
+	ctx := newExprContext(in.ExpressionAttributeNames, nil)
+	projection, err := parseProjectionParam(in.ProjectionExpression, ctx)
+	if err != nil {
+		return err
+	}
+	if err := ctx.checkUnused(); err != nil {
+		return err
+	}
+
+	id, err := t.keyOf(in.Key, true)
+	if err != nil {
+		return err
+	}
+
+	if item, ok := t.items[id]; ok {
+		out.Item, err = project(item, projection)
+	}
+
+	return err
+}
+
+// write is a change to a single item, prepared and checked against its condition before being committed. Preparing
+// doesn't modify the table, so a transaction can prepare all its writes before committing any.
+type write struct {
+	table   *table
+	id      string
+	old     map[string]dynamodb.AttributeValue
+	item    map[string]dynamodb.AttributeValue
+	remove  bool
+	changed []path
+}
+
+func (w *write) commit() {
+	switch {
+	case w.remove:
+		delete(w.table.items, w.id)
+	case w.item != nil:
+		w.table.items[w.id] = w.item
+	}
+}
+
+// writeParams are the parameters shared by the single item writes, and the corresponding transaction actions.
+type writeParams struct {
+	table     *string
+	key       map[string]dynamodb.AttributeValue
+	item      map[string]dynamodb.AttributeValue
+	condition *string
+	update    *string
+	names     map[string]string
+	values    map[string]dynamodb.AttributeValue
+}
+
+// preparePut replaces the item. A failed condition returns the write along with ConditionalCheckFailedException.
+func (c *Client) preparePut(p writeParams) (*write, error) {
+	t, err := c.table(p.table)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx := newExprContext(p.names, p.values)
+	cond, err := parseConditionParam(p.condition, ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := ctx.checkUnused(); err != nil {
+		return nil, err
+	}
+
+	if err := validateItem(p.item); err != nil {
+		return nil, err
+	}
+
+	if err := t.checkIndexKeys(p.item); err != nil {
+		return nil, err
+	}
+
+	id, err := t.keyOf(p.item, false)
+	if err != nil {
+		return nil, err
+	}
+
+	w := &write{table: t, id: id, old: t.items[id], item: copyItem(p.item)}
+	return w, checkCondition(cond, w.old)
+}
+
+// prepareUpdate applies an update expression to the item, creating it from the key if missing.
+func (c *Client) prepareUpdate(p writeParams) (*write, error) {
+	t, err := c.table(p.table)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx := newExprContext(p.names, p.values)
+	cond, err := parseConditionParam(p.condition, ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var upd *update
+	if p.update != nil {
+		if upd, err = parseUpdate(*p.update, ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := ctx.checkUnused(); err != nil {
+		return nil, err
+	}
+
+	id, err := t.keyOf(p.key, true)
+	if err != nil {
+		return nil, err
+	}
+
+	w := &write{table: t, id: id, old: t.items[id]}
+	if err := checkCondition(cond, w.old); err != nil {
+		return w, err
+	}
+
+	// A missing item is created from the key.
+	base := w.old
+	if base == nil {
+		base = copyItem(p.key)
+	}
+
+	w.item = copyItem(base)
+	if upd != nil {
+		for _, pth := range upd.paths() {
+			if _, isKey := t.keyTypes[pth[0].name]; isKey {
+				return nil, validationError("One or more parameter values were invalid: Cannot update attribute %s. This attribute is part of the key", pth[0].name)
+			}
+		}
+
+		if w.item, err = upd.apply(base); err != nil {
+			return nil, err
+		}
+		w.changed = upd.paths()
+	}
+
+	if err := validateItem(w.item); err != nil {
+		return nil, err
+	}
+
+	if err := t.checkIndexKeys(w.item); err != nil {
+		return nil, err
+	}
+
+	return w, nil
+}
+
+// prepareDelete removes the item. With remove false only the condition is checked, as for a ConditionCheck.
+func (c *Client) prepareDelete(p writeParams, remove bool) (*write, error) {
+	t, err := c.table(p.table)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx := newExprContext(p.names, p.values)
+	cond, err := parseConditionParam(p.condition, ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := ctx.checkUnused(); err != nil {
+		return nil, err
+	}
+
+	id, err := t.keyOf(p.key, true)
+	if err != nil {
+		return nil, err
+	}
+
+	w := &write{table: t, id: id, old: t.items[id], remove: remove}
+	return w, checkCondition(cond, w.old)
+}
+
+func (c *Client) putItem(in *dynamodb.PutItemInput, out *dynamodb.PutItemOutput) error {
+	if len(in.Expected) > 0 {
+		return validationError("Expected is not supported by dynamotest, use ConditionExpression")
+	}
+
+	w, err := c.preparePut(writeParams{
+		table:     in.TableName,
+		item:      in.Item,
+		condition: in.ConditionExpression,
+		names:     in.ExpressionAttributeNames,
+		values:    in.ExpressionAttributeValues,
+	})
+	if err != nil {
+		return err
+	}
+
+	switch in.ReturnValues {
+	case dynamodb.ReturnValueNone, "":
+	case dynamodb.ReturnValueAllOld:
+		out.Attributes = copyItem(w.old)
+	default:
+		return validationError("ReturnValues can only be ALL_OLD or NONE")
+	}
+
+	w.commit()
+
+	return nil
+}
+
+func (c *Client) updateItem(in *dynamodb.UpdateItemInput, out *dynamodb.UpdateItemOutput) error {
+	if len(in.Expected) > 0 || len(in.AttributeUpdates) > 0 {
+		return validationError("Expected and AttributeUpdates are not supported by dynamotest, use expressions")
+	}
+
+	w, err := c.prepareUpdate(writeParams{
+		table:     in.TableName,
+		key:       in.Key,
+		condition: in.ConditionExpression,
+		update:    in.UpdateExpression,
+		names:     in.ExpressionAttributeNames,
+		values:    in.ExpressionAttributeValues,
+	})
+	if err != nil {
+		return err
+	}
+
+	switch in.ReturnValues {
+	case dynamodb.ReturnValueNone, "":
+	case dynamodb.ReturnValueAllOld:
+		out.Attributes = copyItem(w.old)
+	case dynamodb.ReturnValueAllNew:
+		out.Attributes = copyItem(w.item)
+	case dynamodb.ReturnValueUpdatedOld:
+		out.Attributes = topLevel(w.old, w.changed)
+	case dynamodb.ReturnValueUpdatedNew:
+		out.Attributes = topLevel(w.item, w.changed)
+	default:
+		return validationError("Invalid ReturnValues: %s", in.ReturnValues)
+	}
+
+	w.commit()
+
+	return nil
+}
+
+// topLevel copies the top level attributes of item touched by paths.
+func topLevel(item map[string]dynamodb.AttributeValue, paths []path) map[string]dynamodb.AttributeValue {
+	res := make(map[string]dynamodb.AttributeValue)
+	for _, pth := range paths {
+		if v, ok := item[pth[0].name]; ok {
+			res[pth[0].name] = copyValue(v)
+		}
+	}
+
+	if len(res) == 0 {
+		return nil
+	}
+	return res
+}
+
+func (c *Client) deleteItem(in *dynamodb.DeleteItemInput, out *dynamodb.DeleteItemOutput) error {
+	if len(in.Expected) > 0 {
+		return validationError("Expected is not supported by dynamotest, use ConditionExpression")
+	}
+
+	w, err := c.prepareDelete(writeParams{
+		table:     in.TableName,
+		key:       in.Key,
+		condition: in.ConditionExpression,
+		names:     in.ExpressionAttributeNames,
+		values:    in.ExpressionAttributeValues,
+	}, true)
+	if err != nil {
+		return err
+	}
+
+	switch in.ReturnValues {
+	case dynamodb.ReturnValueNone, "":
+	case dynamodb.ReturnValueAllOld:
+		out.Attributes = copyItem(w.old)
+	default:
+		return validationError("ReturnValues can only be ALL_OLD or NONE")
+	}
+
+	w.commit()
+
+	return nil
+}
+
+// transactWriteItems prepares every action before committing any. If a condition fails, the transaction is cancelled
+// with the reason of each action in the message, like DynamoDB does.
+func (c *Client) transactWriteItems(in *dynamodb.TransactWriteItemsInput, out *dynamodb.TransactWriteItemsOutput) error {
+	writes := make([]*write, 0, len(in.TransactItems))
+	reasons := make([]string, 0, len(in.TransactItems))
+	cancelled := false
+	seen := make(map[string]bool)
+
+	for _, item := range in.TransactItems {
+		var (
+			w   *write
+			err error
+		)
+
+		switch {
+		case item.Put != nil:
+			w, err = c.preparePut(writeParams{
+				table:     item.Put.TableName,
+				item:      item.Put.Item,
+				condition: item.Put.ConditionExpression,
+				names:     item.Put.ExpressionAttributeNames,
+				values:    item.Put.ExpressionAttributeValues,
+			})
+		case item.Update != nil:
+			w, err = c.prepareUpdate(writeParams{
+				table:     item.Update.TableName,
+				key:       item.Update.Key,
+				condition: item.Update.ConditionExpression,
+				update:    item.Update.UpdateExpression,
+				names:     item.Update.ExpressionAttributeNames,
+				values:    item.Update.ExpressionAttributeValues,
+			})
+		case item.Delete != nil:
+			w, err = c.prepareDelete(writeParams{
+				table:     item.Delete.TableName,
+				key:       item.Delete.Key,
+				condition: item.Delete.ConditionExpression,
+				names:     item.Delete.ExpressionAttributeNames,
+				values:    item.Delete.ExpressionAttributeValues,
+			}, true)
+		case item.ConditionCheck != nil:
+			w, err = c.prepareDelete(writeParams{
+				table:     item.ConditionCheck.TableName,
+				key:       item.ConditionCheck.Key,
+				condition: item.ConditionCheck.ConditionExpression,
+				names:     item.ConditionCheck.ExpressionAttributeNames,
+				values:    item.ConditionCheck.ExpressionAttributeValues,
+			}, false)
+		default:
+			return validationError("TransactItems can only contain one of Check, Put, Update or Delete")
+		}
+
+		if aerr, ok := err.(awserr.Error); ok && aerr.Code() == dynamodb.ErrCodeConditionalCheckFailedException {
+			cancelled = true
+			reasons = append(reasons, "ConditionalCheckFailed")
+		} else if err != nil {
+			return err
+		} else {
+			reasons = append(reasons, "None")
+		}
+
+		target := w.table.name + "|" + w.id
+		if seen[target] {
+			return validationError("Transaction request cannot include multiple operations on one item")
+		}
+		seen[target] = true
+
+		writes = append(writes, w)
+	}
+
+	if cancelled {
+		return awserr.New(dynamodb.ErrCodeTransactionCanceledException,
+			fmt.Sprintf("Transaction cancelled, please refer cancellation reasons for specific reasons [%s]", strings.Join(reasons, ", ")), nil)
+	}
+
+	for _, w := range writes {
+		w.commit()
+	}
+
+	return nil
+}
+
+// readParams are the parameters shared by Scan and Query.
+type readParams struct {
+	limit      *int64
+	startKey   map[string]dynamodb.AttributeValue
+	filter     condition
+	projection []path
+	count      bool
+}
+
+// keyAttrs are the key attributes of the table or an index being read.
+type keyAttrs interface {
+	// key returns the attributes identifying item, as returned in LastEvaluatedKey.
+	key(item map[string]dynamodb.AttributeValue) map[string]dynamodb.AttributeValue
+	// checkKey validates an ExclusiveStartKey.
+	checkKey(key map[string]dynamodb.AttributeValue) error
+}
+
+// page reads items in order, starting after startKey. Limit counts items read before the filter is applied, like
+// DynamoDB. LastEvaluatedKey is set when the limit stops the read, even if no items remain.
+func page(items []map[string]dynamodb.AttributeValue, p readParams, less func(a, b map[string]dynamodb.AttributeValue) bool, keys keyAttrs) (res []map[string]dynamodb.AttributeValue, scanned int64, last map[string]dynamodb.AttributeValue, err error) {
+	start := 0
+	if p.startKey != nil {
+		if err := keys.checkKey(p.startKey); err != nil {
+			return nil, 0, nil, validationError("The provided starting key is invalid: %s", err.(awserr.Error).Message())
+		}
+		start = sort.Search(len(items), func(i int) bool {
+			return less(p.startKey, items[i])
+		})
+	}
+
+	if p.limit != nil && *p.limit <= 0 {
+		return nil, 0, nil, validationError("Limit must be greater than or equal to 1")
+	}
+
+	res = make([]map[string]dynamodb.AttributeValue, 0)
+	for i := start; i < len(items); i++ {
+		scanned++
+
+		if p.filter == nil || p.filter.match(items[i]) {
+			item, err := project(items[i], p.projection)
+			if err != nil {
+				return nil, 0, nil, err
+			}
+			res = append(res, item)
+		}
+
+		if p.limit != nil && scanned == *p.limit {
+			last = keys.key(items[i])
+			break
+		}
+	}
+
+	return res, scanned, last, nil
+}
+
+func parseSelect(s dynamodb.Select, projection *string) (bool, error) {
+	switch s {
+	case "", dynamodb.SelectAllAttributes:
+		return false, nil
+	case dynamodb.SelectCount:
+		if projection != nil {
+			return false, validationError("Cannot specify the ProjectionExpression when choosing to get only the Count")
+		}
+		return true, nil
+	case dynamodb.SelectSpecificAttributes:
+		return false, nil
+	default:
+		return false, validationError("Select %s is not supported by dynamotest", s)
+	}
+}
+
+func (c *Client) scan(in *dynamodb.ScanInput, out *dynamodb.ScanOutput) error {
+	if len(in.ScanFilter) > 0 || len(in.AttributesToGet) > 0 {
+		return validationError("ScanFilter and AttributesToGet are not supported by dynamotest, use expressions")
+	}
+	if in.IndexName != nil {
+		return validationError("Secondary indexes are not supported by dynamotest")
+	}
+	if in.TotalSegments != nil || in.Segment != nil {
+		return validationError("Parallel scans are not supported by dynamotest")
+	}
+
+	t, err := c.table(in.TableName)
+	if err != nil {
+		return err
+	}
+
+	ctx := newExprContext(in.ExpressionAttributeNames, in.ExpressionAttributeValues)
+	p := readParams{limit: in.Limit, startKey: in.ExclusiveStartKey}
+
+	if p.filter, err = parseConditionParam(in.FilterExpression, ctx); err != nil {
+		return err
+	}
+	if p.projection, err = parseProjectionParam(in.ProjectionExpression, ctx); err != nil {
+		return err
+	}
+	if p.count, err = parseSelect(in.Select, in.ProjectionExpression); err != nil {
+		return err
+	}
+	if err := ctx.checkUnused(); err != nil {
+		return err
+	}
+
+	items, scanned, last, err := page(t.sorted(), p, t.less, t)
+	if err != nil {
+		return err
+	}
+
+	if !p.count {
+		out.Items = items
+	}
+	out.Count = aws.Int64(int64(len(items)))
+	out.ScannedCount = aws.Int64(scanned)
+	out.LastEvaluatedKey = last
+
+	return nil
+}
+
+func (c *Client) query(in *dynamodb.QueryInput, out *dynamodb.QueryOutput) error {
+	if len(in.KeyConditions) > 0 || len(in.QueryFilter) > 0 || len(in.AttributesToGet) > 0 {
+		return validationError("KeyConditions, QueryFilter and AttributesToGet are not supported by dynamotest, use expressions")
+	}
+	if in.KeyConditionExpression == nil {
+		return validationError("Either the KeyConditions or KeyConditionExpression parameter must be specified in the request")
+	}
+
+	t, err := c.table(in.TableName)
+	if err != nil {
+		return err
+	}
+
+	// Queries read the table, or the items in an index ordered by the index key.
+	hashKey, rangeKey := t.hashKey, t.rangeKey
+	var keys keyAttrs = t
+	order := t.less
+	contains := func(map[string]dynamodb.AttributeValue) bool { return true }
+
+	if in.IndexName != nil {
+		idx, ok := t.indexes[*in.IndexName]
+		if !ok {
+			return validationError("The table does not have the specified index: %s", *in.IndexName)
+		}
+		if aws.BoolValue(in.ConsistentRead) {
+			return validationError("Consistent reads are not supported on global secondary indexes")
+		}
+
+		hashKey, rangeKey = idx.hashKey, idx.rangeKey
+		keys = idx
+		order = idx.less
+		contains = idx.contains
+	}
+
+	ctx := newExprContext(in.ExpressionAttributeNames, in.ExpressionAttributeValues)
+	keyCond, err := parseCondition(*in.KeyConditionExpression, ctx)
+	if err != nil {
+		return err
+	}
+	if err := checkKeyCondition(keyCond, hashKey, rangeKey); err != nil {
+		return err
+	}
+
+	p := readParams{limit: in.Limit, startKey: in.ExclusiveStartKey}
+	if p.filter, err = parseConditionParam(in.FilterExpression, ctx); err != nil {
+		return err
+	}
+	if p.projection, err = parseProjectionParam(in.ProjectionExpression, ctx); err != nil {
+		return err
+	}
+	if p.count, err = parseSelect(in.Select, in.ProjectionExpression); err != nil {
+		return err
+	}
+	if err := ctx.checkUnused(); err != nil {
+		return err
+	}
+
+	less := order
+	if in.ScanIndexForward != nil && !*in.ScanIndexForward {
+		less = func(a, b map[string]dynamodb.AttributeValue) bool {
+			return order(b, a)
+		}
+	}
+
+	var matched []map[string]dynamodb.AttributeValue
+	for _, item := range t.items {
+		if contains(item) && keyCond.match(item) {
+			matched = append(matched, item)
+		}
+	}
+	sort.Slice(matched, func(i, j int) bool {
+		return less(matched[i], matched[j])
+	})
+
+	items, scanned, last, err := page(matched, p, less, keys)
+	if err != nil {
+		return err
+	}
+
+	if !p.count {
+		out.Items = items
+	}
+	out.Count = aws.Int64(int64(len(items)))
+	out.ScannedCount = aws.Int64(scanned)
+	out.LastEvaluatedKey = last
+
+	return nil
+}
+
+// checkKeyCondition verifies that cond is an equality on the hash key, optionally combined with a single condition
+// on the range key.
+func checkKeyCondition(cond condition, hashKey, rangeKey string) error {
+	invalid := validationError("Query key condition not supported")
+
+	var conds []condition
+	if and, ok := cond.(andCondition); ok {
+		conds = []condition{and.left, and.right}
+	} else {
+		conds = []condition{cond}
+	}
+
+	hash, ranged := false, false
+	for _, c := range conds {
+		attr, op, ok := keyConditionAttr(c)
+		if !ok {
+			return invalid
+		}
+
+		switch {
+		case attr == hashKey && op == "=" && !hash:
+			hash = true
+		case attr == rangeKey && rangeKey != "" && !ranged && op != "<>":
+			ranged = true
+		default:
+			return invalid
+		}
+	}
+
+	if !hash {
+		return validationError("Query condition missed key schema element: %s", hashKey)
+	}
+
+	return nil
+}
+
+// keyConditionAttr returns the attribute and operator of a condition allowed in a key condition expression.
+func keyConditionAttr(cond condition) (string, string, bool) {
+	switch c := cond.(type) {
+	case comparison:
+		p, ok := c.left.(pathOperand)
+		if !ok || len(p.path) != 1 {
+			return "", "", false
+		}
+		if _, ok := c.right.(valueOperand); !ok {
+			return "", "", false
+		}
+		return p.path[0].name, c.op, true
+	case between:
+		p, ok := c.value.(pathOperand)
+		if !ok || len(p.path) != 1 {
+			return "", "", false
+		}
+		return p.path[0].name, "BETWEEN", true
+	case function:
+		if c.name != "begins_with" || len(c.path) != 1 {
+			return "", "", false
+		}
+		return c.path[0].name, "begins_with", true
+	default:
+		return "", "", false
+	}
+}
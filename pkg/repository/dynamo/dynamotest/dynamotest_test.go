@@ -0,0 +1,678 @@
+package dynamotest
+
+import (
+	"context"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/awserr"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/stretchr/testify/assert"
+	"strconv"
+	"testing"
+)
+
+func str(s string) dynamodb.AttributeValue {
+	return dynamodb.AttributeValue{S: &s}
+}
+
+func num(n int) dynamodb.AttributeValue {
+	s := strconv.Itoa(n)
+	return dynamodb.AttributeValue{N: &s}
+}
+
+func errCode(err error) string {
+	if aerr, ok := err.(awserr.Error); ok {
+		return aerr.Code()
+	}
+	return ""
+}
+
+func put(t *testing.T, c *Client, table string, item map[string]dynamodb.AttributeValue) {
+	_, err := c.PutItemRequest(&dynamodb.PutItemInput{TableName: &table, Item: item}).Send(context.Background())
+	assert.NoError(t, err)
+}
+
+func get(t *testing.T, c *Client, table string, key map[string]dynamodb.AttributeValue) map[string]dynamodb.AttributeValue {
+	res, err := c.GetItemRequest(&dynamodb.GetItemInput{TableName: &table, Key: key}).Send(context.Background())
+	assert.NoError(t, err)
+	return res.Item
+}
+
+func TestClient_TableNotFound(t *testing.T) {
+	c := New()
+
+	_, err := c.GetItemRequest(&dynamodb.GetItemInput{
+		TableName: aws.String("missing"),
+		Key:       map[string]dynamodb.AttributeValue{"id": str("a")},
+	}).Send(context.Background())
+	assert.Equal(t, dynamodb.ErrCodeResourceNotFoundException, errCode(err))
+}
+
+func TestClient_CreateTableExists(t *testing.T) {
+	c := New()
+	c.CreateTable("test", "id", "")
+
+	assert.Panics(t, func() { c.CreateTable("test", "id", "") })
+}
+
+func TestClient_UnsupportedOperation(t *testing.T) {
+	c := New()
+
+	_, err := c.ListTablesRequest(&dynamodb.ListTablesInput{}).Send(context.Background())
+	assert.Equal(t, ErrCodeUnknownOperationException, errCode(err))
+}
+
+func TestClient_CancelledContext(t *testing.T) {
+	c := New()
+	c.CreateTable("test", "id", "")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := c.PutItemRequest(&dynamodb.PutItemInput{
+		TableName: aws.String("test"),
+		Item:      map[string]dynamodb.AttributeValue{"id": str("a")},
+	}).Send(ctx)
+	assert.Equal(t, aws.ErrCodeRequestCanceled, errCode(err))
+	assert.Nil(t, get(t, c, "test", map[string]dynamodb.AttributeValue{"id": str("a")}))
+}
+
+func TestClient_KeyValidation(t *testing.T) {
+	c := New()
+	c.CreateTable("test", "id", "")
+
+	tests := []struct {
+		name string
+		key  map[string]dynamodb.AttributeValue
+	}{
+		{"Missing", map[string]dynamodb.AttributeValue{}},
+		{"WrongType", map[string]dynamodb.AttributeValue{"id": num(1)}},
+		{"Empty", map[string]dynamodb.AttributeValue{"id": str("")}},
+		{"ExtraAttribute", map[string]dynamodb.AttributeValue{"id": str("a"), "other": str("b")}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := c.GetItemRequest(&dynamodb.GetItemInput{TableName: aws.String("test"), Key: test.key}).Send(context.Background())
+			assert.Equal(t, ErrCodeValidationException, errCode(err))
+		})
+	}
+}
+
+func TestClient_PutItem_Condition(t *testing.T) {
+	c := New()
+	c.CreateTable("test", "id", "")
+
+	input := &dynamodb.PutItemInput{
+		TableName:                aws.String("test"),
+		Item:                     map[string]dynamodb.AttributeValue{"id": str("a"), "v": num(1)},
+		ConditionExpression:      aws.String("attribute_not_exists(#id)"),
+		ExpressionAttributeNames: map[string]string{"#id": "id"},
+	}
+
+	_, err := c.PutItemRequest(input).Send(context.Background())
+	assert.NoError(t, err)
+
+	input.Item = map[string]dynamodb.AttributeValue{"id": str("a"), "v": num(2)}
+	_, err = c.PutItemRequest(input).Send(context.Background())
+	assert.Equal(t, dynamodb.ErrCodeConditionalCheckFailedException, errCode(err))
+
+	assert.Equal(t, num(1), get(t, c, "test", map[string]dynamodb.AttributeValue{"id": str("a")})["v"])
+}
+
+func TestClient_ItemsNotShared(t *testing.T) {
+	c := New()
+	c.CreateTable("test", "id", "")
+
+	item := map[string]dynamodb.AttributeValue{"id": str("a"), "l": {L: []dynamodb.AttributeValue{str("x")}}}
+	put(t, c, "test", item)
+	item["l"].L[0] = str("changed")
+
+	got := get(t, c, "test", map[string]dynamodb.AttributeValue{"id": str("a")})
+	assert.Equal(t, "x", *got["l"].L[0].S)
+
+	got["l"].L[0] = str("changed")
+	got = get(t, c, "test", map[string]dynamodb.AttributeValue{"id": str("a")})
+	assert.Equal(t, "x", *got["l"].L[0].S)
+}
+
+func TestClient_UpdateItem(t *testing.T) {
+	tests := []struct {
+		name      string
+		update    string
+		condition string
+		values    map[string]dynamodb.AttributeValue
+		expected  map[string]dynamodb.AttributeValue
+		errCode   string
+	}{
+		{
+			name:     "Set",
+			update:   "SET s = :s, n = :n",
+			values:   map[string]dynamodb.AttributeValue{":s": str("new"), ":n": num(5)},
+			expected: map[string]dynamodb.AttributeValue{"s": str("new"), "n": num(5)},
+		},
+		{
+			name:     "IfNotExists",
+			update:   "SET n = if_not_exists(n, :n), created = if_not_exists(created, :n)",
+			values:   map[string]dynamodb.AttributeValue{":n": num(5)},
+			expected: map[string]dynamodb.AttributeValue{"n": num(1), "created": num(5)},
+		},
+		{
+			name:     "Arithmetic",
+			update:   "SET n = n + :n, m = :n - n",
+			values:   map[string]dynamodb.AttributeValue{":n": num(5)},
+			expected: map[string]dynamodb.AttributeValue{"n": num(6), "m": num(4)},
+		},
+		{
+			name:     "ListAppend",
+			update:   "SET l = list_append(l, :l)",
+			values:   map[string]dynamodb.AttributeValue{":l": {L: []dynamodb.AttributeValue{num(3)}}},
+			expected: map[string]dynamodb.AttributeValue{"l": {L: []dynamodb.AttributeValue{num(1), num(2), num(3)}}},
+		},
+		{
+			name:     "NestedAndIndex",
+			update:   "SET m.inner = :s, l[0] = :s REMOVE l[1]",
+			values:   map[string]dynamodb.AttributeValue{":s": str("x")},
+			expected: map[string]dynamodb.AttributeValue{"m": {M: map[string]dynamodb.AttributeValue{"inner": str("x")}}, "l": {L: []dynamodb.AttributeValue{str("x")}}},
+		},
+		{
+			name:     "Remove",
+			update:   "REMOVE s, missing",
+			expected: map[string]dynamodb.AttributeValue{"s": {}},
+		},
+		{
+			name:     "AddAndDelete",
+			update:   "ADD n :n, ss :add DELETE tags :del",
+			values:   map[string]dynamodb.AttributeValue{":n": num(2), ":add": {SS: []string{"a"}}, ":del": {SS: []string{"x", "y"}}},
+			expected: map[string]dynamodb.AttributeValue{"n": num(3), "ss": {SS: []string{"a"}}, "tags": {}},
+		},
+		{
+			name:      "ConditionFailed",
+			update:    "SET s = :s",
+			condition: "n > :n",
+			values:    map[string]dynamodb.AttributeValue{":s": str("new"), ":n": num(1)},
+			errCode:   dynamodb.ErrCodeConditionalCheckFailedException,
+		},
+		{
+			name:    "KeyAttribute",
+			update:  "SET id = :s",
+			values:  map[string]dynamodb.AttributeValue{":s": str("b")},
+			errCode: ErrCodeValidationException,
+		},
+		{
+			name:    "Overlap",
+			update:  "SET m.inner = :s, m = :s",
+			values:  map[string]dynamodb.AttributeValue{":s": str("x")},
+			errCode: ErrCodeValidationException,
+		},
+		{
+			name:    "WrongType",
+			update:  "SET n = s + :n",
+			values:  map[string]dynamodb.AttributeValue{":n": num(1)},
+			errCode: ErrCodeValidationException,
+		},
+		{
+			name:    "UnusedValue",
+			update:  "SET s = :s",
+			values:  map[string]dynamodb.AttributeValue{":s": str("x"), ":unused": str("y")},
+			errCode: ErrCodeValidationException,
+		},
+		{
+			name:    "UndefinedValue",
+			update:  "SET s = :s",
+			errCode: ErrCodeValidationException,
+		},
+		{
+			name:    "SyntaxError",
+			update:  "SET s = = :s",
+			values:  map[string]dynamodb.AttributeValue{":s": str("x")},
+			errCode: ErrCodeValidationException,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := New()
+			c.CreateTable("test", "id", "")
+
+			key := map[string]dynamodb.AttributeValue{"id": str("a")}
+			original := map[string]dynamodb.AttributeValue{
+				"id":   str("a"),
+				"s":    str("old"),
+				"n":    num(1),
+				"l":    {L: []dynamodb.AttributeValue{num(1), num(2)}},
+				"m":    {M: map[string]dynamodb.AttributeValue{}},
+				"tags": {SS: []string{"x"}},
+			}
+			put(t, c, "test", original)
+
+			input := &dynamodb.UpdateItemInput{
+				TableName:                 aws.String("test"),
+				Key:                       key,
+				UpdateExpression:          &test.update,
+				ExpressionAttributeValues: test.values,
+				ReturnValues:              dynamodb.ReturnValueAllNew,
+			}
+			if test.condition != "" {
+				input.ConditionExpression = &test.condition
+			}
+
+			res, err := c.UpdateItemRequest(input).Send(context.Background())
+			if test.errCode != "" {
+				assert.Equal(t, test.errCode, errCode(err))
+				assert.Equal(t, original, get(t, c, "test", key), "failed update should not change item")
+				return
+			}
+			assert.NoError(t, err)
+
+			// An empty expected value means the attribute is removed.
+			for k, v := range test.expected {
+				if typeOf(v) == "" {
+					assert.NotContains(t, res.Attributes, k)
+					continue
+				}
+				assert.True(t, equal(v, res.Attributes[k]), "%s: expected %v, got %v", k, v, res.Attributes[k])
+			}
+
+			assert.Equal(t, res.Attributes, get(t, c, "test", key))
+		})
+	}
+}
+
+func TestClient_UpdateItem_CreatesItem(t *testing.T) {
+	c := New()
+	c.CreateTable("test", "id", "")
+
+	key := map[string]dynamodb.AttributeValue{"id": str("a")}
+	res, err := c.UpdateItemRequest(&dynamodb.UpdateItemInput{
+		TableName:                 aws.String("test"),
+		Key:                       key,
+		UpdateExpression:          aws.String("SET v = :v"),
+		ExpressionAttributeValues: map[string]dynamodb.AttributeValue{":v": num(1)},
+		ReturnValues:              dynamodb.ReturnValueAllOld,
+	}).Send(context.Background())
+	assert.NoError(t, err)
+	assert.Nil(t, res.Attributes)
+
+	assert.Equal(t, map[string]dynamodb.AttributeValue{"id": str("a"), "v": num(1)}, get(t, c, "test", key))
+}
+
+func TestClient_DeleteItem(t *testing.T) {
+	c := New()
+	c.CreateTable("test", "id", "")
+
+	key := map[string]dynamodb.AttributeValue{"id": str("a")}
+	input := &dynamodb.DeleteItemInput{
+		TableName:           aws.String("test"),
+		Key:                 key,
+		ConditionExpression: aws.String("attribute_exists(id)"),
+		ReturnValues:        dynamodb.ReturnValueAllOld,
+	}
+
+	_, err := c.DeleteItemRequest(input).Send(context.Background())
+	assert.Equal(t, dynamodb.ErrCodeConditionalCheckFailedException, errCode(err))
+
+	put(t, c, "test", map[string]dynamodb.AttributeValue{"id": str("a"), "v": num(1)})
+
+	res, err := c.DeleteItemRequest(input).Send(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, num(1), res.Attributes["v"])
+	assert.Nil(t, get(t, c, "test", key))
+}
+
+func TestClient_TransactWriteItems(t *testing.T) {
+	c := New()
+	c.CreateTable("test", "id", "")
+
+	put(t, c, "test", map[string]dynamodb.AttributeValue{"id": str("a"), "v": num(1)})
+	put(t, c, "test", map[string]dynamodb.AttributeValue{"id": str("c")})
+
+	transact := func(cond string) error {
+		_, err := c.TransactWriteItemsRequest(&dynamodb.TransactWriteItemsInput{
+			TransactItems: []dynamodb.TransactWriteItem{
+				{Update: &dynamodb.Update{
+					TableName:                 aws.String("test"),
+					Key:                       map[string]dynamodb.AttributeValue{"id": str("a")},
+					UpdateExpression:          aws.String("SET v = v + :one"),
+					ExpressionAttributeValues: map[string]dynamodb.AttributeValue{":one": num(1)},
+				}},
+				{Put: &dynamodb.Put{
+					TableName: aws.String("test"),
+					Item:      map[string]dynamodb.AttributeValue{"id": str("b")},
+				}},
+				{Delete: &dynamodb.Delete{
+					TableName: aws.String("test"),
+					Key:       map[string]dynamodb.AttributeValue{"id": str("c")},
+				}},
+				{ConditionCheck: &dynamodb.ConditionCheck{
+					TableName:           aws.String("test"),
+					Key:                 map[string]dynamodb.AttributeValue{"id": str("d")},
+					ConditionExpression: aws.String(cond),
+				}},
+			},
+		}).Send(context.Background())
+		return err
+	}
+
+	err := transact("attribute_exists(id)")
+	assert.Equal(t, dynamodb.ErrCodeTransactionCanceledException, errCode(err))
+	assert.Contains(t, err.Error(), "[None, None, None, ConditionalCheckFailed]")
+	assert.Equal(t, num(1), get(t, c, "test", map[string]dynamodb.AttributeValue{"id": str("a")})["v"], "cancelled transaction should not write")
+	assert.Nil(t, get(t, c, "test", map[string]dynamodb.AttributeValue{"id": str("b")}))
+	assert.NotNil(t, get(t, c, "test", map[string]dynamodb.AttributeValue{"id": str("c")}))
+
+	assert.NoError(t, transact("attribute_not_exists(id)"))
+	assert.Equal(t, num(2), get(t, c, "test", map[string]dynamodb.AttributeValue{"id": str("a")})["v"])
+	assert.NotNil(t, get(t, c, "test", map[string]dynamodb.AttributeValue{"id": str("b")}))
+	assert.Nil(t, get(t, c, "test", map[string]dynamodb.AttributeValue{"id": str("c")}))
+	assert.Nil(t, get(t, c, "test", map[string]dynamodb.AttributeValue{"id": str("d")}), "condition check should not write")
+}
+
+func TestClient_TransactWriteItems_SameItem(t *testing.T) {
+	c := New()
+	c.CreateTable("test", "id", "")
+
+	_, err := c.TransactWriteItemsRequest(&dynamodb.TransactWriteItemsInput{
+		TransactItems: []dynamodb.TransactWriteItem{
+			{Put: &dynamodb.Put{TableName: aws.String("test"), Item: map[string]dynamodb.AttributeValue{"id": str("a")}}},
+			{Delete: &dynamodb.Delete{TableName: aws.String("test"), Key: map[string]dynamodb.AttributeValue{"id": str("a")}}},
+		},
+	}).Send(context.Background())
+	assert.Equal(t, ErrCodeValidationException, errCode(err))
+	assert.Nil(t, get(t, c, "test", map[string]dynamodb.AttributeValue{"id": str("a")}))
+}
+
+func TestClient_Conditions(t *testing.T) {
+	item := map[string]dynamodb.AttributeValue{
+		"id":   str("a"),
+		"s":    str("hello"),
+		"n":    num(10),
+		"ss":   {SS: []string{"x", "y"}},
+		"l":    {L: []dynamodb.AttributeValue{str("e")}},
+		"m":    {M: map[string]dynamodb.AttributeValue{"inner": num(1)}},
+		"null": {NULL: aws.Bool(true)},
+	}
+
+	tests := []struct {
+		condition string
+		matches   bool
+	}{
+		{"n = :ten", true},
+		{"n <> :ten", false},
+		{"n < :ten", false},
+		{"n <= :ten", true},
+		{"n > :one", true},
+		{"n >= :one", true},
+		{"n = :hello", false},
+		{"n < :hello", false},
+		{"missing = :ten", false},
+		{"n BETWEEN :one AND :ten", true},
+		{"n BETWEEN :ten AND :one", false},
+		{"n IN (:one, :ten)", true},
+		{"s IN (:one, :ten)", false},
+		{"attribute_exists(m.inner)", true},
+		{"attribute_exists(m.missing)", false},
+		{"attribute_not_exists(missing)", true},
+		{"attribute_type(ss, :ssType)", true},
+		{"attribute_type(null, :ssType)", false},
+		{"begins_with(s, :he)", true},
+		{"begins_with(n, :he)", false},
+		{"contains(s, :ll)", true},
+		{"contains(ss, :x)", true},
+		{"contains(l, :e)", true},
+		{"size(s) = :five", true},
+		{"size(ss) < :five", true},
+		{"m.inner = :one AND l[0] = :e", true},
+		{"n = :one OR s = :hello", true},
+		{"NOT (n = :one OR s = :hello)", false},
+		{"n = :one OR s = :hello AND n = :one", false},
+		{"(n = :one OR s = :hello) AND n = :ten", true},
+	}
+
+	values := map[string]dynamodb.AttributeValue{
+		":one":    num(1),
+		":five":   num(5),
+		":ten":    {N: aws.String("10.0")},
+		":hello":  str("hello"),
+		":he":     str("he"),
+		":ll":     str("ll"),
+		":x":      str("x"),
+		":e":      str("e"),
+		":ssType": str("SS"),
+	}
+
+	for _, test := range tests {
+		t.Run(test.condition, func(t *testing.T) {
+			cond, err := parseCondition(test.condition, newExprContext(nil, values))
+			assert.NoError(t, err)
+			assert.Equal(t, test.matches, cond.match(item))
+		})
+	}
+}
+
+func TestClient_Scan_Paginated(t *testing.T) {
+	c := New()
+	c.CreateTable("test", "id", "")
+
+	for i := 0; i < 10; i++ {
+		put(t, c, "test", map[string]dynamodb.AttributeValue{"id": str(strconv.Itoa(i)), "n": num(i)})
+	}
+
+	var ids []string
+	var requests int
+	paginator := dynamodb.NewScanPaginator(c.ScanRequest(&dynamodb.ScanInput{
+		TableName:                 aws.String("test"),
+		Limit:                     aws.Int64(5),
+		FilterExpression:          aws.String("n >= :min"),
+		ProjectionExpression:      aws.String("id"),
+		ExpressionAttributeValues: map[string]dynamodb.AttributeValue{":min": num(2)},
+	}))
+
+	for paginator.Next(context.Background()) {
+		requests++
+		page := paginator.CurrentPage()
+		assert.True(t, *page.ScannedCount <= 5)
+		for _, item := range page.Items {
+			assert.Len(t, item, 1)
+			ids = append(ids, *item["id"].S)
+		}
+	}
+	assert.NoError(t, paginator.Err())
+
+	assert.Equal(t, []string{"2", "3", "4", "5", "6", "7", "8", "9"}, ids)
+	// The limit is reached on the last item, so an empty page is read before the scan ends.
+	assert.Equal(t, 3, requests)
+}
+
+func TestClient_Query(t *testing.T) {
+	c := New()
+	c.CreateTable("test", "pk", "sk")
+
+	for _, pk := range []string{"a", "b"} {
+		for _, sk := range []string{"1", "2", "3", "4"} {
+			put(t, c, "test", map[string]dynamodb.AttributeValue{"pk": str(pk), "sk": str(sk)})
+		}
+	}
+
+	query := func(input *dynamodb.QueryInput) ([]string, map[string]dynamodb.AttributeValue, error) {
+		input.TableName = aws.String("test")
+		res, err := c.QueryRequest(input).Send(context.Background())
+		if err != nil {
+			return nil, nil, err
+		}
+
+		var sks []string
+		for _, item := range res.Items {
+			assert.Equal(t, "b", *item["pk"].S)
+			sks = append(sks, *item["sk"].S)
+		}
+		return sks, res.LastEvaluatedKey, nil
+	}
+
+	sks, last, err := query(&dynamodb.QueryInput{
+		KeyConditionExpression:    aws.String("pk = :pk AND sk BETWEEN :from AND :to"),
+		ExpressionAttributeValues: map[string]dynamodb.AttributeValue{":pk": str("b"), ":from": str("2"), ":to": str("3")},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"2", "3"}, sks)
+	assert.Nil(t, last)
+
+	sks, last, err = query(&dynamodb.QueryInput{
+		KeyConditionExpression:    aws.String("pk = :pk"),
+		ExpressionAttributeValues: map[string]dynamodb.AttributeValue{":pk": str("b")},
+		ScanIndexForward:          aws.Bool(false),
+		Limit:                     aws.Int64(2),
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"4", "3"}, sks)
+
+	sks, _, err = query(&dynamodb.QueryInput{
+		KeyConditionExpression:    aws.String("pk = :pk"),
+		ExpressionAttributeValues: map[string]dynamodb.AttributeValue{":pk": str("b")},
+		ScanIndexForward:          aws.Bool(false),
+		ExclusiveStartKey:         last,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"2", "1"}, sks)
+
+	_, _, err = query(&dynamodb.QueryInput{
+		KeyConditionExpression:    aws.String("sk = :sk"),
+		ExpressionAttributeValues: map[string]dynamodb.AttributeValue{":sk": str("1")},
+	})
+	assert.Equal(t, ErrCodeValidationException, errCode(err))
+}
+
+func createIndexedTable(t *testing.T, c *Client) {
+	_, err := c.CreateTableRequest(&dynamodb.CreateTableInput{
+		AttributeDefinitions: []dynamodb.AttributeDefinition{
+			{AttributeName: aws.String("id"), AttributeType: dynamodb.ScalarAttributeTypeS},
+			{AttributeName: aws.String("group"), AttributeType: dynamodb.ScalarAttributeTypeS},
+		},
+		KeySchema: []dynamodb.KeySchemaElement{
+			{AttributeName: aws.String("id"), KeyType: dynamodb.KeyTypeHash},
+		},
+		GlobalSecondaryIndexes: []dynamodb.GlobalSecondaryIndex{{
+			IndexName: aws.String("group-index"),
+			KeySchema: []dynamodb.KeySchemaElement{
+				{AttributeName: aws.String("group"), KeyType: dynamodb.KeyTypeHash},
+			},
+			Projection: &dynamodb.Projection{ProjectionType: dynamodb.ProjectionTypeAll},
+		}},
+		BillingMode: dynamodb.BillingModePayPerRequest,
+		TableName:   aws.String("test"),
+	}).Send(context.Background())
+	assert.NoError(t, err)
+}
+
+func TestClient_CreateTable_Index(t *testing.T) {
+	c := New()
+	createIndexedTable(t, c)
+
+	put(t, c, "test", map[string]dynamodb.AttributeValue{"id": str("a"), "group": str("x")})
+	put(t, c, "test", map[string]dynamodb.AttributeValue{"id": str("b")})
+
+	res, err := c.DescribeTableRequest(&dynamodb.DescribeTableInput{TableName: aws.String("test")}).Send(context.Background())
+	assert.NoError(t, err)
+	if assert.Len(t, res.Table.GlobalSecondaryIndexes, 1) {
+		assert.Equal(t, "group-index", *res.Table.GlobalSecondaryIndexes[0].IndexName)
+		assert.Equal(t, int64(1), *res.Table.GlobalSecondaryIndexes[0].ItemCount)
+	}
+
+	_, err = c.CreateTableRequest(&dynamodb.CreateTableInput{
+		AttributeDefinitions: []dynamodb.AttributeDefinition{
+			{AttributeName: aws.String("id"), AttributeType: dynamodb.ScalarAttributeTypeS},
+			{AttributeName: aws.String("group"), AttributeType: dynamodb.ScalarAttributeTypeS},
+		},
+		KeySchema: []dynamodb.KeySchemaElement{
+			{AttributeName: aws.String("id"), KeyType: dynamodb.KeyTypeHash},
+		},
+		GlobalSecondaryIndexes: []dynamodb.GlobalSecondaryIndex{{
+			IndexName: aws.String("group-index"),
+			KeySchema: []dynamodb.KeySchemaElement{
+				{AttributeName: aws.String("group"), KeyType: dynamodb.KeyTypeHash},
+			},
+			Projection: &dynamodb.Projection{ProjectionType: dynamodb.ProjectionTypeKeysOnly},
+		}},
+		BillingMode: dynamodb.BillingModePayPerRequest,
+		TableName:   aws.String("keysOnly"),
+	}).Send(context.Background())
+	assert.Equal(t, ErrCodeValidationException, errCode(err), "only projection ALL is supported")
+}
+
+func TestClient_Index_KeyValidation(t *testing.T) {
+	c := New()
+	createIndexedTable(t, c)
+
+	_, err := c.PutItemRequest(&dynamodb.PutItemInput{
+		TableName: aws.String("test"),
+		Item:      map[string]dynamodb.AttributeValue{"id": str("a"), "group": num(1)},
+	}).Send(context.Background())
+	assert.Equal(t, ErrCodeValidationException, errCode(err), "index key of the wrong type")
+
+	put(t, c, "test", map[string]dynamodb.AttributeValue{"id": str("a")})
+
+	_, err = c.UpdateItemRequest(&dynamodb.UpdateItemInput{
+		TableName:                 aws.String("test"),
+		Key:                       map[string]dynamodb.AttributeValue{"id": str("a")},
+		UpdateExpression:          aws.String("SET #g = :g"),
+		ExpressionAttributeNames:  map[string]string{"#g": "group"},
+		ExpressionAttributeValues: map[string]dynamodb.AttributeValue{":g": str("")},
+	}).Send(context.Background())
+	assert.Equal(t, ErrCodeValidationException, errCode(err), "empty index key")
+}
+
+func TestClient_Query_Index(t *testing.T) {
+	c := New()
+	createIndexedTable(t, c)
+
+	for i := 0; i < 6; i++ {
+		item := map[string]dynamodb.AttributeValue{"id": str(strconv.Itoa(i))}
+		if i%3 != 0 {
+			item["group"] = str("x")
+		}
+		put(t, c, "test", item)
+	}
+	put(t, c, "test", map[string]dynamodb.AttributeValue{"id": str("9"), "group": str("y")})
+
+	var ids []string
+	var requests int
+	paginator := dynamodb.NewQueryPaginator(c.QueryRequest(&dynamodb.QueryInput{
+		TableName:                 aws.String("test"),
+		IndexName:                 aws.String("group-index"),
+		KeyConditionExpression:    aws.String("#g = :g"),
+		ExpressionAttributeNames:  map[string]string{"#g": "group"},
+		ExpressionAttributeValues: map[string]dynamodb.AttributeValue{":g": str("x")},
+		Limit:                     aws.Int64(3),
+	}))
+
+	for paginator.Next(context.Background()) {
+		requests++
+		page := paginator.CurrentPage()
+		if page.LastEvaluatedKey != nil {
+			assert.Len(t, page.LastEvaluatedKey, 2, "table and index key")
+		}
+		for _, item := range page.Items {
+			ids = append(ids, *item["id"].S)
+		}
+	}
+	assert.NoError(t, paginator.Err())
+
+	assert.Equal(t, []string{"1", "2", "4", "5"}, ids)
+	assert.Equal(t, 2, requests)
+
+	query := func(input *dynamodb.QueryInput) error {
+		input.TableName = aws.String("test")
+		input.KeyConditionExpression = aws.String("#g = :g")
+		input.ExpressionAttributeNames = map[string]string{"#g": "group"}
+		input.ExpressionAttributeValues = map[string]dynamodb.AttributeValue{":g": str("x")}
+		_, err := c.QueryRequest(input).Send(context.Background())
+		return err
+	}
+
+	assert.Equal(t, ErrCodeValidationException, errCode(query(&dynamodb.QueryInput{IndexName: aws.String("missing")})))
+	assert.Equal(t, ErrCodeValidationException, errCode(query(&dynamodb.QueryInput{IndexName: aws.String("group-index"), ConsistentRead: aws.Bool(true)})))
+	assert.Equal(t, ErrCodeValidationException, errCode(query(&dynamodb.QueryInput{})), "the table isn't keyed by group")
+	assert.Equal(t, ErrCodeValidationException, errCode(query(&dynamodb.QueryInput{
+		IndexName:         aws.String("group-index"),
+		ExclusiveStartKey: map[string]dynamodb.AttributeValue{"id": str("1")},
+	})), "start key without the index key")
+}
@@ -0,0 +1,1217 @@
+package dynamotest
+
+import (
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"math/big"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Expressions are parsed into a small tree and evaluated against items. Names and values are resolved while parsing,
+// so evaluation only needs the item.
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokIdent
+	tokName
+	tokValue
+	tokNumber
+	tokPunct
+)
+
+type token struct {
+	kind tokenKind
+	text string
+}
+
+func lex(s string) ([]token, error) {
+	var toks []token
+
+	isIdent := func(r rune) bool {
+		return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+	}
+
+	rs := []rune(s)
+	for i := 0; i < len(rs); {
+		r := rs[i]
+
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '#' || r == ':':
+			j := i + 1
+			for j < len(rs) && isIdent(rs[j]) {
+				j++
+			}
+			if j == i+1 {
+				return nil, validationError("Invalid expression: syntax error near %q", string(rs[i:]))
+			}
+
+			kind := tokName
+			if r == ':' {
+				kind = tokValue
+			}
+			toks = append(toks, token{kind: kind, text: string(rs[i:j])})
+			i = j
+		case unicode.IsDigit(r):
+			j := i
+			for j < len(rs) && unicode.IsDigit(rs[j]) {
+				j++
+			}
+			toks = append(toks, token{kind: tokNumber, text: string(rs[i:j])})
+			i = j
+		case isIdent(r):
+			j := i
+			for j < len(rs) && isIdent(rs[j]) {
+				j++
+			}
+			toks = append(toks, token{kind: tokIdent, text: string(rs[i:j])})
+			i = j
+		case i+1 < len(rs) && (string(rs[i:i+2]) == "<=" || string(rs[i:i+2]) == ">=" || string(rs[i:i+2]) == "<>"):
+			toks = append(toks, token{kind: tokPunct, text: string(rs[i : i+2])})
+			i += 2
+		case strings.ContainsRune("()[],.=<>+-", r):
+			toks = append(toks, token{kind: tokPunct, text: string(r)})
+			i++
+		default:
+			return nil, validationError("Invalid expression: unexpected character %q", string(r))
+		}
+	}
+
+	return append(toks, token{kind: tokEOF}), nil
+}
+
+// exprContext resolves expression attribute names and values, and records which were used.
+type exprContext struct {
+	names      map[string]string
+	values     map[string]dynamodb.AttributeValue
+	usedNames  map[string]bool
+	usedValues map[string]bool
+}
+
+func newExprContext(names map[string]string, values map[string]dynamodb.AttributeValue) *exprContext {
+	return &exprContext{
+		names:      names,
+		values:     values,
+		usedNames:  make(map[string]bool),
+		usedValues: make(map[string]bool),
+	}
+}
+
+// checkUnused fails like DynamoDB does when names or values are provided without being used by any expression.
+func (c *exprContext) checkUnused() error {
+	unusedNames := make(map[string]bool)
+	for k := range c.names {
+		if !c.usedNames[k] {
+			unusedNames[k] = true
+		}
+	}
+	if len(unusedNames) > 0 {
+		return validationError("Value provided in ExpressionAttributeNames unused in expressions: keys: {%s}",
+			strings.Join(sortedNames(unusedNames), ", "))
+	}
+
+	unusedValues := make(map[string]bool)
+	for k := range c.values {
+		if !c.usedValues[k] {
+			unusedValues[k] = true
+		}
+	}
+	if len(unusedValues) > 0 {
+		return validationError("Value provided in ExpressionAttributeValues unused in expressions: keys: {%s}",
+			strings.Join(sortedNames(unusedValues), ", "))
+	}
+
+	return nil
+}
+
+type parser struct {
+	toks []token
+	pos  int
+	ctx  *exprContext
+}
+
+func newParser(expr string, ctx *exprContext) (*parser, error) {
+	toks, err := lex(expr)
+	if err != nil {
+		return nil, err
+	}
+	return &parser{toks: toks, ctx: ctx}, nil
+}
+
+func (p *parser) peek() token {
+	return p.toks[p.pos]
+}
+
+func (p *parser) peekAt(n int) token {
+	if p.pos+n >= len(p.toks) {
+		return token{kind: tokEOF}
+	}
+	return p.toks[p.pos+n]
+}
+
+func (p *parser) next() token {
+	t := p.toks[p.pos]
+	if t.kind != tokEOF {
+		p.pos++
+	}
+	return t
+}
+
+// unread steps back over t, returned by the last call to next.
+func (p *parser) unread(t token) {
+	if t.kind != tokEOF {
+		p.pos--
+	}
+}
+
+func (p *parser) isPunct(s string) bool {
+	t := p.peek()
+	return t.kind == tokPunct && t.text == s
+}
+
+func (p *parser) isKeyword(kw string) bool {
+	t := p.peek()
+	return t.kind == tokIdent && strings.EqualFold(t.text, kw)
+}
+
+func (p *parser) expect(s string) error {
+	if !p.isPunct(s) {
+		return p.syntaxError()
+	}
+	p.next()
+	return nil
+}
+
+func (p *parser) syntaxError() error {
+	t := p.peek()
+	if t.kind == tokEOF {
+		return validationError("Invalid expression: unexpected end of expression")
+	}
+	return validationError("Invalid expression: syntax error; token: %q", t.text)
+}
+
+func (p *parser) end() error {
+	if p.peek().kind != tokEOF {
+		return p.syntaxError()
+	}
+	return nil
+}
+
+// pathElem is a single step in a document path: a map key or a list index.
+type pathElem struct {
+	name  string
+	index int
+	isIdx bool
+}
+
+type path []pathElem
+
+func (p path) String() string {
+	var sb strings.Builder
+	for i, e := range p {
+		if e.isIdx {
+			sb.WriteString("[" + strconv.Itoa(e.index) + "]")
+			continue
+		}
+		if i > 0 {
+			sb.WriteString(".")
+		}
+		sb.WriteString(e.name)
+	}
+	return sb.String()
+}
+
+// overlaps reports if one path is a prefix of the other.
+func (p path) overlaps(o path) bool {
+	n := len(p)
+	if len(o) < n {
+		n = len(o)
+	}
+	for i := 0; i < n; i++ {
+		if p[i] != o[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func (p *parser) parseName() (string, error) {
+	t := p.next()
+	switch t.kind {
+	case tokName:
+		name, ok := p.ctx.names[t.text]
+		if !ok {
+			return "", validationError("Value provided in ExpressionAttributeNames unused in expressions or not defined: %s", t.text)
+		}
+		p.ctx.usedNames[t.text] = true
+		return name, nil
+	case tokIdent:
+		return t.text, nil
+	default:
+		p.unread(t)
+		return "", p.syntaxError()
+	}
+}
+
+func (p *parser) parsePath() (path, error) {
+	name, err := p.parseName()
+	if err != nil {
+		return nil, err
+	}
+
+	res := path{{name: name}}
+	for {
+		switch {
+		case p.isPunct("."):
+			p.next()
+			name, err := p.parseName()
+			if err != nil {
+				return nil, err
+			}
+			res = append(res, pathElem{name: name})
+		case p.isPunct("["):
+			p.next()
+			t := p.next()
+			if t.kind != tokNumber {
+				p.unread(t)
+				return nil, p.syntaxError()
+			}
+			idx, err := strconv.Atoi(t.text)
+			if err != nil {
+				return nil, validationError("Invalid expression: list index %s out of range", t.text)
+			}
+			if err := p.expect("]"); err != nil {
+				return nil, err
+			}
+			res = append(res, pathElem{index: idx, isIdx: true})
+		default:
+			return res, nil
+		}
+	}
+}
+
+func (p *parser) parseValue() (dynamodb.AttributeValue, error) {
+	t := p.next()
+	if t.kind != tokValue {
+		p.unread(t)
+		return dynamodb.AttributeValue{}, p.syntaxError()
+	}
+
+	v, ok := p.ctx.values[t.text]
+	if !ok {
+		return v, validationError("An expression attribute value used in expression is not defined; attribute value: %s", t.text)
+	}
+	if msg := validValue(v); msg != "" {
+		return v, validationError("ExpressionAttributeValues contains invalid value: %s for key %s", msg, t.text)
+	}
+	p.ctx.usedValues[t.text] = true
+
+	return v, nil
+}
+
+// operand is evaluated against an item. Returns false if it refers to a missing attribute.
+type operand interface {
+	eval(item map[string]dynamodb.AttributeValue) (dynamodb.AttributeValue, bool)
+}
+
+type pathOperand struct {
+	path path
+}
+
+func (o pathOperand) eval(item map[string]dynamodb.AttributeValue) (dynamodb.AttributeValue, bool) {
+	return getPath(item, o.path)
+}
+
+type valueOperand struct {
+	value dynamodb.AttributeValue
+}
+
+func (o valueOperand) eval(map[string]dynamodb.AttributeValue) (dynamodb.AttributeValue, bool) {
+	return o.value, true
+}
+
+type sizeOperand struct {
+	path path
+}
+
+func (o sizeOperand) eval(item map[string]dynamodb.AttributeValue) (dynamodb.AttributeValue, bool) {
+	v, ok := getPath(item, o.path)
+	if !ok {
+		return v, false
+	}
+
+	var n int
+	switch typeOf(v) {
+	case typeS:
+		n = len(*v.S)
+	case typeB:
+		n = len(v.B)
+	case typeSS:
+		n = len(v.SS)
+	case typeNS:
+		n = len(v.NS)
+	case typeBS:
+		n = len(v.BS)
+	case typeL:
+		n = len(v.L)
+	case typeM:
+		n = len(v.M)
+	default:
+		return v, false
+	}
+
+	s := strconv.Itoa(n)
+	return dynamodb.AttributeValue{N: &s}, true
+}
+
+func (p *parser) parseOperand() (operand, error) {
+	switch t := p.peek(); {
+	case t.kind == tokValue:
+		v, err := p.parseValue()
+		return valueOperand{value: v}, err
+	case t.kind == tokIdent && strings.EqualFold(t.text, "size") && p.peekAt(1).text == "(":
+		p.next()
+		p.next()
+		pth, err := p.parsePath()
+		if err != nil {
+			return nil, err
+		}
+		return sizeOperand{path: pth}, p.expect(")")
+	default:
+		pth, err := p.parsePath()
+		return pathOperand{path: pth}, err
+	}
+}
+
+// condition is a parsed condition, filter or key condition expression.
+type condition interface {
+	match(item map[string]dynamodb.AttributeValue) bool
+}
+
+type andCondition struct {
+	left, right condition
+}
+
+func (c andCondition) match(item map[string]dynamodb.AttributeValue) bool {
+	return c.left.match(item) && c.right.match(item)
+}
+
+type orCondition struct {
+	left, right condition
+}
+
+func (c orCondition) match(item map[string]dynamodb.AttributeValue) bool {
+	return c.left.match(item) || c.right.match(item)
+}
+
+type notCondition struct {
+	cond condition
+}
+
+func (c notCondition) match(item map[string]dynamodb.AttributeValue) bool {
+	return !c.cond.match(item)
+}
+
+// comparison of two operands. Comparing with a missing attribute or a value of another type is false.
+type comparison struct {
+	op          string
+	left, right operand
+}
+
+func (c comparison) match(item map[string]dynamodb.AttributeValue) bool {
+	a, okA := c.left.eval(item)
+	b, okB := c.right.eval(item)
+	if !okA || !okB {
+		return false
+	}
+
+	switch c.op {
+	case "=":
+		return equal(a, b)
+	case "<>":
+		return !equal(a, b)
+	}
+
+	cmp, ok := compare(a, b)
+	if !ok {
+		return false
+	}
+
+	switch c.op {
+	case "<":
+		return cmp < 0
+	case "<=":
+		return cmp <= 0
+	case ">":
+		return cmp > 0
+	default:
+		return cmp >= 0
+	}
+}
+
+type between struct {
+	value, low, high operand
+}
+
+func (c between) match(item map[string]dynamodb.AttributeValue) bool {
+	return comparison{op: ">=", left: c.value, right: c.low}.match(item) &&
+		comparison{op: "<=", left: c.value, right: c.high}.match(item)
+}
+
+type in struct {
+	value   operand
+	options []operand
+}
+
+func (c in) match(item map[string]dynamodb.AttributeValue) bool {
+	for _, o := range c.options {
+		if (comparison{op: "=", left: c.value, right: o}).match(item) {
+			return true
+		}
+	}
+	return false
+}
+
+type function struct {
+	name string
+	path path
+	arg  operand
+}
+
+func (c function) match(item map[string]dynamodb.AttributeValue) bool {
+	v, exists := getPath(item, c.path)
+
+	switch c.name {
+	case "attribute_exists":
+		return exists
+	case "attribute_not_exists":
+		return !exists
+	}
+
+	if !exists {
+		return false
+	}
+
+	arg, ok := c.arg.eval(item)
+	if !ok {
+		return false
+	}
+
+	switch c.name {
+	case "attribute_type":
+		return arg.S != nil && typeOf(v) == *arg.S
+	case "begins_with":
+		switch {
+		case v.S != nil && arg.S != nil:
+			return strings.HasPrefix(*v.S, *arg.S)
+		case v.B != nil && arg.B != nil:
+			return strings.HasPrefix(string(v.B), string(arg.B))
+		}
+		return false
+	default: // contains
+		switch {
+		case v.S != nil && arg.S != nil:
+			return strings.Contains(*v.S, *arg.S)
+		case v.SS != nil && arg.S != nil:
+			return containsString(v.SS, *arg.S, stringEq)
+		case v.NS != nil && arg.N != nil:
+			return containsString(v.NS, *arg.N, numberEq)
+		case v.BS != nil && arg.B != nil:
+			return containsBytes(v.BS, arg.B)
+		case v.L != nil:
+			for _, e := range v.L {
+				if equal(e, arg) {
+					return true
+				}
+			}
+		}
+		return false
+	}
+}
+
+var comparators = map[string]bool{"=": true, "<>": true, "<": true, "<=": true, ">": true, ">=": true}
+
+// parseCondition parses a complete condition expression.
+func parseCondition(expr string, ctx *exprContext) (condition, error) {
+	p, err := newParser(expr, ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	cond, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+
+	return cond, p.end()
+}
+
+func (p *parser) parseOr() (condition, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.isKeyword("OR") {
+		p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = orCondition{left: left, right: right}
+	}
+
+	return left, nil
+}
+
+func (p *parser) parseAnd() (condition, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.isKeyword("AND") {
+		p.next()
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		left = andCondition{left: left, right: right}
+	}
+
+	return left, nil
+}
+
+func (p *parser) parseNot() (condition, error) {
+	if p.isKeyword("NOT") {
+		p.next()
+		cond, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return notCondition{cond: cond}, nil
+	}
+
+	return p.parsePrimary()
+}
+
+func (p *parser) parsePrimary() (condition, error) {
+	if p.isPunct("(") {
+		p.next()
+		cond, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		return cond, p.expect(")")
+	}
+
+	if t := p.peek(); t.kind == tokIdent && p.peekAt(1).text == "(" {
+		name := strings.ToLower(t.text)
+		switch name {
+		case "attribute_exists", "attribute_not_exists", "attribute_type", "begins_with", "contains":
+			return p.parseFunction(name)
+		case "size":
+		default:
+			return nil, validationError("Invalid expression: invalid function name; function: %s", t.text)
+		}
+	}
+
+	left, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+
+	switch t := p.peek(); {
+	case t.kind == tokPunct && comparators[t.text]:
+		p.next()
+		right, err := p.parseOperand()
+		if err != nil {
+			return nil, err
+		}
+		return comparison{op: t.text, left: left, right: right}, nil
+	case p.isKeyword("BETWEEN"):
+		p.next()
+		low, err := p.parseOperand()
+		if err != nil {
+			return nil, err
+		}
+		if !p.isKeyword("AND") {
+			return nil, p.syntaxError()
+		}
+		p.next()
+		high, err := p.parseOperand()
+		if err != nil {
+			return nil, err
+		}
+		return between{value: left, low: low, high: high}, nil
+	case p.isKeyword("IN"):
+		p.next()
+		if err := p.expect("("); err != nil {
+			return nil, err
+		}
+		c := in{value: left}
+		for {
+			o, err := p.parseOperand()
+			if err != nil {
+				return nil, err
+			}
+			c.options = append(c.options, o)
+			if !p.isPunct(",") {
+				break
+			}
+			p.next()
+		}
+		return c, p.expect(")")
+	default:
+		return nil, p.syntaxError()
+	}
+}
+
+func (p *parser) parseFunction(name string) (condition, error) {
+	p.next()
+	p.next()
+
+	pth, err := p.parsePath()
+	if err != nil {
+		return nil, err
+	}
+
+	f := function{name: name, path: pth}
+	if name != "attribute_exists" && name != "attribute_not_exists" {
+		if err := p.expect(","); err != nil {
+			return nil, err
+		}
+		if f.arg, err = p.parseOperand(); err != nil {
+			return nil, err
+		}
+	}
+
+	return f, p.expect(")")
+}
+
+// parseProjection parses a comma separated list of paths.
+func parseProjection(expr string, ctx *exprContext) ([]path, error) {
+	p, err := newParser(expr, ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var paths []path
+	for {
+		pth, err := p.parsePath()
+		if err != nil {
+			return nil, err
+		}
+		paths = append(paths, pth)
+
+		if !p.isPunct(",") {
+			break
+		}
+		p.next()
+	}
+
+	return paths, p.end()
+}
+
+// update is a parsed update expression.
+type update struct {
+	set    []setAction
+	remove []path
+	add    []valueAction
+	delete []valueAction
+}
+
+type setAction struct {
+	path  path
+	value setOperand
+}
+
+type valueAction struct {
+	path  path
+	value dynamodb.AttributeValue
+}
+
+// setOperand is an operand in a SET action. Unlike operand it can fail, eg. when adding a string to a number.
+type setOperand interface {
+	evalSet(item map[string]dynamodb.AttributeValue) (dynamodb.AttributeValue, error)
+}
+
+type plainSetOperand struct {
+	operand
+}
+
+func (o plainSetOperand) evalSet(item map[string]dynamodb.AttributeValue) (dynamodb.AttributeValue, error) {
+	v, ok := o.eval(item)
+	if !ok {
+		return v, validationError("The provided expression refers to an attribute that does not exist in the item")
+	}
+	return v, nil
+}
+
+type ifNotExists struct {
+	path  path
+	value setOperand
+}
+
+func (o ifNotExists) evalSet(item map[string]dynamodb.AttributeValue) (dynamodb.AttributeValue, error) {
+	if v, ok := getPath(item, o.path); ok {
+		return v, nil
+	}
+	return o.value.evalSet(item)
+}
+
+type listAppend struct {
+	left, right setOperand
+}
+
+func (o listAppend) evalSet(item map[string]dynamodb.AttributeValue) (dynamodb.AttributeValue, error) {
+	a, err := o.left.evalSet(item)
+	if err != nil {
+		return a, err
+	}
+	b, err := o.right.evalSet(item)
+	if err != nil {
+		return b, err
+	}
+
+	if a.L == nil || b.L == nil {
+		return a, validationError("An operand in the update expression has an incorrect data type")
+	}
+
+	l := make([]dynamodb.AttributeValue, 0, len(a.L)+len(b.L))
+	return dynamodb.AttributeValue{L: append(append(l, a.L...), b.L...)}, nil
+}
+
+type arithmetic struct {
+	op          string
+	left, right setOperand
+}
+
+func (o arithmetic) evalSet(item map[string]dynamodb.AttributeValue) (dynamodb.AttributeValue, error) {
+	a, err := o.left.evalSet(item)
+	if err != nil {
+		return a, err
+	}
+	b, err := o.right.evalSet(item)
+	if err != nil {
+		return b, err
+	}
+
+	if a.N == nil || b.N == nil {
+		return a, validationError("An operand in the update expression has an incorrect data type")
+	}
+
+	ra, _ := parseNumber(*a.N)
+	rb, _ := parseNumber(*b.N)
+
+	res := new(big.Rat)
+	if o.op == "+" {
+		res.Add(ra, rb)
+	} else {
+		res.Sub(ra, rb)
+	}
+
+	n := formatNumber(res)
+	return dynamodb.AttributeValue{N: &n}, nil
+}
+
+// parseUpdate parses an update expression with SET, REMOVE, ADD and DELETE clauses.
+func parseUpdate(expr string, ctx *exprContext) (*update, error) {
+	p, err := newParser(expr, ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	u := &update{}
+	seen := make(map[string]bool)
+
+	for p.peek().kind != tokEOF {
+		t := p.next()
+		clause := strings.ToUpper(t.text)
+		if t.kind != tokIdent || (clause != "SET" && clause != "REMOVE" && clause != "ADD" && clause != "DELETE") {
+			p.unread(t)
+			return nil, p.syntaxError()
+		}
+
+		if seen[clause] {
+			return nil, validationError("Invalid UpdateExpression: The %q section can only be used once in an update expression", clause)
+		}
+		seen[clause] = true
+
+		for {
+			if err := p.parseUpdateAction(clause, u); err != nil {
+				return nil, err
+			}
+			if !p.isPunct(",") {
+				break
+			}
+			p.next()
+		}
+	}
+
+	if len(seen) == 0 {
+		return nil, validationError("Invalid UpdateExpression: The expression can not be empty")
+	}
+
+	return u, u.checkOverlap()
+}
+
+func (p *parser) parseUpdateAction(clause string, u *update) error {
+	pth, err := p.parsePath()
+	if err != nil {
+		return err
+	}
+
+	switch clause {
+	case "SET":
+		if err := p.expect("="); err != nil {
+			return err
+		}
+		v, err := p.parseSetValue()
+		if err != nil {
+			return err
+		}
+		u.set = append(u.set, setAction{path: pth, value: v})
+	case "REMOVE":
+		u.remove = append(u.remove, pth)
+	default:
+		v, err := p.parseValue()
+		if err != nil {
+			return err
+		}
+		if clause == "ADD" {
+			u.add = append(u.add, valueAction{path: pth, value: v})
+		} else {
+			u.delete = append(u.delete, valueAction{path: pth, value: v})
+		}
+	}
+
+	return nil
+}
+
+func (p *parser) parseSetValue() (setOperand, error) {
+	left, err := p.parseSetOperand()
+	if err != nil {
+		return nil, err
+	}
+
+	if p.isPunct("+") || p.isPunct("-") {
+		op := p.next().text
+		right, err := p.parseSetOperand()
+		if err != nil {
+			return nil, err
+		}
+		return arithmetic{op: op, left: left, right: right}, nil
+	}
+
+	return left, nil
+}
+
+func (p *parser) parseSetOperand() (setOperand, error) {
+	t := p.peek()
+	if t.kind != tokIdent || p.peekAt(1).text != "(" {
+		o, err := p.parseOperand()
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := o.(sizeOperand); ok {
+			return nil, validationError("Invalid UpdateExpression: The function is not allowed in an update expression; function: size")
+		}
+		return plainSetOperand{o}, nil
+	}
+
+	p.next()
+	p.next()
+
+	switch strings.ToLower(t.text) {
+	case "if_not_exists":
+		pth, err := p.parsePath()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(","); err != nil {
+			return nil, err
+		}
+		v, err := p.parseSetValue()
+		if err != nil {
+			return nil, err
+		}
+		return ifNotExists{path: pth, value: v}, p.expect(")")
+	case "list_append":
+		a, err := p.parseSetValue()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(","); err != nil {
+			return nil, err
+		}
+		b, err := p.parseSetValue()
+		if err != nil {
+			return nil, err
+		}
+		return listAppend{left: a, right: b}, p.expect(")")
+	default:
+		return nil, validationError("Invalid UpdateExpression: Invalid function name; function: %s", t.text)
+	}
+}
+
+func (u *update) paths() []path {
+	var paths []path
+	for _, a := range u.set {
+		paths = append(paths, a.path)
+	}
+	paths = append(paths, u.remove...)
+	for _, a := range u.add {
+		paths = append(paths, a.path)
+	}
+	for _, a := range u.delete {
+		paths = append(paths, a.path)
+	}
+	return paths
+}
+
+func (u *update) checkOverlap() error {
+	paths := u.paths()
+	for i := range paths {
+		for j := i + 1; j < len(paths); j++ {
+			if paths[i].overlaps(paths[j]) {
+				return validationError("Invalid UpdateExpression: Two document paths overlap with each other; "+
+					"must remove or rewrite one of these paths; path one: [%s], path two: [%s]", paths[i], paths[j])
+			}
+		}
+	}
+	return nil
+}
+
+// apply returns a copy of item with the update applied. All operands are evaluated against the original item.
+func (u *update) apply(item map[string]dynamodb.AttributeValue) (map[string]dynamodb.AttributeValue, error) {
+	values := make([]dynamodb.AttributeValue, len(u.set))
+	for i, a := range u.set {
+		v, err := a.value.evalSet(item)
+		if err != nil {
+			return nil, err
+		}
+		values[i] = v
+	}
+
+	res := copyItem(item)
+
+	for i, a := range u.set {
+		if err := setPath(res, a.path, copyValue(values[i])); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, pth := range u.remove {
+		if err := removePath(res, pth); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, a := range u.add {
+		existing, ok := getPath(res, a.path)
+		v := copyValue(a.value)
+
+		switch {
+		case !ok && (v.N != nil || isSet(v)):
+		case ok && existing.N != nil && v.N != nil:
+			sum, err := arithmetic{op: "+", left: constSetOperand{existing}, right: constSetOperand{v}}.evalSet(nil)
+			if err != nil {
+				return nil, err
+			}
+			v = sum
+		case ok && isSet(existing) && typeOf(existing) == typeOf(v):
+			v = union(existing, v)
+		default:
+			return nil, validationError("An operand in the update expression has an incorrect data type")
+		}
+
+		if err := setPath(res, a.path, v); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, a := range u.delete {
+		if !isSet(a.value) {
+			return nil, validationError("An operand in the update expression has an incorrect data type")
+		}
+
+		existing, ok := getPath(res, a.path)
+		if !ok {
+			continue
+		}
+		if typeOf(existing) != typeOf(a.value) {
+			return nil, validationError("An operand in the update expression has an incorrect data type")
+		}
+
+		if v, nonEmpty := difference(existing, a.value); nonEmpty {
+			if err := setPath(res, a.path, v); err != nil {
+				return nil, err
+			}
+		} else if err := removePath(res, a.path); err != nil {
+			return nil, err
+		}
+	}
+
+	return res, nil
+}
+
+type constSetOperand struct {
+	value dynamodb.AttributeValue
+}
+
+func (o constSetOperand) evalSet(map[string]dynamodb.AttributeValue) (dynamodb.AttributeValue, error) {
+	return o.value, nil
+}
+
+func getPath(item map[string]dynamodb.AttributeValue, pth path) (dynamodb.AttributeValue, bool) {
+	cur := dynamodb.AttributeValue{M: item}
+
+	for _, e := range pth {
+		if e.isIdx {
+			if e.index >= len(cur.L) {
+				return dynamodb.AttributeValue{}, false
+			}
+			cur = cur.L[e.index]
+			continue
+		}
+
+		v, ok := cur.M[e.name]
+		if !ok {
+			return dynamodb.AttributeValue{}, false
+		}
+		cur = v
+	}
+
+	return cur, true
+}
+
+func invalidPath() error {
+	return validationError("The document path provided in the update expression is invalid for update")
+}
+
+// setPath sets the value at pth. The parent of the last element must exist. Setting a list index past the end
+// appends the value.
+func setPath(item map[string]dynamodb.AttributeValue, pth path, v dynamodb.AttributeValue) error {
+	_, err := setIn(dynamodb.AttributeValue{M: item}, pth, v)
+	return err
+}
+
+func setIn(container dynamodb.AttributeValue, pth path, v dynamodb.AttributeValue) (dynamodb.AttributeValue, error) {
+	e := pth[0]
+
+	if e.isIdx {
+		if container.L == nil {
+			return container, invalidPath()
+		}
+		if len(pth) == 1 {
+			if e.index >= len(container.L) {
+				container.L = append(container.L, v)
+			} else {
+				container.L[e.index] = v
+			}
+			return container, nil
+		}
+		if e.index >= len(container.L) {
+			return container, invalidPath()
+		}
+		child, err := setIn(container.L[e.index], pth[1:], v)
+		container.L[e.index] = child
+		return container, err
+	}
+
+	if container.M == nil {
+		return container, invalidPath()
+	}
+	if len(pth) == 1 {
+		container.M[e.name] = v
+		return container, nil
+	}
+	child, ok := container.M[e.name]
+	if !ok {
+		return container, invalidPath()
+	}
+	child, err := setIn(child, pth[1:], v)
+	container.M[e.name] = child
+	return container, err
+}
+
+// removePath removes the value at pth. Removing a missing attribute does nothing.
+func removePath(item map[string]dynamodb.AttributeValue, pth path) error {
+	_, err := removeIn(dynamodb.AttributeValue{M: item}, pth)
+	return err
+}
+
+func removeIn(container dynamodb.AttributeValue, pth path) (dynamodb.AttributeValue, error) {
+	e := pth[0]
+
+	if e.isIdx {
+		if container.L == nil {
+			return container, invalidPath()
+		}
+		if e.index >= len(container.L) {
+			return container, nil
+		}
+		if len(pth) == 1 {
+			l := make([]dynamodb.AttributeValue, 0, len(container.L)-1)
+			container.L = append(append(l, container.L[:e.index]...), container.L[e.index+1:]...)
+			return container, nil
+		}
+		child, err := removeIn(container.L[e.index], pth[1:])
+		container.L[e.index] = child
+		return container, err
+	}
+
+	if container.M == nil {
+		return container, invalidPath()
+	}
+	if len(pth) == 1 {
+		delete(container.M, e.name)
+		return container, nil
+	}
+	child, ok := container.M[e.name]
+	if !ok {
+		return container, nil
+	}
+	child, err := removeIn(child, pth[1:])
+	container.M[e.name] = child
+	return container, err
+}
+
+// project returns a copy of item holding only the attributes in paths. Nested attributes keep their parent maps.
+func project(item map[string]dynamodb.AttributeValue, paths []path) (map[string]dynamodb.AttributeValue, error) {
+	if paths == nil {
+		return copyItem(item), nil
+	}
+
+	res := make(map[string]dynamodb.AttributeValue)
+	for _, pth := range paths {
+		v, ok := getPath(item, pth)
+		if !ok {
+			continue
+		}
+
+		cur := res
+		for i, e := range pth {
+			if e.isIdx {
+				return nil, validationError("ProjectionExpression with list indexes is not supported by dynamotest")
+			}
+			if i == len(pth)-1 {
+				cur[e.name] = copyValue(v)
+				break
+			}
+			next, ok := cur[e.name]
+			if !ok || next.M == nil {
+				next = dynamodb.AttributeValue{M: make(map[string]dynamodb.AttributeValue)}
+				cur[e.name] = next
+			}
+			cur = next.M
+		}
+	}
+
+	return res, nil
+}
@@ -0,0 +1,151 @@
+package dynamotest
+
+import (
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+)
+
+// index is a global secondary index. Only items with the index key attributes are part of the index. Indexes project
+// all attributes, so they are read straight from the table items.
+type index struct {
+	table    *table
+	name     string
+	schema   []dynamodb.KeySchemaElement
+	hashKey  string
+	rangeKey string
+	keyTypes map[string]string
+}
+
+// newIndex validates the definition of a global secondary index. defined holds the attribute definitions of the
+// table by name.
+func newIndex(t *table, in dynamodb.GlobalSecondaryIndex, defined map[string]string) (*index, error) {
+	idx := &index{
+		table:    t,
+		name:     aws.StringValue(in.IndexName),
+		schema:   in.KeySchema,
+		keyTypes: make(map[string]string),
+	}
+
+	if _, exists := t.indexes[idx.name]; exists {
+		return nil, validationError("Duplicate index name: %s", idx.name)
+	}
+
+	if in.Projection == nil || in.Projection.ProjectionType != dynamodb.ProjectionTypeAll {
+		return nil, validationError("Only projection type ALL is supported by dynamotest. Index: %s", idx.name)
+	}
+
+	for _, k := range in.KeySchema {
+		attr := aws.StringValue(k.AttributeName)
+		typ, ok := defined[attr]
+		if !ok {
+			return nil, validationError("Some index key attributes are not defined in AttributeDefinitions: %s", attr)
+		}
+		idx.keyTypes[attr] = typ
+
+		if k.KeyType == dynamodb.KeyTypeHash && idx.hashKey == "" {
+			idx.hashKey = attr
+		} else if k.KeyType == dynamodb.KeyTypeRange && idx.rangeKey == "" {
+			idx.rangeKey = attr
+		} else {
+			return nil, validationError("Invalid KeySchema: an index has one hash key and at most one range key. Index: %s", idx.name)
+		}
+	}
+
+	if idx.hashKey == "" {
+		return nil, validationError("Invalid KeySchema: hash key is required. Index: %s", idx.name)
+	}
+
+	return idx, nil
+}
+
+func (idx *index) description() dynamodb.GlobalSecondaryIndexDescription {
+	count := 0
+	for _, item := range idx.table.items {
+		if idx.contains(item) {
+			count++
+		}
+	}
+
+	return dynamodb.GlobalSecondaryIndexDescription{
+		IndexName:   aws.String(idx.name),
+		IndexStatus: dynamodb.IndexStatusActive,
+		ItemCount:   aws.Int64(int64(count)),
+		KeySchema:   idx.schema,
+		Projection:  &dynamodb.Projection{ProjectionType: dynamodb.ProjectionTypeAll},
+	}
+}
+
+// contains reports if item has the key attributes of the index. Items with a key attribute of the wrong type are
+// rejected on write, see checkItem.
+func (idx *index) contains(item map[string]dynamodb.AttributeValue) bool {
+	for attr := range idx.keyTypes {
+		if _, ok := item[attr]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// checkItem fails if item has an index key attribute of the wrong type or an empty value, like DynamoDB does.
+func (idx *index) checkItem(item map[string]dynamodb.AttributeValue) error {
+	for attr, typ := range idx.keyTypes {
+		v, ok := item[attr]
+		if !ok {
+			continue
+		}
+
+		if actual := typeOf(v); actual != typ {
+			return validationError("One or more parameter values were invalid: Type mismatch for Index Key %s Expected: %s Actual: %s IndexName: %s", attr, typ, actual, idx.name)
+		}
+
+		if (v.S != nil && *v.S == "") || (v.B != nil && len(v.B) == 0) {
+			return validationError("One or more parameter values are not valid. A value specified for a secondary index key is not supported. The AttributeValue for a key attribute cannot contain an empty value. IndexName: %s, IndexKey: %s", idx.name, attr)
+		}
+	}
+	return nil
+}
+
+// less orders items by the index hash and range key. Items with the same index key are ordered by the table key, so
+// the order is total and pages can continue from a key.
+func (idx *index) less(a, b map[string]dynamodb.AttributeValue) bool {
+	if c, _ := compare(a[idx.hashKey], b[idx.hashKey]); c != 0 {
+		return c < 0
+	}
+	if idx.rangeKey != "" {
+		if c, _ := compare(a[idx.rangeKey], b[idx.rangeKey]); c != 0 {
+			return c < 0
+		}
+	}
+	return idx.table.less(a, b)
+}
+
+// key returns the table and index key attributes of item, as returned in LastEvaluatedKey by a query of the index.
+func (idx *index) key(item map[string]dynamodb.AttributeValue) map[string]dynamodb.AttributeValue {
+	key := idx.table.key(item)
+	for attr := range idx.keyTypes {
+		key[attr] = copyValue(item[attr])
+	}
+	return key
+}
+
+// checkKey validates an ExclusiveStartKey holding exactly the table and index key attributes.
+func (idx *index) checkKey(key map[string]dynamodb.AttributeValue) error {
+	tableKey := make(map[string]dynamodb.AttributeValue)
+	for attr := range idx.table.keyTypes {
+		if v, ok := key[attr]; ok {
+			tableKey[attr] = v
+		}
+	}
+
+	for attr, typ := range idx.keyTypes {
+		if v, ok := key[attr]; !ok || typeOf(v) != typ {
+			return validationError("The provided key element does not match the schema")
+		}
+	}
+
+	if len(key) != len(idx.key(key)) {
+		return validationError("The provided key element does not match the schema")
+	}
+
+	return idx.table.checkKey(tableKey)
+}
@@ -0,0 +1,205 @@
+package dynamo
+
+import (
+	"context"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/awserr"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/dynamodbattribute"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/dynamodbiface"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/expression"
+	"github.com/rejlersembriq/hooked/pkg/event"
+	"time"
+)
+
+// EventRepository implements event.Repository in a DynamoDb table with the string hash key "slug".
+type EventRepository struct {
+	dynamoDb dynamodbiface.ClientAPI
+	table    string
+}
+
+// NewEventRepository returns a repository using the provided table.
+func NewEventRepository(dynamoIface dynamodbiface.ClientAPI, tableName string) *EventRepository {
+	return &EventRepository{
+		dynamoDb: dynamoIface,
+		table:    tableName,
+	}
+}
+
+// Create stores a new event.
+func (r *EventRepository) Create(ctx context.Context, e event.Event) (*event.Event, error) {
+	now := time.Now()
+	e.Created = &now
+	e.Updated = &now
+
+	if err := r.put(ctx, e, "attribute_not_exists(slug)"); err != nil {
+		if isConditionFailed(err) {
+			return nil, event.ErrExists
+		}
+		return nil, err
+	}
+
+	return r.get(ctx, e.Slug)
+}
+
+// Update replaces an event. The creation time is read first, and the event is only written if it still exists.
+func (r *EventRepository) Update(ctx context.Context, e event.Event) (*event.Event, error) {
+	existing, err := r.get(ctx, e.Slug)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	e.Created = existing.Created
+	e.Updated = &now
+
+	if err := r.put(ctx, e, "attribute_exists(slug)"); err != nil {
+		if isConditionFailed(err) {
+			return nil, event.ErrNotExist
+		}
+		return nil, err
+	}
+
+	return r.get(ctx, e.Slug)
+}
+
+// Close sets the status of an open event ending at end to closed. Only the status and update time are written, on
+// condition the event is still open and ends at end. The times are stored by their field names, see event.Event.
+func (r *EventRepository) Close(ctx context.Context, slug string, end time.Time) (*event.Event, error) {
+	update := expression.Set(expression.Name("status"), expression.Value(event.StatusClosed)).
+		Set(expression.Name("updated"), expression.Value(time.Now().Unix()))
+
+	condition := expression.AttributeExists(expression.Name("slug")).
+		And(expression.Name("status").Equal(expression.Value(event.StatusOpen))).
+		And(expression.Name("End").Equal(expression.Value(end.Unix())))
+
+	exp, err := expression.NewBuilder().WithUpdate(update).WithCondition(condition).Build()
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = r.dynamoDb.UpdateItemRequest(&dynamodb.UpdateItemInput{
+		ConditionExpression:       exp.Condition(),
+		ExpressionAttributeNames:  exp.Names(),
+		ExpressionAttributeValues: exp.Values(),
+		Key: map[string]dynamodb.AttributeValue{
+			"slug": {S: &slug},
+		},
+		TableName:        &r.table,
+		UpdateExpression: exp.Update(),
+	}).Send(ctx)
+
+	if isConditionFailed(err) {
+		// Tells a missing event from a changed one.
+		if _, err := r.get(ctx, slug); err != nil {
+			return nil, err
+		}
+		return nil, event.ErrChanged
+	}
+
+	if err != nil {
+		return nil, requestError(ctx, err)
+	}
+
+	return r.get(ctx, slug)
+}
+
+func (r *EventRepository) put(ctx context.Context, e event.Event, condition string) error {
+	item, err := dynamodbattribute.MarshalMap(&e)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.dynamoDb.PutItemRequest(&dynamodb.PutItemInput{
+		ConditionExpression: aws.String(condition),
+		Item:                item,
+		TableName:           &r.table,
+	}).Send(ctx)
+	if err != nil && !isConditionFailed(err) {
+		return requestError(ctx, err)
+	}
+
+	return err
+}
+
+// Get retrieves an event.
+func (r *EventRepository) Get(ctx context.Context, slug string) (*event.Event, error) {
+	return r.get(ctx, slug)
+}
+
+// get reads an event consistently, so an event is found right after it's written.
+func (r *EventRepository) get(ctx context.Context, slug string) (*event.Event, error) {
+	res, err := r.dynamoDb.GetItemRequest(&dynamodb.GetItemInput{
+		ConsistentRead: aws.Bool(true),
+		Key: map[string]dynamodb.AttributeValue{
+			"slug": {S: &slug},
+		},
+		TableName: &r.table,
+	}).Send(ctx)
+	if err != nil {
+		return nil, requestError(ctx, err)
+	}
+
+	if res.Item == nil {
+		return nil, event.ErrNotExist
+	}
+
+	var e event.Event
+	if err := dynamodbattribute.UnmarshalMap(res.Item, &e); err != nil {
+		return nil, err
+	}
+
+	return &e, nil
+}
+
+// List retrieves all events.
+func (r *EventRepository) List(ctx context.Context) ([]*event.Event, error) {
+	es := make([]*event.Event, 0)
+
+	paginator := dynamodb.NewScanPaginator(r.dynamoDb.ScanRequest(&dynamodb.ScanInput{
+		TableName: &r.table,
+	}))
+
+	for paginator.Next(ctx) {
+		var page []*event.Event
+		if err := dynamodbattribute.UnmarshalListOfMaps(paginator.CurrentPage().Items, &page); err != nil {
+			return nil, err
+		}
+
+		es = append(es, page...)
+	}
+
+	if err := paginator.Err(); err != nil {
+		return nil, requestError(ctx, err)
+	}
+
+	event.Sort(es)
+
+	return es, nil
+}
+
+// Delete removes an event.
+func (r *EventRepository) Delete(ctx context.Context, slug string) error {
+	_, err := r.dynamoDb.DeleteItemRequest(&dynamodb.DeleteItemInput{
+		ConditionExpression: aws.String("attribute_exists(slug)"),
+		Key: map[string]dynamodb.AttributeValue{
+			"slug": {S: &slug},
+		},
+		TableName: &r.table,
+	}).Send(ctx)
+
+	if isConditionFailed(err) {
+		return event.ErrNotExist
+	}
+
+	if err != nil {
+		return requestError(ctx, err)
+	}
+
+	return nil
+}
+
+func isConditionFailed(err error) bool {
+	aerr, ok := err.(awserr.Error)
+	return ok && aerr.Code() == dynamodb.ErrCodeConditionalCheckFailedException
+}
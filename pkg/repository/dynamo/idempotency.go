@@ -0,0 +1,169 @@
+package dynamo
+
+import (
+	"context"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/awserr"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/dynamodbattribute"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/dynamodbiface"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/expression"
+	"github.com/rejlersembriq/hooked/pkg/idempotency"
+	"time"
+)
+
+// A reservation can be released between a failed reserve and reading the existing record. Reserving is retried this
+// many times.
+const reserveAttempts = 3
+
+// IdempotencyStore implements idempotency.Store in a DynamoDb table with the string hash key "key".
+// Enable time to live on the "expires" attribute to have DynamoDb remove expired records.
+type IdempotencyStore struct {
+	dynamoDb dynamodbiface.ClientAPI
+	table    string
+}
+
+// NewIdempotencyStore returns a store using the provided table.
+func NewIdempotencyStore(dynamoIface dynamodbiface.ClientAPI, tableName string) *IdempotencyStore {
+	return &IdempotencyStore{
+		dynamoDb: dynamoIface,
+		table:    tableName,
+	}
+}
+
+type idempotencyItem struct {
+	Key         string                `dynamodbav:"key"`
+	Fingerprint string                `dynamodbav:"fingerprint"`
+	Expires     int64                 `dynamodbav:"expires"`
+	Response    *idempotency.Response `dynamodbav:"response,omitempty"`
+}
+
+// Reserve stores rec unless an unexpired record with the same key exists.
+func (s *IdempotencyStore) Reserve(ctx context.Context, rec idempotency.Record) (*idempotency.Record, error) {
+	item, err := dynamodbattribute.MarshalMap(&idempotencyItem{
+		Key:         rec.Key,
+		Fingerprint: rec.Fingerprint,
+		Expires:     rec.Expires.Unix(),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for i := 0; i < reserveAttempts; i++ {
+		// Expired records might not have been removed by DynamoDb yet, so they are overwritten.
+		condition := expression.AttributeNotExists(expression.Name("key")).
+			Or(expression.Name("expires").LessThanEqual(expression.Value(time.Now().Unix())))
+
+		exp, err := expression.NewBuilder().WithCondition(condition).Build()
+		if err != nil {
+			return nil, err
+		}
+
+		_, err = s.dynamoDb.PutItemRequest(&dynamodb.PutItemInput{
+			ConditionExpression:       exp.Condition(),
+			ExpressionAttributeNames:  exp.Names(),
+			ExpressionAttributeValues: exp.Values(),
+			Item:                      item,
+			TableName:                 &s.table,
+		}).Send(ctx)
+		if err == nil {
+			return nil, nil
+		}
+
+		if aerr, ok := err.(awserr.Error); !ok || aerr.Code() != dynamodb.ErrCodeConditionalCheckFailedException {
+			return nil, requestError(ctx, err)
+		}
+
+		existing, err := s.get(ctx, rec.Key)
+		if err != nil {
+			return nil, err
+		}
+
+		if existing != nil {
+			return existing, nil
+		}
+	}
+
+	return nil, awserr.New(dynamodb.ErrCodeConditionalCheckFailedException, "unable to reserve idempotency key", nil)
+}
+
+func (s *IdempotencyStore) get(ctx context.Context, key string) (*idempotency.Record, error) {
+	res, err := s.dynamoDb.GetItemRequest(&dynamodb.GetItemInput{
+		ConsistentRead: aws.Bool(true),
+		Key: map[string]dynamodb.AttributeValue{
+			"key": {S: &key},
+		},
+		TableName: &s.table,
+	}).Send(ctx)
+	if err != nil {
+		return nil, requestError(ctx, err)
+	}
+
+	if res.Item == nil {
+		return nil, nil
+	}
+
+	var item idempotencyItem
+	if err := dynamodbattribute.UnmarshalMap(res.Item, &item); err != nil {
+		return nil, err
+	}
+
+	return &idempotency.Record{
+		Key:         item.Key,
+		Fingerprint: item.Fingerprint,
+		Expires:     time.Unix(item.Expires, 0),
+		Response:    item.Response,
+	}, nil
+}
+
+// Complete stores the response for a reserved key, keeping the record until expires. Does nothing if the key isn't
+// reserved.
+func (s *IdempotencyStore) Complete(ctx context.Context, key string, res idempotency.Response, expires time.Time) error {
+	update := expression.Set(expression.Name("response"), expression.Value(&res)).
+		Set(expression.Name("expires"), expression.Value(expires.Unix()))
+
+	exp, err := expression.NewBuilder().
+		WithUpdate(update).
+		WithCondition(expression.AttributeExists(expression.Name("key"))).
+		Build()
+	if err != nil {
+		return err
+	}
+
+	_, err = s.dynamoDb.UpdateItemRequest(&dynamodb.UpdateItemInput{
+		ConditionExpression:       exp.Condition(),
+		ExpressionAttributeNames:  exp.Names(),
+		ExpressionAttributeValues: exp.Values(),
+		Key: map[string]dynamodb.AttributeValue{
+			"key": {S: &key},
+		},
+		TableName:        &s.table,
+		UpdateExpression: exp.Update(),
+	}).Send(ctx)
+
+	if aerr, ok := err.(awserr.Error); ok && aerr.Code() == dynamodb.ErrCodeConditionalCheckFailedException {
+		return nil
+	}
+
+	if err != nil {
+		return requestError(ctx, err)
+	}
+
+	return nil
+}
+
+// Release removes a reservation.
+func (s *IdempotencyStore) Release(ctx context.Context, key string) error {
+	_, err := s.dynamoDb.DeleteItemRequest(&dynamodb.DeleteItemInput{
+		Key: map[string]dynamodb.AttributeValue{
+			"key": {S: &key},
+		},
+		TableName: &s.table,
+	}).Send(ctx)
+
+	if err != nil {
+		return requestError(ctx, err)
+	}
+
+	return nil
+}
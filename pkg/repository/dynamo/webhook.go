@@ -0,0 +1,213 @@
+package dynamo
+
+import (
+	"context"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/dynamodbattribute"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/dynamodbiface"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/expression"
+	"github.com/rejlersembriq/hooked/pkg/webhook"
+)
+
+// WebhookRepository implements webhook.Repository in a DynamoDb table with the string hash key "id".
+type WebhookRepository struct {
+	dynamoDb dynamodbiface.ClientAPI
+	table    string
+}
+
+// NewWebhookRepository returns a repository using the provided table.
+func NewWebhookRepository(dynamoIface dynamodbiface.ClientAPI, tableName string) *WebhookRepository {
+	return &WebhookRepository{
+		dynamoDb: dynamoIface,
+		table:    tableName,
+	}
+}
+
+// Create stores a new hook.
+func (r *WebhookRepository) Create(ctx context.Context, h webhook.Hook) (*webhook.Hook, error) {
+	item, err := dynamodbattribute.MarshalMap(&h)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = r.dynamoDb.PutItemRequest(&dynamodb.PutItemInput{
+		Item:      item,
+		TableName: &r.table,
+	}).Send(ctx)
+	if err != nil {
+		return nil, requestError(ctx, err)
+	}
+
+	return r.Get(ctx, h.ID)
+}
+
+// Get retrieves a hook. The read is consistent, so a hook is found right after it's created.
+func (r *WebhookRepository) Get(ctx context.Context, id string) (*webhook.Hook, error) {
+	res, err := r.dynamoDb.GetItemRequest(&dynamodb.GetItemInput{
+		ConsistentRead: aws.Bool(true),
+		Key: map[string]dynamodb.AttributeValue{
+			"id": {S: &id},
+		},
+		TableName: &r.table,
+	}).Send(ctx)
+	if err != nil {
+		return nil, requestError(ctx, err)
+	}
+
+	if res.Item == nil {
+		return nil, webhook.ErrNotExist
+	}
+
+	var h webhook.Hook
+	if err := dynamodbattribute.UnmarshalMap(res.Item, &h); err != nil {
+		return nil, err
+	}
+
+	return &h, nil
+}
+
+// List retrieves all hooks.
+func (r *WebhookRepository) List(ctx context.Context) ([]*webhook.Hook, error) {
+	hs := make([]*webhook.Hook, 0)
+
+	paginator := dynamodb.NewScanPaginator(r.dynamoDb.ScanRequest(&dynamodb.ScanInput{
+		ConsistentRead: aws.Bool(true),
+		TableName:      &r.table,
+	}))
+
+	for paginator.Next(ctx) {
+		var page []*webhook.Hook
+		if err := dynamodbattribute.UnmarshalListOfMaps(paginator.CurrentPage().Items, &page); err != nil {
+			return nil, err
+		}
+
+		hs = append(hs, page...)
+	}
+
+	if err := paginator.Err(); err != nil {
+		return nil, requestError(ctx, err)
+	}
+
+	webhook.SortHooks(hs)
+
+	return hs, nil
+}
+
+// Delete deletes a hook. The delete is conditional, so a missing hook is reported.
+func (r *WebhookRepository) Delete(ctx context.Context, id string) error {
+	_, err := r.dynamoDb.DeleteItemRequest(&dynamodb.DeleteItemInput{
+		ConditionExpression: aws.String("attribute_exists(id)"),
+		Key: map[string]dynamodb.AttributeValue{
+			"id": {S: &id},
+		},
+		TableName: &r.table,
+	}).Send(ctx)
+
+	if isConditionFailed(err) {
+		return webhook.ErrNotExist
+	}
+
+	if err != nil {
+		return requestError(ctx, err)
+	}
+
+	return nil
+}
+
+// DeliveryRepository implements webhook.DeliveryRepository in a DynamoDb table with the string hash key "hook" and
+// the string range key "id", so the deliveries of a hook are read by a single query.
+type DeliveryRepository struct {
+	dynamoDb dynamodbiface.ClientAPI
+	table    string
+}
+
+// NewDeliveryRepository returns a repository using the provided table.
+func NewDeliveryRepository(dynamoIface dynamodbiface.ClientAPI, tableName string) *DeliveryRepository {
+	return &DeliveryRepository{
+		dynamoDb: dynamoIface,
+		table:    tableName,
+	}
+}
+
+// Save creates or replaces a delivery.
+func (r *DeliveryRepository) Save(ctx context.Context, d webhook.Delivery) (*webhook.Delivery, error) {
+	item, err := dynamodbattribute.MarshalMap(&d)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = r.dynamoDb.PutItemRequest(&dynamodb.PutItemInput{
+		Item:      item,
+		TableName: &r.table,
+	}).Send(ctx)
+	if err != nil {
+		return nil, requestError(ctx, err)
+	}
+
+	saved := d.Clone()
+	return &saved, nil
+}
+
+// Get retrieves a delivery.
+func (r *DeliveryRepository) Get(ctx context.Context, hook, id string) (*webhook.Delivery, error) {
+	res, err := r.dynamoDb.GetItemRequest(&dynamodb.GetItemInput{
+		ConsistentRead: aws.Bool(true),
+		Key: map[string]dynamodb.AttributeValue{
+			"hook": {S: &hook},
+			"id":   {S: &id},
+		},
+		TableName: &r.table,
+	}).Send(ctx)
+	if err != nil {
+		return nil, requestError(ctx, err)
+	}
+
+	if res.Item == nil {
+		return nil, webhook.ErrNotExist
+	}
+
+	var d webhook.Delivery
+	if err := dynamodbattribute.UnmarshalMap(res.Item, &d); err != nil {
+		return nil, err
+	}
+
+	return &d, nil
+}
+
+// List returns the deliveries of a hook.
+func (r *DeliveryRepository) List(ctx context.Context, hook string) ([]*webhook.Delivery, error) {
+	exp, err := expression.NewBuilder().
+		WithKeyCondition(expression.Key("hook").Equal(expression.Value(hook))).
+		Build()
+	if err != nil {
+		return nil, err
+	}
+
+	paginator := dynamodb.NewQueryPaginator(r.dynamoDb.QueryRequest(&dynamodb.QueryInput{
+		ConsistentRead:            aws.Bool(true),
+		ExpressionAttributeNames:  exp.Names(),
+		ExpressionAttributeValues: exp.Values(),
+		KeyConditionExpression:    exp.KeyCondition(),
+		TableName:                 &r.table,
+	}))
+
+	ds := make([]*webhook.Delivery, 0)
+	for paginator.Next(ctx) {
+		var page []*webhook.Delivery
+		if err := dynamodbattribute.UnmarshalListOfMaps(paginator.CurrentPage().Items, &page); err != nil {
+			return nil, err
+		}
+
+		ds = append(ds, page...)
+	}
+
+	if err := paginator.Err(); err != nil {
+		return nil, requestError(ctx, err)
+	}
+
+	// The range key is a random id, so the query order isn't the creation order.
+	webhook.SortDeliveries(ds)
+
+	return ds, nil
+}
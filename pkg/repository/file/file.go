@@ -0,0 +1,465 @@
+package file
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/rejlersembriq/hooked/pkg/participant"
+	"go.uber.org/zap"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// The log is compacted when it holds more than minCompactRecords records and more stale than live records.
+const minCompactRecords = 1000
+
+// ErrCorrupt is returned when opening a log with a damaged record that isn't the last one. A damaged last record is
+// the result of a crash during write and is discarded.
+var ErrCorrupt = errors.New("corrupt log record")
+
+const (
+	opPut    = "put"
+	opDelete = "delete"
+)
+
+// record is a single change in the log. Put holds the complete participant, delete only the id.
+type record struct {
+	Op          string                   `json:"op"`
+	ID          string                   `json:"id"`
+	Participant *participant.Participant `json:"participant,omitempty"`
+}
+
+// File implements repository persisting participants to an append-only log on local disk.
+// Every change is appended as a checksummed line and synced before the call returns, so acknowledged writes survive
+// a crash. All participants are kept in memory. The log is rewritten without stale records when it grows, see
+// Compact. Safe for concurrent use within a single process.
+type File struct {
+	mu           sync.RWMutex
+	path         string
+	log          *os.File
+	size         int64
+	records      int
+	participants map[string]*participant.Participant
+}
+
+// New opens the log at path, creating it if it doesn't exist, and replays it to restore the participants.
+func New(path string) (*File, error) {
+	f := &File{
+		path:         path,
+		participants: make(map[string]*participant.Participant),
+	}
+
+	log, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, err
+	}
+
+	valid, err := f.replay(log)
+	if err != nil {
+		log.Close()
+		return nil, err
+	}
+
+	// Drop a partially written record left by a crash, so new records are appended after the last valid one.
+	if err := log.Truncate(valid); err != nil {
+		log.Close()
+		return nil, err
+	}
+
+	if _, err := log.Seek(valid, io.SeekStart); err != nil {
+		log.Close()
+		return nil, err
+	}
+
+	f.log = log
+	f.size = valid
+
+	return f, nil
+}
+
+// replay applies all records in the log and returns the offset after the last valid record.
+func (f *File) replay(r io.Reader) (int64, error) {
+	reader := bufio.NewReader(r)
+
+	var offset int64
+	var damaged error
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err == io.EOF {
+			// A record is always terminated by newline. Anything else is a partial write.
+			return offset, nil
+		}
+		if err != nil {
+			return 0, err
+		}
+
+		// Only the last record may be damaged. Finding a valid record after a damaged one means the log is corrupt.
+		if damaged != nil {
+			return 0, damaged
+		}
+
+		rec, err := decodeRecord(line)
+		if err != nil {
+			damaged = fmt.Errorf("%w at offset %d: %v", ErrCorrupt, offset, err)
+			continue
+		}
+
+		f.apply(rec)
+		f.records++
+		offset += int64(len(line))
+	}
+}
+
+func (f *File) apply(rec record) {
+	switch rec.Op {
+	case opPut:
+		f.participants[rec.ID] = rec.Participant
+	case opDelete:
+		delete(f.participants, rec.ID)
+	}
+}
+
+// encodeRecord formats a record as a line: crc32 of the json, a space, the json and newline.
+func encodeRecord(rec record) ([]byte, error) {
+	b, err := json.Marshal(&rec)
+	if err != nil {
+		return nil, err
+	}
+
+	line := make([]byte, 0, len(b)+10)
+	line = append(line, fmt.Sprintf("%08x ", crc32.ChecksumIEEE(b))...)
+	line = append(line, b...)
+	line = append(line, '\n')
+
+	return line, nil
+}
+
+func decodeRecord(line []byte) (record, error) {
+	var rec record
+
+	line = bytes.TrimSuffix(line, []byte("\n"))
+	if len(line) < 9 || line[8] != ' ' {
+		return rec, errors.New("missing checksum")
+	}
+
+	sum, err := strconv.ParseUint(string(line[:8]), 16, 32)
+	if err != nil {
+		return rec, err
+	}
+
+	data := line[9:]
+	if crc32.ChecksumIEEE(data) != uint32(sum) {
+		return rec, errors.New("checksum mismatch")
+	}
+
+	if err := json.Unmarshal(data, &rec); err != nil {
+		return rec, err
+	}
+
+	if rec.ID == "" || (rec.Op == opPut && rec.Participant == nil) || (rec.Op != opPut && rec.Op != opDelete) {
+		return rec, errors.New("invalid record")
+	}
+
+	return rec, nil
+}
+
+// write appends the record to the log and syncs it to disk. Must be called with the lock held.
+func (f *File) write(rec record) error {
+	line, err := encodeRecord(rec)
+	if err != nil {
+		return err
+	}
+
+	if _, err := f.log.Write(line); err != nil {
+		f.rollback()
+		return err
+	}
+
+	if err := f.log.Sync(); err != nil {
+		f.rollback()
+		return err
+	}
+
+	f.size += int64(len(line))
+	f.records++
+
+	return nil
+}
+
+// rollback removes a partially written record, so later records aren't appended after it.
+func (f *File) rollback() {
+	if err := f.log.Truncate(f.size); err != nil {
+		zap.L().Error("Error truncating log after failed write.", zap.String("path", f.path), zap.String("error", err.Error()))
+		return
+	}
+
+	if _, err := f.log.Seek(f.size, io.SeekStart); err != nil {
+		zap.L().Error("Error seeking log after failed write.", zap.String("path", f.path), zap.String("error", err.Error()))
+	}
+}
+
+// Save persists a participant to the log.
+func (f *File) Save(ctx context.Context, p participant.Participant) (*participant.Participant, participant.Error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	p = p.Clone()
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	now := time.Now()
+
+	if p.ID != nil {
+		// Entry should exist. Update.
+		existing, exists := f.participants[*p.ID]
+		if !exists {
+			return nil, participant.ErrNotExist
+		}
+
+		// Compare and swap on the version, if provided.
+		if p.Version != nil && *p.Version != existing.CurrentVersion() {
+			return nil, participant.ErrVersionConflict
+		}
+
+		// Participants registered before emails were unique can keep a shared email.
+		if p.Email != nil && (existing.Email == nil || !participant.SameEmail(*existing.Email, *p.Email)) {
+			if owner, taken := f.emailOwner(*p.Email, existing.Event, *p.ID); taken {
+				return nil, &participant.EmailTakenError{ID: owner}
+			}
+		}
+
+		updated := existing.Clone()
+		updated.Apply(p)
+		updated.Updated = &now
+		version := existing.CurrentVersion() + 1
+		updated.Version = &version
+		p = updated
+	} else {
+		// Entry doesn't exist. Insert.
+		if p.Email != nil {
+			if owner, taken := f.emailOwner(*p.Email, p.Event, ""); taken {
+				return nil, &participant.EmailTakenError{ID: owner}
+			}
+		}
+
+		id := uuid.New().String()
+		p.ID = &id
+		p.Created = &now
+		p.Updated = &now
+		version := 1
+		p.Version = &version
+	}
+
+	if err := f.write(record{Op: opPut, ID: *p.ID, Participant: &p}); err != nil {
+		return nil, err
+	}
+
+	f.participants[*p.ID] = &p
+	f.compactIfNeeded()
+
+	saved := p.Clone()
+	return &saved, nil
+}
+
+// emailOwner returns the id of a participant other than id registered with email in the event. Must be called with
+// the lock held.
+func (f *File) emailOwner(email string, event *string, id string) (string, bool) {
+	normalized := participant.NormalizeEmail(email)
+	if normalized == "" {
+		return "", false
+	}
+
+	for k, p := range f.participants {
+		if k != id && participant.SameEvent(p.Event, event) && p.Email != nil && participant.NormalizeEmail(*p.Email) == normalized {
+			return k, true
+		}
+	}
+
+	return "", false
+}
+
+// Get retrieves a participant.
+func (f *File) Get(ctx context.Context, id string) (*participant.Participant, participant.Error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	p, exists := f.participants[id]
+	if !exists {
+		return nil, participant.ErrNotExist
+	}
+
+	cp := p.Clone()
+	return &cp, nil
+}
+
+// GetAll retrieves all participants.
+func (f *File) GetAll(ctx context.Context) ([]*participant.Participant, participant.Error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	var ps []*participant.Participant
+	for _, v := range f.participants {
+		cp := v.Clone()
+		ps = append(ps, &cp)
+	}
+
+	return ps, nil
+}
+
+// Query retrieves a page of participants matching the query. Without a sort field participants are ordered by id.
+func (f *File) Query(ctx context.Context, q participant.Query) (*participant.Page, participant.Error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	f.mu.RLock()
+	ps := make([]*participant.Participant, 0, len(f.participants))
+	for _, v := range f.participants {
+		if q.Match(v) {
+			cp := v.Clone()
+			ps = append(ps, &cp)
+		}
+	}
+	f.mu.RUnlock()
+
+	return q.Paginate(ps)
+}
+
+// Delete removes and entry matching the provided id.
+func (f *File) Delete(ctx context.Context, id string) participant.Error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if _, exists := f.participants[id]; !exists {
+		return participant.ErrNotExist
+	}
+
+	if err := f.write(record{Op: opDelete, ID: id}); err != nil {
+		return err
+	}
+
+	delete(f.participants, id)
+	f.compactIfNeeded()
+
+	return nil
+}
+
+// Compact rewrites the log with a single record per live participant.
+func (f *File) Compact() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.compact()
+}
+
+// Close closes the log. The repository can't be used afterwards.
+func (f *File) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.log.Close()
+}
+
+// compactIfNeeded compacts when stale records outnumber live ones. A failed compaction leaves the current log in
+// place and is retried on a later write. Must be called with the lock held.
+func (f *File) compactIfNeeded() {
+	if f.records < minCompactRecords || f.records < 2*len(f.participants) {
+		return
+	}
+
+	if err := f.compact(); err != nil {
+		zap.L().Error("Error compacting log.", zap.String("path", f.path), zap.String("error", err.Error()))
+	}
+}
+
+// compact writes the live participants to a temporary file and renames it over the log, so a crash during
+// compaction leaves either the old or the new log intact. Must be called with the lock held.
+func (f *File) compact() error {
+	tmp, err := os.OpenFile(f.path+".compact", os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+
+	w := bufio.NewWriter(tmp)
+	for id, p := range f.participants {
+		line, err := encodeRecord(record{Op: opPut, ID: id, Participant: p})
+		if err != nil {
+			tmp.Close()
+			os.Remove(tmp.Name())
+			return err
+		}
+
+		if _, err := w.Write(line); err != nil {
+			tmp.Close()
+			os.Remove(tmp.Name())
+			return err
+		}
+	}
+
+	if err := w.Flush(); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	if err := os.Rename(tmp.Name(), f.path); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	syncDir(filepath.Dir(f.path))
+
+	// The renamed file is positioned at its end, ready for appending.
+	size, err := tmp.Seek(0, io.SeekCurrent)
+	if err != nil {
+		tmp.Close()
+		return err
+	}
+
+	f.log.Close()
+	f.log = tmp
+	f.size = size
+	f.records = len(f.participants)
+
+	return nil
+}
+
+// syncDir makes a rename durable. Not supported on all platforms, so errors are ignored.
+func syncDir(dir string) {
+	d, err := os.Open(dir)
+	if err != nil {
+		return
+	}
+	d.Sync()
+	d.Close()
+}
@@ -0,0 +1,200 @@
+package file
+
+import (
+	"context"
+	"errors"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/rejlersembriq/hooked/pkg/participant"
+	"github.com/rejlersembriq/hooked/pkg/repository/repotest"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func tempPath(t *testing.T) (string, func()) {
+	dir, err := ioutil.TempDir("", "hooked-file-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return filepath.Join(dir, "participants.log"), func() { os.RemoveAll(dir) }
+}
+
+func newTestRepo(t *testing.T) (*File, func()) {
+	path, cleanup := tempPath(t)
+
+	repo, err := New(path)
+	if err != nil {
+		cleanup()
+		t.Fatal(err)
+	}
+
+	return repo, func() {
+		repo.Close()
+		cleanup()
+	}
+}
+
+func TestFile_Conformance(t *testing.T) {
+	repotest.Run(t, func(t *testing.T) (participant.Repository, func()) {
+		return newTestRepo(t)
+	})
+}
+
+func TestFile_PersistedAcrossReopen(t *testing.T) {
+	path, cleanup := tempPath(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	repo, err := New(path)
+	assert.NoError(t, err)
+
+	kept, err := repo.Save(ctx, participant.Participant{Name: aws.String("Kept"), Score: aws.Int(1)})
+	assert.NoError(t, err)
+	_, err = repo.Save(ctx, participant.Participant{ID: kept.ID, Score: aws.Int(2)})
+	assert.NoError(t, err)
+
+	deleted, err := repo.Save(ctx, participant.Participant{Name: aws.String("Deleted")})
+	assert.NoError(t, err)
+	assert.NoError(t, repo.Delete(ctx, *deleted.ID))
+	assert.NoError(t, repo.Close())
+
+	reopened, err := New(path)
+	assert.NoError(t, err)
+	defer reopened.Close()
+
+	p, err := reopened.Get(ctx, *kept.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, "Kept", *p.Name)
+	assert.Equal(t, 2, *p.Score)
+	assert.True(t, kept.Created.Equal(*p.Created))
+
+	_, err = reopened.Get(ctx, *deleted.ID)
+	assert.True(t, errors.Is(err, participant.ErrNotExist))
+}
+
+func TestFile_TornWriteDiscarded(t *testing.T) {
+	path, cleanup := tempPath(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	repo, err := New(path)
+	assert.NoError(t, err)
+	saved, err := repo.Save(ctx, participant.Participant{Name: aws.String("Saved")})
+	assert.NoError(t, err)
+	assert.NoError(t, repo.Close())
+
+	// Simulate a crash in the middle of writing a record.
+	line, err := encodeRecord(record{Op: opDelete, ID: *saved.ID})
+	assert.NoError(t, err)
+	appendToFile(t, path, line[:len(line)/2])
+
+	repo, err = New(path)
+	assert.NoError(t, err)
+
+	_, err = repo.Get(ctx, *saved.ID)
+	assert.NoError(t, err)
+
+	// New records should be readable after the discarded one.
+	second, err := repo.Save(ctx, participant.Participant{Name: aws.String("Second")})
+	assert.NoError(t, err)
+	assert.NoError(t, repo.Close())
+
+	repo, err = New(path)
+	assert.NoError(t, err)
+	defer repo.Close()
+
+	ps, err := repo.GetAll(ctx)
+	assert.NoError(t, err)
+	assert.Len(t, ps, 2)
+
+	_, err = repo.Get(ctx, *second.ID)
+	assert.NoError(t, err)
+}
+
+func TestFile_CorruptRecord(t *testing.T) {
+	path, cleanup := tempPath(t)
+	defer cleanup()
+
+	valid, err := encodeRecord(record{Op: opDelete, ID: "someId"})
+	assert.NoError(t, err)
+
+	corrupt := append([]byte(nil), valid...)
+	corrupt[len(corrupt)-3] = 'X'
+
+	appendToFile(t, path, corrupt)
+	appendToFile(t, path, valid)
+
+	_, err = New(path)
+	assert.True(t, errors.Is(err, ErrCorrupt))
+}
+
+func TestFile_Compact(t *testing.T) {
+	path, cleanup := tempPath(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	repo, err := New(path)
+	assert.NoError(t, err)
+
+	saved, err := repo.Save(ctx, participant.Participant{Name: aws.String("Test"), Score: aws.Int(0)})
+	assert.NoError(t, err)
+	for i := 1; i <= 10; i++ {
+		_, err := repo.Save(ctx, participant.Participant{ID: saved.ID, Score: aws.Int(i)})
+		assert.NoError(t, err)
+	}
+
+	before := fileSize(t, path)
+	assert.NoError(t, repo.Compact())
+	assert.True(t, fileSize(t, path) < before)
+
+	// Writes after compaction go to the new log.
+	_, err = repo.Save(ctx, participant.Participant{ID: saved.ID, Score: aws.Int(11)})
+	assert.NoError(t, err)
+	assert.NoError(t, repo.Close())
+
+	repo, err = New(path)
+	assert.NoError(t, err)
+	defer repo.Close()
+
+	p, err := repo.Get(ctx, *saved.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, 11, *p.Score)
+	assert.Equal(t, 2, repo.records)
+}
+
+func TestFile_AutomaticCompaction(t *testing.T) {
+	repo, cleanup := newTestRepo(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	saved, err := repo.Save(ctx, participant.Participant{Score: aws.Int(0)})
+	assert.NoError(t, err)
+	for i := 0; i < minCompactRecords; i++ {
+		_, err := repo.Save(ctx, participant.Participant{ID: saved.ID, Score: aws.Int(i)})
+		assert.NoError(t, err)
+	}
+
+	assert.True(t, repo.records < minCompactRecords)
+}
+
+func appendToFile(t *testing.T, path string, b []byte) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	if _, err := f.Write(b); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func fileSize(t *testing.T, path string) int64 {
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return info.Size()
+}
@@ -0,0 +1,115 @@
+package memory
+
+import (
+	"context"
+	"github.com/rejlersembriq/hooked/pkg/apikey"
+	"sync"
+	"time"
+)
+
+// APIKeyRepository implements apikey.Repository in memory. Safe for concurrent use.
+type APIKeyRepository struct {
+	mu   sync.RWMutex
+	keys map[string]apikey.Key
+}
+
+// NewAPIKeyRepository returns an empty repository.
+func NewAPIKeyRepository() *APIKeyRepository {
+	return &APIKeyRepository{
+		keys: make(map[string]apikey.Key),
+	}
+}
+
+// Create stores a new key.
+func (r *APIKeyRepository) Create(ctx context.Context, k apikey.Key) (*apikey.Key, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	k = k.Clone()
+
+	r.mu.Lock()
+	r.keys[k.ID] = k
+	r.mu.Unlock()
+
+	saved := k.Clone()
+	return &saved, nil
+}
+
+// Get retrieves a key.
+func (r *APIKeyRepository) Get(ctx context.Context, id string) (*apikey.Key, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	k, exists := r.keys[id]
+	if !exists {
+		return nil, apikey.ErrNotExist
+	}
+
+	cp := k.Clone()
+	return &cp, nil
+}
+
+// List retrieves all keys.
+func (r *APIKeyRepository) List(ctx context.Context) ([]*apikey.Key, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	r.mu.RLock()
+	ks := make([]*apikey.Key, 0, len(r.keys))
+	for _, k := range r.keys {
+		cp := k.Clone()
+		ks = append(ks, &cp)
+	}
+	r.mu.RUnlock()
+
+	apikey.SortKeys(ks)
+
+	return ks, nil
+}
+
+// Rotate replaces the hash of a key.
+func (r *APIKeyRepository) Rotate(ctx context.Context, id, hash string, at time.Time) (*apikey.Key, error) {
+	return r.update(ctx, id, func(k *apikey.Key) {
+		k.Hash = hash
+		k.Rotated = &at
+	})
+}
+
+// Revoke revokes a key.
+func (r *APIKeyRepository) Revoke(ctx context.Context, id string, at time.Time) (*apikey.Key, error) {
+	return r.update(ctx, id, func(k *apikey.Key) {
+		k.Revoked = &at
+	})
+}
+
+// update applies fn to a key not revoked, under the write lock.
+func (r *APIKeyRepository) update(ctx context.Context, id string, fn func(k *apikey.Key)) (*apikey.Key, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	k, exists := r.keys[id]
+	if !exists {
+		return nil, apikey.ErrNotExist
+	}
+
+	if k.Revoked != nil {
+		return nil, apikey.ErrRevoked
+	}
+
+	k = k.Clone()
+	fn(&k)
+	r.keys[id] = k
+
+	cp := k.Clone()
+	return &cp, nil
+}
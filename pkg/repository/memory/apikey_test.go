@@ -0,0 +1,13 @@
+package memory
+
+import (
+	"github.com/rejlersembriq/hooked/pkg/apikey"
+	"github.com/rejlersembriq/hooked/pkg/apikey/apikeytest"
+	"testing"
+)
+
+func TestAPIKeyRepository_Conformance(t *testing.T) {
+	apikeytest.Run(t, func(t *testing.T) (apikey.Repository, func()) {
+		return NewAPIKeyRepository(), func() {}
+	})
+}
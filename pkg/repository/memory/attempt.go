@@ -0,0 +1,76 @@
+package memory
+
+import (
+	"context"
+	"github.com/google/uuid"
+	"github.com/rejlersembriq/hooked/pkg/attempt"
+	"sync"
+	"time"
+)
+
+// AttemptRepository implements attempt.Repository in memory. Safe for concurrent use.
+type AttemptRepository struct {
+	mu       sync.RWMutex
+	attempts map[string][]attempt.Attempt
+}
+
+// NewAttemptRepository returns an empty repository.
+func NewAttemptRepository() *AttemptRepository {
+	return &AttemptRepository{
+		attempts: make(map[string][]attempt.Attempt),
+	}
+}
+
+// Save stores a new attempt.
+func (r *AttemptRepository) Save(ctx context.Context, a attempt.Attempt) (*attempt.Attempt, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	a.ID = uuid.New().String()
+	if a.Created.IsZero() {
+		a.Created = time.Now()
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.attempts[a.Participant] = append(r.attempts[a.Participant], a)
+
+	return &a, nil
+}
+
+// List returns the attempts of a participant, oldest first.
+func (r *AttemptRepository) List(ctx context.Context, participantID string) ([]*attempt.Attempt, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	stored := r.attempts[participantID]
+	as := make([]*attempt.Attempt, len(stored))
+	for i := range stored {
+		a := stored[i]
+		as[i] = &a
+	}
+
+	attempt.Sort(as)
+
+	return as, nil
+}
+
+// DeleteAll removes the attempts of a participant.
+func (r *AttemptRepository) DeleteAll(ctx context.Context, participantID string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.attempts, participantID)
+
+	return nil
+}
@@ -0,0 +1,13 @@
+package memory
+
+import (
+	"github.com/rejlersembriq/hooked/pkg/attempt"
+	"github.com/rejlersembriq/hooked/pkg/attempt/attempttest"
+	"testing"
+)
+
+func TestAttemptRepository_Conformance(t *testing.T) {
+	attempttest.Run(t, func(t *testing.T) (attempt.Repository, func()) {
+		return NewAttemptRepository(), func() {}
+	})
+}
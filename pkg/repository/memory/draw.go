@@ -0,0 +1,78 @@
+package memory
+
+import (
+	"context"
+	"github.com/rejlersembriq/hooked/pkg/draw"
+	"sync"
+)
+
+// DrawRepository implements draw.Repository in memory. Safe for concurrent use.
+type DrawRepository struct {
+	mu    sync.RWMutex
+	draws map[string]draw.Draw
+}
+
+// NewDrawRepository returns an empty repository.
+func NewDrawRepository() *DrawRepository {
+	return &DrawRepository{
+		draws: make(map[string]draw.Draw),
+	}
+}
+
+// Create stores a new draw.
+func (r *DrawRepository) Create(ctx context.Context, d draw.Draw) (*draw.Draw, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	d = d.Clone()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.draws[d.ID]; exists {
+		return nil, draw.ErrExists
+	}
+
+	r.draws[d.ID] = d
+
+	saved := d.Clone()
+	return &saved, nil
+}
+
+// Get retrieves a draw.
+func (r *DrawRepository) Get(ctx context.Context, id string) (*draw.Draw, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	d, exists := r.draws[id]
+	if !exists {
+		return nil, draw.ErrNotExist
+	}
+
+	cp := d.Clone()
+	return &cp, nil
+}
+
+// List retrieves all draws.
+func (r *DrawRepository) List(ctx context.Context) ([]*draw.Draw, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	r.mu.RLock()
+	ds := make([]*draw.Draw, 0, len(r.draws))
+	for _, d := range r.draws {
+		cp := d.Clone()
+		ds = append(ds, &cp)
+	}
+	r.mu.RUnlock()
+
+	draw.Sort(ds)
+
+	return ds, nil
+}
@@ -0,0 +1,13 @@
+package memory
+
+import (
+	"github.com/rejlersembriq/hooked/pkg/draw"
+	"github.com/rejlersembriq/hooked/pkg/draw/drawtest"
+	"testing"
+)
+
+func TestDrawRepository_Conformance(t *testing.T) {
+	drawtest.Run(t, func(t *testing.T) (draw.Repository, func()) {
+		return NewDrawRepository(), func() {}
+	})
+}
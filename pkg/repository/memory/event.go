@@ -0,0 +1,156 @@
+package memory
+
+import (
+	"context"
+	"github.com/rejlersembriq/hooked/pkg/event"
+	"sync"
+	"time"
+)
+
+// EventRepository implements event.Repository in memory. Safe for concurrent use.
+type EventRepository struct {
+	mu     sync.RWMutex
+	events map[string]event.Event
+}
+
+// NewEventRepository returns an empty repository.
+func NewEventRepository() *EventRepository {
+	return &EventRepository{
+		events: make(map[string]event.Event),
+	}
+}
+
+// Create stores a new event.
+func (r *EventRepository) Create(ctx context.Context, e event.Event) (*event.Event, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	e = e.Clone()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.events[e.Slug]; exists {
+		return nil, event.ErrExists
+	}
+
+	now := time.Now()
+	e.Created = &now
+	e.Updated = &now
+
+	r.events[e.Slug] = e
+
+	saved := e.Clone()
+	return &saved, nil
+}
+
+// Update replaces an event.
+func (r *EventRepository) Update(ctx context.Context, e event.Event) (*event.Event, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	e = e.Clone()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	existing, exists := r.events[e.Slug]
+	if !exists {
+		return nil, event.ErrNotExist
+	}
+
+	now := time.Now()
+	e.Created = existing.Created
+	e.Updated = &now
+
+	r.events[e.Slug] = e
+
+	saved := e.Clone()
+	return &saved, nil
+}
+
+// Close sets the status of an open event ending at end to closed.
+func (r *EventRepository) Close(ctx context.Context, slug string, end time.Time) (*event.Event, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	e, exists := r.events[slug]
+	if !exists {
+		return nil, event.ErrNotExist
+	}
+
+	if e.Status != event.StatusOpen || e.End == nil || !e.End.Equal(end) {
+		return nil, event.ErrChanged
+	}
+
+	now := time.Now()
+	e = e.Clone()
+	e.Status = event.StatusClosed
+	e.Updated = &now
+
+	r.events[slug] = e
+
+	saved := e.Clone()
+	return &saved, nil
+}
+
+// Get retrieves an event.
+func (r *EventRepository) Get(ctx context.Context, slug string) (*event.Event, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	e, exists := r.events[slug]
+	if !exists {
+		return nil, event.ErrNotExist
+	}
+
+	cp := e.Clone()
+	return &cp, nil
+}
+
+// List retrieves all events.
+func (r *EventRepository) List(ctx context.Context) ([]*event.Event, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	r.mu.RLock()
+	es := make([]*event.Event, 0, len(r.events))
+	for _, e := range r.events {
+		cp := e.Clone()
+		es = append(es, &cp)
+	}
+	r.mu.RUnlock()
+
+	event.Sort(es)
+
+	return es, nil
+}
+
+// Delete removes an event.
+func (r *EventRepository) Delete(ctx context.Context, slug string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.events[slug]; !exists {
+		return event.ErrNotExist
+	}
+
+	delete(r.events, slug)
+
+	return nil
+}
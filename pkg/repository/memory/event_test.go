@@ -0,0 +1,13 @@
+package memory
+
+import (
+	"github.com/rejlersembriq/hooked/pkg/event"
+	"github.com/rejlersembriq/hooked/pkg/event/eventtest"
+	"testing"
+)
+
+func TestEventRepository_Conformance(t *testing.T) {
+	eventtest.Run(t, func(t *testing.T) (event.Repository, func()) {
+		return NewEventRepository(), func() {}
+	})
+}
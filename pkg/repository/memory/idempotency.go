@@ -0,0 +1,123 @@
+package memory
+
+import (
+	"context"
+	"github.com/rejlersembriq/hooked/pkg/idempotency"
+	"sync"
+	"time"
+)
+
+// Expired records are removed at most this often.
+const purgeInterval = time.Minute
+
+// IdempotencyStore implements idempotency.Store in memory. Safe for concurrent use.
+type IdempotencyStore struct {
+	mu        sync.Mutex
+	records   map[string]idempotency.Record
+	lastPurge time.Time
+}
+
+// NewIdempotencyStore returns an empty store.
+func NewIdempotencyStore() *IdempotencyStore {
+	return &IdempotencyStore{
+		records: make(map[string]idempotency.Record),
+	}
+}
+
+// Reserve stores rec unless an unexpired record with the same key exists.
+func (s *IdempotencyStore) Reserve(ctx context.Context, rec idempotency.Record) (*idempotency.Record, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	s.purge(now)
+
+	if existing, exists := s.records[rec.Key]; exists && !existing.Expired(now) {
+		cp := copyRecord(existing)
+		return &cp, nil
+	}
+
+	rec.Response = nil
+	s.records[rec.Key] = rec
+
+	return nil, nil
+}
+
+// Complete stores the response for a reserved key, keeping the record until expires. Does nothing if the key isn't
+// reserved.
+func (s *IdempotencyStore) Complete(ctx context.Context, key string, res idempotency.Response, expires time.Time) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rec, exists := s.records[key]
+	if !exists {
+		return nil
+	}
+
+	res = copyResponse(res)
+	rec.Response = &res
+	rec.Expires = expires
+	s.records[key] = rec
+
+	return nil
+}
+
+// Release removes a reservation.
+func (s *IdempotencyStore) Release(ctx context.Context, key string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.records, key)
+
+	return nil
+}
+
+// purge removes expired records. Must be called with the lock held.
+func (s *IdempotencyStore) purge(now time.Time) {
+	if now.Sub(s.lastPurge) < purgeInterval {
+		return
+	}
+	s.lastPurge = now
+
+	for k, rec := range s.records {
+		if rec.Expired(now) {
+			delete(s.records, k)
+		}
+	}
+}
+
+func copyRecord(rec idempotency.Record) idempotency.Record {
+	if rec.Response != nil {
+		res := copyResponse(*rec.Response)
+		rec.Response = &res
+	}
+	return rec
+}
+
+func copyResponse(res idempotency.Response) idempotency.Response {
+	cp := idempotency.Response{
+		Status: res.Status,
+		Body:   append([]byte(nil), res.Body...),
+	}
+
+	if res.Header != nil {
+		cp.Header = make(map[string]string, len(res.Header))
+		for k, v := range res.Header {
+			cp.Header[k] = v
+		}
+	}
+
+	return cp
+}
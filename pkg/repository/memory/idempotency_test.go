@@ -0,0 +1,13 @@
+package memory
+
+import (
+	"github.com/rejlersembriq/hooked/pkg/idempotency"
+	"github.com/rejlersembriq/hooked/pkg/idempotency/idempotencytest"
+	"testing"
+)
+
+func TestIdempotencyStore_Conformance(t *testing.T) {
+	idempotencytest.Run(t, func(t *testing.T) (idempotency.Store, func()) {
+		return NewIdempotencyStore(), func() {}
+	})
+}
@@ -1,13 +1,18 @@
 package memory
 
 import (
+	"context"
 	"github.com/google/uuid"
 	"github.com/rejlersembriq/hooked/pkg/participant"
+	"sync"
 	"time"
 )
 
 // Memory implements repository persisting participants in memory.
+// It is safe for concurrent use. Participants are copied on the way in and out so callers never share
+// pointers with the stored entries.
 type Memory struct {
+	mu           sync.RWMutex
 	participants map[string]*participant.Participant
 }
 
@@ -19,7 +24,16 @@ func New() *Memory {
 }
 
 // Save persists a participant to memory.
-func (m *Memory) Save(p participant.Participant) (*participant.Participant, participant.Error) {
+func (m *Memory) Save(ctx context.Context, p participant.Participant) (*participant.Participant, participant.Error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	p = p.Clone()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
 	if p.ID != nil {
 		// Entry should exist. Update.
 		pp, exists := m.participants[*p.ID]
@@ -27,72 +41,134 @@ func (m *Memory) Save(p participant.Participant) (*participant.Participant, part
 			return nil, participant.ErrNotExist
 		}
 
-		if p.Name != nil {
-			pp.Name = p.Name
-		}
-
-		if p.Email != nil {
-			pp.Email = p.Email
-		}
-
-		if p.Phone != nil {
-			pp.Phone = p.Phone
-		}
-
-		if p.Org != nil {
-			pp.Org = p.Org
+		// Compare and swap on the version, if provided.
+		if p.Version != nil && *p.Version != pp.CurrentVersion() {
+			return nil, participant.ErrVersionConflict
 		}
 
-		if p.Score != nil {
-			pp.Score = p.Score
+		// Participants registered before emails were unique can keep a shared email.
+		if p.Email != nil && (pp.Email == nil || !participant.SameEmail(*pp.Email, *p.Email)) {
+			if owner, taken := m.emailOwner(*p.Email, pp.Event, *p.ID); taken {
+				return nil, &participant.EmailTakenError{ID: owner}
+			}
 		}
 
-		if p.Comment != nil {
-			pp.Comment = p.Comment
-		}
+		pp.Apply(p)
 
 		now := time.Now()
 		pp.Updated = &now
+		version := pp.CurrentVersion() + 1
+		pp.Version = &version
 
-		return pp, nil
+		saved := pp.Clone()
+		return &saved, nil
 
 	}
 
 	// Entry doesn't exist. Insert.
+	if p.Email != nil {
+		if owner, taken := m.emailOwner(*p.Email, p.Event, ""); taken {
+			return nil, &participant.EmailTakenError{ID: owner}
+		}
+	}
+
 	id := uuid.New().String()
 	p.ID = &id
 
 	now := time.Now()
 	p.Created = &now
 	p.Updated = &now
+	version := 1
+	p.Version = &version
 
 	m.participants[*p.ID] = &p
 
-	return &p, nil
+	saved := p.Clone()
+	return &saved, nil
+}
+
+// emailOwner returns the id of a participant other than id registered with email in the event. Must be called with
+// the lock held.
+func (m *Memory) emailOwner(email string, event *string, id string) (string, bool) {
+	normalized := participant.NormalizeEmail(email)
+	if normalized == "" {
+		return "", false
+	}
+
+	for k, p := range m.participants {
+		if k != id && participant.SameEvent(p.Event, event) && p.Email != nil && participant.NormalizeEmail(*p.Email) == normalized {
+			return k, true
+		}
+	}
+
+	return "", false
 }
 
 // Get retrieves a participant from memory.
-func (m *Memory) Get(id string) (*participant.Participant, participant.Error) {
+func (m *Memory) Get(ctx context.Context, id string) (*participant.Participant, participant.Error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
 	p, exists := m.participants[id]
 	if !exists {
 		return nil, participant.ErrNotExist
 	}
-	return p, nil
+
+	cp := p.Clone()
+	return &cp, nil
 }
 
 // GetAll retrieves all participants from memory.
-func (m *Memory) GetAll() ([]*participant.Participant, participant.Error) {
+func (m *Memory) GetAll(ctx context.Context) ([]*participant.Participant, participant.Error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
 	var ps []*participant.Participant
 
 	for _, v := range m.participants {
-		ps = append(ps, v)
+		cp := v.Clone()
+		ps = append(ps, &cp)
 	}
 
 	return ps, nil
 }
 
+// Query retrieves a page of participants matching the query. Without a sort field participants are ordered by id.
+func (m *Memory) Query(ctx context.Context, q participant.Query) (*participant.Page, participant.Error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	ps := make([]*participant.Participant, 0, len(m.participants))
+	for _, v := range m.participants {
+		if q.Match(v) {
+			cp := v.Clone()
+			ps = append(ps, &cp)
+		}
+	}
+	m.mu.RUnlock()
+
+	return q.Paginate(ps)
+}
+
 // Delete removes and entry matching the provided id.
-func (m *Memory) Delete(id string) participant.Error {
+func (m *Memory) Delete(ctx context.Context, id string) participant.Error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
 	if _, exists := m.participants[id]; !exists {
 		return participant.ErrNotExist
 	}
@@ -0,0 +1,67 @@
+package memory
+
+import (
+	"context"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/rejlersembriq/hooked/pkg/participant"
+	"github.com/rejlersembriq/hooked/pkg/repository/repotest"
+	"github.com/stretchr/testify/assert"
+	"sync"
+	"testing"
+)
+
+func TestMemory_Conformance(t *testing.T) {
+	repotest.Run(t, func(t *testing.T) (participant.Repository, func()) {
+		return New(), func() {}
+	})
+}
+
+func TestMemory_Save_InputNotRetained(t *testing.T) {
+	repo := New()
+	ctx := context.Background()
+
+	name := "Test Testson"
+	saved, err := repo.Save(ctx, participant.Participant{Name: &name})
+	assert.NoError(t, err)
+
+	name = "Changed"
+
+	p, err := repo.Get(ctx, *saved.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, "Test Testson", *p.Name)
+}
+
+// Run with -race to detect returned values sharing memory with stored entries.
+func TestMemory_ConcurrentReadWriteSameEntry(t *testing.T) {
+	repo := New()
+	ctx := context.Background()
+
+	saved, err := repo.Save(ctx, participant.Participant{Score: aws.Int(0)})
+	assert.NoError(t, err)
+
+	var wg sync.WaitGroup
+	for w := 0; w < 8; w++ {
+		wg.Add(2)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < 100; i++ {
+				_, err := repo.Save(ctx, participant.Participant{ID: saved.ID, Score: aws.Int(w*100 + i)})
+				assert.NoError(t, err)
+			}
+		}(w)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 100; i++ {
+				p, err := repo.Get(ctx, *saved.ID)
+				if assert.NoError(t, err) {
+					*p.Score = -1
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	p, err := repo.Get(ctx, *saved.ID)
+	assert.NoError(t, err)
+	assert.True(t, *p.Score >= 0)
+}
@@ -0,0 +1,160 @@
+package memory
+
+import (
+	"context"
+	"github.com/rejlersembriq/hooked/pkg/webhook"
+	"sync"
+)
+
+// WebhookRepository implements webhook.Repository in memory. Safe for concurrent use.
+type WebhookRepository struct {
+	mu    sync.RWMutex
+	hooks map[string]webhook.Hook
+}
+
+// NewWebhookRepository returns an empty repository.
+func NewWebhookRepository() *WebhookRepository {
+	return &WebhookRepository{
+		hooks: make(map[string]webhook.Hook),
+	}
+}
+
+// Create stores a new hook.
+func (r *WebhookRepository) Create(ctx context.Context, h webhook.Hook) (*webhook.Hook, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	h = h.Clone()
+
+	r.mu.Lock()
+	r.hooks[h.ID] = h
+	r.mu.Unlock()
+
+	saved := h.Clone()
+	return &saved, nil
+}
+
+// Get retrieves a hook.
+func (r *WebhookRepository) Get(ctx context.Context, id string) (*webhook.Hook, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	h, exists := r.hooks[id]
+	if !exists {
+		return nil, webhook.ErrNotExist
+	}
+
+	cp := h.Clone()
+	return &cp, nil
+}
+
+// List retrieves all hooks.
+func (r *WebhookRepository) List(ctx context.Context) ([]*webhook.Hook, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	r.mu.RLock()
+	hs := make([]*webhook.Hook, 0, len(r.hooks))
+	for _, h := range r.hooks {
+		cp := h.Clone()
+		hs = append(hs, &cp)
+	}
+	r.mu.RUnlock()
+
+	webhook.SortHooks(hs)
+
+	return hs, nil
+}
+
+// Delete deletes a hook.
+func (r *WebhookRepository) Delete(ctx context.Context, id string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.hooks[id]; !exists {
+		return webhook.ErrNotExist
+	}
+
+	delete(r.hooks, id)
+
+	return nil
+}
+
+// DeliveryRepository implements webhook.DeliveryRepository in memory. Safe for concurrent use.
+type DeliveryRepository struct {
+	mu         sync.RWMutex
+	deliveries map[string]map[string]webhook.Delivery
+}
+
+// NewDeliveryRepository returns an empty repository.
+func NewDeliveryRepository() *DeliveryRepository {
+	return &DeliveryRepository{
+		deliveries: make(map[string]map[string]webhook.Delivery),
+	}
+}
+
+// Save creates or replaces a delivery.
+func (r *DeliveryRepository) Save(ctx context.Context, d webhook.Delivery) (*webhook.Delivery, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	d = d.Clone()
+
+	r.mu.Lock()
+	if r.deliveries[d.Hook] == nil {
+		r.deliveries[d.Hook] = make(map[string]webhook.Delivery)
+	}
+	r.deliveries[d.Hook][d.ID] = d
+	r.mu.Unlock()
+
+	saved := d.Clone()
+	return &saved, nil
+}
+
+// Get retrieves a delivery.
+func (r *DeliveryRepository) Get(ctx context.Context, hook, id string) (*webhook.Delivery, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	d, exists := r.deliveries[hook][id]
+	if !exists {
+		return nil, webhook.ErrNotExist
+	}
+
+	cp := d.Clone()
+	return &cp, nil
+}
+
+// List retrieves the deliveries of a hook.
+func (r *DeliveryRepository) List(ctx context.Context, hook string) ([]*webhook.Delivery, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	r.mu.RLock()
+	ds := make([]*webhook.Delivery, 0, len(r.deliveries[hook]))
+	for _, d := range r.deliveries[hook] {
+		cp := d.Clone()
+		ds = append(ds, &cp)
+	}
+	r.mu.RUnlock()
+
+	webhook.SortDeliveries(ds)
+
+	return ds, nil
+}
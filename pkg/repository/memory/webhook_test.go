@@ -0,0 +1,19 @@
+package memory
+
+import (
+	"github.com/rejlersembriq/hooked/pkg/webhook"
+	"github.com/rejlersembriq/hooked/pkg/webhook/webhooktest"
+	"testing"
+)
+
+func TestWebhookRepository_Conformance(t *testing.T) {
+	webhooktest.Run(t, func(t *testing.T) (webhook.Repository, func()) {
+		return NewWebhookRepository(), func() {}
+	})
+}
+
+func TestDeliveryRepository_Conformance(t *testing.T) {
+	webhooktest.RunDeliveries(t, func(t *testing.T) (webhook.DeliveryRepository, func()) {
+		return NewDeliveryRepository(), func() {}
+	})
+}
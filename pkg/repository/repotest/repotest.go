@@ -0,0 +1,685 @@
+// Package repotest provides a conformance test suite for participant.Repository implementations.
+//
+// Every implementation should pass the suite to guarantee they behave identically:
+//
+//	func TestMemory_Conformance(t *testing.T) {
+//		repotest.Run(t, func(t *testing.T) (participant.Repository, func()) {
+//			return memory.New(), func() {}
+//		})
+//	}
+package repotest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/google/uuid"
+	"github.com/rejlersembriq/hooked/pkg/participant"
+	"github.com/stretchr/testify/assert"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Factory returns a new, empty repository and a function releasing its resources, called when the test is done.
+type Factory func(t *testing.T) (participant.Repository, func())
+
+// Some stores only keep timestamps with second precision.
+const timePrecision = time.Second
+
+// Run runs all conformance tests against repositories created by newRepo. Each test gets its own repository.
+func Run(t *testing.T, newRepo Factory) {
+	tests := []struct {
+		name string
+		test func(t *testing.T, repo participant.Repository)
+	}{
+		{"Create", testCreate},
+		{"CreateIgnoresTimestamps", testCreateIgnoresTimestamps},
+		{"PartialUpdate", testPartialUpdate},
+		{"UpdateTimestamps", testUpdateTimestamps},
+		{"UpdateNotExist", testUpdateNotExist},
+		{"Version", testVersion},
+		{"VersionConflict", testVersionConflict},
+		{"VersionConflictNotExist", testVersionConflictNotExist},
+		{"EmailTaken", testEmailTaken},
+		{"EmailTakenUpdate", testEmailTakenUpdate},
+		{"EmailUnchanged", testEmailUnchanged},
+		{"EmailReleased", testEmailReleased},
+		{"EmailConcurrent", testEmailConcurrent},
+		{"EmailPerEvent", testEmailPerEvent},
+		{"EventImmutable", testEventImmutable},
+		{"GetNotExist", testGetNotExist},
+		{"Delete", testDelete},
+		{"DeleteNotExist", testDeleteNotExist},
+		{"GetAllComplete", testGetAllComplete},
+		{"GetAllEmpty", testGetAllEmpty},
+		{"ReturnedValuesNotShared", testReturnedValuesNotShared},
+		{"QueryFilter", testQueryFilter},
+		{"QuerySorted", testQuerySorted},
+		{"QueryPaginationComplete", testQueryPaginationComplete},
+		{"QueryInvalidCursor", testQueryInvalidCursor},
+		{"QueryEvent", testQueryEvent},
+		{"CancelledContext", testCancelledContext},
+		{"Concurrency", testConcurrency},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			repo, cleanup := newRepo(t)
+			defer cleanup()
+
+			test.test(t, repo)
+		})
+	}
+}
+
+// fullParticipant returns a participant with all fields set. Every call has a new email, as emails are unique.
+func fullParticipant() participant.Participant {
+	return participant.Participant{
+		Name:    aws.String("Test Testson"),
+		Email:   aws.String("test-" + uuid.New().String() + "@testson.com"),
+		Phone:   aws.String("12345678"),
+		Org:     aws.String("TestOrg"),
+		Score:   aws.Int(2),
+		Comment: aws.String("Test comment."),
+		Consent: aws.Bool(true),
+	}
+}
+
+func mustSave(t *testing.T, repo participant.Repository, p participant.Participant) *participant.Participant {
+	t.Helper()
+
+	saved, err := repo.Save(context.Background(), p)
+	if err != nil {
+		t.Fatalf("Error saving participant: %v", err)
+	}
+
+	return saved
+}
+
+func assertSameFields(t *testing.T, expected, actual participant.Participant) {
+	t.Helper()
+
+	assert.Equal(t, expected.Name, actual.Name)
+	assert.Equal(t, expected.Email, actual.Email)
+	assert.Equal(t, expected.Phone, actual.Phone)
+	assert.Equal(t, expected.Org, actual.Org)
+	assert.Equal(t, expected.Score, actual.Score)
+	assert.Equal(t, expected.Comment, actual.Comment)
+	assert.Equal(t, expected.Consent, actual.Consent)
+}
+
+func assertTimeAround(t *testing.T, expected time.Time, actual *time.Time) {
+	t.Helper()
+
+	if assert.NotNil(t, actual) {
+		assert.WithinDuration(t, expected, *actual, 2*timePrecision)
+	}
+}
+
+func testCreate(t *testing.T, repo participant.Repository) {
+	before := time.Now()
+	p := fullParticipant()
+
+	saved := mustSave(t, repo, p)
+
+	if assert.NotNil(t, saved.ID) {
+		assert.NotEmpty(t, *saved.ID)
+	}
+	assertSameFields(t, p, *saved)
+	assertTimeAround(t, before, saved.Created)
+	assertTimeAround(t, before, saved.Updated)
+
+	got, err := repo.Get(context.Background(), *saved.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, *saved.ID, *got.ID)
+	assertSameFields(t, p, *got)
+	assert.True(t, saved.Created.Truncate(timePrecision).Equal(got.Created.Truncate(timePrecision)))
+
+	other := mustSave(t, repo, fullParticipant())
+	assert.NotEqual(t, *saved.ID, *other.ID)
+}
+
+func testCreateIgnoresTimestamps(t *testing.T, repo participant.Repository) {
+	past := time.Now().Add(-24 * time.Hour)
+	p := fullParticipant()
+	p.Created = &past
+	p.Updated = &past
+
+	saved := mustSave(t, repo, p)
+
+	assertTimeAround(t, time.Now(), saved.Created)
+	assertTimeAround(t, time.Now(), saved.Updated)
+}
+
+func testPartialUpdate(t *testing.T, repo participant.Repository) {
+	original := fullParticipant()
+	saved := mustSave(t, repo, original)
+
+	updated, err := repo.Save(context.Background(), participant.Participant{
+		ID:      saved.ID,
+		Score:   aws.Int(10),
+		Comment: aws.String("Updated comment."),
+	})
+	assert.NoError(t, err)
+
+	expected := original
+	expected.Score = aws.Int(10)
+	expected.Comment = aws.String("Updated comment.")
+
+	assert.Equal(t, *saved.ID, *updated.ID)
+	assertSameFields(t, expected, *updated)
+
+	got, err := repo.Get(context.Background(), *saved.ID)
+	assert.NoError(t, err)
+	assertSameFields(t, expected, *got)
+
+	// Empty strings are values, not missing fields.
+	_, err = repo.Save(context.Background(), participant.Participant{ID: saved.ID, Comment: aws.String("")})
+	assert.NoError(t, err)
+
+	got, err = repo.Get(context.Background(), *saved.ID)
+	assert.NoError(t, err)
+	if assert.NotNil(t, got.Comment) {
+		assert.Equal(t, "", *got.Comment)
+	}
+	assert.Equal(t, original.Name, got.Name)
+}
+
+func testUpdateTimestamps(t *testing.T, repo participant.Repository) {
+	saved := mustSave(t, repo, fullParticipant())
+
+	time.Sleep(timePrecision + 100*time.Millisecond)
+
+	updated, err := repo.Save(context.Background(), participant.Participant{ID: saved.ID, Score: aws.Int(3)})
+	assert.NoError(t, err)
+
+	assert.True(t, saved.Created.Truncate(timePrecision).Equal(updated.Created.Truncate(timePrecision)),
+		"created changed on update")
+	assert.True(t, updated.Updated.After(*saved.Updated), "updated not changed on update")
+}
+
+func testUpdateNotExist(t *testing.T, repo participant.Repository) {
+	_, err := repo.Save(context.Background(), participant.Participant{ID: aws.String("nonExisting"), Score: aws.Int(1)})
+	assert.True(t, errors.Is(err, participant.ErrNotExist), "expected ErrNotExist, got %v", err)
+
+	ps, err := repo.GetAll(context.Background())
+	assert.NoError(t, err)
+	assert.Len(t, ps, 0, "update of non existing participant should not create it")
+}
+
+func testVersion(t *testing.T, repo participant.Repository) {
+	p := fullParticipant()
+	p.Version = aws.Int(10)
+
+	saved := mustSave(t, repo, p)
+	assert.Equal(t, aws.Int(1), saved.Version, "version should start at 1")
+
+	updated := mustSave(t, repo, participant.Participant{ID: saved.ID, Score: aws.Int(3)})
+	assert.Equal(t, aws.Int(2), updated.Version)
+
+	updated = mustSave(t, repo, participant.Participant{ID: saved.ID, Score: aws.Int(4), Version: aws.Int(2)})
+	assert.Equal(t, aws.Int(3), updated.Version)
+
+	got, err := repo.Get(context.Background(), *saved.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, aws.Int(3), got.Version)
+	assert.Equal(t, aws.Int(4), got.Score)
+}
+
+func testVersionConflict(t *testing.T, repo participant.Repository) {
+	saved := mustSave(t, repo, fullParticipant())
+	mustSave(t, repo, participant.Participant{ID: saved.ID, Score: aws.Int(3), Version: saved.Version})
+
+	// A second writer using the same version lost the race.
+	_, err := repo.Save(context.Background(), participant.Participant{ID: saved.ID, Score: aws.Int(4), Version: saved.Version})
+	assert.True(t, errors.Is(err, participant.ErrVersionConflict), "expected ErrVersionConflict, got %v", err)
+
+	got, err := repo.Get(context.Background(), *saved.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, aws.Int(3), got.Score, "conflicting save should not change the participant")
+	assert.Equal(t, aws.Int(2), got.Version)
+}
+
+func testVersionConflictNotExist(t *testing.T, repo participant.Repository) {
+	_, err := repo.Save(context.Background(), participant.Participant{ID: aws.String("nonExisting"), Score: aws.Int(1), Version: aws.Int(1)})
+	assert.True(t, errors.Is(err, participant.ErrNotExist), "expected ErrNotExist, got %v", err)
+}
+
+func assertEmailTaken(t *testing.T, err error, owner string) {
+	t.Helper()
+
+	assert.True(t, errors.Is(err, participant.ErrEmailTaken), "expected ErrEmailTaken, got %v", err)
+
+	var taken *participant.EmailTakenError
+	if assert.True(t, errors.As(err, &taken), "expected *EmailTakenError, got %T", err) {
+		assert.Equal(t, owner, taken.ID)
+	}
+}
+
+func testEmailTaken(t *testing.T, repo participant.Repository) {
+	existing := mustSave(t, repo, fullParticipant())
+
+	p := fullParticipant()
+	p.Email = aws.String(" " + strings.ToUpper(*existing.Email) + " ")
+
+	_, err := repo.Save(context.Background(), p)
+	assertEmailTaken(t, err, *existing.ID)
+
+	ps, err := repo.GetAll(context.Background())
+	assert.NoError(t, err)
+	assert.Len(t, ps, 1, "participant with taken email should not be saved")
+}
+
+func testEmailTakenUpdate(t *testing.T, repo participant.Repository) {
+	existing := mustSave(t, repo, fullParticipant())
+	other := mustSave(t, repo, fullParticipant())
+
+	_, err := repo.Save(context.Background(), participant.Participant{ID: other.ID, Email: existing.Email, Score: aws.Int(10)})
+	assertEmailTaken(t, err, *existing.ID)
+
+	got, err := repo.Get(context.Background(), *other.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, other.Email, got.Email)
+	assert.Equal(t, other.Score, got.Score, "failed update should not change the participant")
+}
+
+func testEmailUnchanged(t *testing.T, repo participant.Repository) {
+	saved := mustSave(t, repo, fullParticipant())
+
+	email := strings.ToUpper(*saved.Email)
+	updated, err := repo.Save(context.Background(), participant.Participant{ID: saved.ID, Email: &email})
+	assert.NoError(t, err)
+	assert.Equal(t, email, *updated.Email)
+
+	// Only the new email is registered.
+	_, err = repo.Save(context.Background(), participant.Participant{Email: saved.Email})
+	assertEmailTaken(t, err, *saved.ID)
+}
+
+func testEmailReleased(t *testing.T, repo participant.Repository) {
+	changed := mustSave(t, repo, fullParticipant())
+	deleted := mustSave(t, repo, fullParticipant())
+
+	_, err := repo.Save(context.Background(), participant.Participant{ID: changed.ID, Email: aws.String("new-" + *changed.Email)})
+	assert.NoError(t, err)
+	assert.NoError(t, repo.Delete(context.Background(), *deleted.ID))
+
+	p := fullParticipant()
+	p.Email = changed.Email
+	mustSave(t, repo, p)
+
+	p = fullParticipant()
+	p.Email = deleted.Email
+	mustSave(t, repo, p)
+}
+
+func testEmailPerEvent(t *testing.T, repo participant.Repository) {
+	withoutEvent := mustSave(t, repo, fullParticipant())
+
+	p := fullParticipant()
+	p.Email = withoutEvent.Email
+	p.Event = aws.String("conf-a")
+	inA := mustSave(t, repo, p)
+	assert.Equal(t, "conf-a", *inA.Event)
+
+	p = fullParticipant()
+	p.Email = withoutEvent.Email
+	p.Event = aws.String("conf-b")
+	inB := mustSave(t, repo, p)
+
+	p = fullParticipant()
+	p.Email = aws.String(strings.ToUpper(*withoutEvent.Email))
+	p.Event = aws.String("conf-a")
+	_, err := repo.Save(context.Background(), p)
+	assertEmailTaken(t, err, *inA.ID)
+
+	// Updates check the event the participant belongs to.
+	other := fullParticipant()
+	other.Event = aws.String("conf-b")
+	otherInB := mustSave(t, repo, other)
+
+	_, err = repo.Save(context.Background(), participant.Participant{ID: otherInB.ID, Email: withoutEvent.Email})
+	assertEmailTaken(t, err, *inB.ID)
+
+	// Deleting releases the email in its event only.
+	assert.NoError(t, repo.Delete(context.Background(), *inA.ID))
+
+	p = fullParticipant()
+	p.Email = withoutEvent.Email
+	p.Event = aws.String("conf-a")
+	mustSave(t, repo, p)
+
+	_, err = repo.Save(context.Background(), participant.Participant{Email: withoutEvent.Email})
+	assertEmailTaken(t, err, *withoutEvent.ID)
+}
+
+func testEventImmutable(t *testing.T, repo participant.Repository) {
+	p := fullParticipant()
+	p.Event = aws.String("conf-a")
+	saved := mustSave(t, repo, p)
+
+	updated, err := repo.Save(context.Background(), participant.Participant{ID: saved.ID, Event: aws.String("conf-b"), Score: aws.Int(5)})
+	assert.NoError(t, err)
+	assert.Equal(t, "conf-a", *updated.Event)
+	assert.Equal(t, 5, *updated.Score)
+
+	got, err := repo.Get(context.Background(), *saved.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, "conf-a", *got.Event)
+}
+
+// Run with -race to detect unsynchronized access.
+func testEmailConcurrent(t *testing.T, repo participant.Repository) {
+	const workers = 8
+
+	email := fullParticipant().Email
+
+	var (
+		wg    sync.WaitGroup
+		mu    sync.Mutex
+		saved []string
+	)
+
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			p := fullParticipant()
+			p.Email = email
+
+			res, err := repo.Save(context.Background(), p)
+			if err != nil {
+				assert.True(t, errors.Is(err, participant.ErrEmailTaken), "expected ErrEmailTaken, got %v", err)
+				return
+			}
+
+			mu.Lock()
+			saved = append(saved, *res.ID)
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	assert.Len(t, saved, 1, "exactly one participant should be saved")
+}
+
+func testGetNotExist(t *testing.T, repo participant.Repository) {
+	_, err := repo.Get(context.Background(), "nonExisting")
+	assert.True(t, errors.Is(err, participant.ErrNotExist), "expected ErrNotExist, got %v", err)
+}
+
+func testDelete(t *testing.T, repo participant.Repository) {
+	saved := mustSave(t, repo, fullParticipant())
+	kept := mustSave(t, repo, fullParticipant())
+
+	assert.NoError(t, repo.Delete(context.Background(), *saved.ID))
+
+	_, err := repo.Get(context.Background(), *saved.ID)
+	assert.True(t, errors.Is(err, participant.ErrNotExist), "expected ErrNotExist, got %v", err)
+
+	_, err = repo.Get(context.Background(), *kept.ID)
+	assert.NoError(t, err)
+
+	err = repo.Delete(context.Background(), *saved.ID)
+	assert.True(t, errors.Is(err, participant.ErrNotExist), "expected ErrNotExist on second delete, got %v", err)
+}
+
+func testDeleteNotExist(t *testing.T, repo participant.Repository) {
+	err := repo.Delete(context.Background(), "nonExisting")
+	assert.True(t, errors.Is(err, participant.ErrNotExist), "expected ErrNotExist, got %v", err)
+}
+
+func testGetAllComplete(t *testing.T, repo participant.Repository) {
+	expected := make(map[string]bool)
+	for i := 0; i < 25; i++ {
+		p := fullParticipant()
+		p.Name = aws.String(fmt.Sprintf("Participant%d", i))
+		expected[*mustSave(t, repo, p).ID] = true
+	}
+
+	ps, err := repo.GetAll(context.Background())
+	assert.NoError(t, err)
+
+	actual := make(map[string]bool)
+	for _, p := range ps {
+		actual[*p.ID] = true
+	}
+	assert.Equal(t, expected, actual)
+	assert.Len(t, ps, len(expected), "duplicates in GetAll")
+}
+
+func testGetAllEmpty(t *testing.T, repo participant.Repository) {
+	ps, err := repo.GetAll(context.Background())
+	assert.NoError(t, err)
+	assert.Len(t, ps, 0)
+}
+
+func testReturnedValuesNotShared(t *testing.T, repo participant.Repository) {
+	saved := mustSave(t, repo, fullParticipant())
+	*saved.Name = "Changed"
+
+	got, err := repo.Get(context.Background(), *saved.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, "Test Testson", *got.Name)
+	*got.Score = 100
+
+	ps, err := repo.GetAll(context.Background())
+	assert.NoError(t, err)
+	if assert.Len(t, ps, 1) {
+		assert.Equal(t, 2, *ps[0].Score)
+	}
+}
+
+// saveScores saves one participant per score, alternating between OrgA and OrgB.
+func saveScores(t *testing.T, repo participant.Repository, scores ...int) {
+	for i, score := range scores {
+		org := "OrgA"
+		if i%2 == 1 {
+			org = "OrgB"
+		}
+
+		p := fullParticipant()
+		p.Name = aws.String(fmt.Sprintf("Participant%d", i))
+		p.Org = aws.String(org)
+		p.Score = aws.Int(score)
+		mustSave(t, repo, p)
+	}
+}
+
+func queryAll(t *testing.T, repo participant.Repository, q participant.Query) []*participant.Participant {
+	t.Helper()
+
+	var ps []*participant.Participant
+	for i := 0; ; i++ {
+		if i > 1000 {
+			t.Fatal("Query doesn't terminate")
+		}
+
+		page, err := repo.Query(context.Background(), q)
+		if err != nil {
+			t.Fatalf("Error querying: %v", err)
+		}
+
+		if q.Limit > 0 {
+			assert.True(t, len(page.Participants) <= q.Limit, "page larger than limit")
+		}
+
+		ps = append(ps, page.Participants...)
+
+		if page.Next == "" {
+			return ps
+		}
+		q.Cursor = page.Next
+	}
+}
+
+func scores(ps []*participant.Participant) []int {
+	res := make([]int, 0, len(ps))
+	for _, p := range ps {
+		res = append(res, *p.Score)
+	}
+	return res
+}
+
+func testQueryFilter(t *testing.T, repo participant.Repository) {
+	saveScores(t, repo, 1, 2, 3, 4, 5, 6, 7, 8)
+
+	ps := queryAll(t, repo, participant.Query{Org: aws.String("OrgA"), MinScore: aws.Int(3), MaxScore: aws.Int(7)})
+	res := scores(ps)
+	sort.Ints(res)
+	assert.Equal(t, []int{3, 5, 7}, res)
+
+	future := time.Now().Add(time.Hour)
+	ps = queryAll(t, repo, participant.Query{CreatedFrom: &future})
+	assert.Len(t, ps, 0)
+
+	past := time.Now().Add(-time.Hour)
+	ps = queryAll(t, repo, participant.Query{UpdatedFrom: &past, UpdatedTo: &future})
+	assert.Len(t, ps, 8)
+}
+
+func testQuerySorted(t *testing.T, repo participant.Repository) {
+	saveScores(t, repo, 5, 3, 8, 1, 9, 2)
+
+	ps := queryAll(t, repo, participant.Query{Sort: participant.SortScore, Descending: true, Limit: 4})
+	assert.Equal(t, []int{9, 8, 5, 3, 2, 1}, scores(ps))
+
+	ps = queryAll(t, repo, participant.Query{Sort: participant.SortScore, Limit: 2})
+	assert.Equal(t, []int{1, 2, 3, 5, 8, 9}, scores(ps))
+}
+
+func testQueryPaginationComplete(t *testing.T, repo participant.Repository) {
+	saveScores(t, repo, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11)
+
+	for _, limit := range []int{0, 1, 3, 11, 20} {
+		ps := queryAll(t, repo, participant.Query{Limit: limit})
+
+		res := scores(ps)
+		sort.Ints(res)
+		assert.Equal(t, []int{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11}, res, "limit %d", limit)
+	}
+
+	ps := queryAll(t, repo, participant.Query{Org: aws.String("OrgB"), Limit: 2})
+	res := scores(ps)
+	sort.Ints(res)
+	assert.Equal(t, []int{2, 4, 6, 8, 10}, res)
+}
+
+func testQueryInvalidCursor(t *testing.T, repo participant.Repository) {
+	saveScores(t, repo, 1, 2, 3)
+
+	_, err := repo.Query(context.Background(), participant.Query{Limit: 1, Cursor: "!invalid!"})
+	assert.True(t, errors.Is(err, participant.ErrInvalidCursor), "expected ErrInvalidCursor, got %v", err)
+
+	_, err = repo.Query(context.Background(), participant.Query{Sort: participant.SortScore, Limit: 1, Cursor: "!invalid!"})
+	assert.True(t, errors.Is(err, participant.ErrInvalidCursor), "expected ErrInvalidCursor, got %v", err)
+}
+
+func testQueryEvent(t *testing.T, repo participant.Repository) {
+	saveScores(t, repo, 1, 2, 3)
+
+	for i, score := range []int{4, 5, 6, 7, 8} {
+		event := "conf-a"
+		if i%2 == 1 {
+			event = "conf-b"
+		}
+
+		p := fullParticipant()
+		p.Event = aws.String(event)
+		p.Org = aws.String("OrgA")
+		p.Score = aws.Int(score)
+		mustSave(t, repo, p)
+	}
+
+	ps := queryAll(t, repo, participant.Query{Event: aws.String("conf-a"), Sort: participant.SortScore, Descending: true, Limit: 2})
+	assert.Equal(t, []int{8, 6, 4}, scores(ps))
+
+	for _, p := range ps {
+		assert.Equal(t, "conf-a", *p.Event)
+	}
+
+	ps = queryAll(t, repo, participant.Query{Event: aws.String("conf-b"), MinScore: aws.Int(6), Limit: 1})
+	assert.Equal(t, []int{7}, scores(ps))
+
+	ps = queryAll(t, repo, participant.Query{Event: aws.String("conf-c")})
+	assert.Empty(t, ps)
+}
+
+func testCancelledContext(t *testing.T, repo participant.Repository) {
+	saved := mustSave(t, repo, fullParticipant())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := repo.Save(ctx, fullParticipant())
+	assert.True(t, errors.Is(err, context.Canceled), "Save: expected context.Canceled, got %v", err)
+
+	_, err = repo.Get(ctx, *saved.ID)
+	assert.True(t, errors.Is(err, context.Canceled), "Get: expected context.Canceled, got %v", err)
+
+	_, err = repo.GetAll(ctx)
+	assert.True(t, errors.Is(err, context.Canceled), "GetAll: expected context.Canceled, got %v", err)
+
+	_, err = repo.Query(ctx, participant.Query{})
+	assert.True(t, errors.Is(err, context.Canceled), "Query: expected context.Canceled, got %v", err)
+
+	err = repo.Delete(ctx, *saved.ID)
+	assert.True(t, errors.Is(err, context.Canceled), "Delete: expected context.Canceled, got %v", err)
+
+	ps, err := repo.GetAll(context.Background())
+	assert.NoError(t, err)
+	assert.Len(t, ps, 1, "cancelled calls should not change the repository")
+}
+
+// Run with -race to detect unsynchronized access.
+func testConcurrency(t *testing.T, repo participant.Repository) {
+	const workers = 8
+	const iterations = 20
+
+	ctx := context.Background()
+
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+
+			for i := 0; i < iterations; i++ {
+				p := fullParticipant()
+				p.Name = aws.String(fmt.Sprintf("Participant%d-%d", w, i))
+				p.Score = aws.Int(i)
+
+				saved, err := repo.Save(ctx, p)
+				if !assert.NoError(t, err) {
+					return
+				}
+
+				_, err = repo.Save(ctx, participant.Participant{ID: saved.ID, Score: aws.Int(i + 1)})
+				assert.NoError(t, err)
+
+				got, err := repo.Get(ctx, *saved.ID)
+				if assert.NoError(t, err) {
+					assert.Equal(t, i+1, *got.Score)
+				}
+
+				_, err = repo.GetAll(ctx)
+				assert.NoError(t, err)
+
+				if i%2 == 0 {
+					assert.NoError(t, repo.Delete(ctx, *saved.ID))
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	ps, err := repo.GetAll(ctx)
+	assert.NoError(t, err)
+	assert.Len(t, ps, workers*iterations/2)
+}
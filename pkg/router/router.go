@@ -9,10 +9,28 @@ import (
 // Router registers handlers and routes the http request to the right handler.
 type Router struct {
 	NotFound http.HandlerFunc
+	// MethodNotAllowed is called when the path matches a route, but not the method. The Allow header is set before
+	// it is called. If nil a plain text response is sent.
+	MethodNotAllowed http.HandlerFunc
+	// Authorize is called before the handler of a route registered with Require, with the roles it requires. It
+	// returns the request to pass on to the handler, eg. with the caller added to its context, and true if the caller
+	// has one of roles. Otherwise it has responded, and the handler isn't called. If nil, such routes respond 403
+	// Forbidden.
+	Authorize func(res http.ResponseWriter, req *http.Request, roles []string) (*http.Request, bool)
 
 	routes map[string]route
 }
 
+// RouteOption configures a route when it is registered.
+type RouteOption func(*handler)
+
+// Require restricts a route to callers having one of roles, see Router.Authorize.
+func Require(roles ...string) RouteOption {
+	return func(h *handler) {
+		h.roles = roles
+	}
+}
+
 // New returns a new Router.
 func New() *Router {
 	return &Router{
@@ -22,61 +40,67 @@ func New() *Router {
 }
 
 // GET adds the specified handler for GET requests matching the path.
-func (r *Router) GET(path string, h http.HandlerFunc) {
-	r.addHandler(http.MethodGet, path, h)
+func (r *Router) GET(path string, h http.HandlerFunc, opts ...RouteOption) {
+	r.addHandler(http.MethodGet, path, h, opts)
 }
 
 // POST adds the specified handler for POST requests matching the path.
-func (r *Router) POST(path string, h http.HandlerFunc) {
-	r.addHandler(http.MethodPost, path, h)
+func (r *Router) POST(path string, h http.HandlerFunc, opts ...RouteOption) {
+	r.addHandler(http.MethodPost, path, h, opts)
 }
 
 // PUT adds the specified handler for PUT requests matching the path.
-func (r *Router) PUT(path string, h http.HandlerFunc) {
-	r.addHandler(http.MethodPut, path, h)
+func (r *Router) PUT(path string, h http.HandlerFunc, opts ...RouteOption) {
+	r.addHandler(http.MethodPut, path, h, opts)
 }
 
 // DELETE adds the specified handler for DELETE requests matching the path.
-func (r *Router) DELETE(path string, h http.HandlerFunc) {
-	r.addHandler(http.MethodDelete, path, h)
+func (r *Router) DELETE(path string, h http.HandlerFunc, opts ...RouteOption) {
+	r.addHandler(http.MethodDelete, path, h, opts)
 }
 
 // OPTIONS adds the specified handler for OPTIONS requests matching the path.
-func (r *Router) OPTIONS(path string, h http.HandlerFunc) {
-	r.addHandler(http.MethodOptions, path, h)
+func (r *Router) OPTIONS(path string, h http.HandlerFunc, opts ...RouteOption) {
+	r.addHandler(http.MethodOptions, path, h, opts)
 }
 
 func segment(path string) []string {
 	return strings.Split(strings.Trim(path, "/"), "/")
 }
 
-func (r *Router) addHandler(method string, path string, h http.HandlerFunc) {
+func (r *Router) addHandler(method string, path string, h http.HandlerFunc, opts []RouteOption) {
+	hdl := handler{handle: h}
+	for _, opt := range opts {
+		opt(&hdl)
+	}
+
 	rt, exist := r.routes[path]
-	if exist {
-		rt.handlers[method] = h
-		return
+	if !exist {
+		rt = route{
+			handlers: make(map[string]handler),
+			segments: segment(path),
+		}
 	}
 
-	r.routes[path] = route{
-		handlers: map[string]http.HandlerFunc{
-			method: h,
-		},
-		segments: segment(path),
+	if _, exist := rt.handlers[method]; !exist {
+		rt.methods = append(rt.methods, method)
 	}
+	rt.handlers[method] = hdl
+	r.routes[path] = rt
 }
 
 func (r *Router) ServeHTTP(res http.ResponseWriter, req *http.Request) {
 	for _, route := range r.routes {
 		if ctx, match := route.match(req.Context(), req.URL.Path); match {
 			if handler, exist := route.handlers[req.Method]; exist {
-				handler.ServeHTTP(res, req.WithContext(ctx))
+				r.serve(handler, res, req.WithContext(ctx))
 				return
 			}
-			var allow []string
-			for k := range route.handlers {
-				allow = append(allow, k)
+			res.Header().Set("Allow", strings.Join(route.methods, ", "))
+			if r.MethodNotAllowed != nil {
+				r.MethodNotAllowed.ServeHTTP(res, req)
+				return
 			}
-			res.Header().Set("Allow", strings.Join(allow, ", "))
 			http.Error(res, "Method Not Allowed", http.StatusMethodNotAllowed)
 			return
 		}
@@ -85,6 +109,22 @@ func (r *Router) ServeHTTP(res http.ResponseWriter, req *http.Request) {
 	r.NotFound.ServeHTTP(res, req)
 }
 
+func (r *Router) serve(h handler, res http.ResponseWriter, req *http.Request) {
+	if h.roles != nil {
+		if r.Authorize == nil {
+			http.Error(res, "Forbidden", http.StatusForbidden)
+			return
+		}
+
+		var ok bool
+		if req, ok = r.Authorize(res, req, h.roles); !ok {
+			return
+		}
+	}
+
+	h.handle.ServeHTTP(res, req)
+}
+
 // paramKey type for adding values to context without risking collision. Eg. two diffenrent packages adding the same string key.
 type paramKey string
 
@@ -94,8 +134,15 @@ func GetParam(ctx context.Context, name string) (string, bool) {
 	return vStr, ok
 }
 
+type handler struct {
+	handle http.HandlerFunc
+	roles  []string
+}
+
 type route struct {
-	handlers map[string]http.HandlerFunc
+	handlers map[string]handler
+	// methods lists the methods of handlers in the order registered, for the Allow header.
+	methods  []string
 	segments []string
 }
 
@@ -367,3 +367,57 @@ func TestRouter_routeMatch(t *testing.T) {
 
 	}
 }
+
+func TestRouter_Require(t *testing.T) {
+	rtr := New()
+	rtr.GET("open", func(res http.ResponseWriter, req *http.Request) { fmt.Fprint(res, "Open") })
+	rtr.GET("restricted", func(res http.ResponseWriter, req *http.Request) {
+		fmt.Fprint(res, "Restricted", req.Context().Value(paramKey("caller")))
+	}, Require("admin", "scorer"))
+
+	req, _ := http.NewRequest(http.MethodGet, "/restricted", nil)
+	res := httptest.NewRecorder()
+	rtr.ServeHTTP(res, req)
+	if res.Code != http.StatusForbidden {
+		t.Errorf("Expected status %d without Authorize, but got %d", http.StatusForbidden, res.Code)
+	}
+
+	var required []string
+	rtr.Authorize = func(res http.ResponseWriter, req *http.Request, roles []string) (*http.Request, bool) {
+		required = roles
+		if req.Header.Get("Role") == "" {
+			http.Error(res, "Unauthorized", http.StatusUnauthorized)
+			return req, false
+		}
+		return req.WithContext(context.WithValue(req.Context(), paramKey("caller"), req.Header.Get("Role"))), true
+	}
+
+	tests := []struct {
+		path         string
+		role         string
+		expectedCode int
+		expectedBody string
+		expectedRole []string
+	}{
+		{path: "/open", expectedCode: http.StatusOK, expectedBody: "Open"},
+		{path: "/restricted", expectedCode: http.StatusUnauthorized, expectedBody: "Unauthorized\n", expectedRole: []string{"admin", "scorer"}},
+		{path: "/restricted", role: "admin", expectedCode: http.StatusOK, expectedBody: "Restrictedadmin", expectedRole: []string{"admin", "scorer"}},
+	}
+
+	for _, test := range tests {
+		required = nil
+		req, _ := http.NewRequest(http.MethodGet, test.path, nil)
+		if test.role != "" {
+			req.Header.Set("Role", test.role)
+		}
+		res := httptest.NewRecorder()
+		rtr.ServeHTTP(res, req)
+
+		if res.Code != test.expectedCode || res.Body.String() != test.expectedBody {
+			t.Errorf("Expected %d %q for %s, but got %d %q", test.expectedCode, test.expectedBody, test.path, res.Code, res.Body.String())
+		}
+		if fmt.Sprint(required) != fmt.Sprint(test.expectedRole) {
+			t.Errorf("Expected Authorize to be called with %v for %s, but got %v", test.expectedRole, test.path, required)
+		}
+	}
+}
@@ -0,0 +1,242 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/rejlersembriq/hooked/pkg/duplicate"
+	"github.com/rejlersembriq/hooked/pkg/event"
+	"github.com/rejlersembriq/hooked/pkg/participant"
+	"github.com/rejlersembriq/hooked/pkg/router"
+	"go.uber.org/zap"
+	"net/http"
+	"time"
+)
+
+// MergeRequest merges the participant Remove into Keep. With DryRun the result is returned without saving.
+type MergeRequest struct {
+	Keep   string `json:"keep"`
+	Remove string `json:"remove"`
+	DryRun bool   `json:"dryRun"`
+}
+
+// MergeResult is the merged participant and the participant removed by a merge.
+type MergeResult struct {
+	Participant *participant.Participant `json:"participant"`
+	Removed     *participant.Participant `json:"removed"`
+	DryRun      bool                     `json:"dryRun"`
+}
+
+// ReopenRequest reopens a closed event. Reason is required and recorded. End moves the end of the event, and is
+// required if it has passed.
+type ReopenRequest struct {
+	Reason string     `json:"reason"`
+	End    *time.Time `json:"end"`
+}
+
+// duplicatesGET returns pairs of participants likely to be the same person.
+func (s *Server) duplicatesGET() http.HandlerFunc {
+	return func(res http.ResponseWriter, req *http.Request) {
+		ps, err := s.participantRepo.GetAll(req.Context())
+		if err != nil {
+			zap.L().Error("Error retrieveing resources.", zap.String("error", err.Error()))
+			sendProblem(http.StatusInternalServerError, "Error retrieving resources").ServeHTTP(res, req)
+			return
+		}
+
+		candidates := duplicate.Find(ps)
+
+		sendJSON(&candidates).ServeHTTP(res, req)
+	}
+}
+
+// mergePOST merges two participants, see duplicate.Merge. The merged participant is saved before the other is
+// deleted, and the save fails if the participant kept changed since it was read. Attempts of the removed participant
+// are moved to the one kept. Participants in a closed event can only be merged after reopening it, as the score might
+// change.
+func (s *Server) mergePOST() http.HandlerFunc {
+	return func(res http.ResponseWriter, req *http.Request) {
+		defer req.Body.Close()
+
+		var mr MergeRequest
+		dec := json.NewDecoder(req.Body)
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&mr); err != nil {
+			decodeProblem(err).ServeHTTP(res, req)
+			return
+		}
+
+		verr := &participant.ValidationError{}
+		if mr.Keep == "" {
+			addFieldError(verr, "keep", "required")
+		}
+		if mr.Remove == "" {
+			addFieldError(verr, "remove", "required")
+		}
+		if mr.Keep != "" && mr.Keep == mr.Remove {
+			addFieldError(verr, "remove", "must differ from keep")
+		}
+		if len(verr.Fields) > 0 {
+			sendValidationProblem(http.StatusUnprocessableEntity, "Invalid merge request", verr).ServeHTTP(res, req)
+			return
+		}
+
+		keep, ok := s.getParticipant(res, req, mr.Keep)
+		if !ok {
+			return
+		}
+
+		remove, ok := s.getParticipant(res, req, mr.Remove)
+		if !ok {
+			return
+		}
+
+		// The event of a participant can't change, and a person may take part in several events.
+		if !participant.SameEvent(keep.Event, remove.Event) {
+			sendProblem(http.StatusUnprocessableEntity, "Participants are in different events", participant.FieldError{Field: "remove", Message: "must be in the same event as keep"}).ServeHTTP(res, req)
+			return
+		}
+
+		// Both leaderboards change, as remove is deleted and keep may get its score.
+		if !mr.DryRun && (!s.scoresOpen(res, req, keep) || !s.scoresOpen(res, req, remove)) {
+			return
+		}
+
+		merged := duplicate.Merge(*keep, *remove)
+		result := MergeResult{Participant: &merged, Removed: remove, DryRun: mr.DryRun}
+
+		if mr.DryRun {
+			sendJSON(&result).ServeHTTP(res, req)
+			return
+		}
+
+		// The version of keep makes the save fail if it changed since it was read.
+		version := keep.CurrentVersion()
+		merged.Version = &version
+
+		saved, err := s.participantRepo.Save(req.Context(), merged)
+		if err != nil {
+			if errors.Is(err, participant.ErrVersionConflict) || errors.Is(err, participant.ErrNotExist) {
+				sendProblem(http.StatusConflict, "Participant changed during merge. Try again.").ServeHTTP(res, req)
+				return
+			}
+
+			zap.L().Error("Error persisting resource.", zap.String("error", err.Error()))
+			sendProblem(http.StatusInternalServerError, "Error persisting resource").ServeHTTP(res, req)
+			return
+		}
+		result.Participant = saved
+
+		if s.attempts != nil {
+			if err := s.moveAttempts(req.Context(), mr.Remove, mr.Keep); err != nil {
+				zap.L().Error("Error moving attempts.", zap.String("from", mr.Remove), zap.String("to", mr.Keep), zap.String("error", err.Error()))
+				sendProblem(http.StatusInternalServerError, "Participant merged, but error moving the removed participant's attempts").ServeHTTP(res, req)
+				return
+			}
+
+			// The score is derived from the attempts of both participants, not the best of their scores.
+			updated, err := s.updateScore(req.Context(), mr.Keep)
+			if err != nil {
+				zap.L().Error("Error updating score.", zap.String("id", mr.Keep), zap.String("error", err.Error()))
+				sendProblem(http.StatusInternalServerError, "Participant merged, but error updating its score").ServeHTTP(res, req)
+				return
+			}
+			result.Participant = updated
+		}
+
+		if err := s.participantRepo.Delete(req.Context(), mr.Remove); err != nil && !errors.Is(err, participant.ErrNotExist) {
+			zap.L().Error("Error deleting merged resource.", zap.String("id", mr.Remove), zap.String("error", err.Error()))
+			sendProblem(http.StatusInternalServerError, "Participant merged, but error deleting the removed participant").ServeHTTP(res, req)
+			return
+		}
+
+		zap.L().Info("Merged participants.", zap.String("keep", mr.Keep), zap.String("remove", mr.Remove))
+		sendJSON(&result).ServeHTTP(res, req)
+	}
+}
+
+// reopenPOST reopens a closed event, overriding the lifecycle. The override is recorded on the event with the reason,
+// request id and caller, and logged.
+func (s *Server) reopenPOST() http.HandlerFunc {
+	return func(res http.ResponseWriter, req *http.Request) {
+		defer req.Body.Close()
+
+		slug, exists := router.GetParam(req.Context(), "slug")
+		if !exists {
+			sendProblem(http.StatusInternalServerError, "Unable to get request parameter").ServeHTTP(res, req)
+			return
+		}
+
+		var rr ReopenRequest
+		dec := json.NewDecoder(req.Body)
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&rr); err != nil {
+			decodeProblem(err).ServeHTTP(res, req)
+			return
+		}
+
+		current, err := s.events.Get(req.Context(), slug)
+		if err != nil {
+			if errors.Is(err, event.ErrNotExist) {
+				sendProblem(http.StatusNotFound, "Event "+slug+" not found").ServeHTTP(res, req)
+				return
+			}
+
+			zap.L().Error("Error retrieving resource.", zap.String("error", err.Error()))
+			sendProblem(http.StatusInternalServerError, "Error retrieving resource").ServeHTTP(res, req)
+			return
+		}
+
+		requestID, _ := getRequestID(req.Context())
+		by := principalSubject(req)
+		now := time.Now()
+
+		reopened, err := event.Reopen(*current, now, rr.Reason, rr.End, requestID, by)
+		if err != nil {
+			if errors.Is(err, event.ErrInvalidTransition) {
+				sendProblem(http.StatusConflict, "Only closed events can be reopened. Event is "+string(current.StatusAt(now))).ServeHTTP(res, req)
+				return
+			}
+
+			var verr *participant.ValidationError
+			if errors.As(err, &verr) {
+				sendValidationProblem(http.StatusUnprocessableEntity, "Invalid reopen request", verr).ServeHTTP(res, req)
+				return
+			}
+
+			sendProblem(http.StatusUnprocessableEntity, err.Error()).ServeHTTP(res, req)
+			return
+		}
+
+		saved, err := s.events.Update(req.Context(), reopened)
+		if err != nil {
+			if errors.Is(err, event.ErrNotExist) {
+				sendProblem(http.StatusNotFound, "Event "+slug+" not found").ServeHTTP(res, req)
+				return
+			}
+
+			zap.L().Error("Error persisting resource.", zap.String("error", err.Error()))
+			sendProblem(http.StatusInternalServerError, "Error persisting resource").ServeHTTP(res, req)
+			return
+		}
+
+		zap.L().Info("Reopened event.", zap.String("slug", slug), zap.String("reason", rr.Reason), zap.String("requestId", requestID), zap.String("by", by))
+		sendJSON(&saved).ServeHTTP(res, req)
+	}
+}
+
+// getParticipant retrieves a participant, responding with a problem if it fails.
+func (s *Server) getParticipant(res http.ResponseWriter, req *http.Request, id string) (*participant.Participant, bool) {
+	p, err := s.participantRepo.Get(req.Context(), id)
+	if err != nil {
+		if errors.Is(err, participant.ErrNotExist) {
+			sendProblem(http.StatusNotFound, "Participant "+id+" not found").ServeHTTP(res, req)
+			return nil, false
+		}
+
+		zap.L().Error("Error retrieving resource.", zap.String("error", err.Error()))
+		sendProblem(http.StatusInternalServerError, "Error retrieving resource").ServeHTTP(res, req)
+		return nil, false
+	}
+
+	return p, true
+}
@@ -0,0 +1,166 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/rejlersembriq/hooked/pkg/attempt"
+	"github.com/rejlersembriq/hooked/pkg/duplicate"
+	"github.com/rejlersembriq/hooked/pkg/participant"
+	"github.com/rejlersembriq/hooked/pkg/repository/memory"
+	"github.com/rejlersembriq/hooked/pkg/router"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func saveDuplicates(t *testing.T, repo participant.Repository) (*participant.Participant, *participant.Participant) {
+	keep, err := repo.Save(context.Background(), participant.Participant{
+		Name:    aws.String("Test Testson"),
+		Email:   aws.String("test@testson.com"),
+		Org:     aws.String("OrgA"),
+		Score:   aws.Int(10),
+		Comment: aws.String("First."),
+	})
+	assert.NoError(t, err)
+
+	remove, err := repo.Save(context.Background(), participant.Participant{
+		Name:    aws.String("Test Testsen"),
+		Email:   aws.String("test2@testson.com"),
+		Phone:   aws.String("12345678"),
+		Org:     aws.String("OrgA"),
+		Score:   aws.Int(20),
+		Comment: aws.String("Second."),
+	})
+	assert.NoError(t, err)
+
+	return keep, remove
+}
+
+func TestServer_ServeHTTP_GETDuplicates(t *testing.T) {
+	repo := memory.New()
+	keep, remove := saveDuplicates(t, repo)
+	srvr := New(router.New(), repo)
+
+	req, _ := http.NewRequest(http.MethodGet, "/admin/duplicates", nil)
+	res := httptest.NewRecorder()
+
+	srvr.ServeHTTP(res, req)
+
+	assert.Equal(t, http.StatusOK, res.Code)
+
+	var candidates []duplicate.Candidate
+	assert.NoError(t, json.Unmarshal(res.Body.Bytes(), &candidates))
+	if assert.Len(t, candidates, 1) {
+		assert.ElementsMatch(t, []string{*keep.ID, *remove.ID}, []string{*candidates[0].Participants[0].ID, *candidates[0].Participants[1].ID})
+		assert.Equal(t, []duplicate.Reason{duplicate.ReasonName}, candidates[0].Reasons)
+	}
+}
+
+func mergeRequest(srvr *Server, payload string) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest(http.MethodPost, "/admin/merge", strings.NewReader(payload))
+	res := httptest.NewRecorder()
+
+	srvr.ServeHTTP(res, req)
+
+	return res
+}
+
+func TestServer_ServeHTTP_POSTMerge_DryRun(t *testing.T) {
+	repo := memory.New()
+	keep, remove := saveDuplicates(t, repo)
+	srvr := New(router.New(), repo)
+
+	res := mergeRequest(srvr, `{"keep":"`+*keep.ID+`","remove":"`+*remove.ID+`","dryRun":true}`)
+	assert.Equal(t, http.StatusOK, res.Code)
+
+	var result MergeResult
+	assert.NoError(t, json.Unmarshal(res.Body.Bytes(), &result))
+	assert.True(t, result.DryRun)
+	assert.Equal(t, 20, *result.Participant.Score)
+	assert.Equal(t, "First.\nSecond.", *result.Participant.Comment)
+	assert.Equal(t, *remove.ID, *result.Removed.ID)
+
+	ps, err := repo.GetAll(context.Background())
+	assert.NoError(t, err)
+	assert.Len(t, ps, 2, "dry run should not change anything")
+
+	got, err := repo.Get(context.Background(), *keep.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, 10, *got.Score)
+}
+
+func TestServer_ServeHTTP_POSTMerge(t *testing.T) {
+	repo := memory.New()
+	keep, remove := saveDuplicates(t, repo)
+	srvr := New(router.New(), repo)
+
+	res := mergeRequest(srvr, `{"keep":"`+*keep.ID+`","remove":"`+*remove.ID+`"}`)
+	assert.Equal(t, http.StatusOK, res.Code)
+
+	got, err := repo.Get(context.Background(), *keep.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, 20, *got.Score)
+	assert.Equal(t, "12345678", *got.Phone)
+	assert.Equal(t, "test@testson.com", *got.Email)
+
+	_, err = repo.Get(context.Background(), *remove.ID)
+	assert.True(t, errors.Is(err, participant.ErrNotExist))
+}
+
+func TestServer_ServeHTTP_POSTMerge_Invalid(t *testing.T) {
+	repo := memory.New()
+	keep, _ := saveDuplicates(t, repo)
+	srvr := New(router.New(), repo)
+
+	tests := []struct {
+		payload string
+		status  int
+	}{
+		{`{"keep":"` + *keep.ID + `"}`, http.StatusUnprocessableEntity},
+		{`{"keep":"` + *keep.ID + `","remove":"` + *keep.ID + `"}`, http.StatusUnprocessableEntity},
+		{`{"keep":"` + *keep.ID + `","remove":"nonExisting"}`, http.StatusNotFound},
+		{`{"keep":"` + *keep.ID + `","unknown":true}`, http.StatusBadRequest},
+		{`{`, http.StatusBadRequest},
+	}
+
+	for _, test := range tests {
+		res := mergeRequest(srvr, test.payload)
+		assert.Equal(t, test.status, res.Code, test.payload)
+		assert.Equal(t, problemContentType, res.Header().Get("Content-Type"), test.payload)
+	}
+}
+
+func TestServer_ServeHTTP_POSTMerge_MovesAttempts(t *testing.T) {
+	repo := memory.New()
+	attempts := memory.NewAttemptRepository()
+	keep, remove := saveDuplicates(t, repo)
+	srvr := New(router.New(), repo, WithAttempts(attempts, attempt.Best))
+
+	for _, id := range []string{*keep.ID, *remove.ID} {
+		_, err := attempts.Save(context.Background(), attempt.Attempt{Participant: id, Score: 1})
+		assert.NoError(t, err)
+	}
+
+	res := mergeRequest(srvr, `{"keep":"`+*keep.ID+`","remove":"`+*remove.ID+`"}`)
+	assert.Equal(t, http.StatusOK, res.Code)
+
+	as, err := attempts.List(context.Background(), *keep.ID)
+	assert.NoError(t, err)
+	assert.Len(t, as, 2)
+
+	as, err = attempts.List(context.Background(), *remove.ID)
+	assert.NoError(t, err)
+	assert.Empty(t, as)
+
+	var result MergeResult
+	assert.NoError(t, json.Unmarshal(res.Body.Bytes(), &result))
+	assert.Equal(t, 1, *result.Participant.Score, "score should be derived from the attempts, not the best score")
+
+	p, err := repo.Get(context.Background(), *keep.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, *p.Score)
+}
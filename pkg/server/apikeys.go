@@ -0,0 +1,179 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/google/uuid"
+	"github.com/rejlersembriq/hooked/pkg/apikey"
+	"github.com/rejlersembriq/hooked/pkg/participant"
+	"github.com/rejlersembriq/hooked/pkg/router"
+	"go.uber.org/zap"
+	"net/http"
+	"time"
+)
+
+// issuedKey is a key with its token, returned when the key is created or rotated.
+type issuedKey struct {
+	*apikey.Key
+	Token string `json:"token"`
+}
+
+// keysGET returns all keys, newest first, without their hashes.
+func (s *Server) keysGET() http.HandlerFunc {
+	return func(res http.ResponseWriter, req *http.Request) {
+		ks, err := s.keys.List(req.Context())
+		if err != nil {
+			zap.L().Error("Error retrieveing resources.", zap.String("error", err.Error()))
+			sendProblem(http.StatusInternalServerError, "Error retrieving resources").ServeHTTP(res, req)
+			return
+		}
+
+		for _, k := range ks {
+			k.Hash = ""
+		}
+
+		sendJSON(&ks).ServeHTTP(res, req)
+	}
+}
+
+// keyPOST creates a key. The response is the only one holding the token.
+func (s *Server) keyPOST() http.HandlerFunc {
+	return func(res http.ResponseWriter, req *http.Request) {
+		defer req.Body.Close()
+
+		var kr apikey.Request
+		dec := json.NewDecoder(req.Body)
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&kr); err != nil {
+			decodeProblem(err).ServeHTTP(res, req)
+			return
+		}
+
+		if err := apikey.Validate(kr); err != nil {
+			var verr *participant.ValidationError
+			if errors.As(err, &verr) {
+				sendValidationProblem(http.StatusUnprocessableEntity, "Invalid API key", verr).ServeHTTP(res, req)
+				return
+			}
+
+			sendProblem(http.StatusUnprocessableEntity, err.Error()).ServeHTTP(res, req)
+			return
+		}
+
+		id := uuid.New().String()
+		token, hash, err := apikey.New(id)
+		if err != nil {
+			zap.L().Error("Error generating API key.", zap.String("error", err.Error()))
+			sendProblem(http.StatusInternalServerError, "Error generating API key").ServeHTTP(res, req)
+			return
+		}
+
+		saved, err := s.keys.Create(req.Context(), apikey.Key{
+			ID:      id,
+			Name:    kr.Name,
+			Scopes:  kr.Scopes,
+			Hash:    hash,
+			Created: time.Now(),
+		})
+		if err != nil {
+			zap.L().Error("Error persisting resource.", zap.String("error", err.Error()))
+			sendProblem(http.StatusInternalServerError, "Error persisting resource").ServeHTTP(res, req)
+			return
+		}
+
+		zap.L().Info("Created API key.", zap.String("id", saved.ID), zap.Strings("scopes", saved.Scopes), zap.String("by", principalSubject(req)))
+
+		saved.Hash = ""
+		sendJSON(&issuedKey{Key: saved, Token: token}).ServeHTTP(res, req)
+	}
+}
+
+func (s *Server) keyGET() http.HandlerFunc {
+	return func(res http.ResponseWriter, req *http.Request) {
+		id, exists := router.GetParam(req.Context(), "id")
+		if !exists {
+			sendProblem(http.StatusInternalServerError, "Unable to get request parameter").ServeHTTP(res, req)
+			return
+		}
+
+		k, err := s.keys.Get(req.Context(), id)
+		if err != nil {
+			if errors.Is(err, apikey.ErrNotExist) {
+				sendProblem(http.StatusNotFound, "API key "+id+" not found").ServeHTTP(res, req)
+				return
+			}
+
+			zap.L().Error("Error retrieving resource.", zap.String("error", err.Error()))
+			sendProblem(http.StatusInternalServerError, "Error retrieving resource").ServeHTTP(res, req)
+			return
+		}
+
+		k.Hash = ""
+		sendJSON(&k).ServeHTTP(res, req)
+	}
+}
+
+// keyRotatePOST replaces the token of a key, keeping its id and scopes. The old token stops working at once.
+func (s *Server) keyRotatePOST() http.HandlerFunc {
+	return func(res http.ResponseWriter, req *http.Request) {
+		id, exists := router.GetParam(req.Context(), "id")
+		if !exists {
+			sendProblem(http.StatusInternalServerError, "Unable to get request parameter").ServeHTTP(res, req)
+			return
+		}
+
+		token, hash, err := apikey.New(id)
+		if err != nil {
+			zap.L().Error("Error generating API key.", zap.String("error", err.Error()))
+			sendProblem(http.StatusInternalServerError, "Error generating API key").ServeHTTP(res, req)
+			return
+		}
+
+		k, err := s.keys.Rotate(req.Context(), id, hash, time.Now())
+		if !s.keyUpdated(res, req, id, err) {
+			return
+		}
+
+		zap.L().Info("Rotated API key.", zap.String("id", id), zap.String("by", principalSubject(req)))
+
+		k.Hash = ""
+		sendJSON(&issuedKey{Key: k, Token: token}).ServeHTTP(res, req)
+	}
+}
+
+// keyRevokePOST revokes a key. Revoked keys are kept, so they're listed with the time they were revoked.
+func (s *Server) keyRevokePOST() http.HandlerFunc {
+	return func(res http.ResponseWriter, req *http.Request) {
+		id, exists := router.GetParam(req.Context(), "id")
+		if !exists {
+			sendProblem(http.StatusInternalServerError, "Unable to get request parameter").ServeHTTP(res, req)
+			return
+		}
+
+		k, err := s.keys.Revoke(req.Context(), id, time.Now())
+		if !s.keyUpdated(res, req, id, err) {
+			return
+		}
+
+		zap.L().Info("Revoked API key.", zap.String("id", id), zap.String("by", principalSubject(req)))
+
+		k.Hash = ""
+		sendJSON(&k).ServeHTTP(res, req)
+	}
+}
+
+// keyUpdated reports if a key was rotated or revoked, responding with a problem if err isn't nil.
+func (s *Server) keyUpdated(res http.ResponseWriter, req *http.Request, id string, err error) bool {
+	switch {
+	case err == nil:
+		return true
+	case errors.Is(err, apikey.ErrNotExist):
+		sendProblem(http.StatusNotFound, "API key "+id+" not found").ServeHTTP(res, req)
+	case errors.Is(err, apikey.ErrRevoked):
+		sendProblem(http.StatusConflict, "API key "+id+" is revoked").ServeHTTP(res, req)
+	default:
+		zap.L().Error("Error persisting resource.", zap.String("error", err.Error()))
+		sendProblem(http.StatusInternalServerError, "Error persisting resource").ServeHTTP(res, req)
+	}
+	return false
+}
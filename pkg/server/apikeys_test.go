@@ -0,0 +1,102 @@
+package server
+
+import (
+	"encoding/json"
+	"github.com/rejlersembriq/hooked/pkg/apikey"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"testing"
+)
+
+type testIssuedKey struct {
+	apikey.Key
+	Token string `json:"token"`
+}
+
+func TestServer_ServeHTTP_APIKeys(t *testing.T) {
+	srvr, _ := authServer(t)
+
+	res := keyRequest(srvr, http.MethodPost, "/admin/key", `{"name":"Scoreboard","scopes":["write-score"]}`, testAdminKey)
+	assert.Equal(t, http.StatusOK, res.Code)
+	assert.NotContains(t, res.Body.String(), "hash")
+
+	var created testIssuedKey
+	assert.NoError(t, json.Unmarshal(res.Body.Bytes(), &created))
+	assert.Equal(t, "Scoreboard", created.Name)
+	assert.Equal(t, []string{apikey.ScopeWriteScore}, created.Scopes)
+	assert.NotEmpty(t, created.Token)
+
+	res = keyRequest(srvr, http.MethodGet, "/participants", "", created.Token)
+	assert.Equal(t, http.StatusOK, res.Code, "the new key should be accepted")
+
+	res = keyRequest(srvr, http.MethodGet, "/admin/key/"+created.ID, "", testAdminKey)
+	assert.Equal(t, http.StatusOK, res.Code)
+	assert.NotContains(t, res.Body.String(), "hash")
+	assert.NotContains(t, res.Body.String(), "token")
+
+	res = keyRequest(srvr, http.MethodGet, "/admin/keys", "", testAdminKey)
+	assert.Equal(t, http.StatusOK, res.Code)
+	assert.NotContains(t, res.Body.String(), "hash")
+
+	var ks []apikey.Key
+	assert.NoError(t, json.Unmarshal(res.Body.Bytes(), &ks))
+	if assert.Len(t, ks, 1) {
+		assert.Equal(t, created.ID, ks[0].ID)
+	}
+
+	res = keyRequest(srvr, http.MethodPost, "/admin/key/"+created.ID+"/rotate", "", testAdminKey)
+	assert.Equal(t, http.StatusOK, res.Code)
+
+	var rotated testIssuedKey
+	assert.NoError(t, json.Unmarshal(res.Body.Bytes(), &rotated))
+	assert.Equal(t, created.ID, rotated.ID)
+	assert.NotNil(t, rotated.Rotated)
+	assert.NotEqual(t, created.Token, rotated.Token)
+
+	res = keyRequest(srvr, http.MethodGet, "/participants", "", created.Token)
+	assert.Equal(t, http.StatusUnauthorized, res.Code, "the old token should stop working")
+
+	res = keyRequest(srvr, http.MethodGet, "/participants", "", rotated.Token)
+	assert.Equal(t, http.StatusOK, res.Code)
+
+	res = keyRequest(srvr, http.MethodPost, "/admin/key/"+created.ID+"/revoke", "", testAdminKey)
+	assert.Equal(t, http.StatusOK, res.Code)
+
+	var revoked apikey.Key
+	assert.NoError(t, json.Unmarshal(res.Body.Bytes(), &revoked))
+	assert.NotNil(t, revoked.Revoked)
+
+	res = keyRequest(srvr, http.MethodGet, "/participants", "", rotated.Token)
+	assert.Equal(t, http.StatusUnauthorized, res.Code, "the revoked key should stop working")
+
+	res = keyRequest(srvr, http.MethodPost, "/admin/key/"+created.ID+"/rotate", "", testAdminKey)
+	assert.Equal(t, http.StatusConflict, res.Code, "a revoked key can't be rotated")
+
+	res = keyRequest(srvr, http.MethodPost, "/admin/key/"+created.ID+"/revoke", "", testAdminKey)
+	assert.Equal(t, http.StatusConflict, res.Code)
+}
+
+func TestServer_ServeHTTP_APIKeyErrors(t *testing.T) {
+	srvr, _ := authServer(t)
+
+	tests := []struct {
+		name    string
+		method  string
+		path    string
+		payload string
+		code    int
+	}{
+		{"invalid key", http.MethodPost, "/admin/key", `{"name":"","scopes":["write"]}`, http.StatusUnprocessableEntity},
+		{"unknown field", http.MethodPost, "/admin/key", `{"name":"Scoreboard","scopes":["read"],"hash":"x"}`, http.StatusBadRequest},
+		{"missing key", http.MethodGet, "/admin/key/missing", "", http.StatusNotFound},
+		{"rotate missing key", http.MethodPost, "/admin/key/missing/rotate", "", http.StatusNotFound},
+		{"revoke missing key", http.MethodPost, "/admin/key/missing/revoke", "", http.StatusNotFound},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			res := keyRequest(srvr, test.method, test.path, test.payload, testAdminKey)
+			assert.Equal(t, test.code, res.Code, res.Body.String())
+		})
+	}
+}
@@ -0,0 +1,170 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/rejlersembriq/hooked/pkg/attempt"
+	"github.com/rejlersembriq/hooked/pkg/participant"
+	"github.com/rejlersembriq/hooked/pkg/router"
+	"go.uber.org/zap"
+	"net/http"
+)
+
+// Updating the participant's score is retried this many times if the participant changes concurrently.
+const scoreUpdateAttempts = 3
+
+// AttemptRequest is the payload recording a new attempt.
+type AttemptRequest struct {
+	Score *int `json:"score"`
+}
+
+// AttemptResult is a recorded attempt and the participant with its score updated.
+type AttemptResult struct {
+	Attempt     *attempt.Attempt         `json:"attempt"`
+	Participant *participant.Participant `json:"participant"`
+}
+
+// attemptsGET returns the attempts of a participant, oldest first.
+func (s *Server) attemptsGET() http.HandlerFunc {
+	return func(res http.ResponseWriter, req *http.Request) {
+		id, exists := router.GetParam(req.Context(), "id")
+		if !exists {
+			sendProblem(http.StatusInternalServerError, "Unable to get request parameter").ServeHTTP(res, req)
+			return
+		}
+
+		if _, ok := s.getParticipant(res, req, id); !ok {
+			return
+		}
+
+		as, err := s.attempts.List(req.Context(), id)
+		if err != nil {
+			zap.L().Error("Error retrieveing resources.", zap.String("error", err.Error()))
+			sendProblem(http.StatusInternalServerError, "Error retrieving resources").ServeHTTP(res, req)
+			return
+		}
+
+		sendJSON(&as).ServeHTTP(res, req)
+	}
+}
+
+// attemptsPOST records an attempt and sets the participant's score from all its attempts, see attempt.Rule.
+func (s *Server) attemptsPOST() http.HandlerFunc {
+	return func(res http.ResponseWriter, req *http.Request) {
+		defer req.Body.Close()
+
+		id, exists := router.GetParam(req.Context(), "id")
+		if !exists {
+			sendProblem(http.StatusInternalServerError, "Unable to get request parameter").ServeHTTP(res, req)
+			return
+		}
+
+		var ar AttemptRequest
+		dec := json.NewDecoder(req.Body)
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&ar); err != nil {
+			decodeProblem(err).ServeHTTP(res, req)
+			return
+		}
+
+		verr := &participant.ValidationError{}
+		if ar.Score == nil {
+			addFieldError(verr, "score", "required")
+		} else if *ar.Score < participant.MinScore || *ar.Score > participant.MaxScore {
+			addFieldError(verr, "score", fmt.Sprintf("must be between %d and %d", participant.MinScore, participant.MaxScore))
+		}
+		if len(verr.Fields) > 0 {
+			sendValidationProblem(http.StatusUnprocessableEntity, "Invalid attempt", verr).ServeHTTP(res, req)
+			return
+		}
+
+		p, ok := s.getParticipant(res, req, id)
+		if !ok {
+			return
+		}
+
+		if !s.scoresOpen(res, req, p) {
+			return
+		}
+
+		saved, err := s.attempts.Save(req.Context(), attempt.Attempt{Participant: id, Score: *ar.Score})
+		if err != nil {
+			zap.L().Error("Error persisting resource.", zap.String("error", err.Error()))
+			sendProblem(http.StatusInternalServerError, "Error persisting resource").ServeHTTP(res, req)
+			return
+		}
+
+		p, err = s.updateScore(req.Context(), id)
+		if err != nil {
+			if errors.Is(err, participant.ErrNotExist) {
+				sendProblem(http.StatusNotFound, "Resource not found").ServeHTTP(res, req)
+				return
+			}
+
+			zap.L().Error("Error updating score.", zap.String("id", id), zap.String("error", err.Error()))
+			sendProblem(http.StatusInternalServerError, "Attempt recorded, but error updating the participant's score").ServeHTTP(res, req)
+			return
+		}
+
+		res.Header().Set(etagHeader, etag(p))
+		sendJSON(&AttemptResult{Attempt: saved, Participant: p}).ServeHTTP(res, req)
+	}
+}
+
+// updateScore sets the participant's score from its attempts. The attempts are read after the participant, and the
+// save fails if the participant changed since, so a concurrent attempt can't be overwritten by a stale score.
+func (s *Server) updateScore(ctx context.Context, id string) (*participant.Participant, error) {
+	for i := 0; i < scoreUpdateAttempts; i++ {
+		current, err := s.participantRepo.Get(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+
+		as, err := s.attempts.List(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+
+		score, ok := s.scoreRule.Score(as)
+		if !ok {
+			return current, nil
+		}
+
+		version := current.CurrentVersion()
+		saved, err := s.participantRepo.Save(ctx, participant.Participant{ID: &id, Score: &score, Version: &version})
+		if errors.Is(err, participant.ErrVersionConflict) {
+			continue
+		}
+
+		return saved, err
+	}
+
+	return nil, participant.ErrVersionConflict
+}
+
+// recordScore records score as an attempt of participant id, and sets its score from its attempts.
+func (s *Server) recordScore(ctx context.Context, id string, score int) (*participant.Participant, error) {
+	if _, err := s.attempts.Save(ctx, attempt.Attempt{Participant: id, Score: score}); err != nil {
+		return nil, err
+	}
+
+	return s.updateScore(ctx, id)
+}
+
+// moveAttempts gives the attempts of participant from to participant to, keeping their creation time.
+func (s *Server) moveAttempts(ctx context.Context, from, to string) error {
+	as, err := s.attempts.List(ctx, from)
+	if err != nil {
+		return err
+	}
+
+	for _, a := range as {
+		if _, err := s.attempts.Save(ctx, attempt.Attempt{Participant: to, Score: a.Score, Created: a.Created}); err != nil {
+			return err
+		}
+	}
+
+	return s.attempts.DeleteAll(ctx, from)
+}
@@ -0,0 +1,211 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/rejlersembriq/hooked/pkg/attempt"
+	"github.com/rejlersembriq/hooked/pkg/participant"
+	"github.com/rejlersembriq/hooked/pkg/repository/memory"
+	"github.com/rejlersembriq/hooked/pkg/router"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func attemptServer(t *testing.T, rule attempt.Rule) (*Server, *memory.Memory, *memory.AttemptRepository, string) {
+	repo := memory.New()
+	attempts := memory.NewAttemptRepository()
+
+	p, err := repo.Save(context.Background(), participant.Participant{
+		Name:  aws.String("Test Testson"),
+		Email: aws.String("test@testson.com"),
+		Score: aws.Int(5),
+	})
+	assert.NoError(t, err)
+
+	return New(router.New(), repo, WithAttempts(attempts, rule)), repo, attempts, *p.ID
+}
+
+func postAttempt(srvr *Server, id, payload string) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest(http.MethodPost, "/participant/"+id+"/attempts", strings.NewReader(payload))
+	res := httptest.NewRecorder()
+
+	srvr.ServeHTTP(res, req)
+
+	return res
+}
+
+func TestServer_ServeHTTP_POSTAttempt(t *testing.T) {
+	tests := []struct {
+		rule   attempt.Rule
+		scores []string
+		score  int
+	}{
+		{attempt.Best, []string{"10", "30", "20"}, 30},
+		{attempt.Latest, []string{"10", "30", "20"}, 20},
+		{attempt.Sum, []string{"10", "30", "20"}, 60},
+		{attempt.TopAverage(2), []string{"10", "30", "20"}, 25},
+	}
+
+	for _, test := range tests {
+		srvr, repo, _, id := attemptServer(t, test.rule)
+
+		var res *httptest.ResponseRecorder
+		for _, score := range test.scores {
+			res = postAttempt(srvr, id, `{"score":`+score+`}`)
+			assert.Equal(t, http.StatusOK, res.Code, test.rule.String())
+		}
+
+		var result AttemptResult
+		assert.NoError(t, json.Unmarshal(res.Body.Bytes(), &result))
+		assert.Equal(t, 20, result.Attempt.Score)
+		assert.Equal(t, id, result.Attempt.Participant)
+		assert.Equal(t, test.score, *result.Participant.Score, test.rule.String())
+		assert.Equal(t, etag(result.Participant), res.Header().Get(etagHeader))
+
+		p, err := repo.Get(context.Background(), id)
+		assert.NoError(t, err)
+		assert.Equal(t, test.score, *p.Score, test.rule.String())
+	}
+}
+
+func TestServer_ServeHTTP_POSTAttempt_Invalid(t *testing.T) {
+	srvr, _, attempts, id := attemptServer(t, attempt.Best)
+
+	tests := []struct {
+		id      string
+		payload string
+		status  int
+	}{
+		{id, `{}`, http.StatusUnprocessableEntity},
+		{id, `{"score":-1}`, http.StatusUnprocessableEntity},
+		{id, `{"score":1,"name":"x"}`, http.StatusBadRequest},
+		{id, `{`, http.StatusBadRequest},
+		{"nonExisting", `{"score":1}`, http.StatusNotFound},
+	}
+
+	for _, test := range tests {
+		res := postAttempt(srvr, test.id, test.payload)
+		assert.Equal(t, test.status, res.Code, test.payload)
+		assert.Equal(t, problemContentType, res.Header().Get("Content-Type"), test.payload)
+	}
+
+	as, err := attempts.List(context.Background(), id)
+	assert.NoError(t, err)
+	assert.Empty(t, as)
+}
+
+func TestServer_ServeHTTP_GETAttempts(t *testing.T) {
+	srvr, _, _, id := attemptServer(t, attempt.Best)
+
+	postAttempt(srvr, id, `{"score":10}`)
+	postAttempt(srvr, id, `{"score":20}`)
+
+	req, _ := http.NewRequest(http.MethodGet, "/participant/"+id+"/attempts", nil)
+	res := httptest.NewRecorder()
+
+	srvr.ServeHTTP(res, req)
+
+	assert.Equal(t, http.StatusOK, res.Code)
+
+	var as []attempt.Attempt
+	assert.NoError(t, json.Unmarshal(res.Body.Bytes(), &as))
+	if assert.Len(t, as, 2) {
+		assert.Equal(t, 10, as[0].Score)
+		assert.Equal(t, 20, as[1].Score)
+	}
+}
+
+func TestServer_ServeHTTP_GETAttempts_NotFound(t *testing.T) {
+	srvr, _, _, _ := attemptServer(t, attempt.Best)
+
+	req, _ := http.NewRequest(http.MethodGet, "/participant/nonExisting/attempts", nil)
+	res := httptest.NewRecorder()
+
+	srvr.ServeHTTP(res, req)
+
+	assert.Equal(t, http.StatusNotFound, res.Code)
+}
+
+func TestServer_ServeHTTP_PUTParticipant_ScoreWithAttempts(t *testing.T) {
+	srvr, repo, attempts, id := attemptServer(t, attempt.Best)
+
+	for _, payload := range []string{`{"score":10,"org":"OrgA"}`, `{"score":3}`} {
+		req, _ := http.NewRequest(http.MethodPut, "/participant/"+id, strings.NewReader(payload))
+		res := httptest.NewRecorder()
+		srvr.ServeHTTP(res, req)
+
+		assert.Equal(t, http.StatusOK, res.Code, payload)
+
+		var p participant.Participant
+		assert.NoError(t, json.NewDecoder(res.Body).Decode(&p))
+		assert.Equal(t, 10, *p.Score, "score should be derived from the attempts")
+		assert.Equal(t, "OrgA", *p.Org)
+	}
+
+	p, err := repo.Get(context.Background(), id)
+	assert.NoError(t, err)
+	assert.Equal(t, 10, *p.Score)
+
+	as, err := attempts.List(context.Background(), id)
+	assert.NoError(t, err)
+	if assert.Len(t, as, 2) {
+		assert.Equal(t, []int{10, 3}, []int{as[0].Score, as[1].Score})
+	}
+}
+
+func TestServer_ServeHTTP_POSTParticipant_ScoreWithAttempts(t *testing.T) {
+	srvr, _, attempts, _ := attemptServer(t, attempt.Best)
+
+	req, _ := http.NewRequest(http.MethodPost, "/participant", strings.NewReader(`{"name":"Annie","email":"annie@testson.com","score":7}`))
+	res := httptest.NewRecorder()
+	srvr.ServeHTTP(res, req)
+
+	assert.Equal(t, http.StatusOK, res.Code)
+
+	var p participant.Participant
+	assert.NoError(t, json.NewDecoder(res.Body).Decode(&p))
+	assert.Equal(t, 7, *p.Score)
+
+	as, err := attempts.List(context.Background(), *p.ID)
+	assert.NoError(t, err)
+	if assert.Len(t, as, 1) {
+		assert.Equal(t, 7, as[0].Score)
+	}
+
+	// The score registered with counts as an attempt, so a lower attempt doesn't replace it.
+	res = postAttempt(srvr, *p.ID, `{"score":3}`)
+	assert.Equal(t, http.StatusOK, res.Code)
+
+	var result AttemptResult
+	assert.NoError(t, json.NewDecoder(res.Body).Decode(&result))
+	assert.Equal(t, 7, *result.Participant.Score)
+}
+
+func TestServer_ServeHTTP_Attempts_Disabled(t *testing.T) {
+	srvr := New(router.New(), memory.New())
+
+	res := postAttempt(srvr, "someId", `{"score":1}`)
+
+	assert.Equal(t, http.StatusNotFound, res.Code)
+}
+
+func TestServer_ServeHTTP_DELETEParticipant_DeletesAttempts(t *testing.T) {
+	srvr, _, attempts, id := attemptServer(t, attempt.Best)
+
+	postAttempt(srvr, id, `{"score":10}`)
+
+	req, _ := http.NewRequest(http.MethodDelete, "/participant/"+id, nil)
+	res := httptest.NewRecorder()
+
+	srvr.ServeHTTP(res, req)
+
+	assert.Equal(t, http.StatusOK, res.Code)
+
+	as, err := attempts.List(context.Background(), id)
+	assert.NoError(t, err)
+	assert.Empty(t, as)
+}
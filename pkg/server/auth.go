@@ -0,0 +1,174 @@
+package server
+
+import (
+	"crypto/subtle"
+	"errors"
+	"github.com/rejlersembriq/hooked/pkg/apikey"
+	"github.com/rejlersembriq/hooked/pkg/auth"
+	"go.uber.org/zap"
+	"net/http"
+	"strings"
+)
+
+const (
+	apiKeyHeader        = "X-API-Key"
+	authorizationHeader = "Authorization"
+)
+
+// adminKeyID identifies the admin key given to WithAPIKeys, eg. in logs.
+const adminKeyID = "admin"
+
+// scopeRoles maps the scopes of API keys to the roles required by routes.
+var scopeRoles = map[string]string{
+	apikey.ScopeRead:       auth.RoleViewer,
+	apikey.ScopeWriteScore: auth.RoleScorer,
+	apikey.ScopeAdmin:      auth.RoleAdmin,
+}
+
+// authorize is the router.Authorize of the server. It requires claims verified by an upstream authorizer, a JWT in the
+// Authorization header, or an API key in the X-API-Key header, whose principal has one of roles, and adds the
+// principal to the request context. Responds 401 Unauthorized if the credentials are missing or invalid, and 403
+// Forbidden if they lack the roles. Routes are open if the server has no API keys, JWT or authorizer enabled.
+func (s *Server) authorize(res http.ResponseWriter, req *http.Request, roles []string) (*http.Request, bool) {
+	if s.keys == nil && s.jwt == nil && s.authorizerRoles == nil {
+		return req, true
+	}
+
+	var p *auth.Principal
+	var ok bool
+	if claims, verified := auth.ClaimsFromContext(req.Context()); verified && s.authorizerRoles != nil {
+		// The authorizer may have verified the Authorization header itself, so its claims go first.
+		p, ok = s.authorizerPrincipal(claims), true
+	} else if header := req.Header.Get(authorizationHeader); header != "" {
+		p, ok = s.authenticateJWT(res, req, header)
+	} else if token := req.Header.Get(apiKeyHeader); token != "" {
+		p, ok = s.authenticateAPIKey(res, req, token)
+	} else {
+		s.unauthorized("Missing credentials. Provide an API key in the "+apiKeyHeader+" header, or a bearer token in the "+authorizationHeader+" header.").ServeHTTP(res, req)
+		return req, false
+	}
+
+	if !ok {
+		return req, false
+	}
+
+	if !p.HasAny(roles...) {
+		setCommonHeaders(sendProblem(http.StatusForbidden, "Requires the "+strings.Join(roles, " or ")+" role")).ServeHTTP(res, req)
+		return req, false
+	}
+
+	return req.WithContext(auth.NewContext(req.Context(), p)), true
+}
+
+// authenticateJWT verifies a bearer token, mapping its claims to roles.
+func (s *Server) authenticateJWT(res http.ResponseWriter, req *http.Request, header string) (*auth.Principal, bool) {
+	if s.jwt == nil {
+		s.unauthorized("Bearer tokens are not accepted").ServeHTTP(res, req)
+		return nil, false
+	}
+
+	const prefix = "Bearer "
+	if len(header) <= len(prefix) || !strings.EqualFold(header[:len(prefix)], prefix) {
+		s.unauthorized("Invalid "+authorizationHeader+" header. Must be Bearer followed by a token.").ServeHTTP(res, req)
+		return nil, false
+	}
+
+	claims, err := s.jwt.Verify(strings.TrimSpace(header[len(prefix):]))
+	if err != nil {
+		s.unauthorized("Invalid bearer token: "+err.Error()).ServeHTTP(res, req)
+		return nil, false
+	}
+
+	return &auth.Principal{
+		Subject: claims.String("sub"),
+		Method:  auth.MethodJWT,
+		Roles:   s.roleMapper.Roles(claims),
+		Claims:  claims,
+	}, true
+}
+
+// authorizerPrincipal maps the claims of an upstream authorizer to roles. The subject is the "sub" claim of a Cognito
+// user pool, or the principalId of a custom authorizer.
+func (s *Server) authorizerPrincipal(claims map[string]interface{}) *auth.Principal {
+	subject, _ := claims["sub"].(string)
+	if subject == "" {
+		subject, _ = claims["principalId"].(string)
+	}
+
+	return &auth.Principal{
+		Subject: subject,
+		Method:  auth.MethodAuthorizer,
+		Roles:   s.authorizerRoles.Roles(claims),
+		Claims:  claims,
+	}
+}
+
+// authenticateAPIKey looks up an API key, mapping its scopes to roles. The admin key has the admin role.
+func (s *Server) authenticateAPIKey(res http.ResponseWriter, req *http.Request, token string) (*auth.Principal, bool) {
+	if s.keys == nil {
+		s.unauthorized("API keys are not accepted").ServeHTTP(res, req)
+		return nil, false
+	}
+
+	if s.adminKeyHash != "" && subtle.ConstantTimeCompare([]byte(apikey.Hash(token)), []byte(s.adminKeyHash)) == 1 {
+		return &auth.Principal{Subject: adminKeyID, Method: auth.MethodAPIKey, Roles: []string{auth.RoleAdmin}}, true
+	}
+
+	id, secret, err := apikey.Parse(token)
+	if err != nil {
+		s.unauthorized("Invalid API key").ServeHTTP(res, req)
+		return nil, false
+	}
+
+	k, err := s.keys.Get(req.Context(), id)
+	if err != nil {
+		if errors.Is(err, apikey.ErrNotExist) {
+			s.unauthorized("Invalid API key").ServeHTTP(res, req)
+			return nil, false
+		}
+
+		zap.L().Error("Error retrieving API key.", zap.String("error", err.Error()))
+		setCommonHeaders(sendProblem(http.StatusInternalServerError, "Error retrieving API key")).ServeHTTP(res, req)
+		return nil, false
+	}
+
+	if !k.Verify(secret) {
+		s.unauthorized("Invalid API key").ServeHTTP(res, req)
+		return nil, false
+	}
+
+	if k.Revoked != nil {
+		s.unauthorized("API key revoked").ServeHTTP(res, req)
+		return nil, false
+	}
+
+	p := &auth.Principal{Subject: k.ID, Method: auth.MethodAPIKey}
+	for _, scope := range k.Scopes {
+		if role, ok := scopeRoles[scope]; ok {
+			p.Roles = append(p.Roles, role)
+		}
+	}
+
+	return p, true
+}
+
+// unauthorized responds 401 Unauthorized, naming the credentials accepted.
+func (s *Server) unauthorized(detail string) http.HandlerFunc {
+	return setCommonHeaders(func(res http.ResponseWriter, req *http.Request) {
+		if s.keys != nil {
+			res.Header().Add("WWW-Authenticate", `APIKey header="`+apiKeyHeader+`"`)
+		}
+		if s.jwt != nil {
+			res.Header().Add("WWW-Authenticate", "Bearer")
+		}
+		sendProblem(http.StatusUnauthorized, detail).ServeHTTP(res, req)
+	})
+}
+
+// principalSubject gets the subject of the principal authorizing the request from context, eg. for logs.
+func principalSubject(req *http.Request) string {
+	if p, ok := auth.FromContext(req.Context()); ok {
+		return p.Subject
+	}
+	return ""
+}
@@ -0,0 +1,340 @@
+package server
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"github.com/rejlersembriq/hooked/pkg/apikey"
+	"github.com/rejlersembriq/hooked/pkg/auth"
+	"github.com/rejlersembriq/hooked/pkg/jwt"
+	"github.com/rejlersembriq/hooked/pkg/repository/memory"
+	"github.com/rejlersembriq/hooked/pkg/router"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testAdminKey = "admin-key-0123456789abcdef0123456789"
+
+func authServer(t *testing.T) (*Server, *memory.APIKeyRepository) {
+	keys := memory.NewAPIKeyRepository()
+	return New(router.New(), memory.New(), WithAPIKeys(keys, testAdminKey)), keys
+}
+
+// createKey stores a key with the given scopes, returning its token.
+func createKey(t *testing.T, keys apikey.Repository, scopes ...string) string {
+	t.Helper()
+
+	token, hash, err := apikey.New("key-" + strings.Join(scopes, "-"))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	id, _, _ := apikey.Parse(token)
+	if _, err := keys.Create(context.Background(), apikey.Key{ID: id, Name: "Test", Scopes: scopes, Hash: hash, Created: time.Now()}); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	return token
+}
+
+func keyRequest(srvr *Server, method, path, payload, key string) *httptest.ResponseRecorder {
+	var body io.Reader
+	if payload != "" {
+		body = strings.NewReader(payload)
+	}
+
+	req, _ := http.NewRequest(method, path, body)
+	if key != "" {
+		req.Header.Set(apiKeyHeader, key)
+	}
+	res := httptest.NewRecorder()
+
+	srvr.ServeHTTP(res, req)
+
+	return res
+}
+
+func TestServer_ServeHTTP_Authorize(t *testing.T) {
+	srvr, keys := authServer(t)
+
+	read := createKey(t, keys, apikey.ScopeRead)
+	write := createKey(t, keys, apikey.ScopeWriteScore)
+	admin := createKey(t, keys, apikey.ScopeAdmin)
+
+	participant := `{"name":"Annie","email":"annie@testson.com","org":"OrgA","score":10}`
+
+	tests := []struct {
+		name    string
+		method  string
+		path    string
+		payload string
+		key     string
+		code    int
+	}{
+		{"public leaderboard", http.MethodGet, "/leaderboard", "", "", http.StatusOK},
+		{"public org leaderboard", http.MethodGet, "/org/OrgA/leaderboard", "", "", http.StatusOK},
+		{"public preflight", http.MethodOptions, "/participant", "", "", http.StatusOK},
+		{"missing key", http.MethodGet, "/participants", "", "", http.StatusUnauthorized},
+		{"malformed key", http.MethodGet, "/participants", "", "secret", http.StatusUnauthorized},
+		{"unknown key", http.MethodGet, "/participants", "", "hk_missing.secret", http.StatusUnauthorized},
+		{"wrong secret", http.MethodGet, "/participants", "", read + "0", http.StatusUnauthorized},
+		{"read", http.MethodGet, "/participants", "", read, http.StatusOK},
+		{"read can't write", http.MethodPost, "/participant", participant, read, http.StatusForbidden},
+		{"write", http.MethodPost, "/participant", participant, write, http.StatusOK},
+		{"write can read", http.MethodGet, "/orgs", "", write, http.StatusOK},
+		{"write can't delete", http.MethodDelete, "/participant/missing", "", write, http.StatusForbidden},
+		{"write can't administrate", http.MethodGet, "/admin/duplicates", "", write, http.StatusForbidden},
+		{"admin", http.MethodGet, "/admin/duplicates", "", admin, http.StatusOK},
+		{"admin can delete", http.MethodDelete, "/participant/missing", "", admin, http.StatusNotFound},
+		{"admin key", http.MethodGet, "/admin/keys", "", testAdminKey, http.StatusOK},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			res := keyRequest(srvr, test.method, test.path, test.payload, test.key)
+			assert.Equal(t, test.code, res.Code, res.Body.String())
+
+			if test.code == http.StatusUnauthorized {
+				assert.Equal(t, `APIKey header="X-API-Key"`, res.Header().Get("WWW-Authenticate"))
+			}
+		})
+	}
+}
+
+func TestServer_ServeHTTP_AuthorizeRevoked(t *testing.T) {
+	srvr, keys := authServer(t)
+
+	token := createKey(t, keys, apikey.ScopeAdmin)
+	id, _, _ := apikey.Parse(token)
+
+	_, err := keys.Revoke(context.Background(), id, time.Now())
+	assert.NoError(t, err)
+
+	res := keyRequest(srvr, http.MethodGet, "/participants", "", token)
+	assert.Equal(t, http.StatusUnauthorized, res.Code)
+	assert.Contains(t, res.Body.String(), "revoked")
+}
+
+func TestServer_ServeHTTP_AuthorizeDisabled(t *testing.T) {
+	srvr := New(router.New(), memory.New())
+
+	res := keyRequest(srvr, http.MethodDelete, "/participant/missing", "", "")
+	assert.Equal(t, http.StatusNotFound, res.Code, "routes should be open without a key repository")
+
+	res = keyRequest(srvr, http.MethodGet, "/admin/keys", "", "")
+	assert.Equal(t, http.StatusNotFound, res.Code)
+}
+
+func TestServer_ServeHTTP_AuthorizeNoAdminKey(t *testing.T) {
+	keys := memory.NewAPIKeyRepository()
+	srvr := New(router.New(), memory.New(), WithAPIKeys(keys, ""))
+
+	res := keyRequest(srvr, http.MethodGet, "/admin/keys", "", apikey.Hash(""))
+	assert.Equal(t, http.StatusUnauthorized, res.Code)
+}
+
+const testJWTSecret = "jwt-secret"
+
+// signToken returns an HS256 token with claims, signed with testJWTSecret.
+func signToken(claims map[string]interface{}) string {
+	segment := func(v interface{}) string {
+		b, _ := json.Marshal(v)
+		return base64.RawURLEncoding.EncodeToString(b)
+	}
+
+	input := segment(map[string]string{"alg": jwt.HS256, "typ": "JWT"}) + "." + segment(claims)
+	mac := hmac.New(sha256.New, []byte(testJWTSecret))
+	mac.Write([]byte(input))
+
+	return input + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func bearerRequest(srvr *Server, method, path, payload, authorization string) *httptest.ResponseRecorder {
+	var body io.Reader
+	if payload != "" {
+		body = strings.NewReader(payload)
+	}
+
+	req, _ := http.NewRequest(method, path, body)
+	if authorization != "" {
+		req.Header.Set(authorizationHeader, authorization)
+	}
+	res := httptest.NewRecorder()
+
+	srvr.ServeHTTP(res, req)
+
+	return res
+}
+
+func TestServer_ServeHTTP_AuthorizeJWT(t *testing.T) {
+	keys := memory.NewAPIKeyRepository()
+	verifier := jwt.NewVerifier(jwt.WithSecret([]byte(testJWTSecret)), jwt.WithIssuer("https://idp.example.com"))
+	mapper := auth.RoleMapper{Claim: "groups", Mapping: map[string]string{"staff": auth.RoleScorer, "it": auth.RoleAdmin}}
+	srvr := New(router.New(), memory.New(), WithAPIKeys(keys, testAdminKey), WithJWT(verifier, mapper))
+
+	token := func(groups ...string) string {
+		return "Bearer " + signToken(map[string]interface{}{
+			"sub":    "annie",
+			"iss":    "https://idp.example.com",
+			"exp":    time.Now().Add(time.Hour).Unix(),
+			"groups": groups,
+		})
+	}
+
+	expired := "Bearer " + signToken(map[string]interface{}{"sub": "annie", "iss": "https://idp.example.com", "exp": time.Now().Add(-time.Hour).Unix()})
+	participant := `{"name":"Annie","email":"annie@testson.com","org":"OrgA","score":10}`
+
+	tests := []struct {
+		name          string
+		method        string
+		path          string
+		payload       string
+		authorization string
+		code          int
+	}{
+		{"missing token", http.MethodGet, "/participants", "", "", http.StatusUnauthorized},
+		{"not bearer", http.MethodGet, "/participants", "", "Basic YW5uaWU6c2VjcmV0", http.StatusUnauthorized},
+		{"invalid token", http.MethodGet, "/participants", "", "Bearer invalid", http.StatusUnauthorized},
+		{"expired", http.MethodGet, "/participants", "", expired, http.StatusUnauthorized},
+		{"no roles", http.MethodGet, "/participants", "", token("visitors"), http.StatusForbidden},
+		{"scorer", http.MethodPost, "/participant", participant, token("staff"), http.StatusOK},
+		{"scorer can read", http.MethodGet, "/participants", "", token("staff"), http.StatusOK},
+		{"scorer can't delete", http.MethodDelete, "/participant/missing", "", token("staff"), http.StatusForbidden},
+		{"admin", http.MethodDelete, "/participant/missing", "", token("visitors", "it"), http.StatusNotFound},
+		{"case insensitive scheme", http.MethodGet, "/participants", "", "bearer " + strings.TrimPrefix(token("staff"), "Bearer "), http.StatusOK},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			res := bearerRequest(srvr, test.method, test.path, test.payload, test.authorization)
+			assert.Equal(t, test.code, res.Code, res.Body.String())
+			assert.Equal(t, "*", res.Header().Get("Access-Control-Allow-Origin"))
+
+			if test.code == http.StatusUnauthorized {
+				assert.Equal(t, []string{`APIKey header="X-API-Key"`, "Bearer"}, res.Header()["Www-Authenticate"])
+			}
+		})
+	}
+
+	res := keyRequest(srvr, http.MethodGet, "/participants", "", testAdminKey)
+	assert.Equal(t, http.StatusOK, res.Code, "API keys should still be accepted")
+}
+
+func TestServer_ServeHTTP_AuthorizeJWTOnly(t *testing.T) {
+	verifier := jwt.NewVerifier(jwt.WithSecret([]byte(testJWTSecret)))
+	srvr := New(router.New(), memory.New(), WithJWT(verifier, auth.RoleMapper{Claim: "roles"}))
+
+	res := keyRequest(srvr, http.MethodGet, "/participants", "", testAdminKey)
+	assert.Equal(t, http.StatusUnauthorized, res.Code, "API keys should be refused without a key repository")
+	assert.Equal(t, "Bearer", res.Header().Get("WWW-Authenticate"))
+
+	res = bearerRequest(srvr, http.MethodGet, "/participants", "", "Bearer "+signToken(map[string]interface{}{
+		"sub": "annie", "exp": time.Now().Add(time.Hour).Unix(), "roles": "viewer",
+	}))
+	assert.Equal(t, http.StatusOK, res.Code, res.Body.String())
+
+	res = bearerRequest(srvr, http.MethodGet, "/leaderboard", "", "")
+	assert.Equal(t, http.StatusOK, res.Code)
+}
+
+func TestServer_authorize_Principal(t *testing.T) {
+	verifier := jwt.NewVerifier(jwt.WithSecret([]byte(testJWTSecret)))
+	keys := memory.NewAPIKeyRepository()
+	srvr := New(router.New(), memory.New(), WithAPIKeys(keys, testAdminKey), WithJWT(verifier, auth.RoleMapper{Claim: "roles"}))
+
+	var got *auth.Principal
+	srvr.router.GET("/whoami", func(res http.ResponseWriter, req *http.Request) {
+		got, _ = auth.FromContext(req.Context())
+	}, router.Require(auth.RoleViewer))
+
+	res := bearerRequest(srvr, http.MethodGet, "/whoami", "", "Bearer "+signToken(map[string]interface{}{
+		"sub": "annie", "exp": time.Now().Add(time.Hour).Unix(), "roles": []string{"scorer"}, "email": "annie@testson.com",
+	}))
+	assert.Equal(t, http.StatusOK, res.Code, res.Body.String())
+	if assert.NotNil(t, got) {
+		assert.Equal(t, "annie", got.Subject)
+		assert.Equal(t, auth.MethodJWT, got.Method)
+		assert.Equal(t, []string{auth.RoleScorer}, got.Roles)
+		assert.Equal(t, "annie@testson.com", got.Claims["email"])
+	}
+
+	read := createKey(t, keys, apikey.ScopeRead, apikey.ScopeWriteScore)
+	res = keyRequest(srvr, http.MethodGet, "/whoami", "", read)
+	assert.Equal(t, http.StatusOK, res.Code, res.Body.String())
+	if assert.NotNil(t, got) {
+		id, _, _ := apikey.Parse(read)
+		assert.Equal(t, id, got.Subject)
+		assert.Equal(t, auth.MethodAPIKey, got.Method)
+		assert.Equal(t, []string{auth.RoleViewer, auth.RoleScorer}, got.Roles)
+		assert.Nil(t, got.Claims)
+	}
+}
+
+func TestServer_ServeHTTP_AuthorizeAuthorizerClaims(t *testing.T) {
+	verifier := jwt.NewVerifier(jwt.WithSecret([]byte(testJWTSecret)))
+	srvr := New(router.New(), memory.New(),
+		WithJWT(verifier, auth.RoleMapper{Claim: "roles"}),
+		WithAuthorizerClaims(auth.RoleMapper{Claim: "cognito:groups", Mapping: map[string]string{"staff": auth.RoleScorer}}))
+
+	var got *auth.Principal
+	srvr.router.GET("/whoami", func(res http.ResponseWriter, req *http.Request) {
+		got, _ = auth.FromContext(req.Context())
+	}, router.Require(auth.RoleViewer))
+
+	request := func(claims map[string]interface{}, authorization string) *httptest.ResponseRecorder {
+		got = nil
+		req, _ := http.NewRequest(http.MethodGet, "/whoami", nil)
+		if claims != nil {
+			req = req.WithContext(auth.NewClaimsContext(req.Context(), claims))
+		}
+		if authorization != "" {
+			req.Header.Set(authorizationHeader, authorization)
+		}
+		res := httptest.NewRecorder()
+		srvr.ServeHTTP(res, req)
+		return res
+	}
+
+	// The authorizer verified the Cognito token in the Authorization header, which the server can't.
+	res := request(map[string]interface{}{"sub": "annie", "cognito:groups": "visitors,staff"}, "Bearer cognito-token")
+	assert.Equal(t, http.StatusOK, res.Code, res.Body.String())
+	if assert.NotNil(t, got) {
+		assert.Equal(t, "annie", got.Subject)
+		assert.Equal(t, auth.MethodAuthorizer, got.Method)
+		assert.Equal(t, []string{auth.RoleScorer}, got.Roles)
+	}
+
+	res = request(map[string]interface{}{"principalId": "bob", "cognito:groups": "staff"}, "")
+	assert.Equal(t, http.StatusOK, res.Code, res.Body.String())
+	if assert.NotNil(t, got) {
+		assert.Equal(t, "bob", got.Subject)
+	}
+
+	res = request(map[string]interface{}{"sub": "annie", "cognito:groups": "visitors"}, "")
+	assert.Equal(t, http.StatusForbidden, res.Code)
+
+	res = request(nil, "")
+	assert.Equal(t, http.StatusUnauthorized, res.Code, "requests without claims should need credentials")
+
+	res = request(nil, "Bearer "+signToken(map[string]interface{}{"sub": "carol", "exp": time.Now().Add(time.Hour).Unix(), "roles": "viewer"}))
+	assert.Equal(t, http.StatusOK, res.Code, "JWTs should be accepted without claims")
+}
+
+func TestServer_ServeHTTP_AuthorizerClaimsNotTrusted(t *testing.T) {
+	verifier := jwt.NewVerifier(jwt.WithSecret([]byte(testJWTSecret)))
+	srvr := New(router.New(), memory.New(), WithJWT(verifier, auth.RoleMapper{Claim: "roles"}))
+
+	req, _ := http.NewRequest(http.MethodGet, "/participants", nil)
+	req = req.WithContext(auth.NewClaimsContext(req.Context(), map[string]interface{}{"sub": "annie", "roles": "admin"}))
+	res := httptest.NewRecorder()
+	srvr.ServeHTTP(res, req)
+
+	assert.Equal(t, http.StatusUnauthorized, res.Code, "claims should only be trusted WithAuthorizerClaims")
+}
@@ -0,0 +1,156 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/rejlersembriq/hooked/pkg/draw"
+	"github.com/rejlersembriq/hooked/pkg/participant"
+	"github.com/rejlersembriq/hooked/pkg/router"
+	"go.uber.org/zap"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// VerifyResult reports if repeating a draw from its recorded seed and entries picks the recorded winners.
+type VerifyResult struct {
+	ID       string `json:"id"`
+	Verified bool   `json:"verified"`
+}
+
+// drawsGET returns all draws, newest first.
+func (s *Server) drawsGET() http.HandlerFunc {
+	return func(res http.ResponseWriter, req *http.Request) {
+		ds, err := s.draws.List(req.Context())
+		if err != nil {
+			zap.L().Error("Error retrieveing resources.", zap.String("error", err.Error()))
+			sendProblem(http.StatusInternalServerError, "Error retrieving resources").ServeHTTP(res, req)
+			return
+		}
+
+		sendJSON(&ds).ServeHTTP(res, req)
+	}
+}
+
+// drawPOST runs and records a draw among the participants matching the criteria, see draw.Run. A draw id can only be
+// used once, so a recorded draw can't be re-rolled. Draws without eligible participants aren't recorded.
+func (s *Server) drawPOST() http.HandlerFunc {
+	return func(res http.ResponseWriter, req *http.Request) {
+		defer req.Body.Close()
+
+		var dr draw.Request
+		dec := json.NewDecoder(req.Body)
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&dr); err != nil {
+			decodeProblem(err).ServeHTTP(res, req)
+			return
+		}
+
+		if err := draw.Validate(dr); err != nil {
+			var verr *participant.ValidationError
+			if errors.As(err, &verr) {
+				sendValidationProblem(http.StatusUnprocessableEntity, "Invalid draw", verr).ServeHTTP(res, req)
+				return
+			}
+
+			sendProblem(http.StatusUnprocessableEntity, err.Error()).ServeHTTP(res, req)
+			return
+		}
+
+		page, err := s.participantRepo.Query(req.Context(), dr.Criteria.Query())
+		if err != nil {
+			zap.L().Error("Error retrieveing resources.", zap.String("error", err.Error()))
+			sendProblem(http.StatusInternalServerError, "Error retrieving resources").ServeHTTP(res, req)
+			return
+		}
+
+		d, err := draw.Run(dr, page.Participants, time.Now())
+		if err != nil {
+			zap.L().Error("Error running draw.", zap.String("error", err.Error()))
+			sendProblem(http.StatusInternalServerError, "Error running draw").ServeHTTP(res, req)
+			return
+		}
+
+		if len(d.Entries) == 0 {
+			sendProblem(http.StatusUnprocessableEntity, "No participants match the criteria", participant.FieldError{Field: "criteria", Message: "no eligible participants"}).ServeHTTP(res, req)
+			return
+		}
+
+		d.RequestID, _ = getRequestID(req.Context())
+
+		saved, err := s.draws.Create(req.Context(), d)
+		if err != nil {
+			if errors.Is(err, draw.ErrExists) {
+				res.Header().Set(locationHeader, "/admin/draw/"+url.PathEscape(dr.ID))
+				sendProblem(http.StatusConflict, "Draw "+dr.ID+" has already been held", participant.FieldError{Field: "id", Message: "already in use"}).ServeHTTP(res, req)
+				return
+			}
+
+			zap.L().Error("Error persisting resource.", zap.String("error", err.Error()))
+			sendProblem(http.StatusInternalServerError, "Error persisting resource").ServeHTTP(res, req)
+			return
+		}
+
+		zap.L().Info("Held draw.", zap.String("id", saved.ID), zap.String("seed", saved.Seed), zap.Int("entries", len(saved.Entries)), zap.Int("winners", len(saved.Winners)), zap.String("requestId", saved.RequestID))
+		sendJSON(&saved).ServeHTTP(res, req)
+	}
+}
+
+func (s *Server) drawGET() http.HandlerFunc {
+	return func(res http.ResponseWriter, req *http.Request) {
+		id, exists := router.GetParam(req.Context(), "id")
+		if !exists {
+			sendProblem(http.StatusInternalServerError, "Unable to get request parameter").ServeHTTP(res, req)
+			return
+		}
+
+		d, ok := s.getDraw(res, req, id)
+		if !ok {
+			return
+		}
+
+		sendJSON(&d).ServeHTTP(res, req)
+	}
+}
+
+// drawVerifyGET repeats a recorded draw, see draw.Verify.
+func (s *Server) drawVerifyGET() http.HandlerFunc {
+	return func(res http.ResponseWriter, req *http.Request) {
+		id, exists := router.GetParam(req.Context(), "id")
+		if !exists {
+			sendProblem(http.StatusInternalServerError, "Unable to get request parameter").ServeHTTP(res, req)
+			return
+		}
+
+		d, ok := s.getDraw(res, req, id)
+		if !ok {
+			return
+		}
+
+		verified, err := draw.Verify(*d)
+		if err != nil {
+			zap.L().Error("Error verifying draw.", zap.String("id", id), zap.String("error", err.Error()))
+			sendProblem(http.StatusInternalServerError, "Error verifying draw").ServeHTTP(res, req)
+			return
+		}
+
+		sendJSON(&VerifyResult{ID: d.ID, Verified: verified}).ServeHTTP(res, req)
+	}
+}
+
+// getDraw retrieves a draw, responding with a problem if it fails.
+func (s *Server) getDraw(res http.ResponseWriter, req *http.Request, id string) (*draw.Draw, bool) {
+	d, err := s.draws.Get(req.Context(), id)
+	if err != nil {
+		if errors.Is(err, draw.ErrNotExist) {
+			sendProblem(http.StatusNotFound, "Draw "+id+" not found").ServeHTTP(res, req)
+			return nil, false
+		}
+
+		zap.L().Error("Error retrieving resource.", zap.String("error", err.Error()))
+		sendProblem(http.StatusInternalServerError, "Error retrieving resource").ServeHTTP(res, req)
+		return nil, false
+	}
+
+	return d, true
+}
@@ -0,0 +1,157 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/rejlersembriq/hooked/pkg/draw"
+	"github.com/rejlersembriq/hooked/pkg/participant"
+	"github.com/rejlersembriq/hooked/pkg/repository/memory"
+	"github.com/rejlersembriq/hooked/pkg/router"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"testing"
+)
+
+func drawServer(t *testing.T) (*Server, *memory.DrawRepository) {
+	repo := memory.New()
+	draws := memory.NewDrawRepository()
+
+	for _, p := range []participant.Participant{
+		{Name: aws.String("Annie"), Email: aws.String("annie@testson.com"), Org: aws.String("OrgA"), Score: aws.Int(10), Consent: aws.Bool(true)},
+		{Name: aws.String("Bob"), Email: aws.String("bob@testson.com"), Org: aws.String("OrgA"), Score: aws.Int(20), Consent: aws.Bool(true)},
+		{Name: aws.String("Carl"), Email: aws.String("carl@testson.com"), Org: aws.String("OrgA"), Score: aws.Int(30)},
+		{Name: aws.String("Dina"), Email: aws.String("dina@testson.com"), Org: aws.String("OrgB"), Score: aws.Int(40), Consent: aws.Bool(true)},
+	} {
+		_, err := repo.Save(context.Background(), p)
+		assert.NoError(t, err)
+	}
+
+	return New(router.New(), repo, WithDraws(draws)), draws
+}
+
+func TestServer_ServeHTTP_POSTDraw(t *testing.T) {
+	srvr, draws := drawServer(t)
+
+	res := eventRequest(srvr, http.MethodPost, "/admin/draw", `{"id":"tshirts","winners":1,"weighted":true,"criteria":{"org":"OrgA","minScore":15,"consent":true}}`)
+
+	assert.Equal(t, http.StatusOK, res.Code)
+
+	var d draw.Draw
+	assert.NoError(t, json.Unmarshal(res.Body.Bytes(), &d))
+	assert.Equal(t, "tshirts", d.ID)
+	assert.NotEmpty(t, d.Seed)
+	assert.NotEmpty(t, d.RequestID)
+	if assert.Len(t, d.Entries, 1) && assert.Len(t, d.Winners, 1) {
+		assert.Equal(t, 20, d.Entries[0].Weight)
+		assert.Equal(t, "Bob", d.Winners[0].Name)
+	}
+
+	_, err := draws.Get(context.Background(), "tshirts")
+	assert.NoError(t, err)
+}
+
+func TestServer_ServeHTTP_POSTDraw_Seed(t *testing.T) {
+	srvr, draws := drawServer(t)
+
+	res := eventRequest(srvr, http.MethodPost, "/admin/draw", `{"id":"chosen","winners":2,"seed":"000102030405060708090a0b0c0d0e0f"}`)
+	assert.Equal(t, http.StatusBadRequest, res.Code, "the caller can't choose the seed")
+
+	_, err := draws.Get(context.Background(), "chosen")
+	assert.True(t, errors.Is(err, draw.ErrNotExist))
+
+	first := eventRequest(srvr, http.MethodPost, "/admin/draw", `{"id":"first","winners":2}`)
+	second := eventRequest(srvr, http.MethodPost, "/admin/draw", `{"id":"second","winners":2}`)
+
+	var a, b draw.Draw
+	assert.NoError(t, json.Unmarshal(first.Body.Bytes(), &a))
+	assert.NoError(t, json.Unmarshal(second.Body.Bytes(), &b))
+
+	assert.NotEmpty(t, a.Seed)
+	assert.NotEqual(t, a.Seed, b.Seed, "every draw gets a new seed")
+}
+
+func TestServer_ServeHTTP_POSTDraw_NoReroll(t *testing.T) {
+	srvr, draws := drawServer(t)
+
+	res := eventRequest(srvr, http.MethodPost, "/admin/draw", `{"id":"tshirts","winners":1}`)
+	assert.Equal(t, http.StatusOK, res.Code)
+
+	original, err := draws.Get(context.Background(), "tshirts")
+	assert.NoError(t, err)
+
+	res = eventRequest(srvr, http.MethodPost, "/admin/draw", `{"id":"tshirts","winners":3}`)
+	assert.Equal(t, http.StatusConflict, res.Code)
+	assert.Equal(t, "/admin/draw/tshirts", res.Header().Get(locationHeader))
+
+	got, err := draws.Get(context.Background(), "tshirts")
+	assert.NoError(t, err)
+	assert.Equal(t, original, got, "recorded draw should be unchanged")
+}
+
+func TestServer_ServeHTTP_POSTDraw_Invalid(t *testing.T) {
+	srvr, draws := drawServer(t)
+
+	tests := []struct {
+		payload string
+		status  int
+	}{
+		{`{"id":"","winners":1}`, http.StatusUnprocessableEntity},
+		{`{"id":"prize","winners":0}`, http.StatusUnprocessableEntity},
+		{`{"id":"prize","winners":1,"criteria":{"org":"OrgC"}}`, http.StatusUnprocessableEntity},
+		{`{"id":"prize","winners":1,"rigged":true}`, http.StatusBadRequest},
+	}
+
+	for _, test := range tests {
+		res := eventRequest(srvr, http.MethodPost, "/admin/draw", test.payload)
+		assert.Equal(t, test.status, res.Code, test.payload)
+	}
+
+	ds, err := draws.List(context.Background())
+	assert.NoError(t, err)
+	assert.Empty(t, ds)
+}
+
+func TestServer_ServeHTTP_GETDraw(t *testing.T) {
+	srvr, draws := drawServer(t)
+
+	eventRequest(srvr, http.MethodPost, "/admin/draw", `{"id":"tshirts","winners":2}`)
+
+	res := eventRequest(srvr, http.MethodGet, "/admin/draw/tshirts", "")
+	assert.Equal(t, http.StatusOK, res.Code)
+
+	var d draw.Draw
+	assert.NoError(t, json.Unmarshal(res.Body.Bytes(), &d))
+	assert.Len(t, d.Winners, 2)
+
+	res = eventRequest(srvr, http.MethodGet, "/admin/draws", "")
+	assert.Equal(t, http.StatusOK, res.Code)
+
+	var ds []draw.Draw
+	assert.NoError(t, json.Unmarshal(res.Body.Bytes(), &ds))
+	assert.Len(t, ds, 1)
+
+	res = eventRequest(srvr, http.MethodGet, "/admin/draw/missing", "")
+	assert.Equal(t, http.StatusNotFound, res.Code)
+
+	res = eventRequest(srvr, http.MethodGet, "/admin/draw/tshirts/verify", "")
+	assert.Equal(t, http.StatusOK, res.Code)
+	assert.JSONEq(t, `{"id":"tshirts","verified":true}`, res.Body.String())
+
+	// A draw tampered with in storage fails verification.
+	d.ID = "tampered"
+	d.Winners[0], d.Winners[1] = d.Winners[1], d.Winners[0]
+	_, err := draws.Create(context.Background(), d)
+	assert.NoError(t, err)
+
+	res = eventRequest(srvr, http.MethodGet, "/admin/draw/tampered/verify", "")
+	assert.JSONEq(t, `{"id":"tampered","verified":false}`, res.Body.String())
+}
+
+func TestServer_ServeHTTP_DrawsDisabled(t *testing.T) {
+	srvr := New(router.New(), memory.New())
+
+	res := eventRequest(srvr, http.MethodPost, "/admin/draw", `{"id":"tshirts","winners":1}`)
+	assert.Equal(t, http.StatusNotFound, res.Code)
+}
@@ -0,0 +1,44 @@
+package server
+
+import (
+	"github.com/rejlersembriq/hooked/pkg/participant"
+	"strconv"
+	"strings"
+)
+
+// Conditional request headers, RFC 7232.
+const (
+	etagHeader        = "ETag"
+	ifMatchHeader     = "If-Match"
+	ifNoneMatchHeader = "If-None-Match"
+)
+
+// etag returns the entity tag of a participant. The version changes on every save, so it's a strong tag.
+func etag(p *participant.Participant) string {
+	return `"` + strconv.Itoa(p.CurrentVersion()) + `"`
+}
+
+// etagMatches reports if tag is in the comma separated list of entity tags in header, or the header is "*".
+// If-Match uses strong comparison where weak tags never match. If-None-Match uses weak comparison.
+func etagMatches(header, tag string, weak bool) bool {
+	for _, t := range strings.Split(header, ",") {
+		t = strings.TrimSpace(t)
+
+		if t == "*" {
+			return true
+		}
+
+		if strings.HasPrefix(t, "W/") {
+			if !weak {
+				continue
+			}
+			t = strings.TrimPrefix(t, "W/")
+		}
+
+		if t == tag {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,27 @@
+package server
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestEtagMatches(t *testing.T) {
+	tests := []struct {
+		header  string
+		weak    bool
+		matches bool
+	}{
+		{`"1"`, false, true},
+		{`"2"`, false, false},
+		{`"2", "1"`, false, true},
+		{`"2","1"`, false, true},
+		{`*`, false, true},
+		{`W/"1"`, false, false},
+		{`W/"1"`, true, true},
+		{`1`, false, false},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.matches, etagMatches(test.header, `"1"`, test.weak), "header %s, weak %v", test.header, test.weak)
+	}
+}
@@ -0,0 +1,377 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/rejlersembriq/hooked/pkg/event"
+	"github.com/rejlersembriq/hooked/pkg/leaderboard"
+	"github.com/rejlersembriq/hooked/pkg/participant"
+	"github.com/rejlersembriq/hooked/pkg/router"
+	"go.uber.org/zap"
+	"net/http"
+	"time"
+)
+
+// eventsGET returns all events, see event.Sort for the order. Events past their end are returned as closed.
+func (s *Server) eventsGET() http.HandlerFunc {
+	return func(res http.ResponseWriter, req *http.Request) {
+		es, err := s.events.List(req.Context())
+		if err != nil {
+			zap.L().Error("Error retrieveing resources.", zap.String("error", err.Error()))
+			sendProblem(http.StatusInternalServerError, "Error retrieving resources").ServeHTTP(res, req)
+			return
+		}
+
+		now := time.Now()
+		for _, e := range es {
+			e.Status = e.StatusAt(now)
+		}
+
+		sendJSON(&es).ServeHTTP(res, req)
+	}
+}
+
+// eventPOST creates an event. The status defaults to draft.
+func (s *Server) eventPOST() http.HandlerFunc {
+	return func(res http.ResponseWriter, req *http.Request) {
+		defer req.Body.Close()
+
+		e, ok := decodeEvent(res, req)
+		if !ok {
+			return
+		}
+
+		if e.Status == "" {
+			e.Status = event.StatusDraft
+		}
+
+		if err := event.Validate(e); err != nil {
+			eventValidationProblem(err).ServeHTTP(res, req)
+			return
+		}
+
+		saved, err := s.events.Create(req.Context(), e)
+		if err != nil {
+			if errors.Is(err, event.ErrExists) {
+				sendProblem(http.StatusConflict, "Event already exists", participant.FieldError{Field: "slug", Message: "already in use"}).ServeHTTP(res, req)
+				return
+			}
+
+			zap.L().Error("Error persisting resource.", zap.String("error", err.Error()))
+			sendProblem(http.StatusInternalServerError, "Error persisting resource").ServeHTTP(res, req)
+			return
+		}
+
+		sendJSON(&saved).ServeHTTP(res, req)
+	}
+}
+
+// eventPUT replaces an event. The slug in the payload is optional, but must match the path if set. The status is kept
+// if not set, and can only be changed as allowed by event.Transition. Archived events can't be changed.
+func (s *Server) eventPUT() http.HandlerFunc {
+	return func(res http.ResponseWriter, req *http.Request) {
+		defer req.Body.Close()
+
+		slug, exists := router.GetParam(req.Context(), "slug")
+		if !exists {
+			sendProblem(http.StatusInternalServerError, "Unable to get request parameter").ServeHTTP(res, req)
+			return
+		}
+
+		e, ok := decodeEvent(res, req)
+		if !ok {
+			return
+		}
+
+		if e.Slug != "" && e.Slug != slug {
+			sendProblem(http.StatusUnprocessableEntity, "Invalid event", participant.FieldError{Field: "slug", Message: "must match the path"}).ServeHTTP(res, req)
+			return
+		}
+		e.Slug = slug
+
+		current, ok := s.getEvent(res, req, slug)
+		if !ok {
+			return
+		}
+
+		if current.Status == event.StatusArchived {
+			sendProblem(http.StatusConflict, "Event is archived").ServeHTTP(res, req)
+			return
+		}
+
+		if e.Status == "" {
+			e.Status = current.Status
+		}
+
+		if err := event.Validate(e); err != nil {
+			eventValidationProblem(err).ServeHTTP(res, req)
+			return
+		}
+
+		if err := event.Transition(current.Status, e.Status); err != nil {
+			sendProblem(http.StatusConflict, fmt.Sprintf("Event can't change status from %s to %s", current.Status, e.Status), participant.FieldError{Field: "status", Message: "invalid transition"}).ServeHTTP(res, req)
+			return
+		}
+		e.Overrides = current.Overrides
+
+		saved, err := s.events.Update(req.Context(), e)
+		if err != nil {
+			if errors.Is(err, event.ErrNotExist) {
+				sendProblem(http.StatusNotFound, "Resource not found").ServeHTTP(res, req)
+				return
+			}
+
+			zap.L().Error("Error persisting resource.", zap.String("error", err.Error()))
+			sendProblem(http.StatusInternalServerError, "Error persisting resource").ServeHTTP(res, req)
+			return
+		}
+
+		sendJSON(&saved).ServeHTTP(res, req)
+	}
+}
+
+func (s *Server) eventGET() http.HandlerFunc {
+	return func(res http.ResponseWriter, req *http.Request) {
+		slug, exists := router.GetParam(req.Context(), "slug")
+		if !exists {
+			sendProblem(http.StatusInternalServerError, "Unable to get request parameter").ServeHTTP(res, req)
+			return
+		}
+
+		e, ok := s.getEvent(res, req, slug)
+		if !ok {
+			return
+		}
+
+		sendJSON(&e).ServeHTTP(res, req)
+	}
+}
+
+// eventDELETE removes an event. Events with participants can't be deleted, as the participants would be left in an
+// event that doesn't exist. Only archived events can be deleted: nobody can register in them, and they can't be
+// changed, so no participant is added between the check and the delete, even if the check reads a stale index.
+func (s *Server) eventDELETE() http.HandlerFunc {
+	return func(res http.ResponseWriter, req *http.Request) {
+		slug, exists := router.GetParam(req.Context(), "slug")
+		if !exists {
+			sendProblem(http.StatusInternalServerError, "Unable to get request parameter").ServeHTTP(res, req)
+			return
+		}
+
+		e, ok := s.getEvent(res, req, slug)
+		if !ok {
+			return
+		}
+
+		if e.Status != event.StatusArchived {
+			sendProblem(http.StatusConflict, "Only archived events can be deleted. Event is "+string(e.StatusAt(time.Now()))).ServeHTTP(res, req)
+			return
+		}
+
+		page, err := s.participantRepo.Query(req.Context(), participant.Query{Event: &slug, Limit: 1})
+		if err != nil {
+			zap.L().Error("Error retrieveing resources.", zap.String("error", err.Error()))
+			sendProblem(http.StatusInternalServerError, "Error retrieving resources").ServeHTTP(res, req)
+			return
+		}
+
+		if len(page.Participants) > 0 {
+			sendProblem(http.StatusConflict, "Event has participants").ServeHTTP(res, req)
+			return
+		}
+
+		if err := s.events.Delete(req.Context(), slug); err != nil {
+			if errors.Is(err, event.ErrNotExist) {
+				sendProblem(http.StatusNotFound, "Resource not found").ServeHTTP(res, req)
+				return
+			}
+
+			zap.L().Error("Error deleting resource.", zap.String("slug", slug), zap.String("error", err.Error()))
+			sendProblem(http.StatusInternalServerError, "Error deleting resource").ServeHTTP(res, req)
+			return
+		}
+
+		sendString("Deleted").ServeHTTP(res, req)
+	}
+}
+
+// eventParticipantsGET returns a page of the participants in an event. Takes the same query parameters as
+// participantsGET, except event.
+func (s *Server) eventParticipantsGET() http.HandlerFunc {
+	return func(res http.ResponseWriter, req *http.Request) {
+		slug, exists := router.GetParam(req.Context(), "slug")
+		if !exists {
+			sendProblem(http.StatusInternalServerError, "Unable to get request parameter").ServeHTTP(res, req)
+			return
+		}
+
+		q, verr := parseQuery(req.URL.Query())
+		if verr != nil {
+			sendValidationProblem(http.StatusBadRequest, "Invalid query parameter", verr).ServeHTTP(res, req)
+			return
+		}
+		q.Event = &slug
+
+		if _, ok := s.getEvent(res, req, slug); !ok {
+			return
+		}
+
+		page, err := s.participantRepo.Query(req.Context(), q)
+		if err != nil {
+			if errors.Is(err, participant.ErrInvalidCursor) {
+				invalidParam("cursor", "invalid cursor").ServeHTTP(res, req)
+				return
+			}
+
+			zap.L().Error("Error retrieveing resources.", zap.String("error", err.Error()))
+			sendProblem(http.StatusInternalServerError, "Error retrieving resources").ServeHTTP(res, req)
+			return
+		}
+
+		sendJSON(page).ServeHTTP(res, req)
+	}
+}
+
+// eventLeaderboardGET returns the participants in an event ranked by score. Takes the same query parameters as
+// leaderboardGET.
+func (s *Server) eventLeaderboardGET() http.HandlerFunc {
+	return func(res http.ResponseWriter, req *http.Request) {
+		slug, exists := router.GetParam(req.Context(), "slug")
+		if !exists {
+			sendProblem(http.StatusInternalServerError, "Unable to get request parameter").ServeHTTP(res, req)
+			return
+		}
+
+		tie, err := leaderboard.ParseTieBreak(req.URL.Query().Get("ties"))
+		if err != nil {
+			invalidParam("ties", "must be one of: shared, earliest").ServeHTTP(res, req)
+			return
+		}
+
+		limit, err := parseLimit(req.URL.Query().Get("limit"))
+		if err != nil {
+			invalidParam("limit", "must be a positive integer").ServeHTTP(res, req)
+			return
+		}
+
+		if _, ok := s.getEvent(res, req, slug); !ok {
+			return
+		}
+
+		page, err := s.participantRepo.Query(req.Context(), participant.Query{Event: &slug})
+		if err != nil {
+			if errors.Is(err, participant.ErrQueryTooLarge) {
+				tooManyToRankProblem().ServeHTTP(res, req)
+				return
+			}
+
+			zap.L().Error("Error retrieveing resources.", zap.String("error", err.Error()))
+			sendProblem(http.StatusInternalServerError, "Error retrieving resources").ServeHTTP(res, req)
+			return
+		}
+
+		entries := leaderboard.Rank(page.Participants, tie, limit)
+
+		sendJSON(&entries).ServeHTTP(res, req)
+	}
+}
+
+// getEvent retrieves an event, responding with a problem if it fails. The status is the status at the current time,
+// see event.Event.StatusAt.
+func (s *Server) getEvent(res http.ResponseWriter, req *http.Request, slug string) (*event.Event, bool) {
+	e, err := s.events.Get(req.Context(), slug)
+	if err != nil {
+		if errors.Is(err, event.ErrNotExist) {
+			sendProblem(http.StatusNotFound, "Event "+slug+" not found").ServeHTTP(res, req)
+			return nil, false
+		}
+
+		zap.L().Error("Error retrieving resource.", zap.String("error", err.Error()))
+		sendProblem(http.StatusInternalServerError, "Error retrieving resource").ServeHTTP(res, req)
+		return nil, false
+	}
+
+	e.Status = e.StatusAt(time.Now())
+
+	return e, true
+}
+
+// checkEvent validates the event of a participant about to be created. Returns a field error if events aren't
+// configured or the event doesn't exist.
+func (s *Server) checkEvent(ctx context.Context, slug string) (*event.Event, *participant.ValidationError, error) {
+	verr := &participant.ValidationError{}
+
+	if s.events == nil {
+		addFieldError(verr, "event", "events are not enabled")
+		return nil, verr, nil
+	}
+
+	e, err := s.events.Get(ctx, slug)
+	if err != nil {
+		if errors.Is(err, event.ErrNotExist) {
+			addFieldError(verr, "event", "unknown event")
+			return nil, verr, nil
+		}
+		return nil, nil, err
+	}
+
+	return e, nil, nil
+}
+
+// scoresOpen reports if the score of p can be changed, responding with 409 Conflict if the event of p is closed or
+// archived. Participants without an event can always be changed.
+func (s *Server) scoresOpen(res http.ResponseWriter, req *http.Request, p *participant.Participant) bool {
+	if s.events == nil || p.Event == nil {
+		return true
+	}
+
+	e, err := s.events.Get(req.Context(), *p.Event)
+	if err != nil {
+		// Events with participants can't be deleted, so a missing event only happens for inconsistent data.
+		if errors.Is(err, event.ErrNotExist) {
+			return true
+		}
+
+		zap.L().Error("Error retrieving resource.", zap.String("error", err.Error()))
+		sendProblem(http.StatusInternalServerError, "Error retrieving resource").ServeHTTP(res, req)
+		return false
+	}
+
+	if now := time.Now(); e.ScoresLocked(now) {
+		scoresLockedProblem(e.Slug, e.StatusAt(now)).ServeHTTP(res, req)
+		return false
+	}
+
+	return true
+}
+
+// scoresLockedProblem responds with 409 Conflict when changing a score in a closed or archived event.
+func scoresLockedProblem(slug string, status event.Status) http.HandlerFunc {
+	return sendProblem(http.StatusConflict, fmt.Sprintf("Event %s is %s. Scores can't be changed.", slug, status))
+}
+
+// decodeEvent reads an event payload, responding with a problem if it is malformed.
+func decodeEvent(res http.ResponseWriter, req *http.Request) (event.Event, bool) {
+	var e event.Event
+
+	dec := json.NewDecoder(req.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&e); err != nil {
+		decodeProblem(err).ServeHTTP(res, req)
+		return e, false
+	}
+
+	return e, true
+}
+
+// eventValidationProblem responds to an error from event.Validate.
+func eventValidationProblem(err error) http.HandlerFunc {
+	var verr *participant.ValidationError
+	if errors.As(err, &verr) {
+		return sendValidationProblem(http.StatusUnprocessableEntity, "Invalid event", verr)
+	}
+
+	return sendProblem(http.StatusUnprocessableEntity, err.Error())
+}
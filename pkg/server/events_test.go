@@ -0,0 +1,444 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/rejlersembriq/hooked/pkg/apikey"
+	"github.com/rejlersembriq/hooked/pkg/attempt"
+	"github.com/rejlersembriq/hooked/pkg/event"
+	"github.com/rejlersembriq/hooked/pkg/leaderboard"
+	"github.com/rejlersembriq/hooked/pkg/participant"
+	"github.com/rejlersembriq/hooked/pkg/repository/memory"
+	"github.com/rejlersembriq/hooked/pkg/router"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func eventServer(t *testing.T) (*Server, *memory.Memory, *memory.EventRepository) {
+	repo := memory.New()
+	events := memory.NewEventRepository()
+
+	_, err := events.Create(context.Background(), event.Event{Slug: "conf-a", Name: "Conference A", Status: event.StatusOpen})
+	assert.NoError(t, err)
+
+	return New(router.New(), repo, WithEvents(events)), repo, events
+}
+
+func eventRequest(srvr *Server, method, path, payload string) *httptest.ResponseRecorder {
+	var body io.Reader
+	if payload != "" {
+		body = strings.NewReader(payload)
+	}
+
+	req, _ := http.NewRequest(method, path, body)
+	res := httptest.NewRecorder()
+
+	srvr.ServeHTTP(res, req)
+
+	return res
+}
+
+func saveInEvent(t *testing.T, repo participant.Repository, event, name string, score int) *participant.Participant {
+	p := participant.Participant{
+		Name:  aws.String(name),
+		Email: aws.String(strings.ToLower(name) + "@testson.com"),
+		Score: aws.Int(score),
+	}
+	if event != "" {
+		p.Event = aws.String(event)
+	}
+
+	saved, err := repo.Save(context.Background(), p)
+	assert.NoError(t, err)
+
+	return saved
+}
+
+func TestServer_ServeHTTP_POSTEvent(t *testing.T) {
+	srvr, _, events := eventServer(t)
+
+	res := eventRequest(srvr, http.MethodPost, "/event", `{"slug":"conf-b","name":"Conference B","start":"2020-05-01T09:00:00Z","end":"2020-05-02T17:00:00Z"}`)
+
+	assert.Equal(t, http.StatusOK, res.Code)
+
+	var e event.Event
+	assert.NoError(t, json.Unmarshal(res.Body.Bytes(), &e))
+	assert.Equal(t, "conf-b", e.Slug)
+	assert.Equal(t, event.StatusDraft, e.Status, "status should default to draft")
+	assert.NotNil(t, e.Created)
+
+	_, err := events.Get(context.Background(), "conf-b")
+	assert.NoError(t, err)
+}
+
+func TestServer_ServeHTTP_POSTEvent_Invalid(t *testing.T) {
+	srvr, _, _ := eventServer(t)
+
+	tests := []struct {
+		payload string
+		status  int
+	}{
+		{`{"slug":"conf-a","name":"Again"}`, http.StatusConflict},
+		{`{"slug":"Conf B","name":"Conference B"}`, http.StatusUnprocessableEntity},
+		{`{"slug":"conf-b","name":""}`, http.StatusUnprocessableEntity},
+		{`{"slug":"conf-b","name":"B","status":"running"}`, http.StatusUnprocessableEntity},
+		{`{"slug":"conf-b","name":"B","start":"2020-05-02T00:00:00Z","end":"2020-05-01T00:00:00Z"}`, http.StatusUnprocessableEntity},
+		{`{"slug":"conf-b","name":"B","venue":"Oslo"}`, http.StatusBadRequest},
+		{`{`, http.StatusBadRequest},
+	}
+
+	for _, test := range tests {
+		res := eventRequest(srvr, http.MethodPost, "/event", test.payload)
+		assert.Equal(t, test.status, res.Code, test.payload)
+		assert.Equal(t, problemContentType, res.Header().Get("Content-Type"), test.payload)
+	}
+}
+
+func TestServer_ServeHTTP_PUTEvent(t *testing.T) {
+	srvr, _, _ := eventServer(t)
+
+	res := eventRequest(srvr, http.MethodPut, "/event/conf-a", `{"name":"Renamed"}`)
+
+	assert.Equal(t, http.StatusOK, res.Code)
+
+	var e event.Event
+	assert.NoError(t, json.Unmarshal(res.Body.Bytes(), &e))
+	assert.Equal(t, "conf-a", e.Slug)
+	assert.Equal(t, "Renamed", e.Name)
+	assert.Equal(t, event.StatusOpen, e.Status, "status should be kept when not set")
+
+	res = eventRequest(srvr, http.MethodPut, "/event/conf-a", `{"slug":"conf-b","name":"Renamed"}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, res.Code)
+
+	res = eventRequest(srvr, http.MethodPut, "/event/nonExisting", `{"name":"Renamed"}`)
+	assert.Equal(t, http.StatusNotFound, res.Code)
+}
+
+func TestServer_ServeHTTP_GETEvents(t *testing.T) {
+	srvr, _, _ := eventServer(t)
+
+	eventRequest(srvr, http.MethodPost, "/event", `{"slug":"conf-b","name":"Conference B","start":"2020-05-01T09:00:00Z"}`)
+
+	res := eventRequest(srvr, http.MethodGet, "/events", "")
+
+	assert.Equal(t, http.StatusOK, res.Code)
+
+	var es []event.Event
+	assert.NoError(t, json.Unmarshal(res.Body.Bytes(), &es))
+	if assert.Len(t, es, 2) {
+		assert.Equal(t, "conf-b", es[0].Slug, "events with a start time should be first")
+		assert.Equal(t, "conf-a", es[1].Slug)
+	}
+
+	res = eventRequest(srvr, http.MethodGet, "/event/conf-b", "")
+	assert.Equal(t, http.StatusOK, res.Code)
+
+	res = eventRequest(srvr, http.MethodGet, "/event/nonExisting", "")
+	assert.Equal(t, http.StatusNotFound, res.Code)
+}
+
+func TestServer_ServeHTTP_DELETEEvent(t *testing.T) {
+	srvr, repo, events := eventServer(t)
+
+	p := saveInEvent(t, repo, "conf-a", "Anna", 1)
+	assert.NoError(t, repo.Delete(context.Background(), *p.ID))
+
+	res := eventRequest(srvr, http.MethodDelete, "/event/conf-a", "")
+	assert.Equal(t, http.StatusConflict, res.Code, "open events can't be deleted")
+
+	for _, status := range []string{"closed", "archived"} {
+		res = eventRequest(srvr, http.MethodPut, "/event/conf-a", `{"name":"Conference A","status":"`+status+`"}`)
+		assert.Equal(t, http.StatusOK, res.Code, status)
+	}
+
+	p = saveInEvent(t, repo, "conf-a", "Anna", 1)
+
+	res = eventRequest(srvr, http.MethodDelete, "/event/conf-a", "")
+	assert.Equal(t, http.StatusConflict, res.Code, "events with participants can't be deleted")
+
+	assert.NoError(t, repo.Delete(context.Background(), *p.ID))
+
+	res = eventRequest(srvr, http.MethodDelete, "/event/conf-a", "")
+	assert.Equal(t, http.StatusOK, res.Code)
+
+	_, err := events.Get(context.Background(), "conf-a")
+	assert.Equal(t, event.ErrNotExist, err)
+
+	res = eventRequest(srvr, http.MethodDelete, "/event/conf-a", "")
+	assert.Equal(t, http.StatusNotFound, res.Code)
+}
+
+func TestServer_ServeHTTP_POSTParticipant_Event(t *testing.T) {
+	srvr, repo, _ := eventServer(t)
+
+	res := eventRequest(srvr, http.MethodPost, "/participant", `{"name":"Test","email":"test@testson.com","event":"conf-a"}`)
+	assert.Equal(t, http.StatusOK, res.Code)
+
+	// The same email can register in another event, but not twice in the same.
+	res = eventRequest(srvr, http.MethodPost, "/participant", `{"name":"Test","email":"test@testson.com"}`)
+	assert.Equal(t, http.StatusOK, res.Code)
+
+	res = eventRequest(srvr, http.MethodPost, "/participant", `{"name":"Test","email":"test@testson.com","event":"conf-a"}`)
+	assert.Equal(t, http.StatusConflict, res.Code)
+
+	res = eventRequest(srvr, http.MethodPost, "/participant", `{"name":"Test","email":"other@testson.com","event":"conf-x"}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, res.Code)
+	assert.Contains(t, res.Body.String(), "unknown event")
+
+	page, err := repo.Query(context.Background(), participant.Query{Event: aws.String("conf-a")})
+	assert.NoError(t, err)
+	assert.Len(t, page.Participants, 1)
+}
+
+func TestServer_ServeHTTP_POSTParticipant_EventsDisabled(t *testing.T) {
+	srvr := New(router.New(), memory.New())
+
+	res := eventRequest(srvr, http.MethodPost, "/participant", `{"name":"Test","email":"test@testson.com","event":"conf-a"}`)
+
+	assert.Equal(t, http.StatusUnprocessableEntity, res.Code)
+	assert.Contains(t, res.Body.String(), "events are not enabled")
+
+	res = eventRequest(srvr, http.MethodGet, "/events", "")
+	assert.Equal(t, http.StatusNotFound, res.Code)
+}
+
+func TestServer_ServeHTTP_PUTParticipant_EventReadOnly(t *testing.T) {
+	srvr, repo, _ := eventServer(t)
+
+	p := saveInEvent(t, repo, "conf-a", "Anna", 1)
+
+	res := eventRequest(srvr, http.MethodPut, "/participant/"+*p.ID, `{"event":"conf-b"}`)
+
+	assert.Equal(t, http.StatusUnprocessableEntity, res.Code)
+}
+
+func TestServer_ServeHTTP_GETEventParticipants(t *testing.T) {
+	srvr, repo, _ := eventServer(t)
+
+	saveInEvent(t, repo, "conf-a", "Anna", 10)
+	saveInEvent(t, repo, "conf-a", "Bob", 30)
+	saveInEvent(t, repo, "", "Carl", 20)
+
+	res := eventRequest(srvr, http.MethodGet, "/event/conf-a/participants?sort=-score", "")
+
+	assert.Equal(t, http.StatusOK, res.Code)
+
+	var page participant.Page
+	assert.NoError(t, json.Unmarshal(res.Body.Bytes(), &page))
+	if assert.Len(t, page.Participants, 2) {
+		assert.Equal(t, "Bob", *page.Participants[0].Name)
+		assert.Equal(t, "Anna", *page.Participants[1].Name)
+	}
+
+	res = eventRequest(srvr, http.MethodGet, "/participants?event=conf-a", "")
+	var ps []*participant.Participant
+	assert.NoError(t, json.Unmarshal(res.Body.Bytes(), &ps))
+	assert.Len(t, ps, 2)
+
+	res = eventRequest(srvr, http.MethodGet, "/event/nonExisting/participants", "")
+	assert.Equal(t, http.StatusNotFound, res.Code)
+}
+
+func TestServer_ServeHTTP_GETEventLeaderboard(t *testing.T) {
+	srvr, repo, _ := eventServer(t)
+
+	saveInEvent(t, repo, "conf-a", "Anna", 10)
+	saveInEvent(t, repo, "conf-a", "Bob", 30)
+	saveInEvent(t, repo, "", "Carl", 20)
+
+	res := eventRequest(srvr, http.MethodGet, "/event/conf-a/leaderboard?limit=1", "")
+
+	assert.Equal(t, http.StatusOK, res.Code)
+
+	var entries []leaderboard.Entry
+	assert.NoError(t, json.Unmarshal(res.Body.Bytes(), &entries))
+	if assert.Len(t, entries, 1) {
+		assert.Equal(t, "Bob", *entries[0].Participant.Name)
+	}
+
+	res = eventRequest(srvr, http.MethodGet, "/event/nonExisting/leaderboard", "")
+	assert.Equal(t, http.StatusNotFound, res.Code)
+}
+
+func TestServer_ServeHTTP_Merge_DifferentEvents(t *testing.T) {
+	srvr, repo, _ := eventServer(t)
+
+	keep := saveInEvent(t, repo, "conf-a", "Anna", 10)
+	remove := saveInEvent(t, repo, "", "Anna", 20)
+
+	res := mergeRequest(srvr, `{"keep":"`+*keep.ID+`","remove":"`+*remove.ID+`"}`)
+
+	assert.Equal(t, http.StatusUnprocessableEntity, res.Code)
+
+	_, err := repo.Get(context.Background(), *remove.ID)
+	assert.NoError(t, err)
+}
+
+// createEvent stores an event directly, bypassing the lifecycle checks of the api.
+func createEvent(t *testing.T, events event.Repository, e event.Event) {
+	_, err := events.Create(context.Background(), e)
+	assert.NoError(t, err)
+}
+
+func TestServer_ServeHTTP_PUTEvent_Transitions(t *testing.T) {
+	srvr, _, _ := eventServer(t)
+
+	tests := []struct {
+		status string
+		code   int
+	}{
+		{"draft", http.StatusConflict},
+		{"closed", http.StatusOK},
+		{"open", http.StatusConflict},
+		{"archived", http.StatusOK},
+		{"archived", http.StatusConflict},
+	}
+
+	for _, test := range tests {
+		res := eventRequest(srvr, http.MethodPut, "/event/conf-a", `{"name":"Conference A","status":"`+test.status+`"}`)
+		assert.Equal(t, test.code, res.Code, test.status)
+	}
+}
+
+func TestServer_ServeHTTP_ClosedEvent_ScoresLocked(t *testing.T) {
+	srvr, repo, events := eventServer(t)
+	srvr = New(router.New(), repo, WithEvents(events), WithAttempts(memory.NewAttemptRepository(), attempt.Best))
+
+	p := saveInEvent(t, repo, "conf-a", "Anna", 10)
+	other := saveInEvent(t, repo, "conf-a", "Annie", 20)
+	outside := saveInEvent(t, repo, "", "Carl", 10)
+
+	res := eventRequest(srvr, http.MethodPut, "/event/conf-a", `{"name":"Conference A","status":"closed"}`)
+	assert.Equal(t, http.StatusOK, res.Code)
+
+	tests := []struct {
+		name    string
+		method  string
+		path    string
+		payload string
+		code    int
+	}{
+		{"score", http.MethodPut, "/participant/" + *p.ID, `{"score":50}`, http.StatusConflict},
+		{"name", http.MethodPut, "/participant/" + *p.ID, `{"name":"Anna A"}`, http.StatusOK},
+		{"attempt", http.MethodPost, "/participant/" + *p.ID + "/attempts", `{"score":50}`, http.StatusConflict},
+		{"create", http.MethodPost, "/participant", `{"name":"New","email":"new@testson.com","event":"conf-a"}`, http.StatusConflict},
+		{"merge", http.MethodPost, "/admin/merge", `{"keep":"` + *p.ID + `","remove":"` + *other.ID + `"}`, http.StatusConflict},
+		{"merge dry run", http.MethodPost, "/admin/merge", `{"keep":"` + *p.ID + `","remove":"` + *other.ID + `","dryRun":true}`, http.StatusOK},
+		{"without event", http.MethodPost, "/participant/" + *outside.ID + "/attempts", `{"score":50}`, http.StatusOK},
+		{"delete", http.MethodDelete, "/participant/" + *other.ID, "", http.StatusConflict},
+		{"delete without event", http.MethodDelete, "/participant/" + *outside.ID, "", http.StatusOK},
+	}
+
+	for _, test := range tests {
+		res := eventRequest(srvr, test.method, test.path, test.payload)
+		assert.Equal(t, test.code, res.Code, test.name)
+	}
+
+	got, err := repo.Get(context.Background(), *p.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, 10, *got.Score)
+
+	_, err = repo.Get(context.Background(), *other.ID)
+	assert.NoError(t, err, "participant in a closed event should not be deleted")
+}
+
+func TestServer_ServeHTTP_EndedEvent_ClosedAutomatically(t *testing.T) {
+	srvr, repo, events := eventServer(t)
+
+	ended := time.Now().Add(-time.Minute)
+	createEvent(t, events, event.Event{Slug: "conf-b", Name: "Conference B", End: &ended, Status: event.StatusOpen})
+	p := saveInEvent(t, repo, "conf-b", "Anna", 10)
+
+	res := eventRequest(srvr, http.MethodGet, "/event/conf-b", "")
+
+	var e event.Event
+	assert.NoError(t, json.Unmarshal(res.Body.Bytes(), &e))
+	assert.Equal(t, event.StatusClosed, e.Status)
+
+	res = eventRequest(srvr, http.MethodPut, "/participant/"+*p.ID, `{"score":50}`)
+	assert.Equal(t, http.StatusConflict, res.Code)
+}
+
+func TestServer_ServeHTTP_ReopenEvent(t *testing.T) {
+	srvr, repo, events := eventServer(t)
+
+	ended := time.Now().Add(-time.Minute)
+	createEvent(t, events, event.Event{Slug: "conf-b", Name: "Conference B", End: &ended, Status: event.StatusOpen})
+	p := saveInEvent(t, repo, "conf-b", "Anna", 10)
+
+	end := time.Now().Add(time.Hour).UTC().Truncate(time.Second).Format(time.RFC3339)
+
+	tests := []struct {
+		slug    string
+		payload string
+		code    int
+	}{
+		{"conf-b", `{"end":"` + end + `"}`, http.StatusUnprocessableEntity},
+		{"conf-b", `{"reason":"Wrong score"}`, http.StatusUnprocessableEntity},
+		{"conf-b", `{"reason":"Wrong score","until":"` + end + `"}`, http.StatusBadRequest},
+		{"conf-a", `{"reason":"Wrong score"}`, http.StatusConflict},
+		{"nonExisting", `{"reason":"Wrong score"}`, http.StatusNotFound},
+	}
+
+	for _, test := range tests {
+		res := eventRequest(srvr, http.MethodPost, "/admin/event/"+test.slug+"/reopen", test.payload)
+		assert.Equal(t, test.code, res.Code, test.payload)
+	}
+
+	req, _ := http.NewRequest(http.MethodPost, "/admin/event/conf-b/reopen", strings.NewReader(`{"reason":"Wrong score","end":"`+end+`"}`))
+	req.Header.Set(requestIDHeader, "req-1")
+	res := httptest.NewRecorder()
+
+	srvr.ServeHTTP(res, req)
+
+	assert.Equal(t, http.StatusOK, res.Code)
+
+	var e event.Event
+	assert.NoError(t, json.Unmarshal(res.Body.Bytes(), &e))
+	assert.Equal(t, event.StatusOpen, e.Status)
+	if assert.Len(t, e.Overrides, 1) {
+		assert.Equal(t, event.OverrideReopen, e.Overrides[0].Action)
+		assert.Equal(t, event.StatusClosed, e.Overrides[0].From)
+		assert.Equal(t, "Wrong score", e.Overrides[0].Reason)
+		assert.Equal(t, "req-1", e.Overrides[0].RequestID)
+	}
+
+	res = eventRequest(srvr, http.MethodPut, "/participant/"+*p.ID, `{"score":50}`)
+	assert.Equal(t, http.StatusOK, res.Code)
+
+	// Overrides are kept when the event is replaced.
+	res = eventRequest(srvr, http.MethodPut, "/event/conf-b", `{"name":"Renamed","end":"`+end+`"}`)
+	assert.Equal(t, http.StatusOK, res.Code)
+
+	got, err := events.Get(context.Background(), "conf-b")
+	assert.NoError(t, err)
+	assert.Len(t, got.Overrides, 1)
+}
+
+func TestServer_ServeHTTP_ReopenEvent_By(t *testing.T) {
+	events := memory.NewEventRepository()
+	ended := time.Now().Add(-time.Minute)
+	createEvent(t, events, event.Event{Slug: "conf-b", Name: "Conference B", End: &ended, Status: event.StatusClosed})
+
+	keys := memory.NewAPIKeyRepository()
+	token := createKey(t, keys, apikey.ScopeAdmin)
+	id, _, _ := apikey.Parse(token)
+
+	srvr := New(router.New(), memory.New(), WithEvents(events), WithAPIKeys(keys, testAdminKey))
+
+	end := time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+	res := keyRequest(srvr, http.MethodPost, "/admin/event/conf-b/reopen", `{"reason":"Wrong score","end":"`+end+`"}`, token)
+	assert.Equal(t, http.StatusOK, res.Code)
+
+	var e event.Event
+	assert.NoError(t, json.Unmarshal(res.Body.Bytes(), &e))
+	if assert.Len(t, e.Overrides, 1) {
+		assert.Equal(t, id, e.Overrides[0].By)
+	}
+}
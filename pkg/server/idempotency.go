@@ -0,0 +1,192 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/rejlersembriq/hooked/pkg/auth"
+	"github.com/rejlersembriq/hooked/pkg/idempotency"
+	"go.uber.org/zap"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	idempotencyKeyHeader     = "Idempotency-Key"
+	idempotentReplayedHeader = "Idempotent-Replayed"
+	idempotencyKeyMaxLength  = 255
+)
+
+const (
+	// A reservation expires after idempotencyLease unless completed, so a key abandoned by a request that never
+	// finished, eg. a crashed lambda, can be reserved again. Must outlast the slowest request.
+	idempotencyLease = time.Minute
+	// Completing or releasing a key is given idempotencyStoreTimeout, independent of the request which might have been
+	// cancelled by the client.
+	idempotencyStoreTimeout = 5 * time.Second
+)
+
+// idempotent stores the response of h for requests with an Idempotency-Key header, and replays it for retries with
+// the same key and payload. Does nothing if the server has no idempotency store, or the header is missing.
+// Responses with a server error status are not stored, so the request can be retried.
+func (s *Server) idempotent(h http.HandlerFunc) http.HandlerFunc {
+	return func(res http.ResponseWriter, req *http.Request) {
+		key := req.Header.Get(idempotencyKeyHeader)
+		if s.idempotency == nil || key == "" {
+			h.ServeHTTP(res, req)
+			return
+		}
+
+		if len(key) > idempotencyKeyMaxLength {
+			sendProblem(http.StatusBadRequest, fmt.Sprintf("%s too long. Max %d characters.", idempotencyKeyHeader, idempotencyKeyMaxLength)).ServeHTTP(res, req)
+			return
+		}
+
+		body, err := ioutil.ReadAll(req.Body)
+		if err != nil {
+			decodeProblem(err).ServeHTTP(res, req)
+			return
+		}
+		req.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+		// Keys are scoped to the route and the caller, so a client can't replay a response from another endpoint, or
+		// one sent to another caller using the same key.
+		scope := req.Method + " " + req.URL.Path
+		if caller := idempotencyCaller(req); caller != "" {
+			scope += " " + caller
+		}
+		key = scope + ":" + key
+		fingerprint := idempotency.Fingerprint(body)
+
+		lease := idempotencyLease
+		if s.idempotencyWindow < lease {
+			lease = s.idempotencyWindow
+		}
+
+		existing, err := s.idempotency.Reserve(req.Context(), idempotency.Record{
+			Key:         key,
+			Fingerprint: fingerprint,
+			Expires:     time.Now().Add(lease),
+		})
+		if err != nil {
+			zap.L().Error("Error reserving idempotency key.", zap.String("error", err.Error()))
+			sendProblem(http.StatusInternalServerError, "Error reserving idempotency key").ServeHTTP(res, req)
+			return
+		}
+
+		if existing != nil {
+			switch {
+			case existing.Fingerprint != fingerprint:
+				sendProblem(http.StatusUnprocessableEntity, fmt.Sprintf("%s already used with a different payload", idempotencyKeyHeader)).ServeHTTP(res, req)
+			case existing.Response == nil:
+				sendProblem(http.StatusConflict, fmt.Sprintf("A request with this %s is in progress", idempotencyKeyHeader)).ServeHTTP(res, req)
+			default:
+				replay(*existing.Response).ServeHTTP(res, req)
+			}
+			return
+		}
+
+		rec := newResponseRecorder(res)
+		h.ServeHTTP(rec, req)
+
+		ctx, cancel := context.WithTimeout(context.Background(), idempotencyStoreTimeout)
+		defer cancel()
+
+		if rec.status >= http.StatusInternalServerError {
+			if err := s.idempotency.Release(ctx, key); err != nil {
+				zap.L().Error("Error releasing idempotency key.", zap.String("error", err.Error()))
+			}
+			return
+		}
+
+		if err := s.idempotency.Complete(ctx, key, rec.response(), time.Now().Add(s.idempotencyWindow)); err != nil {
+			zap.L().Error("Error storing idempotent response.", zap.String("error", err.Error()))
+		}
+	}
+}
+
+// replay responds with a stored response.
+func replay(stored idempotency.Response) http.HandlerFunc {
+	return func(res http.ResponseWriter, req *http.Request) {
+		for k, v := range stored.Header {
+			res.Header().Set(k, v)
+		}
+		res.Header().Set(idempotentReplayedHeader, "true")
+
+		res.WriteHeader(stored.Status)
+		if _, err := res.Write(stored.Body); err != nil {
+			zap.L().Error("Error sending replayed response.", zap.String("error", err.Error()))
+		}
+	}
+}
+
+// responseRecorder writes through to the client while keeping the status, body and headers set by the handler.
+// Headers set before the handler ran, like the request id, belong to the request and aren't recorded.
+type responseRecorder struct {
+	http.ResponseWriter
+	before map[string]string
+	header map[string]string
+	status int
+	body   bytes.Buffer
+}
+
+func newResponseRecorder(res http.ResponseWriter) *responseRecorder {
+	before := make(map[string]string, len(res.Header()))
+	for k := range res.Header() {
+		before[k] = res.Header().Get(k)
+	}
+
+	return &responseRecorder{
+		ResponseWriter: res,
+		before:         before,
+	}
+}
+
+func (r *responseRecorder) WriteHeader(status int) {
+	if r.status != 0 {
+		return
+	}
+	r.status = status
+
+	r.header = make(map[string]string)
+	for k := range r.Header() {
+		if v := r.Header().Get(k); v != r.before[k] {
+			r.header[k] = v
+		}
+	}
+
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *responseRecorder) Write(p []byte) (int, error) {
+	if r.status == 0 {
+		r.WriteHeader(http.StatusOK)
+	}
+
+	r.body.Write(p)
+	return r.ResponseWriter.Write(p)
+}
+
+func (r *responseRecorder) response() idempotency.Response {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+
+	return idempotency.Response{
+		Status: r.status,
+		Header: r.header,
+		Body:   r.body.Bytes(),
+	}
+}
+
+// idempotencyCaller identifies the caller the idempotency keys of req are scoped to: the method and subject of its
+// principal, quoted so a subject can't be made to look like another. Empty when authentication isn't enabled.
+func idempotencyCaller(req *http.Request) string {
+	p, ok := auth.FromContext(req.Context())
+	if !ok {
+		return ""
+	}
+	return strconv.Quote(p.Method + " " + p.Subject)
+}
@@ -0,0 +1,205 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/rejlersembriq/hooked/pkg/apikey"
+	"github.com/rejlersembriq/hooked/pkg/idempotency"
+	"github.com/rejlersembriq/hooked/pkg/participant"
+	"github.com/rejlersembriq/hooked/pkg/repository/memory"
+	"github.com/rejlersembriq/hooked/pkg/router"
+	"github.com/rejlersembriq/hooked/test"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+const idempotentPayload = `{"name":"Test Testson","email":"test@testson.com","score":2}`
+
+func idempotentPOST(srvr *Server, key, payload string) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest(http.MethodPost, "/participant", strings.NewReader(payload))
+	req.Header.Set(idempotencyKeyHeader, key)
+	res := httptest.NewRecorder()
+
+	srvr.ServeHTTP(res, req)
+
+	return res
+}
+
+func TestServer_ServeHTTP_POSTParticipant_IdempotencyKey(t *testing.T) {
+	repo := memory.New()
+	srvr := New(router.New(), repo, WithIdempotency(memory.NewIdempotencyStore(), time.Hour))
+
+	first := idempotentPOST(srvr, "someKey", idempotentPayload)
+	assert.Equal(t, http.StatusOK, first.Code)
+	assert.Empty(t, first.Header().Get(idempotentReplayedHeader))
+
+	retry := idempotentPOST(srvr, "someKey", idempotentPayload)
+	assert.Equal(t, http.StatusOK, retry.Code)
+	assert.Equal(t, "true", retry.Header().Get(idempotentReplayedHeader))
+	assert.Equal(t, first.Header().Get("Content-Type"), retry.Header().Get("Content-Type"))
+	assert.Equal(t, first.Header().Get(etagHeader), retry.Header().Get(etagHeader))
+	assert.Equal(t, first.Body.String(), retry.Body.String())
+	assert.NotEqual(t, first.Header().Get(requestIDHeader), retry.Header().Get(requestIDHeader))
+
+	ps, err := repo.GetAll(context.Background())
+	assert.NoError(t, err)
+	assert.Len(t, ps, 1, "retry should not create another participant")
+
+	other := idempotentPOST(srvr, "otherKey", `{"name":"Other","email":"other@testson.com","score":3}`)
+	assert.Equal(t, http.StatusOK, other.Code)
+
+	var p1, p2 participant.Participant
+	assert.NoError(t, json.Unmarshal(first.Body.Bytes(), &p1))
+	assert.NoError(t, json.Unmarshal(other.Body.Bytes(), &p2))
+	assert.NotEqual(t, *p1.ID, *p2.ID)
+}
+
+func TestServer_ServeHTTP_POSTParticipant_IdempotencyKeyPerCaller(t *testing.T) {
+	repo := memory.New()
+	keys := memory.NewAPIKeyRepository()
+	srvr := New(router.New(), repo, WithIdempotency(memory.NewIdempotencyStore(), time.Hour), WithAPIKeys(keys, testAdminKey))
+
+	first := createKey(t, keys, apikey.ScopeWriteScore)
+	second := createKey(t, keys, apikey.ScopeAdmin)
+
+	post := func(token, payload string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(http.MethodPost, "/participant", strings.NewReader(payload))
+		req.Header.Set(idempotencyKeyHeader, "someKey")
+		req.Header.Set(apiKeyHeader, token)
+		res := httptest.NewRecorder()
+		srvr.ServeHTTP(res, req)
+		return res
+	}
+
+	res := post(first, idempotentPayload)
+	assert.Equal(t, http.StatusOK, res.Code)
+
+	res = post(second, `{"name":"Other","email":"other@testson.com","score":3}`)
+	assert.Equal(t, http.StatusOK, res.Code, "another caller's key should not conflict")
+	assert.Empty(t, res.Header().Get(idempotentReplayedHeader))
+
+	res = post(first, idempotentPayload)
+	assert.Equal(t, "true", res.Header().Get(idempotentReplayedHeader))
+
+	ps, err := repo.GetAll(context.Background())
+	assert.NoError(t, err)
+	assert.Len(t, ps, 2)
+}
+
+func TestServer_ServeHTTP_POSTParticipant_IdempotencyKeyDifferentPayload(t *testing.T) {
+	srvr := New(router.New(), memory.New(), WithIdempotency(memory.NewIdempotencyStore(), time.Hour))
+
+	res := idempotentPOST(srvr, "someKey", idempotentPayload)
+	assert.Equal(t, http.StatusOK, res.Code)
+
+	res = idempotentPOST(srvr, "someKey", `{"name":"Other","email":"other@testson.com","score":3}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, res.Code)
+	assert.Equal(t, problemContentType, res.Header().Get("Content-Type"))
+}
+
+func TestServer_ServeHTTP_POSTParticipant_IdempotencyKeyInProgress(t *testing.T) {
+	store := memory.NewIdempotencyStore()
+	srvr := New(router.New(), memory.New(), WithIdempotency(store, time.Hour))
+
+	_, err := store.Reserve(context.Background(), idempotency.Record{
+		Key:         "POST /participant:someKey",
+		Fingerprint: idempotency.Fingerprint([]byte(idempotentPayload)),
+		Expires:     time.Now().Add(time.Hour),
+	})
+	assert.NoError(t, err)
+
+	res := idempotentPOST(srvr, "someKey", idempotentPayload)
+	assert.Equal(t, http.StatusConflict, res.Code)
+}
+
+func TestServer_ServeHTTP_POSTParticipant_IdempotencyKeyServerError(t *testing.T) {
+	fail := true
+	mock := &test.RepoMock{
+		SaveHandler: func(ctx context.Context, p participant.Participant) (*participant.Participant, participant.Error) {
+			if fail {
+				return nil, errors.New("SomeError")
+			}
+			p.ID = aws.String("someId")
+			return &p, nil
+		},
+	}
+	srvr := New(router.New(), mock, WithIdempotency(memory.NewIdempotencyStore(), time.Hour))
+
+	res := idempotentPOST(srvr, "someKey", idempotentPayload)
+	assert.Equal(t, http.StatusInternalServerError, res.Code)
+
+	fail = false
+	res = idempotentPOST(srvr, "someKey", idempotentPayload)
+	assert.Equal(t, http.StatusOK, res.Code, "failed request should be retried")
+	assert.Empty(t, res.Header().Get(idempotentReplayedHeader))
+}
+
+func TestServer_ServeHTTP_POSTParticipant_IdempotencyKeyCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancelled := false
+	mock := &test.RepoMock{
+		SaveHandler: func(ctx context.Context, p participant.Participant) (*participant.Participant, participant.Error) {
+			if !cancelled {
+				cancelled = true
+				cancel()
+				return nil, ctx.Err()
+			}
+			p.ID = aws.String("someId")
+			return &p, nil
+		},
+	}
+	srvr := New(router.New(), mock, WithIdempotency(memory.NewIdempotencyStore(), time.Hour))
+
+	req, _ := http.NewRequest(http.MethodPost, "/participant", strings.NewReader(idempotentPayload))
+	req = req.WithContext(ctx)
+	req.Header.Set(idempotencyKeyHeader, "someKey")
+	res := httptest.NewRecorder()
+	srvr.ServeHTTP(res, req)
+	assert.Equal(t, http.StatusInternalServerError, res.Code)
+
+	res = idempotentPOST(srvr, "someKey", idempotentPayload)
+	assert.Equal(t, http.StatusOK, res.Code, "key should be released when the client disconnects")
+	assert.Empty(t, res.Header().Get(idempotentReplayedHeader))
+}
+
+func TestServer_ServeHTTP_POSTParticipant_IdempotencyKeyLeaseExpired(t *testing.T) {
+	store := memory.NewIdempotencyStore()
+	srvr := New(router.New(), memory.New(), WithIdempotency(store, time.Hour))
+
+	_, err := store.Reserve(context.Background(), idempotency.Record{
+		Key:         "POST /participant:someKey",
+		Fingerprint: idempotency.Fingerprint([]byte(idempotentPayload)),
+		Expires:     time.Now().Add(-time.Second),
+	})
+	assert.NoError(t, err)
+
+	res := idempotentPOST(srvr, "someKey", idempotentPayload)
+	assert.Equal(t, http.StatusOK, res.Code, "abandoned reservation should be taken again")
+
+	res = idempotentPOST(srvr, "someKey", idempotentPayload)
+	assert.Equal(t, http.StatusOK, res.Code)
+	assert.Equal(t, "true", res.Header().Get(idempotentReplayedHeader), "response should be kept for the window, not the lease")
+}
+
+func TestServer_ServeHTTP_POSTParticipant_IdempotencyKeyTooLong(t *testing.T) {
+	srvr := New(router.New(), memory.New(), WithIdempotency(memory.NewIdempotencyStore(), time.Hour))
+
+	res := idempotentPOST(srvr, strings.Repeat("k", idempotencyKeyMaxLength+1), idempotentPayload)
+	assert.Equal(t, http.StatusBadRequest, res.Code)
+}
+
+func TestServer_ServeHTTP_POSTParticipant_IdempotencyDisabled(t *testing.T) {
+	repo := memory.New()
+	srvr := New(router.New(), repo)
+
+	idempotentPOST(srvr, "someKey", idempotentPayload)
+	res := idempotentPOST(srvr, "someKey", idempotentPayload)
+	assert.Equal(t, http.StatusConflict, res.Code, "retry should be handled as a new request")
+	assert.Empty(t, res.Header().Get(idempotentReplayedHeader))
+}
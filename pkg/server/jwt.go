@@ -0,0 +1,82 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"github.com/rejlersembriq/hooked/pkg/auth"
+	"github.com/rejlersembriq/hooked/pkg/jwt"
+)
+
+// DefaultJWTRolesClaim is the claim holding the roles of a JWT unless JWTConfig.RolesClaim is set.
+const DefaultJWTRolesClaim = "roles"
+
+// ErrJWTAudienceRequired is returned by JWTOption for a key set without an audience. An identity provider signs the
+// tokens of all its applications with the same keys, so without checking the audience, a token issued for any of them
+// would be accepted.
+var ErrJWTAudienceRequired = errors.New("an audience is required with a key set")
+
+// JWTConfig configures the JWTs accepted by the server, as read from the environment by the binaries. Empty values are
+// unset.
+type JWTConfig struct {
+	// Secret verifies HS256 tokens.
+	Secret string
+	// KeySetFile is the path of a JSON Web Key Set verifying RS256 tokens. Requires Audience.
+	KeySetFile string
+	// Issuer and Audience are required "iss" and "aud" claims.
+	Issuer   string
+	Audience string
+	// RolesClaim holds the roles of the caller, DefaultJWTRolesClaim if not set.
+	RolesClaim string
+	// RoleMap maps values of the roles claim to roles, see auth.ParseMapping.
+	RoleMap string
+}
+
+// Enabled reports if JWTs are accepted, that is if a secret or key set is configured.
+func (c JWTConfig) Enabled() bool {
+	return c.Secret != "" || c.KeySetFile != ""
+}
+
+// JWTOption returns the option accepting JWTs as configured by c, see WithJWT. Returns nil if JWTs aren't enabled, and
+// ErrJWTAudienceRequired for a key set without an audience.
+func JWTOption(c JWTConfig) (Option, error) {
+	if !c.Enabled() {
+		return nil, nil
+	}
+
+	var opts []jwt.Option
+	if c.Secret != "" {
+		opts = append(opts, jwt.WithSecret([]byte(c.Secret)))
+	}
+
+	if c.KeySetFile != "" {
+		if c.Audience == "" {
+			return nil, ErrJWTAudienceRequired
+		}
+
+		keys, err := jwt.LoadJWKS(c.KeySetFile)
+		if err != nil {
+			return nil, fmt.Errorf("invalid key set %q: %w", c.KeySetFile, err)
+		}
+		opts = append(opts, jwt.WithKeys(keys))
+	}
+
+	if c.Issuer != "" {
+		opts = append(opts, jwt.WithIssuer(c.Issuer))
+	}
+
+	if c.Audience != "" {
+		opts = append(opts, jwt.WithAudience(c.Audience))
+	}
+
+	mapping, err := auth.ParseMapping(c.RoleMap)
+	if err != nil {
+		return nil, fmt.Errorf("invalid role map: %w", err)
+	}
+
+	claim := c.RolesClaim
+	if claim == "" {
+		claim = DefaultJWTRolesClaim
+	}
+
+	return WithJWT(jwt.NewVerifier(opts...), auth.RoleMapper{Claim: claim, Mapping: mapping}), nil
+}
@@ -0,0 +1,22 @@
+package server
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestJWTOption(t *testing.T) {
+	opt, err := JWTOption(JWTConfig{Issuer: "issuer", Audience: "hooked"})
+	assert.NoError(t, err)
+	assert.Nil(t, opt, "JWTs accepted without a secret or key set")
+
+	opt, err = JWTOption(JWTConfig{Secret: "secret"})
+	assert.NoError(t, err)
+	assert.NotNil(t, opt)
+
+	_, err = JWTOption(JWTConfig{KeySetFile: "jwks.json"})
+	assert.Equal(t, ErrJWTAudienceRequired, err)
+
+	_, err = JWTOption(JWTConfig{Secret: "secret", RoleMap: "invalid"})
+	assert.Error(t, err)
+}
@@ -0,0 +1,154 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/rejlersembriq/hooked/pkg/participant"
+	"go.uber.org/zap"
+	"io"
+	"net/http"
+	"net/url"
+)
+
+const problemContentType = "application/problem+json"
+
+const locationHeader = "Location"
+
+// requestIDHeader is read from incoming requests and set on all responses.
+const requestIDHeader = "X-Request-ID"
+
+// errBodyTooLarge is returned when reading more than reqMaxBytes from a request body.
+var errBodyTooLarge = errors.New("request body too large")
+
+// Problem is an RFC 7807 problem details response body. Type is always about:blank, so Title is the status text and
+// Detail explains this occurrence. Errors lists the invalid fields or query parameters when relevant.
+type Problem struct {
+	Type      string                   `json:"type"`
+	Title     string                   `json:"title"`
+	Status    int                      `json:"status"`
+	Detail    string                   `json:"detail,omitempty"`
+	Instance  string                   `json:"instance,omitempty"`
+	RequestID string                   `json:"requestId,omitempty"`
+	Errors    []participant.FieldError `json:"errors,omitempty"`
+}
+
+// sendProblem responds with a problem+json body.
+func sendProblem(status int, detail string, fieldErrors ...participant.FieldError) http.HandlerFunc {
+	return func(res http.ResponseWriter, req *http.Request) {
+		id, _ := getRequestID(req.Context())
+
+		p := Problem{
+			Type:      "about:blank",
+			Title:     http.StatusText(status),
+			Status:    status,
+			Detail:    detail,
+			Instance:  req.URL.Path,
+			RequestID: id,
+			Errors:    fieldErrors,
+		}
+
+		res.Header().Set("Content-Type", problemContentType)
+		res.WriteHeader(status)
+		if err := json.NewEncoder(res).Encode(&p); err != nil {
+			zap.L().Error("Error marshalling problem response.", zap.String("error", err.Error()))
+		}
+	}
+}
+
+// sendValidationProblem responds with the field errors in verr.
+func sendValidationProblem(status int, detail string, verr *participant.ValidationError) http.HandlerFunc {
+	return sendProblem(status, detail, verr.Fields...)
+}
+
+// invalidParam responds with 400 Bad Request for a single invalid query parameter.
+func invalidParam(name, msg string) http.HandlerFunc {
+	return sendProblem(http.StatusBadRequest, "Invalid query parameter", participant.FieldError{Field: name, Message: msg})
+}
+
+// decodeProblem responds to an error from decoding a request payload.
+func decodeProblem(err error) http.HandlerFunc {
+	var verr *participant.ValidationError
+
+	switch {
+	case errors.Is(err, errBodyTooLarge):
+		return sendProblem(http.StatusRequestEntityTooLarge, fmt.Sprintf("Request payload too large. Max %d bytes.", reqMaxBytes))
+	case errors.As(err, &verr):
+		return sendValidationProblem(http.StatusBadRequest, "Request payload contains unknown fields", verr)
+	default:
+		return sendProblem(http.StatusBadRequest, "Malformed JSON payload")
+	}
+}
+
+// emailTakenProblem responds with 409 Conflict when the email of a participant is registered to another participant.
+// The Location header links to the existing participant.
+func emailTakenProblem(err *participant.EmailTakenError) http.HandlerFunc {
+	return func(res http.ResponseWriter, req *http.Request) {
+		res.Header().Set(locationHeader, "/participant/"+url.PathEscape(err.ID))
+		sendProblem(http.StatusConflict, "Email already registered", participant.FieldError{Field: "email", Message: "already registered"}).ServeHTTP(res, req)
+	}
+}
+
+// requestIDKey type for adding the request id to context without risking collision.
+type requestIDKey struct{}
+
+// withRequestID uses the request id provided by the client or generates a new one. It is added to the request
+// context and echoed in the response header.
+func withRequestID(res http.ResponseWriter, req *http.Request) *http.Request {
+	id := req.Header.Get(requestIDHeader)
+	if id == "" || len(id) > 128 {
+		id = uuid.New().String()
+	}
+
+	res.Header().Set(requestIDHeader, id)
+
+	return req.WithContext(context.WithValue(req.Context(), requestIDKey{}, id))
+}
+
+// getRequestID gets the request id from context.
+func getRequestID(ctx context.Context) (string, bool) {
+	id, ok := ctx.Value(requestIDKey{}).(string)
+	return id, ok
+}
+
+// limitedBody stops reading from the request body after limit bytes and returns errBodyTooLarge.
+type limitedBody struct {
+	io.ReadCloser
+	remaining int64
+}
+
+func newLimitedBody(body io.ReadCloser, limit int64) *limitedBody {
+	return &limitedBody{
+		ReadCloser: body,
+		remaining:  limit,
+	}
+}
+
+func (l *limitedBody) Read(p []byte) (int, error) {
+	if l.remaining < 0 {
+		return 0, errBodyTooLarge
+	}
+
+	// Read one byte more than remaining to detect if the body exceeds the limit.
+	if int64(len(p)) > l.remaining+1 {
+		p = p[:l.remaining+1]
+	}
+
+	n, err := l.ReadCloser.Read(p)
+	if int64(n) > l.remaining {
+		n = int(l.remaining)
+		l.remaining = -1
+		return n, errBodyTooLarge
+	}
+
+	l.remaining -= int64(n)
+	return n, err
+}
+
+// tooManyToRankProblem responds with 422 Unprocessable Entity when a leaderboard has more participants than the
+// repository reads at once, see participant.ErrQueryTooLarge.
+func tooManyToRankProblem() http.HandlerFunc {
+	return sendProblem(http.StatusUnprocessableEntity, "Too many participants to rank")
+}
@@ -0,0 +1,24 @@
+package server
+
+import (
+	"bytes"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"testing"
+)
+
+func TestLimitedBody(t *testing.T) {
+	body := newLimitedBody(ioutil.NopCloser(bytes.NewBufferString("1234")), 4)
+	b, err := ioutil.ReadAll(body)
+	assert.NoError(t, err)
+	assert.Equal(t, "1234", string(b))
+
+	body = newLimitedBody(ioutil.NopCloser(bytes.NewBufferString("12345")), 4)
+	b, err = ioutil.ReadAll(body)
+	assert.True(t, errors.Is(err, errBodyTooLarge))
+	assert.Equal(t, "1234", string(b))
+
+	_, err = body.Read(make([]byte, 1))
+	assert.True(t, errors.Is(err, errBodyTooLarge))
+}
@@ -4,60 +4,307 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"github.com/rejlersembriq/hooked/pkg/apikey"
+	"github.com/rejlersembriq/hooked/pkg/attempt"
+	"github.com/rejlersembriq/hooked/pkg/auth"
+	"github.com/rejlersembriq/hooked/pkg/draw"
+	"github.com/rejlersembriq/hooked/pkg/event"
+	"github.com/rejlersembriq/hooked/pkg/idempotency"
+	"github.com/rejlersembriq/hooked/pkg/jwt"
+	"github.com/rejlersembriq/hooked/pkg/leaderboard"
 	"github.com/rejlersembriq/hooked/pkg/participant"
 	"github.com/rejlersembriq/hooked/pkg/router"
+	"github.com/rejlersembriq/hooked/pkg/webhook"
 	"go.uber.org/zap"
 	"net/http"
+	"net/url"
+	"strconv"
 	"strings"
+	"time"
 )
 
 const reqMaxBytes = 256 * 100
 
+// Page sizes for participant listing.
+const (
+	defaultPageSize = 100
+	maxPageSize     = 1000
+)
+
 // Server handles incomming http requests.
 type Server struct {
-	router          *router.Router
-	participantRepo participant.Repository
+	router            *router.Router
+	participantRepo   participant.Repository
+	idempotency       idempotency.Store
+	idempotencyWindow time.Duration
+	attempts          attempt.Repository
+	scoreRule         attempt.Rule
+	events            event.Repository
+	draws             draw.Repository
+	hooks             webhook.Repository
+	deliveries        webhook.DeliveryRepository
+	dispatcher        *webhook.Dispatcher
+	keys              apikey.Repository
+	adminKeyHash      string
+	jwt               *jwt.Verifier
+	roleMapper        auth.RoleMapper
+	authorizerRoles   *auth.RoleMapper
+	hub               *leaderboard.Hub
+	liveHeartbeat     time.Duration
+}
+
+// Option configures optional Server features.
+type Option func(*Server)
+
+// WithIdempotency honours the Idempotency-Key header on participant creation. Keys are stored for window.
+func WithIdempotency(store idempotency.Store, window time.Duration) Option {
+	return func(s *Server) {
+		s.idempotency = store
+		s.idempotencyWindow = window
+	}
+}
+
+// WithAttempts records every score through POST /participant/:id/attempts, and sets the participant's score from its
+// attempts by rule.
+func WithAttempts(repo attempt.Repository, rule attempt.Rule) Option {
+	return func(s *Server) {
+		s.attempts = repo
+		s.scoreRule = rule
+	}
+}
+
+// WithEvents enables the event endpoints, and participants to be registered in an event.
+func WithEvents(repo event.Repository) Option {
+	return func(s *Server) {
+		s.events = repo
+	}
+}
+
+// WithDraws enables the prize draw endpoints.
+func WithDraws(repo draw.Repository) Option {
+	return func(s *Server) {
+		s.draws = repo
+	}
+}
+
+// WithWebhooks enables the webhook endpoints. The dispatcher should deliver to the hooks of the repositories, and be
+// fed by the changes of the participant repository, see participant.ObservedRepository.
+func WithWebhooks(hooks webhook.Repository, deliveries webhook.DeliveryRepository, dispatcher *webhook.Dispatcher) Option {
+	return func(s *Server) {
+		s.hooks = hooks
+		s.deliveries = deliveries
+		s.dispatcher = dispatcher
+	}
+}
+
+// WithAPIKeys requires API keys from repo for every route but reading leaderboards, and enables the key endpoints.
+// adminKey, if not empty, grants every scope, so the first keys can be created. Only its hash is kept.
+func WithAPIKeys(repo apikey.Repository, adminKey string) Option {
+	return func(s *Server) {
+		s.keys = repo
+		if adminKey != "" {
+			s.adminKeyHash = apikey.Hash(adminKey)
+		}
+	}
+}
+
+// WithJWT accepts JWTs verified by v as bearer tokens in the Authorization header, granting the roles mapped from
+// their claims by roles. Every route but reading leaderboards then requires a token or an API key.
+func WithJWT(v *jwt.Verifier, roles auth.RoleMapper) Option {
+	return func(s *Server) {
+		s.jwt = v
+		s.roleMapper = roles
+	}
+}
+
+// WithAuthorizerClaims trusts claims verified before the request reached the server, granting the roles mapped from
+// them by roles, see auth.NewClaimsContext. Eg. the claims of an API Gateway authorizer added by
+// lambdahandler.Handler. Requests without such claims can still use a JWT or an API key.
+func WithAuthorizerClaims(roles auth.RoleMapper) Option {
+	return func(s *Server) {
+		s.authorizerRoles = &roles
+	}
+}
+
+// WithLiveLeaderboard enables GET /leaderboard/stream and GET /leaderboard/ws, sending the updates of hub as
+// server-sent events and over WebSockets with a heartbeat at the given interval. The hub should be fed by the changes
+// of the participant repository, see participant.ObservedRepository.
+func WithLiveLeaderboard(hub *leaderboard.Hub, heartbeat time.Duration) Option {
+	return func(s *Server) {
+		s.hub = hub
+		s.liveHeartbeat = heartbeat
+	}
 }
 
 // New returns a new Server with routes initialized.
-func New(r *router.Router, pr participant.Repository) *Server {
+func New(r *router.Router, pr participant.Repository, opts ...Option) *Server {
 	srvr := &Server{
 		router:          r,
 		participantRepo: pr,
 	}
 
+	for _, opt := range opts {
+		opt(srvr)
+	}
+
+	r.NotFound = sendProblem(http.StatusNotFound, "No route matches the request path")
+	r.MethodNotAllowed = func(res http.ResponseWriter, req *http.Request) {
+		sendProblem(http.StatusMethodNotAllowed, fmt.Sprintf("Method %s not allowed", req.Method)).ServeHTTP(res, req)
+	}
+	r.Authorize = srvr.authorize
+
 	srvr.routes()
 
 	return srvr
 }
 
+// routes registers the routes. Reading leaderboards and CORS preflight requests are public, other routes require a
+// role, see authorize.
 func (s *Server) routes() {
-	s.router.GET("/participants", setCommonHeaders(s.participantsGET()))
-	s.router.POST("/participant", setCommonHeaders(s.participantPOST()))
-	s.router.PUT("/participant/:id", setCommonHeaders(s.participantPUT()))
-	s.router.GET("/participant/:id", setCommonHeaders(s.participantGET()))
-	s.router.DELETE("/participant/:id", setCommonHeaders(s.participantDELETE()))
+	s.router.GET("/participants", setCommonHeaders(s.participantsGET()), router.Require(auth.RoleViewer))
+	s.router.POST("/participant", setCommonHeaders(s.idempotent(s.participantPOST())), router.Require(auth.RoleScorer))
+	s.router.PUT("/participant/:id", setCommonHeaders(s.participantPUT()), router.Require(auth.RoleScorer))
+	s.router.GET("/participant/:id", setCommonHeaders(s.participantGET()), router.Require(auth.RoleViewer))
+	s.router.DELETE("/participant/:id", setCommonHeaders(s.participantDELETE()), router.Require(auth.RoleAdmin))
+	s.router.GET("/leaderboard", setCommonHeaders(s.leaderboardGET()))
+	s.router.GET("/orgs", setCommonHeaders(s.orgsGET()), router.Require(auth.RoleViewer))
+	s.router.GET("/teams", setCommonHeaders(s.teamsGET()), router.Require(auth.RoleViewer))
+	s.router.GET("/org/:org", setCommonHeaders(s.orgGET()), router.Require(auth.RoleViewer))
+	s.router.GET("/org/:org/leaderboard", setCommonHeaders(s.orgLeaderboardGET()))
+	s.router.GET("/admin/duplicates", setCommonHeaders(s.duplicatesGET()), router.Require(auth.RoleAdmin))
+	s.router.POST("/admin/merge", setCommonHeaders(s.mergePOST()), router.Require(auth.RoleAdmin))
+
+	if s.attempts != nil {
+		s.router.GET("/participant/:id/attempts", setCommonHeaders(s.attemptsGET()), router.Require(auth.RoleViewer))
+		s.router.POST("/participant/:id/attempts", setCommonHeaders(s.attemptsPOST()), router.Require(auth.RoleScorer))
+		s.router.OPTIONS("/participant/:id/attempts", setCommonHeaders(options(http.MethodGet, http.MethodPost)))
+	}
+
+	if s.events != nil {
+		s.router.GET("/events", setCommonHeaders(s.eventsGET()), router.Require(auth.RoleViewer))
+		s.router.POST("/event", setCommonHeaders(s.eventPOST()), router.Require(auth.RoleAdmin))
+		s.router.PUT("/event/:slug", setCommonHeaders(s.eventPUT()), router.Require(auth.RoleAdmin))
+		s.router.GET("/event/:slug", setCommonHeaders(s.eventGET()), router.Require(auth.RoleViewer))
+		s.router.DELETE("/event/:slug", setCommonHeaders(s.eventDELETE()), router.Require(auth.RoleAdmin))
+		s.router.GET("/event/:slug/participants", setCommonHeaders(s.eventParticipantsGET()), router.Require(auth.RoleViewer))
+		s.router.GET("/event/:slug/leaderboard", setCommonHeaders(s.eventLeaderboardGET()))
+		s.router.POST("/admin/event/:slug/reopen", setCommonHeaders(s.reopenPOST()), router.Require(auth.RoleAdmin))
+		s.router.OPTIONS("/events", setCommonHeaders(options(http.MethodGet)))
+		s.router.OPTIONS("/event", setCommonHeaders(options(http.MethodPost)))
+		s.router.OPTIONS("/event/:slug", setCommonHeaders(options(http.MethodPut, http.MethodGet, http.MethodDelete)))
+		s.router.OPTIONS("/event/:slug/participants", setCommonHeaders(options(http.MethodGet)))
+		s.router.OPTIONS("/event/:slug/leaderboard", setCommonHeaders(options(http.MethodGet)))
+		s.router.OPTIONS("/admin/event/:slug/reopen", setCommonHeaders(options(http.MethodPost)))
+	}
+
+	if s.hub != nil {
+		s.router.GET("/leaderboard/stream", setCommonHeaders(s.leaderboardStreamGET()))
+		s.router.GET("/leaderboard/ws", setCommonHeaders(s.leaderboardWS()))
+		s.router.OPTIONS("/leaderboard/stream", setCommonHeaders(options(http.MethodGet)))
+	}
+
+	if s.draws != nil {
+		s.router.GET("/admin/draws", setCommonHeaders(s.drawsGET()), router.Require(auth.RoleAdmin))
+		s.router.POST("/admin/draw", setCommonHeaders(s.drawPOST()), router.Require(auth.RoleAdmin))
+		s.router.GET("/admin/draw/:id", setCommonHeaders(s.drawGET()), router.Require(auth.RoleAdmin))
+		s.router.GET("/admin/draw/:id/verify", setCommonHeaders(s.drawVerifyGET()), router.Require(auth.RoleAdmin))
+		s.router.OPTIONS("/admin/draws", setCommonHeaders(options(http.MethodGet)))
+		s.router.OPTIONS("/admin/draw", setCommonHeaders(options(http.MethodPost)))
+		s.router.OPTIONS("/admin/draw/:id", setCommonHeaders(options(http.MethodGet)))
+		s.router.OPTIONS("/admin/draw/:id/verify", setCommonHeaders(options(http.MethodGet)))
+	}
+
+	if s.hooks != nil {
+		s.router.GET("/admin/webhooks", setCommonHeaders(s.webhooksGET()), router.Require(auth.RoleAdmin))
+		s.router.POST("/admin/webhook", setCommonHeaders(s.webhookPOST()), router.Require(auth.RoleAdmin))
+		s.router.GET("/admin/webhook/:id", setCommonHeaders(s.webhookGET()), router.Require(auth.RoleAdmin))
+		s.router.DELETE("/admin/webhook/:id", setCommonHeaders(s.webhookDELETE()), router.Require(auth.RoleAdmin))
+		s.router.GET("/admin/webhook/:id/deliveries", setCommonHeaders(s.webhookDeliveriesGET()), router.Require(auth.RoleAdmin))
+		s.router.GET("/admin/webhook/:id/delivery/:delivery", setCommonHeaders(s.webhookDeliveryGET()), router.Require(auth.RoleAdmin))
+		s.router.POST("/admin/webhook/:id/delivery/:delivery/redeliver", setCommonHeaders(s.webhookRedeliverPOST()), router.Require(auth.RoleAdmin))
+		s.router.OPTIONS("/admin/webhooks", setCommonHeaders(options(http.MethodGet)))
+		s.router.OPTIONS("/admin/webhook", setCommonHeaders(options(http.MethodPost)))
+		s.router.OPTIONS("/admin/webhook/:id", setCommonHeaders(options(http.MethodGet, http.MethodDelete)))
+		s.router.OPTIONS("/admin/webhook/:id/deliveries", setCommonHeaders(options(http.MethodGet)))
+		s.router.OPTIONS("/admin/webhook/:id/delivery/:delivery", setCommonHeaders(options(http.MethodGet)))
+		s.router.OPTIONS("/admin/webhook/:id/delivery/:delivery/redeliver", setCommonHeaders(options(http.MethodPost)))
+	}
+
+	if s.keys != nil {
+		s.router.GET("/admin/keys", setCommonHeaders(s.keysGET()), router.Require(auth.RoleAdmin))
+		s.router.POST("/admin/key", setCommonHeaders(s.keyPOST()), router.Require(auth.RoleAdmin))
+		s.router.GET("/admin/key/:id", setCommonHeaders(s.keyGET()), router.Require(auth.RoleAdmin))
+		s.router.POST("/admin/key/:id/rotate", setCommonHeaders(s.keyRotatePOST()), router.Require(auth.RoleAdmin))
+		s.router.POST("/admin/key/:id/revoke", setCommonHeaders(s.keyRevokePOST()), router.Require(auth.RoleAdmin))
+		s.router.OPTIONS("/admin/keys", setCommonHeaders(options(http.MethodGet)))
+		s.router.OPTIONS("/admin/key", setCommonHeaders(options(http.MethodPost)))
+		s.router.OPTIONS("/admin/key/:id", setCommonHeaders(options(http.MethodGet)))
+		s.router.OPTIONS("/admin/key/:id/rotate", setCommonHeaders(options(http.MethodPost)))
+		s.router.OPTIONS("/admin/key/:id/revoke", setCommonHeaders(options(http.MethodPost)))
+	}
 
 	s.router.OPTIONS("/participants", setCommonHeaders(options(http.MethodGet)))
 	s.router.OPTIONS("/participant", setCommonHeaders(options(http.MethodPost)))
 	s.router.OPTIONS("/participant/:id", setCommonHeaders(options(http.MethodPut, http.MethodGet, http.MethodDelete)))
+	s.router.OPTIONS("/leaderboard", setCommonHeaders(options(http.MethodGet)))
+	s.router.OPTIONS("/orgs", setCommonHeaders(options(http.MethodGet)))
+	s.router.OPTIONS("/teams", setCommonHeaders(options(http.MethodGet)))
+	s.router.OPTIONS("/org/:org", setCommonHeaders(options(http.MethodGet)))
+	s.router.OPTIONS("/org/:org/leaderboard", setCommonHeaders(options(http.MethodGet)))
+	s.router.OPTIONS("/admin/duplicates", setCommonHeaders(options(http.MethodGet)))
+	s.router.OPTIONS("/admin/merge", setCommonHeaders(options(http.MethodPost)))
 }
 
 func (s *Server) ServeHTTP(res http.ResponseWriter, req *http.Request) {
-	req.Body = http.MaxBytesReader(res, req.Body, reqMaxBytes)
+	req = withRequestID(res, req)
+	if req.Body != nil {
+		req.Body = newLimitedBody(req.Body, reqMaxBytes)
+	}
 	s.router.ServeHTTP(res, req)
 }
 
+// participantsGET returns a page of participants. See parseQuery for supported query parameters. Without limit or
+// cursor every participant matching the query is returned as an array, as before pages were added, as far as the
+// repository reads them at once.
 func (s *Server) participantsGET() http.HandlerFunc {
 	return func(res http.ResponseWriter, req *http.Request) {
-		ps, err := s.participantRepo.GetAll()
+		values := req.URL.Query()
+		q, verr := parseQuery(values)
+		if verr != nil {
+			sendValidationProblem(http.StatusBadRequest, "Invalid query parameter", verr).ServeHTTP(res, req)
+			return
+		}
+
+		_, hasLimit := values["limit"]
+		_, hasCursor := values["cursor"]
+		paged := hasLimit || hasCursor
+		if !paged {
+			q.Limit = 0
+		}
+
+		page, err := s.participantRepo.Query(req.Context(), q)
 		if err != nil {
+			if errors.Is(err, participant.ErrInvalidCursor) {
+				invalidParam("cursor", "invalid cursor").ServeHTTP(res, req)
+				return
+			}
+
+			if errors.Is(err, participant.ErrQueryTooLarge) {
+				sendProblem(http.StatusUnprocessableEntity, "Too many participants match the query. Request pages with limit, without sort, or filter by event.").ServeHTTP(res, req)
+				return
+			}
+
 			zap.L().Error("Error retrieveing resources.", zap.String("error", err.Error()))
-			http.Error(res, "Error retrieving resources", http.StatusInternalServerError)
+			sendProblem(http.StatusInternalServerError, "Error retrieving resources").ServeHTTP(res, req)
 			return
 		}
 
-		sendJSON(&ps).ServeHTTP(res, req)
+		if !paged {
+			sendJSON(page.Participants).ServeHTTP(res, req)
+			return
+		}
+
+		sendJSON(page).ServeHTTP(res, req)
 	}
 }
 
@@ -65,25 +312,68 @@ func (s *Server) participantPOST() http.HandlerFunc {
 	return func(res http.ResponseWriter, req *http.Request) {
 		defer req.Body.Close()
 
-		var p participant.Participant
-		if err := json.NewDecoder(req.Body).Decode(&p); err != nil {
-			if err.Error() == "http: request body too large" {
-				http.Error(res, fmt.Sprintf("Request payload too large. Max %d bytes.", reqMaxBytes), http.StatusRequestEntityTooLarge)
+		p, err := participant.Decode(req.Body)
+		if err != nil {
+			decodeProblem(err).ServeHTTP(res, req)
+			return
+		}
+
+		if err := participant.ValidateCreate(p); err != nil {
+			var verr *participant.ValidationError
+			if errors.As(err, &verr) {
+				sendValidationProblem(http.StatusUnprocessableEntity, "Invalid participant", verr).ServeHTTP(res, req)
 				return
 			}
 
-			http.Error(res, "Error unmarshalling request", http.StatusInternalServerError)
+			sendProblem(http.StatusUnprocessableEntity, err.Error()).ServeHTTP(res, req)
 			return
 		}
 
+		if p.Event != nil {
+			e, verr, err := s.checkEvent(req.Context(), *p.Event)
+			if err != nil {
+				zap.L().Error("Error retrieving resource.", zap.String("error", err.Error()))
+				sendProblem(http.StatusInternalServerError, "Error retrieving resource").ServeHTTP(res, req)
+				return
+			}
+
+			if verr != nil {
+				sendValidationProblem(http.StatusUnprocessableEntity, "Invalid participant", verr).ServeHTTP(res, req)
+				return
+			}
+
+			// The leaderboard of a closed event is final, so nobody can join with a score.
+			if now := time.Now(); e.ScoresLocked(now) {
+				scoresLockedProblem(e.Slug, e.StatusAt(now)).ServeHTTP(res, req)
+				return
+			}
+		}
+
 		p.ID = nil
-		saved, err := s.participantRepo.Save(p)
+		saved, err := s.participantRepo.Save(req.Context(), p)
 		if err != nil {
+			var taken *participant.EmailTakenError
+			if errors.As(err, &taken) {
+				emailTakenProblem(taken).ServeHTTP(res, req)
+				return
+			}
+
 			zap.L().Error("Error persisting resource.", zap.String("error", err.Error()))
-			http.Error(res, "Error persisting resource", http.StatusInternalServerError)
+			sendProblem(http.StatusInternalServerError, "Error persisting resource").ServeHTTP(res, req)
 			return
 		}
 
+		// The score registered with is the first attempt, so later attempts are scored against it. A single attempt is
+		// its own score by every rule.
+		if s.attempts != nil && saved.Score != nil {
+			if _, err := s.attempts.Save(req.Context(), attempt.Attempt{Participant: *saved.ID, Score: *saved.Score}); err != nil {
+				zap.L().Error("Error recording score.", zap.String("id", *saved.ID), zap.String("error", err.Error()))
+				sendProblem(http.StatusInternalServerError, "Participant registered, but error recording the score").ServeHTTP(res, req)
+				return
+			}
+		}
+
+		res.Header().Set(etagHeader, etag(saved))
 		sendJSON(&saved).ServeHTTP(res, req)
 	}
 }
@@ -92,34 +382,101 @@ func (s *Server) participantPUT() http.HandlerFunc {
 	return func(res http.ResponseWriter, req *http.Request) {
 		id, exists := router.GetParam(req.Context(), "id")
 		if !exists {
-			http.Error(res, "Unable to get request parameter", http.StatusInternalServerError)
+			sendProblem(http.StatusInternalServerError, "Unable to get request parameter").ServeHTTP(res, req)
+			return
+		}
+
+		p, err := participant.Decode(req.Body)
+		if err != nil {
+			decodeProblem(err).ServeHTTP(res, req)
 			return
 		}
 
-		var p participant.Participant
-		if err := json.NewDecoder(req.Body).Decode(&p); err != nil {
-			if err.Error() == "http: request body too large" {
-				http.Error(res, fmt.Sprintf("Request payload too large. Max %d bytes.", reqMaxBytes), http.StatusRequestEntityTooLarge)
+		if err := participant.ValidateUpdate(p); err != nil {
+			var verr *participant.ValidationError
+			if errors.As(err, &verr) {
+				sendValidationProblem(http.StatusUnprocessableEntity, "Invalid participant", verr).ServeHTTP(res, req)
 				return
 			}
 
-			http.Error(res, "Error unmarshalling request", http.StatusInternalServerError)
+			sendProblem(http.StatusUnprocessableEntity, err.Error()).ServeHTTP(res, req)
 			return
 		}
 
 		p.ID = &id
-		saved, err := s.participantRepo.Save(p)
+
+		ifMatch := req.Header.Get(ifMatchHeader)
+
+		// The current participant is needed to match the ETag, and to find the event of a score change.
+		var current *participant.Participant
+		if ifMatch != "" || (p.Score != nil && s.events != nil) {
+			current, err = s.participantRepo.Get(req.Context(), id)
+			if err != nil {
+				if errors.Is(err, participant.ErrNotExist) {
+					sendProblem(http.StatusNotFound, "Resource not found").ServeHTTP(res, req)
+					return
+				}
+
+				zap.L().Error("Error retrieving resource.", zap.String("error", err.Error()))
+				sendProblem(http.StatusInternalServerError, "Error retrieving resource").ServeHTTP(res, req)
+				return
+			}
+		}
+
+		if p.Score != nil && current != nil && !s.scoresOpen(res, req, current) {
+			return
+		}
+
+		// With If-Match the update is only done if the participant hasn't changed since the client read it.
+		if ifMatch != "" {
+			if !etagMatches(ifMatch, etag(current), false) {
+				sendProblem(http.StatusPreconditionFailed, "Resource has been modified").ServeHTTP(res, req)
+				return
+			}
+
+			version := current.CurrentVersion()
+			p.Version = &version
+		}
+
+		// With attempts the score is recorded as an attempt once the other fields are saved, and derived by rule.
+		var score *int
+		if s.attempts != nil {
+			score, p.Score = p.Score, nil
+		}
+
+		saved, err := s.participantRepo.Save(req.Context(), p)
 		if err != nil {
 			if errors.Is(err, participant.ErrNotExist) {
-				http.Error(res, "Resource not found", http.StatusNotFound)
+				sendProblem(http.StatusNotFound, "Resource not found").ServeHTTP(res, req)
+				return
+			}
+
+			if errors.Is(err, participant.ErrVersionConflict) {
+				sendProblem(http.StatusPreconditionFailed, "Resource has been modified").ServeHTTP(res, req)
+				return
+			}
+
+			var taken *participant.EmailTakenError
+			if errors.As(err, &taken) {
+				emailTakenProblem(taken).ServeHTTP(res, req)
 				return
 			}
 
 			zap.L().Error("Error persisting resource.", zap.String("error", err.Error()))
-			http.Error(res, "Error persisting resource", http.StatusInternalServerError)
+			sendProblem(http.StatusInternalServerError, "Error persisting resource").ServeHTTP(res, req)
 			return
 		}
 
+		if score != nil {
+			saved, err = s.recordScore(req.Context(), id, *score)
+			if err != nil {
+				zap.L().Error("Error recording score.", zap.String("id", id), zap.String("error", err.Error()))
+				sendProblem(http.StatusInternalServerError, "Participant saved, but error recording the score").ServeHTTP(res, req)
+				return
+			}
+		}
+
+		res.Header().Set(etagHeader, etag(saved))
 		sendJSON(&saved).ServeHTTP(res, req)
 	}
 }
@@ -128,19 +485,27 @@ func (s *Server) participantGET() http.HandlerFunc {
 	return func(res http.ResponseWriter, req *http.Request) {
 		id, exists := router.GetParam(req.Context(), "id")
 		if !exists {
-			http.Error(res, "Unable to get request parameter", http.StatusInternalServerError)
+			sendProblem(http.StatusInternalServerError, "Unable to get request parameter").ServeHTTP(res, req)
 			return
 		}
 
-		p, err := s.participantRepo.Get(id)
+		p, err := s.participantRepo.Get(req.Context(), id)
 		if err != nil {
 			if errors.Is(err, participant.ErrNotExist) {
-				http.Error(res, "Resource not found", http.StatusNotFound)
+				sendProblem(http.StatusNotFound, "Resource not found").ServeHTTP(res, req)
 				return
 			}
 
 			zap.L().Error("Error persisting resource.", zap.String("error", err.Error()))
-			http.Error(res, "Error retrieving resource", http.StatusInternalServerError)
+			sendProblem(http.StatusInternalServerError, "Error retrieving resource").ServeHTTP(res, req)
+			return
+		}
+
+		tag := etag(p)
+		res.Header().Set(etagHeader, tag)
+
+		if ifNoneMatch := req.Header.Get(ifNoneMatchHeader); ifNoneMatch != "" && etagMatches(ifNoneMatch, tag, true) {
+			res.WriteHeader(http.StatusNotModified)
 			return
 		}
 
@@ -152,45 +517,318 @@ func (s *Server) participantDELETE() http.HandlerFunc {
 	return func(res http.ResponseWriter, req *http.Request) {
 		id, exists := router.GetParam(req.Context(), "id")
 		if !exists {
-			http.Error(res, "Unable to get request parameter", http.StatusInternalServerError)
+			sendProblem(http.StatusInternalServerError, "Unable to get request parameter").ServeHTTP(res, req)
 			return
 		}
 
-		if err := s.participantRepo.Delete(id); err != nil {
+		// Removing a participant changes the leaderboard of its event, so it's locked like the scores.
+		if s.events != nil {
+			current, ok := s.getParticipant(res, req, id)
+			if !ok || !s.scoresOpen(res, req, current) {
+				return
+			}
+		}
+
+		if err := s.participantRepo.Delete(req.Context(), id); err != nil {
 			if errors.Is(err, participant.ErrNotExist) {
-				http.Error(res, "Resource not found", http.StatusNotFound)
+				sendProblem(http.StatusNotFound, "Resource not found").ServeHTTP(res, req)
 				return
 			}
 
-			zap.L().Error("rror deleting resource.", zap.String("id", id), zap.String("error", err.Error()))
-			http.Error(res, "Error retrieving resource", http.StatusInternalServerError)
+			zap.L().Error("Error deleting resource.", zap.String("id", id), zap.String("error", err.Error()))
+			sendProblem(http.StatusInternalServerError, "Error deleting resource").ServeHTTP(res, req)
 			return
 		}
 
+		// The participant is gone, so failing to delete its attempts only leaves unreachable data behind.
+		if s.attempts != nil {
+			if err := s.attempts.DeleteAll(req.Context(), id); err != nil {
+				zap.L().Error("Error deleting attempts.", zap.String("id", id), zap.String("error", err.Error()))
+			}
+		}
+
 		sendString("Deleted").ServeHTTP(res, req)
 	}
 }
 
+// leaderboardGET returns participants ranked by score. Query parameters:
+// ties: "shared" (default) or "earliest". limit: max number of entries returned.
+func (s *Server) leaderboardGET() http.HandlerFunc {
+	return func(res http.ResponseWriter, req *http.Request) {
+		tie, err := leaderboard.ParseTieBreak(req.URL.Query().Get("ties"))
+		if err != nil {
+			invalidParam("ties", "must be one of: shared, earliest").ServeHTTP(res, req)
+			return
+		}
+
+		limit, err := parseLimit(req.URL.Query().Get("limit"))
+		if err != nil {
+			invalidParam("limit", "must be a positive integer").ServeHTTP(res, req)
+			return
+		}
+
+		ps, err := s.participantRepo.GetAll(req.Context())
+		if err != nil {
+			zap.L().Error("Error retrieveing resources.", zap.String("error", err.Error()))
+			sendProblem(http.StatusInternalServerError, "Error retrieving resources").ServeHTTP(res, req)
+			return
+		}
+
+		entries := leaderboard.Rank(ps, tie, limit)
+
+		sendJSON(&entries).ServeHTTP(res, req)
+	}
+}
+
+// orgsGET returns the names of all organisations with at least one participant.
+func (s *Server) orgsGET() http.HandlerFunc {
+	return func(res http.ResponseWriter, req *http.Request) {
+		ps, err := s.participantRepo.GetAll(req.Context())
+		if err != nil {
+			zap.L().Error("Error retrieveing resources.", zap.String("error", err.Error()))
+			sendProblem(http.StatusInternalServerError, "Error retrieving resources").ServeHTTP(res, req)
+			return
+		}
+
+		orgs := leaderboard.Orgs(ps)
+
+		sendJSON(&orgs).ServeHTTP(res, req)
+	}
+}
+
+// teamsGET returns the organisations ranked by their aggregated score. Query parameters:
+// by: "total" (default), "average" or "best". limit: max number of entries returned.
+func (s *Server) teamsGET() http.HandlerFunc {
+	return func(res http.ResponseWriter, req *http.Request) {
+		by, err := leaderboard.ParseMetric(req.URL.Query().Get("by"))
+		if err != nil {
+			invalidParam("by", "must be one of: total, average, best").ServeHTTP(res, req)
+			return
+		}
+
+		limit, err := parseLimit(req.URL.Query().Get("limit"))
+		if err != nil {
+			invalidParam("limit", "must be a positive integer").ServeHTTP(res, req)
+			return
+		}
+
+		ps, err := s.participantRepo.GetAll(req.Context())
+		if err != nil {
+			zap.L().Error("Error retrieveing resources.", zap.String("error", err.Error()))
+			sendProblem(http.StatusInternalServerError, "Error retrieving resources").ServeHTTP(res, req)
+			return
+		}
+
+		entries := leaderboard.RankTeams(leaderboard.Teams(ps), by, limit)
+
+		sendJSON(&entries).ServeHTTP(res, req)
+	}
+}
+
+// orgGET returns the aggregated score for a single organisation.
+func (s *Server) orgGET() http.HandlerFunc {
+	return func(res http.ResponseWriter, req *http.Request) {
+		org, exists := router.GetParam(req.Context(), "org")
+		if !exists {
+			sendProblem(http.StatusInternalServerError, "Unable to get request parameter").ServeHTTP(res, req)
+			return
+		}
+
+		// The organisation is read a page at a time, as a query reading every participant at once fails for large ones.
+		b := leaderboard.NewTeamBuilder(org)
+		q := participant.Query{Org: &org, Limit: maxPageSize}
+		for {
+			page, err := s.participantRepo.Query(req.Context(), q)
+			if err != nil {
+				zap.L().Error("Error retrieveing resources.", zap.String("error", err.Error()))
+				sendProblem(http.StatusInternalServerError, "Error retrieving resources").ServeHTTP(res, req)
+				return
+			}
+
+			b.Add(page.Participants)
+			if page.Next == "" {
+				break
+			}
+			q.Cursor = page.Next
+		}
+
+		team := b.Team()
+		if team.Participants == 0 {
+			sendProblem(http.StatusNotFound, "Resource not found").ServeHTTP(res, req)
+			return
+		}
+
+		sendJSON(&team).ServeHTTP(res, req)
+	}
+}
+
+// orgLeaderboardGET returns the participants in an organisation ranked by score. Takes the same query parameters
+// as leaderboardGET.
+func (s *Server) orgLeaderboardGET() http.HandlerFunc {
+	return func(res http.ResponseWriter, req *http.Request) {
+		org, exists := router.GetParam(req.Context(), "org")
+		if !exists {
+			sendProblem(http.StatusInternalServerError, "Unable to get request parameter").ServeHTTP(res, req)
+			return
+		}
+
+		tie, err := leaderboard.ParseTieBreak(req.URL.Query().Get("ties"))
+		if err != nil {
+			invalidParam("ties", "must be one of: shared, earliest").ServeHTTP(res, req)
+			return
+		}
+
+		limit, err := parseLimit(req.URL.Query().Get("limit"))
+		if err != nil {
+			invalidParam("limit", "must be a positive integer").ServeHTTP(res, req)
+			return
+		}
+
+		page, err := s.participantRepo.Query(req.Context(), participant.Query{Org: &org})
+		if err != nil {
+			if errors.Is(err, participant.ErrQueryTooLarge) {
+				tooManyToRankProblem().ServeHTTP(res, req)
+				return
+			}
+
+			zap.L().Error("Error retrieveing resources.", zap.String("error", err.Error()))
+			sendProblem(http.StatusInternalServerError, "Error retrieving resources").ServeHTTP(res, req)
+			return
+		}
+
+		entries := leaderboard.Rank(page.Participants, tie, limit)
+
+		sendJSON(&entries).ServeHTTP(res, req)
+	}
+}
+
 func options(allowed ...string) http.HandlerFunc {
 	return func(res http.ResponseWriter, req *http.Request) {
 		res.Header().Set("Access-Control-Allow-Methods", strings.Join(allowed, ", "))
-		res.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+		res.Header().Set("Access-Control-Allow-Headers", strings.Join([]string{"Content-Type", requestIDHeader, ifMatchHeader, ifNoneMatchHeader, idempotencyKeyHeader, lastEventIDHeader, apiKeyHeader, authorizationHeader}, ", "))
 	}
 }
 
 func setCommonHeaders(h http.HandlerFunc) http.HandlerFunc {
 	return func(res http.ResponseWriter, req *http.Request) {
 		res.Header().Set("Access-Control-Allow-Origin", "*")
+		res.Header().Set("Access-Control-Expose-Headers", strings.Join([]string{requestIDHeader, etagHeader, locationHeader, idempotentReplayedHeader}, ", "))
 		h.ServeHTTP(res, req)
 	}
 }
 
+// parseLimit parses a limit query parameter. An empty value means no limit and returns 0.
+func parseLimit(v string) (int, error) {
+	if v == "" {
+		return 0, nil
+	}
+
+	limit, err := strconv.Atoi(v)
+	if err != nil {
+		return 0, err
+	}
+
+	if limit < 1 {
+		return 0, errors.New("limit must be positive")
+	}
+
+	return limit, nil
+}
+
+// parseQuery builds a participant query from the request query parameters:
+// org, event, minScore, maxScore, createdFrom, createdTo, updatedFrom, updatedTo (RFC 3339), sort (score, name, created
+// or updated, prefix with - for descending), limit and cursor. All invalid parameters are reported in the returned
+// error.
+func parseQuery(values url.Values) (participant.Query, *participant.ValidationError) {
+	var q participant.Query
+	verr := &participant.ValidationError{}
+
+	if v, ok := values["org"]; ok {
+		q.Org = &v[0]
+	}
+
+	if v, ok := values["event"]; ok {
+		q.Event = &v[0]
+	}
+
+	q.MinScore = parseIntParam(values, "minScore", verr)
+	q.MaxScore = parseIntParam(values, "maxScore", verr)
+	q.CreatedFrom = parseTimeParam(values, "createdFrom", verr)
+	q.CreatedTo = parseTimeParam(values, "createdTo", verr)
+	q.UpdatedFrom = parseTimeParam(values, "updatedFrom", verr)
+	q.UpdatedTo = parseTimeParam(values, "updatedTo", verr)
+
+	sortParam := values.Get("sort")
+	if strings.HasPrefix(sortParam, "-") {
+		q.Descending = true
+		sortParam = sortParam[1:]
+	}
+
+	field, ok := participant.ParseSortField(sortParam)
+	if !ok {
+		addFieldError(verr, "sort", "must be one of: score, name, created, updated")
+	}
+	q.Sort = field
+
+	limit, err := parseLimit(values.Get("limit"))
+	if err != nil || limit > maxPageSize {
+		addFieldError(verr, "limit", fmt.Sprintf("must be an integer between 1 and %d", maxPageSize))
+	}
+
+	q.Limit = defaultPageSize
+	if limit > 0 {
+		q.Limit = limit
+	}
+
+	q.Cursor = values.Get("cursor")
+
+	if len(verr.Fields) > 0 {
+		return q, verr
+	}
+
+	return q, nil
+}
+
+func addFieldError(verr *participant.ValidationError, field, msg string) {
+	verr.Fields = append(verr.Fields, participant.FieldError{Field: field, Message: msg})
+}
+
+func parseIntParam(values url.Values, name string, verr *participant.ValidationError) *int {
+	v := values.Get(name)
+	if v == "" {
+		return nil
+	}
+
+	i, err := strconv.Atoi(v)
+	if err != nil {
+		addFieldError(verr, name, "must be an integer")
+		return nil
+	}
+
+	return &i
+}
+
+func parseTimeParam(values url.Values, name string, verr *participant.ValidationError) *time.Time {
+	v := values.Get(name)
+	if v == "" {
+		return nil
+	}
+
+	t, err := time.Parse(time.RFC3339, v)
+	if err != nil {
+		addFieldError(verr, name, "must be a RFC 3339 timestamp")
+		return nil
+	}
+
+	return &t
+}
+
 func sendJSON(v interface{}) http.HandlerFunc {
 	return func(res http.ResponseWriter, req *http.Request) {
 		res.Header().Set("Content-Type", "application/json")
 		if err := json.NewEncoder(res).Encode(v); err != nil {
 			zap.L().Error("Error marshalling response.", zap.String("error", err.Error()))
-			http.Error(res, "Error marshalling response", http.StatusInternalServerError)
+			sendProblem(http.StatusInternalServerError, "Error marshalling response").ServeHTTP(res, req)
 		}
 	}
 }
@@ -200,7 +838,7 @@ func sendString(s string) http.HandlerFunc {
 		res.Header().Set("Content-Type", "text/plain; charset=utf-8")
 		if _, err := res.Write([]byte(s)); err != nil {
 			zap.L().Error("Error sending string response.", zap.String("error", err.Error()))
-			http.Error(res, "Internal Server Error", http.StatusInternalServerError)
+			sendProblem(http.StatusInternalServerError, "Internal Server Error").ServeHTTP(res, req)
 		}
 	}
 }
@@ -2,15 +2,20 @@ package server
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/rejlersembriq/hooked/pkg/leaderboard"
 	"github.com/rejlersembriq/hooked/pkg/participant"
+	"github.com/rejlersembriq/hooked/pkg/repository/memory"
 	"github.com/rejlersembriq/hooked/pkg/router"
 	"github.com/rejlersembriq/hooked/test"
 	"github.com/stretchr/testify/assert"
 	"net/http"
 	"net/http/httptest"
+	"strconv"
+	"strings"
 	"testing"
 	"time"
 )
@@ -27,7 +32,7 @@ func TestServer_ServeHTTP_NotFound(t *testing.T) {
 	srvr.ServeHTTP(res, req)
 
 	assert.Equal(t, http.StatusNotFound, res.Code)
-	assert.Equal(t, "text/plain; charset=utf-8", res.Header().Get("Content-Type"))
+	assert.Equal(t, problemContentType, res.Header().Get("Content-Type"))
 }
 
 func TestServer_ServeHTTP_GETParticipants(t *testing.T) {
@@ -35,8 +40,9 @@ func TestServer_ServeHTTP_GETParticipants(t *testing.T) {
 	res := httptest.NewRecorder()
 
 	mock := &test.RepoMock{
-		GetAllHandler: func() ([]*participant.Participant, participant.Error) {
-			return []*participant.Participant{}, nil
+		QueryHandler: func(ctx context.Context, q participant.Query) (*participant.Page, participant.Error) {
+			assert.Equal(t, 0, q.Limit, "without limit or cursor every participant should be returned")
+			return &participant.Page{Participants: []*participant.Participant{{ID: aws.String("1")}}}, nil
 		},
 	}
 
@@ -47,6 +53,50 @@ func TestServer_ServeHTTP_GETParticipants(t *testing.T) {
 
 	assert.Equal(t, http.StatusOK, res.Code)
 	assert.Equal(t, "application/json", res.Header().Get("Content-Type"))
+
+	var ps []*participant.Participant
+	assert.NoError(t, json.NewDecoder(res.Body).Decode(&ps), "without limit or cursor the response should be an array")
+	assert.Len(t, ps, 1)
+}
+
+func TestServer_ServeHTTP_GETParticipants_Paged(t *testing.T) {
+	req, _ := http.NewRequest(http.MethodGet, "/participants?cursor=", nil)
+	res := httptest.NewRecorder()
+
+	mock := &test.RepoMock{
+		QueryHandler: func(ctx context.Context, q participant.Query) (*participant.Page, participant.Error) {
+			assert.Equal(t, defaultPageSize, q.Limit)
+			return &participant.Page{Participants: []*participant.Participant{}, Next: "next"}, nil
+		},
+	}
+
+	srvr := New(router.New(), mock)
+
+	srvr.ServeHTTP(res, req)
+
+	assert.Equal(t, http.StatusOK, res.Code)
+
+	var page participant.Page
+	assert.NoError(t, json.NewDecoder(res.Body).Decode(&page))
+	assert.Equal(t, "next", page.Next)
+}
+
+func TestServer_ServeHTTP_GETParticipants_TooLarge(t *testing.T) {
+	req, _ := http.NewRequest(http.MethodGet, "/participants?sort=score", nil)
+	res := httptest.NewRecorder()
+
+	mock := &test.RepoMock{
+		QueryHandler: func(ctx context.Context, q participant.Query) (*participant.Page, participant.Error) {
+			return nil, participant.ErrQueryTooLarge
+		},
+	}
+
+	srvr := New(router.New(), mock)
+
+	srvr.ServeHTTP(res, req)
+
+	assert.Equal(t, http.StatusUnprocessableEntity, res.Code)
+	assert.Equal(t, problemContentType, res.Header().Get("Content-Type"))
 }
 
 func TestServer_ServeHTTP_GETparticipants_Error(t *testing.T) {
@@ -54,7 +104,7 @@ func TestServer_ServeHTTP_GETparticipants_Error(t *testing.T) {
 	res := httptest.NewRecorder()
 
 	mock := &test.RepoMock{
-		GetAllHandler: func() ([]*participant.Participant, participant.Error) {
+		QueryHandler: func(ctx context.Context, q participant.Query) (*participant.Page, participant.Error) {
 			return nil, errors.New("SomeError")
 		},
 	}
@@ -65,7 +115,77 @@ func TestServer_ServeHTTP_GETparticipants_Error(t *testing.T) {
 	srvr.ServeHTTP(res, req)
 
 	assert.Equal(t, http.StatusInternalServerError, res.Code)
-	assert.Equal(t, "text/plain; charset=utf-8", res.Header().Get("Content-Type"))
+	assert.Equal(t, problemContentType, res.Header().Get("Content-Type"))
+}
+
+func TestServer_ServeHTTP_GETParticipants_Query(t *testing.T) {
+	req, _ := http.NewRequest(http.MethodGet, "/participants?org=OrgA&minScore=1&maxScore=10&createdFrom=2019-10-01T12:00:00Z&sort=-score&limit=5&cursor=abc", nil)
+	res := httptest.NewRecorder()
+
+	mock := &test.RepoMock{
+		QueryHandler: func(ctx context.Context, q participant.Query) (*participant.Page, participant.Error) {
+			assert.Equal(t, "OrgA", *q.Org)
+			assert.Equal(t, 1, *q.MinScore)
+			assert.Equal(t, 10, *q.MaxScore)
+			assert.Equal(t, time.Date(2019, 10, 1, 12, 0, 0, 0, time.UTC), *q.CreatedFrom)
+			assert.Nil(t, q.CreatedTo)
+			assert.Equal(t, participant.SortScore, q.Sort)
+			assert.True(t, q.Descending)
+			assert.Equal(t, 5, q.Limit)
+			assert.Equal(t, "abc", q.Cursor)
+			return &participant.Page{Participants: []*participant.Participant{}, Next: "next"}, nil
+		},
+	}
+
+	srvr := New(router.New(), mock)
+
+	srvr.ServeHTTP(res, req)
+
+	assert.Equal(t, http.StatusOK, res.Code)
+
+	var page participant.Page
+	assert.NoError(t, json.NewDecoder(res.Body).Decode(&page))
+	assert.Equal(t, "next", page.Next)
+}
+
+func TestServer_ServeHTTP_GETParticipants_BadRequest(t *testing.T) {
+	queries := []string{
+		"?minScore=abc",
+		"?maxScore=1.5",
+		"?createdTo=yesterday",
+		"?updatedFrom=2019-10-01",
+		"?sort=email",
+		"?limit=0",
+		"?limit=100000",
+	}
+
+	for _, query := range queries {
+		req, _ := http.NewRequest(http.MethodGet, "/participants"+query, nil)
+		res := httptest.NewRecorder()
+
+		srvr := New(router.New(), &test.RepoMock{})
+
+		srvr.ServeHTTP(res, req)
+
+		assert.Equal(t, http.StatusBadRequest, res.Code, query)
+	}
+}
+
+func TestServer_ServeHTTP_GETParticipants_InvalidCursor(t *testing.T) {
+	req, _ := http.NewRequest(http.MethodGet, "/participants?cursor=invalid", nil)
+	res := httptest.NewRecorder()
+
+	mock := &test.RepoMock{
+		QueryHandler: func(ctx context.Context, q participant.Query) (*participant.Page, participant.Error) {
+			return nil, participant.ErrInvalidCursor
+		},
+	}
+
+	srvr := New(router.New(), mock)
+
+	srvr.ServeHTTP(res, req)
+
+	assert.Equal(t, http.StatusBadRequest, res.Code)
 }
 
 func TestServer_ServeHTTP_POSTParticipant(t *testing.T) {
@@ -85,7 +205,7 @@ func TestServer_ServeHTTP_POSTParticipant(t *testing.T) {
 	res := httptest.NewRecorder()
 
 	mock := &test.RepoMock{
-		SaveHandler: func(p participant.Participant) (*participant.Participant, participant.Error) {
+		SaveHandler: func(ctx context.Context, p participant.Participant) (*participant.Participant, participant.Error) {
 			assert.Nil(t, p.ID)
 
 			p.ID = aws.String("someId")
@@ -122,7 +242,7 @@ func TestServer_ServeHTTP_POSTParticipant_Error(t *testing.T) {
 	res := httptest.NewRecorder()
 
 	mock := &test.RepoMock{
-		SaveHandler: func(p participant.Participant) (*participant.Participant, participant.Error) {
+		SaveHandler: func(ctx context.Context, p participant.Participant) (*participant.Participant, participant.Error) {
 			return &p, errors.New("SomeError")
 		},
 	}
@@ -133,7 +253,7 @@ func TestServer_ServeHTTP_POSTParticipant_Error(t *testing.T) {
 	srvr.ServeHTTP(res, req)
 
 	assert.Equal(t, http.StatusInternalServerError, res.Code)
-	assert.Equal(t, "text/plain; charset=utf-8", res.Header().Get("Content-Type"))
+	assert.Equal(t, problemContentType, res.Header().Get("Content-Type"))
 }
 
 func TestServer_ServeHTTP_PUTParticipant(t *testing.T) {
@@ -154,7 +274,7 @@ func TestServer_ServeHTTP_PUTParticipant(t *testing.T) {
 
 	rtr := router.New()
 	mock := &test.RepoMock{
-		SaveHandler: func(p participant.Participant) (*participant.Participant, participant.Error) {
+		SaveHandler: func(ctx context.Context, p participant.Participant) (*participant.Participant, participant.Error) {
 			assert.Equal(t, "someId", *p.ID)
 
 			now := time.Now()
@@ -189,7 +309,7 @@ func TestServer_ServeHTTP_PUTParticipant_Error(t *testing.T) {
 
 	rtr := router.New()
 	mock := &test.RepoMock{
-		SaveHandler: func(p participant.Participant) (*participant.Participant, participant.Error) {
+		SaveHandler: func(ctx context.Context, p participant.Participant) (*participant.Participant, participant.Error) {
 			assert.Equal(t, "someId", *p.ID)
 			return &p, errors.New("SomeError")
 		},
@@ -199,7 +319,7 @@ func TestServer_ServeHTTP_PUTParticipant_Error(t *testing.T) {
 	srvr.ServeHTTP(res, req)
 
 	assert.Equal(t, http.StatusInternalServerError, res.Code)
-	assert.Equal(t, "text/plain; charset=utf-8", res.Header().Get("Content-Type"))
+	assert.Equal(t, problemContentType, res.Header().Get("Content-Type"))
 }
 
 func TestServer_ServeHTTP_PUTParticipant_NotFound(t *testing.T) {
@@ -220,7 +340,7 @@ func TestServer_ServeHTTP_PUTParticipant_NotFound(t *testing.T) {
 
 	rtr := router.New()
 	mock := &test.RepoMock{
-		SaveHandler: func(p participant.Participant) (*participant.Participant, participant.Error) {
+		SaveHandler: func(ctx context.Context, p participant.Participant) (*participant.Participant, participant.Error) {
 			assert.Equal(t, "someId", *p.ID)
 			return nil, participant.ErrNotExist
 		},
@@ -230,7 +350,7 @@ func TestServer_ServeHTTP_PUTParticipant_NotFound(t *testing.T) {
 	srvr.ServeHTTP(res, req)
 
 	assert.Equal(t, http.StatusNotFound, res.Code)
-	assert.Equal(t, "text/plain; charset=utf-8", res.Header().Get("Content-Type"))
+	assert.Equal(t, problemContentType, res.Header().Get("Content-Type"))
 }
 
 func TestServer_ServeHTTP_GETParticipant(t *testing.T) {
@@ -239,7 +359,7 @@ func TestServer_ServeHTTP_GETParticipant(t *testing.T) {
 
 	rtr := router.New()
 	mock := &test.RepoMock{
-		GetHandler: func(id string) (*participant.Participant, participant.Error) {
+		GetHandler: func(ctx context.Context, id string) (*participant.Participant, participant.Error) {
 			return &participant.Participant{}, nil
 		},
 	}
@@ -257,7 +377,7 @@ func TestServer_ServeHTTP_GETParticipant_Error(t *testing.T) {
 
 	rtr := router.New()
 	mock := &test.RepoMock{
-		GetHandler: func(id string) (*participant.Participant, participant.Error) {
+		GetHandler: func(ctx context.Context, id string) (*participant.Participant, participant.Error) {
 			return nil, errors.New("SomeError")
 		},
 	}
@@ -266,7 +386,7 @@ func TestServer_ServeHTTP_GETParticipant_Error(t *testing.T) {
 	srvr.ServeHTTP(res, req)
 
 	assert.Equal(t, http.StatusInternalServerError, res.Code)
-	assert.Equal(t, "text/plain; charset=utf-8", res.Header().Get("Content-Type"))
+	assert.Equal(t, problemContentType, res.Header().Get("Content-Type"))
 }
 
 func TestServer_ServeHTTP_GETParticipant_NotFound(t *testing.T) {
@@ -275,7 +395,7 @@ func TestServer_ServeHTTP_GETParticipant_NotFound(t *testing.T) {
 
 	rtr := router.New()
 	mock := &test.RepoMock{
-		GetHandler: func(id string) (*participant.Participant, participant.Error) {
+		GetHandler: func(ctx context.Context, id string) (*participant.Participant, participant.Error) {
 			return nil, participant.ErrNotExist
 		},
 	}
@@ -284,7 +404,7 @@ func TestServer_ServeHTTP_GETParticipant_NotFound(t *testing.T) {
 	srvr.ServeHTTP(res, req)
 
 	assert.Equal(t, http.StatusNotFound, res.Code)
-	assert.Equal(t, "text/plain; charset=utf-8", res.Header().Get("Content-Type"))
+	assert.Equal(t, problemContentType, res.Header().Get("Content-Type"))
 }
 
 func TestServer_ServeHTTP_DELETEParticipant(t *testing.T) {
@@ -293,7 +413,7 @@ func TestServer_ServeHTTP_DELETEParticipant(t *testing.T) {
 
 	rtr := router.New()
 	mock := &test.RepoMock{
-		DeleteHandler: func(id string) participant.Error {
+		DeleteHandler: func(ctx context.Context, id string) participant.Error {
 			return nil
 		},
 	}
@@ -311,7 +431,7 @@ func TestServer_ServeHTTP_DELETEParticipant_Error(t *testing.T) {
 
 	rtr := router.New()
 	mock := &test.RepoMock{
-		DeleteHandler: func(id string) participant.Error {
+		DeleteHandler: func(ctx context.Context, id string) participant.Error {
 			return errors.New("SomeError")
 		},
 	}
@@ -320,7 +440,7 @@ func TestServer_ServeHTTP_DELETEParticipant_Error(t *testing.T) {
 	srvr.ServeHTTP(res, req)
 
 	assert.Equal(t, http.StatusInternalServerError, res.Code)
-	assert.Equal(t, "text/plain; charset=utf-8", res.Header().Get("Content-Type"))
+	assert.Equal(t, problemContentType, res.Header().Get("Content-Type"))
 }
 
 func TestServer_ServeHTTP_DELETEParticipant_NotFound(t *testing.T) {
@@ -329,7 +449,7 @@ func TestServer_ServeHTTP_DELETEParticipant_NotFound(t *testing.T) {
 
 	rtr := router.New()
 	mock := &test.RepoMock{
-		DeleteHandler: func(id string) participant.Error {
+		DeleteHandler: func(ctx context.Context, id string) participant.Error {
 			return participant.ErrNotExist
 		},
 	}
@@ -338,5 +458,566 @@ func TestServer_ServeHTTP_DELETEParticipant_NotFound(t *testing.T) {
 	srvr.ServeHTTP(res, req)
 
 	assert.Equal(t, http.StatusNotFound, res.Code)
-	assert.Equal(t, "text/plain; charset=utf-8", res.Header().Get("Content-Type"))
+	assert.Equal(t, problemContentType, res.Header().Get("Content-Type"))
+}
+
+func TestServer_ServeHTTP_RequestContextPassedToRepository(t *testing.T) {
+	type ctxKey string
+	ctx := context.WithValue(context.Background(), ctxKey("key"), "value")
+
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, "/participant/someId", nil)
+	res := httptest.NewRecorder()
+
+	rtr := router.New()
+	mock := &test.RepoMock{
+		GetHandler: func(ctx context.Context, id string) (*participant.Participant, participant.Error) {
+			assert.Equal(t, "value", ctx.Value(ctxKey("key")))
+			return &participant.Participant{}, nil
+		},
+	}
+	srvr := New(rtr, mock)
+
+	srvr.ServeHTTP(res, req)
+
+	assert.Equal(t, http.StatusOK, res.Code)
+}
+
+func TestServer_ServeHTTP_GETLeaderboard(t *testing.T) {
+	req, _ := http.NewRequest(http.MethodGet, "/leaderboard?ties=earliest&limit=2", nil)
+	res := httptest.NewRecorder()
+
+	rtr := router.New()
+	mock := &test.RepoMock{
+		GetAllHandler: func(ctx context.Context) ([]*participant.Participant, participant.Error) {
+			return []*participant.Participant{
+				{ID: aws.String("low"), Score: aws.Int(1)},
+				{ID: aws.String("high"), Score: aws.Int(3)},
+				{ID: aws.String("mid"), Score: aws.Int(2)},
+			}, nil
+		},
+	}
+	srvr := New(rtr, mock)
+
+	srvr.ServeHTTP(res, req)
+
+	assert.Equal(t, http.StatusOK, res.Code)
+	assert.Equal(t, "application/json", res.Header().Get("Content-Type"))
+
+	var entries []struct {
+		Rank int    `json:"rank"`
+		ID   string `json:"id"`
+	}
+	assert.NoError(t, json.NewDecoder(res.Body).Decode(&entries))
+	assert.Len(t, entries, 2)
+	assert.Equal(t, 1, entries[0].Rank)
+	assert.Equal(t, "high", entries[0].ID)
+	assert.Equal(t, 2, entries[1].Rank)
+	assert.Equal(t, "mid", entries[1].ID)
+}
+
+func TestServer_ServeHTTP_GETLeaderboard_BadRequest(t *testing.T) {
+	for _, query := range []string{"?ties=invalid", "?limit=0", "?limit=abc"} {
+		req, _ := http.NewRequest(http.MethodGet, "/leaderboard"+query, nil)
+		res := httptest.NewRecorder()
+
+		rtr := router.New()
+		srvr := New(rtr, &test.RepoMock{})
+
+		srvr.ServeHTTP(res, req)
+
+		assert.Equal(t, http.StatusBadRequest, res.Code, query)
+	}
+}
+
+func TestServer_ServeHTTP_GETLeaderboard_Error(t *testing.T) {
+	req, _ := http.NewRequest(http.MethodGet, "/leaderboard", nil)
+	res := httptest.NewRecorder()
+
+	rtr := router.New()
+	mock := &test.RepoMock{
+		GetAllHandler: func(ctx context.Context) ([]*participant.Participant, participant.Error) {
+			return nil, errors.New("SomeError")
+		},
+	}
+	srvr := New(rtr, mock)
+
+	srvr.ServeHTTP(res, req)
+
+	assert.Equal(t, http.StatusInternalServerError, res.Code)
+}
+
+func orgParticipantsMock() *test.RepoMock {
+	ps := []*participant.Participant{
+		{ID: aws.String("a1"), Org: aws.String("OrgA"), Score: aws.Int(1)},
+		{ID: aws.String("a2"), Org: aws.String("OrgA"), Score: aws.Int(5)},
+		{ID: aws.String("b1"), Org: aws.String("OrgB"), Score: aws.Int(4)},
+	}
+
+	return &test.RepoMock{
+		GetAllHandler: func(ctx context.Context) ([]*participant.Participant, participant.Error) {
+			return ps, nil
+		},
+		QueryHandler: func(ctx context.Context, q participant.Query) (*participant.Page, participant.Error) {
+			var res []*participant.Participant
+			for _, p := range ps {
+				if q.Match(p) {
+					res = append(res, p)
+				}
+			}
+			return q.Paginate(res)
+		},
+	}
+}
+
+func TestServer_ServeHTTP_GETOrgs(t *testing.T) {
+	req, _ := http.NewRequest(http.MethodGet, "/orgs", nil)
+	res := httptest.NewRecorder()
+
+	srvr := New(router.New(), orgParticipantsMock())
+
+	srvr.ServeHTTP(res, req)
+
+	assert.Equal(t, http.StatusOK, res.Code)
+
+	var orgs []string
+	assert.NoError(t, json.NewDecoder(res.Body).Decode(&orgs))
+	assert.Equal(t, []string{"OrgA", "OrgB"}, orgs)
+}
+
+func TestServer_ServeHTTP_GETTeams(t *testing.T) {
+	req, _ := http.NewRequest(http.MethodGet, "/teams?by=best", nil)
+	res := httptest.NewRecorder()
+
+	srvr := New(router.New(), orgParticipantsMock())
+
+	srvr.ServeHTTP(res, req)
+
+	assert.Equal(t, http.StatusOK, res.Code)
+
+	var teams []struct {
+		Rank int    `json:"rank"`
+		Org  string `json:"org"`
+		Best int    `json:"best"`
+	}
+	assert.NoError(t, json.NewDecoder(res.Body).Decode(&teams))
+	assert.Len(t, teams, 2)
+	assert.Equal(t, "OrgA", teams[0].Org)
+	assert.Equal(t, 5, teams[0].Best)
+	assert.Equal(t, "OrgB", teams[1].Org)
+}
+
+func TestServer_ServeHTTP_GETTeams_BadRequest(t *testing.T) {
+	req, _ := http.NewRequest(http.MethodGet, "/teams?by=invalid", nil)
+	res := httptest.NewRecorder()
+
+	srvr := New(router.New(), orgParticipantsMock())
+
+	srvr.ServeHTTP(res, req)
+
+	assert.Equal(t, http.StatusBadRequest, res.Code)
+}
+
+func TestServer_ServeHTTP_GETOrg(t *testing.T) {
+	req, _ := http.NewRequest(http.MethodGet, "/org/OrgA", nil)
+	res := httptest.NewRecorder()
+
+	srvr := New(router.New(), orgParticipantsMock())
+
+	srvr.ServeHTTP(res, req)
+
+	assert.Equal(t, http.StatusOK, res.Code)
+
+	var team struct {
+		Org          string  `json:"org"`
+		Participants int     `json:"participants"`
+		Total        int     `json:"total"`
+		Average      float64 `json:"average"`
+	}
+	assert.NoError(t, json.NewDecoder(res.Body).Decode(&team))
+	assert.Equal(t, "OrgA", team.Org)
+	assert.Equal(t, 2, team.Participants)
+	assert.Equal(t, 6, team.Total)
+	assert.Equal(t, 3.0, team.Average)
+}
+
+func TestServer_ServeHTTP_GETOrg_Large(t *testing.T) {
+	var ps []*participant.Participant
+	for i := 0; i < 2500; i++ {
+		ps = append(ps, &participant.Participant{ID: aws.String(strconv.Itoa(i)), Org: aws.String("OrgA"), Score: aws.Int(i % 10)})
+	}
+
+	srvr := New(router.New(), &test.RepoMock{
+		QueryHandler: func(ctx context.Context, q participant.Query) (*participant.Page, participant.Error) {
+			// Like the DynamoDB repository, reading every match at once fails for large queries.
+			if q.Limit == 0 {
+				return nil, participant.ErrQueryTooLarge
+			}
+			return q.Paginate(ps)
+		},
+	})
+
+	req, _ := http.NewRequest(http.MethodGet, "/org/OrgA", nil)
+	res := httptest.NewRecorder()
+	srvr.ServeHTTP(res, req)
+
+	assert.Equal(t, http.StatusOK, res.Code)
+
+	var team leaderboard.Team
+	assert.NoError(t, json.NewDecoder(res.Body).Decode(&team))
+	assert.Equal(t, 2500, team.Participants)
+	assert.Equal(t, 250*45, team.Total)
+	assert.Equal(t, 9, *team.Best)
+
+	req, _ = http.NewRequest(http.MethodGet, "/org/OrgA/leaderboard", nil)
+	res = httptest.NewRecorder()
+	srvr.ServeHTTP(res, req)
+
+	assert.Equal(t, http.StatusUnprocessableEntity, res.Code)
+	assert.Equal(t, problemContentType, res.Header().Get("Content-Type"))
+}
+
+func TestServer_ServeHTTP_GETOrg_NotFound(t *testing.T) {
+	req, _ := http.NewRequest(http.MethodGet, "/org/NoOrg", nil)
+	res := httptest.NewRecorder()
+
+	srvr := New(router.New(), orgParticipantsMock())
+
+	srvr.ServeHTTP(res, req)
+
+	assert.Equal(t, http.StatusNotFound, res.Code)
+}
+
+func TestServer_ServeHTTP_GETOrgLeaderboard(t *testing.T) {
+	req, _ := http.NewRequest(http.MethodGet, "/org/OrgA/leaderboard", nil)
+	res := httptest.NewRecorder()
+
+	srvr := New(router.New(), orgParticipantsMock())
+
+	srvr.ServeHTTP(res, req)
+
+	assert.Equal(t, http.StatusOK, res.Code)
+
+	var entries []struct {
+		Rank int    `json:"rank"`
+		ID   string `json:"id"`
+	}
+	assert.NoError(t, json.NewDecoder(res.Body).Decode(&entries))
+	assert.Len(t, entries, 2)
+	assert.Equal(t, "a2", entries[0].ID)
+	assert.Equal(t, "a1", entries[1].ID)
+}
+
+func TestServer_ServeHTTP_GETLeaderboard_Public(t *testing.T) {
+	ps := []*participant.Participant{{
+		ID:      aws.String("annie"),
+		Name:    aws.String("Annie"),
+		Email:   aws.String("annie@testson.com"),
+		Phone:   aws.String("12345678"),
+		Org:     aws.String("OrgA"),
+		Comment: aws.String("Call after five"),
+		Consent: aws.Bool(true),
+		Score:   aws.Int(3),
+	}}
+	mock := &test.RepoMock{
+		GetAllHandler: func(ctx context.Context) ([]*participant.Participant, participant.Error) {
+			return ps, nil
+		},
+		QueryHandler: func(ctx context.Context, q participant.Query) (*participant.Page, participant.Error) {
+			return q.Paginate(ps)
+		},
+	}
+
+	for _, path := range []string{"/leaderboard", "/org/OrgA/leaderboard"} {
+		t.Run(path, func(t *testing.T) {
+			req, _ := http.NewRequest(http.MethodGet, path, nil)
+			res := httptest.NewRecorder()
+
+			New(router.New(), mock).ServeHTTP(res, req)
+
+			assert.Equal(t, http.StatusOK, res.Code)
+			assert.JSONEq(t, `[{"rank":1,"id":"annie","name":"Annie","org":"OrgA","score":3}]`, res.Body.String())
+		})
+	}
+}
+
+func TestServer_ServeHTTP_POSTParticipant_Invalid(t *testing.T) {
+	req, _ := http.NewRequest(http.MethodPost, "/participant", bytes.NewBufferString(`{"name":"","email":"invalid","score":-1}`))
+	res := httptest.NewRecorder()
+
+	srvr := New(router.New(), &test.RepoMock{})
+
+	srvr.ServeHTTP(res, req)
+
+	assert.Equal(t, http.StatusUnprocessableEntity, res.Code)
+	assert.Equal(t, problemContentType, res.Header().Get("Content-Type"))
+
+	var problem Problem
+	assert.NoError(t, json.NewDecoder(res.Body).Decode(&problem))
+	assert.Equal(t, http.StatusUnprocessableEntity, problem.Status)
+	assert.Len(t, problem.Errors, 3)
+}
+
+func TestServer_ServeHTTP_PUTParticipant_UnknownField(t *testing.T) {
+	req, _ := http.NewRequest(http.MethodPut, "/participant/someId", bytes.NewBufferString(`{"score":1,"rank":1}`))
+	res := httptest.NewRecorder()
+
+	srvr := New(router.New(), &test.RepoMock{})
+
+	srvr.ServeHTTP(res, req)
+
+	assert.Equal(t, http.StatusBadRequest, res.Code)
+
+	var problem Problem
+	assert.NoError(t, json.NewDecoder(res.Body).Decode(&problem))
+	assert.Equal(t, []participant.FieldError{{Field: "rank", Message: "unknown field"}}, problem.Errors)
+}
+
+func TestServer_ServeHTTP_ProblemResponse(t *testing.T) {
+	req, _ := http.NewRequest(http.MethodGet, "/participant/someId", nil)
+	req.Header.Set(requestIDHeader, "someRequestId")
+	res := httptest.NewRecorder()
+
+	mock := &test.RepoMock{
+		GetHandler: func(ctx context.Context, id string) (*participant.Participant, participant.Error) {
+			return nil, participant.ErrNotExist
+		},
+	}
+	srvr := New(router.New(), mock)
+
+	srvr.ServeHTTP(res, req)
+
+	assert.Equal(t, http.StatusNotFound, res.Code)
+	assert.Equal(t, "someRequestId", res.Header().Get(requestIDHeader))
+
+	var problem Problem
+	assert.NoError(t, json.NewDecoder(res.Body).Decode(&problem))
+	assert.Equal(t, Problem{
+		Type:      "about:blank",
+		Title:     "Not Found",
+		Status:    http.StatusNotFound,
+		Detail:    "Resource not found",
+		Instance:  "/participant/someId",
+		RequestID: "someRequestId",
+	}, problem)
+}
+
+func TestServer_ServeHTTP_RequestIDGenerated(t *testing.T) {
+	req, _ := http.NewRequest(http.MethodGet, "/invalid", nil)
+	res := httptest.NewRecorder()
+
+	srvr := New(router.New(), &test.RepoMock{})
+
+	srvr.ServeHTTP(res, req)
+
+	var problem Problem
+	assert.NoError(t, json.NewDecoder(res.Body).Decode(&problem))
+	assert.NotEmpty(t, problem.RequestID)
+	assert.Equal(t, problem.RequestID, res.Header().Get(requestIDHeader))
+}
+
+func TestServer_ServeHTTP_MethodNotAllowed(t *testing.T) {
+	req, _ := http.NewRequest(http.MethodPatch, "/participant/someId", nil)
+	res := httptest.NewRecorder()
+
+	srvr := New(router.New(), &test.RepoMock{})
+
+	srvr.ServeHTTP(res, req)
+
+	assert.Equal(t, http.StatusMethodNotAllowed, res.Code)
+	assert.Equal(t, problemContentType, res.Header().Get("Content-Type"))
+	assert.NotEmpty(t, res.Header().Get("Allow"))
+}
+
+func TestServer_ServeHTTP_MalformedJSON(t *testing.T) {
+	for _, payload := range []string{`{"name":`, `[]`, `{"score":"high"}`} {
+		req, _ := http.NewRequest(http.MethodPost, "/participant", bytes.NewBufferString(payload))
+		res := httptest.NewRecorder()
+
+		srvr := New(router.New(), &test.RepoMock{})
+
+		srvr.ServeHTTP(res, req)
+
+		assert.Equal(t, http.StatusBadRequest, res.Code, payload)
+		assert.Equal(t, problemContentType, res.Header().Get("Content-Type"))
+	}
+}
+
+func TestServer_ServeHTTP_PayloadTooLarge(t *testing.T) {
+	payload := `{"name":"` + strings.Repeat("a", reqMaxBytes) + `"}`
+	req, _ := http.NewRequest(http.MethodPut, "/participant/someId", bytes.NewBufferString(payload))
+	res := httptest.NewRecorder()
+
+	srvr := New(router.New(), &test.RepoMock{})
+
+	srvr.ServeHTTP(res, req)
+
+	assert.Equal(t, http.StatusRequestEntityTooLarge, res.Code)
+	assert.Equal(t, problemContentType, res.Header().Get("Content-Type"))
+}
+
+func TestServer_ServeHTTP_GETParticipants_InvalidParams(t *testing.T) {
+	req, _ := http.NewRequest(http.MethodGet, "/participants?minScore=abc&sort=email", nil)
+	res := httptest.NewRecorder()
+
+	srvr := New(router.New(), &test.RepoMock{})
+
+	srvr.ServeHTTP(res, req)
+
+	assert.Equal(t, http.StatusBadRequest, res.Code)
+
+	var problem Problem
+	assert.NoError(t, json.NewDecoder(res.Body).Decode(&problem))
+	assert.Equal(t, []participant.FieldError{
+		{Field: "minScore", Message: "must be an integer"},
+		{Field: "sort", Message: "must be one of: score, name, created, updated"},
+	}, problem.Errors)
+}
+
+func TestServer_ServeHTTP_GETParticipant_ETag(t *testing.T) {
+	mock := &test.RepoMock{
+		GetHandler: func(ctx context.Context, id string) (*participant.Participant, participant.Error) {
+			return &participant.Participant{ID: aws.String(id), Version: aws.Int(3)}, nil
+		},
+	}
+	srvr := New(router.New(), mock)
+
+	tests := []struct {
+		name        string
+		ifNoneMatch string
+		code        int
+	}{
+		{"NoHeader", "", http.StatusOK},
+		{"Match", `"3"`, http.StatusNotModified},
+		{"WeakMatch", `"1", W/"3"`, http.StatusNotModified},
+		{"Any", "*", http.StatusNotModified},
+		{"Modified", `"2"`, http.StatusOK},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req, _ := http.NewRequest(http.MethodGet, "/participant/someId", nil)
+			if test.ifNoneMatch != "" {
+				req.Header.Set(ifNoneMatchHeader, test.ifNoneMatch)
+			}
+			res := httptest.NewRecorder()
+
+			srvr.ServeHTTP(res, req)
+
+			assert.Equal(t, test.code, res.Code)
+			assert.Equal(t, `"3"`, res.Header().Get(etagHeader))
+			if test.code == http.StatusNotModified {
+				assert.Empty(t, res.Body.String())
+			}
+		})
+	}
+}
+
+func TestServer_ServeHTTP_PUTParticipant_IfMatch(t *testing.T) {
+	srvr := New(router.New(), memory.New())
+
+	req, _ := http.NewRequest(http.MethodPost, "/participant", strings.NewReader(`{"name":"Test","email":"test@testson.com"}`))
+	res := httptest.NewRecorder()
+	srvr.ServeHTTP(res, req)
+	assert.Equal(t, http.StatusOK, res.Code)
+	assert.Equal(t, `"1"`, res.Header().Get(etagHeader))
+
+	var created participant.Participant
+	assert.NoError(t, json.NewDecoder(res.Body).Decode(&created))
+
+	put := func(ifMatch string, score int) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(http.MethodPut, "/participant/"+*created.ID, strings.NewReader(`{"score":`+strconv.Itoa(score)+`}`))
+		req.Header.Set(ifMatchHeader, ifMatch)
+		res := httptest.NewRecorder()
+		srvr.ServeHTTP(res, req)
+		return res
+	}
+
+	// First operator updates the version they read.
+	res = put(`"1"`, 10)
+	assert.Equal(t, http.StatusOK, res.Code)
+	assert.Equal(t, `"2"`, res.Header().Get(etagHeader))
+
+	// Second operator read the same version and is rejected.
+	res = put(`"1"`, 20)
+	assert.Equal(t, http.StatusPreconditionFailed, res.Code)
+	assert.Equal(t, problemContentType, res.Header().Get("Content-Type"))
+
+	// Weak tags never match If-Match.
+	res = put(`W/"2"`, 20)
+	assert.Equal(t, http.StatusPreconditionFailed, res.Code)
+
+	res = put(`"2"`, 20)
+	assert.Equal(t, http.StatusOK, res.Code)
+
+	res = put("*", 30)
+	assert.Equal(t, http.StatusOK, res.Code)
+	assert.Equal(t, `"4"`, res.Header().Get(etagHeader))
+}
+
+func TestServer_ServeHTTP_PUTParticipant_IfMatchNotFound(t *testing.T) {
+	srvr := New(router.New(), memory.New())
+
+	req, _ := http.NewRequest(http.MethodPut, "/participant/nonExisting", strings.NewReader(`{"score":1}`))
+	req.Header.Set(ifMatchHeader, `"1"`)
+	res := httptest.NewRecorder()
+
+	srvr.ServeHTTP(res, req)
+
+	assert.Equal(t, http.StatusNotFound, res.Code)
+}
+
+func TestServer_ServeHTTP_PUTParticipant_VersionConflict(t *testing.T) {
+	// The participant changes between the If-Match check and the save.
+	mock := &test.RepoMock{
+		GetHandler: func(ctx context.Context, id string) (*participant.Participant, participant.Error) {
+			return &participant.Participant{ID: aws.String(id), Version: aws.Int(1)}, nil
+		},
+		SaveHandler: func(ctx context.Context, p participant.Participant) (*participant.Participant, participant.Error) {
+			assert.Equal(t, aws.Int(1), p.Version)
+			return nil, participant.ErrVersionConflict
+		},
+	}
+	srvr := New(router.New(), mock)
+
+	req, _ := http.NewRequest(http.MethodPut, "/participant/someId", strings.NewReader(`{"score":1}`))
+	req.Header.Set(ifMatchHeader, `"1"`)
+	res := httptest.NewRecorder()
+
+	srvr.ServeHTTP(res, req)
+
+	assert.Equal(t, http.StatusPreconditionFailed, res.Code)
+}
+
+func TestServer_ServeHTTP_POSTParticipant_EmailTaken(t *testing.T) {
+	repo := memory.New()
+	existing, err := repo.Save(context.Background(), participant.Participant{Name: aws.String("Test Testson"), Email: aws.String("test@testson.com")})
+	assert.NoError(t, err)
+
+	srvr := New(router.New(), repo)
+
+	req, _ := http.NewRequest(http.MethodPost, "/participant", strings.NewReader(`{"name":"Test Testson","email":"TEST@testson.com"}`))
+	res := httptest.NewRecorder()
+
+	srvr.ServeHTTP(res, req)
+
+	assert.Equal(t, http.StatusConflict, res.Code)
+	assert.Equal(t, problemContentType, res.Header().Get("Content-Type"))
+	assert.Equal(t, "/participant/"+*existing.ID, res.Header().Get(locationHeader))
+}
+
+func TestServer_ServeHTTP_PUTParticipant_EmailTaken(t *testing.T) {
+	mock := &test.RepoMock{
+		SaveHandler: func(ctx context.Context, p participant.Participant) (*participant.Participant, participant.Error) {
+			return nil, &participant.EmailTakenError{ID: "existingId"}
+		},
+	}
+	srvr := New(router.New(), mock)
+
+	req, _ := http.NewRequest(http.MethodPut, "/participant/someId", strings.NewReader(`{"email":"test@testson.com"}`))
+	res := httptest.NewRecorder()
+
+	srvr.ServeHTTP(res, req)
+
+	assert.Equal(t, http.StatusConflict, res.Code)
+	assert.Equal(t, "/participant/existingId", res.Header().Get(locationHeader))
 }
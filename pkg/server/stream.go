@@ -0,0 +1,92 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/rejlersembriq/hooked/pkg/leaderboard"
+	"go.uber.org/zap"
+	"net/http"
+	"time"
+)
+
+// lastEventIDHeader is sent by EventSource clients when reconnecting, with the id of the last event received.
+const lastEventIDHeader = "Last-Event-ID"
+
+// streamRetry is how long clients wait before reconnecting, in milliseconds.
+const streamRetry = 3000
+
+// leaderboardStreamGET sends leaderboard updates as server-sent events, optionally for the participants of an event
+// and/or organisation. The stream starts with a snapshot, or the updates missed since the Last-Event-ID header, and a
+// comment is sent every heartbeat to keep the connection open. The stream ends if the client falls behind, and the
+// client reconnects with Last-Event-ID.
+func (s *Server) leaderboardStreamGET() http.HandlerFunc {
+	return func(res http.ResponseWriter, req *http.Request) {
+		flusher, ok := res.(http.Flusher)
+		if !ok {
+			sendProblem(http.StatusInternalServerError, "Streaming not supported").ServeHTTP(res, req)
+			return
+		}
+
+		var topic leaderboard.Topic
+		if event := req.URL.Query().Get("event"); event != "" {
+			topic.Event = &event
+		}
+		if org := req.URL.Query().Get("org"); org != "" {
+			topic.Org = &org
+		}
+
+		backlog, updates, cancel := s.hub.Subscribe(topic, req.Header.Get(lastEventIDHeader))
+		defer cancel()
+
+		res.Header().Set("Content-Type", "text/event-stream")
+		res.Header().Set("Cache-Control", "no-cache")
+		res.Header().Set("X-Accel-Buffering", "no")
+		res.WriteHeader(http.StatusOK)
+
+		if _, err := fmt.Fprintf(res, "retry: %d\n\n", streamRetry); err != nil {
+			return
+		}
+
+		for _, u := range backlog {
+			if err := writeUpdate(res, u); err != nil {
+				return
+			}
+		}
+		flusher.Flush()
+
+		heartbeat := time.NewTicker(s.liveHeartbeat)
+		defer heartbeat.Stop()
+
+		for {
+			select {
+			case <-req.Context().Done():
+				return
+			case u, ok := <-updates:
+				if !ok {
+					return
+				}
+
+				if err := writeUpdate(res, u); err != nil {
+					return
+				}
+			case <-heartbeat.C:
+				if _, err := fmt.Fprint(res, ": heartbeat\n\n"); err != nil {
+					return
+				}
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+// writeUpdate writes u as a server-sent event.
+func writeUpdate(res http.ResponseWriter, u leaderboard.Update) error {
+	data, err := json.Marshal(&u)
+	if err != nil {
+		zap.L().Error("Error marshalling update.", zap.String("error", err.Error()))
+		return err
+	}
+
+	_, err = fmt.Fprintf(res, "id: %d\nevent: %s\ndata: %s\n\n", u.ID, u.Type, data)
+	return err
+}
@@ -0,0 +1,186 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/rejlersembriq/hooked/pkg/leaderboard"
+	"github.com/rejlersembriq/hooked/pkg/participant"
+	"github.com/rejlersembriq/hooked/pkg/repository/memory"
+	"github.com/rejlersembriq/hooked/pkg/router"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// sseEvent is a server-sent event read by readEvent. Comments are returned as events with only comment set.
+type sseEvent struct {
+	id, event, data, comment string
+}
+
+func streamServer(t *testing.T, heartbeat time.Duration) (*httptest.Server, *participant.ObservedRepository) {
+	repo := participant.Observe(memory.New())
+
+	ps, err := repo.GetAll(context.Background())
+	assert.NoError(t, err)
+
+	hub := leaderboard.NewHub(ps, leaderboard.TieShared, 100)
+	repo.OnChange(hub.Apply)
+
+	return httptest.NewServer(New(router.New(), repo, WithLiveLeaderboard(hub, heartbeat))), repo
+}
+
+func openStream(t *testing.T, url, lastID string) (*bufio.Reader, func()) {
+	req, _ := http.NewRequest(http.MethodGet, url, nil)
+	if lastID != "" {
+		req.Header.Set(lastEventIDHeader, lastID)
+	}
+
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Error opening stream: %v", err)
+	}
+
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	assert.Equal(t, "text/event-stream", res.Header.Get("Content-Type"))
+
+	return bufio.NewReader(res.Body), func() { res.Body.Close() }
+}
+
+func parseID(t *testing.T, id string) uint64 {
+	t.Helper()
+
+	n, err := strconv.ParseUint(id, 10, 64)
+	if err != nil {
+		t.Fatalf("Invalid event id %q: %v", id, err)
+	}
+
+	return n
+}
+
+// readEvent reads the next event, skipping the retry field.
+func readEvent(t *testing.T, r *bufio.Reader) sseEvent {
+	t.Helper()
+
+	var e sseEvent
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			t.Fatalf("Error reading stream: %v", err)
+		}
+
+		line = strings.TrimSuffix(line, "\n")
+		switch {
+		case line == "":
+			if e != (sseEvent{}) {
+				return e
+			}
+		case strings.HasPrefix(line, ":"):
+			e.comment = strings.TrimSpace(line[1:])
+		case strings.HasPrefix(line, "id: "):
+			e.id = line[len("id: "):]
+		case strings.HasPrefix(line, "event: "):
+			e.event = line[len("event: "):]
+		case strings.HasPrefix(line, "data: "):
+			e.data = line[len("data: "):]
+		}
+	}
+}
+
+func TestServer_ServeHTTP_LeaderboardStream(t *testing.T) {
+	srv, repo := streamServer(t, time.Hour)
+	defer srv.Close()
+
+	annie, err := repo.Save(context.Background(), participant.Participant{Name: aws.String("Annie"), Email: aws.String("annie@testson.com"), Score: aws.Int(10)})
+	assert.NoError(t, err)
+
+	r, closeStream := openStream(t, srv.URL+"/leaderboard/stream", "")
+	defer closeStream()
+
+	e := readEvent(t, r)
+	assert.Equal(t, leaderboard.UpdateSnapshot, e.event)
+	start := parseID(t, e.id)
+
+	assert.NotContains(t, e.data, "annie@testson.com")
+
+	var u leaderboard.Update
+	assert.NoError(t, json.Unmarshal([]byte(e.data), &u))
+	if assert.Len(t, u.Entries, 1) {
+		assert.Equal(t, "Annie", *u.Entries[0].Name)
+	}
+
+	_, err = repo.Save(context.Background(), participant.Participant{Name: aws.String("Bob"), Email: aws.String("bob@testson.com"), Score: aws.Int(20)})
+	assert.NoError(t, err)
+
+	e = readEvent(t, r)
+	assert.Equal(t, leaderboard.UpdateRank, e.event)
+	assert.Equal(t, start+1, parseID(t, e.id))
+	assert.NoError(t, json.Unmarshal([]byte(e.data), &u))
+	assert.Equal(t, []string{"Bob", "Annie"}, []string{*u.Entries[0].Name, *u.Entries[1].Name})
+	assert.Equal(t, []int{1, 2}, []int{u.Entries[0].Rank, u.Entries[1].Rank})
+
+	assert.NoError(t, repo.Delete(context.Background(), *annie.ID))
+
+	e = readEvent(t, r)
+	assert.Equal(t, start+2, parseID(t, e.id))
+	assert.JSONEq(t, `{"entries":[],"removed":["`+*annie.ID+`"]}`, e.data)
+}
+
+func TestServer_ServeHTTP_LeaderboardStream_Reconnect(t *testing.T) {
+	srv, repo := streamServer(t, time.Hour)
+	defer srv.Close()
+
+	// The first stream is kept open, so the updates are kept for the topic.
+	first, closeFirst := openStream(t, srv.URL+"/leaderboard/stream", "")
+	defer closeFirst()
+	start := parseID(t, readEvent(t, first).id)
+
+	for _, name := range []string{"Annie", "Bob", "Carl"} {
+		_, err := repo.Save(context.Background(), participant.Participant{Name: aws.String(name), Email: aws.String(strings.ToLower(name) + "@testson.com"), Score: aws.Int(10)})
+		assert.NoError(t, err)
+	}
+
+	r, closeStream := openStream(t, srv.URL+"/leaderboard/stream", strconv.FormatUint(start+1, 10))
+	defer closeStream()
+
+	assert.Equal(t, start+2, parseID(t, readEvent(t, r).id))
+	e := readEvent(t, r)
+	assert.Equal(t, start+3, parseID(t, e.id))
+	assert.Equal(t, leaderboard.UpdateRank, e.event)
+}
+
+func TestServer_ServeHTTP_LeaderboardStream_Topic(t *testing.T) {
+	srv, repo := streamServer(t, time.Hour)
+	defer srv.Close()
+
+	r, closeStream := openStream(t, srv.URL+"/leaderboard/stream?org=acme", "")
+	defer closeStream()
+	assert.Equal(t, leaderboard.UpdateSnapshot, readEvent(t, r).event)
+
+	for _, org := range []string{"initech", "acme"} {
+		_, err := repo.Save(context.Background(), participant.Participant{Name: aws.String(org), Email: aws.String("annie@" + org + ".com"), Org: aws.String(org), Score: aws.Int(10)})
+		assert.NoError(t, err)
+	}
+
+	var u leaderboard.Update
+	assert.NoError(t, json.Unmarshal([]byte(readEvent(t, r).data), &u))
+	if assert.Len(t, u.Entries, 1) {
+		assert.Equal(t, "acme", *u.Entries[0].Org)
+	}
+}
+
+func TestServer_ServeHTTP_LeaderboardStream_Heartbeat(t *testing.T) {
+	srv, _ := streamServer(t, 10*time.Millisecond)
+	defer srv.Close()
+
+	r, closeStream := openStream(t, srv.URL+"/leaderboard/stream", "")
+	defer closeStream()
+
+	assert.Equal(t, leaderboard.UpdateSnapshot, readEvent(t, r).event)
+	assert.Equal(t, sseEvent{comment: "heartbeat"}, readEvent(t, r))
+}
@@ -0,0 +1,230 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/google/uuid"
+	"github.com/rejlersembriq/hooked/pkg/participant"
+	"github.com/rejlersembriq/hooked/pkg/router"
+	"github.com/rejlersembriq/hooked/pkg/webhook"
+	"go.uber.org/zap"
+	"net/http"
+	"time"
+)
+
+// webhooksGET returns all hooks, newest first, without their secrets.
+func (s *Server) webhooksGET() http.HandlerFunc {
+	return func(res http.ResponseWriter, req *http.Request) {
+		hs, err := s.hooks.List(req.Context())
+		if err != nil {
+			zap.L().Error("Error retrieveing resources.", zap.String("error", err.Error()))
+			sendProblem(http.StatusInternalServerError, "Error retrieving resources").ServeHTTP(res, req)
+			return
+		}
+
+		for _, h := range hs {
+			h.Secret = ""
+		}
+
+		sendJSON(&hs).ServeHTTP(res, req)
+	}
+}
+
+// webhookPOST creates a hook. The response is the only one holding the secret, generated if none is given.
+func (s *Server) webhookPOST() http.HandlerFunc {
+	return func(res http.ResponseWriter, req *http.Request) {
+		defer req.Body.Close()
+
+		var wr webhook.Request
+		dec := json.NewDecoder(req.Body)
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&wr); err != nil {
+			decodeProblem(err).ServeHTTP(res, req)
+			return
+		}
+
+		if err := webhook.Validate(wr); err != nil {
+			var verr *participant.ValidationError
+			if errors.As(err, &verr) {
+				sendValidationProblem(http.StatusUnprocessableEntity, "Invalid webhook", verr).ServeHTTP(res, req)
+				return
+			}
+
+			sendProblem(http.StatusUnprocessableEntity, err.Error()).ServeHTTP(res, req)
+			return
+		}
+
+		if err := s.dispatcher.CheckURL(req.Context(), wr.URL); err != nil {
+			msg := "host can't be resolved"
+			if errors.Is(err, webhook.ErrForbiddenAddress) {
+				msg = "must not resolve to a loopback, link-local or private address"
+			}
+
+			sendProblem(http.StatusUnprocessableEntity, "Invalid webhook", participant.FieldError{Field: "url", Message: msg}).ServeHTTP(res, req)
+			return
+		}
+
+		secret := wr.Secret
+		if secret == "" {
+			var err error
+			if secret, err = webhook.NewSecret(); err != nil {
+				zap.L().Error("Error generating webhook secret.", zap.String("error", err.Error()))
+				sendProblem(http.StatusInternalServerError, "Error generating webhook secret").ServeHTTP(res, req)
+				return
+			}
+		}
+
+		saved, err := s.hooks.Create(req.Context(), webhook.Hook{
+			ID:      uuid.New().String(),
+			URL:     wr.URL,
+			Events:  wr.Events,
+			Secret:  secret,
+			Created: time.Now(),
+		})
+		if err != nil {
+			zap.L().Error("Error persisting resource.", zap.String("error", err.Error()))
+			sendProblem(http.StatusInternalServerError, "Error persisting resource").ServeHTTP(res, req)
+			return
+		}
+
+		zap.L().Info("Created webhook.", zap.String("id", saved.ID), zap.String("url", saved.URL), zap.Strings("events", saved.Events))
+		sendJSON(&saved).ServeHTTP(res, req)
+	}
+}
+
+func (s *Server) webhookGET() http.HandlerFunc {
+	return func(res http.ResponseWriter, req *http.Request) {
+		id, exists := router.GetParam(req.Context(), "id")
+		if !exists {
+			sendProblem(http.StatusInternalServerError, "Unable to get request parameter").ServeHTTP(res, req)
+			return
+		}
+
+		h, ok := s.getHook(res, req, id)
+		if !ok {
+			return
+		}
+
+		h.Secret = ""
+		sendJSON(&h).ServeHTTP(res, req)
+	}
+}
+
+// webhookDELETE deletes a hook. Its deliveries are kept, and pending ones fail.
+func (s *Server) webhookDELETE() http.HandlerFunc {
+	return func(res http.ResponseWriter, req *http.Request) {
+		id, exists := router.GetParam(req.Context(), "id")
+		if !exists {
+			sendProblem(http.StatusInternalServerError, "Unable to get request parameter").ServeHTTP(res, req)
+			return
+		}
+
+		if err := s.hooks.Delete(req.Context(), id); err != nil {
+			if errors.Is(err, webhook.ErrNotExist) {
+				sendProblem(http.StatusNotFound, "Webhook "+id+" not found").ServeHTTP(res, req)
+				return
+			}
+
+			zap.L().Error("Error deleting resource.", zap.String("error", err.Error()))
+			sendProblem(http.StatusInternalServerError, "Error deleting resource").ServeHTTP(res, req)
+			return
+		}
+
+		zap.L().Info("Deleted webhook.", zap.String("id", id))
+		sendString("Deleted").ServeHTTP(res, req)
+	}
+}
+
+// webhookDeliveriesGET returns the deliveries of a hook, newest first.
+func (s *Server) webhookDeliveriesGET() http.HandlerFunc {
+	return func(res http.ResponseWriter, req *http.Request) {
+		id, exists := router.GetParam(req.Context(), "id")
+		if !exists {
+			sendProblem(http.StatusInternalServerError, "Unable to get request parameter").ServeHTTP(res, req)
+			return
+		}
+
+		if _, ok := s.getHook(res, req, id); !ok {
+			return
+		}
+
+		ds, err := s.deliveries.List(req.Context(), id)
+		if err != nil {
+			zap.L().Error("Error retrieveing resources.", zap.String("error", err.Error()))
+			sendProblem(http.StatusInternalServerError, "Error retrieving resources").ServeHTTP(res, req)
+			return
+		}
+
+		sendJSON(&ds).ServeHTTP(res, req)
+	}
+}
+
+func (s *Server) webhookDeliveryGET() http.HandlerFunc {
+	return func(res http.ResponseWriter, req *http.Request) {
+		id, idExists := router.GetParam(req.Context(), "id")
+		deliveryID, deliveryExists := router.GetParam(req.Context(), "delivery")
+		if !idExists || !deliveryExists {
+			sendProblem(http.StatusInternalServerError, "Unable to get request parameter").ServeHTTP(res, req)
+			return
+		}
+
+		d, err := s.deliveries.Get(req.Context(), id, deliveryID)
+		if err != nil {
+			if errors.Is(err, webhook.ErrNotExist) {
+				sendProblem(http.StatusNotFound, "Delivery "+deliveryID+" of webhook "+id+" not found").ServeHTTP(res, req)
+				return
+			}
+
+			zap.L().Error("Error retrieving resource.", zap.String("error", err.Error()))
+			sendProblem(http.StatusInternalServerError, "Error retrieving resource").ServeHTTP(res, req)
+			return
+		}
+
+		sendJSON(&d).ServeHTTP(res, req)
+	}
+}
+
+// webhookRedeliverPOST delivers the payload of a delivery again as a new delivery, see webhook.Dispatcher.Redeliver.
+// The new delivery is made in the background.
+func (s *Server) webhookRedeliverPOST() http.HandlerFunc {
+	return func(res http.ResponseWriter, req *http.Request) {
+		id, idExists := router.GetParam(req.Context(), "id")
+		deliveryID, deliveryExists := router.GetParam(req.Context(), "delivery")
+		if !idExists || !deliveryExists {
+			sendProblem(http.StatusInternalServerError, "Unable to get request parameter").ServeHTTP(res, req)
+			return
+		}
+
+		d, err := s.dispatcher.Redeliver(req.Context(), id, deliveryID)
+		if err != nil {
+			if errors.Is(err, webhook.ErrNotExist) {
+				sendProblem(http.StatusNotFound, "Delivery "+deliveryID+" of webhook "+id+" not found").ServeHTTP(res, req)
+				return
+			}
+
+			zap.L().Error("Error persisting resource.", zap.String("error", err.Error()))
+			sendProblem(http.StatusInternalServerError, "Error persisting resource").ServeHTTP(res, req)
+			return
+		}
+
+		zap.L().Info("Redelivering webhook delivery.", zap.String("hook", id), zap.String("delivery", deliveryID), zap.String("redelivery", d.ID))
+		sendJSON(&d).ServeHTTP(res, req)
+	}
+}
+
+// getHook retrieves a hook, responding with a problem if it fails.
+func (s *Server) getHook(res http.ResponseWriter, req *http.Request, id string) (*webhook.Hook, bool) {
+	h, err := s.hooks.Get(req.Context(), id)
+	if err != nil {
+		if errors.Is(err, webhook.ErrNotExist) {
+			sendProblem(http.StatusNotFound, "Webhook "+id+" not found").ServeHTTP(res, req)
+			return nil, false
+		}
+
+		zap.L().Error("Error retrieving resource.", zap.String("error", err.Error()))
+		sendProblem(http.StatusInternalServerError, "Error retrieving resource").ServeHTTP(res, req)
+		return nil, false
+	}
+
+	return h, true
+}
@@ -0,0 +1,238 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/rejlersembriq/hooked/pkg/participant"
+	"github.com/rejlersembriq/hooked/pkg/repository/memory"
+	"github.com/rejlersembriq/hooked/pkg/router"
+	"github.com/rejlersembriq/hooked/pkg/webhook"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// webhookReceiver records the bodies and signatures posted to it, answering with status.
+func webhookReceiver(status int) (*httptest.Server, chan *http.Request, chan []byte) {
+	requests := make(chan *http.Request, 10)
+	bodies := make(chan []byte, 10)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		body, _ := ioutil.ReadAll(req.Body)
+		requests <- req
+		bodies <- body
+		res.WriteHeader(status)
+	}))
+
+	return srv, requests, bodies
+}
+
+func webhookServer(t *testing.T) (*Server, *webhook.Dispatcher, *memory.DeliveryRepository) {
+	repo := participant.Observe(memory.New())
+	hooks := memory.NewWebhookRepository()
+	deliveries := memory.NewDeliveryRepository()
+
+	dispatcher := webhook.NewDispatcher(hooks, deliveries, webhook.WithRetries(2, time.Millisecond), webhook.WithPrivateAddresses())
+	repo.OnChange(dispatcher.Notify)
+
+	return New(router.New(), repo, WithWebhooks(hooks, deliveries, dispatcher)), dispatcher, deliveries
+}
+
+func createHook(t *testing.T, srvr *Server, payload string) webhook.Hook {
+	t.Helper()
+
+	res := eventRequest(srvr, http.MethodPost, "/admin/webhook", payload)
+	if res.Code != http.StatusOK {
+		t.Fatalf("Create webhook: %d %s", res.Code, res.Body.String())
+	}
+
+	var h webhook.Hook
+	assert.NoError(t, json.Unmarshal(res.Body.Bytes(), &h))
+
+	return h
+}
+
+// waitDeliveries polls the deliveries of a hook until count of them are done.
+func waitDeliveries(t *testing.T, repo webhook.DeliveryRepository, hook string, count int) []*webhook.Delivery {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		ds, err := repo.List(context.Background(), hook)
+		assert.NoError(t, err)
+
+		done := 0
+		for _, d := range ds {
+			if d.Status != webhook.StatusPending {
+				done++
+			}
+		}
+
+		if done >= count {
+			return ds
+		}
+
+		if time.Now().After(deadline) {
+			t.Fatalf("Deliveries of %s not done: %+v", hook, ds)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestServer_ServeHTTP_Webhooks(t *testing.T) {
+	srvr, dispatcher, deliveries := webhookServer(t)
+	defer dispatcher.Close()
+
+	receiver, requests, bodies := webhookReceiver(http.StatusOK)
+	defer receiver.Close()
+
+	h := createHook(t, srvr, `{"url":"`+receiver.URL+`","events":["participant.created"]}`)
+	assert.NotEmpty(t, h.ID)
+	assert.NotEmpty(t, h.Secret, "the secret should be generated and shown on creation")
+
+	res := eventRequest(srvr, http.MethodGet, "/admin/webhook/"+h.ID, "")
+	assert.Equal(t, http.StatusOK, res.Code)
+	assert.NotContains(t, res.Body.String(), "secret")
+
+	res = eventRequest(srvr, http.MethodGet, "/admin/webhooks", "")
+	assert.Equal(t, http.StatusOK, res.Code)
+	assert.NotContains(t, res.Body.String(), "secret")
+
+	var hs []webhook.Hook
+	assert.NoError(t, json.Unmarshal(res.Body.Bytes(), &hs))
+	if assert.Len(t, hs, 1) {
+		assert.Equal(t, h.ID, hs[0].ID)
+	}
+
+	res = eventRequest(srvr, http.MethodPost, "/participant", `{"name":"Annie","email":"annie@testson.com","org":"OrgA","score":10}`)
+	assert.Equal(t, http.StatusOK, res.Code)
+
+	select {
+	case req := <-requests:
+		body := <-bodies
+		assert.Equal(t, webhook.EventParticipantCreated, req.Header.Get(webhook.EventHeader))
+		assert.True(t, webhook.Verify(h.Secret, body, req.Header.Get(webhook.SignatureHeader)), "signature should verify")
+
+		var p webhook.Payload
+		assert.NoError(t, json.Unmarshal(body, &p))
+		assert.Equal(t, "Annie", *p.Participant.Name)
+	case <-time.After(5 * time.Second):
+		t.Fatal("Delivery not received")
+	}
+
+	ds := waitDeliveries(t, deliveries, h.ID, 1)
+
+	res = eventRequest(srvr, http.MethodGet, "/admin/webhook/"+h.ID+"/deliveries", "")
+	assert.Equal(t, http.StatusOK, res.Code)
+
+	var listed []webhook.Delivery
+	assert.NoError(t, json.Unmarshal(res.Body.Bytes(), &listed))
+	if assert.Len(t, listed, 1) {
+		assert.Equal(t, webhook.StatusSucceeded, listed[0].Status)
+		assert.Len(t, listed[0].Attempts, 1)
+	}
+
+	res = eventRequest(srvr, http.MethodGet, "/admin/webhook/"+h.ID+"/delivery/"+ds[0].ID, "")
+	assert.Equal(t, http.StatusOK, res.Code)
+
+	res = eventRequest(srvr, http.MethodDelete, "/admin/webhook/"+h.ID, "")
+	assert.Equal(t, http.StatusOK, res.Code)
+
+	res = eventRequest(srvr, http.MethodGet, "/admin/webhook/"+h.ID, "")
+	assert.Equal(t, http.StatusNotFound, res.Code)
+}
+
+func TestServer_ServeHTTP_WebhookRedeliver(t *testing.T) {
+	srvr, dispatcher, deliveries := webhookServer(t)
+	defer dispatcher.Close()
+
+	receiver, requests, bodies := webhookReceiver(http.StatusOK)
+	defer receiver.Close()
+
+	h := createHook(t, srvr, `{"url":"`+receiver.URL+`","events":["participant.created"],"secret":"0123456789abcdef"}`)
+	assert.Equal(t, "0123456789abcdef", h.Secret)
+
+	eventRequest(srvr, http.MethodPost, "/participant", `{"name":"Annie","email":"annie@testson.com","org":"OrgA","score":10}`)
+	<-requests
+	original := <-bodies
+
+	ds := waitDeliveries(t, deliveries, h.ID, 1)
+
+	res := eventRequest(srvr, http.MethodPost, "/admin/webhook/"+h.ID+"/delivery/"+ds[0].ID+"/redeliver", "")
+	assert.Equal(t, http.StatusOK, res.Code)
+
+	var d webhook.Delivery
+	assert.NoError(t, json.Unmarshal(res.Body.Bytes(), &d))
+	assert.Equal(t, ds[0].ID, d.Redelivery)
+	assert.NotEqual(t, ds[0].ID, d.ID)
+
+	select {
+	case req := <-requests:
+		assert.Equal(t, d.ID, req.Header.Get(webhook.DeliveryHeader))
+		assert.Equal(t, original, <-bodies, "the redelivery should have the original payload")
+	case <-time.After(5 * time.Second):
+		t.Fatal("Redelivery not received")
+	}
+
+	waitDeliveries(t, deliveries, h.ID, 2)
+
+	res = eventRequest(srvr, http.MethodPost, "/admin/webhook/"+h.ID+"/delivery/missing/redeliver", "")
+	assert.Equal(t, http.StatusNotFound, res.Code)
+}
+
+func TestServer_ServeHTTP_WebhookErrors(t *testing.T) {
+	srvr, dispatcher, _ := webhookServer(t)
+	defer dispatcher.Close()
+
+	tests := []struct {
+		name    string
+		method  string
+		path    string
+		payload string
+		code    int
+	}{
+		{"invalid webhook", http.MethodPost, "/admin/webhook", `{"url":"ftp://example.com","events":["participant.renamed"]}`, http.StatusUnprocessableEntity},
+		{"unknown field", http.MethodPost, "/admin/webhook", `{"url":"https://example.com","events":["participant.created"],"extra":1}`, http.StatusBadRequest},
+		{"missing webhook", http.MethodGet, "/admin/webhook/missing", "", http.StatusNotFound},
+		{"delete missing webhook", http.MethodDelete, "/admin/webhook/missing", "", http.StatusNotFound},
+		{"deliveries of missing webhook", http.MethodGet, "/admin/webhook/missing/deliveries", "", http.StatusNotFound},
+		{"missing delivery", http.MethodGet, "/admin/webhook/missing/delivery/missing", "", http.StatusNotFound},
+		{"redeliver missing webhook", http.MethodPost, "/admin/webhook/missing/delivery/missing/redeliver", "", http.StatusNotFound},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			res := eventRequest(srvr, test.method, test.path, test.payload)
+			assert.Equal(t, test.code, res.Code, res.Body.String())
+		})
+	}
+}
+
+func TestServer_ServeHTTP_POSTWebhook_ForbiddenAddress(t *testing.T) {
+	hooks := memory.NewWebhookRepository()
+	deliveries := memory.NewDeliveryRepository()
+	dispatcher := webhook.NewDispatcher(hooks, deliveries)
+	defer dispatcher.Close()
+
+	srvr := New(router.New(), memory.New(), WithWebhooks(hooks, deliveries, dispatcher))
+
+	for _, u := range []string{"http://127.0.0.1:8080/hooks", "http://169.254.169.254/latest/meta-data", "http://192.168.1.1/hooks"} {
+		res := eventRequest(srvr, http.MethodPost, "/admin/webhook", `{"url":"`+u+`","events":["participant.created"]}`)
+		assert.Equal(t, http.StatusUnprocessableEntity, res.Code, u)
+		assert.Contains(t, res.Body.String(), "private address", u)
+	}
+
+	hs, err := hooks.List(context.Background())
+	assert.NoError(t, err)
+	assert.Empty(t, hs)
+}
+
+func TestServer_ServeHTTP_WebhooksNotEnabled(t *testing.T) {
+	srvr := New(router.New(), memory.New())
+
+	res := eventRequest(srvr, http.MethodGet, "/admin/webhooks", "")
+	assert.Equal(t, http.StatusNotFound, res.Code)
+}
@@ -0,0 +1,339 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/rejlersembriq/hooked/pkg/leaderboard"
+	"github.com/rejlersembriq/hooked/pkg/websocket"
+	"go.uber.org/zap"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// WebSocket client message types.
+const (
+	wsSubscribe   = "subscribe"
+	wsUnsubscribe = "unsubscribe"
+	wsAck         = "ack"
+)
+
+// WebSocket server message types, besides the update types of leaderboard.Update.
+const (
+	wsSubscribed   = "subscribed"
+	wsUnsubscribed = "unsubscribed"
+	wsError        = "error"
+)
+
+const (
+	// wsReadLimit is the max size of a client message.
+	wsReadLimit = 4096
+	// wsWriteTimeout is how long a message may take to write before the connection is closed.
+	wsWriteTimeout = 10 * time.Second
+	// wsMaxSubscriptions is the max number of subscriptions on a connection.
+	wsMaxSubscriptions = 8
+	// wsAckWindow is the max number of updates sent on a subscription without being acknowledged.
+	wsAckWindow = 16
+)
+
+// wsRequest is a message from a WebSocket client.
+type wsRequest struct {
+	Type  string  `json:"type"`
+	ID    string  `json:"id"`
+	Event *string `json:"event,omitempty"`
+	Org   *string `json:"org,omitempty"`
+	Since *uint64 `json:"since,omitempty"`
+	Seq   *uint64 `json:"seq,omitempty"`
+}
+
+// wsMessage is a message to a WebSocket client about a subscription.
+type wsMessage struct {
+	Type   string  `json:"type"`
+	ID     string  `json:"id,omitempty"`
+	Event  *string `json:"event,omitempty"`
+	Org    *string `json:"org,omitempty"`
+	Detail string  `json:"detail,omitempty"`
+}
+
+// wsUpdate is a leaderboard update on a subscription.
+type wsUpdate struct {
+	Type string `json:"type"`
+	ID   string `json:"id"`
+	Seq  uint64 `json:"seq"`
+	leaderboard.Update
+}
+
+// wsClient is a WebSocket connection and its subscriptions.
+type wsClient struct {
+	conn          *websocket.Conn
+	hub           *leaderboard.Hub
+	subscriptions map[string]*wsSubscription
+	wg            sync.WaitGroup
+}
+
+// wsSubscription sends the updates of a topic to the client, see wsClient.pump.
+type wsSubscription struct {
+	id    string
+	topic leaderboard.Topic
+	stop  chan struct{}
+	done  chan struct{}
+
+	mu     sync.Mutex
+	acked  uint64
+	hasAck bool
+	// ackSignal wakes the pump when an ack is received.
+	ackSignal chan struct{}
+}
+
+// leaderboardWS sends leaderboard updates over a WebSocket. Clients subscribe to the leaderboard of an event and/or
+// organisation, and acknowledge the updates they've handled. Updates are held back while too many are
+// unacknowledged, and a client falling behind gets a new snapshot when it catches up. A ping is sent every heartbeat,
+// and the connection is closed if nothing is received for two.
+func (s *Server) leaderboardWS() http.HandlerFunc {
+	return func(res http.ResponseWriter, req *http.Request) {
+		conn, err := websocket.Upgrade(res, req)
+		if err == websocket.ErrBadHandshake {
+			sendProblem(http.StatusBadRequest, "Expected a WebSocket handshake").ServeHTTP(res, req)
+			return
+		}
+		if err != nil {
+			zap.L().Error("Error upgrading to WebSocket.", zap.String("error", err.Error()))
+			sendProblem(http.StatusInternalServerError, "").ServeHTTP(res, req)
+			return
+		}
+
+		c := &wsClient{conn: conn, hub: s.hub, subscriptions: make(map[string]*wsSubscription)}
+		defer c.close()
+
+		conn.SetReadLimit(wsReadLimit)
+
+		stopPing := make(chan struct{})
+		defer close(stopPing)
+		go c.ping(s.liveHeartbeat, stopPing)
+
+		for {
+			if err := conn.SetReadDeadline(time.Now().Add(2 * s.liveHeartbeat)); err != nil {
+				return
+			}
+
+			msgType, msg, err := conn.ReadMessage()
+			if err != nil {
+				var closeErr *websocket.CloseError
+				if !errors.As(err, &closeErr) {
+					zap.L().Debug("WebSocket read failed.", zap.String("error", err.Error()))
+					conn.WriteClose(websocket.CloseGoingAway, "")
+				}
+				return
+			}
+
+			switch msgType {
+			case websocket.TextMessage:
+				c.handle(msg)
+			case websocket.BinaryMessage:
+				conn.WriteClose(websocket.CloseInvalidPayload, "expected text message")
+				return
+			}
+		}
+	}
+}
+
+// handle handles a client message. Invalid messages are answered with an error, leaving the connection open.
+func (c *wsClient) handle(msg []byte) {
+	var r wsRequest
+	if err := json.Unmarshal(msg, &r); err != nil {
+		c.send(wsMessage{Type: wsError, Detail: "Invalid JSON"})
+		return
+	}
+
+	if r.ID == "" {
+		c.send(wsMessage{Type: wsError, Detail: "Missing id"})
+		return
+	}
+
+	switch r.Type {
+	case wsSubscribe:
+		if _, exists := c.subscriptions[r.ID]; exists {
+			c.send(wsMessage{Type: wsError, ID: r.ID, Detail: "Subscription already exists"})
+			return
+		}
+
+		if len(c.subscriptions) >= wsMaxSubscriptions {
+			c.send(wsMessage{Type: wsError, ID: r.ID, Detail: "Too many subscriptions"})
+			return
+		}
+
+		lastID := ""
+		if r.Since != nil {
+			lastID = strconv.FormatUint(*r.Since, 10)
+		}
+
+		sub := &wsSubscription{
+			id:        r.ID,
+			topic:     leaderboard.Topic{Event: r.Event, Org: r.Org},
+			stop:      make(chan struct{}),
+			done:      make(chan struct{}),
+			ackSignal: make(chan struct{}, 1),
+		}
+		c.subscriptions[r.ID] = sub
+
+		// Sent before starting the pump, so it's received before the updates.
+		c.send(wsMessage{Type: wsSubscribed, ID: r.ID, Event: r.Event, Org: r.Org})
+
+		c.wg.Add(1)
+		go c.pump(sub, lastID)
+	case wsUnsubscribe:
+		sub, exists := c.subscriptions[r.ID]
+		if !exists {
+			c.send(wsMessage{Type: wsError, ID: r.ID, Detail: "Unknown subscription"})
+			return
+		}
+
+		delete(c.subscriptions, r.ID)
+		close(sub.stop)
+		<-sub.done
+
+		c.send(wsMessage{Type: wsUnsubscribed, ID: r.ID})
+	case wsAck:
+		sub, exists := c.subscriptions[r.ID]
+		if !exists {
+			c.send(wsMessage{Type: wsError, ID: r.ID, Detail: "Unknown subscription"})
+			return
+		}
+
+		if r.Seq == nil {
+			c.send(wsMessage{Type: wsError, ID: r.ID, Detail: "Missing seq"})
+			return
+		}
+
+		sub.ack(*r.Seq)
+	default:
+		c.send(wsMessage{Type: wsError, ID: r.ID, Detail: "Unknown message type"})
+	}
+}
+
+// pump sends the updates of a subscription until it's stopped, starting after lastID. At most wsAckWindow updates
+// are sent before being acknowledged. Updates aren't read from the hub meanwhile, so a client too far behind is
+// dropped by the stream, and subscribes again from the last update sent once it has caught up.
+func (c *wsClient) pump(sub *wsSubscription, lastID string) {
+	defer c.wg.Done()
+	defer close(sub.done)
+
+	pending, updates, cancel := c.hub.Subscribe(sub.topic, lastID)
+	defer func() { cancel() }()
+
+	var sent []uint64
+	for {
+		if seq, ok := sub.takeAck(); ok {
+			i := 0
+			for i < len(sent) && sent[i] <= seq {
+				i++
+			}
+			sent = sent[i:]
+		}
+
+		if len(sent) >= wsAckWindow {
+			select {
+			case <-sub.stop:
+				return
+			case <-sub.ackSignal:
+			}
+			continue
+		}
+
+		if len(pending) > 0 {
+			u := pending[0]
+			pending = pending[1:]
+
+			if err := c.send(wsUpdate{Type: u.Type, ID: sub.id, Seq: u.ID, Update: u}); err != nil {
+				return
+			}
+
+			sent = append(sent, u.ID)
+			lastID = strconv.FormatUint(u.ID, 10)
+			continue
+		}
+
+		select {
+		case <-sub.stop:
+			return
+		case <-sub.ackSignal:
+		case u, ok := <-updates:
+			if !ok {
+				// Subscribing before cancelling keeps the topic, so the missed updates can be resumed.
+				cancelOld := cancel
+				pending, updates, cancel = c.hub.Subscribe(sub.topic, lastID)
+				cancelOld()
+				continue
+			}
+			pending = append(pending, u)
+		}
+	}
+}
+
+// ack records that the client has handled the updates up to seq.
+func (s *wsSubscription) ack(seq uint64) {
+	s.mu.Lock()
+	if !s.hasAck || seq > s.acked {
+		s.acked, s.hasAck = seq, true
+	}
+	s.mu.Unlock()
+
+	select {
+	case s.ackSignal <- struct{}{}:
+	default:
+	}
+}
+
+// takeAck returns the last ack received since the previous call.
+func (s *wsSubscription) takeAck() (uint64, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	seq, ok := s.acked, s.hasAck
+	s.acked, s.hasAck = 0, false
+	return seq, ok
+}
+
+// send writes v as a text message. The connection is closed if the write fails, ending the read loop.
+func (c *wsClient) send(v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		zap.L().Error("Error marshalling WebSocket message.", zap.String("error", err.Error()))
+		return err
+	}
+
+	if err := c.conn.WriteMessage(websocket.TextMessage, data, time.Now().Add(wsWriteTimeout)); err != nil {
+		c.conn.Close()
+		return err
+	}
+
+	return nil
+}
+
+// ping pings the client every heartbeat until stop is closed.
+func (c *wsClient) ping(heartbeat time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(heartbeat)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			if err := c.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(wsWriteTimeout)); err != nil {
+				c.conn.Close()
+				return
+			}
+		}
+	}
+}
+
+// close stops the subscriptions and closes the connection.
+func (c *wsClient) close() {
+	for _, sub := range c.subscriptions {
+		close(sub.stop)
+	}
+	c.conn.Close()
+	c.wg.Wait()
+}
@@ -0,0 +1,260 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/rejlersembriq/hooked/pkg/leaderboard"
+	"github.com/rejlersembriq/hooked/pkg/participant"
+	"github.com/rejlersembriq/hooked/pkg/websocket"
+	"github.com/stretchr/testify/assert"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// wsReceived is any message from the server.
+type wsReceived struct {
+	Type    string              `json:"type"`
+	ID      string              `json:"id"`
+	Seq     uint64              `json:"seq"`
+	Org     *string             `json:"org"`
+	Entries []leaderboard.Entry `json:"entries"`
+	Removed []string            `json:"removed"`
+	Detail  string              `json:"detail"`
+}
+
+func dialWS(t *testing.T, srv *httptest.Server) *websocket.Conn {
+	t.Helper()
+
+	conn, err := websocket.Dial("ws"+strings.TrimPrefix(srv.URL, "http")+"/leaderboard/ws", nil)
+	if err != nil {
+		t.Fatalf("Error dialing: %v", err)
+	}
+
+	return conn
+}
+
+func sendWS(t *testing.T, conn *websocket.Conn, msg string) {
+	t.Helper()
+
+	if err := conn.WriteMessage(websocket.TextMessage, []byte(msg), time.Now().Add(time.Second)); err != nil {
+		t.Fatalf("Error writing message: %v", err)
+	}
+}
+
+// readWS reads the next message, skipping pongs.
+func readWS(t *testing.T, conn *websocket.Conn) wsReceived {
+	t.Helper()
+
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	for {
+		msgType, msg, err := conn.ReadMessage()
+		if err != nil {
+			t.Fatalf("Error reading message: %v", err)
+		}
+
+		if msgType == websocket.PongMessage {
+			continue
+		}
+
+		var m wsReceived
+		if err := json.Unmarshal(msg, &m); err != nil {
+			t.Fatalf("Invalid message %s: %v", msg, err)
+		}
+		return m
+	}
+}
+
+func saveScore(t *testing.T, repo participant.Repository, name, org string, score int) {
+	t.Helper()
+
+	_, err := repo.Save(context.Background(), participant.Participant{Name: aws.String(name), Email: aws.String(strings.ToLower(name) + "@testson.com"), Org: aws.String(org), Score: aws.Int(score)})
+	assert.NoError(t, err)
+}
+
+func TestServer_ServeHTTP_LeaderboardWS(t *testing.T) {
+	srv, repo := streamServer(t, time.Hour)
+	defer srv.Close()
+
+	saveScore(t, repo, "Annie", "acme", 10)
+
+	conn := dialWS(t, srv)
+	defer conn.Close()
+
+	sendWS(t, conn, `{"type":"subscribe","id":"s1","org":"acme"}`)
+
+	m := readWS(t, conn)
+	assert.Equal(t, wsSubscribed, m.Type)
+	assert.Equal(t, "s1", m.ID)
+	assert.Equal(t, "acme", *m.Org)
+
+	m = readWS(t, conn)
+	assert.Equal(t, leaderboard.UpdateSnapshot, m.Type)
+	assert.Equal(t, "s1", m.ID)
+	if assert.Len(t, m.Entries, 1) {
+		assert.Equal(t, "Annie", *m.Entries[0].Name)
+	}
+	start := m.Seq
+
+	// Participants of other organisations aren't sent.
+	saveScore(t, repo, "Bob", "initech", 30)
+	saveScore(t, repo, "Carl", "acme", 20)
+
+	m = readWS(t, conn)
+	assert.Equal(t, leaderboard.UpdateRank, m.Type)
+	assert.Equal(t, start+2, m.Seq)
+	assert.Equal(t, []string{"Carl", "Annie"}, []string{*m.Entries[0].Name, *m.Entries[1].Name})
+	assert.Equal(t, []int{1, 2}, []int{m.Entries[0].Rank, m.Entries[1].Rank})
+
+	sendWS(t, conn, `{"type":"unsubscribe","id":"s1"}`)
+	assert.Equal(t, wsReceived{Type: wsUnsubscribed, ID: "s1"}, readWS(t, conn))
+
+	// No updates are sent after unsubscribing, so the error is the next message.
+	saveScore(t, repo, "Dina", "acme", 40)
+	sendWS(t, conn, `{"type":"ack","id":"s1","seq":1}`)
+	assert.Equal(t, wsReceived{Type: wsError, ID: "s1", Detail: "Unknown subscription"}, readWS(t, conn))
+
+	assert.NoError(t, conn.WriteClose(websocket.CloseNormal, ""))
+}
+
+func TestServer_ServeHTTP_LeaderboardWS_Ack(t *testing.T) {
+	srv, repo := streamServer(t, time.Hour)
+	defer srv.Close()
+
+	conn := dialWS(t, srv)
+	defer conn.Close()
+
+	sendWS(t, conn, `{"type":"subscribe","id":"s1"}`)
+	assert.Equal(t, wsSubscribed, readWS(t, conn).Type)
+	start := readWS(t, conn).Seq
+
+	// More changes than fit in the stream's buffer, so the subscription is dropped by the stream and resumed.
+	const changes = 50
+	for i := 1; i <= changes; i++ {
+		saveScore(t, repo, fmt.Sprintf("P%d", i), "acme", i)
+	}
+
+	// The snapshot and the first updates fill the ack window.
+	seqs := make([]uint64, 0, changes)
+	for i := 1; i < wsAckWindow; i++ {
+		seqs = append(seqs, readWS(t, conn).Seq)
+	}
+
+	// Nothing more is sent before the updates are acknowledged.
+	sendWS(t, conn, `{"type":"unknown","id":"s1"}`)
+	assert.Equal(t, wsError, readWS(t, conn).Type)
+
+	for len(seqs) < changes {
+		sendWS(t, conn, fmt.Sprintf(`{"type":"ack","id":"s1","seq":%d}`, seqs[len(seqs)-1]))
+
+		m := readWS(t, conn)
+		assert.Equal(t, leaderboard.UpdateRank, m.Type)
+		seqs = append(seqs, m.Seq)
+	}
+
+	for i, seq := range seqs {
+		assert.Equal(t, start+uint64(i)+1, seq)
+	}
+}
+
+func TestServer_ServeHTTP_LeaderboardWS_Errors(t *testing.T) {
+	srv, _ := streamServer(t, time.Hour)
+	defer srv.Close()
+
+	conn := dialWS(t, srv)
+	defer conn.Close()
+
+	tests := []struct {
+		msg  string
+		want wsReceived
+	}{
+		{`{`, wsReceived{Type: wsError, Detail: "Invalid JSON"}},
+		{`{"type":"subscribe"}`, wsReceived{Type: wsError, Detail: "Missing id"}},
+		{`{"type":"unsubscribe","id":"s1"}`, wsReceived{Type: wsError, ID: "s1", Detail: "Unknown subscription"}},
+		{`{"type":"shout","id":"s1"}`, wsReceived{Type: wsError, ID: "s1", Detail: "Unknown message type"}},
+	}
+
+	for _, test := range tests {
+		sendWS(t, conn, test.msg)
+		assert.Equal(t, test.want, readWS(t, conn), test.msg)
+	}
+
+	sendWS(t, conn, `{"type":"subscribe","id":"s1"}`)
+	assert.Equal(t, wsSubscribed, readWS(t, conn).Type)
+	assert.Equal(t, leaderboard.UpdateSnapshot, readWS(t, conn).Type)
+
+	sendWS(t, conn, `{"type":"subscribe","id":"s1"}`)
+	assert.Equal(t, wsReceived{Type: wsError, ID: "s1", Detail: "Subscription already exists"}, readWS(t, conn))
+
+	sendWS(t, conn, `{"type":"ack","id":"s1"}`)
+	assert.Equal(t, wsReceived{Type: wsError, ID: "s1", Detail: "Missing seq"}, readWS(t, conn))
+
+	for i := 2; i <= wsMaxSubscriptions; i++ {
+		sendWS(t, conn, fmt.Sprintf(`{"type":"subscribe","id":"s%d"}`, i))
+		assert.Equal(t, wsSubscribed, readWS(t, conn).Type)
+		assert.Equal(t, leaderboard.UpdateSnapshot, readWS(t, conn).Type)
+	}
+
+	sendWS(t, conn, `{"type":"subscribe","id":"s0"}`)
+	assert.Equal(t, wsReceived{Type: wsError, ID: "s0", Detail: "Too many subscriptions"}, readWS(t, conn))
+}
+
+func TestServer_ServeHTTP_LeaderboardWS_Heartbeat(t *testing.T) {
+	srv, _ := streamServer(t, 50*time.Millisecond)
+	defer srv.Close()
+
+	// A client answering pings stays connected past the read deadline of two heartbeats.
+	conn := dialWS(t, srv)
+	defer conn.Close()
+
+	received := make(chan []byte, 1)
+	go func() {
+		defer close(received)
+		for {
+			msgType, msg, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			if msgType == websocket.TextMessage {
+				received <- msg
+			}
+		}
+	}()
+
+	time.Sleep(300 * time.Millisecond)
+	sendWS(t, conn, `{"type":"subscribe","id":"s1"}`)
+	assert.Contains(t, string(<-received), wsSubscribed)
+
+	// A client not reading doesn't answer pings, and is disconnected. Answering the pings received before fails.
+	idle := dialWS(t, srv)
+	defer idle.Close()
+
+	time.Sleep(300 * time.Millisecond)
+	idle.SetReadDeadline(time.Now().Add(5 * time.Second))
+
+	var err error
+	for err == nil {
+		_, _, err = idle.ReadMessage()
+	}
+
+	var netErr net.Error
+	assert.False(t, errors.As(err, &netErr) && netErr.Timeout(), "expected the connection to be closed, got %v", err)
+}
+
+func TestServer_ServeHTTP_LeaderboardWS_BadHandshake(t *testing.T) {
+	srv, _ := streamServer(t, time.Hour)
+	defer srv.Close()
+
+	res, err := http.Get(srv.URL + "/leaderboard/ws")
+	assert.NoError(t, err)
+	defer res.Body.Close()
+
+	assert.Equal(t, http.StatusBadRequest, res.StatusCode)
+	assert.Equal(t, problemContentType, res.Header.Get("Content-Type"))
+}
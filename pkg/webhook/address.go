@@ -0,0 +1,116 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"syscall"
+	"time"
+)
+
+// ErrForbiddenAddress is returned for hooks on an address deliveries aren't made to, see Dispatcher.CheckURL.
+var ErrForbiddenAddress = errors.New("address not allowed for webhooks")
+
+// privateNetworks are the networks not reachable from the internet, besides loopback and link-local addresses.
+var privateNetworks = parseCIDRs(
+	"0.0.0.0/8",
+	"10.0.0.0/8",
+	"100.64.0.0/10",
+	"172.16.0.0/12",
+	"192.168.0.0/16",
+	"198.18.0.0/15",
+	"fc00::/7",
+)
+
+func parseCIDRs(cidrs ...string) []*net.IPNet {
+	nets := make([]*net.IPNet, len(cidrs))
+	for i, c := range cidrs {
+		_, n, err := net.ParseCIDR(c)
+		if err != nil {
+			panic(err)
+		}
+		nets[i] = n
+	}
+	return nets
+}
+
+// forbidden reports if deliveries must not be made to ip: a loopback, link-local, eg. the cloud metadata service at
+// 169.254.169.254, private, unspecified or multicast address. Otherwise a hook could reach services on the network of
+// the server.
+func forbidden(ip net.IP) bool {
+	if ip.IsLoopback() || ip.IsLinkLocalUnicast() || ip.IsUnspecified() || ip.IsMulticast() {
+		return true
+	}
+
+	for _, n := range privateNetworks {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// CheckURL resolves the host of a hook url, returning ErrForbiddenAddress if any of its addresses is forbidden. Hooks
+// are checked when created, and the address of every delivery is checked again when connecting, as the host might
+// resolve differently by then. Does nothing if the dispatcher allows private addresses, see WithPrivateAddresses.
+func (d *Dispatcher) CheckURL(ctx context.Context, rawurl string) error {
+	if d.allowPrivate {
+		return nil
+	}
+
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return err
+	}
+
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, u.Hostname())
+	if err != nil {
+		return err
+	}
+
+	for _, a := range addrs {
+		if forbidden(a.IP) {
+			return fmt.Errorf("%w: %s resolves to %s", ErrForbiddenAddress, u.Hostname(), a.IP)
+		}
+	}
+
+	return nil
+}
+
+// checkAddress is the net.Dialer Control refusing connections to forbidden addresses. It's called with the resolved
+// address, so a host resolving to another address than when the hook was created is caught too.
+func checkAddress(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+
+	if ip := net.ParseIP(host); ip == nil || forbidden(ip) {
+		return fmt.Errorf("%w: %s", ErrForbiddenAddress, host)
+	}
+
+	return nil
+}
+
+// newClient returns the client deliveries are made with, refusing forbidden addresses unless allowPrivate. It doesn't
+// use a proxy, as the address checked would be the proxy's.
+func newClient(allowPrivate bool) *http.Client {
+	dialer := &net.Dialer{Timeout: requestTimeout}
+	if !allowPrivate {
+		dialer.Control = checkAddress
+	}
+
+	return &http.Client{
+		Timeout: requestTimeout,
+		Transport: &http.Transport{
+			DialContext:         dialer.DialContext,
+			TLSHandshakeTimeout: requestTimeout,
+			MaxIdleConns:        100,
+			IdleConnTimeout:     90 * time.Second,
+		},
+	}
+}
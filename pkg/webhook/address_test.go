@@ -0,0 +1,76 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestForbidden(t *testing.T) {
+	tests := []struct {
+		ip        string
+		forbidden bool
+	}{
+		{"127.0.0.1", true},
+		{"::1", true},
+		{"169.254.169.254", true},
+		{"fe80::1", true},
+		{"10.1.2.3", true},
+		{"172.16.0.1", true},
+		{"192.168.1.1", true},
+		{"100.64.0.1", true},
+		{"fd00::1", true},
+		{"0.0.0.0", true},
+		{"::", true},
+		{"224.0.0.1", true},
+		{"::ffff:127.0.0.1", true},
+		{"93.184.216.34", false},
+		{"172.32.0.1", false},
+		{"2606:2800:220:1:248:1893:25c8:1946", false},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.forbidden, forbidden(net.ParseIP(test.ip)), test.ip)
+	}
+}
+
+func TestDispatcher_CheckURL(t *testing.T) {
+	d, _, _ := newTestDispatcher()
+	defer d.Close()
+	d.allowPrivate = false
+
+	for _, u := range []string{"http://127.0.0.1:8080/hooks", "http://[::1]/hooks", "http://169.254.169.254/latest/meta-data", "https://10.0.0.1/hooks", "http://localhost/hooks"} {
+		err := d.CheckURL(context.Background(), u)
+		assert.True(t, errors.Is(err, ErrForbiddenAddress), "%s: expected ErrForbiddenAddress, got %v", u, err)
+	}
+
+	assert.NoError(t, d.CheckURL(context.Background(), "https://93.184.216.34/hooks"))
+
+	d.allowPrivate = true
+	assert.NoError(t, d.CheckURL(context.Background(), "http://127.0.0.1:8080/hooks"))
+}
+
+func TestDispatcher_ForbiddenAddress(t *testing.T) {
+	srv, requests := receiver(http.StatusOK)
+	defer srv.Close()
+
+	// A hook created while its host resolved to a public address is still refused at delivery.
+	hr := &hookStub{hooks: map[string]Hook{"hook": {ID: "hook", URL: srv.URL, Events: Events, Secret: "0123456789abcdef"}}}
+	dr := &deliveryStub{deliveries: make(map[string]Delivery)}
+	d := NewDispatcher(hr, dr, WithRetries(1, time.Millisecond))
+	defer d.Close()
+
+	d.Notify(saved("a", true))
+
+	ds := waitDone(t, dr, "hook", 1)
+	assert.Equal(t, StatusFailed, ds[0].Status)
+	if assert.Len(t, ds[0].Attempts, 1) {
+		assert.True(t, strings.Contains(ds[0].Attempts[0].Error, ErrForbiddenAddress.Error()), ds[0].Attempts[0].Error)
+	}
+	assert.Empty(t, requests, "the receiver should not be reached")
+}
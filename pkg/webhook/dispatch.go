@@ -0,0 +1,367 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/google/uuid"
+	"github.com/rejlersembriq/hooked/pkg/participant"
+	"go.uber.org/zap"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Default delivery settings, see WithRetries. Failed deliveries are retried after 10 seconds, doubling the wait after
+// every attempt, giving up after about 21 minutes.
+const (
+	DefaultMaxAttempts = 8
+	DefaultBackoff     = 10 * time.Second
+)
+
+const (
+	// maxBackoff is the longest wait between attempts.
+	maxBackoff = time.Hour
+	// requestTimeout is how long a receiver has to answer, and the timeout for recording attempts.
+	requestTimeout = 10 * time.Second
+	// queueSize is the number of changes waiting to be dispatched before changes are dropped.
+	queueSize = 1000
+	// maxResponseBytes is how much of a response is read before closing it, to reuse the connection.
+	maxResponseBytes = 64 << 10
+	userAgent        = "hooked-webhook"
+)
+
+// Option configures a Dispatcher.
+type Option func(*Dispatcher)
+
+// WithClient sends deliveries with client instead of a client with a 10 second timeout refusing forbidden addresses.
+// The client is used as is, so it should refuse them too, see CheckURL.
+func WithClient(client *http.Client) Option {
+	return func(d *Dispatcher) {
+		d.client = client
+	}
+}
+
+// WithRetries makes up to maxAttempts attempts at every delivery, waiting backoff after the first failure and
+// doubling the wait after every following one, up to an hour.
+func WithRetries(maxAttempts int, backoff time.Duration) Option {
+	return func(d *Dispatcher) {
+		d.maxAttempts = maxAttempts
+		d.backoff = backoff
+	}
+}
+
+// WithPrivateAddresses allows hooks on loopback, link-local and private addresses, eg. for receivers on the network of
+// the server. They're refused by default, see CheckURL.
+func WithPrivateAddresses() Option {
+	return func(d *Dispatcher) {
+		d.allowPrivate = true
+	}
+}
+
+// Dispatcher delivers participant changes to the hooks subscribing to them. Changes are queued by Notify and
+// delivered in the background, recording every attempt. Deliveries are retried until a hook answers with a 2xx
+// status or the attempts run out. Safe for concurrent use.
+type Dispatcher struct {
+	hooks        Repository
+	deliveries   DeliveryRepository
+	client       *http.Client
+	allowPrivate bool
+	maxAttempts  int
+	backoff      time.Duration
+
+	changes chan participant.Change
+	ctx     context.Context
+	cancel  context.CancelFunc
+
+	mu     sync.Mutex
+	closed bool
+	wg     sync.WaitGroup
+}
+
+// NewDispatcher returns a dispatcher delivering to hooks, recording the deliveries. Close stops it.
+func NewDispatcher(hooks Repository, deliveries DeliveryRepository, opts ...Option) *Dispatcher {
+	d := &Dispatcher{
+		hooks:       hooks,
+		deliveries:  deliveries,
+		maxAttempts: DefaultMaxAttempts,
+		backoff:     DefaultBackoff,
+		changes:     make(chan participant.Change, queueSize),
+	}
+
+	for _, opt := range opts {
+		opt(d)
+	}
+
+	if d.client == nil {
+		d.client = newClient(d.allowPrivate)
+	}
+
+	d.ctx, d.cancel = context.WithCancel(context.Background())
+
+	d.wg.Add(1)
+	go d.run()
+
+	return d
+}
+
+// Notify queues a change for delivery, see participant.ObservedRepository.OnChange. The change is dropped and logged
+// if the queue is full, rather than holding up the request making it.
+func (d *Dispatcher) Notify(c participant.Change) {
+	select {
+	case d.changes <- c:
+	default:
+		zap.L().Error("Webhook queue full, dropping change.", zap.String("participant", c.ID))
+	}
+}
+
+// Redeliver delivers the payload of a delivery again, as a new delivery. Returns ErrNotExist if the hook or the
+// delivery is not found.
+func (d *Dispatcher) Redeliver(ctx context.Context, hook, id string) (*Delivery, error) {
+	if _, err := d.hooks.Get(ctx, hook); err != nil {
+		return nil, err
+	}
+
+	original, err := d.deliveries.Get(ctx, hook, id)
+	if err != nil {
+		return nil, err
+	}
+
+	saved, err := d.deliveries.Save(ctx, Delivery{
+		ID:         uuid.New().String(),
+		Hook:       hook,
+		Event:      original.Event,
+		Payload:    original.Payload,
+		Status:     StatusPending,
+		Attempts:   []Attempt{},
+		Redelivery: original.ID,
+		Created:    time.Now(),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	d.start(*saved)
+
+	return saved, nil
+}
+
+// Resume restarts the pending deliveries, eg. those interrupted by a restart.
+func (d *Dispatcher) Resume(ctx context.Context) error {
+	hooks, err := d.hooks.List(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, h := range hooks {
+		ds, err := d.deliveries.List(ctx, h.ID)
+		if err != nil {
+			return err
+		}
+
+		for _, del := range ds {
+			if del.Status == StatusPending {
+				d.start(*del)
+			}
+		}
+	}
+
+	return nil
+}
+
+// Close stops dispatching and waits for deliveries in progress to stop. Pending deliveries are left pending, see
+// Resume.
+func (d *Dispatcher) Close() {
+	d.mu.Lock()
+	d.closed = true
+	d.mu.Unlock()
+
+	d.cancel()
+	d.wg.Wait()
+}
+
+// run dispatches the queued changes until the dispatcher is closed.
+func (d *Dispatcher) run() {
+	defer d.wg.Done()
+
+	for {
+		select {
+		case <-d.ctx.Done():
+			return
+		case c := <-d.changes:
+			d.dispatch(c)
+		}
+	}
+}
+
+// dispatch creates a delivery of a change for every hook subscribing to it.
+func (d *Dispatcher) dispatch(c participant.Change) {
+	event := EventParticipantUpdated
+	switch {
+	case c.Op == participant.ChangeDeleted:
+		event = EventParticipantDeleted
+	case c.Created:
+		event = EventParticipantCreated
+	}
+
+	hooks, err := d.hooks.List(d.ctx)
+	if err != nil {
+		zap.L().Error("Error retrieving webhooks.", zap.String("error", err.Error()))
+		return
+	}
+
+	now := time.Now()
+	p := c.Participant
+	if p == nil {
+		id := c.ID
+		p = &participant.Participant{ID: &id}
+	}
+
+	payload, err := json.Marshal(&Payload{ID: uuid.New().String(), Event: event, Created: now, Participant: p})
+	if err != nil {
+		zap.L().Error("Error marshalling webhook payload.", zap.String("error", err.Error()))
+		return
+	}
+
+	for _, h := range hooks {
+		if !h.Subscribed(event) {
+			continue
+		}
+
+		saved, err := d.deliveries.Save(d.ctx, Delivery{
+			ID:       uuid.New().String(),
+			Hook:     h.ID,
+			Event:    event,
+			Payload:  payload,
+			Status:   StatusPending,
+			Attempts: []Attempt{},
+			Created:  now,
+		})
+		if err != nil {
+			zap.L().Error("Error persisting webhook delivery.", zap.String("hook", h.ID), zap.String("error", err.Error()))
+			continue
+		}
+
+		d.start(*saved)
+	}
+}
+
+// start delivers in the background, unless the dispatcher is closed.
+func (d *Dispatcher) start(del Delivery) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.closed {
+		return
+	}
+
+	d.wg.Add(1)
+	go d.deliver(del)
+}
+
+// deliver makes attempts at a delivery until it succeeds or fails, saving it after every attempt.
+func (d *Dispatcher) deliver(del Delivery) {
+	defer d.wg.Done()
+
+	for {
+		h, err := d.hooks.Get(d.ctx, del.Hook)
+		if err != nil && !errors.Is(err, ErrNotExist) {
+			if d.ctx.Err() != nil {
+				return
+			}
+
+			// Not counted as an attempt, as the hook wasn't tried.
+			zap.L().Error("Error retrieving webhook.", zap.String("hook", del.Hook), zap.String("error", err.Error()))
+		} else {
+			if err != nil {
+				del.Attempts = append(del.Attempts, Attempt{Time: time.Now(), Error: "webhook deleted"})
+				del.Status = StatusFailed
+			} else {
+				a := d.attempt(h, del)
+				if d.ctx.Err() != nil {
+					// Interrupted by Close, left for Resume.
+					return
+				}
+
+				del.Attempts = append(del.Attempts, a)
+				switch {
+				case a.StatusCode >= 200 && a.StatusCode < 300:
+					del.Status = StatusSucceeded
+				case len(del.Attempts) >= d.maxAttempts:
+					del.Status = StatusFailed
+				}
+			}
+
+			d.save(del)
+
+			if del.Status != StatusPending {
+				zap.L().Info("Webhook delivery done.", zap.String("hook", del.Hook), zap.String("delivery", del.ID), zap.String("status", del.Status), zap.Int("attempts", len(del.Attempts)))
+				return
+			}
+		}
+
+		timer := time.NewTimer(d.wait(len(del.Attempts)))
+		select {
+		case <-d.ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+	}
+}
+
+// save records a delivery. Failures are logged, as the delivery goes on regardless.
+func (d *Dispatcher) save(del Delivery) {
+	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+	defer cancel()
+
+	if _, err := d.deliveries.Save(ctx, del); err != nil {
+		zap.L().Error("Error persisting webhook delivery.", zap.String("delivery", del.ID), zap.String("error", err.Error()))
+	}
+}
+
+// wait returns how long to wait after attempts failed attempts.
+func (d *Dispatcher) wait(attempts int) time.Duration {
+	wait := d.backoff
+	for i := 1; i < attempts && wait < maxBackoff; i++ {
+		wait *= 2
+	}
+
+	if wait > maxBackoff {
+		return maxBackoff
+	}
+	return wait
+}
+
+// attempt posts the payload of a delivery to a hook.
+func (d *Dispatcher) attempt(h *Hook, del Delivery) Attempt {
+	a := Attempt{Time: time.Now()}
+
+	req, err := http.NewRequest(http.MethodPost, h.URL, bytes.NewReader(del.Payload))
+	if err != nil {
+		a.Error = err.Error()
+		return a
+	}
+	req = req.WithContext(d.ctx)
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", userAgent)
+	req.Header.Set(EventHeader, del.Event)
+	req.Header.Set(DeliveryHeader, del.ID)
+	req.Header.Set(SignatureHeader, Sign(h.Secret, del.Payload))
+
+	res, err := d.client.Do(req)
+	if err != nil {
+		a.Error = err.Error()
+		return a
+	}
+	defer res.Body.Close()
+
+	io.Copy(ioutil.Discard, io.LimitReader(res.Body, maxResponseBytes))
+
+	a.StatusCode = res.StatusCode
+	return a
+}
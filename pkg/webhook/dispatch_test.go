@@ -0,0 +1,373 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/rejlersembriq/hooked/pkg/participant"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// hookStub and deliveryStub store hooks and deliveries in memory.
+type hookStub struct {
+	mu    sync.Mutex
+	hooks map[string]Hook
+}
+
+func (r *hookStub) Create(ctx context.Context, h Hook) (*Hook, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.hooks[h.ID] = h.Clone()
+	return &h, nil
+}
+
+func (r *hookStub) Get(ctx context.Context, id string) (*Hook, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	h, exists := r.hooks[id]
+	if !exists {
+		return nil, ErrNotExist
+	}
+	cp := h.Clone()
+	return &cp, nil
+}
+
+func (r *hookStub) List(ctx context.Context) ([]*Hook, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	hs := make([]*Hook, 0, len(r.hooks))
+	for _, h := range r.hooks {
+		cp := h.Clone()
+		hs = append(hs, &cp)
+	}
+	return hs, nil
+}
+
+func (r *hookStub) Delete(ctx context.Context, id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.hooks, id)
+	return nil
+}
+
+type deliveryStub struct {
+	mu         sync.Mutex
+	deliveries map[string]Delivery
+}
+
+func (r *deliveryStub) Save(ctx context.Context, d Delivery) (*Delivery, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.deliveries[d.ID] = d.Clone()
+	return &d, nil
+}
+
+func (r *deliveryStub) Get(ctx context.Context, hook, id string) (*Delivery, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	d, exists := r.deliveries[id]
+	if !exists || d.Hook != hook {
+		return nil, ErrNotExist
+	}
+	cp := d.Clone()
+	return &cp, nil
+}
+
+func (r *deliveryStub) List(ctx context.Context, hook string) ([]*Delivery, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	ds := make([]*Delivery, 0)
+	for _, d := range r.deliveries {
+		if d.Hook == hook {
+			cp := d.Clone()
+			ds = append(ds, &cp)
+		}
+	}
+	SortDeliveries(ds)
+	return ds, nil
+}
+
+// received is a request received by a receiver.
+type received struct {
+	header http.Header
+	body   []byte
+}
+
+// receiver answers with the statuses in order, repeating the last, and records the requests.
+func receiver(statuses ...int) (*httptest.Server, chan received) {
+	requests := make(chan received, 100)
+
+	var mu sync.Mutex
+	srv := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		body, _ := ioutil.ReadAll(req.Body)
+		requests <- received{header: req.Header, body: body}
+
+		mu.Lock()
+		status := statuses[0]
+		if len(statuses) > 1 {
+			statuses = statuses[1:]
+		}
+		mu.Unlock()
+
+		res.WriteHeader(status)
+	}))
+
+	return srv, requests
+}
+
+func newTestDispatcher(hooks ...Hook) (*Dispatcher, *hookStub, *deliveryStub) {
+	hr := &hookStub{hooks: make(map[string]Hook)}
+	for _, h := range hooks {
+		hr.Create(context.Background(), h)
+	}
+	dr := &deliveryStub{deliveries: make(map[string]Delivery)}
+
+	return NewDispatcher(hr, dr, WithRetries(3, time.Millisecond), WithPrivateAddresses()), hr, dr
+}
+
+// waitDone waits for the deliveries of a hook to be done, returning them newest first.
+func waitDone(t *testing.T, repo DeliveryRepository, hook string, count int) []*Delivery {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		ds, err := repo.List(context.Background(), hook)
+		assert.NoError(t, err)
+
+		done := 0
+		for _, d := range ds {
+			if d.Status != StatusPending {
+				done++
+			}
+		}
+
+		if done >= count {
+			return ds
+		}
+
+		if time.Now().After(deadline) {
+			t.Fatalf("Deliveries of %s not done: %+v", hook, ds)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func saved(id string, created bool) participant.Change {
+	return participant.Change{
+		Op:          participant.ChangeSaved,
+		ID:          id,
+		Participant: &participant.Participant{ID: aws.String(id), Name: aws.String("Annie"), Score: aws.Int(10)},
+		Created:     created,
+	}
+}
+
+func TestDispatcher_Notify(t *testing.T) {
+	srv, requests := receiver(http.StatusNoContent)
+	defer srv.Close()
+
+	d, _, dr := newTestDispatcher(
+		Hook{ID: "created", URL: srv.URL, Events: []string{EventParticipantCreated}, Secret: "0123456789abcdef"},
+		Hook{ID: "all", URL: srv.URL + "/all", Events: Events, Secret: "fedcba9876543210"},
+	)
+	defer d.Close()
+
+	d.Notify(saved("a", true))
+	d.Notify(saved("a", false))
+	d.Notify(participant.Change{Op: participant.ChangeDeleted, ID: "a"})
+
+	ds := waitDone(t, dr, "all", 3)
+	assert.Equal(t, []string{StatusSucceeded, StatusSucceeded, StatusSucceeded}, []string{ds[0].Status, ds[1].Status, ds[2].Status})
+
+	ds = waitDone(t, dr, "created", 1)
+	if assert.Len(t, ds, 1) {
+		assert.Equal(t, EventParticipantCreated, ds[0].Event)
+		if assert.Len(t, ds[0].Attempts, 1) {
+			assert.Equal(t, http.StatusNoContent, ds[0].Attempts[0].StatusCode)
+		}
+	}
+
+	events := make(map[string]bool)
+	for i := 0; i < 4; i++ {
+		r := <-requests
+		secret := "fedcba9876543210"
+		if r.header.Get(DeliveryHeader) == ds[0].ID {
+			secret = "0123456789abcdef"
+		}
+
+		assert.True(t, Verify(secret, r.body, r.header.Get(SignatureHeader)), "signature of %s", r.body)
+		assert.Equal(t, "application/json", r.header.Get("Content-Type"))
+
+		var p Payload
+		assert.NoError(t, json.Unmarshal(r.body, &p))
+		assert.Equal(t, r.header.Get(EventHeader), p.Event)
+		assert.Equal(t, "a", *p.Participant.ID)
+		events[p.Event] = true
+
+		if p.Event == EventParticipantDeleted {
+			assert.Nil(t, p.Participant.Name)
+		} else {
+			assert.Equal(t, "Annie", *p.Participant.Name)
+		}
+	}
+	assert.Len(t, events, 3)
+}
+
+func TestDispatcher_Retries(t *testing.T) {
+	srv, _ := receiver(http.StatusInternalServerError, http.StatusBadGateway, http.StatusOK)
+	defer srv.Close()
+
+	d, _, dr := newTestDispatcher(Hook{ID: "hook", URL: srv.URL, Events: Events, Secret: "0123456789abcdef"})
+	defer d.Close()
+
+	d.Notify(saved("a", true))
+
+	ds := waitDone(t, dr, "hook", 1)
+	assert.Equal(t, StatusSucceeded, ds[0].Status)
+	if assert.Len(t, ds[0].Attempts, 3) {
+		assert.Equal(t, []int{500, 502, 200}, []int{ds[0].Attempts[0].StatusCode, ds[0].Attempts[1].StatusCode, ds[0].Attempts[2].StatusCode})
+	}
+}
+
+func TestDispatcher_Failed(t *testing.T) {
+	// Nothing listens on a closed server.
+	srv, _ := receiver(http.StatusOK)
+	srv.Close()
+
+	d, _, dr := newTestDispatcher(Hook{ID: "hook", URL: srv.URL, Events: Events, Secret: "0123456789abcdef"})
+	defer d.Close()
+
+	d.Notify(saved("a", true))
+
+	ds := waitDone(t, dr, "hook", 1)
+	assert.Equal(t, StatusFailed, ds[0].Status)
+	if assert.Len(t, ds[0].Attempts, 3) {
+		assert.Zero(t, ds[0].Attempts[2].StatusCode)
+		assert.NotEmpty(t, ds[0].Attempts[2].Error)
+	}
+}
+
+func TestDispatcher_Redeliver(t *testing.T) {
+	srv, requests := receiver(http.StatusInternalServerError, http.StatusInternalServerError, http.StatusInternalServerError, http.StatusOK)
+	defer srv.Close()
+
+	d, _, dr := newTestDispatcher(Hook{ID: "hook", URL: srv.URL, Events: Events, Secret: "0123456789abcdef"})
+	defer d.Close()
+
+	d.Notify(saved("a", true))
+	failed := waitDone(t, dr, "hook", 1)[0]
+	assert.Equal(t, StatusFailed, failed.Status)
+
+	redelivery, err := d.Redeliver(context.Background(), "hook", failed.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, failed.ID, redelivery.Redelivery)
+	assert.Equal(t, StatusPending, redelivery.Status)
+
+	ds := waitDone(t, dr, "hook", 2)
+	assert.Equal(t, redelivery.ID, ds[0].ID)
+	assert.Equal(t, StatusSucceeded, ds[0].Status)
+
+	// The payload is the same, so receivers can tell it's a change seen before.
+	var last received
+	for i := 0; i < 4; i++ {
+		last = <-requests
+	}
+	assert.Equal(t, string(failed.Payload), string(last.body))
+	assert.Equal(t, redelivery.ID, last.header.Get(DeliveryHeader))
+
+	_, err = d.Redeliver(context.Background(), "hook", "missing")
+	assert.True(t, errors.Is(err, ErrNotExist), "expected ErrNotExist, got %v", err)
+	_, err = d.Redeliver(context.Background(), "missing", failed.ID)
+	assert.True(t, errors.Is(err, ErrNotExist), "expected ErrNotExist, got %v", err)
+}
+
+func TestDispatcher_HookDeleted(t *testing.T) {
+	srv, _ := receiver(http.StatusInternalServerError)
+	defer srv.Close()
+
+	hr := &hookStub{hooks: map[string]Hook{"hook": {ID: "hook", URL: srv.URL, Events: Events, Secret: "0123456789abcdef"}}}
+	dr := &deliveryStub{deliveries: make(map[string]Delivery)}
+	d := NewDispatcher(hr, dr, WithRetries(3, 50*time.Millisecond), WithPrivateAddresses())
+	defer d.Close()
+
+	d.Notify(saved("a", true))
+
+	// Deleted while waiting to retry.
+	for {
+		ds, _ := dr.List(context.Background(), "hook")
+		if len(ds) == 1 && len(ds[0].Attempts) == 1 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	hr.Delete(context.Background(), "hook")
+
+	ds := waitDone(t, dr, "hook", 1)
+	assert.Equal(t, StatusFailed, ds[0].Status)
+	if assert.Len(t, ds[0].Attempts, 2) {
+		assert.Equal(t, "webhook deleted", ds[0].Attempts[1].Error)
+	}
+}
+
+func TestDispatcher_Resume(t *testing.T) {
+	srv, _ := receiver(http.StatusOK)
+	defer srv.Close()
+
+	d, _, dr := newTestDispatcher(Hook{ID: "hook", URL: srv.URL, Events: Events, Secret: "0123456789abcdef"})
+	defer d.Close()
+
+	dr.Save(context.Background(), Delivery{ID: "pending", Hook: "hook", Event: EventParticipantCreated, Payload: json.RawMessage(`{}`), Status: StatusPending, Attempts: []Attempt{{Error: "interrupted"}}})
+	dr.Save(context.Background(), Delivery{ID: "failed", Hook: "hook", Event: EventParticipantCreated, Payload: json.RawMessage(`{}`), Status: StatusFailed})
+
+	assert.NoError(t, d.Resume(context.Background()))
+
+	waitDone(t, dr, "hook", 2)
+
+	pending, _ := dr.Get(context.Background(), "hook", "pending")
+	assert.Equal(t, StatusSucceeded, pending.Status)
+	assert.Len(t, pending.Attempts, 2)
+
+	failed, _ := dr.Get(context.Background(), "hook", "failed")
+	assert.Equal(t, StatusFailed, failed.Status)
+	assert.Empty(t, failed.Attempts)
+}
+
+func TestDispatcher_Close(t *testing.T) {
+	srv, _ := receiver(http.StatusInternalServerError)
+	defer srv.Close()
+
+	hr := &hookStub{hooks: map[string]Hook{"hook": {ID: "hook", URL: srv.URL, Events: Events, Secret: "0123456789abcdef"}}}
+	dr := &deliveryStub{deliveries: make(map[string]Delivery)}
+	d := NewDispatcher(hr, dr, WithRetries(3, time.Hour), WithPrivateAddresses())
+
+	d.Notify(saved("a", true))
+	for {
+		ds, _ := dr.List(context.Background(), "hook")
+		if len(ds) == 1 && len(ds[0].Attempts) == 1 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	// Close doesn't wait for the retry, and leaves the delivery pending.
+	d.Close()
+
+	ds, _ := dr.List(context.Background(), "hook")
+	assert.Equal(t, StatusPending, ds[0].Status)
+}
+
+func TestDispatcher_wait(t *testing.T) {
+	d := &Dispatcher{backoff: 10 * time.Second}
+
+	assert.Equal(t, 10*time.Second, d.wait(1))
+	assert.Equal(t, 20*time.Second, d.wait(2))
+	assert.Equal(t, 80*time.Second, d.wait(4))
+	assert.Equal(t, maxBackoff, d.wait(10))
+	assert.Equal(t, maxBackoff, d.wait(1000))
+}
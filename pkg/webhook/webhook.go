@@ -0,0 +1,248 @@
+// Package webhook notifies subscribers of participant changes. Hooks subscribe a url to event types, and every
+// matching change is posted to the url as a signed JSON payload, see Sign. Deliveries are made asynchronously and
+// retried with exponential backoff, and every attempt is recorded, see Dispatcher.
+package webhook
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/rejlersembriq/hooked/pkg/participant"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Event types sent to hooks.
+const (
+	EventParticipantCreated = "participant.created"
+	EventParticipantUpdated = "participant.updated"
+	EventParticipantDeleted = "participant.deleted"
+)
+
+// Events lists the event types hooks can subscribe to.
+var Events = []string{EventParticipantCreated, EventParticipantUpdated, EventParticipantDeleted}
+
+// Delivery statuses.
+const (
+	// StatusPending is a delivery not yet succeeded, with attempts left.
+	StatusPending = "pending"
+	// StatusSucceeded is a delivery answered with a 2xx status.
+	StatusSucceeded = "succeeded"
+	// StatusFailed is a delivery out of attempts, or to a deleted hook.
+	StatusFailed = "failed"
+)
+
+// Headers sent with every delivery.
+const (
+	EventHeader     = "X-Hooked-Event"
+	DeliveryHeader  = "X-Hooked-Delivery"
+	SignatureHeader = "X-Hooked-Signature"
+)
+
+// signaturePrefix names the algorithm of a signature.
+const signaturePrefix = "sha256="
+
+// ErrNotExist should be returned when the requested hook or delivery is not found in the repository.
+var ErrNotExist = errors.New("webhook doesn't exist")
+
+// Validation limits.
+const (
+	MaxURLLength = 2048
+	// MinSecretLength and MaxSecretLength limit the length of a secret given by the caller.
+	MinSecretLength = 16
+	MaxSecretLength = 128
+)
+
+// secretBytes is the length of generated secrets, before hex encoding.
+const secretBytes = 32
+
+// Request describes a hook to create. A secret is generated if none is given.
+type Request struct {
+	URL    string   `json:"url"`
+	Events []string `json:"events"`
+	Secret string   `json:"secret,omitempty"`
+}
+
+// Hook subscribes a url to event types. Secret signs the payloads, and is only shown when the hook is created.
+type Hook struct {
+	ID      string    `json:"id"`
+	URL     string    `json:"url"`
+	Events  []string  `json:"events"`
+	Secret  string    `json:"secret,omitempty"`
+	Created time.Time `json:"created" dynamodbav:",unixtime"`
+}
+
+// Clone returns a copy of h where no field shares memory with h.
+func (h Hook) Clone() Hook {
+	if h.Events != nil {
+		h.Events = append([]string(nil), h.Events...)
+	}
+	return h
+}
+
+// Subscribed reports if the hook subscribes to an event type.
+func (h Hook) Subscribed(event string) bool {
+	for _, e := range h.Events {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+// Payload is the body posted to hooks. ID identifies the change, and is the same for every hook and redelivery, so
+// receivers can ignore changes seen before. Participant only holds the id of a deleted participant.
+type Payload struct {
+	ID          string                   `json:"id"`
+	Event       string                   `json:"event"`
+	Created     time.Time                `json:"created"`
+	Participant *participant.Participant `json:"participant"`
+}
+
+// Attempt is a single try at a delivery. StatusCode is 0 if no response was received or the hook was deleted, and
+// Error describes the failure.
+type Attempt struct {
+	Time       time.Time `json:"time" dynamodbav:",unixtime"`
+	StatusCode int       `json:"statusCode,omitempty"`
+	Error      string    `json:"error,omitempty"`
+}
+
+// Delivery is a payload sent to a hook, with its attempts in order. Redelivery is the id of the delivery it repeats.
+type Delivery struct {
+	ID         string          `json:"id"`
+	Hook       string          `json:"hook"`
+	Event      string          `json:"event"`
+	Payload    json.RawMessage `json:"payload"`
+	Status     string          `json:"status"`
+	Attempts   []Attempt       `json:"attempts"`
+	Redelivery string          `json:"redelivery,omitempty"`
+	Created    time.Time       `json:"created" dynamodbav:",unixtime"`
+}
+
+// Clone returns a copy of d where no field shares memory with d.
+func (d Delivery) Clone() Delivery {
+	if d.Payload != nil {
+		d.Payload = append(json.RawMessage(nil), d.Payload...)
+	}
+	if d.Attempts != nil {
+		d.Attempts = append([]Attempt(nil), d.Attempts...)
+	}
+	return d
+}
+
+// Repository persists hooks. Hooks can't be updated, so the secret deliveries are signed with never changes.
+type Repository interface {
+	Create(ctx context.Context, h Hook) (*Hook, error)
+	Get(ctx context.Context, id string) (*Hook, error)
+	// List returns all hooks, newest first.
+	List(ctx context.Context) ([]*Hook, error)
+	// Delete deletes a hook, keeping its deliveries. Returns ErrNotExist if the hook is not found.
+	Delete(ctx context.Context, id string) error
+}
+
+// DeliveryRepository persists the deliveries of hooks, keyed by hook and delivery id. A delivery is saved again after
+// every attempt, so it holds the whole history of its attempts.
+type DeliveryRepository interface {
+	// Save creates or replaces a delivery.
+	Save(ctx context.Context, d Delivery) (*Delivery, error)
+	Get(ctx context.Context, hook, id string) (*Delivery, error)
+	// List returns the deliveries of a hook, newest first.
+	List(ctx context.Context, hook string) ([]*Delivery, error)
+}
+
+// SortHooks orders hooks newest first. Hooks created at the same time are ordered by id.
+func SortHooks(hs []*Hook) {
+	sort.Slice(hs, func(i, j int) bool {
+		if !hs[i].Created.Equal(hs[j].Created) {
+			return hs[i].Created.After(hs[j].Created)
+		}
+		return hs[i].ID < hs[j].ID
+	})
+}
+
+// SortDeliveries orders deliveries newest first. Deliveries created at the same time are ordered by id.
+func SortDeliveries(ds []*Delivery) {
+	sort.Slice(ds, func(i, j int) bool {
+		if !ds[i].Created.Equal(ds[j].Created) {
+			return ds[i].Created.After(ds[j].Created)
+		}
+		return ds[i].ID < ds[j].ID
+	})
+}
+
+// Validate validates a hook request. Returns a *participant.ValidationError listing every invalid field.
+func Validate(r Request) error {
+	verr := &participant.ValidationError{}
+	add := func(field, msg string) {
+		verr.Fields = append(verr.Fields, participant.FieldError{Field: field, Message: msg})
+	}
+
+	if r.URL == "" {
+		add("url", "required")
+	} else if u, err := url.Parse(r.URL); err != nil || len(r.URL) > MaxURLLength || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		add("url", fmt.Sprintf("must be an absolute http or https url of at most %d characters", MaxURLLength))
+	}
+
+	if len(r.Events) == 0 {
+		add("events", "required")
+	}
+
+	seen := make(map[string]bool, len(r.Events))
+	for _, e := range r.Events {
+		switch {
+		case !knownEvent(e):
+			add("events", fmt.Sprintf("unknown event %q. Valid values: %s", e, strings.Join(Events, ", ")))
+		case seen[e]:
+			add("events", fmt.Sprintf("duplicate event %q", e))
+		}
+		seen[e] = true
+	}
+
+	if r.Secret != "" && (len(r.Secret) < MinSecretLength || len(r.Secret) > MaxSecretLength) {
+		add("secret", fmt.Sprintf("must be %d to %d characters", MinSecretLength, MaxSecretLength))
+	}
+
+	if len(verr.Fields) > 0 {
+		return verr
+	}
+
+	return nil
+}
+
+func knownEvent(event string) bool {
+	for _, e := range Events {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+// NewSecret returns a hex encoded secret from a cryptographically secure source.
+func NewSecret() (string, error) {
+	b := make([]byte, secretBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// Sign returns the signature of a payload, sent in the X-Hooked-Signature header: "sha256=" followed by the hex
+// encoded HMAC-SHA256 of the body, keyed with the secret of the hook.
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return signaturePrefix + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify reports if signature is the signature of body, comparing in constant time. Used by receivers.
+func Verify(secret string, body []byte, signature string) bool {
+	return hmac.Equal([]byte(Sign(secret, body)), []byte(signature))
+}
@@ -0,0 +1,79 @@
+package webhook
+
+import (
+	"errors"
+	"github.com/rejlersembriq/hooked/pkg/participant"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	valid := Request{URL: "https://example.com/hooks", Events: []string{EventParticipantCreated}}
+	assert.NoError(t, Validate(valid))
+
+	tests := []struct {
+		name   string
+		modify func(r *Request)
+		fields []string
+	}{
+		{"missing url", func(r *Request) { r.URL = "" }, []string{"url"}},
+		{"relative url", func(r *Request) { r.URL = "/hooks" }, []string{"url"}},
+		{"other scheme", func(r *Request) { r.URL = "ftp://example.com" }, []string{"url"}},
+		{"long url", func(r *Request) { r.URL = "https://example.com/" + strings.Repeat("a", MaxURLLength) }, []string{"url"}},
+		{"missing events", func(r *Request) { r.Events = nil }, []string{"events"}},
+		{"unknown event", func(r *Request) { r.Events = []string{"participant.renamed"} }, []string{"events"}},
+		{"duplicate event", func(r *Request) { r.Events = []string{EventParticipantCreated, EventParticipantCreated} }, []string{"events"}},
+		{"short secret", func(r *Request) { r.Secret = "secret" }, []string{"secret"}},
+		{"long secret", func(r *Request) { r.Secret = strings.Repeat("s", MaxSecretLength+1) }, []string{"secret"}},
+		{"several", func(r *Request) { r.URL, r.Events = "", nil }, []string{"url", "events"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := valid
+			test.modify(&r)
+
+			var verr *participant.ValidationError
+			if err := Validate(r); assert.True(t, errors.As(err, &verr), "expected *participant.ValidationError, got %v", err) {
+				fields := make([]string, len(verr.Fields))
+				for i, f := range verr.Fields {
+					fields[i] = f.Field
+				}
+				assert.Equal(t, test.fields, fields)
+			}
+		})
+	}
+}
+
+func TestSign(t *testing.T) {
+	// HMAC-SHA256 test case 2 from RFC 4231.
+	assert.Equal(t, "sha256=5bdcc146bf60754e6a042426089575c75a003f089d2739839dec58b964ec3843", Sign("Jefe", []byte("what do ya want for nothing?")))
+
+	body := []byte(`{"event":"participant.created"}`)
+	signature := Sign("0123456789abcdef", body)
+
+	assert.True(t, Verify("0123456789abcdef", body, signature))
+	assert.False(t, Verify("0123456789abcdeg", body, signature))
+	assert.False(t, Verify("0123456789abcdef", []byte(`{"event":"participant.deleted"}`), signature))
+	assert.False(t, Verify("0123456789abcdef", body, strings.TrimPrefix(signature, "sha256=")))
+}
+
+func TestNewSecret(t *testing.T) {
+	a, err := NewSecret()
+	assert.NoError(t, err)
+	b, err := NewSecret()
+	assert.NoError(t, err)
+
+	assert.Len(t, a, 2*secretBytes)
+	assert.NotEqual(t, a, b)
+	assert.NoError(t, Validate(Request{URL: "https://example.com", Events: Events, Secret: a}))
+}
+
+func TestHook_Subscribed(t *testing.T) {
+	h := Hook{Events: []string{EventParticipantCreated, EventParticipantDeleted}}
+
+	assert.True(t, h.Subscribed(EventParticipantCreated))
+	assert.False(t, h.Subscribed(EventParticipantUpdated))
+	assert.True(t, h.Subscribed(EventParticipantDeleted))
+}
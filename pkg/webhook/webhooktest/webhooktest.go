@@ -0,0 +1,333 @@
+// Package webhooktest checks the two stores of the dispatcher: hooks, which are only created and deleted, and their
+// deliveries, which are saved again after every attempt and must keep the full attempt history and payload.
+package webhooktest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/google/uuid"
+	"github.com/rejlersembriq/hooked/pkg/webhook"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+// Factory returns a repository without hooks, and a function removing it when the test is done.
+type Factory func(t *testing.T) (webhook.Repository, func())
+
+// DeliveryFactory returns a repository without deliveries, and a function removing it when the test is done.
+type DeliveryFactory func(t *testing.T) (webhook.DeliveryRepository, func())
+
+// Run tests a new hook repository from newRepo in every case.
+func Run(t *testing.T, newRepo Factory) {
+	tests := []struct {
+		name string
+		test func(t *testing.T, repo webhook.Repository)
+	}{
+		{"Create", testCreate},
+		{"GetNotExist", testGetNotExist},
+		{"List", testList},
+		{"Delete", testDelete},
+		{"ReturnedValuesNotShared", testReturnedValuesNotShared},
+		{"CancelledContext", testCancelledContext},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			repo, cleanup := newRepo(t)
+			defer cleanup()
+
+			test.test(t, repo)
+		})
+	}
+}
+
+// RunDeliveries tests a new delivery repository from newRepo in every case.
+func RunDeliveries(t *testing.T, newRepo DeliveryFactory) {
+	tests := []struct {
+		name string
+		test func(t *testing.T, repo webhook.DeliveryRepository)
+	}{
+		{"Save", testSaveDelivery},
+		{"SaveReplaces", testSaveDeliveryReplaces},
+		{"GetNotExist", testGetDeliveryNotExist},
+		{"List", testListDeliveries},
+		{"ReturnedValuesNotShared", testDeliveryReturnedValuesNotShared},
+		{"CancelledContext", testDeliveryCancelledContext},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			repo, cleanup := newRepo(t)
+			defer cleanup()
+
+			test.test(t, repo)
+		})
+	}
+}
+
+func timestamp(offset time.Duration) time.Time {
+	return time.Date(2020, 5, 1, 17, 0, 0, 0, time.UTC).Add(offset)
+}
+
+func newHook() webhook.Hook {
+	return webhook.Hook{
+		ID:      uuid.New().String(),
+		URL:     "https://example.com/hooks",
+		Events:  []string{webhook.EventParticipantCreated, webhook.EventParticipantDeleted},
+		Secret:  "0123456789abcdef",
+		Created: timestamp(0),
+	}
+}
+
+func mustCreate(t *testing.T, repo webhook.Repository, h webhook.Hook) *webhook.Hook {
+	t.Helper()
+
+	created, err := repo.Create(context.Background(), h)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	return created
+}
+
+func assertSameHook(t *testing.T, expected webhook.Hook, actual *webhook.Hook) {
+	t.Helper()
+
+	assert.Equal(t, expected.ID, actual.ID)
+	assert.Equal(t, expected.URL, actual.URL)
+	assert.Equal(t, expected.Events, actual.Events)
+	assert.Equal(t, expected.Secret, actual.Secret)
+	assert.True(t, expected.Created.Equal(actual.Created), "created: expected %v, got %v", expected.Created, actual.Created)
+}
+
+func testCreate(t *testing.T, repo webhook.Repository) {
+	h := newHook()
+
+	created := mustCreate(t, repo, h)
+	assertSameHook(t, h, created)
+
+	got, err := repo.Get(context.Background(), h.ID)
+	assert.NoError(t, err)
+	assertSameHook(t, h, got)
+}
+
+func testGetNotExist(t *testing.T, repo webhook.Repository) {
+	_, err := repo.Get(context.Background(), "missing")
+	assert.True(t, errors.Is(err, webhook.ErrNotExist), "expected ErrNotExist, got %v", err)
+}
+
+func testList(t *testing.T, repo webhook.Repository) {
+	hs, err := repo.List(context.Background())
+	assert.NoError(t, err)
+	assert.Empty(t, hs)
+
+	first := newHook()
+	second := newHook()
+	second.Created = timestamp(time.Hour)
+
+	mustCreate(t, repo, first)
+	mustCreate(t, repo, second)
+
+	hs, err = repo.List(context.Background())
+	assert.NoError(t, err)
+
+	if assert.Len(t, hs, 2) {
+		assert.Equal(t, []string{second.ID, first.ID}, []string{hs[0].ID, hs[1].ID})
+	}
+}
+
+func testDelete(t *testing.T, repo webhook.Repository) {
+	h := mustCreate(t, repo, newHook())
+
+	assert.NoError(t, repo.Delete(context.Background(), h.ID))
+
+	_, err := repo.Get(context.Background(), h.ID)
+	assert.True(t, errors.Is(err, webhook.ErrNotExist), "expected ErrNotExist, got %v", err)
+
+	err = repo.Delete(context.Background(), h.ID)
+	assert.True(t, errors.Is(err, webhook.ErrNotExist), "expected ErrNotExist, got %v", err)
+}
+
+func testReturnedValuesNotShared(t *testing.T, repo webhook.Repository) {
+	h := newHook()
+	created := mustCreate(t, repo, h)
+
+	created.Events[0] = "changed"
+	h.Events[1] = "changed"
+
+	got, err := repo.Get(context.Background(), h.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, newHook().Events, got.Events)
+
+	got.Events[0] = "changed"
+
+	hs, err := repo.List(context.Background())
+	assert.NoError(t, err)
+	if assert.Len(t, hs, 1) {
+		assert.Equal(t, newHook().Events, hs[0].Events)
+	}
+}
+
+func testCancelledContext(t *testing.T, repo webhook.Repository) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	h := newHook()
+
+	_, err := repo.Create(ctx, h)
+	assert.Equal(t, context.Canceled, err)
+
+	_, err = repo.Get(ctx, h.ID)
+	assert.Equal(t, context.Canceled, err)
+
+	_, err = repo.List(ctx)
+	assert.Equal(t, context.Canceled, err)
+
+	assert.Equal(t, context.Canceled, repo.Delete(ctx, h.ID))
+}
+
+func newDelivery(hook string) webhook.Delivery {
+	return webhook.Delivery{
+		ID:       uuid.New().String(),
+		Hook:     hook,
+		Event:    webhook.EventParticipantCreated,
+		Payload:  json.RawMessage(`{"id":"change","event":"participant.created"}`),
+		Status:   webhook.StatusPending,
+		Attempts: []webhook.Attempt{},
+		Created:  timestamp(0),
+	}
+}
+
+func mustSave(t *testing.T, repo webhook.DeliveryRepository, d webhook.Delivery) *webhook.Delivery {
+	t.Helper()
+
+	saved, err := repo.Save(context.Background(), d)
+	if err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	return saved
+}
+
+func assertSameDelivery(t *testing.T, expected webhook.Delivery, actual *webhook.Delivery) {
+	t.Helper()
+
+	assert.Equal(t, expected.ID, actual.ID)
+	assert.Equal(t, expected.Hook, actual.Hook)
+	assert.Equal(t, expected.Event, actual.Event)
+	assert.JSONEq(t, string(expected.Payload), string(actual.Payload))
+	assert.Equal(t, expected.Status, actual.Status)
+	assert.Equal(t, expected.Redelivery, actual.Redelivery)
+	assert.True(t, expected.Created.Equal(actual.Created), "created: expected %v, got %v", expected.Created, actual.Created)
+
+	if assert.Len(t, actual.Attempts, len(expected.Attempts)) {
+		for i, a := range expected.Attempts {
+			assert.True(t, a.Time.Equal(actual.Attempts[i].Time), "attempt time: expected %v, got %v", a.Time, actual.Attempts[i].Time)
+			assert.Equal(t, a.StatusCode, actual.Attempts[i].StatusCode)
+			assert.Equal(t, a.Error, actual.Attempts[i].Error)
+		}
+	}
+}
+
+func testSaveDelivery(t *testing.T, repo webhook.DeliveryRepository) {
+	d := newDelivery("hook")
+	d.Redelivery = "original"
+
+	saved := mustSave(t, repo, d)
+	assertSameDelivery(t, d, saved)
+
+	got, err := repo.Get(context.Background(), d.Hook, d.ID)
+	assert.NoError(t, err)
+	assertSameDelivery(t, d, got)
+}
+
+func testSaveDeliveryReplaces(t *testing.T, repo webhook.DeliveryRepository) {
+	d := newDelivery("hook")
+	mustSave(t, repo, d)
+
+	d.Attempts = append(d.Attempts,
+		webhook.Attempt{Time: timestamp(time.Second), Error: "connection refused"},
+		webhook.Attempt{Time: timestamp(time.Minute), StatusCode: 200},
+	)
+	d.Status = webhook.StatusSucceeded
+	mustSave(t, repo, d)
+
+	got, err := repo.Get(context.Background(), d.Hook, d.ID)
+	assert.NoError(t, err)
+	assertSameDelivery(t, d, got)
+}
+
+func testGetDeliveryNotExist(t *testing.T, repo webhook.DeliveryRepository) {
+	d := mustSave(t, repo, newDelivery("hook"))
+
+	_, err := repo.Get(context.Background(), "hook", "missing")
+	assert.True(t, errors.Is(err, webhook.ErrNotExist), "expected ErrNotExist, got %v", err)
+
+	_, err = repo.Get(context.Background(), "other", d.ID)
+	assert.True(t, errors.Is(err, webhook.ErrNotExist), "expected ErrNotExist, got %v", err)
+}
+
+func testListDeliveries(t *testing.T, repo webhook.DeliveryRepository) {
+	ds, err := repo.List(context.Background(), "hook")
+	assert.NoError(t, err)
+	assert.Empty(t, ds)
+
+	first := newDelivery("hook")
+	second := newDelivery("hook")
+	second.Created = timestamp(time.Hour)
+
+	mustSave(t, repo, first)
+	mustSave(t, repo, second)
+	mustSave(t, repo, newDelivery("other"))
+
+	ds, err = repo.List(context.Background(), "hook")
+	assert.NoError(t, err)
+
+	if assert.Len(t, ds, 2) {
+		assert.Equal(t, []string{second.ID, first.ID}, []string{ds[0].ID, ds[1].ID})
+	}
+}
+
+func testDeliveryReturnedValuesNotShared(t *testing.T, repo webhook.DeliveryRepository) {
+	d := newDelivery("hook")
+	d.Attempts = []webhook.Attempt{{Time: timestamp(0), StatusCode: 500}}
+
+	saved := mustSave(t, repo, d)
+	saved.Attempts[0].StatusCode = 200
+	saved.Payload[0] = '['
+	d.Attempts[0].Error = "changed"
+
+	got, err := repo.Get(context.Background(), d.Hook, d.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, 500, got.Attempts[0].StatusCode)
+	assert.Empty(t, got.Attempts[0].Error)
+	assert.JSONEq(t, string(newDelivery("hook").Payload), string(got.Payload))
+
+	got.Attempts[0].StatusCode = 200
+
+	ds, err := repo.List(context.Background(), d.Hook)
+	assert.NoError(t, err)
+	if assert.Len(t, ds, 1) {
+		assert.Equal(t, 500, ds[0].Attempts[0].StatusCode)
+	}
+}
+
+func testDeliveryCancelledContext(t *testing.T, repo webhook.DeliveryRepository) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	d := newDelivery("hook")
+
+	_, err := repo.Save(ctx, d)
+	assert.Equal(t, context.Canceled, err)
+
+	_, err = repo.Get(ctx, d.Hook, d.ID)
+	assert.Equal(t, context.Canceled, err)
+
+	_, err = repo.List(ctx, d.Hook)
+	assert.Equal(t, context.Canceled, err)
+}
@@ -0,0 +1,438 @@
+// Package websocket implements the parts of the WebSocket protocol (RFC 6455) used by the server: the opening
+// handshake, unfragmented writes, reading fragmented messages, ping, pong and close. Extensions and subprotocols
+// aren't supported.
+package websocket
+
+import (
+	"bufio"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// MessageType is the opcode of a frame.
+type MessageType int
+
+// Message types. Ping is answered and close is handled by the connection, so neither is returned by ReadMessage.
+const (
+	continuation  MessageType = 0
+	TextMessage   MessageType = 1
+	BinaryMessage MessageType = 2
+	CloseMessage  MessageType = 8
+	PingMessage   MessageType = 9
+	PongMessage   MessageType = 10
+)
+
+// Close codes, see RFC 6455 section 7.4.1.
+const (
+	CloseNormal          = 1000
+	CloseGoingAway       = 1001
+	CloseProtocolError   = 1002
+	CloseNoStatus        = 1005
+	CloseInvalidPayload  = 1007
+	ClosePolicyViolation = 1008
+	CloseTooBig          = 1009
+)
+
+// acceptGUID is appended to the client key to compute Sec-WebSocket-Accept.
+const acceptGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// maxControlPayload is the largest payload allowed in control frames.
+const maxControlPayload = 125
+
+// closeTimeout is how long the close frame may take to write when closing.
+const closeTimeout = time.Second
+
+// ErrBadHandshake is returned by Upgrade and Dial when the handshake is invalid.
+var ErrBadHandshake = errors.New("websocket: bad handshake")
+
+// ErrCloseSent is returned when writing after a close frame.
+var ErrCloseSent = errors.New("websocket: close sent")
+
+// CloseError is returned by ReadMessage when the peer closes the connection.
+type CloseError struct {
+	Code int
+	Text string
+}
+
+func (e *CloseError) Error() string {
+	return fmt.Sprintf("websocket: closed %d %s", e.Code, e.Text)
+}
+
+// Conn is a WebSocket connection. Writes are safe for concurrent use, and one goroutine may read concurrently.
+type Conn struct {
+	conn     net.Conn
+	r        *bufio.Reader
+	isServer bool
+
+	readLimit int64
+
+	writeMu    sync.Mutex
+	closeSent  bool
+	closeOnce  sync.Once
+	closeError error
+}
+
+// Upgrade performs the server side of the opening handshake, taking over the connection. Returns ErrBadHandshake
+// without writing a response if the request isn't a valid WebSocket handshake.
+func Upgrade(res http.ResponseWriter, req *http.Request) (*Conn, error) {
+	if req.Method != http.MethodGet ||
+		!headerContains(req.Header, "Connection", "upgrade") ||
+		!headerContains(req.Header, "Upgrade", "websocket") ||
+		req.Header.Get("Sec-WebSocket-Version") != "13" {
+		return nil, ErrBadHandshake
+	}
+
+	key := req.Header.Get("Sec-WebSocket-Key")
+	if decoded, err := base64.StdEncoding.DecodeString(key); err != nil || len(decoded) != 16 {
+		return nil, ErrBadHandshake
+	}
+
+	hijacker, ok := res.(http.Hijacker)
+	if !ok {
+		return nil, errors.New("websocket: response doesn't support hijacking")
+	}
+
+	conn, brw, err := hijacker.Hijack()
+	if err != nil {
+		return nil, err
+	}
+
+	response := "HTTP/1.1 101 Switching Protocols\r\n" +
+		"Upgrade: websocket\r\n" +
+		"Connection: Upgrade\r\n" +
+		"Sec-WebSocket-Accept: " + acceptKey(key) + "\r\n\r\n"
+
+	if _, err := conn.Write([]byte(response)); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	return &Conn{conn: conn, r: brw.Reader, isServer: true}, nil
+}
+
+// Dial opens a client connection to a ws:// url. Used by tests and tools, so TLS isn't supported.
+func Dial(url string, header http.Header) (*Conn, error) {
+	if !strings.HasPrefix(url, "ws://") {
+		return nil, fmt.Errorf("websocket: unsupported url %q", url)
+	}
+	url = "http://" + strings.TrimPrefix(url, "ws://")
+
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	for k, vs := range header {
+		req.Header[k] = vs
+	}
+
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	key := base64.StdEncoding.EncodeToString(nonce)
+
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	req.Header.Set("Sec-WebSocket-Key", key)
+
+	conn, err := net.Dial("tcp", req.URL.Host)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := req.Write(conn); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	r := bufio.NewReader(conn)
+	res, err := http.ReadResponse(r, req)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	res.Body.Close()
+
+	if res.StatusCode != http.StatusSwitchingProtocols || res.Header.Get("Sec-WebSocket-Accept") != acceptKey(key) {
+		conn.Close()
+		return nil, fmt.Errorf("%w: status %d", ErrBadHandshake, res.StatusCode)
+	}
+
+	return &Conn{conn: conn, r: r}, nil
+}
+
+func acceptKey(key string) string {
+	h := sha1.New()
+	h.Write([]byte(key + acceptGUID))
+	return base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
+
+// headerContains reports if the comma separated header contains token, ignoring case.
+func headerContains(h http.Header, name, token string) bool {
+	for _, v := range h[http.CanonicalHeaderKey(name)] {
+		for _, t := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(t), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// SetReadLimit sets the max size of a message. Larger messages close the connection with CloseTooBig. 0 means no
+// limit.
+func (c *Conn) SetReadLimit(limit int64) {
+	c.readLimit = limit
+}
+
+// SetReadDeadline sets the deadline for reading the next message.
+func (c *Conn) SetReadDeadline(t time.Time) error {
+	return c.conn.SetReadDeadline(t)
+}
+
+// RemoteAddr returns the address of the peer.
+func (c *Conn) RemoteAddr() net.Addr {
+	return c.conn.RemoteAddr()
+}
+
+// ReadMessage returns the next text, binary or pong message. Pings are answered. Returns a *CloseError when the
+// peer closes the connection, after answering the close.
+func (c *Conn) ReadMessage() (MessageType, []byte, error) {
+	var (
+		msgType MessageType
+		message []byte
+	)
+
+	for {
+		fin, opcode, payload, err := c.readFrame()
+		if err != nil {
+			return 0, nil, err
+		}
+
+		switch opcode {
+		case PingMessage:
+			if err := c.WriteControl(PongMessage, payload, time.Now().Add(closeTimeout)); err != nil {
+				return 0, nil, err
+			}
+			continue
+		case PongMessage:
+			// Pongs between the fragments of a message are dropped.
+			if msgType == 0 {
+				return PongMessage, payload, nil
+			}
+			continue
+		case CloseMessage:
+			return 0, nil, c.handleClose(payload)
+		case TextMessage, BinaryMessage:
+			if msgType != 0 {
+				return 0, nil, c.fail(CloseProtocolError, "expected continuation frame")
+			}
+			msgType = opcode
+		case continuation:
+			if msgType == 0 {
+				return 0, nil, c.fail(CloseProtocolError, "unexpected continuation frame")
+			}
+		default:
+			return 0, nil, c.fail(CloseProtocolError, "unknown opcode")
+		}
+
+		if c.readLimit > 0 && int64(len(message)+len(payload)) > c.readLimit {
+			return 0, nil, c.fail(CloseTooBig, "message too big")
+		}
+		message = append(message, payload...)
+
+		if fin {
+			if msgType == TextMessage && !utf8.Valid(message) {
+				return 0, nil, c.fail(CloseInvalidPayload, "invalid utf-8")
+			}
+			return msgType, message, nil
+		}
+	}
+}
+
+// readFrame reads a single frame, unmasking the payload.
+func (c *Conn) readFrame() (fin bool, opcode MessageType, payload []byte, err error) {
+	var head [2]byte
+	if _, err := io.ReadFull(c.r, head[:]); err != nil {
+		return false, 0, nil, err
+	}
+
+	fin = head[0]&0x80 != 0
+	opcode = MessageType(head[0] & 0x0f)
+	masked := head[1]&0x80 != 0
+	length := uint64(head[1] & 0x7f)
+
+	if head[0]&0x70 != 0 {
+		return false, 0, nil, c.fail(CloseProtocolError, "reserved bits set")
+	}
+
+	// Clients must mask their frames, and servers must not.
+	if masked != c.isServer {
+		return false, 0, nil, c.fail(CloseProtocolError, "invalid masking")
+	}
+
+	switch length {
+	case 126:
+		var ext [2]byte
+		if _, err := io.ReadFull(c.r, ext[:]); err != nil {
+			return false, 0, nil, err
+		}
+		length = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err := io.ReadFull(c.r, ext[:]); err != nil {
+			return false, 0, nil, err
+		}
+		length = binary.BigEndian.Uint64(ext[:])
+	}
+
+	if opcode >= CloseMessage && (length > maxControlPayload || !fin) {
+		return false, 0, nil, c.fail(CloseProtocolError, "invalid control frame")
+	}
+
+	if c.readLimit > 0 && length > uint64(c.readLimit) {
+		return false, 0, nil, c.fail(CloseTooBig, "message too big")
+	}
+
+	var mask [4]byte
+	if masked {
+		if _, err := io.ReadFull(c.r, mask[:]); err != nil {
+			return false, 0, nil, err
+		}
+	}
+
+	payload = make([]byte, length)
+	if _, err := io.ReadFull(c.r, payload); err != nil {
+		return false, 0, nil, err
+	}
+
+	if masked {
+		for i := range payload {
+			payload[i] ^= mask[i%4]
+		}
+	}
+
+	return fin, opcode, payload, nil
+}
+
+// handleClose answers a close frame, returning the *CloseError to report.
+func (c *Conn) handleClose(payload []byte) error {
+	closeErr := &CloseError{Code: CloseNoStatus}
+	if len(payload) >= 2 {
+		closeErr.Code = int(binary.BigEndian.Uint16(payload))
+		closeErr.Text = string(payload[2:])
+	}
+
+	c.WriteControl(CloseMessage, closePayload(closeErr.Code, ""), time.Now().Add(closeTimeout))
+
+	return closeErr
+}
+
+// fail closes the connection after a protocol violation by the peer.
+func (c *Conn) fail(code int, text string) error {
+	c.WriteControl(CloseMessage, closePayload(code, text), time.Now().Add(closeTimeout))
+	return &CloseError{Code: code, Text: text}
+}
+
+func closePayload(code int, text string) []byte {
+	if code == CloseNoStatus {
+		return nil
+	}
+
+	b := make([]byte, 2+len(text))
+	binary.BigEndian.PutUint16(b, uint16(code))
+	copy(b[2:], text)
+	return b
+}
+
+// WriteMessage writes a text or binary message as a single frame, failing if it takes longer than deadline. A zero
+// deadline means no deadline.
+func (c *Conn) WriteMessage(msgType MessageType, data []byte, deadline time.Time) error {
+	return c.writeFrame(msgType, data, deadline)
+}
+
+// WriteControl writes a ping, pong or close frame. Nothing is written after a close frame.
+func (c *Conn) WriteControl(msgType MessageType, data []byte, deadline time.Time) error {
+	if len(data) > maxControlPayload {
+		return errors.New("websocket: control payload too large")
+	}
+	return c.writeFrame(msgType, data, deadline)
+}
+
+// WriteClose writes a close frame with code and reason.
+func (c *Conn) WriteClose(code int, reason string) error {
+	return c.WriteControl(CloseMessage, closePayload(code, reason), time.Now().Add(closeTimeout))
+}
+
+func (c *Conn) writeFrame(opcode MessageType, payload []byte, deadline time.Time) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	if c.closeSent {
+		return ErrCloseSent
+	}
+
+	frame := make([]byte, 0, len(payload)+14)
+	frame = append(frame, 0x80|byte(opcode))
+
+	maskBit := byte(0)
+	if !c.isServer {
+		maskBit = 0x80
+	}
+
+	switch {
+	case len(payload) <= 125:
+		frame = append(frame, maskBit|byte(len(payload)))
+	case len(payload) <= 0xffff:
+		frame = append(frame, maskBit|126, 0, 0)
+		binary.BigEndian.PutUint16(frame[len(frame)-2:], uint16(len(payload)))
+	default:
+		frame = append(frame, maskBit|127, 0, 0, 0, 0, 0, 0, 0, 0)
+		binary.BigEndian.PutUint64(frame[len(frame)-8:], uint64(len(payload)))
+	}
+
+	if c.isServer {
+		frame = append(frame, payload...)
+	} else {
+		var mask [4]byte
+		if _, err := rand.Read(mask[:]); err != nil {
+			return err
+		}
+		frame = append(frame, mask[:]...)
+		for i, b := range payload {
+			frame = append(frame, b^mask[i%4])
+		}
+	}
+
+	if opcode == CloseMessage {
+		c.closeSent = true
+	}
+
+	if err := c.conn.SetWriteDeadline(deadline); err != nil {
+		return err
+	}
+
+	_, err := c.conn.Write(frame)
+	return err
+}
+
+// Close closes the underlying connection without a close frame. Call WriteClose first for a clean close.
+func (c *Conn) Close() error {
+	c.closeOnce.Do(func() {
+		c.closeError = c.conn.Close()
+	})
+	return c.closeError
+}
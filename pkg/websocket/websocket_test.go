@@ -0,0 +1,211 @@
+package websocket
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// echoServer echoes text and binary messages, reporting the error ending each connection on errs.
+func echoServer(t *testing.T, limit int64) (*httptest.Server, chan error) {
+	errs := make(chan error, 1)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		conn, err := Upgrade(res, req)
+		if err != nil {
+			http.Error(res, err.Error(), http.StatusBadRequest)
+			return
+		}
+		defer conn.Close()
+		conn.SetReadLimit(limit)
+
+		for {
+			msgType, msg, err := conn.ReadMessage()
+			if err != nil {
+				errs <- err
+				return
+			}
+
+			if msgType == PongMessage {
+				continue
+			}
+
+			if err := conn.WriteMessage(msgType, msg, time.Now().Add(time.Second)); err != nil {
+				errs <- err
+				return
+			}
+		}
+	}))
+
+	return srv, errs
+}
+
+func dial(t *testing.T, srv *httptest.Server) *Conn {
+	t.Helper()
+
+	conn, err := Dial("ws"+strings.TrimPrefix(srv.URL, "http"), nil)
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+
+	return conn
+}
+
+// rawFrame returns a masked client frame.
+func rawFrame(fin bool, opcode MessageType, payload []byte) []byte {
+	b0 := byte(opcode)
+	if fin {
+		b0 |= 0x80
+	}
+
+	frame := []byte{b0, 0x80 | byte(len(payload)), 1, 2, 3, 4}
+	for i, b := range payload {
+		frame = append(frame, b^frame[2+i%4])
+	}
+
+	return frame
+}
+
+func TestConn_Echo(t *testing.T) {
+	srv, _ := echoServer(t, 0)
+	defer srv.Close()
+
+	conn := dial(t, srv)
+	defer conn.Close()
+
+	for _, size := range []int{0, 5, 125, 126, 70000} {
+		msg := bytes.Repeat([]byte("a"), size)
+		assert.NoError(t, conn.WriteMessage(TextMessage, msg, time.Now().Add(time.Second)))
+
+		msgType, got, err := conn.ReadMessage()
+		assert.NoError(t, err)
+		assert.Equal(t, TextMessage, msgType)
+		assert.Equal(t, string(msg), string(got), "size %d", size)
+	}
+}
+
+func TestConn_Fragmented(t *testing.T) {
+	srv, _ := echoServer(t, 0)
+	defer srv.Close()
+
+	conn := dial(t, srv)
+	defer conn.Close()
+
+	// A ping between fragments is answered, and the message is still assembled.
+	var frames []byte
+	frames = append(frames, rawFrame(false, TextMessage, []byte("Hello, "))...)
+	frames = append(frames, rawFrame(true, PingMessage, []byte("ping"))...)
+	frames = append(frames, rawFrame(true, continuation, []byte("world"))...)
+	_, err := conn.conn.Write(frames)
+	assert.NoError(t, err)
+
+	msgType, msg, err := conn.ReadMessage()
+	assert.NoError(t, err)
+	assert.Equal(t, PongMessage, msgType)
+	assert.Equal(t, "ping", string(msg))
+
+	msgType, msg, err = conn.ReadMessage()
+	assert.NoError(t, err)
+	assert.Equal(t, TextMessage, msgType)
+	assert.Equal(t, "Hello, world", string(msg))
+}
+
+func TestConn_Close(t *testing.T) {
+	srv, errs := echoServer(t, 0)
+	defer srv.Close()
+
+	conn := dial(t, srv)
+	defer conn.Close()
+
+	assert.NoError(t, conn.WriteClose(CloseNormal, "bye"))
+
+	var closeErr *CloseError
+	if err := <-errs; assert.True(t, errors.As(err, &closeErr), "expected *CloseError, got %v", err) {
+		assert.Equal(t, CloseNormal, closeErr.Code)
+		assert.Equal(t, "bye", closeErr.Text)
+	}
+
+	// The close is answered with the same code.
+	_, _, err := conn.ReadMessage()
+	if assert.True(t, errors.As(err, &closeErr), "expected *CloseError, got %v", err) {
+		assert.Equal(t, CloseNormal, closeErr.Code)
+	}
+
+	assert.Equal(t, ErrCloseSent, conn.WriteMessage(TextMessage, []byte("late"), time.Time{}))
+}
+
+func TestConn_ProtocolErrors(t *testing.T) {
+	unmasked := []byte{0x81, 0x02, 'h', 'i'}
+	long := make([]byte, 0, 10)
+	long = append(long, 0x81, 0x80|126, 0, 0, 1, 2, 3, 4)
+	binary.BigEndian.PutUint16(long[2:], 1000)
+
+	tests := []struct {
+		name  string
+		frame []byte
+		code  int
+	}{
+		{"unmasked", unmasked, CloseProtocolError},
+		{"reserved bits", append([]byte{0xc1}, rawFrame(true, TextMessage, []byte("hi"))[1:]...), CloseProtocolError},
+		{"unexpected continuation", rawFrame(true, continuation, []byte("hi")), CloseProtocolError},
+		{"fragmented control", rawFrame(false, PingMessage, nil), CloseProtocolError},
+		{"unknown opcode", rawFrame(true, 3, nil), CloseProtocolError},
+		{"invalid utf-8", rawFrame(true, TextMessage, []byte{0xff, 0xfe}), CloseInvalidPayload},
+		{"too big", long, CloseTooBig},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			srv, errs := echoServer(t, 100)
+			defer srv.Close()
+
+			conn := dial(t, srv)
+			defer conn.Close()
+
+			_, err := conn.conn.Write(test.frame)
+			assert.NoError(t, err)
+
+			var closeErr *CloseError
+			if err := <-errs; assert.True(t, errors.As(err, &closeErr), "expected *CloseError, got %v", err) {
+				assert.Equal(t, test.code, closeErr.Code)
+			}
+
+			_, _, err = conn.ReadMessage()
+			if assert.True(t, errors.As(err, &closeErr), "expected *CloseError, got %v", err) {
+				assert.Equal(t, test.code, closeErr.Code)
+			}
+		})
+	}
+}
+
+func TestUpgrade_BadHandshake(t *testing.T) {
+	srv, _ := echoServer(t, 0)
+	defer srv.Close()
+
+	res, err := http.Get(srv.URL)
+	assert.NoError(t, err)
+	res.Body.Close()
+	assert.Equal(t, http.StatusBadRequest, res.StatusCode)
+
+	req, _ := http.NewRequest(http.MethodGet, srv.URL, nil)
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Sec-WebSocket-Version", "8")
+	req.Header.Set("Sec-WebSocket-Key", "dGhlIHNhbXBsZSBub25jZQ==")
+
+	res, err = http.DefaultClient.Do(req)
+	assert.NoError(t, err)
+	res.Body.Close()
+	assert.Equal(t, http.StatusBadRequest, res.StatusCode)
+}
+
+func TestAcceptKey(t *testing.T) {
+	// Example from RFC 6455 section 1.3.
+	assert.Equal(t, "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=", acceptKey("dGhlIHNhbXBsZSBub25jZQ=="))
+}
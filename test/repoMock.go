@@ -1,31 +1,40 @@
 package test
 
-import "github.com/rejlersembriq/hooked/pkg/participant"
+import (
+	"context"
+	"github.com/rejlersembriq/hooked/pkg/participant"
+)
 
 // RepoMock is used to mock Participant Repository. Inject the desired behaviour.
 type RepoMock struct {
-	SaveHandler   func(participant participant.Participant) (*participant.Participant, participant.Error)
-	GetHandler    func(id string) (*participant.Participant, participant.Error)
-	GetAllHandler func() ([]*participant.Participant, participant.Error)
-	DeleteHandler func(id string) participant.Error
+	SaveHandler   func(ctx context.Context, participant participant.Participant) (*participant.Participant, participant.Error)
+	GetHandler    func(ctx context.Context, id string) (*participant.Participant, participant.Error)
+	GetAllHandler func(ctx context.Context) ([]*participant.Participant, participant.Error)
+	QueryHandler  func(ctx context.Context, query participant.Query) (*participant.Page, participant.Error)
+	DeleteHandler func(ctx context.Context, id string) participant.Error
 }
 
 // Save mocks participant.Repository Save.
-func (r *RepoMock) Save(participant participant.Participant) (*participant.Participant, participant.Error) {
-	return r.SaveHandler(participant)
+func (r *RepoMock) Save(ctx context.Context, participant participant.Participant) (*participant.Participant, participant.Error) {
+	return r.SaveHandler(ctx, participant)
 }
 
 // Get mocks participant.Repository Get.
-func (r *RepoMock) Get(id string) (*participant.Participant, participant.Error) {
-	return r.GetHandler(id)
+func (r *RepoMock) Get(ctx context.Context, id string) (*participant.Participant, participant.Error) {
+	return r.GetHandler(ctx, id)
 }
 
 // GetAll mocks participant.Repository GetAll.
-func (r *RepoMock) GetAll() ([]*participant.Participant, participant.Error) {
-	return r.GetAllHandler()
+func (r *RepoMock) GetAll(ctx context.Context) ([]*participant.Participant, participant.Error) {
+	return r.GetAllHandler(ctx)
+}
+
+// Query mocks participant.Repository Query.
+func (r *RepoMock) Query(ctx context.Context, query participant.Query) (*participant.Page, participant.Error) {
+	return r.QueryHandler(ctx, query)
 }
 
 // Delete mocks participant.Repository Delete.
-func (r *RepoMock) Delete(id string) participant.Error {
-	return r.DeleteHandler(id)
+func (r *RepoMock) Delete(ctx context.Context, id string) participant.Error {
+	return r.DeleteHandler(ctx, id)
 }